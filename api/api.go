@@ -0,0 +1,372 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package api lets programs such as imaging tools and agents embed GooGet
+// instead of running googet: install, update and remove packages and manage
+// the repos of a GooGet root.
+//
+// New reads the archs, cache life, proxy, allowed repos, signature and
+// cosign settings of googet.conf in the root into the fields of the Client.
+// The other settings of googet.conf, such as repoca or the publisher policy,
+// are variables of the install, client and download packages shared by the
+// Clients of the process, which embedders set themselves.
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/install"
+	"github.com/google/googet/v2/priority"
+	"github.com/google/googet/v2/system"
+	"github.com/google/logger"
+)
+
+// Files and directories of a GooGet root.
+const (
+	StateFile = "googet.state"
+	LockFile  = "googet.lock"
+	CacheDir  = "cache"
+	RepoDir   = "repos"
+	ConfFile  = "googet.conf"
+)
+
+// Client operates on a GooGet root. Its methods changing the root hold the
+// GooGet lock, so they can run alongside googet and the other Clients of
+// the root.
+type Client struct {
+	// Root is the GooGet root directory.
+	Root string
+	// Archs are the architectures packages are installed for, in order of
+	// preference.
+	Archs []string
+	// Sources, if set, are used instead of the repos of the .repo files.
+	Sources map[string]priority.Value
+	// CacheLife is how long the indexes of repos are cached.
+	CacheLife time.Duration
+	// Downloader fetches indexes and packages, by default a
+	// client.HTTPDownloader using ProxyServer and the transport settings of
+	// the .repo files.
+	Downloader  client.Downloader
+	ProxyServer string
+	// DBOnly only records changes in the state file, without installing or
+	// removing files or running scripts.
+	DBOnly bool
+	// AllowYanked allows installing versions yanked from their repo.
+	AllowYanked bool
+	// AllowedRepos restricts the repos used, see the AllowedRepos variable.
+	AllowedRepos []string
+	// Signatures and Cosign apply to local packages and the repos whose
+	// .repo entry doesn't replace them, see install.DefaultSignatures and
+	// install.DefaultCosign.
+	Signatures install.Signatures
+	Cosign     install.Cosign
+	// Confirm, if set, is asked whether to go ahead before installing,
+	// updating or removing packages.
+	Confirm func(prompt string) bool
+}
+
+// New returns a Client for root with the settings of the googet.conf in
+// root, installing packages for the architectures of the machine unless it
+// sets archs.
+func New(root string) (*Client, error) {
+	archs, err := system.InstallableArchs()
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{Root: root, Archs: archs, CacheLife: 3 * time.Minute}
+	gc, err := readConf(c.path(ConfFile))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.configure(gc); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) path(name string) string {
+	return filepath.Join(c.Root, name)
+}
+
+// lock takes the GooGet lock of the root, it returns an error if ctx is done
+// first.
+func (c *Client) lock(ctx context.Context) (func(), error) {
+	if err := os.MkdirAll(c.Root, 0774); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(c.path(LockFile), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	type result struct {
+		unlock func()
+		err    error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		unlock, err := Lock(f)
+		ch <- result{unlock, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.unlock, r.err
+	case <-ctx.Done():
+		// Release the lock once it is taken.
+		go func() {
+			if r := <-ch; r.err == nil {
+				r.unlock()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// begin takes the GooGet lock of the root and begins an operation, see
+// Begin. The returned func releases the lock.
+func (c *Client) begin(ctx context.Context) (*Op, func(), error) {
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	o, err := c.Begin()
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return o, unlock, nil
+}
+
+// downloader returns the Downloader of c, by default one using the transport
+// settings of rs.
+func (c *Client) downloader(rs repoSettings) client.Downloader {
+	if c.Downloader != nil {
+		return c.Downloader
+	}
+	return client.HTTPDownloader{ProxyServer: c.ProxyServer, Repos: rs.transports}
+}
+
+// Installed returns the installed packages.
+func (c *Client) Installed() (client.GooGetState, error) {
+	state, err := ReadState(c.path(StateFile))
+	if err != nil {
+		return nil, err
+	}
+	return *state, nil
+}
+
+// Install installs the packages pis and their dependencies from the repos,
+// the latest version of those without a version, packages with a version
+// also need an arch. Packages already installed at that version or a newer
+// one are skipped, as are those Confirm declines. It stops at the first
+// package failing to install, see Op.Install.
+func (c *Client) Install(ctx context.Context, pis ...goolib.PackageInfo) error {
+	o, unlock, err := c.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	for _, pi := range pis {
+		if _, _, err := o.Install(ctx, pi); err != nil && err != ErrCanceled {
+			return fmt.Errorf("error installing %s: %v", pi.Name, err)
+		}
+	}
+	return nil
+}
+
+// Update updates the installed packages to the latest version available,
+// migrates those renamed in their repo to their new name, and returns the
+// packages installed. It returns ErrCanceled if Confirm declines and stops
+// at the first package failing to update.
+func (c *Client) Update(ctx context.Context) ([]goolib.PackageInfo, error) {
+	o, unlock, err := c.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	rm, err := o.RepoMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pm := installedVersions(*o.State)
+	ud, mg := Updates(pm, rm, c.Archs), Migrations(pm, rm, c.Archs)
+	if ud == nil && mg == nil {
+		return nil, nil
+	}
+	if c.Confirm != nil && !c.Confirm("Perform update?") {
+		return nil, ErrCanceled
+	}
+	pis := append([]goolib.PackageInfo(nil), ud...)
+	for _, m := range mg {
+		pis = append(pis, m.To)
+	}
+	if err := o.Prefetch(ctx, pis); err != nil {
+		return nil, err
+	}
+	var done []goolib.PackageInfo
+	for _, pi := range ud {
+		if err := o.Update(ctx, pi); err != nil {
+			return done, fmt.Errorf("error updating %s: %v", pi.Name, err)
+		}
+		done = append(done, pi)
+	}
+	for _, m := range mg {
+		if err := o.Migrate(ctx, m); err != nil {
+			return done, fmt.Errorf("error migrating %s to %s: %v", m.From.Name, m.To.Name, err)
+		}
+		done = append(done, m.To)
+	}
+	return done, nil
+}
+
+// PendingUpdates returns the packages Update would install, sorted by name.
+// Migrations to a new name are not included.
+func (c *Client) PendingUpdates(ctx context.Context) ([]goolib.PackageInfo, error) {
+	o, err := c.Begin()
+	if err != nil {
+		return nil, err
+	}
+	rm, err := o.RepoMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ud := Updates(installedVersions(*o.State), rm, c.Archs)
+	sort.Slice(ud, func(i, j int) bool { return ud[i].String() < ud[j].String() })
+	return ud, nil
+}
+
+func installedVersions(state client.GooGetState) map[string]string {
+	pm := make(map[string]string)
+	for _, p := range state {
+		pm[p.PackageSpec.Name+"."+p.PackageSpec.Arch] = p.PackageSpec.Version
+	}
+	return pm
+}
+
+// Updates returns the latest versions available in rm of the packages in
+// pm, which maps the name and arch of installed packages to their version,
+// that are not installed. A version from a repo of higher priority than the
+// default is installed even if it is older.
+func Updates(pm map[string]string, rm client.RepoMap, archs []string) []goolib.PackageInfo {
+	var ud []goolib.PackageInfo
+	for p, ver := range pm {
+		pi := goolib.PkgNameSplit(p)
+		if _, ok := client.Renamed(pi.Name, rm); ok {
+			// Renamed packages are migrated instead.
+			continue
+		}
+		v, r, _, err := client.FindRepoLatest(pi, rm, archs)
+		if err != nil {
+			// This error is because this installed package is not available in a repo.
+			logger.Info(err)
+			continue
+		}
+		c, err := goolib.ComparePriorityVersion(rm[r].Priority, v, priority.Default, ver)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		if c < 1 {
+			logger.Infof("%s - highest priority version already installed", p)
+			continue
+		}
+		// The versions might actually be the same even though the priorities are different,
+		// so do another check to skip reinstall of the same version.
+		c, err = goolib.Compare(v, ver)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		if c == 0 {
+			logger.Infof("%s - same version installed", p)
+			continue
+		}
+		op := "Upgrade"
+		if c == -1 {
+			op = "Downgrade"
+		}
+		logger.Infof("%s for package %s, %s installed and %s available from %s.", op, p, ver, v, r)
+		ud = append(ud, goolib.PackageInfo{Name: pi.Name, Arch: pi.Arch, Ver: v})
+	}
+	return ud
+}
+
+// Remove removes the installed packages pis, the arch and version of which
+// are optional, and the packages depending on them, skipping those Confirm
+// declines. It stops at the first package failing to be removed.
+func (c *Client) Remove(ctx context.Context, pis ...goolib.PackageInfo) error {
+	o, unlock, err := c.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	for _, pi := range pis {
+		ins := o.Match(pi)
+		switch len(ins) {
+		case 0:
+			return fmt.Errorf("package %s is not installed", pi.Name)
+		case 1:
+		default:
+			return fmt.Errorf("more than one %s installed: %s", pi.Name, strings.Join(ins, ", "))
+		}
+		pi = goolib.PkgNameSplit(ins[0])
+		if err := o.Remove(ctx, pi); err != nil && err != ErrCanceled {
+			return fmt.Errorf("error removing %s: %v", pi.Name, err)
+		}
+	}
+	return nil
+}
+
+// Repos returns the .repo files of the root.
+func (c *Client) Repos() ([]RepoFile, error) {
+	return ReadRepoFiles(c.path(RepoDir))
+}
+
+// AddRepo adds e to the .repo file named file, by default after the name of
+// e, see AddRepo.
+func (c *Client) AddRepo(ctx context.Context, file string, e RepoEntry) error {
+	if file == "" {
+		file = e.Name + ".repo"
+	}
+	if !strings.HasSuffix(file, ".repo") || filepath.Base(file) != file {
+		return fmt.Errorf("invalid repo file name %q, want a name ending in .repo", file)
+	}
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := os.MkdirAll(c.path(RepoDir), 0774); err != nil {
+		return err
+	}
+	_, err = addRepo(filepath.Join(c.path(RepoDir), file), e, c.AllowedRepos)
+	return err
+}
+
+// RemoveRepo removes the repo named name, see RemoveRepo.
+func (c *Client) RemoveRepo(ctx context.Context, name string) error {
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	_, _, err = RemoveRepo(c.path(RepoDir), name)
+	return err
+}
@@ -0,0 +1,206 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/googettest"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/install"
+	"github.com/google/googet/v2/priority"
+)
+
+func TestClient(t *testing.T) {
+	root := t.TempDir()
+	dst := filepath.Join(root, "dst")
+	gen := func(ver string) *googettest.Package {
+		p, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: ver, Files: map[string]string{"bin": dst}}, map[string][]byte{"bin/foo": []byte(ver)})
+		if err != nil {
+			t.Fatalf("error running GenGoo: %v", err)
+		}
+		return p
+	}
+	const repo = "https://repo.example.com/repo"
+	d := googettest.NewDownloader()
+	if err := d.AddRepo(repo, gen("1.0.0@1"), gen("2.0.0@1")); err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{Root: root, Archs: []string{"noarch"}, Sources: map[string]priority.Value{repo: priority.Default}, Downloader: d}
+	ctx := context.Background()
+
+	if err := c.Install(ctx, goolib.PackageInfo{Name: "foo", Arch: "noarch", Ver: "1.0.0@1"}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dst, "foo")); err != nil || string(b) != "1.0.0@1" {
+		t.Errorf("installed file = %q, %v, want 1.0.0@1", b, err)
+	}
+
+	ud, err := c.Update(ctx)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if len(ud) != 1 || ud[0].Ver != "2.0.0@1" {
+		t.Errorf("Update = %v, want foo 2.0.0@1", ud)
+	}
+	state, err := c.Installed()
+	if err != nil {
+		t.Fatalf("Installed: %v", err)
+	}
+	if len(state) != 1 || state[0].PackageSpec.Version != "2.0.0@1" {
+		t.Errorf("Installed after update = %+v, want foo 2.0.0@1", state)
+	}
+
+	if err := c.Remove(ctx, goolib.PackageInfo{Name: "foo"}); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if state, _ := c.Installed(); len(state) != 0 {
+		t.Errorf("Installed after remove = %+v, want none", state)
+	}
+	if err := c.Remove(ctx, goolib.PackageInfo{Name: "foo"}); err == nil {
+		t.Error("Remove of a package not installed succeeded")
+	}
+}
+
+func TestClientLockCanceled(t *testing.T) {
+	c := &Client{Root: t.TempDir()}
+	unlock, err := c.lock(context.Background())
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := c.Install(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Install with the lock held = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestClientSettings(t *testing.T) {
+	root := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(root, "foo.repo"), []byte("url: https://foo.com/googet/foo\n"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{Root: root, AllowedRepos: []string{"https://bar.com"}}
+	o, err := c.Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if len(o.Sources()) != 0 {
+		t.Errorf("Sources = %v, want none allowed", o.Sources())
+	}
+
+	// The settings of a Client apply to its operations, not to those of
+	// other Clients.
+	p, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}, nil)
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	const repo = "https://repo.example.com/repo"
+	d := googettest.NewDownloader()
+	if err := d.AddRepo(repo, p); err != nil {
+		t.Fatal(err)
+	}
+	newClient := func(s install.Signatures) *Client {
+		return &Client{Root: t.TempDir(), Archs: []string{"noarch"}, Sources: map[string]priority.Value{repo: priority.Default}, Downloader: d, Signatures: s}
+	}
+	enforce, off := newClient(install.Signatures{Policy: install.SignatureEnforce}), newClient(install.Signatures{})
+	ctx := context.Background()
+	if err := enforce.Install(ctx, goolib.PackageInfo{Name: "foo"}); err == nil {
+		t.Error("Install of an unsigned package with the enforce policy succeeded")
+	}
+	if err := off.Install(ctx, goolib.PackageInfo{Name: "foo"}); err != nil {
+		t.Errorf("Install of an unsigned package with the off policy: %v", err)
+	}
+
+	off.Confirm = func(string) bool { return false }
+	if err := off.Remove(ctx, goolib.PackageInfo{Name: "foo"}); err != nil {
+		t.Fatalf("Remove declined: %v", err)
+	}
+	if state, _ := off.Installed(); len(state) != 1 {
+		t.Errorf("Installed after a declined remove = %+v, want foo", state)
+	}
+}
+
+func TestCheckStatus(t *testing.T) {
+	repo := client.Repo{Packages: []goolib.RepoSpec{
+		{PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}},
+		{PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "2.0.0@1"}, Status: goolib.StatusYanked, StatusReason: "broken"},
+		{PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "3.0.0@1"}, Status: goolib.StatusDeprecated},
+	}}
+	for _, tt := range []struct {
+		ver         string
+		allowYanked bool
+		wantErr     bool
+	}{
+		{"1.0.0@1", false, false},
+		{"2.0.0@1", false, true},
+		{"2.0.0@1", true, false},
+		{"3.0.0@1", false, false},
+	} {
+		pi := goolib.PackageInfo{Name: "foo", Arch: "noarch", Ver: tt.ver}
+		if err := CheckStatus(pi, repo, tt.allowYanked); (err != nil) != tt.wantErr {
+			t.Errorf("CheckStatus(%v, allowYanked=%t) = %v, want error: %t", pi, tt.allowYanked, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMarkExplicit(t *testing.T) {
+	state := &client.GooGetState{
+		{PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}, InstallReason: client.ReasonDependency},
+		{PackageSpec: &goolib.PkgSpec{Name: "bar", Arch: "noarch", Version: "1.0.0@1"}},
+	}
+	if !markExplicit(state, goolib.PackageInfo{Name: "foo", Arch: "noarch", Ver: "0.1.0@1"}) {
+		t.Error("markExplicit of a dependency returned false")
+	}
+	if markExplicit(state, goolib.PackageInfo{Name: "bar", Arch: "noarch"}) {
+		t.Error("markExplicit of a package installed by an older GooGet returned true")
+	}
+	if (*state)[0].InstallReason != client.ReasonExplicit || (*state)[1].InstallReason != "" {
+		t.Errorf("markExplicit left state %+v", *state)
+	}
+}
+
+func TestClientRepos(t *testing.T) {
+	c := &Client{Root: t.TempDir()}
+	ctx := context.Background()
+	if err := c.AddRepo(ctx, "", RepoEntry{Name: "foo", URL: "https://foo.com/googet/foo"}); err != nil {
+		t.Fatalf("AddRepo: %v", err)
+	}
+	if err := c.AddRepo(ctx, "foo.repo", RepoEntry{Name: "bar", URL: "https://foo.com/googet/bar"}); err != nil {
+		t.Fatalf("AddRepo: %v", err)
+	}
+	if err := c.AddRepo(ctx, "../foo.repo", RepoEntry{Name: "baz", URL: "https://foo.com/googet/baz"}); err == nil {
+		t.Error("AddRepo outside the repo directory succeeded")
+	}
+	rfs, err := c.Repos()
+	if err != nil {
+		t.Fatalf("Repos: %v", err)
+	}
+	if len(rfs) != 1 || len(rfs[0].Entries) != 2 {
+		t.Fatalf("Repos = %+v, want foo.repo with foo and bar", rfs)
+	}
+	if err := c.RemoveRepo(ctx, "FOO"); err != nil {
+		t.Fatalf("RemoveRepo: %v", err)
+	}
+	if err := c.RemoveRepo(ctx, "foo"); err != ErrRepoNotFound {
+		t.Errorf("RemoveRepo of a removed repo = %v, want %v", err, ErrRepoNotFound)
+	}
+}
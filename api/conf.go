@@ -0,0 +1,123 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-yaml/yaml"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/install"
+	"github.com/google/logger"
+)
+
+// conf holds the settings of googet.conf a Client uses.
+type conf struct {
+	Archs           []string
+	ArchPreference  []string
+	CacheLife       string
+	ProxyServer     string
+	AllowedRepos    []string
+	SignatureKeys   []string
+	SignaturePolicy string
+	CosignKeys      []string
+	CosignIdentity  string
+	CosignIssuer    string
+	CosignPolicy    string
+}
+
+// readConf reads the conf file cf and the fragments in its .d directory,
+// merged in name order. Missing files are skipped.
+func readConf(cf string) (*conf, error) {
+	frags, err := filepath.Glob(filepath.Join(cf+".d", "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(frags)
+	gc := &conf{}
+	for _, f := range append([]string{cf}, frags...) {
+		b, err := ioutil.ReadFile(f)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(b, gc); err != nil {
+			return nil, fmt.Errorf("error unmarshalling conf file %s: %v", f, err)
+		}
+	}
+	return gc, nil
+}
+
+// configure applies the settings of gc to c.
+func (c *Client) configure(gc *conf) error {
+	if gc.Archs != nil {
+		c.Archs = gc.Archs
+	}
+	c.Archs = PreferArchs(c.Archs, gc.ArchPreference)
+	if gc.CacheLife != "" {
+		l, err := time.ParseDuration(gc.CacheLife)
+		if err != nil {
+			return fmt.Errorf("error reading cachelife: %v", err)
+		}
+		c.CacheLife = l
+	}
+	if gc.ProxyServer != "" {
+		c.ProxyServer = gc.ProxyServer
+	}
+	c.AllowedRepos = gc.AllowedRepos
+	var err error
+	// Falling back to no checks would silently weaken the policy.
+	if c.Signatures.Policy, err = install.ParseSignaturePolicy(gc.SignaturePolicy); err != nil {
+		return fmt.Errorf("error reading signaturepolicy: %v", err)
+	}
+	if c.Signatures.Keys, err = ReadSignatureKeys(gc.SignatureKeys); err != nil {
+		return fmt.Errorf("error reading signaturekeys: %v", err)
+	}
+	if c.Cosign.Policy, err = install.ParseSignaturePolicy(gc.CosignPolicy); err != nil {
+		return fmt.Errorf("error reading cosignpolicy: %v", err)
+	}
+	if c.Cosign.Keys, err = ReadCosignKeys(gc.CosignKeys); err != nil {
+		return fmt.Errorf("error reading cosignkeys: %v", err)
+	}
+	c.Cosign.Identity, c.Cosign.Issuer = gc.CosignIdentity, gc.CosignIssuer
+	return nil
+}
+
+// PreferArchs moves the archs listed in pref to the front of archs, in the
+// order of pref, ignoring those that are not installable.
+func PreferArchs(archs, pref []string) []string {
+	var res []string
+	for _, a := range pref {
+		if !goolib.ContainsString(a, archs) {
+			logger.Warningf("Preferred arch %q is not installable, ignoring it", a)
+			continue
+		}
+		if !goolib.ContainsString(a, res) {
+			res = append(res, a)
+		}
+	}
+	for _, a := range archs {
+		if !goolib.ContainsString(a, res) {
+			res = append(res, a)
+		}
+	}
+	return res
+}
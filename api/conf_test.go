@@ -0,0 +1,82 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/googet/v2/install"
+)
+
+func TestNewReadsConf(t *testing.T) {
+	root := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(root, ConfFile), []byte("archs: [noarch, x86_64]\narchpreference: [x86_64]\ncachelife: 10m\nallowedrepos: [https://repo.example.com]\nsignaturepolicy: warn\n"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, ConfFile+".d"), 0774); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, ConfFile+".d", "proxy.conf"), []byte("proxyserver: http://proxy.example.com\n"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(root)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if want := []string{"x86_64", "noarch"}; !reflect.DeepEqual(c.Archs, want) {
+		t.Errorf("Archs = %v, want %v", c.Archs, want)
+	}
+	if c.CacheLife != 10*time.Minute {
+		t.Errorf("CacheLife = %v, want 10m", c.CacheLife)
+	}
+	if c.ProxyServer != "http://proxy.example.com" {
+		t.Errorf("ProxyServer = %q, want the one of the fragment", c.ProxyServer)
+	}
+	if want := []string{"https://repo.example.com"}; !reflect.DeepEqual(c.AllowedRepos, want) {
+		t.Errorf("AllowedRepos = %v, want %v", c.AllowedRepos, want)
+	}
+	if c.Signatures.Policy != install.SignatureWarn {
+		t.Errorf("Signatures.Policy = %v, want %v", c.Signatures.Policy, install.SignatureWarn)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(root, ConfFile), []byte("signaturepolicy: bogus\n"), 0664); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(root); err == nil {
+		t.Error("New with an invalid signaturepolicy succeeded")
+	}
+}
+
+func TestPreferArchs(t *testing.T) {
+	archs := []string{"noarch", "x86_32", "x86_64"}
+	table := []struct {
+		pref []string
+		want []string
+	}{
+		{nil, []string{"noarch", "x86_32", "x86_64"}},
+		{[]string{"x86_64"}, []string{"x86_64", "noarch", "x86_32"}},
+		{[]string{"x86_64", "x86_32"}, []string{"x86_64", "x86_32", "noarch"}},
+		{[]string{"arm64", "x86_64", "x86_64"}, []string{"x86_64", "noarch", "x86_32"}},
+	}
+	for _, tt := range table {
+		if got := PreferArchs(archs, tt.pref); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PreferArchs(%v, %v) = %v, want %v", archs, tt.pref, got, tt.want)
+		}
+	}
+}
@@ -1,3 +1,6 @@
+//go:build linux || darwin
+// +build linux darwin
+
 //  Copyright 2019 Google Inc. All Rights Reserved.
 //
 //  Licensed under the Apache License, Version 2.0 (the "License");
@@ -12,18 +15,21 @@
 //  See the License for the specific language governing permissions and
 //  limitations under the License.
 
-package main
+package api
 
 import (
 	"os"
 	"syscall"
 )
 
-func lock(f *os.File) error {
+// Lock takes an exclusive lock on f, waiting until no other process
+// holds it, and returns a function releasing it and closing f.
+func Lock(f *os.File) (func(), error) {
 	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
-		return err
+		return nil, err
 	}
 
-	deferredFuncs = append(deferredFuncs, func() { syscall.Flock(int(f.Fd()), syscall.LOCK_UN); f.Close(); os.Remove(lockFile) })
-	return nil
+	// The lock file is kept, a process waiting for the lock would otherwise
+	// hold it on the removed file while another one locks a new file.
+	return func() { syscall.Flock(int(f.Fd()), syscall.LOCK_UN); f.Close() }, nil
 }
@@ -12,7 +12,7 @@
 //  See the License for the specific language governing permissions and
 //  limitations under the License.
 
-package main
+package api
 
 import (
 	"errors"
@@ -31,8 +31,8 @@ var (
 
 const (
 	// https://docs.microsoft.com/en-us/windows/desktop/api/fileapi/nf-fileapi-lockfileex
-	LOCKFILE_EXCLUSIVE_LOCK   = 2
-	LOCKFILE_FAIL_IMMEDIATELY = 1
+	lockfileExclusiveLock   = 2
+	lockfileFailImmediately = 1
 )
 
 func lockFileEx(hFile uintptr, dwFlags, nNumberOfBytesToLockLow, nNumberOfBytesToLockHigh uint32, lpOverlapped *syscall.Overlapped) (err error) {
@@ -66,11 +66,12 @@ func unlockFileEx(hFile uintptr, nNumberOfBytesToLockLow, nNumberOfBytesToLockHi
 	return nil
 }
 
-func lock(f *os.File) error {
-	if err := lockFileEx(f.Fd(), LOCKFILE_EXCLUSIVE_LOCK, 1, 0, &syscall.Overlapped{}); err != nil {
-		return err
+// Lock takes an exclusive lock on f, waiting until no other process
+// holds it, and returns a function releasing it, closing f and removing it.
+func Lock(f *os.File) (func(), error) {
+	if err := lockFileEx(f.Fd(), lockfileExclusiveLock, 1, 0, &syscall.Overlapped{}); err != nil {
+		return nil, err
 	}
 
-	deferredFuncs = append(deferredFuncs, func() { unlockFileEx(f.Fd(), 1, 0, &syscall.Overlapped{}); f.Close(); os.Remove(lockFile) })
-	return nil
+	return func() { unlockFileEx(f.Fd(), 1, 0, &syscall.Overlapped{}); f.Close(); os.Remove(f.Name()) }, nil
 }
@@ -0,0 +1,377 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/install"
+	"github.com/google/googet/v2/priority"
+	"github.com/google/googet/v2/remove"
+	"github.com/google/logger"
+)
+
+// ErrCanceled is returned by the operations Confirm of the Client declined.
+var ErrCanceled = errors.New("canceled")
+
+// Op is an operation on the root of a Client, whose methods install, update
+// and remove one package at a time. The caller holds the GooGet lock of the
+// root, googet does so for its commands and the methods of the Client take
+// it themselves.
+type Op struct {
+	// State is the state of the root, which the methods changing it write to
+	// the state file.
+	State *client.GooGetState
+	c     *Client
+	rs    repoSettings
+	d     client.Downloader
+	rm    client.RepoMap
+}
+
+// Begin reads the state and the repos of the root for an operation.
+func (c *Client) Begin() (*Op, error) {
+	state, err := ReadState(c.path(StateFile))
+	if err != nil {
+		return nil, err
+	}
+	rs, err := readRepos(c.path(RepoDir), c.AllowedRepos, c.Signatures, c.Cosign)
+	if err != nil {
+		return nil, err
+	}
+	return &Op{State: state, c: c, rs: rs, d: c.downloader(rs)}, nil
+}
+
+// context returns a copy of ctx with which packages are verified and indexes
+// cached with the settings of the Client and its repos.
+func (o *Op) context(ctx context.Context) context.Context {
+	ctx = install.WithVerification(ctx, install.Verification{
+		Signatures:     o.c.Signatures,
+		RepoSignatures: o.rs.signatures,
+		Cosign:         o.c.Cosign,
+		RepoCosign:     o.rs.cosign,
+	})
+	return client.WithRepoCacheLife(ctx, o.rs.cacheLife)
+}
+
+func (o *Op) writeState() error {
+	return WriteState(o.State, o.c.path(StateFile))
+}
+
+// Sources returns the repos of the operation, Sources of the Client if set.
+func (o *Op) Sources() map[string]priority.Value {
+	// The settings of the .repo files also apply to Sources.
+	if o.c.Sources != nil {
+		return o.c.Sources
+	}
+	return o.rs.priorities
+}
+
+// RepoMap returns the indexes of the repos, fetched on the first call. The
+// repos whose index can't be fetched are left out.
+func (o *Op) RepoMap(ctx context.Context) (client.RepoMap, error) {
+	if o.rm != nil {
+		return o.rm, nil
+	}
+	repos := o.Sources()
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("no repos defined in %s", o.c.path(RepoDir))
+	}
+	if err := os.MkdirAll(o.c.path(CacheDir), 0774); err != nil {
+		return nil, err
+	}
+	o.rm = client.AvailableVersions(o.context(ctx), repos, o.c.path(CacheDir), o.c.CacheLife, o.d)
+	return o.rm, nil
+}
+
+// Resolve returns pi with the version and arch it resolves to in the repos,
+// the latest version if it has none, and the repo it is installed from.
+// Without a version, a package renamed in its repo resolves to its new name.
+func (o *Op) Resolve(ctx context.Context, pi goolib.PackageInfo) (goolib.PackageInfo, string, error) {
+	rm, err := o.RepoMap(ctx)
+	if err != nil {
+		return pi, "", err
+	}
+	if n, ok := client.Renamed(pi.Name, rm); ok && pi.Ver == "" {
+		logger.Warningf("%s was renamed to %s, installing %s instead", pi.Name, n, n)
+		pi.Name = n
+	}
+	if pi.Ver == "" {
+		v, _, a, err := client.FindRepoLatest(pi, rm, o.c.Archs)
+		if err != nil {
+			return pi, "", fmt.Errorf("can't resolve version for package %q: %v", pi.Name, err)
+		}
+		pi.Ver, pi.Arch = v, a
+	}
+	if _, err := goolib.ParseVersion(pi.Ver); err != nil {
+		return pi, "", fmt.Errorf("invalid package version %q: %v", pi.Ver, err)
+	}
+	r, err := client.WhatRepo(pi, rm)
+	if err != nil {
+		return pi, "", fmt.Errorf("error finding %s.%s.%s in repo: %v", pi.Name, pi.Arch, pi.Ver, err)
+	}
+	return pi, r, nil
+}
+
+// Install installs pi and its dependencies from the repos, see Resolve, and
+// records pi as explicitly installed. It returns pi as resolved and reports
+// whether it was installed, which it is not if that version or a newer one
+// already is. Versions yanked from their repo are only installed if
+// AllowYanked is set.
+func (o *Op) Install(ctx context.Context, pi goolib.PackageInfo) (goolib.PackageInfo, bool, error) {
+	pi, r, err := o.Resolve(ctx, pi)
+	if err != nil {
+		return pi, false, err
+	}
+	ni, err := install.NeedsInstallation(pi, *o.State)
+	if err != nil {
+		return pi, false, err
+	}
+	if !ni {
+		if markExplicit(o.State, pi) {
+			return pi, false, o.writeState()
+		}
+		return pi, false, nil
+	}
+	if err := CheckStatus(pi, o.rm[r], o.c.AllowYanked); err != nil {
+		return pi, false, err
+	}
+	if o.c.Confirm != nil {
+		b, err := enumerateDeps(pi, o.rm, r, o.c.Archs, *o.State)
+		if err != nil {
+			return pi, false, err
+		}
+		if !o.c.Confirm(b.String()) {
+			return pi, false, ErrCanceled
+		}
+	}
+	if err := o.Prefetch(ctx, []goolib.PackageInfo{pi}); err != nil {
+		return pi, false, err
+	}
+	err = install.FromRepo(o.context(ctx), pi, r, o.c.path(CacheDir), o.rm, o.c.Archs, o.State, o.c.DBOnly, o.d)
+	if err == nil {
+		markExplicit(o.State, pi)
+	}
+	// A failed install can still have installed files to record.
+	if werr := o.writeState(); err == nil {
+		err = werr
+	}
+	return pi, err == nil, err
+}
+
+// InstallFile installs the local package file path, even if its version is
+// installed if reinstall is set.
+func (o *Op) InstallFile(ctx context.Context, path string, reinstall bool) error {
+	if o.c.Confirm != nil && !o.c.Confirm(fmt.Sprintf("Install %s?", filepath.Base(path))) {
+		return ErrCanceled
+	}
+	err := install.FromDisk(o.context(ctx), path, o.c.path(CacheDir), o.State, o.c.DBOnly, reinstall)
+	// A failed install can still have installed files to record.
+	if werr := o.writeState(); err == nil {
+		err = werr
+	}
+	return err
+}
+
+// Reinstall installs the installed package pi again, downloading it again if
+// redownload is set.
+func (o *Op) Reinstall(ctx context.Context, pi goolib.PackageInfo, redownload bool) error {
+	ps, err := o.State.GetPackageState(pi)
+	if err != nil {
+		return fmt.Errorf("cannot reinstall something that is not already installed")
+	}
+	if o.c.Confirm != nil && !o.c.Confirm(fmt.Sprintf("Reinstall %s?", pi.Name)) {
+		return ErrCanceled
+	}
+	err = install.Reinstall(o.context(ctx), ps, o.State, redownload, o.d)
+	if werr := o.writeState(); err == nil {
+		err = werr
+	}
+	return err
+}
+
+// Prefetch downloads the packages pis and their dependencies to the cache,
+// unless DBOnly is set, so that installing them doesn't wait for downloads.
+func (o *Op) Prefetch(ctx context.Context, pis []goolib.PackageInfo) error {
+	if o.c.DBOnly {
+		return nil
+	}
+	rm, err := o.RepoMap(ctx)
+	if err != nil {
+		return err
+	}
+	install.Prefetch(o.context(ctx), pis, o.c.path(CacheDir), rm, o.c.Archs, *o.State, o.d)
+	return nil
+}
+
+// Update installs pi, an update of an installed package, see Updates.
+func (o *Op) Update(ctx context.Context, pi goolib.PackageInfo) error {
+	rm, err := o.RepoMap(ctx)
+	if err != nil {
+		return err
+	}
+	r, err := client.WhatRepo(pi, rm)
+	if err != nil {
+		return err
+	}
+	err = install.FromRepo(o.context(ctx), pi, r, o.c.path(CacheDir), rm, o.c.Archs, o.State, o.c.DBOnly, o.d)
+	// A failed update can still have installed files to record.
+	if werr := o.writeState(); err == nil {
+		err = werr
+	}
+	return err
+}
+
+// Migrate moves an installed package to the name it was renamed to, see
+// Migrations.
+func (o *Op) Migrate(ctx context.Context, m Migration) error {
+	rm, err := o.RepoMap(ctx)
+	if err != nil {
+		return err
+	}
+	err = install.Migrate(o.context(ctx), m.From, m.To, m.Repo, o.c.path(CacheDir), rm, o.c.Archs, o.State, o.c.DBOnly, o.d)
+	if werr := o.writeState(); err == nil {
+		err = werr
+	}
+	return err
+}
+
+// Match returns the name and arch of the installed packages matching pi,
+// whose arch and version are optional.
+func (o *Op) Match(pi goolib.PackageInfo) []string {
+	var ins []string
+	for _, ps := range *o.State {
+		if ps.Match(pi) {
+			ins = append(ins, ps.PackageSpec.Name+"."+ps.PackageSpec.Arch)
+		}
+	}
+	return ins
+}
+
+// Remove removes the installed package pi and the packages depending on it.
+func (o *Op) Remove(ctx context.Context, pi goolib.PackageInfo) error {
+	deps, dl := remove.EnumerateDeps(pi, *o.State)
+	if o.c.Confirm != nil {
+		var b bytes.Buffer
+		fmt.Fprintln(&b, "The following packages will be removed:")
+		for _, d := range dl {
+			fmt.Fprintln(&b, "  "+d)
+		}
+		fmt.Fprintf(&b, "Do you wish to remove %s and all dependencies?", pi.Name)
+		if !o.c.Confirm(b.String()) {
+			return ErrCanceled
+		}
+	}
+	// Uninstalling can need the package, which is downloaded again if it is
+	// no longer cached.
+	if err := remove.All(o.context(ctx), pi, deps, o.State, o.c.DBOnly, o.d); err != nil {
+		return err
+	}
+	return o.writeState()
+}
+
+// markExplicit records the installed package pi, which was installed as a
+// dependency, as explicitly installed as it was asked for, and reports
+// whether it was.
+func markExplicit(state *client.GooGetState, pi goolib.PackageInfo) bool {
+	pi = goolib.PackageInfo{Name: pi.Name, Arch: pi.Arch}
+	if ps, err := state.GetPackageState(pi); err != nil || !ps.Dependency() {
+		return false
+	}
+	logger.Infof("Marking %s.%s as explicitly installed", pi.Name, pi.Arch)
+	return state.SetInstallReason(pi, client.ReasonExplicit)
+}
+
+// CheckStatus returns an error if pi is yanked in repo unless allowYanked is
+// set, deprecated versions only log a warning.
+func CheckStatus(pi goolib.PackageInfo, repo client.Repo, allowYanked bool) error {
+	rs, err := client.FindRepoSpec(pi, repo)
+	if err != nil {
+		return err
+	}
+	var reason string
+	if rs.StatusReason != "" {
+		reason = ": " + rs.StatusReason
+	}
+	switch rs.Status {
+	case goolib.StatusYanked:
+		if !allowYanked {
+			return fmt.Errorf("%s.%s.%s was yanked from the repo%s", pi.Name, pi.Arch, pi.Ver, reason)
+		}
+		logger.Warningf("Installing yanked version %s.%s.%s%s", pi.Name, pi.Arch, pi.Ver, reason)
+	case goolib.StatusDeprecated:
+		logger.Warningf("%s.%s.%s is deprecated%s", pi.Name, pi.Arch, pi.Ver, reason)
+	}
+	return nil
+}
+
+func enumerateDeps(pi goolib.PackageInfo, rm client.RepoMap, r string, archs []string, state client.GooGetState) (*bytes.Buffer, error) {
+	dl, err := install.ListDeps(pi, rm, r, archs)
+	if err != nil {
+		return nil, fmt.Errorf("error listing dependencies for %s.%s.%s: %v", pi.Name, pi.Arch, pi.Ver, err)
+	}
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "The following packages will be installed:")
+	for _, di := range dl {
+		ni, err := install.NeedsInstallation(di, state)
+		if err != nil {
+			return nil, err
+		}
+		if ni {
+			fmt.Fprintf(&b, "  %s.%s.%s\n", di.Name, di.Arch, di.Ver)
+		}
+	}
+	fmt.Fprintf(&b, "Do you wish to install %s.%s.%s and all dependencies?", pi.Name, pi.Arch, pi.Ver)
+	return &b, nil
+}
+
+// Migration moves the installed package From to the name it was renamed to,
+// the version To from Repo.
+type Migration struct {
+	From, To goolib.PackageInfo
+	Repo     string
+}
+
+// Migrations returns the migrations of the installed packages in pm, see
+// Updates, that were renamed to the latest version of their new name.
+// Packages whose new name is already installed are left alone with a
+// warning.
+func Migrations(pm map[string]string, rm client.RepoMap, archs []string) []Migration {
+	var mg []Migration
+	for p, ver := range pm {
+		pi := goolib.PkgNameSplit(p)
+		n, ok := client.Renamed(pi.Name, rm)
+		if !ok {
+			continue
+		}
+		if _, ok := pm[n+"."+pi.Arch]; ok {
+			logger.Warningf("%s was renamed to %s, which is also installed, remove %s", p, n, pi.Name)
+			continue
+		}
+		v, r, a, err := client.FindRepoLatest(goolib.PackageInfo{Name: n, Arch: pi.Arch}, rm, archs)
+		if err != nil {
+			logger.Errorf("%s was renamed to %s: %v", p, n, err)
+			continue
+		}
+		logger.Warningf("%s was renamed to %s, migrating %s installed to %s.%s %s from %s.", p, n, ver, n, a, v, r)
+		mg = append(mg, Migration{From: pi, To: goolib.PackageInfo{Name: n, Arch: a, Ver: v}, Repo: r})
+	}
+	return mg
+}
@@ -0,0 +1,661 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-yaml/yaml"
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/install"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/googet/v2/priority"
+	"github.com/google/logger"
+)
+
+// RepoFile is a .repo file and the repos it lists.
+type RepoFile struct {
+	FileName string
+	Entries  []RepoEntry
+}
+
+// RepoEntry is a repo listed in a .repo file.
+type RepoEntry struct {
+	Name     string
+	URL      string
+	UseOAuth bool
+	Priority priority.Value `yaml:",omitempty"`
+	// AllowHTTP allows the repo to be served over plain HTTP.
+	AllowHTTP bool `yaml:",omitempty"`
+	// ProxyServer, CA, ClientCert, ClientKey and Timeout replace the
+	// transport settings of googet.conf for the repo, see
+	// client.RepoTransport. CA and the client certificate and key are PEM
+	// files, the key defaulting to ClientCert.
+	ProxyServer string `yaml:",omitempty"`
+	CA          string `yaml:",omitempty"`
+	ClientCert  string `yaml:",omitempty"`
+	ClientKey   string `yaml:",omitempty"`
+	Timeout     string `yaml:",omitempty"`
+	// SignatureKey, a PEM file of an ed25519 public key, and
+	// SignaturePolicy replace those of googet.conf for the packages of the
+	// repo.
+	SignatureKey    string `yaml:",omitempty"`
+	SignaturePolicy string `yaml:",omitempty"`
+	// CosignKey, CosignIdentity, CosignIssuer and CosignPolicy replace
+	// those of googet.conf for the cosign bundles of the packages of the
+	// repo.
+	CosignKey      string `yaml:",omitempty"`
+	CosignIdentity string `yaml:",omitempty"`
+	CosignIssuer   string `yaml:",omitempty"`
+	CosignPolicy   string `yaml:",omitempty"`
+	// Headers are added to the requests for the index and packages of the
+	// repo, as some artifact proxies require.
+	Headers map[string]string `yaml:",omitempty"`
+	// CacheLife replaces the cachelife of googet.conf and the freshness set
+	// by the repo server for the index of the repo.
+	CacheLife string `yaml:",omitempty"`
+}
+
+// UnmarshalYAML provides custom unmarshalling for RepoEntry objects.
+func (r *RepoEntry) UnmarshalYAML(unmarshal func(any) error) error {
+	var raw map[string]interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	u := make(map[string]string)
+	for k, rv := range raw {
+		if strings.ToLower(k) == "headers" {
+			if err := r.unmarshalHeaders(rv); err != nil {
+				return err
+			}
+			continue
+		}
+		if rv != nil {
+			u[k] = fmt.Sprint(rv)
+		}
+	}
+	for k, v := range u {
+		switch key := strings.ToLower(k); key {
+		case "name":
+			r.Name = v
+		case "url":
+			r.URL = v
+		case "useoauth":
+			r.UseOAuth = strings.ToLower(v) == "true"
+		case "allowhttp":
+			r.AllowHTTP = strings.ToLower(v) == "true"
+		case "priority":
+			var err error
+			r.Priority, err = priority.FromString(v)
+			if err != nil {
+				return fmt.Errorf("invalid priority: %v", v)
+			}
+		case "proxyserver":
+			r.ProxyServer = v
+		case "ca":
+			r.CA = v
+		case "clientcert":
+			r.ClientCert = v
+		case "clientkey":
+			r.ClientKey = v
+		case "timeout":
+			if _, err := time.ParseDuration(v); err != nil {
+				return fmt.Errorf("invalid timeout: %v", v)
+			}
+			r.Timeout = v
+		case "cachelife":
+			if _, err := time.ParseDuration(v); err != nil {
+				return fmt.Errorf("invalid cachelife: %v", v)
+			}
+			r.CacheLife = v
+		case "signaturekey":
+			r.SignatureKey = v
+		case "signaturepolicy":
+			if _, err := install.ParseSignaturePolicy(v); err != nil {
+				return err
+			}
+			r.SignaturePolicy = v
+		case "cosignkey":
+			r.CosignKey = v
+		case "cosignidentity":
+			r.CosignIdentity = v
+		case "cosignissuer":
+			r.CosignIssuer = v
+		case "cosignpolicy":
+			if _, err := install.ParseSignaturePolicy(v); err != nil {
+				return err
+			}
+			r.CosignPolicy = v
+		}
+	}
+	if r.URL == "" {
+		return fmt.Errorf("repo entry missing url: %+v", u)
+	}
+	return nil
+}
+
+// unmarshalHeaders sets the Headers of r from the headers map v of a .repo
+// file.
+func (r *RepoEntry) unmarshalHeaders(v interface{}) error {
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf("invalid headers, want a map of names to values: %v", v)
+	}
+	r.Headers = make(map[string]string)
+	for k, v := range m {
+		name, value := fmt.Sprint(k), fmt.Sprint(v)
+		if err := client.CheckHeader(name, value); err != nil {
+			return err
+		}
+		r.Headers[name] = value
+	}
+	return nil
+}
+
+// Transport loads the transport settings of r, it reports false if r sets
+// none.
+func (r RepoEntry) Transport() (client.RepoTransport, bool, error) {
+	var t client.RepoTransport
+	if r.ProxyServer == "" && r.CA == "" && r.ClientCert == "" && r.Timeout == "" && len(r.Headers) == 0 {
+		return t, false, nil
+	}
+	t.ProxyServer = r.ProxyServer
+	t.Headers = r.Headers
+	if r.CA != "" {
+		pool, err := ReadCertPool(r.CA, false)
+		if err != nil {
+			return t, false, err
+		}
+		t.RootCAs = pool
+	}
+	if r.ClientCert != "" {
+		cert, err := LoadKeyPair(r.ClientCert, r.ClientKey)
+		if err != nil {
+			return t, false, err
+		}
+		t.Certificate = cert
+	}
+	if r.Timeout != "" {
+		d, err := time.ParseDuration(r.Timeout)
+		if err != nil {
+			return t, false, err
+		}
+		t.Timeout = d
+	}
+	return t, true, nil
+}
+
+// Signatures returns the signature settings of r, falling back to
+// install.DefaultSignatures for those it does not set. It reports false if r
+// sets none.
+func (r RepoEntry) Signatures() (install.Signatures, bool, error) {
+	return r.signatures(install.DefaultSignatures)
+}
+
+func (r RepoEntry) signatures(s install.Signatures) (install.Signatures, bool, error) {
+	if r.SignatureKey == "" && r.SignaturePolicy == "" {
+		return s, false, nil
+	}
+	if r.SignaturePolicy != "" {
+		p, err := install.ParseSignaturePolicy(r.SignaturePolicy)
+		if err != nil {
+			return s, false, err
+		}
+		s.Policy = p
+	}
+	if r.SignatureKey != "" {
+		keys, err := ReadSignatureKeys([]string{r.SignatureKey})
+		if err != nil {
+			return s, false, err
+		}
+		s.Keys = keys
+	}
+	return s, true, nil
+}
+
+// Cosign returns the cosign settings of r, falling back to
+// install.DefaultCosign for those it does not set. It reports false if r
+// sets none.
+func (r RepoEntry) Cosign() (install.Cosign, bool, error) {
+	return r.cosign(install.DefaultCosign)
+}
+
+func (r RepoEntry) cosign(c install.Cosign) (install.Cosign, bool, error) {
+	if r.CosignKey == "" && r.CosignIdentity == "" && r.CosignIssuer == "" && r.CosignPolicy == "" {
+		return c, false, nil
+	}
+	if r.CosignPolicy != "" {
+		p, err := install.ParseSignaturePolicy(r.CosignPolicy)
+		if err != nil {
+			return c, false, err
+		}
+		c.Policy = p
+	}
+	if r.CosignKey != "" {
+		keys, err := ReadCosignKeys([]string{r.CosignKey})
+		if err != nil {
+			return c, false, err
+		}
+		c.Keys = keys
+	}
+	if r.CosignIdentity != "" {
+		c.Identity = r.CosignIdentity
+	}
+	if r.CosignIssuer != "" {
+		c.Issuer = r.CosignIssuer
+	}
+	return c, true, nil
+}
+
+// ReadCosignKeys reads the PEM encoded ECDSA or RSA public keys in files,
+// each named by its file.
+func ReadCosignKeys(files []string) ([]install.CosignKey, error) {
+	var keys []install.CosignKey
+	for _, f := range files {
+		key, err := goolib.ReadPublicKey(f)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, install.CosignKey{Name: f, Key: key})
+	}
+	return keys, nil
+}
+
+// ReadSignatureKeys reads the PEM encoded ed25519 public keys in files, each
+// named by its file.
+func ReadSignatureKeys(files []string) ([]install.SignatureKey, error) {
+	var keys []install.SignatureKey
+	for _, f := range files {
+		key, err := goolib.ReadVerifyKey(f)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, install.SignatureKey{Name: f, Key: key})
+	}
+	return keys, nil
+}
+
+// ReadCertPool reads the PEM encoded certificates in file, added to the
+// system roots if system is set.
+func ReadCertPool(file string, system bool) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if system {
+		if pool, err = x509.SystemCertPool(); err != nil {
+			return nil, err
+		}
+	}
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+// LoadKeyPair loads a client certificate and its key from PEM files, the key
+// defaulting to the certificate file.
+func LoadKeyPair(certFile, keyFile string) (*tls.Certificate, error) {
+	if keyFile == "" {
+		keyFile = certFile
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// ReadRepoFile reads the .repo file p, which holds a single entry or a list
+// of entries. Files without YAML content give an empty RepoFile.
+func ReadRepoFile(p string) (RepoFile, error) {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return RepoFile{}, err
+	}
+
+	// Don't try to unmarshal files with no YAML content
+	var yml bool
+	lns := strings.Split(string(b), "\n")
+	for _, ln := range lns {
+		ln = strings.TrimSpace(ln)
+		if !strings.HasPrefix(ln, "#") && ln != "" {
+			yml = true
+			break
+		}
+	}
+	if !yml {
+		return RepoFile{}, nil
+	}
+
+	// Both RepoFile and []RepoFile are valid for backwards compatibility.
+	var re RepoEntry
+	if err := yaml.Unmarshal(b, &re); err == nil && re.URL != "" {
+		return RepoFile{FileName: p, Entries: []RepoEntry{re}}, nil
+	}
+
+	var res []RepoEntry
+	if err := yaml.Unmarshal(b, &res); err != nil {
+		return RepoFile{}, err
+	}
+	return RepoFile{FileName: p, Entries: res}, nil
+}
+
+// ReadRepoFiles reads the .repo files in dir, skipping those that can't be
+// read.
+func ReadRepoFiles(dir string) ([]RepoFile, error) {
+	fl, err := filepath.Glob(filepath.Join(dir, "*.repo"))
+	if err != nil {
+		return nil, err
+	}
+	var rfs []RepoFile
+	for _, f := range fl {
+		rf, err := ReadRepoFile(f)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+		if rf.FileName != "" {
+			rfs = append(rfs, rf)
+		}
+	}
+	return rfs, nil
+}
+
+// WriteRepoFile writes the entries of rf to its file.
+func WriteRepoFile(rf RepoFile) error {
+	d, err := yaml.Marshal(rf.Entries)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(rf.FileName, d, 0664)
+}
+
+// AddRepo adds e to the .repo file at path, creating it if needed, and
+// reports whether the file already existed. Entries of the file with the
+// name or URL of e are replaced.
+func AddRepo(path string, e RepoEntry) (bool, error) {
+	return addRepo(path, e, AllowedRepos)
+}
+
+func addRepo(path string, e RepoEntry, allowed []string) (bool, error) {
+	if err := repoAllowed(e.URL, allowed); err != nil {
+		return false, err
+	}
+	if _, err := oswrap.Stat(path); err != nil && os.IsNotExist(err) {
+		return false, WriteRepoFile(RepoFile{path, []RepoEntry{e}})
+	}
+	rf, err := ReadRepoFile(path)
+	if err != nil {
+		return false, err
+	}
+	var res []RepoEntry
+	for _, re := range rf.Entries {
+		if re.Name != e.Name && re.URL != e.URL {
+			res = append(res, re)
+		}
+	}
+	return true, WriteRepoFile(RepoFile{path, append(res, e)})
+}
+
+// ErrRepoNotFound is returned by RemoveRepo if no repo has the name.
+var ErrRepoNotFound = errors.New("repo not found")
+
+// RemoveRepo removes the repo named name, ignoring case, from its .repo file
+// in dir and returns the file. The file is deleted, which is reported, if it
+// lists no other repo.
+func RemoveRepo(dir, name string) (string, bool, error) {
+	rfs, err := ReadRepoFiles(dir)
+	if err != nil {
+		return "", false, err
+	}
+	var foundRepo RepoFile
+	for _, rf := range rfs {
+		for _, re := range rf.Entries {
+			if strings.EqualFold(re.Name, name) {
+				foundRepo = rf
+				break
+			}
+		}
+	}
+	if foundRepo.FileName == "" {
+		return "", false, ErrRepoNotFound
+	}
+
+	var res []RepoEntry
+	for _, re := range foundRepo.Entries {
+		if !strings.EqualFold(re.Name, name) {
+			res = append(res, re)
+		}
+	}
+	if len(res) > 0 {
+		return foundRepo.FileName, false, WriteRepoFile(RepoFile{foundRepo.FileName, res})
+	}
+	return foundRepo.FileName, true, oswrap.Remove(foundRepo.FileName)
+}
+
+// AllowedRepos, if set, restricts the repos that can be used to those under
+// one of these URLs, a host starting with "*." also matching its
+// subdomains.
+var AllowedRepos []string
+
+// RepoAllowed returns an error if AllowedRepos is set and u is not under one
+// of its URLs.
+func RepoAllowed(u string) error {
+	return repoAllowed(u, AllowedRepos)
+}
+
+func repoAllowed(u string, allowed []string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	u = strings.TrimPrefix(u, "oauth-")
+	pu, err := url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("failed to parse repo URL %q: %v", u, err)
+	}
+	for _, a := range allowed {
+		pa, err := url.Parse(a)
+		if err != nil {
+			logger.Errorf("Failed to parse allowedrepos entry %q: %v", a, err)
+			continue
+		}
+		if matchRepo(pa, pu) {
+			return nil
+		}
+	}
+	return fmt.Errorf("repo %s is not allowed by the allowedrepos setting in googet.conf", u)
+}
+
+// matchRepo reports whether u has the scheme and host of a and is a or below
+// it.
+func matchRepo(a, u *url.URL) bool {
+	if a.Scheme != u.Scheme || u.User != nil {
+		return false
+	}
+	host, ah := strings.ToLower(u.Host), strings.ToLower(a.Host)
+	if strings.HasPrefix(ah, "*.") {
+		if !strings.HasSuffix(host, ah[1:]) {
+			return false
+		}
+	} else if host != ah {
+		return false
+	}
+	ap := strings.TrimSuffix(a.Path, "/")
+	up := path.Clean("/" + u.Path)
+	return up == ap || strings.HasPrefix(up, ap+"/")
+}
+
+// validateRepoURL checks u against allowed and reports whether it is an
+// https, Google Cloud Storage or file URL, or a plain HTTP one and allowHTTP
+// is set.
+func validateRepoURL(u string, allowHTTP bool, allowed []string) bool {
+	if err := repoAllowed(u, allowed); err != nil {
+		logger.Errorf("%v, skipping it", err)
+		return false
+	}
+	if gcs, _, _ := goolib.SplitGCSUrl(u); gcs {
+		return true
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		logger.Errorf("Failed to parse URL '%s', skipping repo", u)
+		return false
+	}
+	switch parsed.Scheme {
+	case "https", "file":
+		return true
+	case "http":
+		if allowHTTP {
+			logger.Warningf("Repo %s is served over plain HTTP, its index and packages can be read and modified in transit", u)
+			return true
+		}
+	}
+	logger.Errorf("%s will not be used as a repository, only https, Google Cloud Storage and file endpoints will be used unless 'allowhttp' is set to 'true' in its .repo entry", u)
+	return false
+}
+
+// RepoTransports maps repo URLs to the transport settings of their .repo
+// entry, filled by RepoList.
+var RepoTransports = make(map[string]client.RepoTransport)
+
+// RepoList returns a deduped set of all repos listed in the repo config files contained in dir.
+// The repos are mapped to priority values. If a repo config does not specify a priority, the repo
+// is assigned the default priority value. If the same repo appears multiple times with different
+// priority values, it is mapped to the highest seen priority value.
+//
+// The settings of the repos are added to RepoTransports, client.RepoCacheLife,
+// install.RepoSignatures and install.RepoCosign.
+func RepoList(dir string) (map[string]priority.Value, error) {
+	rs, err := readRepos(dir, AllowedRepos, install.DefaultSignatures, install.DefaultCosign)
+	if err != nil {
+		return nil, err
+	}
+	for u, t := range rs.transports {
+		RepoTransports[u] = t
+	}
+	for u, l := range rs.cacheLife {
+		if client.RepoCacheLife == nil {
+			client.RepoCacheLife = make(map[string]time.Duration)
+		}
+		client.RepoCacheLife[u] = l
+	}
+	for u, s := range rs.signatures {
+		if install.RepoSignatures == nil {
+			install.RepoSignatures = make(map[string]install.Signatures)
+		}
+		install.RepoSignatures[u] = s
+	}
+	for u, c := range rs.cosign {
+		if install.RepoCosign == nil {
+			install.RepoCosign = make(map[string]install.Cosign)
+		}
+		install.RepoCosign[u] = c
+	}
+	return rs.priorities, nil
+}
+
+// repoSettings are the repos of the .repo files of a directory and their
+// settings, by repo URL.
+type repoSettings struct {
+	priorities map[string]priority.Value
+	transports map[string]client.RepoTransport
+	cacheLife  map[string]time.Duration
+	signatures map[string]install.Signatures
+	cosign     map[string]install.Cosign
+}
+
+// readRepos reads the repos of the .repo files in dir that allowed allows,
+// see RepoList. Their signature and cosign settings fall back to sigs and
+// cos.
+func readRepos(dir string, allowed []string, sigs install.Signatures, cos install.Cosign) (repoSettings, error) {
+	rs := repoSettings{
+		priorities: make(map[string]priority.Value),
+		transports: make(map[string]client.RepoTransport),
+		cacheLife:  make(map[string]time.Duration),
+		signatures: make(map[string]install.Signatures),
+		cosign:     make(map[string]install.Cosign),
+	}
+	rfs, err := ReadRepoFiles(dir)
+	if err != nil {
+		return rs, err
+	}
+	for _, rf := range rfs {
+		for _, re := range rf.Entries {
+			u := re.URL
+			if u == "" || !validateRepoURL(u, re.AllowHTTP, allowed) {
+				continue
+			}
+			if re.UseOAuth {
+				u = "oauth-" + u
+			}
+			// Using a repo without its settings could bypass its proxy or
+			// trust the wrong servers.
+			t, ok, err := re.Transport()
+			if err != nil {
+				logger.Errorf("Skipping repo %s, error loading its settings: %v", re.URL, err)
+				continue
+			}
+			if ok {
+				rs.transports[u] = t
+			}
+			if re.CacheLife != "" {
+				l, err := time.ParseDuration(re.CacheLife)
+				if err != nil {
+					logger.Errorf("Skipping repo %s, invalid cachelife: %v", re.URL, err)
+					continue
+				}
+				rs.cacheLife[u] = l
+			}
+			// Installing from a repo without its keys would block or
+			// accept the wrong packages.
+			s, ok, err := re.signatures(sigs)
+			if err != nil {
+				logger.Errorf("Skipping repo %s, error loading its signature settings: %v", re.URL, err)
+				continue
+			}
+			c, cok, err := re.cosign(cos)
+			if err != nil {
+				logger.Errorf("Skipping repo %s, error loading its cosign settings: %v", re.URL, err)
+				continue
+			}
+			if ok {
+				rs.signatures[u] = s
+			}
+			if cok {
+				rs.cosign[u] = c
+			}
+			p := re.Priority
+			if p <= 0 {
+				p = priority.Default
+			}
+			if q, ok := rs.priorities[u]; !ok || p > q {
+				rs.priorities[u] = p
+			}
+		}
+	}
+	return rs, nil
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-yaml/yaml"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/install"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/googet/v2/priority"
+)
+
+func TestRepoList(t *testing.T) {
+	testRepo := "https://foo.com/googet/bar"
+	testHTTPRepo := "http://foo.com/googet/bar"
+	testFileRepo := "file:///srv/googet/bar"
+
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+
+	testFile := filepath.Join(tempDir, "test.repo")
+
+	repoTests := []struct {
+		content []byte
+		want    map[string]priority.Value
+	}{
+		{[]byte("\n"), nil},
+		{[]byte("# This is just a comment"), nil},
+		{[]byte("url: " + testRepo), map[string]priority.Value{testRepo: priority.Default}},
+		{[]byte("\n # Comment\nurl: " + testRepo), map[string]priority.Value{testRepo: priority.Default}},
+		{[]byte("- url: " + testRepo), map[string]priority.Value{testRepo: priority.Default}},
+		// The HTTP repo should be dropped.
+		{[]byte("- url: " + testHTTPRepo), nil},
+		// The HTTP repo should not be dropped.
+		{[]byte("- url: " + testHTTPRepo + "\n  allowhttp: true"), map[string]priority.Value{testHTTPRepo: priority.Default}},
+		{[]byte("- URL: " + testRepo), map[string]priority.Value{testRepo: priority.Default}},
+		// The HTTP repo should be dropped.
+		{[]byte("- url: " + testRepo + "\n\n- URL: " + testHTTPRepo), map[string]priority.Value{testRepo: priority.Default}},
+		// The HTTP repo should not be dropped.
+		{[]byte("- url: " + testRepo + "\n\n- URL: " + testHTTPRepo + "\n  allowhttp: true"), map[string]priority.Value{testRepo: priority.Default, testHTTPRepo: 500}},
+		{[]byte("- url: " + testFileRepo), map[string]priority.Value{testFileRepo: priority.Default}},
+		{[]byte("- url: " + testRepo + "\n\n- URL: " + testRepo), map[string]priority.Value{testRepo: priority.Default}},
+		{[]byte("- url: " + testRepo + "\n\n- url: " + testRepo), map[string]priority.Value{testRepo: priority.Default}},
+		// Should contain oauth- prefix
+		{[]byte("- url: " + testRepo + "\n  useoauth: true"), map[string]priority.Value{"oauth-" + testRepo: priority.Default}},
+		// Should not contain oauth- prefix
+		{[]byte("- url: " + testRepo + "\n  useoauth: false"), map[string]priority.Value{testRepo: priority.Default}},
+		{[]byte("- url: " + testRepo + "\n  priority: 1200"), map[string]priority.Value{testRepo: priority.Value(1200)}},
+		{[]byte("- url: " + testRepo + "\n  priority: default"), map[string]priority.Value{testRepo: priority.Default}},
+		{[]byte("- url: " + testRepo + "\n  priority: canary"), map[string]priority.Value{testRepo: priority.Canary}},
+		{[]byte("- url: " + testRepo + "\n  priority: pin"), map[string]priority.Value{testRepo: priority.Pin}},
+		{[]byte("- url: " + testRepo + "\n  priority: rollback"), map[string]priority.Value{testRepo: priority.Rollback}},
+	}
+
+	for i, tt := range repoTests {
+		if err := ioutil.WriteFile(testFile, tt.content, 0660); err != nil {
+			t.Fatalf("error writing repo: %v", err)
+		}
+		got, err := RepoList(tempDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tt.want, got, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("test case %d: RepoList unexpected diff (-want +got): %v", i+1, diff)
+		}
+	}
+}
+
+func TestRepoListSignatures(t *testing.T) {
+	defer func() { install.DefaultSignatures, install.RepoSignatures = install.Signatures{}, nil }()
+	dir := t.TempDir()
+	content := "- url: https://foo.com/googet/warn\n  signaturepolicy: warn\n" +
+		"- url: https://foo.com/googet/missing\n  signaturekey: " + filepath.Join(dir, "missing.pub") + "\n" +
+		"- url: https://foo.com/googet/default\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "test.repo"), []byte(content), 0660); err != nil {
+		t.Fatalf("error writing repo: %v", err)
+	}
+	install.DefaultSignatures = install.Signatures{Policy: install.SignatureEnforce}
+
+	got, err := RepoList(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The repo whose key can't be read is skipped.
+	want := map[string]priority.Value{"https://foo.com/googet/warn": priority.Default, "https://foo.com/googet/default": priority.Default}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("RepoList unexpected diff (-want +got): %v", diff)
+	}
+	wantSigs := map[string]install.Signatures{"https://foo.com/googet/warn": {Policy: install.SignatureWarn}}
+	if diff := cmp.Diff(wantSigs, install.RepoSignatures); diff != "" {
+		t.Errorf("install.RepoSignatures unexpected diff (-want +got): %v", diff)
+	}
+}
+
+func TestRepoListCacheLife(t *testing.T) {
+	defer func() { client.RepoCacheLife = nil }()
+	dir := t.TempDir()
+	content := "- url: https://foo.com/googet/slow\n  cachelife: 1h\n" +
+		"- url: https://foo.com/googet/fast\n  useoauth: true\n  cachelife: 0s\n" +
+		"- url: https://foo.com/googet/default\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "test.repo"), []byte(content), 0660); err != nil {
+		t.Fatalf("error writing repo: %v", err)
+	}
+	if _, err := RepoList(dir); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]time.Duration{"https://foo.com/googet/slow": time.Hour, "oauth-https://foo.com/googet/fast": 0}
+	if diff := cmp.Diff(want, client.RepoCacheLife); diff != "" {
+		t.Errorf("client.RepoCacheLife unexpected diff (-want +got): %v", diff)
+	}
+
+	var e RepoEntry
+	if err := yaml.Unmarshal([]byte("url: https://foo.com/googet/bar\ncachelife: soon"), &e); err == nil {
+		t.Error("yaml.Unmarshal of an invalid cachelife did not return an error")
+	}
+}
+
+func TestWriteRepoFile(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		entries []RepoEntry
+		want    string
+	}{
+		{
+			name:    "with-no-priority-specified",
+			entries: []RepoEntry{{Name: "bar", URL: "https://foo.com/googet/bar"}},
+			want: `- name: bar
+  url: https://foo.com/googet/bar
+  useoauth: false
+`,
+		},
+		{
+			name:    "with-default-priority",
+			entries: []RepoEntry{{Name: "bar", URL: "https://foo.com/googet/bar", Priority: priority.Default}},
+			want: `- name: bar
+  url: https://foo.com/googet/bar
+  useoauth: false
+  priority: default
+`,
+		},
+		{
+			name:    "with-rollback-priority",
+			entries: []RepoEntry{{Name: "bar", URL: "https://foo.com/googet/bar", Priority: priority.Rollback}},
+			want: `- name: bar
+  url: https://foo.com/googet/bar
+  useoauth: false
+  priority: rollback
+`,
+		},
+		{
+			name:    "with-non-standard-priority",
+			entries: []RepoEntry{{Name: "bar", URL: "https://foo.com/googet/bar", Priority: 42}},
+			want: `- name: bar
+  url: https://foo.com/googet/bar
+  useoauth: false
+  priority: 42
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := os.CreateTemp("", "test.repo")
+			if err != nil {
+				t.Fatalf("os.CreateTemp: %v", err)
+			}
+			defer func() {
+				os.Remove(f.Name())
+			}()
+			if err := f.Close(); err != nil {
+				t.Fatalf("f.Close: %v", err)
+			}
+			rf := RepoFile{FileName: f.Name(), Entries: tc.entries}
+			if err := WriteRepoFile(rf); err != nil {
+				t.Fatalf("WriteRepoFile(%v): %v", rf, err)
+			}
+			b, err := os.ReadFile(f.Name())
+			if err != nil {
+				t.Fatalf("os.ReadFile(%v): %v", f.Name(), err)
+			}
+			t.Logf("wrote repo file contents:\n%v", string(b))
+			// Make the diff easier to read by splitting into lines first.
+			got := strings.Split(string(b), "\n")
+			want := strings.Split(tc.want, "\n")
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("WriteRepoFile got unexpected diff (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestRepoEntryHeaders(t *testing.T) {
+	var entries []RepoEntry
+	content := "- url: https://foo.com/googet/bar\n  priority: 1200\n  useoauth: true\n  headers:\n    X-Api-Key: secret\n    X-Route: eu\n"
+	if err := yaml.Unmarshal([]byte(content), &entries); err != nil {
+		t.Fatalf("yaml.Unmarshal: %v", err)
+	}
+	want := RepoEntry{URL: "https://foo.com/googet/bar", Priority: 1200, UseOAuth: true, Headers: map[string]string{"X-Api-Key": "secret", "X-Route": "eu"}}
+	if diff := cmp.Diff([]RepoEntry{want}, entries); diff != "" {
+		t.Errorf("yaml.Unmarshal unexpected diff (-want +got): %v", diff)
+	}
+	tr, ok, err := entries[0].Transport()
+	if err != nil || !ok {
+		t.Fatalf("Transport() = %v, %v, want the transport of the headers", ok, err)
+	}
+	if diff := cmp.Diff(want.Headers, tr.Headers); diff != "" {
+		t.Errorf("Transport() headers unexpected diff (-want +got): %v", diff)
+	}
+
+	for _, bad := range []string{
+		"url: https://foo.com/googet/bar\nheaders: X-Api-Key",
+		"url: https://foo.com/googet/bar\nheaders:\n  X Api Key: secret",
+	} {
+		var e RepoEntry
+		if err := yaml.Unmarshal([]byte(bad), &e); err == nil {
+			t.Errorf("yaml.Unmarshal(%q) did not return an error", bad)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/system"
+	"github.com/google/logger"
+)
+
+// bootTime is replaced in tests.
+var bootTime = system.BootTime
+
+// WriteState writes s to the state file sf, keeping the previous one as a
+// backup.
+func WriteState(s *client.GooGetState, sf string) error {
+	b, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+	// Write state to a temporary file first
+	tmp, err := ioutil.TempFile(filepath.Dir(sf), "googet.*.state")
+	if err != nil {
+		return err
+	}
+	newStateFile := tmp.Name()
+	if _, err = tmp.Write(b); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(newStateFile, 0664); err != nil {
+		return err
+	}
+	// Back up the old state file so we can recover it if need be
+	backupStateFile := sf + ".bak"
+	if err = os.Rename(sf, backupStateFile); err != nil {
+		logger.Infof("Unable to back up state file %s to %s. Err: %v", sf, backupStateFile, err)
+	}
+	// Move the new temp file to the live path
+	return os.Rename(newStateFile, sf)
+}
+
+// ReadState reads the state file sf, or its backup if it is missing or
+// corrupted. No state file means no packages are installed.
+func ReadState(sf string) (*client.GooGetState, error) {
+	state, err := readStateFromPath(sf)
+	if err != nil {
+		sfNotExist := os.IsNotExist(err)
+		state, err = readStateFromPath(sf + ".bak")
+		if sfNotExist && os.IsNotExist(err) {
+			logger.Info("No state file found, assuming no packages installed.")
+			return &client.GooGetState{}, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Installs that needed a reboot completed with it.
+	if boot, err := bootTime(); err != nil {
+		logger.Errorf("Error getting the boot time: %v", err)
+	} else {
+		state.ClearReboots(boot)
+	}
+	return state, nil
+}
+
+func readStateFromPath(sf string) (*client.GooGetState, error) {
+	b, err := ioutil.ReadFile(sf)
+	if err != nil {
+		return nil, err
+	}
+	return client.UnmarshalState(b)
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+)
+
+func TestWriteReadState(t *testing.T) {
+	want := &client.GooGetState{
+		client.PackageState{PackageSpec: &goolib.PkgSpec{Name: "test"}},
+	}
+
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+
+	sf := filepath.Join(tempDir, "test.state")
+
+	if err := WriteState(want, sf); err != nil {
+		t.Errorf("error running WriteState: %v", err)
+	}
+
+	got, err := ReadState(sf)
+	if err != nil {
+		t.Errorf("error running ReadState: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("did not get expected state, got: %+v, want %+v", got, want)
+	}
+}
+
+func TestReadStateRecovery(t *testing.T) {
+	original := &client.GooGetState{
+		client.PackageState{PackageSpec: &goolib.PkgSpec{Name: "test.org"}},
+	}
+
+	overwrite := &client.GooGetState{
+		client.PackageState{PackageSpec: &goolib.PkgSpec{Name: "test.new"}},
+	}
+
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+
+	const (
+		deleteState int = iota
+		corruptState
+	)
+
+	table := []struct {
+		name       string
+		disruption int
+	}{
+		{"test-deleted.state", deleteState},
+		{"test-corrupted.state", corruptState},
+	}
+
+	for _, tt := range table {
+		sf := filepath.Join(tempDir, tt.name)
+
+		if err := WriteState(original, sf); err != nil {
+			t.Errorf("error running WriteState: %v", err)
+		}
+
+		if err := WriteState(overwrite, sf); err != nil {
+			t.Errorf("error running WriteState second time: %v", err)
+		}
+
+		got, err := ReadState(sf)
+		if err != nil {
+			t.Errorf("error running ReadState: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, overwrite) {
+			t.Errorf("did not get expected state after overwrite, got: %+v, want %+v", got, overwrite)
+		}
+
+		switch tt.disruption {
+		case deleteState:
+			if err := oswrap.Remove(sf); err != nil {
+				t.Errorf("error deleting state: %v", err)
+			}
+		case corruptState:
+			if err := ioutil.WriteFile(sf, []byte{0, 0, 0, 0}, 0664); err != nil {
+				t.Errorf("error corrupting state: %v", err)
+			}
+		}
+
+		got, err = ReadState(sf)
+		if err != nil {
+			t.Errorf("error running ReadState after corruption of active state: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, original) {
+			t.Errorf("did not get expected state after corruption, got: %+v, want %+v", got, original)
+		}
+	}
+}
+
+func TestReadStateClearsReboots(t *testing.T) {
+	boot := time.Now()
+	defer func(f func() (time.Time, error)) { bootTime = f }(bootTime)
+	bootTime = func() (time.Time, error) { return boot, nil }
+
+	before, after := boot.Add(-time.Hour), boot.Add(time.Hour)
+	state := &client.GooGetState{
+		client.PackageState{PackageSpec: &goolib.PkgSpec{Name: "before"}, RebootRequired: true, RebootRequested: &before},
+		client.PackageState{PackageSpec: &goolib.PkgSpec{Name: "after"}, RebootRequired: true, RebootRequested: &after},
+	}
+	sf := filepath.Join(t.TempDir(), "test.state")
+	if err := WriteState(state, sf); err != nil {
+		t.Fatalf("error running WriteState: %v", err)
+	}
+	got, err := ReadState(sf)
+	if err != nil {
+		t.Fatalf("error running ReadState: %v", err)
+	}
+	if (*got)[0].RebootRequired || (*got)[0].RebootRequested != nil {
+		t.Errorf("reboot of %q not cleared: %+v", "before", (*got)[0])
+	}
+	if !(*got)[1].RebootRequired {
+		t.Errorf("reboot of %q cleared", "after")
+	}
+}
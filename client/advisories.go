@@ -0,0 +1,217 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/logger"
+)
+
+// AdvisoriesFile is the advisories feed of a repo, next to its index.
+const AdvisoriesFile = "advisories.json"
+
+// Advisory is a vulnerability advisory in the OSV format, only the fields
+// used to match installed packages are read.
+type Advisory struct {
+	ID       string
+	Aliases  []string
+	Summary  string
+	Severity []struct {
+		Type, Score string
+	}
+	Affected         []Affected
+	DatabaseSpecific struct {
+		Severity string
+	} `json:"database_specific"`
+}
+
+// Affected lists the affected versions of a package of an Advisory.
+type Affected struct {
+	Package struct {
+		Name, Ecosystem string
+	}
+	Ranges []struct {
+		Type   string
+		Events []Event
+	}
+	Versions []string
+}
+
+// Event is a version at which a range of affected versions starts or ends,
+// only one of its fields is set.
+type Event struct {
+	Introduced   string
+	Fixed        string
+	LastAffected string `json:"last_affected"`
+}
+
+// FetchAdvisories returns the advisories of the repo at repoURL, none if the
+// repo has no feed.
+func FetchAdvisories(ctx context.Context, repoURL string, downloader Downloader) ([]Advisory, error) {
+	r, err := openAdvisories(ctx, strings.TrimSuffix(repoURL, "/")+"/"+AdvisoriesFile, downloader)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	defer r.Close()
+	var as []Advisory
+	if err := json.NewDecoder(r).Decode(&as); err != nil {
+		return nil, fmt.Errorf("error parsing advisories of repo %s: %v", strings.TrimPrefix(repoURL, "oauth-"), err)
+	}
+	return as, nil
+}
+
+// openAdvisories opens the feed at u, it returns nil if there is none.
+func openAdvisories(ctx context.Context, u string, downloader Downloader) (io.ReadCloser, error) {
+	logger.Infof("Fetching %q", strings.TrimPrefix(u, "oauth-"))
+	if isGCSURL, bucket, object := goolib.SplitGCSUrl(u); isGCSURL {
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		r, err := client.Bucket(bucket).Object(object).NewReader(ctx)
+		if err == storage.ErrObjectNotExist {
+			return nil, nil
+		}
+		return r, err
+	}
+	res, err := downloader.Get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+		return res.Body, nil
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, nil
+	}
+	res.Body.Close()
+	return nil, fmt.Errorf("advisories GET request returned status: %q", res.Status)
+}
+
+// affected returns the entries of a for the package name, ignoring those of
+// other ecosystems.
+func (a Advisory) affected(name string) []Affected {
+	var af []Affected
+	for _, f := range a.Affected {
+		e := f.Package.Ecosystem
+		if f.Package.Name == name && (e == "" || strings.EqualFold(e, "googet")) {
+			af = append(af, f)
+		}
+	}
+	return af
+}
+
+// Affects reports whether version ver of the package name is affected by a.
+func (a Advisory) Affects(name, ver string) bool {
+	for _, f := range a.affected(name) {
+		for _, v := range f.Versions {
+			if v == ver {
+				return true
+			}
+		}
+		for _, r := range f.Ranges {
+			if t := strings.ToUpper(r.Type); t != "ECOSYSTEM" && t != "SEMVER" {
+				continue
+			}
+			if inRange(ver, r.Events) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inRange reports whether ver falls in the range of the OSV events.
+func inRange(ver string, events []Event) bool {
+	type event struct {
+		v    string
+		kind int
+	}
+	const (
+		introduced = iota
+		fixed
+		lastAffected
+	)
+	var evs []event
+	for _, e := range events {
+		switch {
+		case e.Introduced != "":
+			evs = append(evs, event{e.Introduced, introduced})
+		case e.Fixed != "":
+			evs = append(evs, event{e.Fixed, fixed})
+		case e.LastAffected != "":
+			evs = append(evs, event{e.LastAffected, lastAffected})
+		}
+	}
+	cmp := func(a, b string) int {
+		c, err := goolib.Compare(a, b)
+		if err != nil {
+			return strings.Compare(a, b)
+		}
+		return c
+	}
+	sort.SliceStable(evs, func(i, j int) bool { return cmp(evs[i].v, evs[j].v) < 0 })
+	in := false
+	for _, e := range evs {
+		c := cmp(ver, e.v)
+		switch {
+		case e.kind == introduced && (c >= 0 || e.v == "0"):
+			in = true
+		case e.kind == fixed && c >= 0:
+			in = false
+		case e.kind == lastAffected && c > 0:
+			in = false
+		}
+	}
+	return in
+}
+
+// Fixed returns the versions of the package name that fix a.
+func (a Advisory) Fixed(name string) []string {
+	var fs []string
+	for _, f := range a.affected(name) {
+		for _, r := range f.Ranges {
+			for _, e := range r.Events {
+				if e.Fixed != "" {
+					fs = append(fs, e.Fixed)
+				}
+			}
+		}
+	}
+	return fs
+}
+
+// SeverityName returns the severity of a, such as "HIGH" or a CVSS vector,
+// or "UNKNOWN".
+func (a Advisory) SeverityName() string {
+	if s := a.DatabaseSpecific.Severity; s != "" {
+		return strings.ToUpper(s)
+	}
+	for _, s := range a.Severity {
+		if s.Score != "" {
+			return s.Score
+		}
+	}
+	return "UNKNOWN"
+}
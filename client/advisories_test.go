@@ -0,0 +1,112 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const testAdvisories = `[
+  {
+    "id": "GA-2026-1",
+    "aliases": ["CVE-2026-0001"],
+    "affected": [
+      {
+        "package": {"name": "foo", "ecosystem": "GooGet"},
+        "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "1.2.0"}, {"fixed": "1.4.0@2"}]}],
+        "versions": ["0.9.0"]
+      },
+      {
+        "package": {"name": "bar", "ecosystem": "PyPI"},
+        "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}]}]
+      }
+    ],
+    "database_specific": {"severity": "high"}
+  },
+  {
+    "id": "GA-2026-2",
+    "affected": [
+      {
+        "package": {"name": "bar"},
+        "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"last_affected": "2.0.0"}]}]
+      }
+    ],
+    "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N"}]
+  }
+]`
+
+func TestAdvisories(t *testing.T) {
+	var as []Advisory
+	if err := json.Unmarshal([]byte(testAdvisories), &as); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		a         int
+		name, ver string
+		want      bool
+	}{
+		{0, "foo", "1.1.0", false},
+		{0, "foo", "1.2.0", true},
+		{0, "foo", "1.4.0@1", true},
+		{0, "foo", "1.4.0@2", false},
+		{0, "foo", "0.9.0", true},
+		{0, "bar", "1.0.0", false},
+		{1, "bar", "0.0.1", true},
+		{1, "bar", "2.0.0", true},
+		{1, "bar", "2.0.1", false},
+		{1, "foo", "1.0.0", false},
+	} {
+		if got := as[tc.a].Affects(tc.name, tc.ver); got != tc.want {
+			t.Errorf("%s.Affects(%q, %q) = %v, want %v", as[tc.a].ID, tc.name, tc.ver, got, tc.want)
+		}
+	}
+	if got, want := as[0].Fixed("foo"), []string{"1.4.0@2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fixed(foo) = %v, want %v", got, want)
+	}
+	if got := as[0].SeverityName(); got != "HIGH" {
+		t.Errorf("SeverityName() = %q, want HIGH", got)
+	}
+	if got := as[1].SeverityName(); got != "CVSS:3.1/AV:N" {
+		t.Errorf("SeverityName() = %q, want the CVSS vector", got)
+	}
+}
+
+func TestFetchAdvisories(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repo/"+AdvisoriesFile {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testAdvisories))
+	}))
+	defer ts.Close()
+
+	as, err := FetchAdvisories(context.Background(), ts.URL+"/repo", HTTPDownloader{})
+	if err != nil {
+		t.Fatalf("FetchAdvisories: %v", err)
+	}
+	if len(as) != 2 || as[0].ID != "GA-2026-1" || as[1].ID != "GA-2026-2" {
+		t.Errorf("FetchAdvisories returned %+v, want the two test advisories", as)
+	}
+
+	as, err = FetchAdvisories(context.Background(), ts.URL+"/other", HTTPDownloader{})
+	if err != nil || as != nil {
+		t.Errorf("FetchAdvisories of a repo without feed = %v, %v, want nil, nil", as, err)
+	}
+}
@@ -15,10 +15,14 @@ limitations under the License.
 package client
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -27,6 +31,9 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,7 +41,9 @@ import (
 	"github.com/google/googet/v2/goolib"
 	"github.com/google/googet/v2/oswrap"
 	"github.com/google/googet/v2/priority"
+	"github.com/google/googet/v2/telemetry"
 	"github.com/google/logger"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/googleapi"
 )
@@ -44,6 +53,51 @@ type PackageState struct {
 	SourceRepo, DownloadURL, Checksum, LocalPath, UnpackDir string
 	PackageSpec                                             *goolib.PkgSpec
 	InstalledFiles                                          map[string]string
+	// CreatedDirs lists the directories created by the install, it is nil
+	// for packages installed by older versions of GooGet.
+	CreatedDirs []string
+	// Scan is the result of the scan run before the package was installed,
+	// nil if no scanner was configured.
+	Scan *ScanResult
+	// ManifestDigest is the SHA-256 checksum of the manifest embedded in
+	// the package, which the installed files matched. It is empty if the
+	// package has none.
+	ManifestDigest string `json:",omitempty"`
+	// RebootRequired is set if the install command exited with one of its
+	// reboot exit codes, the install completes with the next reboot.
+	RebootRequired bool `json:",omitempty"`
+	// RebootRequested is when that install ran, it is nil for packages
+	// recorded by older versions of GooGet.
+	RebootRequested *time.Time `json:",omitempty"`
+	// EnvChanges are the changes made to the machine environment by the
+	// Env of the package.
+	EnvChanges *goolib.EnvChanges `json:",omitempty"`
+	// Shortcuts are the paths of the Start Menu shortcuts created.
+	Shortcuts []string `json:",omitempty"`
+	// MSIProductCode is the product code of the MSI of the package, which
+	// is uninstalled with it.
+	MSIProductCode string `json:",omitempty"`
+	// InstallReason is ReasonExplicit or ReasonDependency, it is empty for
+	// packages installed by older versions of GooGet, which count as
+	// explicitly installed.
+	InstallReason string `json:",omitempty"`
+}
+
+// Reasons a package was installed for.
+const (
+	// ReasonExplicit is a package requested by a user or a manifest.
+	ReasonExplicit = "explicit"
+	// ReasonDependency is a package pulled in as a dependency of another.
+	ReasonDependency = "dependency"
+)
+
+// ScanResult records a run of the configured scanner on a package.
+type ScanResult struct {
+	Time     time.Time
+	Command  string
+	Duration time.Duration
+	ExitCode int
+	Output   string
 }
 
 // GooGetState describes the overall package state on a client.
@@ -77,6 +131,21 @@ func (s *GooGetState) GetPackageState(pi goolib.PackageInfo) (PackageState, erro
 	return PackageState{}, fmt.Errorf("no match found for package %s.%s.%s", pi.Name, pi.Arch, pi.Ver)
 }
 
+// SharesCache reports whether another package in s uses the cached package
+// file of ps, as packages with identical contents share it.
+func (s *GooGetState) SharesCache(ps PackageState) bool {
+	if ps.LocalPath == "" {
+		return false
+	}
+	pi := goolib.PackageInfo{Name: ps.PackageSpec.Name, Arch: ps.PackageSpec.Arch, Ver: ps.PackageSpec.Version}
+	for _, o := range *s {
+		if o.LocalPath == ps.LocalPath && !o.Match(pi) {
+			return true
+		}
+	}
+	return false
+}
+
 // Marshal JSON marshals GooGetState.
 func (s *GooGetState) Marshal() ([]byte, error) {
 	return json.Marshal(s)
@@ -88,6 +157,38 @@ func UnmarshalState(b []byte) (*GooGetState, error) {
 	return &s, json.Unmarshal(b, &s)
 }
 
+// SetInstallReason sets the InstallReason of the packages matching pi and
+// reports whether any changed.
+func (s *GooGetState) SetInstallReason(pi goolib.PackageInfo, reason string) bool {
+	changed := false
+	for i := range *s {
+		if (*s)[i].Match(pi) && (*s)[i].InstallReason != reason {
+			(*s)[i].InstallReason = reason
+			changed = true
+		}
+	}
+	return changed
+}
+
+// ClearReboots clears the RebootRequired of the packages whose install ran
+// before boot, the last boot of the machine, and reports whether any was.
+func (s *GooGetState) ClearReboots(boot time.Time) bool {
+	changed := false
+	for i := range *s {
+		ps := &(*s)[i]
+		if ps.RebootRequired && ps.RebootRequested != nil && ps.RebootRequested.Before(boot) {
+			ps.RebootRequired, ps.RebootRequested = false, nil
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Dependency reports whether the package was only installed as a dependency.
+func (ps *PackageState) Dependency() bool {
+	return ps.InstallReason == ReasonDependency
+}
+
 // Match reports whether the PackageState corresponds to the package info.
 func (ps *PackageState) Match(pi goolib.PackageInfo) bool {
 	return ps.PackageSpec.Name == pi.Name && (ps.PackageSpec.Arch == pi.Arch || pi.Arch == "") && (ps.PackageSpec.Version == pi.Ver || pi.Ver == "")
@@ -103,10 +204,12 @@ type Repo struct {
 type RepoMap map[string]Repo
 
 // AvailableVersions builds a RepoMap from a list of sources.
-func AvailableVersions(ctx context.Context, srcs map[string]priority.Value, cacheDir string, cacheLife time.Duration, proxyServer string) RepoMap {
+func AvailableVersions(ctx context.Context, srcs map[string]priority.Value, cacheDir string, cacheLife time.Duration, downloader Downloader) RepoMap {
 	rm := make(RepoMap)
 	for r, pri := range srcs {
-		rf, err := unmarshalRepoPackages(ctx, r, cacheDir, cacheLife, proxyServer)
+		_, span := telemetry.Start(ctx, "repo.fetch", "googet.repo", strings.TrimPrefix(r, "oauth-"))
+		rf, err := unmarshalRepoPackages(ctx, r, cacheDir, cacheLife, downloader)
+		span.End(err)
 		if err != nil {
 			logger.Errorf("error reading repo %q: %v", r, err)
 			continue
@@ -119,39 +222,60 @@ func AvailableVersions(ctx context.Context, srcs map[string]priority.Value, cach
 	return rm
 }
 
-func decode(index io.ReadCloser, ct, url, cf string) ([]goolib.RepoSpec, error) {
+// readIndex reads and closes the index of content type ct.
+func readIndex(index io.ReadCloser, ct string) ([]byte, error) {
 	defer index.Close()
 
-	var dec *json.Decoder
+	var r io.Reader
 	switch ct {
 	case "application/x-gzip":
 		gr, err := gzip.NewReader(index)
 		if err != nil {
 			return nil, err
 		}
-		dec = json.NewDecoder(gr)
+		r = gr
 	case "application/json":
-		dec = json.NewDecoder(index)
+		r = index
 	default:
 		return nil, fmt.Errorf("unsupported content type: %s", ct)
 	}
+	return ioutil.ReadAll(r)
+}
 
+// parseIndex returns the packages of the index b.
+func parseIndex(b []byte) ([]goolib.RepoSpec, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
 	var m []goolib.RepoSpec
 	for dec.More() {
 		if err := dec.Decode(&m); err != nil {
 			return nil, err
 		}
 	}
+	return m, nil
+}
 
-	f, err := oswrap.Create(cf)
+// decode reads the index of the repo at url, checks it with verify if set,
+// and caches it in cf.
+func decode(index io.ReadCloser, ct, url, cf string, verify func([]byte) error) ([]goolib.RepoSpec, error) {
+	b, err := readIndex(index, ct)
+	if err != nil {
+		return nil, err
+	}
+	if verify != nil {
+		if err := verify(b); err != nil {
+			return nil, err
+		}
+	}
+	m, err := parseIndex(b)
 	if err != nil {
 		return nil, err
 	}
+
 	j, err := json.Marshal(m)
 	if err != nil {
 		return nil, err
 	}
-	if _, err := f.Write(j); err != nil {
+	if err := writeCache(cf, j); err != nil {
 		return nil, err
 	}
 
@@ -162,24 +286,143 @@ func decode(index io.ReadCloser, ct, url, cf string) ([]goolib.RepoSpec, error)
 		logger.Errorf("Failed to write '%s': %v", mf, err)
 	}
 
-	return m, f.Close()
+	return m, nil
+}
+
+// writeCache replaces the cache file cf with data atomically, so readers
+// never see a partially written file.
+func writeCache(cf string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(cf), filepath.Base(cf)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return oswrap.Rename(f.Name(), cf)
+}
+
+// RepoCacheLife maps repo URLs to the cache life set in their .repo entry,
+// which replaces both the cacheLife passed to AvailableVersions, unless it
+// is 0, and the freshness set by the repo server.
+var RepoCacheLife map[string]time.Duration
+
+type cacheLifeKey struct{}
+
+// WithRepoCacheLife returns a copy of ctx with which AvailableVersions uses
+// the cache life of the repos in m instead of RepoCacheLife.
+func WithRepoCacheLife(ctx context.Context, m map[string]time.Duration) context.Context {
+	return context.WithValue(ctx, cacheLifeKey{}, m)
+}
+
+// expiresSuffix is appended to the name of a cached index to name the file
+// holding when it expires, as set by the Cache-Control or Expires headers
+// of the repo server.
+const expiresSuffix = ".expires"
+
+// cacheExpiry returns when a response with header h received at now is no
+// longer fresh, it reports false if h does not say.
+func cacheExpiry(h http.Header, now time.Time) (time.Time, bool) {
+	for _, d := range strings.Split(h.Get("Cache-Control"), ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		switch {
+		case d == "no-cache" || d == "no-store":
+			return now, true
+		case strings.HasPrefix(d, "max-age="):
+			age, err := strconv.Atoi(strings.TrimPrefix(d, "max-age="))
+			if err != nil {
+				return now, true
+			}
+			// Caches between GooGet and the repo report how long they
+			// held the response.
+			if a, err := strconv.Atoi(h.Get("Age")); err == nil {
+				age -= a
+			}
+			return now.Add(time.Duration(age) * time.Second), true
+		}
+	}
+	e := h.Get("Expires")
+	if e == "" {
+		return time.Time{}, false
+	}
+	exp, err := http.ParseTime(e)
+	if err != nil {
+		// Invalid dates, such as 0, mean already expired.
+		return now, true
+	}
+	// Relative to the clock of the server, which may differ from ours.
+	if date, err := http.ParseTime(h.Get("Date")); err == nil {
+		return now.Add(exp.Sub(date)), true
+	}
+	return exp, true
+}
+
+// writeExpiry records when the cached index cf expires, as set by h, or
+// removes the record if h does not say.
+func writeExpiry(cf string, h http.Header) error {
+	exp, ok := cacheExpiry(h, time.Now())
+	if !ok {
+		if err := oswrap.Remove(cf + expiresSuffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return writeCache(cf+expiresSuffix, []byte(exp.UTC().Format(time.RFC3339)))
+}
+
+// cacheFresh reports whether the cached index cf of the repo p, last
+// written at mod, can be used.
+func cacheFresh(ctx context.Context, p, cf string, mod time.Time, cacheLife time.Duration) bool {
+	if cacheLife == 0 {
+		return false
+	}
+	rl, ok := ctx.Value(cacheLifeKey{}).(map[string]time.Duration)
+	if !ok {
+		rl = RepoCacheLife
+	}
+	if l, ok := rl[p]; ok {
+		return time.Since(mod) < l
+	}
+	if b, err := ioutil.ReadFile(cf + expiresSuffix); err == nil {
+		if exp, err := time.Parse(time.RFC3339, strings.TrimSpace(string(b))); err == nil {
+			return time.Now().Before(exp)
+		}
+	}
+	return time.Since(mod) < cacheLife
 }
 
 // unmarshalRepoPackages gets and unmarshals a repository URL or uses the cached contents
-// if mtime is less than cacheLife.
+// if they are fresh, see cacheFresh.
 // Successfully unmarshalled contents will be written to a cache.
-func unmarshalRepoPackages(ctx context.Context, p, cacheDir string, cacheLife time.Duration, proxyServer string) ([]goolib.RepoSpec, error) {
+func unmarshalRepoPackages(ctx context.Context, p, cacheDir string, cacheLife time.Duration, downloader Downloader) ([]goolib.RepoSpec, error) {
 	pName := strings.TrimPrefix(p, "oauth-")
 
 	cf := filepath.Join(cacheDir, fmt.Sprintf("%x.rs", sha256.Sum256([]byte(pName))))
 
+	// Concurrent GooGet runs wait for each other instead of fetching the
+	// same index, the later ones then use the fresh cache.
+	unlock, err := lockCache(cf)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	fi, err := oswrap.Stat(cf)
-	if err == nil && time.Since(fi.ModTime()) < cacheLife {
+	if err == nil && cacheFresh(ctx, p, cf, fi.ModTime(), cacheLife) {
 		logger.Infof("Using cached repo content for %s.", pName)
 		f, err := oswrap.Open(cf)
 		if err != nil {
 			return nil, err
 		}
+		defer f.Close()
 		var m []goolib.RepoSpec
 		dec := json.NewDecoder(f)
 		for dec.More() {
@@ -192,46 +435,169 @@ func unmarshalRepoPackages(ctx context.Context, p, cacheDir string, cacheLife ti
 	if err != nil && !os.IsNotExist(err) {
 		return nil, err
 	}
-	logger.Infof("Fetching repo content for %s, cache either doesn't exist or is older than %v", pName, cacheLife)
+	logger.Infof("Fetching repo content for %s, cache either doesn't exist or is stale", pName)
 
 	isGCSURL, bucket, object := goolib.SplitGCSUrl(pName)
 	if isGCSURL {
-		return unmarshalRepoPackagesGCS(ctx, bucket, object, pName, cf, proxyServer)
+		return unmarshalRepoPackagesGCS(ctx, bucket, object, pName, cf, downloader)
 	}
-	return unmarshalRepoPackagesHTTP(ctx, p, cf, proxyServer)
+	return unmarshalRepoPackagesHTTP(ctx, p, cf, downloader)
 }
 
+// RootCAs, if set, replaces the system roots when verifying the certificates
+// of HTTPS servers.
+var RootCAs *x509.CertPool
+
+// Certificate, if set, is presented to HTTPS servers asking for a client
+// certificate.
+var Certificate *tls.Certificate
+
 // Get gets a url using an optional proxy server, retrying once on any error.
 func Get(ctx context.Context, path, proxyServer string) (*http.Response, error) {
-	httpClient := http.DefaultClient
+	return get(ctx, path, RepoTransport{ProxyServer: proxyServer, RootCAs: RootCAs, Certificate: Certificate})
+}
+
+// Timeouts limit the stages of the requests to repos, 0 meaning no limit.
+type Timeouts struct {
+	// Connect limits establishing the connection to the repo or proxy.
+	Connect time.Duration
+	// TLSHandshake limits the TLS handshake.
+	TLSHandshake time.Duration
+	// ResponseHeader limits the wait for the response headers once the
+	// request is sent, catching servers that accept connections but never
+	// answer.
+	ResponseHeader time.Duration
+	// Request limits whole requests, reading the body included.
+	Request time.Duration
+}
+
+// DefaultTimeouts are the timeouts of all requests, the Timeout of a
+// RepoTransport replacing Request for its repo. Requests are not limited as
+// a whole by default as packages can be large.
+var DefaultTimeouts = Timeouts{
+	Connect:        30 * time.Second,
+	TLSHandshake:   10 * time.Second,
+	ResponseHeader: time.Minute,
+}
+
+// RepoTransport holds the settings used to talk to a repo.
+type RepoTransport struct {
+	// ProxyServer is the URL of the proxy server, the proxy of the
+	// environment is used if empty.
+	ProxyServer string
+	// RootCAs, if set, replaces the system roots when verifying the
+	// certificate of the repo server.
+	RootCAs *x509.CertPool
+	// Certificate, if set, is presented to the repo server if it asks for a
+	// client certificate.
+	Certificate *tls.Certificate
+	// Timeout limits each request, 0 means the Request limit of
+	// DefaultTimeouts.
+	Timeout time.Duration
+	// Headers are set on each request, they are dropped when redirected
+	// to another host.
+	Headers map[string]string
+}
+
+// validHeaderName matches the tokens HTTP header names are made of.
+var validHeaderName = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// CheckHeader returns an error if name and value can't be sent as an HTTP
+// header.
+func CheckHeader(name, value string) error {
+	if !validHeaderName.MatchString(name) {
+		return fmt.Errorf("invalid header name %q", name)
+	}
+	if strings.ContainsAny(value, "\r\n\x00") {
+		return fmt.Errorf("invalid value of header %s, it contains a line break", name)
+	}
+	return nil
+}
+
+func get(ctx context.Context, path string, t RepoTransport) (*http.Response, error) {
+	httpClient := &http.Client{Timeout: DefaultTimeouts.Request}
+	if t.Timeout != 0 {
+		httpClient.Timeout = t.Timeout
+	}
 	proxy := http.ProxyFromEnvironment
-	if proxyServer != "" {
-		proxyURL, err := url.Parse(proxyServer)
+	if t.ProxyServer != "" {
+		proxyURL, err := url.Parse(t.ProxyServer)
 		if err != nil {
 			return nil, err
 		}
 		proxy = http.ProxyURL(proxyURL)
 	}
-	httpClient.Transport = &http.Transport{
-		Proxy: proxy,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
+	tlsConfig := &tls.Config{RootCAs: t.RootCAs}
+	if Pins != nil {
+		var host string
+		if u, err := url.Parse(path); err == nil {
+			host = u.Hostname()
+		}
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			return Pins.checkCertificate(host, cs)
+		}
+	}
+	if t.Certificate != nil {
+		tlsConfig.Certificates = []tls.Certificate{*t.Certificate}
+	}
+	d := &net.Dialer{
+		Timeout:   DefaultTimeouts.Connect,
+		KeepAlive: 30 * time.Second,
+	}
+	tr := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           d.DialContext,
 		ForceAttemptHTTP2:     true,
 		MaxIdleConns:          100,
 		IdleConnTimeout:       60 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
+		TLSHandshakeTimeout:   DefaultTimeouts.TLSHandshake,
+		ResponseHeaderTimeout: DefaultTimeouts.ResponseHeader,
 		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+	tr.RegisterProtocol("file", fileTransport{})
+	httpClient.Transport = tr
+	if NewProxyAuth != nil {
+		httpClient.Transport = newProxyTransport(tr, d, proxy)
 	}
 	useOauth := strings.HasPrefix(path, "oauth-")
 	path = strings.TrimPrefix(path, "oauth-")
-	req, err := http.NewRequest(http.MethodGet, path, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
 	if err != nil {
 		return nil, err
 	}
+	for k, v := range t.Headers {
+		req.Header.Set(k, v)
+	}
+	if len(t.Headers) > 0 {
+		httpClient.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			// Like credentials, the headers of the repo are not sent to
+			// other hosts.
+			if r.URL.Host != via[0].URL.Host {
+				for k := range t.Headers {
+					r.Header.Del(k)
+				}
+			}
+			return nil
+		}
+	}
 	if useOauth {
-		creds, err := google.FindDefaultCredentials(ctx)
+		// The token is requested through the proxy of the repo, with its
+		// authentication, but trusting the system roots.
+		ttr := &http.Transport{
+			Proxy:                 proxy,
+			DialContext:           d.DialContext,
+			TLSHandshakeTimeout:   DefaultTimeouts.TLSHandshake,
+			ResponseHeaderTimeout: DefaultTimeouts.ResponseHeader,
+		}
+		tc := &http.Client{Timeout: httpClient.Timeout, Transport: ttr}
+		if NewProxyAuth != nil {
+			tc.Transport = newProxyTransport(ttr, d, proxy)
+		}
+		creds, err := google.FindDefaultCredentials(context.WithValue(ctx, oauth2.HTTPClient, tc))
 		if err != nil {
 			return nil, fmt.Errorf("failed to obtain creds: %v", err)
 		}
@@ -250,12 +616,141 @@ func Get(ctx context.Context, path, proxyServer string) (*http.Response, error)
 	return httpClient.Do(req)
 }
 
-func unmarshalRepoPackagesHTTP(ctx context.Context, repoURL string, cf string, proxyServer string) ([]goolib.RepoSpec, error) {
+// fileTransport serves file URLs from the local file system, or from UNC
+// paths on Windows if they have a host.
+type fileTransport struct{}
+
+func (fileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p := filepath.FromSlash(req.URL.Path)
+	if runtime.GOOS == "windows" {
+		if req.URL.Host != "" {
+			p = `\\` + req.URL.Host + p
+		} else {
+			p = strings.TrimPrefix(p, `\`)
+		}
+	}
+	resp := &http.Response{
+		Proto:      "HTTP/1.0",
+		ProtoMajor: 1,
+		Header:     make(http.Header),
+		Request:    req,
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		resp.Status = "404 Not Found"
+		resp.StatusCode = http.StatusNotFound
+		resp.Body = http.NoBody
+		return resp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := f.Stat(); err == nil {
+		resp.Header.Set("Last-Modified", fi.ModTime().UTC().Format(http.TimeFormat))
+	}
+	resp.Status = "200 OK"
+	resp.StatusCode = http.StatusOK
+	resp.Body = f
+	return resp, nil
+}
+
+// Downloader retrieves the contents of a URL. It allows the transport used
+// to talk to repos to be replaced, for example with an in-memory
+// implementation in tests.
+type Downloader interface {
+	Get(ctx context.Context, url string) (*http.Response, error)
+}
+
+// HTTPDownloader is a Downloader backed by Get using an optional proxy server.
+type HTTPDownloader struct {
+	ProxyServer string
+	// Repos maps repo URLs to their settings, which replace ProxyServer and
+	// RootCAs for the URLs under the repo URL and those got with a context
+	// from WithRepo.
+	Repos map[string]RepoTransport
+}
+
+// Get gets a url using the configured proxy server.
+func (d HTTPDownloader) Get(ctx context.Context, url string) (*http.Response, error) {
+	if repo, ok := ctx.Value(repoKey{}).(string); ok {
+		if t, ok := d.repoTransport(repo); ok {
+			return get(ctx, url, t)
+		}
+	}
+	return get(ctx, url, d.transport(url))
+}
+
+// transport returns the settings used for u, those of the repo with the
+// longest URL u is under.
+func (d HTTPDownloader) transport(u string) RepoTransport {
+	t := RepoTransport{ProxyServer: d.ProxyServer, RootCAs: RootCAs, Certificate: Certificate}
+	u = strings.TrimPrefix(u, "oauth-")
+	best := -1
+	for repo, rt := range d.Repos {
+		repo = trimRepo(repo) + "/"
+		if !strings.HasPrefix(u, repo) || len(repo) <= best {
+			continue
+		}
+		best = len(repo)
+		t = d.merge(rt)
+	}
+	return t
+}
+
+// repoTransport returns the settings of repo, ok false if it has none.
+func (d HTTPDownloader) repoTransport(repo string) (RepoTransport, bool) {
+	repo = trimRepo(repo)
+	for r, rt := range d.Repos {
+		if trimRepo(r) == repo {
+			return d.merge(rt), true
+		}
+	}
+	return RepoTransport{}, false
+}
+
+// merge returns the settings of a repo, rt, over those of d.
+func (d HTTPDownloader) merge(rt RepoTransport) RepoTransport {
+	t := RepoTransport{ProxyServer: d.ProxyServer, RootCAs: RootCAs, Certificate: Certificate, Timeout: rt.Timeout, Headers: rt.Headers}
+	if rt.ProxyServer != "" {
+		t.ProxyServer = rt.ProxyServer
+	}
+	if rt.RootCAs != nil {
+		t.RootCAs = rt.RootCAs
+	}
+	if rt.Certificate != nil {
+		t.Certificate = rt.Certificate
+	}
+	return t
+}
+
+func trimRepo(repo string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(repo, "oauth-"), "/")
+}
+
+type repoKey struct{}
+
+// WithRepo returns a copy of ctx with which an HTTPDownloader gets URLs with
+// the settings of repo. Packages and sidecars are fetched with it, as they
+// can be outside the repo URL.
+func WithRepo(ctx context.Context, repo string) context.Context {
+	return context.WithValue(ctx, repoKey{}, repo)
+}
+
+// ProxyServer returns the proxy server used by downloader for url, if any.
+// Only an HTTPDownloader is known to use a proxy.
+func ProxyServer(downloader Downloader, url string) string {
+	if d, ok := downloader.(HTTPDownloader); ok {
+		return d.transport(url).ProxyServer
+	}
+	return ""
+}
+
+func unmarshalRepoPackagesHTTP(ctx context.Context, repoURL string, cf string, downloader Downloader) ([]goolib.RepoSpec, error) {
 	indexURL := repoURL + "/index.gz"
 	trimmedIndexURL := strings.TrimPrefix(indexURL, "oauth-")
 	ct := "application/x-gzip"
 	logger.Infof("Fetching %q", trimmedIndexURL)
-	res, err := Get(ctx, indexURL, proxyServer)
+	res, err := downloader.Get(ctx, indexURL)
 	if err != nil {
 		return nil, err
 	}
@@ -265,7 +760,7 @@ func unmarshalRepoPackagesHTTP(ctx context.Context, repoURL string, cf string, p
 		indexURL = repoURL + "/index"
 		ct = "application/json"
 		logger.Infof("Fetching %q", trimmedIndexURL)
-		res, err = Get(ctx, indexURL, proxyServer)
+		res, err = downloader.Get(ctx, indexURL)
 		if err != nil {
 			return nil, err
 		}
@@ -275,15 +770,27 @@ func unmarshalRepoPackagesHTTP(ctx context.Context, repoURL string, cf string, p
 		}
 	}
 
-	return decode(res.Body, ct, repoURL, cf)
+	var verify func([]byte) error
+	if Pins != nil {
+		verify = func(b []byte) error { return verifyIndex(ctx, repoURL, b, downloader) }
+	}
+	m, err := decode(res.Body, ct, repoURL, cf, verify)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeExpiry(cf, res.Header); err != nil {
+		logger.Errorf("Error recording when the index of %s expires: %v", strings.TrimPrefix(repoURL, "oauth-"), err)
+	}
+	return m, nil
 }
 
-func unmarshalRepoPackagesGCS(ctx context.Context, bucket, object, url, cf string, proxyServer string) ([]goolib.RepoSpec, error) {
-	if proxyServer != "" {
+func unmarshalRepoPackagesGCS(ctx context.Context, bucket, object, url, cf string, downloader Downloader) ([]goolib.RepoSpec, error) {
+	if ProxyServer(downloader, url) != "" {
 		logger.Errorf("Proxy server not supported with gs:// URLs, skiping repo 'gs://%s/%s'", bucket, object)
 		var empty []goolib.RepoSpec
 		return empty, nil
 	}
+	warnGCSProxy()
 
 	client, err := storage.NewClient(ctx)
 	if err != nil {
@@ -296,9 +803,13 @@ func unmarshalRepoPackagesGCS(ctx context.Context, bucket, object, url, cf strin
 	}
 
 	indexPath := object + "index.gz"
+	var verify func([]byte) error
+	if Pins != nil {
+		verify = func(b []byte) error { return verifyIndex(ctx, url, b, downloader) }
+	}
 	logger.Infof("Fetching 'gs://%s/%s", bucket, indexPath)
 	if r, err := bkt.Object(indexPath).NewReader(ctx); err == nil {
-		return decode(r, "application/x-gzip", url, cf)
+		return decode(r, "application/x-gzip", url, cf, verify)
 	}
 
 	if gErr, ok := err.(*googleapi.Error); ok && gErr.Code != http.StatusNotFound {
@@ -312,7 +823,7 @@ func unmarshalRepoPackagesGCS(ctx context.Context, bucket, object, url, cf strin
 		return nil, err
 	}
 
-	return decode(r, "application/json", url, cf)
+	return decode(r, "application/json", url, cf, verify)
 }
 
 // FindRepoSpec returns the RepoSpec in repo whose PackageSpec matches pi.
@@ -354,8 +865,15 @@ func latest(psm map[string][]*goolib.PkgSpec, rm RepoMap) (string, string) {
 
 // FindRepoLatest returns the latest version of a package along with its repo and arch.
 // The archs are searched in order; if a matching package is found for any arch, it is
-// returned immediately even if a later arch might have a later version.
+// returned immediately even if a later arch might have a later version. Yanked
+// versions are never returned.
 func FindRepoLatest(pi goolib.PackageInfo, rm RepoMap, archs []string) (string, string, string, error) {
+	return FindRepoLatestMatching(pi, goolib.Constraint{}, rm, archs)
+}
+
+// FindRepoLatestMatching is FindRepoLatest only considering versions
+// satisfying c.
+func FindRepoLatestMatching(pi goolib.PackageInfo, c goolib.Constraint, rm RepoMap, archs []string) (string, string, string, error) {
 	psm := make(map[string][]*goolib.PkgSpec)
 	name := pi.Name
 	if pi.Arch != "" {
@@ -365,9 +883,13 @@ func FindRepoLatest(pi goolib.PackageInfo, rm RepoMap, archs []string) (string,
 	for _, a := range archs {
 		for r, repo := range rm {
 			for _, p := range repo.Packages {
-				if p.PackageSpec.Name == pi.Name && p.PackageSpec.Arch == a {
-					psm[r] = append(psm[r], p.PackageSpec)
+				if p.PackageSpec.Name != pi.Name || p.PackageSpec.Arch != a || p.Status == goolib.StatusYanked {
+					continue
+				}
+				if ok, err := c.Check(p.PackageSpec.Version); err != nil || !ok {
+					continue
 				}
+				psm[r] = append(psm[r], p.PackageSpec)
 			}
 		}
 		if len(psm) != 0 {
@@ -375,9 +897,44 @@ func FindRepoLatest(pi goolib.PackageInfo, rm RepoMap, archs []string) (string,
 			return v, r, a, nil
 		}
 	}
+	if ver := c.String(); ver != "" {
+		return "", "", "", fmt.Errorf("no versions of package %s matching %s found in any repo", name, ver)
+	}
 	return "", "", "", fmt.Errorf("no versions of package %s found in any repo", name)
 }
 
+// Renamed returns the name that packages named name were renamed to in rm,
+// following repeated renames, ok is false if they were not renamed. If repos
+// disagree the rename of the highest priority repo is used.
+func Renamed(name string, rm RepoMap) (newName string, ok bool) {
+	seen := map[string]bool{name: true}
+	for {
+		n, found := renamedTo(name, rm)
+		if !found || seen[n] {
+			return name, ok
+		}
+		seen[n] = true
+		name, ok = n, true
+	}
+}
+
+func renamedTo(name string, rm RepoMap) (string, bool) {
+	var to string
+	var pri priority.Value
+	found := false
+	for _, repo := range rm {
+		for _, p := range repo.Packages {
+			if p.PackageSpec.Name != name || p.RenamedTo == "" {
+				continue
+			}
+			if !found || repo.Priority > pri || repo.Priority == pri && p.RenamedTo < to {
+				to, pri, found = p.RenamedTo, repo.Priority, true
+			}
+		}
+	}
+	return to, found
+}
+
 // WhatRepo returns what repo a package is in.
 // Name, Arch, and Ver fields of PackageInfo must be provided.
 func WhatRepo(pi goolib.PackageInfo, rm RepoMap) (string, error) {
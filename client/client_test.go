@@ -17,15 +17,24 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -94,6 +103,23 @@ func TestGetPackageStateNoMatch(t *testing.T) {
 	}
 }
 
+func TestSharesCache(t *testing.T) {
+	a := PackageState{LocalPath: "cache/abc.goo", PackageSpec: &goolib.PkgSpec{Name: "a", Arch: "noarch", Version: "1"}}
+	b := PackageState{LocalPath: "cache/abc.goo", PackageSpec: &goolib.PkgSpec{Name: "b", Arch: "noarch", Version: "1"}}
+	c := PackageState{LocalPath: "cache/def.goo", PackageSpec: &goolib.PkgSpec{Name: "c", Arch: "noarch", Version: "1"}}
+	s := &GooGetState{a, c}
+	if s.SharesCache(a) {
+		t.Error("SharesCache reported a package sharing its cache entry with itself")
+	}
+	s.Add(b)
+	if !s.SharesCache(a) {
+		t.Error("SharesCache did not report a package with the same cache entry")
+	}
+	if s.SharesCache(PackageState{PackageSpec: &goolib.PkgSpec{Name: "d"}}) {
+		t.Error("SharesCache reported a package without a cache entry")
+	}
+}
+
 func TestWhatRepo(t *testing.T) {
 	rm := RepoMap{
 		"foo_repo": Repo{
@@ -118,6 +144,55 @@ func TestWhatRepo(t *testing.T) {
 	}
 }
 
+func TestRenamed(t *testing.T) {
+	pkg := func(name, to string) goolib.RepoSpec {
+		return goolib.RepoSpec{PackageSpec: &goolib.PkgSpec{Name: name, Arch: "noarch", Version: "1.0.0@1"}, RenamedTo: to}
+	}
+	rm := RepoMap{
+		"low":  Repo{Priority: 500, Packages: []goolib.RepoSpec{pkg("a", "b"), pkg("b", "c"), pkg("x", "low"), pkg("loop1", "loop2"), pkg("loop2", "loop1")}},
+		"high": Repo{Priority: 1000, Packages: []goolib.RepoSpec{pkg("x", "high"), pkg("c", "")}},
+	}
+	for _, tt := range []struct {
+		name, want string
+		ok         bool
+	}{
+		{"a", "c", true},
+		{"b", "c", true},
+		{"c", "c", false},
+		{"x", "high", true},
+		{"loop1", "loop2", true},
+		{"unknown", "unknown", false},
+	} {
+		if got, ok := Renamed(tt.name, rm); got != tt.want || ok != tt.ok {
+			t.Errorf("Renamed(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestClearReboots(t *testing.T) {
+	boot := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	before, after := boot.Add(-time.Hour), boot.Add(time.Hour)
+	state := GooGetState{
+		{PackageSpec: &goolib.PkgSpec{Name: "before"}, RebootRequired: true, RebootRequested: &before},
+		{PackageSpec: &goolib.PkgSpec{Name: "after"}, RebootRequired: true, RebootRequested: &after},
+		{PackageSpec: &goolib.PkgSpec{Name: "old"}, RebootRequired: true},
+	}
+	if !state.ClearReboots(boot) {
+		t.Error("ClearReboots = false, want true")
+	}
+	for i, want := range []bool{false, true, true} {
+		if state[i].RebootRequired != want {
+			t.Errorf("%s: RebootRequired = %v, want %v", state[i].PackageSpec.Name, state[i].RebootRequired, want)
+		}
+	}
+	if state[0].RebootRequested != nil {
+		t.Errorf("RebootRequested = %v, want nil", state[0].RebootRequested)
+	}
+	if state.ClearReboots(boot) {
+		t.Error("second ClearReboots = true, want false")
+	}
+}
+
 func TestFindRepoLatest(t *testing.T) {
 	for _, tt := range []struct {
 		desc        string
@@ -220,6 +295,32 @@ func TestFindRepoLatest(t *testing.T) {
 			wantArch:    "noarch",
 			wantRepo:    "high_priority_repo",
 		},
+		{
+			desc:  "yanked version skipped",
+			pi:    goolib.PackageInfo{Name: "foo_pkg", Arch: "noarch"},
+			archs: []string{"noarch"},
+			rm: RepoMap{
+				"foo_repo": Repo{Packages: []goolib.RepoSpec{
+					{PackageSpec: &goolib.PkgSpec{Name: "foo_pkg", Version: "1.2.3@4", Arch: "noarch"}},
+					{PackageSpec: &goolib.PkgSpec{Name: "foo_pkg", Version: "2.0.0@1", Arch: "noarch"}, Status: goolib.StatusYanked},
+					{PackageSpec: &goolib.PkgSpec{Name: "foo_pkg", Version: "1.5.0@1", Arch: "noarch"}, Status: goolib.StatusDeprecated},
+				}},
+			},
+			wantVersion: "1.5.0@1",
+			wantArch:    "noarch",
+			wantRepo:    "foo_repo",
+		},
+		{
+			desc:  "only yanked versions",
+			pi:    goolib.PackageInfo{Name: "foo_pkg", Arch: "noarch"},
+			archs: []string{"noarch"},
+			rm: RepoMap{
+				"foo_repo": Repo{Packages: []goolib.RepoSpec{
+					{PackageSpec: &goolib.PkgSpec{Name: "foo_pkg", Version: "2.0.0@1", Arch: "noarch"}, Status: goolib.StatusYanked},
+				}},
+			},
+			wantErr: true,
+		},
 	} {
 		t.Run(tt.desc, func(t *testing.T) {
 			gotVersion, gotRepo, gotArch, err := FindRepoLatest(tt.pi, tt.rm, tt.archs)
@@ -241,6 +342,46 @@ func TestFindRepoLatest(t *testing.T) {
 	}
 }
 
+func TestFindRepoLatestMatching(t *testing.T) {
+	rm := RepoMap{
+		"high_priority_repo": Repo{
+			Priority: 1500,
+			Packages: []goolib.RepoSpec{
+				{PackageSpec: &goolib.PkgSpec{Name: "foo_pkg", Version: "2.1.0@1", Arch: "noarch"}},
+			},
+		},
+		"low_priority_repo": Repo{
+			Priority: 500,
+			Packages: []goolib.RepoSpec{
+				{PackageSpec: &goolib.PkgSpec{Name: "foo_pkg", Version: "1.4.0@1", Arch: "noarch"}},
+				{PackageSpec: &goolib.PkgSpec{Name: "foo_pkg", Version: "1.5.0@1", Arch: "noarch"}},
+				{PackageSpec: &goolib.PkgSpec{Name: "foo_pkg", Version: "1.3.0@1", Arch: "noarch"}},
+			},
+		},
+	}
+	for _, tt := range []struct {
+		c, wantVersion, wantRepo string
+	}{
+		{"", "2.1.0@1", "high_priority_repo"},
+		{"1.4.0", "2.1.0@1", "high_priority_repo"},
+		{">=1.2 <2.0 !=1.5.0", "1.4.0@1", "low_priority_repo"},
+		{"~1.3", "1.3.0@1", "low_priority_repo"},
+		{">=3", "", ""},
+	} {
+		c, err := goolib.ParseConstraint(tt.c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotVersion, gotRepo, _, err := FindRepoLatestMatching(goolib.PackageInfo{Name: "foo_pkg"}, c, rm, []string{"noarch"})
+		if (err != nil) != (tt.wantVersion == "") {
+			t.Errorf("FindRepoLatestMatching(%q) returned error %v", tt.c, err)
+		}
+		if gotVersion != tt.wantVersion || gotRepo != tt.wantRepo {
+			t.Errorf("FindRepoLatestMatching(%q) = %q, %q, want %q, %q", tt.c, gotVersion, gotRepo, tt.wantVersion, tt.wantRepo)
+		}
+	}
+}
+
 func TestUnmarshalRepoPackagesJSON(t *testing.T) {
 	tempDir, err := ioutil.TempDir("", "")
 	if err != nil {
@@ -268,7 +409,7 @@ func TestUnmarshalRepoPackagesJSON(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	got, err := unmarshalRepoPackages(context.Background(), ts.URL, tempDir, cacheLife, proxyServer)
+	got, err := unmarshalRepoPackages(context.Background(), ts.URL, tempDir, cacheLife, HTTPDownloader{ProxyServer: proxyServer})
 	if err != nil {
 		t.Fatalf("Error running unmarshalRepoPackages: %v", err)
 	}
@@ -278,6 +419,112 @@ func TestUnmarshalRepoPackagesJSON(t *testing.T) {
 	}
 }
 
+func TestHTTPDownloaderTransport(t *testing.T) {
+	d := HTTPDownloader{
+		ProxyServer: "http://global:3128",
+		Repos: map[string]RepoTransport{
+			"https://example.com/repos/stable":         {ProxyServer: "http://internal:3128", Timeout: time.Minute},
+			"oauth-https://example.com/repos/sub/beta": {Timeout: time.Second},
+			"https://other.example.com":                {ProxyServer: "http://other:3128"},
+		},
+	}
+	table := []struct {
+		url         string
+		wantProxy   string
+		wantTimeout time.Duration
+	}{
+		{"https://example.com/repos/stable/index.gz", "http://internal:3128", time.Minute},
+		{"https://example.com/repos/foo.x86_64.1.0.0@1.goo", "http://global:3128", 0},
+		{"https://example.com/repos/stable-old/index.gz", "http://global:3128", 0},
+		{"oauth-https://example.com/repos/sub/beta/index.gz", "http://global:3128", time.Second},
+		{"https://other.example.com/index.gz", "http://other:3128", 0},
+		{"https://example.com/other/index.gz", "http://global:3128", 0},
+	}
+	for _, tt := range table {
+		got := d.transport(tt.url)
+		if got.ProxyServer != tt.wantProxy || got.Timeout != tt.wantTimeout {
+			t.Errorf("transport(%q) = proxy %q timeout %v, want %q %v", tt.url, got.ProxyServer, got.Timeout, tt.wantProxy, tt.wantTimeout)
+		}
+	}
+}
+
+func TestHTTPDownloaderFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "index"), []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	base := "file:///" + strings.TrimPrefix(filepath.ToSlash(dir), "/")
+
+	resp, err := HTTPDownloader{}.Get(context.Background(), base+"/index")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK || string(b) != "[]" {
+		t.Errorf("Get of the index = %d %q, %v, want 200 \"[]\"", resp.StatusCode, b, err)
+	}
+
+	resp, err = HTTPDownloader{}.Get(context.Background(), base+"/index.gz")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Get of a missing file returned status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestHTTPDownloaderRepoCA(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	if _, err := (HTTPDownloader{}).Get(context.Background(), ts.URL+"/repo/index"); err == nil {
+		t.Error("Get of a server with an untrusted certificate got no error")
+	}
+	d := HTTPDownloader{Repos: map[string]RepoTransport{ts.URL + "/repo": {RootCAs: pool}}}
+	res, err := d.Get(context.Background(), ts.URL+"/repo/index")
+	if err != nil {
+		t.Fatalf("Get with the repo CA: %v", err)
+	}
+	res.Body.Close()
+}
+
+func TestHTTPDownloaderClientCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	defer func(r *x509.CertPool) { RootCAs = r }(RootCAs)
+	RootCAs = pool
+
+	if _, err := (HTTPDownloader{}).Get(context.Background(), ts.URL+"/index"); err == nil {
+		t.Error("Get without a client certificate got no error")
+	}
+	defer func() { Certificate = nil }()
+	Certificate = cert
+	res, err := (HTTPDownloader{}).Get(context.Background(), ts.URL+"/index")
+	if err != nil {
+		t.Fatalf("Get with a client certificate: %v", err)
+	}
+	res.Body.Close()
+}
+
 func TestUnmarshalRepoPackagesGzip(t *testing.T) {
 	tempDir, err := ioutil.TempDir("", "")
 	if err != nil {
@@ -313,7 +560,7 @@ func TestUnmarshalRepoPackagesGzip(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	got, err := unmarshalRepoPackages(context.Background(), ts.URL, tempDir, cacheLife, proxyServer)
+	got, err := unmarshalRepoPackages(context.Background(), ts.URL, tempDir, cacheLife, HTTPDownloader{ProxyServer: proxyServer})
 	if err != nil {
 		t.Fatalf("Error running unmarshalRepoPackages: %v", err)
 	}
@@ -351,7 +598,7 @@ func TestUnmarshalRepoPackagesCache(t *testing.T) {
 	}
 
 	// No http server as this should use the cached content.
-	got, err := unmarshalRepoPackages(context.Background(), url, tempDir, cacheLife, proxyServer)
+	got, err := unmarshalRepoPackages(context.Background(), url, tempDir, cacheLife, HTTPDownloader{ProxyServer: proxyServer})
 	if err != nil {
 		t.Fatalf("Error running unmarshalRepoPackages: %v", err)
 	}
@@ -361,6 +608,171 @@ func TestUnmarshalRepoPackagesCache(t *testing.T) {
 	}
 }
 
+func TestGetTimeouts(t *testing.T) {
+	defer func(d Timeouts) { DefaultTimeouts = d }(DefaultTimeouts)
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			io.WriteString(w, "partial")
+			w.(http.Flusher).Flush()
+		}
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	DefaultTimeouts.ResponseHeader = 50 * time.Millisecond
+	if _, err := get(context.Background(), ts.URL+"/hang", RepoTransport{}); err == nil {
+		t.Error("get from a server that never answers returned no error")
+	}
+
+	DefaultTimeouts.Request = 100 * time.Millisecond
+	res, err := get(context.Background(), ts.URL+"/slow", RepoTransport{})
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer res.Body.Close()
+	if _, err := ioutil.ReadAll(res.Body); err == nil {
+		t.Error("reading a body that never ends returned no error")
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		header map[string]string
+		want   time.Time
+		ok     bool
+	}{
+		{nil, time.Time{}, false},
+		{map[string]string{"Cache-Control": "public, max-age=600"}, now.Add(10 * time.Minute), true},
+		{map[string]string{"Cache-Control": "max-age=600", "Age": "100"}, now.Add(500 * time.Second), true},
+		{map[string]string{"Cache-Control": "no-cache"}, now, true},
+		{map[string]string{"Cache-Control": "No-Store", "Expires": "Fri, 16 Oct 2026 13:00:00 GMT"}, now, true},
+		// Expires is relative to the Date of the server.
+		{map[string]string{"Expires": "Fri, 16 Oct 2026 13:00:00 GMT", "Date": "Fri, 16 Oct 2026 12:30:00 GMT"}, now.Add(30 * time.Minute), true},
+		{map[string]string{"Expires": "Fri, 16 Oct 2026 13:00:00 GMT"}, now.Add(time.Hour), true},
+		{map[string]string{"Expires": "0"}, now, true},
+	} {
+		h := make(http.Header)
+		for k, v := range tt.header {
+			h.Set(k, v)
+		}
+		got, ok := cacheExpiry(h, now)
+		if !got.Equal(tt.want) || ok != tt.ok {
+			t.Errorf("cacheExpiry(%v) = %v, %v, want %v, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestUnmarshalRepoPackagesFreshness(t *testing.T) {
+	defer func() { RepoCacheLife = nil }()
+	var fetches int32
+	var cacheControl atomic.Value
+	cacheControl.Store("max-age=3600")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&fetches, 1)
+		w.Header().Set("Cache-Control", cacheControl.Load().(string))
+		io.WriteString(w, `[{"Source":"foo"}]`)
+	}))
+	defer ts.Close()
+	tempDir := t.TempDir()
+	ctx := context.Background()
+	fetch := func(life time.Duration) {
+		t.Helper()
+		if _, err := unmarshalRepoPackages(ctx, ts.URL, tempDir, life, HTTPDownloader{}); err != nil {
+			t.Fatalf("unmarshalRepoPackages: %v", err)
+		}
+	}
+	check := func(name string, want int32) {
+		t.Helper()
+		if got := atomic.LoadInt32(&fetches); got != want {
+			t.Errorf("%s: fetched the index %d times, want %d", name, got, want)
+		}
+	}
+
+	fetch(time.Nanosecond)
+	fetch(time.Nanosecond)
+	check("index fresh for an hour per the server", 1)
+	fetch(0)
+	check("cache life of 0", 2)
+
+	RepoCacheLife = map[string]time.Duration{ts.URL: 0}
+	fetch(cacheLife)
+	check("cache life of the repo", 3)
+	RepoCacheLife = nil
+	ctx = WithRepoCacheLife(ctx, map[string]time.Duration{ts.URL: 0})
+	fetch(cacheLife)
+	check("cache life of the repo in the context", 4)
+	ctx = context.Background()
+
+	cacheControl.Store("no-cache")
+	fetch(0)
+	fetch(cacheLife)
+	check("index not to be cached per the server", 6)
+}
+
+func TestUnmarshalRepoPackagesConcurrent(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+
+	want := []goolib.RepoSpec{
+		{Source: "foo"},
+		{Source: "bar"},
+	}
+	j, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("Error marshalling json: %v", err)
+	}
+	var fetches int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.String() == "/index" {
+			atomic.AddInt32(&fetches, 1)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(j)
+		} else {
+			w.WriteHeader(404)
+		}
+	}))
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := unmarshalRepoPackages(context.Background(), ts.URL, tempDir, cacheLife, HTTPDownloader{ProxyServer: proxyServer})
+			if err != nil {
+				t.Errorf("Error running unmarshalRepoPackages: %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("unmarshalRepoPackages did not return expected content, got: %+v, want: %+v", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The runs waiting for the lock use the cache written by the first.
+	if fetches != 1 {
+		t.Errorf("index was fetched %d times, want 1", fetches)
+	}
+	tmp, err := filepath.Glob(filepath.Join(tempDir, "*.tmp*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmp) != 0 {
+		t.Errorf("temporary cache files were left behind: %v", tmp)
+	}
+}
+
 func TestFindRepoSpec(t *testing.T) {
 	want := goolib.RepoSpec{PackageSpec: &goolib.PkgSpec{Name: "test"}}
 	repo := Repo{Packages: []goolib.RepoSpec{
@@ -384,3 +796,67 @@ func TestFindRepoSpecNoMatch(t *testing.T) {
 		t.Error("did not get expected error when running FindRepoSpec")
 	}
 }
+
+func TestRepoHeaders(t *testing.T) {
+	var other http.Header
+	ts2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		other = r.Header
+	}))
+	defer ts2.Close()
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		http.Redirect(w, r, ts2.URL+"/moved", http.StatusFound)
+	}))
+	defer ts.Close()
+
+	d := HTTPDownloader{Repos: map[string]RepoTransport{ts.URL + "/repo": {Headers: map[string]string{"X-Api-Key": "secret"}}}}
+	res, err := d.Get(context.Background(), ts.URL+"/repo/index")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	res.Body.Close()
+	if got.Get("X-Api-Key") != "secret" {
+		t.Errorf("request headers %v, want X-Api-Key: secret", got)
+	}
+	if other == nil || other.Get("X-Api-Key") != "" {
+		t.Errorf("headers of the request redirected to another host %v, want no X-Api-Key", other)
+	}
+}
+
+func TestWithRepo(t *testing.T) {
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("X-Api-Key"))
+	}))
+	defer ts.Close()
+
+	d := HTTPDownloader{Repos: map[string]RepoTransport{ts.URL + "/repos/stable": {Headers: map[string]string{"X-Api-Key": "secret"}}}}
+	// Packages are next to the repo rather than under it.
+	for _, ctx := range []context.Context{context.Background(), WithRepo(context.Background(), ts.URL+"/repos/stable/")} {
+		res, err := d.Get(ctx, ts.URL+"/repos/foo.x86_64.1.0.0@1.goo")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		res.Body.Close()
+	}
+	if want := []string{"", "secret"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("X-Api-Key headers = %q, want %q", got, want)
+	}
+}
+
+func TestCheckHeader(t *testing.T) {
+	for _, tt := range []struct {
+		name, value string
+		ok          bool
+	}{
+		{"X-Api-Key", "secret", true},
+		{"X Api Key", "secret", false},
+		{"", "secret", false},
+		{"X-Api-Key", "secret\r\nX-Other: 1", false},
+	} {
+		if err := CheckHeader(tt.name, tt.value); (err == nil) != tt.ok {
+			t.Errorf("CheckHeader(%q, %q) = %v, want ok %v", tt.name, tt.value, err, tt.ok)
+		}
+	}
+}
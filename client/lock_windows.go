@@ -0,0 +1,35 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockCache takes an exclusive lock on the cache file cf, waiting for other
+// GooGet processes holding it, and returns the function releasing it.
+func lockCache(cf string) (func(), error) {
+	f, err := os.OpenFile(cf+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	h := windows.Handle(f.Fd())
+	if err := windows.LockFileEx(h, windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &windows.Overlapped{}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() { windows.UnlockFileEx(h, 0, 1, 0, &windows.Overlapped{}); f.Close() }, nil
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/logger"
+)
+
+// Kinds of pinned values.
+const (
+	// PinCertificate is the public key of the CA that issued the TLS
+	// certificate of a host.
+	PinCertificate = "certificate"
+	// PinKey is the key a repo signs its index with.
+	PinKey = "signing key"
+)
+
+// Pin holds the fingerprints first seen for a host or repo, and the changed
+// fingerprints seen since, which are rejected until accepted.
+type Pin struct {
+	Certificate        string `json:",omitempty"`
+	Key                string `json:",omitempty"`
+	PendingCertificate string `json:",omitempty"`
+	PendingKey         string `json:",omitempty"`
+}
+
+func (p *Pin) fields(kind string) (pinned, pending *string) {
+	if kind == PinCertificate {
+		return &p.Certificate, &p.PendingCertificate
+	}
+	return &p.Key, &p.PendingKey
+}
+
+// PinStore is a file of the pins of hosts and repos.
+type PinStore struct {
+	path string
+	mu   sync.Mutex
+	pins map[string]*Pin
+}
+
+// Pins, if set, pins the TLS certificates of HTTPS hosts and the index
+// signing keys of repos when they are first seen.
+var Pins *PinStore
+
+// LoadPins reads the pins stored at path, which need not exist yet.
+func LoadPins(path string) (*PinStore, error) {
+	s := &PinStore{path: path, pins: make(map[string]*Pin)}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.pins); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// save writes the pins, s.mu must be held.
+func (s *PinStore) save() {
+	b, err := json.MarshalIndent(s.pins, "", "  ")
+	if err == nil {
+		err = writeCache(s.path, b)
+	}
+	if err != nil {
+		logger.Errorf("Error saving pins: %v", err)
+	}
+}
+
+// Fingerprint returns the fingerprint of b as pinned.
+func Fingerprint(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// check pins fingerprint fp of kind for name if nothing is pinned yet, and
+// returns an error if another fingerprint is pinned.
+func (s *PinStore) check(name, kind, fp string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pins[name]
+	if !ok {
+		p = &Pin{}
+		s.pins[name] = p
+	}
+	pinned, pending := p.fields(kind)
+	switch *pinned {
+	case fp:
+		return nil
+	case "":
+		logger.Infof("Pinning the %s of %s: %s", kind, name, fp)
+		*pinned = fp
+		s.save()
+		return nil
+	}
+	if *pending != fp {
+		*pending = fp
+		s.save()
+	}
+	return fmt.Errorf("the %s of %s changed from %s to %s since it was first seen, run 'googet trust accept %s' if the change is expected", kind, name, *pinned, fp, name)
+}
+
+// checkCertificate checks the certificate of the host of the TLS connection
+// cs, which is host if the connection has no server name, as for IPs. The
+// issuing CA is pinned rather than the certificate itself, whose key changes
+// each time the host renews it, a self-signed certificate being its own CA.
+func (s *PinStore) checkCertificate(host string, cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+	if cs.ServerName != "" {
+		host = cs.ServerName
+	}
+	return s.check(host, PinCertificate, Fingerprint(issuer(cs).RawSubjectPublicKeyInfo))
+}
+
+// issuer returns the certificate of the CA that issued the certificate of
+// the peer of cs, the certificate itself if the chain has no other.
+func issuer(cs tls.ConnectionState) *x509.Certificate {
+	chain := cs.PeerCertificates
+	if len(cs.VerifiedChains) > 0 {
+		chain = cs.VerifiedChains[0]
+	}
+	if len(chain) > 1 {
+		return chain[1]
+	}
+	return cs.PeerCertificates[0]
+}
+
+// verifyIndex checks the signature of the index b of the repo at repoURL
+// with the signing key the repo publishes, if any, which is pinned.
+func verifyIndex(ctx context.Context, repoURL string, b []byte, downloader Downloader) error {
+	name := strings.TrimPrefix(repoURL, "oauth-")
+	key, sig, err := indexSignature(ctx, repoURL, downloader)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		if Pins.Get(name).Key != "" {
+			return fmt.Errorf("repo %s no longer publishes its index signing key", name)
+		}
+		return nil
+	}
+	if err := Pins.check(name, PinKey, Fingerprint(key)); err != nil {
+		return err
+	}
+	if !goolib.VerifySignature(key, b, sig) {
+		return fmt.Errorf("index signature of repo %s does not verify with its signing key", name)
+	}
+	return nil
+}
+
+// indexSignature returns the index signing key the repo at repoURL
+// publishes and the signature of its index, a nil key if it publishes none.
+func indexSignature(ctx context.Context, repoURL string, downloader Downloader) (ed25519.PublicKey, []byte, error) {
+	name := strings.TrimPrefix(repoURL, "oauth-")
+	pub, err := readRepoFile(ctx, repoURL, "index"+goolib.PublicKeySuffix, downloader)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading the index signing key: %v", err)
+	}
+	key, err := goolib.ParseVerifyKey(pub)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid index signing key of repo %s: %v", name, err)
+	}
+	sig, err := readRepoFile(ctx, repoURL, "index"+goolib.SignatureSuffix, downloader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading the index signature: %v", err)
+	}
+	return key, sig, nil
+}
+
+// readRepoFile reads the file next to the index of the repo at repoURL, from
+// its bucket for gs:// repos. The error satisfies os.IsNotExist if the repo
+// has no such file.
+func readRepoFile(ctx context.Context, repoURL, file string, downloader Downloader) ([]byte, error) {
+	if isGCSURL, bucket, object := goolib.SplitGCSUrl(repoURL); isGCSURL {
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(object) != 0 {
+			object += "/"
+		}
+		r, err := client.Bucket(bucket).Object(object + file).NewReader(ctx)
+		if err == storage.ErrObjectNotExist {
+			return nil, os.ErrNotExist
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	res, err := downloader.Get(ctx, repoURL+"/"+file)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, os.ErrNotExist
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s GET request returned status: %q", file, res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// Accept pins the changed fingerprints seen for name, it returns an error if
+// there are none.
+func (s *PinStore) Accept(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pins[name]
+	if !ok || (p.PendingCertificate == "" && p.PendingKey == "") {
+		return fmt.Errorf("no changes seen for %s", name)
+	}
+	for _, kind := range []string{PinCertificate, PinKey} {
+		if pinned, pending := p.fields(kind); *pending != "" {
+			logger.Infof("Accepted the new %s of %s: %s", kind, name, *pending)
+			*pinned, *pending = *pending, ""
+		}
+	}
+	s.save()
+	return nil
+}
+
+// Names returns the sorted names of the hosts and repos with pins.
+func (s *PinStore) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for n := range s.pins {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the pins of name.
+func (s *PinStore) Get(name string) Pin {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.pins[name]; ok {
+		return *p
+	}
+	return Pin{}
+}
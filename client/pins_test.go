@@ -0,0 +1,189 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestPinStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "googet.pins")
+	s, err := LoadPins(path)
+	if err != nil {
+		t.Fatalf("LoadPins: %v", err)
+	}
+	if err := s.check("repo", PinKey, "aaaa"); err != nil {
+		t.Errorf("check on first use: %v", err)
+	}
+	if err := s.check("repo", PinKey, "aaaa"); err != nil {
+		t.Errorf("check of the pinned key: %v", err)
+	}
+	if err := s.check("repo", PinCertificate, "cccc"); err != nil {
+		t.Errorf("check of the first certificate: %v", err)
+	}
+	if err := s.check("repo", PinKey, "bbbb"); err == nil || !strings.Contains(err.Error(), "googet trust accept repo") {
+		t.Errorf("check of a changed key returned %v, want an error naming googet trust accept", err)
+	}
+
+	// The change is remembered until accepted.
+	s, err = LoadPins(path)
+	if err != nil {
+		t.Fatalf("LoadPins: %v", err)
+	}
+	if got, want := s.Get("repo"), (Pin{Certificate: "cccc", Key: "aaaa", PendingKey: "bbbb"}); got != want {
+		t.Errorf("Get after reloading = %+v, want %+v", got, want)
+	}
+	if err := s.Accept("repo"); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	if err := s.check("repo", PinKey, "bbbb"); err != nil {
+		t.Errorf("check of the accepted key: %v", err)
+	}
+	if err := s.Accept("repo"); err == nil {
+		t.Error("Accept without changes got no error")
+	}
+	if got := s.Names(); len(got) != 1 || got[0] != "repo" {
+		t.Errorf("Names = %v, want [repo]", got)
+	}
+}
+
+func TestPinsCertificate(t *testing.T) {
+	defer func() { Pins = nil }()
+	var err error
+	if Pins, err = LoadPins(filepath.Join(t.TempDir(), "googet.pins")); err != nil {
+		t.Fatalf("LoadPins: %v", err)
+	}
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := u.Hostname()
+
+	d := HTTPDownloader{Repos: map[string]RepoTransport{ts.URL: {RootCAs: pool}}}
+	res, err := d.Get(context.Background(), ts.URL+"/index")
+	if err != nil {
+		t.Fatalf("Get on first use: %v", err)
+	}
+	res.Body.Close()
+	if want := Fingerprint(ts.Certificate().RawSubjectPublicKeyInfo); Pins.Get(host).Certificate != want {
+		t.Errorf("pinned certificate of %s = %q, want %q", host, Pins.Get(host).Certificate, want)
+	}
+
+	Pins.pins[host].Certificate = "0000"
+	if _, err := d.Get(context.Background(), ts.URL+"/index"); err == nil || !strings.Contains(err.Error(), "googet trust accept "+host) {
+		t.Errorf("Get with a changed certificate returned %v, want an error naming googet trust accept", err)
+	}
+}
+
+func TestIssuer(t *testing.T) {
+	leaf := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("leaf")}
+	ca := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("ca")}
+	root := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("root")}
+	for _, tt := range []struct {
+		desc string
+		cs   tls.ConnectionState
+		want *x509.Certificate
+	}{
+		{"self-signed", tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}, leaf},
+		{"sent chain", tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf, ca}}, ca},
+		{"verified chain", tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}, VerifiedChains: [][]*x509.Certificate{{leaf, ca, root}}}, ca},
+	} {
+		if got := issuer(tt.cs); got != tt.want {
+			t.Errorf("%s: issuer = %s, want %s", tt.desc, got.RawSubjectPublicKeyInfo, tt.want.RawSubjectPublicKeyInfo)
+		}
+	}
+}
+
+func TestVerifyIndex(t *testing.T) {
+	defer func() { Pins = nil }()
+	var err error
+	if Pins, err = LoadPins(filepath.Join(t.TempDir(), "googet.pins")); err != nil {
+		t.Fatalf("LoadPins: %v", err)
+	}
+	index := []byte("[]")
+	var mux atomic.Value
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(pub ed25519.PublicKey, priv ed25519.PrivateKey) {
+		t.Helper()
+		b, err := goolib.MarshalVerifyKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := http.NewServeMux()
+		m.HandleFunc("/repo/index", func(w http.ResponseWriter, r *http.Request) { w.Write(index) })
+		m.HandleFunc("/repo/index.pub", func(w http.ResponseWriter, r *http.Request) { w.Write(b) })
+		m.HandleFunc("/repo/index.sig", func(w http.ResponseWriter, r *http.Request) { w.Write(goolib.Sign(priv, index)) })
+		mux.Store(m)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { mux.Load().(*http.ServeMux).ServeHTTP(w, r) }))
+	defer ts.Close()
+	repo := ts.URL + "/repo"
+	fetch := func() error {
+		_, err := unmarshalRepoPackages(context.Background(), repo, t.TempDir(), 0, HTTPDownloader{})
+		return err
+	}
+
+	serve(pub, priv)
+	if err := fetch(); err != nil {
+		t.Fatalf("fetching a signed index on first use: %v", err)
+	}
+	if Pins.Get(repo).Key != Fingerprint(pub) {
+		t.Errorf("pinned key of %s = %q, want %q", repo, Pins.Get(repo).Key, Fingerprint(pub))
+	}
+
+	// A signature by another key is rejected.
+	_, other, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(pub, other)
+	if err := fetch(); err == nil || !strings.Contains(err.Error(), "does not verify") {
+		t.Errorf("fetching an index with a bad signature returned %v, want a verify error", err)
+	}
+
+	// A new key is rejected until accepted.
+	pub2, priv2, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve(pub2, priv2)
+	if err := fetch(); err == nil || !strings.Contains(err.Error(), "googet trust accept "+repo) {
+		t.Errorf("fetching an index with a new key returned %v, want an error naming googet trust accept", err)
+	}
+	if err := Pins.Accept(repo); err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	if err := fetch(); err != nil {
+		t.Errorf("fetching an index with the accepted key: %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/logger"
+)
+
+// ProxyAuth authenticates a connection to a proxy with a challenge and
+// response scheme, such as Negotiate or NTLM, which http.Transport can't do
+// as the exchange has to happen on a single connection.
+type ProxyAuth interface {
+	// Scheme is the scheme of the Proxy-Authorization header, such as
+	// Negotiate.
+	Scheme() string
+	// Step returns the token to send in reply to the challenge of the
+	// proxy, which is nil for the first token.
+	Step(challenge []byte) ([]byte, error)
+	// Close releases the resources of the authentication.
+	Close()
+}
+
+// NewProxyAuth, if set, starts the authentication to proxy with one of the
+// schemes it offered, returning nil if it supports none of them. HTTPS
+// requests through a proxy then use a tunnel set up by GooGet, which
+// authenticates with it when the proxy asks to. On Windows it authenticates
+// as the user running GooGet with Negotiate or NTLM.
+var NewProxyAuth func(proxy *url.URL, schemes []string) (ProxyAuth, error)
+
+// maxProxyAuthSteps limits the requests sent to authenticate one connection.
+const maxProxyAuthSteps = 4
+
+// proxyKey is the context key of the proxy of a request tunneled by
+// proxyTransport.
+type proxyKey struct{}
+
+// proxyTransport tunnels HTTPS requests through the proxy of the request,
+// authenticating with NewProxyAuth, and sends other requests through tr.
+// Plain HTTP requests the proxy refuses to forward without authentication
+// are sent again through a tunnel, as the authentication of Negotiate and
+// NTLM holds for a connection, which tr doesn't keep for the exchange.
+type proxyTransport struct {
+	tr    *http.Transport
+	proxy func(*http.Request) (*url.URL, error)
+}
+
+// newProxyTransport makes tr tunnel requests through the proxy returned by
+// proxy itself, dialing with d.
+func newProxyTransport(tr *http.Transport, d *net.Dialer, proxy func(*http.Request) (*url.URL, error)) http.RoundTripper {
+	tr.Proxy = func(req *http.Request) (*url.URL, error) {
+		if _, ok := req.Context().Value(proxyKey{}).(*url.URL); ok {
+			return nil, nil
+		}
+		return proxy(req)
+	}
+	// HTTPS proxies are trusted like the repos.
+	var roots *x509.CertPool
+	if tr.TLSClientConfig != nil {
+		roots = tr.TLSClientConfig.RootCAs
+	}
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if u, ok := ctx.Value(proxyKey{}).(*url.URL); ok {
+			return dialProxy(ctx, d, u, addr, roots)
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+	return proxyTransport{tr, proxy}
+}
+
+// tunnelable reports whether the tunnels through the proxy u are set up by
+// proxyTransport, which leaves SOCKS proxies to http.Transport.
+func tunnelable(u *url.URL) bool {
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+func (t proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, err := t.proxy(req)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || !tunnelable(u) {
+		return t.tr.RoundTrip(req)
+	}
+	tunneled := req.WithContext(context.WithValue(req.Context(), proxyKey{}, u))
+	if req.URL.Scheme == "https" {
+		return t.tr.RoundTrip(tunneled)
+	}
+	resp, err := t.tr.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusProxyAuthRequired || (req.Body != nil && req.Body != http.NoBody) {
+		return resp, err
+	}
+	schemes, _ := proxySchemes(resp, "")
+	if !offersChallenge(schemes) {
+		return resp, nil
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	logger.Infof("Proxy %s requires %v authentication for %s, tunneling the request", u.Host, schemes, req.URL.Host)
+	return t.tr.RoundTrip(tunneled)
+}
+
+// offersChallenge reports whether schemes has a scheme other than Basic,
+// which http.Transport sends itself.
+func offersChallenge(schemes []string) bool {
+	for _, s := range schemes {
+		if !strings.EqualFold(s, "Basic") {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyAddr returns the host and port of the proxy u.
+func proxyAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// dialProxyConn connects to the proxy u with d, verifying the certificate of
+// an HTTPS proxy with roots, the system roots if nil.
+func dialProxyConn(ctx context.Context, d *net.Dialer, u *url.URL, roots *x509.CertPool) (net.Conn, *bufio.Reader, error) {
+	conn, err := d.DialContext(ctx, "tcp", proxyAddr(u))
+	if err != nil {
+		return nil, nil, err
+	}
+	// The tunnel is set up within the deadline of the request.
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+	if u.Scheme == "https" {
+		hctx := ctx
+		if DefaultTimeouts.TLSHandshake > 0 {
+			var cancel context.CancelFunc
+			hctx, cancel = context.WithTimeout(ctx, DefaultTimeouts.TLSHandshake)
+			defer cancel()
+		}
+		tc := tls.Client(conn, &tls.Config{ServerName: u.Hostname(), RootCAs: roots})
+		if err := tc.HandshakeContext(hctx); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn = tc
+	}
+	return conn, bufio.NewReader(conn), nil
+}
+
+// proxySchemes returns the schemes offered by the Proxy-Authenticate headers
+// of resp and the challenge of the scheme named want, base64 decoded.
+func proxySchemes(resp *http.Response, want string) ([]string, []byte) {
+	var schemes []string
+	var challenge []byte
+	for _, h := range resp.Header.Values("Proxy-Authenticate") {
+		s := strings.SplitN(strings.TrimSpace(h), " ", 2)
+		schemes = append(schemes, s[0])
+		if strings.EqualFold(s[0], want) && len(s) == 2 {
+			if b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s[1])); err == nil {
+				challenge = b
+			}
+		}
+	}
+	return schemes, challenge
+}
+
+// dialProxy returns a connection to addr tunneled through the proxy u,
+// authenticating with NewProxyAuth if the proxy requires it.
+func dialProxy(ctx context.Context, d *net.Dialer, u *url.URL, addr string, roots *x509.CertPool) (_ net.Conn, err error) {
+	conn, br, err := dialProxyConn(ctx, d, u, roots)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			conn.Close()
+		}
+	}()
+	var auth ProxyAuth
+	defer func() {
+		if auth != nil {
+			auth.Close()
+		}
+	}()
+	var token []byte
+	for i := 0; ; i++ {
+		req := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: addr},
+			Host:   addr,
+			Header: make(http.Header),
+		}
+		if auth != nil {
+			req.Header.Set("Proxy-Authorization", auth.Scheme()+" "+base64.StdEncoding.EncodeToString(token))
+		} else if u.User != nil {
+			p, _ := u.User.Password()
+			req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.User.Username()+":"+p)))
+		}
+		if err := req.Write(conn); err != nil {
+			return nil, err
+		}
+		// Proxies that never answer fail like repos that don't.
+		if rh := DefaultTimeouts.ResponseHeader; rh > 0 {
+			dl := time.Now().Add(rh)
+			if cd, ok := ctx.Deadline(); ok && cd.Before(dl) {
+				dl = cd
+			}
+			conn.SetReadDeadline(dl)
+		}
+		resp, err := http.ReadResponse(br, req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			// The tunnel follows, the response has no body.
+			if br.Buffered() > 0 {
+				return nil, errors.New("proxy sent data before the tunnel was set up")
+			}
+			return conn, conn.SetDeadline(time.Time{})
+		}
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusProxyAuthRequired || NewProxyAuth == nil || i == maxProxyAuthSteps {
+			return nil, fmt.Errorf("proxy %s refused to connect to %s: %s", u.Host, addr, resp.Status)
+		}
+
+		if auth == nil {
+			schemes, _ := proxySchemes(resp, "")
+			if auth, err = NewProxyAuth(u, schemes); err != nil {
+				return nil, err
+			}
+			if auth == nil {
+				return nil, fmt.Errorf("proxy %s requires authentication with an unsupported scheme, offered %v", u.Host, schemes)
+			}
+			logger.Infof("Authenticating to proxy %s with %s", u.Host, auth.Scheme())
+			token, err = auth.Step(nil)
+		} else {
+			_, challenge := proxySchemes(resp, auth.Scheme())
+			if challenge == nil {
+				return nil, fmt.Errorf("proxy %s rejected the %s credentials: %s", u.Host, auth.Scheme(), resp.Status)
+			}
+			token, err = auth.Step(challenge)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error authenticating to proxy %s: %v", u.Host, err)
+		}
+		// Proxies closing the connection after asking for credentials
+		// are answered on a new one.
+		if resp.Close {
+			conn.Close()
+			if conn, br, err = dialProxyConn(ctx, d, u, roots); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// warnGCSProxy logs that requests to GCS, which the storage client sends
+// through the proxy of the environment with its own transport, are not
+// authenticated with NewProxyAuth.
+func warnGCSProxy() {
+	if NewProxyAuth == nil {
+		return
+	}
+	req := &http.Request{URL: &url.URL{Scheme: "https", Host: "storage.googleapis.com"}}
+	if u, err := http.ProxyFromEnvironment(req); err == nil && u != nil {
+		logger.Warningf("Requests to gs:// repos go through proxy %s without Negotiate or NTLM authentication", u.Host)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeAuth answers the challenge of fakeProxy.
+type fakeAuth struct{ closed bool }
+
+func (*fakeAuth) Scheme() string { return "Fake" }
+
+func (*fakeAuth) Step(challenge []byte) ([]byte, error) {
+	if challenge == nil {
+		return []byte("hello"), nil
+	}
+	return []byte("response:" + string(challenge)), nil
+}
+
+func (a *fakeAuth) Close() { a.closed = true }
+
+// fakeProxy tunnels CONNECT requests authenticated with the three step
+// exchange of fakeAuth on a single connection.
+func fakeProxy(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	b64 := base64.StdEncoding.EncodeToString
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				challenged := false
+				for {
+					req, err := http.ReadRequest(br)
+					if err != nil {
+						return
+					}
+					switch auth := req.Header.Get("Proxy-Authorization"); {
+					case auth == "Fake "+b64([]byte("hello")):
+						challenged = true
+						fmt.Fprintf(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Fake %s\r\nContent-Length: 0\r\n\r\n", b64([]byte("challenge")))
+					case challenged && auth == "Fake "+b64([]byte("response:challenge")):
+						target, err := net.Dial("tcp", req.Host)
+						if err != nil {
+							fmt.Fprint(conn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+							return
+						}
+						defer target.Close()
+						fmt.Fprint(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+						go io.Copy(target, br)
+						io.Copy(conn, target)
+						return
+					default:
+						fmt.Fprint(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Basic realm=\"proxy\"\r\nProxy-Authenticate: Fake\r\nContent-Length: 0\r\n\r\n")
+					}
+				}
+			}()
+		}
+	}()
+	return "http://" + l.Addr().String()
+}
+
+func TestProxyAuth(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "index")
+	}))
+	defer ts.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	proxy := fakeProxy(t)
+	defer func(f func(*url.URL, []string) (ProxyAuth, error)) { NewProxyAuth = f }(NewProxyAuth)
+
+	NewProxyAuth = nil
+	if _, err := get(context.Background(), ts.URL, RepoTransport{ProxyServer: proxy, RootCAs: pool}); err == nil {
+		t.Error("get through a proxy requiring authentication without NewProxyAuth got no error")
+	}
+
+	var schemes []string
+	NewProxyAuth = func(_ *url.URL, s []string) (ProxyAuth, error) {
+		schemes = s
+		return nil, nil
+	}
+	if _, err := get(context.Background(), ts.URL, RepoTransport{ProxyServer: proxy, RootCAs: pool}); err == nil || !strings.Contains(err.Error(), "unsupported scheme") {
+		t.Errorf("get through a proxy offering unsupported schemes = %v, want an unsupported scheme error", err)
+	}
+	if want := []string{"Basic", "Fake"}; strings.Join(schemes, ",") != strings.Join(want, ",") {
+		t.Errorf("NewProxyAuth got schemes %v, want %v", schemes, want)
+	}
+
+	var auths []*fakeAuth
+	NewProxyAuth = func(*url.URL, []string) (ProxyAuth, error) {
+		a := &fakeAuth{}
+		auths = append(auths, a)
+		return a, nil
+	}
+	res, err := get(context.Background(), ts.URL, RepoTransport{ProxyServer: proxy, RootCAs: pool})
+	if err != nil {
+		t.Fatalf("get through an authenticating proxy: %v", err)
+	}
+	b, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil || string(b) != "index" {
+		t.Errorf("get through an authenticating proxy read %q, %v, want index", b, err)
+	}
+	if len(auths) != 1 || !auths[0].closed {
+		t.Errorf("NewProxyAuth called %d times, want once and the ProxyAuth closed", len(auths))
+	}
+
+	// Plain HTTP requests refused by the proxy are tunneled too.
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "plain index")
+	}))
+	defer hs.Close()
+	if res, err = get(context.Background(), hs.URL, RepoTransport{ProxyServer: proxy}); err != nil {
+		t.Fatalf("get of a plain HTTP URL through an authenticating proxy: %v", err)
+	}
+	b, err = io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil || res.StatusCode != http.StatusOK || string(b) != "plain index" {
+		t.Errorf("get of a plain HTTP URL through an authenticating proxy = %s %q, %v, want 200 plain index", res.Status, b, err)
+	}
+	if len(auths) != 2 {
+		t.Errorf("NewProxyAuth called %d times, want twice", len(auths))
+	}
+}
+
+func TestDialProxyConnRoots(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn, _, err := dialProxyConn(context.Background(), &net.Dialer{}, u, nil); err == nil {
+		conn.Close()
+		t.Error("dialProxyConn to a proxy with an untrusted certificate got no error")
+	}
+	conn, _, err := dialProxyConn(context.Background(), &net.Dialer{}, u, pool)
+	if err != nil {
+		t.Fatalf("dialProxyConn with the roots of the proxy: %v", err)
+	}
+	conn.Close()
+}
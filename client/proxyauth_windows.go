@@ -0,0 +1,144 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	secur32                       = windows.NewLazySystemDLL("secur32.dll")
+	procAcquireCredentialsHandle  = secur32.NewProc("AcquireCredentialsHandleW")
+	procInitializeSecurityContext = secur32.NewProc("InitializeSecurityContextW")
+	procDeleteSecurityContext     = secur32.NewProc("DeleteSecurityContext")
+	procFreeCredentialsHandle     = secur32.NewProc("FreeCredentialsHandle")
+	procFreeContextBuffer         = secur32.NewProc("FreeContextBuffer")
+)
+
+const (
+	secpkgCredOutbound   = 2
+	securityNativeDrep   = 0x10
+	secbufferVersion     = 0
+	secbufferToken       = 2
+	iscReqConnection     = 0x800
+	iscReqAllocateMemory = 0x100
+	secIContinueNeeded   = 0x00090312
+)
+
+type secHandle struct {
+	lower, upper uintptr
+}
+
+type secBuffer struct {
+	size uint32
+	typ  uint32
+	buf  *byte
+}
+
+type secBufferDesc struct {
+	version uint32
+	count   uint32
+	buffers *secBuffer
+}
+
+func init() {
+	NewProxyAuth = newSSPIAuth
+}
+
+// sspiAuth authenticates to a proxy as the user running GooGet with SSPI.
+type sspiAuth struct {
+	scheme  string
+	target  *uint16
+	cred    secHandle
+	ctx     secHandle
+	started bool
+}
+
+// newSSPIAuth authenticates with Negotiate, which uses Kerberos where it
+// can, or else NTLM, as offered by the proxy.
+func newSSPIAuth(proxy *url.URL, schemes []string) (ProxyAuth, error) {
+	for _, want := range []string{"Negotiate", "NTLM"} {
+		for _, s := range schemes {
+			if strings.EqualFold(s, want) {
+				return acquireSSPIAuth(want, proxy)
+			}
+		}
+	}
+	return nil, nil
+}
+
+func acquireSSPIAuth(scheme string, proxy *url.URL) (*sspiAuth, error) {
+	pkg, err := syscall.UTF16PtrFromString(scheme)
+	if err != nil {
+		return nil, err
+	}
+	target, err := syscall.UTF16PtrFromString("HTTP/" + proxy.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	a := &sspiAuth{scheme: scheme, target: target}
+	var expiry int64
+	if r, _, _ := procAcquireCredentialsHandle.Call(0, uintptr(unsafe.Pointer(pkg)), secpkgCredOutbound, 0, 0, 0, 0, uintptr(unsafe.Pointer(&a.cred)), uintptr(unsafe.Pointer(&expiry))); r != 0 {
+		return nil, fmt.Errorf("AcquireCredentialsHandle: %v", syscall.Errno(r))
+	}
+	return a, nil
+}
+
+func (a *sspiAuth) Scheme() string { return a.scheme }
+
+func (a *sspiAuth) Step(challenge []byte) ([]byte, error) {
+	var in *secBufferDesc
+	var ctx *secHandle
+	if a.started {
+		if len(challenge) == 0 {
+			return nil, errors.New("no challenge from the proxy")
+		}
+		in = &secBufferDesc{version: secbufferVersion, count: 1, buffers: &secBuffer{size: uint32(len(challenge)), typ: secbufferToken, buf: &challenge[0]}}
+		ctx = &a.ctx
+	}
+	outBuf := secBuffer{typ: secbufferToken}
+	out := secBufferDesc{version: secbufferVersion, count: 1, buffers: &outBuf}
+	var attrs uint32
+	var expiry int64
+	r, _, _ := procInitializeSecurityContext.Call(
+		uintptr(unsafe.Pointer(&a.cred)), uintptr(unsafe.Pointer(ctx)), uintptr(unsafe.Pointer(a.target)),
+		iscReqConnection|iscReqAllocateMemory, 0, securityNativeDrep, uintptr(unsafe.Pointer(in)), 0,
+		uintptr(unsafe.Pointer(&a.ctx)), uintptr(unsafe.Pointer(&out)), uintptr(unsafe.Pointer(&attrs)), uintptr(unsafe.Pointer(&expiry)))
+	if r != 0 && r != secIContinueNeeded {
+		return nil, fmt.Errorf("InitializeSecurityContext: %v", syscall.Errno(r))
+	}
+	a.started = true
+	if outBuf.buf == nil {
+		return nil, nil
+	}
+	defer procFreeContextBuffer.Call(uintptr(unsafe.Pointer(outBuf.buf)))
+	return append([]byte(nil), unsafe.Slice(outBuf.buf, outBuf.size)...), nil
+}
+
+func (a *sspiAuth) Close() {
+	if a.started {
+		procDeleteSecurityContext.Call(uintptr(unsafe.Pointer(&a.ctx)))
+	}
+	procFreeCredentialsHandle.Call(uintptr(unsafe.Pointer(&a.cred)))
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/googet/v2/goolib"
+)
+
+// States of the index signature of a RepoStatus.
+const (
+	// SignatureValid is an index signed with the key the repo publishes,
+	// which is the pinned key if one is.
+	SignatureValid = "valid"
+	// SignatureInvalid is an index whose signature does not verify.
+	SignatureInvalid = "invalid"
+	// SignatureUnsigned is an index of a repo publishing no signing key.
+	SignatureUnsigned = "unsigned"
+	// SignatureUntrusted is an index not signed with the pinned key of
+	// the repo, which GooGet rejects.
+	SignatureUntrusted = "untrusted"
+	// SignatureUnchecked is an index whose signature could not be checked.
+	SignatureUnchecked = "unchecked"
+)
+
+// RepoStatus is the health of a repo as seen by CheckRepo.
+type RepoStatus struct {
+	URL string
+	// Reachable reports whether the index of the repo was read, Error
+	// is why not, or why its signature could not be checked.
+	Reachable bool
+	Error     string `json:",omitempty"`
+	// Index is the URL of the index read.
+	Index string `json:",omitempty"`
+	// LastModified is when the index last changed, if the repo says.
+	LastModified *time.Time `json:",omitempty"`
+	// Digest is the SHA-256 of the index, which identifies its version
+	// across the mirrors of a repo.
+	Digest   string `json:",omitempty"`
+	Packages int
+	// Signature is one of the Signature states, empty for repos whose
+	// signature GooGet does not check.
+	Signature string `json:",omitempty"`
+	// Latency is the time until the repo answered the index request.
+	Latency time.Duration
+}
+
+// CheckRepo fetches the index of the repo at repoURL, bypassing the cache,
+// and returns the status of the repo. The signature of the index is checked
+// against the pinned key of the repo without pinning it.
+func CheckRepo(ctx context.Context, repoURL string, downloader Downloader) RepoStatus {
+	s := RepoStatus{URL: strings.TrimPrefix(repoURL, "oauth-")}
+	var b []byte
+	var err error
+	if isGCSURL, bucket, object := goolib.SplitGCSUrl(s.URL); isGCSURL {
+		b, err = s.readGCS(ctx, bucket, object)
+	} else {
+		b, err = s.readHTTP(ctx, repoURL, downloader)
+	}
+	if err != nil {
+		s.Error = err.Error()
+		return s
+	}
+	s.Reachable = true
+	s.Digest = fmt.Sprintf("%x", sha256.Sum256(b))
+	m, err := parseIndex(b)
+	if err != nil {
+		s.Error = fmt.Sprintf("error parsing index: %v", err)
+		return s
+	}
+	s.Packages = len(m)
+	s.checkSignature(ctx, repoURL, b, downloader)
+	return s
+}
+
+// readHTTP reads the index of the repo at repoURL, the gzipped one if the
+// repo has it.
+func (s *RepoStatus) readHTTP(ctx context.Context, repoURL string, downloader Downloader) ([]byte, error) {
+	name, ct := "index.gz", "application/x-gzip"
+	start := time.Now()
+	res, err := downloader.Get(ctx, repoURL+"/"+name)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		name, ct = "index", "application/json"
+		start = time.Now()
+		if res, err = downloader.Get(ctx, repoURL+"/"+name); err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
+			return nil, fmt.Errorf("index GET request returned status: %q", res.Status)
+		}
+	}
+	s.Latency = time.Since(start)
+	s.Index = s.URL + "/" + name
+	if t, err := http.ParseTime(res.Header.Get("Last-Modified")); err == nil {
+		s.LastModified = &t
+	}
+	return readIndex(res.Body, ct)
+}
+
+// readGCS reads the index of the repo in object of bucket, the gzipped one
+// if the repo has it.
+func (s *RepoStatus) readGCS(ctx context.Context, bucket, object string) ([]byte, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(object) != 0 {
+		object += "/"
+	}
+	start := time.Now()
+	name, ct := object+"index.gz", "application/x-gzip"
+	r, err := client.Bucket(bucket).Object(name).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		name, ct = object+"index", "application/json"
+		r, err = client.Bucket(bucket).Object(name).NewReader(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.Latency = time.Since(start)
+	s.Index = fmt.Sprintf("gs://%s/%s", bucket, name)
+	if t := r.Attrs.LastModified; !t.IsZero() {
+		s.LastModified = &t
+	}
+	return readIndex(r, ct)
+}
+
+// checkSignature sets the Signature state of the index b.
+func (s *RepoStatus) checkSignature(ctx context.Context, repoURL string, b []byte, downloader Downloader) {
+	key, sig, err := indexSignature(ctx, repoURL, downloader)
+	var pinned string
+	if Pins != nil {
+		pinned = Pins.Get(s.URL).Key
+	}
+	switch {
+	case err != nil:
+		s.Signature = SignatureUnchecked
+		s.Error = err.Error()
+	case key == nil && pinned != "":
+		s.Signature = SignatureUntrusted
+		s.Error = "repo no longer publishes its pinned index signing key"
+	case key == nil:
+		s.Signature = SignatureUnsigned
+	case pinned != "" && pinned != Fingerprint(key):
+		s.Signature = SignatureUntrusted
+		s.Error = "index signing key differs from the pinned key"
+	case !goolib.VerifySignature(key, b, sig):
+		s.Signature = SignatureInvalid
+	default:
+		s.Signature = SignatureValid
+	}
+}
+
+// Healthy reports whether GooGet can install packages from the repo.
+func (s RepoStatus) Healthy() bool {
+	return s.Reachable && s.Error == "" && s.Signature != SignatureInvalid && s.Signature != SignatureUntrusted
+}
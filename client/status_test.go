@@ -0,0 +1,87 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestCheckRepo(t *testing.T) {
+	defer func() { Pins = nil }()
+	var err error
+	if Pins, err = LoadPins(filepath.Join(t.TempDir(), "googet.pins")); err != nil {
+		t.Fatalf("LoadPins: %v", err)
+	}
+	index := []byte(`[{"Source":"repo","PackageSpec":{"Name":"foo","Version":"1.0.0@1","Arch":"noarch"}}]`)
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := goolib.MarshalVerifyKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := goolib.Sign(priv, index)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repo/index", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		w.Write(index)
+	})
+	mux.HandleFunc("/repo/index.pub", func(w http.ResponseWriter, r *http.Request) { w.Write(b) })
+	mux.HandleFunc("/repo/index.sig", func(w http.ResponseWriter, r *http.Request) { w.Write(sig) })
+	mux.HandleFunc("/unsigned/index", func(w http.ResponseWriter, r *http.Request) { w.Write(index) })
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	ctx := context.Background()
+
+	s := CheckRepo(ctx, ts.URL+"/repo", HTTPDownloader{})
+	if !s.Healthy() || s.Index != ts.URL+"/repo/index" || s.Packages != 1 || s.Signature != SignatureValid || s.Digest == "" {
+		t.Errorf("CheckRepo of a signed repo = %+v, want a healthy repo with 1 package and a valid signature", s)
+	}
+	if s.LastModified == nil || !s.LastModified.Equal(modified) {
+		t.Errorf("CheckRepo LastModified = %v, want %v", s.LastModified, modified)
+	}
+	if Pins.Get(ts.URL+"/repo").Key != "" {
+		t.Error("CheckRepo pinned the signing key of the repo")
+	}
+
+	sig = goolib.Sign(priv, []byte("[]"))
+	if s := CheckRepo(ctx, ts.URL+"/repo", HTTPDownloader{}); s.Healthy() || s.Signature != SignatureInvalid {
+		t.Errorf("CheckRepo of a repo with a bad signature = %+v, want an invalid signature", s)
+	}
+	sig = goolib.Sign(priv, index)
+
+	if err := Pins.check(ts.URL+"/repo", PinKey, "other"); err != nil {
+		t.Fatal(err)
+	}
+	if s := CheckRepo(ctx, ts.URL+"/repo", HTTPDownloader{}); s.Healthy() || s.Signature != SignatureUntrusted {
+		t.Errorf("CheckRepo of a repo with another pinned key = %+v, want an untrusted signature", s)
+	}
+
+	if s := CheckRepo(ctx, ts.URL+"/unsigned", HTTPDownloader{}); !s.Healthy() || s.Signature != SignatureUnsigned {
+		t.Errorf("CheckRepo of an unsigned repo = %+v, want a healthy unsigned repo", s)
+	}
+	if s := CheckRepo(ctx, ts.URL+"/missing", HTTPDownloader{}); s.Reachable || s.Healthy() || s.Error == "" {
+		t.Errorf("CheckRepo of a missing repo = %+v, want an unreachable repo", s)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package daemon
+
+import (
+	"context"
+	"io"
+	"net"
+
+	"google.golang.org/grpc"
+)
+
+// Client calls the daemon.
+type Client struct {
+	cc *grpc.ClientConn
+}
+
+// Dial returns a Client of the daemon listening on the unix domain socket
+// socket.
+func Dial(socket string) (*Client, error) {
+	cc, err := grpc.Dial("passthrough:///googet",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(codecName)))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{cc: cc}, nil
+}
+
+// Close closes the connection to the daemon.
+func (c *Client) Close() error {
+	return c.cc.Close()
+}
+
+// ListInstalled returns the installed packages.
+func (c *Client) ListInstalled(ctx context.Context) ([]Package, error) {
+	res := new(ListInstalledResponse)
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/ListInstalled", &ListInstalledRequest{}, res); err != nil {
+		return nil, err
+	}
+	return res.Packages, nil
+}
+
+// GetStatus returns the status of the daemon.
+func (c *Client) GetStatus(ctx context.Context) (*Status, error) {
+	res := new(Status)
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/GetStatus", &StatusRequest{}, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// stream calls the streaming method at index i of the service with req,
+// passing the messages received to progress, if not nil, and returns the
+// last one.
+func (c *Client) stream(ctx context.Context, i int, req interface{}, progress func(*Progress)) (*Progress, error) {
+	sd := &serviceDesc.Streams[i]
+	s, err := c.cc.NewStream(ctx, sd, "/"+ServiceName+"/"+sd.StreamName)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := s.CloseSend(); err != nil {
+		return nil, err
+	}
+	for {
+		p := new(Progress)
+		if err := s.RecvMsg(p); err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress(p)
+		}
+		if p.Done {
+			return p, nil
+		}
+	}
+}
+
+// Install installs pkgs, named name[.arch[.version]], passing the progress
+// messages to progress if not nil.
+func (c *Client) Install(ctx context.Context, pkgs []string, progress func(*Progress)) error {
+	_, err := c.stream(ctx, 0, &InstallRequest{Packages: pkgs}, progress)
+	return err
+}
+
+// Update updates all installed packages, passing the progress messages to
+// progress if not nil, and returns the updated packages.
+func (c *Client) Update(ctx context.Context, progress func(*Progress)) ([]string, error) {
+	p, err := c.stream(ctx, 1, &UpdateRequest{}, progress)
+	if err != nil {
+		return nil, err
+	}
+	return p.Packages, nil
+}
+
+// Remove removes pkgs, named name[.arch], passing the progress messages to
+// progress if not nil.
+func (c *Client) Remove(ctx context.Context, pkgs []string, progress func(*Progress)) error {
+	_, err := c.stream(ctx, 2, &RemoveRequest{Packages: pkgs}, progress)
+	return err
+}
@@ -0,0 +1,338 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package daemon implements the JSON management API run by googet daemon on
+// a local socket, a client for it, and its REST API.
+//
+// The socket API uses gRPC as transport only: the service googet.v1.GooGet
+// exchanges the JSON encoding of the types of this package, with the content
+// subtype "json". It is not a protobuf service, there is no .proto, so
+// grpcurl and stubs generated by protoc can't call it. Programs in other
+// languages use the REST API, see Server.Handler, whose OpenAPI definition
+// describes the same types.
+//
+// Install, Update and Remove stream a Progress message for each event of the
+// operation, see the events package, and end with one with Done set.
+// Canceling the call cancels the operation.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/googet/v2/api"
+	"github.com/google/googet/v2/events"
+	"github.com/google/googet/v2/goolib"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName is the full name of the service, in gRPC method names.
+const ServiceName = "googet.v1.GooGet"
+
+// ListInstalledRequest is the request of ListInstalled.
+type ListInstalledRequest struct{}
+
+// ListInstalledResponse is the response of ListInstalled.
+type ListInstalledResponse struct {
+	Packages []Package
+}
+
+// Package is an installed package.
+type Package struct {
+	Name, Arch, Version string
+	Repo                string `json:",omitempty"`
+	RebootRequired      bool   `json:",omitempty"`
+}
+
+// InstallRequest is the request of Install, packages are named as on the
+// googet command line, name[.arch[.version]].
+type InstallRequest struct {
+	Packages []string
+}
+
+// UpdateRequest is the request of Update.
+type UpdateRequest struct{}
+
+// RemoveRequest is the request of Remove, packages are named as on the
+// googet command line, name[.arch].
+type RemoveRequest struct {
+	Packages []string
+}
+
+// Progress is streamed by Install, Update and Remove.
+type Progress struct {
+	Event *events.Event `json:",omitempty"`
+	// Done is set on the last message of a successful operation.
+	Done bool `json:",omitempty"`
+	// Packages are the packages updated by Update, with Done.
+	Packages []string `json:",omitempty"`
+}
+
+// StatusRequest is the request of GetStatus.
+type StatusRequest struct{}
+
+// Status describes the daemon.
+type Status struct {
+	Version string
+	Root    string
+	// Operation is the operation running, if any, since Started.
+	Operation string `json:",omitempty"`
+	Started   time.Time
+	Installed int
+}
+
+// Server implements the service with an api.Client, running one operation
+// at a time.
+type Server struct {
+	c       *api.Client
+	version string
+
+	opMu sync.Mutex
+
+	mu      sync.Mutex
+	op      string
+	started time.Time
+}
+
+// NewServer returns a Server operating with c, version is reported by
+// GetStatus.
+func NewServer(c *api.Client, version string) *Server {
+	return &Server{c: c, version: version}
+}
+
+// Register registers s on gs.
+func (s *Server) Register(gs *grpc.Server) {
+	gs.RegisterService(&serviceDesc, s)
+}
+
+// ListInstalled returns the installed packages.
+func (s *Server) ListInstalled(ctx context.Context, _ *ListInstalledRequest) (*ListInstalledResponse, error) {
+	state, err := s.c.Installed()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	res := &ListInstalledResponse{Packages: []Package{}}
+	for _, ps := range state {
+		res.Packages = append(res.Packages, Package{Name: ps.PackageSpec.Name, Arch: ps.PackageSpec.Arch, Version: ps.PackageSpec.Version, Repo: ps.SourceRepo, RebootRequired: ps.RebootRequired})
+	}
+	return res, nil
+}
+
+// GetStatus returns the status of the daemon.
+func (s *Server) GetStatus(ctx context.Context, _ *StatusRequest) (*Status, error) {
+	state, err := s.c.Installed()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &Status{Version: s.version, Root: s.c.Root, Operation: s.op, Started: s.started, Installed: len(state)}, nil
+}
+
+// run runs op, named name, after the running operation, passing its events
+// to send.
+func (s *Server) run(ctx context.Context, name string, send func(*Progress), op func(context.Context) (*Progress, error)) (*Progress, error) {
+	s.opMu.Lock()
+	defer s.opMu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.op, s.started = name, time.Now()
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.op, s.started = "", time.Time{}
+		s.mu.Unlock()
+	}()
+
+	// Operations are serialized, all events of the process are theirs.
+	stop := events.Watch(func(e events.Event) {
+		send(&Progress{Event: &e})
+	})
+	last, err := op(ctx)
+	stop()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	last.Done = true
+	return last, nil
+}
+
+// runStream runs op like run, streaming its progress to stream.
+func (s *Server) runStream(stream grpc.ServerStream, name string, op func(context.Context) (*Progress, error)) error {
+	last, err := s.run(stream.Context(), name, func(p *Progress) { stream.SendMsg(p) }, op)
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return status.FromContextError(err).Err()
+	}
+	if err != nil {
+		return status.Error(codes.Aborted, err.Error())
+	}
+	return stream.SendMsg(last)
+}
+
+func packageInfos(pkgs []string) ([]goolib.PackageInfo, error) {
+	if len(pkgs) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no packages")
+	}
+	var pis []goolib.PackageInfo
+	for _, p := range pkgs {
+		pis = append(pis, goolib.PkgNameSplit(p))
+	}
+	return pis, nil
+}
+
+// Install installs the packages of req.
+func (s *Server) Install(req *InstallRequest, stream grpc.ServerStream) error {
+	pis, err := packageInfos(req.Packages)
+	if err != nil {
+		return err
+	}
+	return s.runStream(stream, fmt.Sprintf("install %v", req.Packages), s.install(pis))
+}
+
+func (s *Server) install(pis []goolib.PackageInfo) func(context.Context) (*Progress, error) {
+	return func(ctx context.Context) (*Progress, error) {
+		return &Progress{}, s.c.Install(ctx, pis...)
+	}
+}
+
+// Update updates all installed packages.
+func (s *Server) Update(_ *UpdateRequest, stream grpc.ServerStream) error {
+	return s.runStream(stream, "update", s.update)
+}
+
+func (s *Server) update(ctx context.Context) (*Progress, error) {
+	ud, err := s.c.Update(ctx)
+	p := &Progress{}
+	for _, pi := range ud {
+		p.Packages = append(p.Packages, pi.String())
+	}
+	return p, err
+}
+
+// Remove removes the packages of req.
+func (s *Server) Remove(req *RemoveRequest, stream grpc.ServerStream) error {
+	pis, err := packageInfos(req.Packages)
+	if err != nil {
+		return err
+	}
+	return s.runStream(stream, fmt.Sprintf("remove %v", req.Packages), s.remove(pis))
+}
+
+func (s *Server) remove(pis []goolib.PackageInfo) func(context.Context) (*Progress, error) {
+	return func(ctx context.Context) (*Progress, error) {
+		return &Progress{}, s.c.Remove(ctx, pis...)
+	}
+}
+
+// service is the interface of Server, as grpc.ServiceDesc requires one.
+type service interface {
+	ListInstalled(context.Context, *ListInstalledRequest) (*ListInstalledResponse, error)
+	GetStatus(context.Context, *StatusRequest) (*Status, error)
+	Install(*InstallRequest, grpc.ServerStream) error
+	Update(*UpdateRequest, grpc.ServerStream) error
+	Remove(*RemoveRequest, grpc.ServerStream) error
+}
+
+// The handlers below are what protoc-gen-go-grpc would generate for the
+// service.
+
+func listInstalledHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(ListInstalledRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(service).ListInstalled(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/ListInstalled"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(service).ListInstalled(ctx, req.(*ListInstalledRequest))
+	})
+}
+
+func getStatusHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(StatusRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(service).GetStatus(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/GetStatus"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(service).GetStatus(ctx, req.(*StatusRequest))
+	})
+}
+
+func installHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(InstallRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(service).Install(req, stream)
+}
+
+func updateHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(UpdateRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(service).Update(req, stream)
+}
+
+func removeHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(RemoveRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(service).Remove(req, stream)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*service)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "ListInstalled", Handler: listInstalledHandler},
+		{MethodName: "GetStatus", Handler: getStatusHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Install", Handler: installHandler, ServerStreams: true},
+		{StreamName: "Update", Handler: updateHandler, ServerStreams: true},
+		{StreamName: "Remove", Handler: removeHandler, ServerStreams: true},
+	},
+}
+
+// codecName is the content subtype of the messages.
+const codecName = "json"
+
+// jsonCodec encodes messages as JSON.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)   { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(b []byte, v interface{}) error { return json.Unmarshal(b, v) }
+func (jsonCodec) Name() string                            { return codecName }
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package daemon
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/googet/v2/api"
+	"github.com/google/googet/v2/events"
+	"github.com/google/googet/v2/googettest"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/priority"
+	"github.com/google/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	logger.Init("test", true, false, ioutil.Discard)
+}
+
+func TestDaemon(t *testing.T) {
+	root, err := os.MkdirTemp("", "googetd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	var pkgs []*googettest.Package
+	for _, v := range []string{"1.0.0@1", "2.0.0@1"} {
+		p, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: v}, nil)
+		if err != nil {
+			t.Fatalf("error running GenGoo: %v", err)
+		}
+		pkgs = append(pkgs, p)
+	}
+	const repo = "https://repo.example.com/repo"
+	d := googettest.NewDownloader()
+	if err := d.AddRepo(repo, pkgs...); err != nil {
+		t.Fatal(err)
+	}
+	c := &api.Client{Root: root, Archs: []string{"noarch"}, Sources: map[string]priority.Value{repo: priority.Default}, Downloader: d}
+
+	// Unix domain socket paths are short, t.TempDir may be too long.
+	sock := filepath.Join(root, "d.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs := grpc.NewServer()
+	NewServer(c, "1.2.3").Register(gs)
+	go gs.Serve(l)
+	defer gs.Stop()
+
+	dc, err := Dial(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dc.Close()
+	ctx := context.Background()
+
+	var got []events.Event
+	if err := dc.Install(ctx, []string{"foo.noarch.1.0.0@1"}, func(p *Progress) {
+		if p.Event != nil {
+			got = append(got, *p.Event)
+		}
+	}); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if len(got) != 2 || got[0].State != events.Started || got[1].State != events.Completed || got[1].Package != "foo.noarch.1.0.0@1" {
+		t.Errorf("Install progress events %+v, want the started and completed events of foo", got)
+	}
+
+	ps, err := dc.ListInstalled(ctx)
+	if err != nil {
+		t.Fatalf("ListInstalled: %v", err)
+	}
+	if want := []Package{{Name: "foo", Arch: "noarch", Version: "1.0.0@1", Repo: repo}}; len(ps) != 1 || ps[0] != want[0] {
+		t.Errorf("ListInstalled = %+v, want %+v", ps, want)
+	}
+
+	ud, err := dc.Update(ctx, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if len(ud) != 1 || ud[0] != "foo.noarch.2.0.0@1" {
+		t.Errorf("Update = %v, want foo.noarch.2.0.0@1", ud)
+	}
+
+	st, err := dc.GetStatus(ctx)
+	if err != nil {
+		t.Fatalf("GetStatus: %v", err)
+	}
+	if st.Version != "1.2.3" || st.Root != root || st.Operation != "" || st.Installed != 1 {
+		t.Errorf("GetStatus = %+v", st)
+	}
+
+	if err := dc.Remove(ctx, []string{"foo"}, nil); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := dc.Remove(ctx, []string{"foo"}, nil); status.Code(err) != codes.Aborted {
+		t.Errorf("Remove of a package not installed = %v, want code %v", err, codes.Aborted)
+	}
+	if err := dc.Install(ctx, nil, nil); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Install without packages = %v, want code %v", err, codes.InvalidArgument)
+	}
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package daemon
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/google/googet/v2/events"
+	"github.com/google/logger"
+)
+
+// openAPI is the OpenAPI definition of the REST API.
+//
+//go:embed openapi.json
+var openAPI []byte
+
+// Repo is a repo of the REST API.
+type Repo struct {
+	Name     string
+	URL      string
+	Priority int
+	// File is the .repo file listing the repo.
+	File string
+}
+
+// ReposResponse is the response of GET /v1/repos.
+type ReposResponse struct {
+	Repos []Repo
+}
+
+// Update is a pending update of the REST API.
+type Update struct {
+	// Package is the name and arch of the package.
+	Package   string
+	Installed string
+	Available string
+}
+
+// UpdatesResponse is the response of GET /v1/updates.
+type UpdatesResponse struct {
+	Updates []Update
+}
+
+// OperationResponse is the response of the REST operations.
+type OperationResponse struct {
+	Events []events.Event
+	// Packages are the packages updated by POST /v1/update.
+	Packages []string `json:",omitempty"`
+	Error    string   `json:",omitempty"`
+}
+
+// ListenLocal listens on the TCP address addr, which must be a loopback
+// address.
+func ListenLocal(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if a, ok := l.Addr().(*net.TCPAddr); !ok || !a.IP.IsLoopback() {
+		l.Close()
+		return nil, fmt.Errorf("%s is not a loopback address", addr)
+	}
+	return l, nil
+}
+
+// localHost reports whether the host of a request is a loopback address,
+// so that web pages can't reach the API by rebinding their DNS name.
+func localHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// Handler returns the REST API of s. Queries are open to local callers,
+// operations need token as bearer token.
+func (s *Server) Handler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPI)
+	})
+	mux.HandleFunc("/v1/status", s.get(func(ctx context.Context) (interface{}, error) {
+		return s.GetStatus(ctx, &StatusRequest{})
+	}))
+	mux.HandleFunc("/v1/packages", s.get(func(ctx context.Context) (interface{}, error) {
+		return s.ListInstalled(ctx, &ListInstalledRequest{})
+	}))
+	mux.HandleFunc("/v1/repos", s.get(s.repos))
+	mux.HandleFunc("/v1/updates", s.get(s.updates))
+	mux.HandleFunc("/v1/install", s.post(token, func(w http.ResponseWriter, r *http.Request) {
+		var req InstallRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pis, err := packageInfos(req.Packages)
+		if err != nil {
+			http.Error(w, "no packages", http.StatusBadRequest)
+			return
+		}
+		s.operate(w, r, fmt.Sprintf("install %v", req.Packages), s.install(pis))
+	}))
+	mux.HandleFunc("/v1/update", s.post(token, func(w http.ResponseWriter, r *http.Request) {
+		s.operate(w, r, "update", s.update)
+	}))
+	mux.HandleFunc("/v1/remove", s.post(token, func(w http.ResponseWriter, r *http.Request) {
+		var req RemoveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pis, err := packageInfos(req.Packages)
+		if err != nil {
+			http.Error(w, "no packages", http.StatusBadRequest)
+			return
+		}
+		s.operate(w, r, fmt.Sprintf("remove %v", req.Packages), s.remove(pis))
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !localHost(r.Host) {
+			http.Error(w, "only local requests are allowed", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Errorf("GooGet daemon: error writing response: %v", err)
+	}
+}
+
+// get returns a handler of GET requests answered by f.
+func (s *Server) get(f func(context.Context) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		v, err := f(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+// post returns a handler of POST requests carrying token handled by f.
+func (s *Server) post(token string, f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+			return
+		}
+		f(w, r)
+	}
+}
+
+// operate runs op, canceled if the caller goes away, and answers with its
+// events.
+func (s *Server) operate(w http.ResponseWriter, r *http.Request, name string, op func(context.Context) (*Progress, error)) {
+	res := &OperationResponse{Events: []events.Event{}}
+	last, err := s.run(r.Context(), name, func(p *Progress) {
+		res.Events = append(res.Events, *p.Event)
+	}, op)
+	if err != nil {
+		res.Error = err.Error()
+		writeJSON(w, http.StatusInternalServerError, res)
+		return
+	}
+	res.Packages = last.Packages
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) repos(context.Context) (interface{}, error) {
+	rfs, err := s.c.Repos()
+	if err != nil {
+		return nil, err
+	}
+	res := &ReposResponse{Repos: []Repo{}}
+	for _, rf := range rfs {
+		for _, re := range rf.Entries {
+			res.Repos = append(res.Repos, Repo{Name: re.Name, URL: re.URL, Priority: int(re.Priority), File: rf.FileName})
+		}
+	}
+	return res, nil
+}
+
+func (s *Server) updates(ctx context.Context) (interface{}, error) {
+	state, err := s.c.Installed()
+	if err != nil {
+		return nil, err
+	}
+	ud, err := s.c.PendingUpdates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := &UpdatesResponse{Updates: []Update{}}
+	for _, pi := range ud {
+		u := Update{Package: pi.Name + "." + pi.Arch, Available: pi.Ver}
+		for _, ps := range state {
+			if ps.PackageSpec.Name == pi.Name && ps.PackageSpec.Arch == pi.Arch {
+				u.Installed = ps.PackageSpec.Version
+			}
+		}
+		res.Updates = append(res.Updates, u)
+	}
+	return res, nil
+}
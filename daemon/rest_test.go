@@ -0,0 +1,159 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/googet/v2/api"
+	"github.com/google/googet/v2/events"
+	"github.com/google/googet/v2/googettest"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/priority"
+)
+
+func TestHandler(t *testing.T) {
+	root := t.TempDir()
+	var pkgs []*googettest.Package
+	for _, v := range []string{"1.0.0@1", "2.0.0@1"} {
+		p, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: v}, nil)
+		if err != nil {
+			t.Fatalf("error running GenGoo: %v", err)
+		}
+		pkgs = append(pkgs, p)
+	}
+	const repo = "https://repo.example.com/repo"
+	d := googettest.NewDownloader()
+	if err := d.AddRepo(repo, pkgs...); err != nil {
+		t.Fatal(err)
+	}
+	c := &api.Client{Root: root, Archs: []string{"noarch"}, Sources: map[string]priority.Value{repo: priority.Default}, Downloader: d}
+	h := NewServer(c, "1.2.3").Handler("secret")
+
+	do := func(method, path, token, body string, res interface{}) int {
+		t.Helper()
+		r := httptest.NewRequest(method, "http://localhost:8642"+path, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if res != nil && w.Code < 400 {
+			if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+				t.Fatalf("%s %s: error decoding %q: %v", method, path, w.Body, err)
+			}
+		}
+		return w.Code
+	}
+
+	for _, tc := range []struct {
+		method, path, token, body string
+		want                      int
+	}{
+		{http.MethodPost, "/v1/install", "", `{"Packages": ["foo"]}`, http.StatusUnauthorized},
+		{http.MethodPost, "/v1/install", "wrong", `{"Packages": ["foo"]}`, http.StatusUnauthorized},
+		{http.MethodPost, "/v1/install", "secret", `{}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/install", "secret", `{`, http.StatusBadRequest},
+		{http.MethodGet, "/v1/install", "secret", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/v1/packages", "", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/openapi.json", "", "", http.StatusOK},
+	} {
+		if got := do(tc.method, tc.path, tc.token, tc.body, nil); got != tc.want {
+			t.Errorf("%s %s with token %q and body %q = %d, want %d", tc.method, tc.path, tc.token, tc.body, got, tc.want)
+		}
+	}
+
+	var op OperationResponse
+	if got := do(http.MethodPost, "/v1/install", "secret", `{"Packages": ["foo.noarch.1.0.0@1"]}`, &op); got != http.StatusOK {
+		t.Fatalf("POST /v1/install = %d, want %d", got, http.StatusOK)
+	}
+	if len(op.Events) != 2 || op.Events[1].State != events.Completed || op.Events[1].Package != "foo.noarch.1.0.0@1" {
+		t.Errorf("POST /v1/install events %+v, want the started and completed events of foo", op.Events)
+	}
+
+	var ps ListInstalledResponse
+	do(http.MethodGet, "/v1/packages", "", "", &ps)
+	if want := (Package{Name: "foo", Arch: "noarch", Version: "1.0.0@1", Repo: repo}); len(ps.Packages) != 1 || ps.Packages[0] != want {
+		t.Errorf("GET /v1/packages = %+v, want %+v", ps.Packages, want)
+	}
+
+	var ud UpdatesResponse
+	do(http.MethodGet, "/v1/updates", "", "", &ud)
+	if want := (Update{Package: "foo.noarch", Installed: "1.0.0@1", Available: "2.0.0@1"}); len(ud.Updates) != 1 || ud.Updates[0] != want {
+		t.Errorf("GET /v1/updates = %+v, want %+v", ud.Updates, want)
+	}
+
+	var rs ReposResponse
+	do(http.MethodGet, "/v1/repos", "", "", &rs)
+	if len(rs.Repos) != 0 {
+		t.Errorf("GET /v1/repos = %+v, want no repos", rs.Repos)
+	}
+
+	op = OperationResponse{}
+	do(http.MethodPost, "/v1/update", "secret", "", &op)
+	if len(op.Packages) != 1 || op.Packages[0] != "foo.noarch.2.0.0@1" {
+		t.Errorf("POST /v1/update packages = %v, want foo.noarch.2.0.0@1", op.Packages)
+	}
+
+	if got := do(http.MethodPost, "/v1/remove", "secret", `{"Packages": ["foo"]}`, nil); got != http.StatusOK {
+		t.Errorf("POST /v1/remove = %d, want %d", got, http.StatusOK)
+	}
+	if got := do(http.MethodPost, "/v1/remove", "secret", `{"Packages": ["foo"]}`, nil); got != http.StatusInternalServerError {
+		t.Errorf("POST /v1/remove of a package not installed = %d, want %d", got, http.StatusInternalServerError)
+	}
+
+	// Requests for other hosts, as from a rebound DNS name, are rejected.
+	r := httptest.NewRequest(http.MethodGet, "http://attacker.example.com/v1/packages", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("GET /v1/packages of attacker.example.com = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestListenLocal(t *testing.T) {
+	l, err := ListenLocal("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenLocal(127.0.0.1:0): %v", err)
+	}
+	l.Close()
+	if l, err := ListenLocal(":0"); err == nil {
+		l.Close()
+		t.Error("ListenLocal(:0) did not return an error")
+	}
+}
+
+func TestLocalHost(t *testing.T) {
+	for _, tc := range []struct {
+		host string
+		want bool
+	}{
+		{"localhost:8642", true},
+		{"LOCALHOST", true},
+		{"127.0.0.1:8642", true},
+		{"[::1]:8642", true},
+		{"[::1]", true},
+		{"10.0.0.1:8642", false},
+		{"example.com", false},
+		{"", false},
+	} {
+		if got := localHost(tc.host); got != tc.want {
+			t.Errorf("localHost(%q) = %v, want %v", tc.host, got, tc.want)
+		}
+	}
+}
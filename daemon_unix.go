@@ -0,0 +1,24 @@
+//go:build linux || darwin
+// +build linux darwin
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import "io/ioutil"
+
+// writePrivateFile writes b to the new file path, readable only by its owner.
+func writePrivateFile(path string, b []byte) error {
+	return ioutil.WriteFile(path, b, 0600)
+}
@@ -15,25 +15,26 @@ limitations under the License.
 package download
 
 import (
-	"archive/tar"
-	"compress/gzip"
 	"context"
-	"crypto/sha256"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"cloud.google.com/go/storage"
 	humanize "github.com/dustin/go-humanize"
 	"github.com/google/googet/v2/client"
 	"github.com/google/googet/v2/goolib"
 	"github.com/google/googet/v2/oswrap"
+	"github.com/google/googet/v2/report"
+	"github.com/google/googet/v2/telemetry"
 	"github.com/google/logger"
 )
 
@@ -41,9 +42,60 @@ const (
 	httpOK = 200
 )
 
+// MaxBandwidth caps the combined rate of package downloads in bytes per
+// second, 0 for no limit.
+var MaxBandwidth int64
+
+// limiter paces the reads of all downloads, next is the time the bytes
+// read so far may be read by at MaxBandwidth.
+var limiter struct {
+	sync.Mutex
+	next time.Time
+}
+
+// limitedReader reads from r no faster than MaxBandwidth allows.
+type limitedReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (l limitedReader) Read(p []byte) (int, error) {
+	rate := MaxBandwidth
+	if rate <= 0 {
+		return l.r.Read(p)
+	}
+	// Small reads keep the rate smooth for low limits.
+	if max := rate/4 + 1; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := l.r.Read(p)
+	if n == 0 {
+		return n, err
+	}
+	limiter.Lock()
+	now := time.Now()
+	if limiter.next.Before(now) {
+		limiter.next = now
+	}
+	limiter.next = limiter.next.Add(time.Duration(n) * time.Second / time.Duration(rate))
+	d := limiter.next.Sub(now)
+	limiter.Unlock()
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-l.ctx.Done():
+		return n, l.ctx.Err()
+	}
+	return n, err
+}
+
 // Package downloads a package from the given url,
 // the provided SHA256 checksum will be checked during download.
-func Package(ctx context.Context, pkgURL, dst, chksum, proxyServer string) error {
+func Package(ctx context.Context, pkgURL, dst, chksum string, downloader client.Downloader) (err error) {
+	ctx, span := telemetry.Start(ctx, "download", "googet.url", pkgURL)
+	defer func() { span.End(err) }()
 	if err := oswrap.RemoveAll(dst); err != nil {
 		return err
 	}
@@ -53,12 +105,12 @@ func Package(ctx context.Context, pkgURL, dst, chksum, proxyServer string) error
 		return packageGCS(ctx, bucket, object, dst, chksum, "")
 	}
 
-	return packageHTTP(ctx, pkgURL, dst, chksum, proxyServer)
+	return packageHTTP(ctx, pkgURL, dst, chksum, downloader)
 }
 
 // Downloads a package from an HTTP(s) server
-func packageHTTP(ctx context.Context, pkgURL, dst, chksum string, proxyServer string) error {
-	resp, err := client.Get(ctx, pkgURL, proxyServer)
+func packageHTTP(ctx context.Context, pkgURL, dst, chksum string, downloader client.Downloader) error {
+	resp, err := downloader.Get(ctx, pkgURL)
 	if err != nil {
 		return err
 	}
@@ -68,7 +120,9 @@ func packageHTTP(ctx context.Context, pkgURL, dst, chksum string, proxyServer st
 	}
 
 	logger.Infof("Downloading %q", pkgURL)
-	return download(resp.Body, dst, chksum)
+	transfers.begin(resp.ContentLength)
+	defer transfers.end()
+	return download(limitedReader{ctx, progressReader{resp.Body}}, dst, chksum)
 }
 
 // Downloads a package from Google Cloud Storage
@@ -90,11 +144,83 @@ func packageGCS(ctx context.Context, bucket, object string, dst, chksum string,
 	defer r.Close()
 
 	logger.Infof("Downloading gs://%s/%s", bucket, object)
-	return download(r, dst, chksum)
+	transfers.begin(r.Attrs.Size)
+	defer transfers.end()
+	return download(limitedReader{ctx, progressReader{r}}, dst, chksum)
 }
 
-// FromRepo downloads a package from a repo.
-func FromRepo(ctx context.Context, rs goolib.RepoSpec, repo, dir string, proxyServer string) (string, error) {
+// RepoSidecar returns the contents of the sidecar file with suffix of the
+// package rs in repo, from the source the index lists for it if any, else
+// from next to the package. It returns an error satisfying os.IsNotExist if
+// there is none.
+func RepoSidecar(ctx context.Context, rs goolib.RepoSpec, repo, suffix string, downloader client.Downloader) ([]byte, error) {
+	ctx = client.WithRepo(ctx, repo)
+	src := ""
+	switch suffix {
+	case goolib.SpecSidecarSuffix:
+		src = rs.SpecSource
+	case goolib.ManifestSuffix:
+		src = rs.ManifestSource
+	case goolib.SignatureSuffix:
+		src = rs.SignatureSource
+	}
+	if src == "" {
+		pkgURL, err := PackageURL(rs, repo)
+		if err != nil {
+			return nil, err
+		}
+		return Sidecar(ctx, pkgURL, suffix, downloader)
+	}
+	// Sources are relative to the repo like the package's.
+	rs.Source = src
+	u, err := PackageURL(rs, repo)
+	if err != nil {
+		return nil, err
+	}
+	return Sidecar(ctx, u, "", downloader)
+}
+
+// Sidecar returns the contents of the sidecar file with suffix next to the
+// package at pkgURL, which may also be a local path. It returns an error
+// satisfying os.IsNotExist if there is none.
+func Sidecar(ctx context.Context, pkgURL, suffix string, downloader client.Downloader) ([]byte, error) {
+	if isGCSURL, bucket, object := goolib.SplitGCSUrl(pkgURL); isGCSURL {
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer client.Close()
+		r, err := client.Bucket(bucket).Object(object + suffix).NewReader(ctx)
+		if err == storage.ErrObjectNotExist {
+			return nil, os.ErrNotExist
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	if u, err := url.Parse(pkgURL); err != nil || len(u.Scheme) < 2 {
+		// Not a URL, or a Windows path with a drive letter.
+		return ioutil.ReadFile(pkgURL + suffix)
+	}
+
+	resp, err := downloader.Get(ctx, pkgURL+suffix)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case httpOK:
+		return ioutil.ReadAll(resp.Body)
+	case 404:
+		return nil, os.ErrNotExist
+	}
+	return nil, fmt.Errorf("Invalid return code from server, got: %d, want: %d", resp.StatusCode, httpOK)
+}
+
+// PackageURL returns the URL of the package described by rs in repo.
+func PackageURL(rs goolib.RepoSpec, repo string) (string, error) {
 	repoURL, err := url.Parse(repo)
 	if err != nil {
 		return "", err
@@ -111,14 +237,102 @@ func FromRepo(ctx context.Context, rs goolib.RepoSpec, repo, dir string, proxySe
 	if err != nil {
 		return "", err
 	}
+	return pkgURL.String(), nil
+}
+
+// CachePath returns the path of the package with the SHA256 checksum in the
+// cache directory dir. Packages are stored by checksum, so identical packages
+// from different repos share an entry and different packages never collide.
+func CachePath(dir, checksum string) (string, error) {
+	_, digest, err := goolib.ParseChecksum(checksum)
+	if err != nil {
+		return "", fmt.Errorf("invalid package checksum %q", checksum)
+	}
+	return filepath.Join(dir, digest+".goo"), nil
+}
+
+// Cached returns the path of a cached copy of the package installed as ps.
+// That is its LocalPath if it still holds the package, otherwise the cache
+// entry of its checksum in the same directory, so packages are found after
+// the file they were installed from was replaced by an identical one or the
+// cache was moved to checksum names. Packages installed from disk have no
+// checksum and are only looked for at their LocalPath.
+func Cached(ps client.PackageState) (string, bool) {
+	paths := []string{ps.LocalPath}
+	if ps.Checksum != "" {
+		if p, err := CachePath(filepath.Dir(ps.LocalPath), ps.Checksum); err == nil && p != ps.LocalPath {
+			paths = append(paths, p)
+		}
+	}
+	for _, p := range paths {
+		f, err := oswrap.Open(p)
+		if err != nil {
+			continue
+		}
+		ok := ps.Checksum == "" || goolib.MatchChecksum(f, ps.Checksum)
+		f.Close()
+		if ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// NamePath returns the path of the file recording the name of the package
+// cached at dst.
+func NamePath(dst string) string {
+	return strings.TrimSuffix(dst, filepath.Ext(dst)) + ".name"
+}
 
-	pn := goolib.PackageInfo{Name: rs.PackageSpec.Name, Arch: rs.PackageSpec.Arch, Ver: rs.PackageSpec.Version}.PkgName()
-	dst := filepath.Join(dir, filepath.Base(pn))
-	return dst, Package(ctx, pkgURL.String(), dst, rs.Checksum, proxyServer)
+// WriteCacheName records the name of the package cached at dst next to it.
+func WriteCacheName(dst string, ps *goolib.PkgSpec) {
+	// The .name files aren't used by googet but help developers and the
+	// curious figure out which package a cache entry holds.
+	nf := NamePath(dst)
+	if err := ioutil.WriteFile(nf, []byte(ps.String()), 0644); err != nil {
+		logger.Errorf("Failed to write '%s': %v", nf, err)
+	}
+}
+
+// RemoveCached removes the package cached at dst and the file recording its
+// name.
+func RemoveCached(dst string) error {
+	if err := oswrap.RemoveAll(dst); err != nil {
+		return err
+	}
+	if err := oswrap.Remove(NamePath(dst)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// FromRepo downloads a package from a repo, unless the cache in dir already
+// holds it.
+func FromRepo(ctx context.Context, rs goolib.RepoSpec, repo, dir string, downloader client.Downloader) (string, error) {
+	ctx = client.WithRepo(ctx, repo)
+	pkgURL, err := PackageURL(rs, repo)
+	if err != nil {
+		return "", err
+	}
+
+	chksum := rs.BestChecksum()
+	dst, err := CachePath(dir, chksum)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := Cached(client.PackageState{LocalPath: dst, Checksum: chksum}); ok {
+		logger.Infof("Using cached package %q for %s", dst, rs.PackageSpec)
+		return dst, nil
+	}
+	if err := Package(ctx, pkgURL, dst, chksum, downloader); err != nil {
+		return "", err
+	}
+	WriteCacheName(dst, rs.PackageSpec)
+	return dst, nil
 }
 
 // Latest downloads the latest available version of a package.
-func Latest(ctx context.Context, name, dir string, rm client.RepoMap, archs []string, proxyServer string) (string, error) {
+func Latest(ctx context.Context, name, dir string, rm client.RepoMap, archs []string, downloader client.Downloader) (string, error) {
 	ver, repo, arch, err := client.FindRepoLatest(goolib.PackageInfo{Name: name, Arch: "", Ver: ""}, rm, archs)
 	if err != nil {
 		return "", err
@@ -127,11 +341,27 @@ func Latest(ctx context.Context, name, dir string, rm client.RepoMap, archs []st
 	if err != nil {
 		return "", err
 	}
-	return FromRepo(ctx, rs, repo, dir, proxyServer)
+	return FromRepo(ctx, rs, repo, dir, downloader)
 }
 
+// partialSuffix is appended to the name of a package while it is
+// downloaded, so a package only appears once its checksum matched.
+const partialSuffix = ".part"
+
+// download writes r to dst, hashing it as it is written instead of reading
+// it back. A download failing or not matching chksum is removed.
 func download(r io.Reader, dst, chksum string) (err error) {
-	f, err := oswrap.Create(dst)
+	algo, digest, err := goolib.ParseChecksum(chksum)
+	if err != nil {
+		return err
+	}
+	hash, err := goolib.NewHash(algo)
+	if err != nil {
+		return err
+	}
+
+	part := dst + partialSuffix
+	f, err := oswrap.Create(part)
 	if err != nil {
 		return err
 	}
@@ -139,19 +369,24 @@ func download(r io.Reader, dst, chksum string) (err error) {
 		if cErr := f.Close(); cErr != nil && err == nil {
 			err = cErr
 		}
+		if err == nil {
+			err = oswrap.Rename(part, dst)
+		}
+		if err != nil {
+			if rErr := oswrap.Remove(part); rErr != nil && !os.IsNotExist(rErr) {
+				logger.Error(rErr)
+			}
+		}
 	}()
 
-	hash := sha256.New()
-	tw := io.MultiWriter(f, hash)
-
-	b, err := io.Copy(tw, r)
+	b, err := io.Copy(f, io.TeeReader(r, hash))
+	report.Downloaded(b)
 	if err != nil {
 		return err
 	}
 
-	if hex.EncodeToString(hash.Sum(nil)) != chksum {
-		fmt.Println(hex.EncodeToString(hash.Sum(nil)), chksum)
-		return errors.New("checksum of downloaded file does not match expected checksum")
+	if got := hex.EncodeToString(hash.Sum(nil)); got != digest {
+		return &goolib.VerificationError{Err: fmt.Errorf("%s checksum of downloaded file %s does not match expected checksum %s", algo, got, digest)}
 	}
 
 	logger.Infof("Successfully downloaded %s", humanize.IBytes(uint64(b)))
@@ -176,49 +411,8 @@ func ExtractPkg(src string) (dst string, err error) {
 	}
 	defer f.Close()
 
-	gr, err := gzip.NewReader(f)
-	if err != nil {
-		if !os.IsExist(err) {
-			return "", err
-		}
-	}
-	tr := tar.NewReader(gr)
-
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return "", fmt.Errorf("error opening file: %v", err)
-		}
-
-		name := filepath.Clean(header.Name)
-		if name[0:3] == ".."+string(os.PathSeparator) {
-			return "", fmt.Errorf("error unpacking package, file contains path traversal: %q", name)
-		}
-
-		path := filepath.Join(dst, name)
-		if header.FileInfo().IsDir() {
-			if err := oswrap.MkdirAll(path, 0755); err != nil {
-				return "", err
-			}
-			continue
-		}
-		if err := oswrap.MkdirAll(filepath.Dir(path), 0755); err != nil {
-			return "", err
-		}
-		f, err := oswrap.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode))
-		if err != nil {
-			return "", err
-		}
-		if _, err := io.Copy(f, tr); err != nil {
-			f.Close()
-			return "", err
-		}
-		if err := f.Close(); err != nil {
-			return "", err
-		}
+	if err := goolib.ExtractPackage(f, dst); err != nil {
+		return "", err
 	}
 	return dst, nil
 }
@@ -17,11 +17,21 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"io"
 	"io/ioutil"
+	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/googettest"
 	"github.com/google/googet/v2/goolib"
 	"github.com/google/googet/v2/oswrap"
 	"github.com/google/logger"
@@ -50,6 +60,22 @@ func TestDownload(t *testing.T) {
 	if err := download(r, tempFile, "notachecksum"); err == nil {
 		t.Error("wanted but did not recieve checksum error")
 	}
+	if b, err := ioutil.ReadFile(tempFile); err != nil || string(b) != "some content" {
+		t.Errorf("download with an invalid checksum changed %s to %q, %v", tempFile, b, err)
+	}
+
+	sum := sha512.Sum512([]byte("some content"))
+	if err := download(bytes.NewReader([]byte("some content")), tempFile, goolib.FormatChecksum(goolib.SHA512, sum[:])); err != nil {
+		t.Errorf("error downloading and checking sha512 checksum: %v", err)
+	}
+	err = download(bytes.NewReader([]byte("other content")), tempFile, goolib.FormatChecksum(goolib.SHA512, sum[:]))
+	var ve *goolib.VerificationError
+	if !errors.As(err, &ve) {
+		t.Errorf("wanted a sha512 checksum verification error, got %v", err)
+	}
+	if _, err := os.Stat(tempFile + partialSuffix); !os.IsNotExist(err) {
+		t.Errorf("download with a checksum mismatch left %s%s behind: %v", tempFile, partialSuffix, err)
+	}
 }
 
 func TestExtractPkg(t *testing.T) {
@@ -144,3 +170,218 @@ func TestExtractPkgPathTraversal(t *testing.T) {
 		t.Fatal("error expected because of path traversal")
 	}
 }
+
+func TestRepoSidecar(t *testing.T) {
+	pkg, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}, map[string][]byte{"bar.txt": []byte("bar")})
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	repo := "https://example.com/repo"
+	rs := pkg.RepoSpec()
+	pkgURL, err := PackageURL(rs, repo)
+	if err != nil {
+		t.Fatalf("error running PackageURL: %v", err)
+	}
+	if want := "https://example.com/packages/foo.noarch.1.0.0@1.goo"; pkgURL != want {
+		t.Errorf("PackageURL returned %q, want %q", pkgURL, want)
+	}
+
+	d := googettest.NewDownloader()
+	d.Add(pkgURL+goolib.ManifestSuffix, []byte("next to the package"))
+	d.Add("https://example.com/meta/foo.manifest", []byte("listed in the index"))
+	b, err := RepoSidecar(context.Background(), rs, repo, goolib.ManifestSuffix, d)
+	if err != nil || string(b) != "next to the package" {
+		t.Errorf("RepoSidecar without ManifestSource = %q, %v, want %q", b, err, "next to the package")
+	}
+	rs.ManifestSource = "meta/foo.manifest"
+	b, err = RepoSidecar(context.Background(), rs, repo, goolib.ManifestSuffix, d)
+	if err != nil || string(b) != "listed in the index" {
+		t.Errorf("RepoSidecar with ManifestSource = %q, %v, want %q", b, err, "listed in the index")
+	}
+	if _, err := RepoSidecar(context.Background(), rs, repo, goolib.SpecSidecarSuffix, d); !os.IsNotExist(err) {
+		t.Errorf("RepoSidecar of a missing sidecar returned %v, want a not exist error", err)
+	}
+}
+
+func TestFromRepoCache(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+
+	pkg, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}, map[string][]byte{"bar.txt": []byte("bar")})
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	d := googettest.NewDownloader()
+	for _, repo := range []string{"https://a.example.com/repo", "https://b.example.com/repo"} {
+		if err := d.AddRepo(repo, pkg); err != nil {
+			t.Fatalf("error running AddRepo: %v", err)
+		}
+	}
+
+	rs := pkg.RepoSpec()
+	dst, err := FromRepo(context.Background(), rs, "https://a.example.com/repo", tempDir, d)
+	if err != nil {
+		t.Fatalf("error running FromRepo: %v", err)
+	}
+	if want := filepath.Join(tempDir, rs.Checksum+".goo"); dst != want {
+		t.Errorf("FromRepo returned %q, want %q", dst, want)
+	}
+	name, err := ioutil.ReadFile(filepath.Join(tempDir, rs.Checksum+".name"))
+	if err != nil || string(name) != "foo.noarch.1.0.0@1" {
+		t.Errorf("cache name file = %q, %v, want foo.noarch.1.0.0@1", name, err)
+	}
+
+	// The same package from another repo is taken from the cache.
+	n := len(d.Requests())
+	if dst2, err := FromRepo(context.Background(), rs, "https://b.example.com/repo", tempDir, d); err != nil || dst2 != dst {
+		t.Errorf("FromRepo from the second repo = %q, %v, want %q", dst2, err, dst)
+	}
+	if len(d.Requests()) != n {
+		t.Errorf("FromRepo downloaded a cached package: %v", d.Requests()[n:])
+	}
+
+	// A corrupt cache entry is replaced.
+	if err := ioutil.WriteFile(dst, []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromRepo(context.Background(), rs, "https://b.example.com/repo", tempDir, d); err != nil {
+		t.Fatalf("error running FromRepo: %v", err)
+	}
+	if b, err := ioutil.ReadFile(dst); err != nil || !bytes.Equal(b, pkg.Data) {
+		t.Error("FromRepo did not replace the corrupt cache entry")
+	}
+
+	// The strongest checksum published by the repo is verified.
+	sum := sha512.Sum512(pkg.Data)
+	rs.Checksums = []string{goolib.FormatChecksum(goolib.SHA512, sum[:])}
+	if dst, err := FromRepo(context.Background(), rs, "https://a.example.com/repo", tempDir, d); err != nil || dst != filepath.Join(tempDir, hex.EncodeToString(sum[:])+".goo") {
+		t.Errorf("FromRepo with a sha512 checksum = %q, %v, want the package cached by its sha512 digest", dst, err)
+	}
+	rs.Checksums = nil
+
+	rs.Checksum = "../../etc/passwd"
+	if _, err := FromRepo(context.Background(), rs, "https://a.example.com/repo", tempDir, d); err == nil {
+		t.Error("FromRepo accepted an invalid checksum")
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	defer func(b int64) { MaxBandwidth = b }(MaxBandwidth)
+	MaxBandwidth = 1000
+
+	start := time.Now()
+	b, err := ioutil.ReadAll(limitedReader{context.Background(), bytes.NewReader(make([]byte, 500))})
+	if err != nil || len(b) != 500 {
+		t.Fatalf("ReadAll = %d bytes, %v, want 500 bytes", len(b), err)
+	}
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("reading 500 bytes at 1000 bytes/s took %v, want at least 400ms", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ioutil.ReadAll(limitedReader{ctx, bytes.NewReader(make([]byte, 5000))}); err != context.Canceled {
+		t.Errorf("ReadAll with a canceled context = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestProgressStatus(t *testing.T) {
+	now := time.Now()
+	for _, tt := range []struct {
+		p    *progress
+		want string
+	}{
+		{&progress{count: 2, done: 50 << 20, total: 100 << 20, start: now.Add(-10 * time.Second)}, "Downloading 2 packages: 50 MiB of 100 MiB (50%), 5.0 MiB/s, ETA 10s"},
+		{&progress{count: 1, done: 1 << 10, total: 1 << 10, start: now.Add(-time.Second)}, "Downloading 1 package: 1.0 KiB of 1.0 KiB (100%), 1.0 KiB/s"},
+		{&progress{count: 2, done: 1 << 10, total: 1 << 20, unknown: true, start: now.Add(-time.Second)}, "Downloading 2 packages: 1.0 KiB, 1.0 KiB/s"},
+	} {
+		if got := tt.p.status(now); got != tt.want {
+			t.Errorf("status() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestProgressTerminal(t *testing.T) {
+	defer func(w io.Writer) { progressOut = w }(progressOut)
+	var buf bytes.Buffer
+	progressOut = &buf
+
+	transfers.begin(100)
+	transfers.begin(-1)
+	transfers.add(60)
+	transfers.end()
+	time.Sleep(2 * redrawInterval)
+	transfers.add(40)
+	transfers.end()
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "\rDownloading 2 packages: 60 B, ") || !strings.HasSuffix(out, "\n") {
+		t.Errorf("progress output %q, want the progress of both downloads ending with a newline", out)
+	}
+	if !strings.Contains(out, "\rDownloading 2 packages: 100 B, ") {
+		t.Errorf("progress output %q does not end with the final state", out)
+	}
+}
+
+func TestCached(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte("package")
+	chksum := goolib.Checksum(bytes.NewReader(data))
+	byName := filepath.Join(dir, "foo.noarch.1.0.0@1.goo")
+	byChecksum := filepath.Join(dir, chksum+".goo")
+
+	if p, ok := Cached(client.PackageState{LocalPath: byName, Checksum: chksum}); ok {
+		t.Errorf("Cached of a package not cached = %q", p)
+	}
+	if err := ioutil.WriteFile(byChecksum, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A package installed from a file named after it is found by checksum.
+	if p, ok := Cached(client.PackageState{LocalPath: byName, Checksum: chksum}); !ok || p != byChecksum {
+		t.Errorf("Cached by checksum = %q, %v, want %q", p, ok, byChecksum)
+	}
+	if err := ioutil.WriteFile(byName, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := Cached(client.PackageState{LocalPath: byName, Checksum: chksum}); !ok || p != byName {
+		t.Errorf("Cached by LocalPath = %q, %v, want %q", p, ok, byName)
+	}
+	// A LocalPath not matching the checksum falls back to the checksum.
+	if err := ioutil.WriteFile(byName, []byte("corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := Cached(client.PackageState{LocalPath: byName, Checksum: chksum}); !ok || p != byChecksum {
+		t.Errorf("Cached with a corrupt LocalPath = %q, %v, want %q", p, ok, byChecksum)
+	}
+	// Packages installed from disk without a checksum are taken as is.
+	if p, ok := Cached(client.PackageState{LocalPath: byName}); !ok || p != byName {
+		t.Errorf("Cached without a checksum = %q, %v, want %q", p, ok, byName)
+	}
+}
+
+func TestRemoveCached(t *testing.T) {
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "abc.goo")
+	if err := ioutil.WriteFile(dst, []byte("package"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	WriteCacheName(dst, &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"})
+	if err := RemoveCached(dst); err != nil {
+		t.Fatalf("RemoveCached: %v", err)
+	}
+	for _, p := range []string{dst, NamePath(dst)} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s not removed, Stat: %v", p, err)
+		}
+	}
+	// Entries without a name file are removed as well.
+	if err := ioutil.WriteFile(dst, []byte("package"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveCached(dst); err != nil {
+		t.Errorf("RemoveCached without a name file: %v", err)
+	}
+}
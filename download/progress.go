@@ -0,0 +1,185 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package download
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/google/logger"
+)
+
+// ProgressInterval is how often the progress of the downloads in flight is
+// logged when stdout is not a terminal.
+var ProgressInterval = 10 * time.Second
+
+// redrawInterval is how often the progress line is redrawn on a terminal.
+const redrawInterval = 250 * time.Millisecond
+
+// progressOut is the terminal the progress line is drawn on, nil if stdout
+// is not a terminal.
+var progressOut io.Writer = terminal(os.Stdout)
+
+func terminal(f *os.File) io.Writer {
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return f
+}
+
+// progress aggregates the transfers of the downloads in flight, which are
+// reported together until the last one ends.
+type progress struct {
+	mu sync.Mutex
+	// active is the number of downloads in flight, count the number started
+	// since none were.
+	active, count int
+	done, total   int64
+	// unknown is set if the size of any download is unknown.
+	unknown bool
+	start   time.Time
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+var transfers progress
+
+// begin adds a download of size bytes, -1 if unknown.
+func (p *progress) begin(size int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.active == 0 {
+		p.count, p.done, p.total, p.unknown = 0, 0, 0, false
+		p.start, p.stop, p.stopped = time.Now(), make(chan struct{}), make(chan struct{})
+		go p.report(p.stop, p.stopped)
+	}
+	p.active++
+	p.count++
+	if size < 0 {
+		p.unknown = true
+	} else {
+		p.total += size
+	}
+}
+
+// add records that n bytes were transferred.
+func (p *progress) add(n int) {
+	p.mu.Lock()
+	p.done += int64(n)
+	p.mu.Unlock()
+}
+
+// end removes a download, the transfer statistics are logged once the last
+// one ends.
+func (p *progress) end() {
+	p.mu.Lock()
+	p.active--
+	if p.active > 0 {
+		p.mu.Unlock()
+		return
+	}
+	stop, stopped := p.stop, p.stopped
+	count, done, elapsed := p.count, p.done, time.Since(p.start)
+	p.mu.Unlock()
+
+	close(stop)
+	<-stopped
+	noun := "packages"
+	if count == 1 {
+		noun = "package"
+	}
+	logger.Infof("Downloaded %d %s, %s in %s at %s/s", count, noun, humanize.IBytes(uint64(done)), elapsed.Round(time.Millisecond), humanize.IBytes(uint64(rate(done, elapsed))))
+}
+
+// report draws the progress line on the terminal, or logs it every
+// ProgressInterval, until stop is closed.
+func (p *progress) report(stop, stopped chan struct{}) {
+	defer close(stopped)
+	out, interval := progressOut, ProgressInterval
+	if out != nil {
+		interval = redrawInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	width := 0
+	for {
+		select {
+		case <-t.C:
+		case <-stop:
+			if width > 0 {
+				// Leave the final state of the line.
+				fmt.Fprintf(out, "\r%-*s\n", width, p.status(time.Now()))
+			}
+			return
+		}
+		s := p.status(time.Now())
+		if out == nil {
+			logger.Info(s)
+			continue
+		}
+		// Pad to erase the end of a longer previous line.
+		fmt.Fprintf(out, "\r%-*s", width, s)
+		if len(s) > width {
+			width = len(s)
+		}
+	}
+}
+
+// status describes the transfers at now.
+func (p *progress) status(now time.Time) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	noun := "packages"
+	if p.count == 1 {
+		noun = "package"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Downloading %d %s: %s", p.count, noun, humanize.IBytes(uint64(p.done)))
+	r := rate(p.done, now.Sub(p.start))
+	known := !p.unknown && p.total > 0
+	if known {
+		fmt.Fprintf(&b, " of %s (%d%%)", humanize.IBytes(uint64(p.total)), p.done*100/p.total)
+	}
+	fmt.Fprintf(&b, ", %s/s", humanize.IBytes(uint64(r)))
+	if known && r > 0 && p.done < p.total {
+		eta := time.Duration(float64(p.total-p.done) / r * float64(time.Second))
+		fmt.Fprintf(&b, ", ETA %s", eta.Round(time.Second))
+	}
+	return b.String()
+}
+
+// rate returns the rate in bytes per second of n bytes in d.
+func rate(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+// progressReader records the bytes read from r in transfers.
+type progressReader struct {
+	r io.Reader
+}
+
+func (p progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	transfers.add(n)
+	return n, err
+}
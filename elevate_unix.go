@@ -0,0 +1,36 @@
+//go:build linux || darwin
+// +build linux darwin
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// exitNotElevated is the exit status of commands that need root run without
+// it, EX_NOPERM.
+const exitNotElevated = 77
+
+// isElevated reports whether GooGet runs as root.
+func isElevated() bool {
+	return os.Geteuid() == 0
+}
+
+// runElevated is only supported on Windows, use sudo elsewhere.
+func runElevated(args []string) (int, error) {
+	return 0, errors.New("-elevate is only supported on Windows, run googet with sudo")
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// exitNotElevated is the exit status of commands that need admin rights run
+// without them, ERROR_ELEVATION_REQUIRED.
+const exitNotElevated = 740
+
+var procShellExecuteExW = windows.NewLazySystemDLL("shell32.dll").NewProc("ShellExecuteExW")
+
+const (
+	seeMaskNoCloseProcess = 0x40
+	swShowNormal          = 1
+)
+
+// shellExecuteInfo is the SHELLEXECUTEINFOW structure.
+type shellExecuteInfo struct {
+	size       uint32
+	mask       uint32
+	hwnd       windows.Handle
+	verb       *uint16
+	file       *uint16
+	parameters *uint16
+	directory  *uint16
+	show       int32
+	instApp    windows.Handle
+	idList     uintptr
+	class      *uint16
+	keyClass   windows.Handle
+	hotKey     uint32
+	icon       windows.Handle
+	process    windows.Handle
+}
+
+// isElevated reports whether GooGet runs with admin rights.
+func isElevated() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
+
+// runElevated runs GooGet again with args after a UAC prompt, waits for it
+// and returns its exit code.
+func runElevated(args []string) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return 0, err
+	}
+	info := shellExecuteInfo{
+		mask:       seeMaskNoCloseProcess,
+		verb:       windows.StringToUTF16Ptr("runas"),
+		file:       windows.StringToUTF16Ptr(exe),
+		parameters: windows.StringToUTF16Ptr(windows.ComposeCommandLine(args)),
+		directory:  windows.StringToUTF16Ptr(wd),
+		show:       swShowNormal,
+	}
+	info.size = uint32(unsafe.Sizeof(info))
+	if r, _, err := procShellExecuteExW.Call(uintptr(unsafe.Pointer(&info))); r == 0 {
+		if err == windows.ERROR_CANCELLED {
+			return 0, errors.New("elevation was declined")
+		}
+		return 0, err
+	}
+	defer windows.CloseHandle(info.process)
+	if _, err := windows.WaitForSingleObject(info.process, windows.INFINITE); err != nil {
+		return 0, err
+	}
+	var code uint32
+	if err := windows.GetExitCodeProcess(info.process, &code); err != nil {
+		return 0, err
+	}
+	return int(code), nil
+}
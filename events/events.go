@@ -0,0 +1,207 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package events publishes GooGet transaction events to other agents on the
+// machine.
+//
+// Each subscriber listens on a unix domain socket, supported on Windows 10
+// and later as well, in the events directory under the GooGet root. Every
+// event is sent to every socket in the directory as a single line of JSON on
+// its own connection.
+package events
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/jsonlog"
+	"github.com/google/googet/v2/report"
+	"github.com/google/logger"
+)
+
+// Actions of a transaction.
+const (
+	Install   = "install"
+	Reinstall = "reinstall"
+	Remove    = "remove"
+	// Scan is the run of the scanner on a package before it is installed.
+	Scan = "scan"
+)
+
+// States of a transaction.
+const (
+	Started   = "started"
+	Completed = "completed"
+	Failed    = "failed"
+)
+
+// socketSuffix is the suffix of subscriber sockets in Dir.
+const socketSuffix = ".sock"
+
+// Dir is the directory holding the subscriber sockets, events are not
+// published if it is empty.
+var Dir string
+
+// timeout limits the time spent sending an event to a single subscriber.
+var timeout = time.Second
+
+// Event describes a state change of a transaction on a single package.
+type Event struct {
+	Time    time.Time
+	Action  string
+	State   string
+	Package string
+	// Repo is the repo the package is installed from, if any.
+	Repo  string `json:",omitempty"`
+	Error string `json:",omitempty"`
+}
+
+var (
+	watchMu  sync.Mutex
+	watchers = make(map[int]func(Event))
+	nextID   int
+)
+
+// Watch calls f with every event emitted by this process until the returned
+// function is called.
+func Watch(f func(Event)) func() {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	id := nextID
+	nextID++
+	watchers[id] = f
+	return func() {
+		watchMu.Lock()
+		defer watchMu.Unlock()
+		delete(watchers, id)
+	}
+}
+
+// Emit sends e to all watchers and subscribers. Subscribers that are not
+// listening or are too slow are skipped, publishing never fails a
+// transaction.
+func Emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	watchMu.Lock()
+	for _, f := range watchers {
+		f(e)
+	}
+	watchMu.Unlock()
+
+	if Dir == "" {
+		return
+	}
+	socks, err := filepath.Glob(filepath.Join(Dir, "*"+socketSuffix))
+	if err != nil || len(socks) == 0 {
+		return
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		logger.Errorf("Error encoding event: %v", err)
+		return
+	}
+	b = append(b, '\n')
+	for _, s := range socks {
+		if err := send(s, b); err != nil {
+			logger.Infof("Error sending event to %q: %v", s, err)
+		}
+	}
+}
+
+func send(sock string, b []byte) error {
+	c, err := net.DialTimeout("unix", sock, timeout)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	_, err = c.Write(b)
+	return err
+}
+
+// Begin emits the Started event of action on pi from repo, empty for local
+// packages, and returns a function that emits the Completed or Failed event
+// depending on the error passed to it. The logs written meanwhile are
+// labeled with the package in JSON mode, see jsonlog, and the transaction is
+// recorded in the report, see report.
+func Begin(action string, pi goolib.PackageInfo, repo string) func(error) {
+	pkg := pi.Name + "." + pi.Arch + "." + pi.Ver
+	Emit(Event{Action: action, State: Started, Package: pkg, Repo: repo})
+	end := jsonlog.Begin(action, pi.Name+"."+pi.Arch, pi.Ver, repo)
+	endReport := report.Begin(action, pi.Name+"."+pi.Arch, pi.Ver, repo)
+	return func(err error) {
+		end(err)
+		endReport(err)
+		e := Event{Action: action, State: Completed, Package: pkg, Repo: repo}
+		if err != nil {
+			e.State = Failed
+			e.Error = err.Error()
+		}
+		Emit(e)
+	}
+}
+
+// Subscriber receives the events published by GooGet.
+type Subscriber struct {
+	l net.Listener
+}
+
+// Subscribe starts listening for events on a socket named name in dir,
+// normally the events directory under the GooGet root. A stale socket left
+// by a previous subscriber of the same name is replaced.
+func Subscribe(dir, name string) (*Subscriber, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, name+socketSuffix)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &Subscriber{l: l}, nil
+}
+
+// Next blocks until the next event is received.
+func (s *Subscriber) Next() (Event, error) {
+	for {
+		c, err := s.l.Accept()
+		if err != nil {
+			return Event{}, err
+		}
+		var e Event
+		c.SetReadDeadline(time.Now().Add(timeout))
+		err = json.NewDecoder(c).Decode(&e)
+		c.Close()
+		// Ignore connections that do not carry an event.
+		if err == nil {
+			return e, nil
+		}
+	}
+}
+
+// Close stops listening and removes the socket.
+func (s *Subscriber) Close() error {
+	return s.l.Close()
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/logger"
+)
+
+func init() {
+	logger.Init("test", true, false, ioutil.Discard)
+}
+
+func TestSubscribe(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	defer func(d string) { Dir = d }(Dir)
+	Dir = filepath.Join(tempDir, "events")
+
+	// Publishing without subscribers is a no-op.
+	Emit(Event{Action: Install, State: Started, Package: "foo"})
+
+	s, err := Subscribe(Dir, "test")
+	if err != nil {
+		t.Fatalf("error subscribing: %v", err)
+	}
+	defer s.Close()
+	// A stale socket of a subscriber that went away is skipped.
+	if err := ioutil.WriteFile(filepath.Join(Dir, "stale.sock"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan Event)
+	go func() {
+		for {
+			e, err := s.Next()
+			if err != nil {
+				close(got)
+				return
+			}
+			got <- e
+		}
+	}()
+
+	go func() {
+		done := Begin(Remove, goolib.PackageInfo{Name: "foo", Arch: "noarch", Ver: "1.0.0@1"}, "")
+		done(errors.New("boom"))
+	}()
+	for _, want := range []Event{
+		{Action: Remove, State: Started, Package: "foo.noarch.1.0.0@1"},
+		{Action: Remove, State: Failed, Package: "foo.noarch.1.0.0@1", Error: "boom"},
+	} {
+		e := <-got
+		if e.Time.IsZero() {
+			t.Errorf("event %+v has no time", e)
+		}
+		e.Time = want.Time
+		if e != want {
+			t.Errorf("got event %+v, want %+v", e, want)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	var got []Event
+	stop := Watch(func(e Event) { got = append(got, e) })
+	Begin(Install, goolib.PackageInfo{Name: "foo", Arch: "noarch", Ver: "1.0.0@1"}, "repo")(nil)
+	stop()
+	Emit(Event{Action: Install, State: Started, Package: "bar"})
+
+	if len(got) != 2 || got[0].State != Started || got[1].State != Completed || got[1].Package != "foo.noarch.1.0.0@1" || got[1].Time.IsZero() {
+		t.Errorf("watched events %+v, want the started and completed events of foo", got)
+	}
+}
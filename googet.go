@@ -16,50 +16,77 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/x509"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"net/url"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	humanize "github.com/dustin/go-humanize"
 	"github.com/go-yaml/yaml"
+	"github.com/google/googet/v2/api"
 	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/download"
+	"github.com/google/googet/v2/events"
 	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/hooks"
+	"github.com/google/googet/v2/install"
+	"github.com/google/googet/v2/jsonlog"
 	"github.com/google/googet/v2/priority"
+	"github.com/google/googet/v2/remove"
 	"github.com/google/googet/v2/system"
+	"github.com/google/googet/v2/telemetry"
 	"github.com/google/logger"
 	"github.com/google/subcommands"
 	"github.com/olekukonko/tablewriter"
 )
 
 const (
-	stateFile = "googet.state"
+	stateFile = api.StateFile
 	confFile  = "googet.conf"
 	logFile   = "googet.log"
-	cacheDir  = "cache"
-	repoDir   = "repos"
+	cacheDir  = api.CacheDir
+	trashDir  = "trash"
+	eventsDir = "events"
+	repoDir   = api.RepoDir
+	pinsFile  = "googet.pins"
 	envVar    = "GooGetRoot"
 	logSize   = 10 * 1024 * 1024
 )
 
 var (
-	rootDir        string
-	noConfirm      bool
-	verbose        bool
-	systemLog      bool
-	showVer        bool
-	version        string
-	cacheLife      = 3 * time.Minute
-	archs          []string
-	proxyServer    string
-	allowUnsafeURL bool
-	lockFile       string
+	rootDir      string
+	noConfirm    bool
+	verbose      bool
+	systemLog    bool
+	showVer      bool
+	elevate      bool
+	maxDownloads int
+	maxBandwidth string
+	logFormat    string
+	strategy     string
+	version      string
+	cacheLife    = 3 * time.Minute
+	trashLife    time.Duration
+	archs        []string
+	proxyServer  string
+	otherRoots   []string
+	lockFile     string
+	metricsFile  string
 )
 
 type packageMap map[string]string
@@ -74,234 +101,205 @@ func installedPackages(state client.GooGetState) packageMap {
 	return pm
 }
 
-type repoFile struct {
-	fileName    string
-	repoEntries []repoEntry
-}
-
-type repoEntry struct {
-	Name     string
-	URL      string
-	UseOAuth bool
-	Priority priority.Value `yaml:",omitempty"`
-}
-
-// UnmarshalYAML provides custom unmarshalling for repoEntry objects.
-func (r *repoEntry) UnmarshalYAML(unmarshal func(any) error) error {
-	var u map[string]string
-	if err := unmarshal(&u); err != nil {
-		return err
-	}
-	for k, v := range u {
-		switch key := strings.ToLower(k); key {
-		case "name":
-			r.Name = v
-		case "url":
-			r.URL = v
-		case "useoauth":
-			r.UseOAuth = strings.ToLower(v) == "true"
-		case "priority":
-			var err error
-			r.Priority, err = priority.FromString(v)
-			if err != nil {
-				return fmt.Errorf("invalid priority: %v", v)
+// readCosignTrust reads the Fulcio certificates in the PEM files roots, the
+// self-signed ones being roots and the others intermediates, and the Rekor
+// public keys in the PEM files rekorKeys.
+func readCosignTrust(roots, rekorKeys []string) (goolib.CosignTrust, error) {
+	var t goolib.CosignTrust
+	var hasRoot bool
+	if len(roots) > 0 {
+		t.Roots, t.Intermediates = x509.NewCertPool(), x509.NewCertPool()
+	}
+	for _, f := range roots {
+		certs, err := goolib.ReadCertificates(f)
+		if err != nil {
+			return t, err
+		}
+		for _, c := range certs {
+			if bytes.Equal(c.RawIssuer, c.RawSubject) {
+				t.Roots.AddCert(c)
+				hasRoot = true
+			} else {
+				t.Intermediates.AddCert(c)
 			}
 		}
 	}
-	if r.URL == "" {
-		return fmt.Errorf("repo entry missing url: %+v", u)
+	if len(roots) > 0 && !hasRoot {
+		return t, errors.New("no self-signed root certificate in cosignroots")
 	}
-	return nil
-}
-
-func writeRepoFile(rf repoFile) error {
-	d, err := yaml.Marshal(rf.repoEntries)
-	if err != nil {
-		return err
-	}
-	return ioutil.WriteFile(rf.fileName, d, 0664)
-}
-
-func unmarshalRepoFile(p string) (repoFile, error) {
-	b, err := ioutil.ReadFile(p)
-	if err != nil {
-		return repoFile{}, err
-	}
-
-	// Don't try to unmarshal files with no YAML content
-	var yml bool
-	lns := strings.Split(string(b), "\n")
-	for _, ln := range lns {
-		ln = strings.TrimSpace(ln)
-		if !strings.HasPrefix(ln, "#") && ln != "" {
-			yml = true
-			break
+	for _, f := range rekorKeys {
+		key, err := goolib.ReadPublicKey(f)
+		if err != nil {
+			return t, err
 		}
+		t.RekorKeys = append(t.RekorKeys, key)
 	}
-	if !yml {
-		return repoFile{}, nil
-	}
-
-	// Both repoFile and []repoFile are valid for backwards compatibility.
-	var re repoEntry
-	if err := yaml.Unmarshal(b, &re); err == nil && re.URL != "" {
-		return repoFile{fileName: p, repoEntries: []repoEntry{re}}, nil
-	}
-
-	var res []repoEntry
-	if err := yaml.Unmarshal(b, &res); err != nil {
-		return repoFile{}, err
-	}
-	return repoFile{fileName: p, repoEntries: res}, nil
+	return t, nil
 }
 
 type conf struct {
-	Archs          []string
+	Archs []string
+	// ArchPreference lists archs to prefer, in order, over the other
+	// installable archs.
+	ArchPreference []string
 	CacheLife      string
 	ProxyServer    string
+	// AllowUnsafeURL is no longer supported, repos allow plain HTTP with
+	// the allowhttp setting of their .repo entry.
 	AllowUnsafeURL bool
+	// FileMode, DirMode and Umask are octal permissions applied to files and
+	// directories created by installs on Linux and darwin.
+	FileMode, DirMode, Umask string
+	// TrashLife enables moving removed files to the trash directory, where
+	// they are kept for this duration.
+	TrashLife string
+	// ModifiedFiles is the policy for files modified since install when their
+	// package is removed: remove, keep or backup.
+	ModifiedFiles string
+	// ScanCommand is run with the path of each package appended before it
+	// is installed, a non-zero exit code blocks the install.
+	ScanCommand []string
+	// ScanTimeout limits the run time of ScanCommand.
+	ScanTimeout string
+	// Hooks maps hook points, such as preinstall, to the commands run there,
+	// see the hooks package. HookTimeout limits the run time of each.
+	Hooks       map[string][]string
+	HookTimeout string
+	// ScriptTimeout limits the run time of package install, uninstall and
+	// verify commands that don't set a timeout, "0" for no limit.
+	ScriptTimeout string
+	// AllowedRepos restricts the repos that can be used to URLs under one of
+	// these URLs, a host starting with "*." also matches its subdomains.
+	AllowedRepos []string
+	// RepoCA is a PEM file of the CA certificates trusted for HTTPS repos,
+	// replacing the system roots unless RepoCAAppend is set.
+	RepoCA       string
+	RepoCAAppend bool
+	// ClientCert and ClientKey are PEM files of the client certificate
+	// presented to HTTPS repos, the key defaulting to ClientCert.
+	ClientCert, ClientKey string
+	// ConnectTimeout, TLSHandshakeTimeout, ResponseHeaderTimeout and
+	// RequestTimeout replace client.DefaultTimeouts, "0" for no limit.
+	ConnectTimeout, TLSHandshakeTimeout, ResponseHeaderTimeout, RequestTimeout string
+	// HelperUsers and HelperGroups may run HelperCommands through the
+	// helper, see googet_helper.go.
+	HelperUsers, HelperGroups, HelperCommands []string
+	// PublisherKeys maps publisher names to PEM files of the ed25519 public
+	// keys their packages are signed with.
+	PublisherKeys map[string]string
+	// PublisherPolicy restricts the repos and publishers packages can be
+	// installed from by name prefix.
+	PublisherPolicy []install.PublisherRule
+	// Roots lists the other GooGet roots of the machine, whose packages
+	// installed -all_roots lists as well.
+	Roots []string
+	// SignatureKeys are PEM files of the ed25519 public keys the embedded
+	// signatures of packages are verified with, SignaturePolicy is off, warn
+	// or enforce. .repo entries can replace both.
+	SignatureKeys   []string
+	SignaturePolicy string
+	// CosignKeys are PEM files of the public keys cosign bundles of packages
+	// are verified with, keyless bundles must instead be issued to
+	// CosignIdentity by CosignIssuer by the Fulcio CA whose certificates are
+	// in the CosignRoots PEM files and be recorded in a Rekor log whose keys
+	// are in the CosignRekorKeys PEM files. CosignPolicy is off, warn or
+	// enforce. .repo entries can replace all but the roots and Rekor keys.
+	CosignKeys      []string
+	CosignIdentity  string
+	CosignIssuer    string
+	CosignRoots     []string
+	CosignRekorKeys []string
+	CosignPolicy    string
+	// MaxDownloads is the number of packages downloaded in parallel before
+	// an install or update, MaxBandwidth caps the download rate, in bytes
+	// per second with an optional unit such as "2MB".
+	MaxDownloads int
+	MaxBandwidth string
+	// MaxInstalls is not supported, packages are always installed one at a
+	// time.
+	MaxInstalls int
+	// PinRepos pins the TLS certificates of repo hosts and the index signing
+	// keys of repos the first time they are seen, changes then need
+	// googet trust accept.
+	PinRepos bool
+	// LogFormat is text, the default, or json.
+	LogFormat string
+	// ResolveStrategy is a comma separated list of the rules of
+	// install.Strategy dependencies are resolved with.
+	ResolveStrategy string
+	// OTLPEndpoint is the base URL of an OpenTelemetry collector receiving
+	// OTLP over HTTP, such as http://localhost:4318, to export the spans of
+	// each run and their durations to. OTLPHeaders are sent with them.
+	OTLPEndpoint string
+	OTLPHeaders  map[string]string
+	// MetricsFile is a file the metrics of the commands run directly are
+	// kept in, in the Prometheus text format, see googet_metrics.go.
+	MetricsFile string
 }
 
-func unmarshalConfFile(p string) (*conf, error) {
+// unmarshalConfFile reads the conf file p into cf, keeping the settings it
+// does not set.
+func unmarshalConfFile(p string, cf *conf) error {
 	b, err := ioutil.ReadFile(p)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var cf conf
-	return &cf, yaml.Unmarshal(b, &cf)
+	return yaml.Unmarshal(b, cf)
 }
 
-// validateRepoURL uses the global allowUnsafeURL to determine if u should be checked for https or
-// GCS status.
-func validateRepoURL(u string) bool {
-	if allowUnsafeURL {
-		return true
-	}
-	gcs, _, _ := goolib.SplitGCSUrl(u)
-	parsed, err := url.Parse(u)
-	if err != nil {
-		logger.Errorf("Failed to parse URL '%s', skipping repo", u)
-		return false
-	}
-	if parsed.Scheme != "https" && !gcs {
-		logger.Errorf("%s will not be used as a repository, only https and Google Cloud Storage endpoints will be used unless 'allowunsafeurl' is set to 'true' in googet.conf", u)
-		return false
-	}
-	return true
-}
+var loadRepoTransports sync.Once
 
-// repoList returns a deduped set of all repos listed in the repo config files contained in dir.
-// The repos are mapped to priority values. If a repo config does not specify a priority, the repo
-// is assigned the default priority value. If the same repo appears multiple times with different
-// priority values, it is mapped to the highest seen priority value.
-func repoList(dir string) (map[string]priority.Value, error) {
-	rfs, err := repos(dir)
-	if err != nil {
-		return nil, err
-	}
-	result := make(map[string]priority.Value)
-	for _, rf := range rfs {
-		for _, re := range rf.repoEntries {
-			u := re.URL
-			if u == "" || !validateRepoURL(u) {
-				continue
-			}
-			if re.UseOAuth {
-				u = "oauth-" + u
-			}
-			p := re.Priority
-			if p <= 0 {
-				p = priority.Default
-			}
-			if q, ok := result[u]; !ok || p > q {
-				result[u] = p
-			}
-		}
-	}
-	return result, nil
-}
-
-func repos(dir string) ([]repoFile, error) {
-	fl, err := filepath.Glob(filepath.Join(dir, "*.repo"))
-	if err != nil {
-		return nil, err
-	}
-	var rfs []repoFile
-	for _, f := range fl {
-		rf, err := unmarshalRepoFile(f)
-		if err != nil {
+// newDownloader returns the Downloader used to fetch indexes and packages.
+func newDownloader() client.Downloader {
+	// Commands that don't list the repos, like remove, still download
+	// packages from them.
+	loadRepoTransports.Do(func() {
+		if _, err := api.RepoList(filepath.Join(rootDir, repoDir)); err != nil {
 			logger.Error(err)
-			continue
-		}
-		if rf.fileName != "" {
-			rfs = append(rfs, rf)
 		}
-	}
-	return rfs, nil
-}
-
-func writeState(s *client.GooGetState, sf string) error {
-	b, err := s.Marshal()
-	if err != nil {
-		return err
-	}
-	// Write state to a temporary file first
-	tmp, err := ioutil.TempFile(rootDir, "googet.*.state")
-	if err != nil {
-		return err
-	}
-	newStateFile := tmp.Name()
-	if _, err = tmp.Write(b); err != nil {
-		return err
-	}
-	if err := tmp.Close(); err != nil {
-		return err
-	}
-	if err := os.Chmod(newStateFile, 0664); err != nil {
-		return err
-	}
-	// Back up the old state file so we can recover it if need be
-	backupStateFile := sf + ".bak"
-	if err = os.Rename(sf, backupStateFile); err != nil {
-		logger.Infof("Unable to back up state file %s to %s. Err: %v", sf, backupStateFile, err)
-	}
-	// Move the new temp file to the live path
-	return os.Rename(newStateFile, sf)
-}
-
-func readState(sf string) (*client.GooGetState, error) {
-	state, err := readStateFromPath(sf)
-	if err != nil {
-		sfNotExist := os.IsNotExist(err)
-		state, err = readStateFromPath(sf + ".bak")
-		if sfNotExist && os.IsNotExist(err) {
-			logger.Info("No state file found, assuming no packages installed.")
-			return &client.GooGetState{}, nil
-		}
-	}
-
-	return state, err
-}
-
-func readStateFromPath(sf string) (*client.GooGetState, error) {
-	b, err := ioutil.ReadFile(sf)
-	if err != nil {
-		return nil, err
-	}
-	return client.UnmarshalState(b)
+	})
+	return client.HTTPDownloader{ProxyServer: proxyServer, Repos: api.RepoTransports}
 }
 
 func buildSources(s string) (map[string]priority.Value, error) {
 	if s == "" {
-		return repoList(filepath.Join(rootDir, repoDir))
+		return api.RepoList(filepath.Join(rootDir, repoDir))
 	}
 	m := make(map[string]priority.Value)
 	for _, src := range strings.Split(s, ",") {
+		if err := api.RepoAllowed(src); err != nil {
+			return nil, err
+		}
 		m[src] = priority.Default
 	}
 	return m, nil
 }
 
+// newClient returns an api.Client for the root with the settings of
+// googet.conf, using the repos of sources instead of the .repo files if set.
+// It asks for confirmation unless -noconfirm is set.
+func newClient(sources string) (*api.Client, error) {
+	c := &api.Client{
+		Root:         rootDir,
+		Archs:        archs,
+		CacheLife:    cacheLife,
+		ProxyServer:  proxyServer,
+		AllowedRepos: api.AllowedRepos,
+		Signatures:   install.DefaultSignatures,
+		Cosign:       install.DefaultCosign,
+	}
+	if !noConfirm {
+		c.Confirm = confirmation
+	}
+	if sources != "" {
+		var err error
+		if c.Sources, err = buildSources(sources); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
 func confirmation(msg string) bool {
 	var c string
 	fmt.Print(msg + " (y/N): ")
@@ -310,6 +308,35 @@ func confirmation(msg string) bool {
 	return c == "y" || c == "yes"
 }
 
+// chooseCandidate asks which of choices to install to meet dep, the first
+// one if there is no answer or with -noconfirm.
+func chooseCandidate(dep string, choices []string) int {
+	if noConfirm {
+		return 0
+	}
+	fmt.Printf("%s can be met by:\n", dep)
+	for i, c := range choices {
+		fmt.Printf("  %d) %s\n", i+1, c)
+	}
+	var c string
+	fmt.Printf("Install which? [1-%d] (1): ", len(choices))
+	fmt.Scanln(&c)
+	if i, err := strconv.Atoi(c); err == nil && i >= 1 && i <= len(choices) {
+		return i - 1
+	}
+	return 0
+}
+
+// tagKeys returns the sorted keys of the tags of ps.
+func tagKeys(ps *goolib.PkgSpec) []string {
+	var keys []string
+	for k := range ps.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func info(ps *goolib.PkgSpec, r string) {
 	fmt.Println()
 
@@ -324,6 +351,7 @@ func info(ps *goolib.PkgSpec, r string) {
 		{"Owners", ps.Owners},
 		{"Source", ps.Source},
 		{"Description", ps.Description},
+		{"Tags", strings.Join(tagKeys(ps), ", ")},
 		{"Dependencies", ""},
 		{"ReleaseNotes", ""},
 	}
@@ -338,7 +366,7 @@ func info(ps *goolib.PkgSpec, r string) {
 	for _, pi := range pkgInfo {
 		if pi.name == "Dependencies" {
 			var deps []string
-			for p, v := range ps.PkgDependencies {
+			for p, v := range ps.Dependencies() {
 				deps = append(deps, p+" "+v)
 			}
 			if len(deps) == 0 {
@@ -398,12 +426,25 @@ func rotateLog(logPath string, ls int64) error {
 }
 
 func readConf(cf string) {
-	gc, err := unmarshalConfFile(cf)
+	// Settings in the environment are overridden by the conf file.
+	gc, err := envConf(os.Environ())
 	if err != nil {
-		if os.IsNotExist(err) {
-			gc = &conf{}
-		} else {
-			logger.Errorf("Error unmarshalling conf file: %v", err)
+		logger.Error(err)
+		gc = &conf{}
+	}
+	if err := unmarshalConfFile(cf, gc); err != nil && !os.IsNotExist(err) {
+		logger.Errorf("Error unmarshalling conf file: %v", err)
+	}
+	// Fragments in the .d directory of the conf file are merged in name
+	// order, later ones overriding the settings they set.
+	frags, err := filepath.Glob(filepath.Join(cf+".d", "*.conf"))
+	if err != nil {
+		logger.Error(err)
+	}
+	sort.Strings(frags)
+	for _, f := range frags {
+		if err := unmarshalConfFile(f, gc); err != nil {
+			logger.Errorf("Error unmarshalling conf file %s: %v", f, err)
 		}
 	}
 
@@ -415,6 +456,7 @@ func readConf(cf string) {
 			logger.Fatal(err)
 		}
 	}
+	archs = api.PreferArchs(archs, gc.ArchPreference)
 
 	if gc.CacheLife != "" {
 		cacheLife, err = time.ParseDuration(gc.CacheLife)
@@ -427,7 +469,184 @@ func readConf(cf string) {
 		proxyServer = gc.ProxyServer
 	}
 
-	allowUnsafeURL = gc.AllowUnsafeURL
+	if gc.AllowUnsafeURL {
+		logger.Warning("allowunsafeurl in googet.conf is no longer supported and is ignored, set allowhttp in the .repo entries of the repos served over plain HTTP")
+	}
+	api.AllowedRepos = gc.AllowedRepos
+	if gc.RepoCA != "" {
+		// Falling back to the system roots would silently weaken the policy.
+		if client.RootCAs, err = api.ReadCertPool(gc.RepoCA, gc.RepoCAAppend); err != nil {
+			logger.Fatalf("Error reading repoca: %v", err)
+		}
+	}
+	if gc.ClientCert != "" {
+		cert, err := api.LoadKeyPair(gc.ClientCert, gc.ClientKey)
+		if err != nil {
+			logger.Fatalf("Error reading clientcert: %v", err)
+		}
+		client.Certificate = cert
+	}
+	for _, t := range []struct {
+		name, value string
+		d           *time.Duration
+	}{
+		{"connecttimeout", gc.ConnectTimeout, &client.DefaultTimeouts.Connect},
+		{"tlshandshaketimeout", gc.TLSHandshakeTimeout, &client.DefaultTimeouts.TLSHandshake},
+		{"responseheadertimeout", gc.ResponseHeaderTimeout, &client.DefaultTimeouts.ResponseHeader},
+		{"requesttimeout", gc.RequestTimeout, &client.DefaultTimeouts.Request},
+	} {
+		if t.value == "" {
+			continue
+		}
+		// Keep the default rather than disable the limit if this is invalid.
+		if d, err := time.ParseDuration(t.value); err != nil {
+			logger.Errorf("Error reading %s: %v", t.name, err)
+		} else {
+			*t.d = d
+		}
+	}
+
+	if gc.TrashLife != "" {
+		trashLife, err = time.ParseDuration(gc.TrashLife)
+		if err != nil {
+			logger.Error(err)
+		}
+	}
+	if trashLife > 0 {
+		remove.TrashDir = filepath.Join(rootDir, trashDir)
+	}
+	if remove.ModifiedFiles, err = remove.ParseModifiedPolicy(gc.ModifiedFiles); err != nil {
+		logger.Error(err)
+		remove.ModifiedFiles = remove.RemoveModified
+	}
+
+	helperUsers, helperGroups, helperCommands = gc.HelperUsers, gc.HelperGroups, gc.HelperCommands
+	otherRoots = gc.Roots
+
+	install.PublisherRules = gc.PublisherPolicy
+	install.PublisherKeys = make(map[string]ed25519.PublicKey)
+	for p, f := range gc.PublisherKeys {
+		// Packages of a publisher without a key can't be verified and so
+		// are blocked by rules naming it.
+		key, err := goolib.ReadVerifyKey(f)
+		if err != nil {
+			logger.Errorf("Error reading key of publisher %q: %v", p, err)
+			continue
+		}
+		install.PublisherKeys[p] = key
+	}
+
+	// Falling back to no checks would silently weaken the policy.
+	if install.DefaultSignatures.Policy, err = install.ParseSignaturePolicy(gc.SignaturePolicy); err != nil {
+		logger.Fatalf("Error reading signaturepolicy: %v", err)
+	}
+	if install.DefaultSignatures.Keys, err = api.ReadSignatureKeys(gc.SignatureKeys); err != nil {
+		logger.Fatalf("Error reading signaturekeys: %v", err)
+	}
+	if install.DefaultCosign.Policy, err = install.ParseSignaturePolicy(gc.CosignPolicy); err != nil {
+		logger.Fatalf("Error reading cosignpolicy: %v", err)
+	}
+	if install.DefaultCosign.Keys, err = api.ReadCosignKeys(gc.CosignKeys); err != nil {
+		logger.Fatalf("Error reading cosignkeys: %v", err)
+	}
+	install.DefaultCosign.Identity, install.DefaultCosign.Issuer = gc.CosignIdentity, gc.CosignIssuer
+	if install.CosignTrust, err = readCosignTrust(gc.CosignRoots, gc.CosignRekorKeys); err != nil {
+		logger.Fatalf("Error reading cosignroots or cosignrekorkeys: %v", err)
+	}
+
+	if gc.PinRepos {
+		if client.Pins, err = client.LoadPins(filepath.Join(rootDir, pinsFile)); err != nil {
+			logger.Fatalf("Error reading pins: %v", err)
+		}
+	}
+
+	install.ScanCommand = gc.ScanCommand
+	if gc.ScanTimeout != "" {
+		install.ScanTimeout, err = time.ParseDuration(gc.ScanTimeout)
+		if err != nil {
+			logger.Error(err)
+		}
+	}
+
+	for h := range gc.Hooks {
+		if !goolib.ContainsString(h, hooks.Points) {
+			logger.Errorf("Unknown hook point %q in %s", h, cf)
+		}
+	}
+	hooks.Commands = gc.Hooks
+	if gc.HookTimeout != "" {
+		// Keep the default rather than disable the limit if this is invalid.
+		if d, err := time.ParseDuration(gc.HookTimeout); err != nil {
+			logger.Error(err)
+		} else {
+			hooks.Timeout = d
+		}
+	}
+
+	if gc.ScriptTimeout != "" {
+		// Keep the default rather than disable the limit if this is invalid.
+		if d, err := time.ParseDuration(gc.ScriptTimeout); err != nil {
+			logger.Error(err)
+		} else {
+			system.ScriptTimeout = d
+		}
+	}
+
+	// The -max_downloads and -max_bandwidth flags override the conf file.
+	if gc.MaxInstalls > 1 {
+		logger.Warning("maxinstalls in googet.conf is not supported and is ignored, packages are installed one at a time, use maxdownloads to download them in parallel")
+	}
+	if maxDownloads == 0 {
+		maxDownloads = gc.MaxDownloads
+	}
+	if maxDownloads > 0 {
+		install.MaxDownloads = maxDownloads
+	}
+	if maxBandwidth == "" {
+		maxBandwidth = gc.MaxBandwidth
+	}
+	if maxBandwidth != "" {
+		if b, err := humanize.ParseBytes(maxBandwidth); err != nil {
+			logger.Errorf("Invalid maxbandwidth %q: %v", maxBandwidth, err)
+		} else {
+			download.MaxBandwidth = int64(b)
+		}
+	}
+	if logFormat == "" {
+		logFormat = gc.LogFormat
+	}
+	switch logFormat = strings.ToLower(logFormat); logFormat {
+	case "", "text", "json":
+	default:
+		logger.Errorf("Invalid logformat %q, want text or json", logFormat)
+		logFormat = ""
+	}
+	if strategy == "" {
+		strategy = gc.ResolveStrategy
+	}
+	if s, err := install.ParseStrategy(strategy); err != nil {
+		logger.Error(err)
+	} else {
+		install.ResolveStrategy = s
+	}
+	install.Choose = chooseCandidate
+	metricsFile = gc.MetricsFile
+	telemetry.Endpoint = gc.OTLPEndpoint
+	telemetry.Headers = gc.OTLPHeaders
+	telemetry.Resource["service.version"] = version
+	if h, err := os.Hostname(); err == nil {
+		telemetry.Resource["host.name"] = h
+	}
+
+	if install.DefaultPermissions.FileMode, err = goolib.ParseMode(gc.FileMode); err != nil {
+		logger.Error(err)
+	}
+	if install.DefaultPermissions.DirMode, err = goolib.ParseMode(gc.DirMode); err != nil {
+		logger.Error(err)
+	}
+	if install.DefaultPermissions.Umask, err = goolib.ParseMode(gc.Umask); err != nil {
+		logger.Error(err)
+	}
 }
 
 var deferredFuncs []func()
@@ -449,38 +668,78 @@ func obtainLock(lockFile string) error {
 		return err
 	}
 
-	c := make(chan error)
+	type result struct {
+		unlock func()
+		err    error
+	}
+	// The lock taken after the timeout is left to the exit of the process.
+	c := make(chan result)
 	go func() {
-		c <- lock(f)
+		unlock, err := api.Lock(f)
+		c <- result{unlock, err}
 	}()
 
 	ticker := time.NewTicker(5 * time.Second)
 	// 90% of all GooGet runs happen in < 60s, we wait 70s.
 	for i := 1; i < 15; i++ {
 		select {
-		case err := <-c:
-			if err != nil {
-				return err
+		case r := <-c:
+			if r.err != nil {
+				return r.err
 			}
+			deferredFuncs = append(deferredFuncs, r.unlock)
 			return nil
 		case <-ticker.C:
 			fmt.Fprintln(os.Stdout, "GooGet lock already held, waiting...")
 		}
 	}
-	return errors.New("timed out waiting for lock")
+	return errLockTimeout
+}
+
+// requireElevation handles err, caused by running a command without the
+// admin rights it needs: with -elevate GooGet runs the command again
+// elevated, otherwise it exits with exitNotElevated so wrappers can tell.
+func requireElevation(err error) {
+	if elevate {
+		code, err := runElevated(append([]string{"-root", rootDir}, os.Args[1:]...))
+		if err == nil {
+			os.Exit(code)
+		}
+		logger.Errorf("Error running elevated: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "GooGet needs admin rights: %v\n", err)
+	os.Exit(exitNotElevated)
+}
+
+// defaultRootDir returns the root directory set in the environment, or the
+// default root of the system.
+func defaultRootDir() string {
+	if r := os.Getenv(envVar); r != "" {
+		return r
+	}
+	return system.DefaultRoot
 }
 
 func main() {
 	ggFlags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
-	ggFlags.StringVar(&rootDir, "root", os.Getenv(envVar), "googet root directory")
+	ggFlags.StringVar(&rootDir, "root", defaultRootDir(), "googet root directory")
 	ggFlags.BoolVar(&noConfirm, "noconfirm", false, "skip confirmation")
 	ggFlags.BoolVar(&verbose, "verbose", false, "print info level logs to stdout")
 	ggFlags.BoolVar(&systemLog, "system_log", true, "log to Linux Syslog or Windows Event Log")
 	ggFlags.BoolVar(&showVer, "version", false, "display GooGet version and exit")
+	ggFlags.BoolVar(&elevate, "elevate", false, "if admin rights are needed, run again elevated after a UAC prompt, Windows only")
+	ggFlags.IntVar(&maxDownloads, "max_downloads", 0, "number of packages to download in parallel, overrides maxdownloads in googet.conf")
+	ggFlags.StringVar(&maxBandwidth, "max_bandwidth", "", "cap on the download rate in bytes per second, such as 2MB, overrides maxbandwidth in googet.conf")
+	ggFlags.BoolVar(&detailedExitCodes, "detailed_exit_codes", false, "exit with a distinct status when there is nothing to do, on partial failures, when the lock is held, a repo is unreachable or a package fails verification")
+	ggFlags.StringVar(&strategy, "resolve_strategy", "", "comma separated rules dependencies are resolved with: prefer-highest-priority-repo, prefer-installed-arch, fail-fast or interactive, overrides resolvestrategy in googet.conf")
+	ggFlags.StringVar(&logFormat, "log_format", "", "format of the log file and of the logs printed with -verbose, text or json, overrides logformat in googet.conf")
 
 	if err := ggFlags.Parse(os.Args[1:]); err != nil && err != flag.ErrHelp {
 		logger.Fatal(err)
 	}
+	if err := flagsFromEnv(ggFlags); err != nil {
+		logger.Fatal(err)
+	}
 
 	if showVer {
 		fmt.Println("GooGet version:", version)
@@ -491,18 +750,26 @@ func main() {
 	cmdr.Register(cmdr.FlagsCommand(), "")
 	cmdr.Register(cmdr.CommandsCommand(), "")
 	cmdr.Register(cmdr.HelpCommand(), "")
-	cmdr.Register(&installCmd{}, "package management")
-	cmdr.Register(&downloadCmd{}, "package management")
-	cmdr.Register(&removeCmd{}, "package management")
-	cmdr.Register(&updateCmd{}, "package management")
-	cmdr.Register(&verifyCmd{}, "package management")
-	cmdr.Register(&installedCmd{}, "package query")
-	cmdr.Register(&latestCmd{}, "package query")
-	cmdr.Register(&availableCmd{}, "package query")
-	cmdr.Register(&listReposCmd{}, "repository management")
-	cmdr.Register(&addRepoCmd{}, "repository management")
-	cmdr.Register(&rmRepoCmd{}, "repository management")
-	cmdr.Register(&cleanCmd{}, "")
+	cmdr.Register(envCmd{&installCmd{}}, "package management")
+	cmdr.Register(envCmd{&downloadCmd{}}, "package management")
+	cmdr.Register(envCmd{&removeCmd{}}, "package management")
+	cmdr.Register(envCmd{&updateCmd{}}, "package management")
+	cmdr.Register(envCmd{&verifyCmd{}}, "package management")
+	cmdr.Register(envCmd{&applyCmd{}}, "package management")
+	cmdr.Register(envCmd{&installedCmd{}}, "package query")
+	cmdr.Register(envCmd{&latestCmd{}}, "package query")
+	cmdr.Register(envCmd{&availableCmd{}}, "package query")
+	cmdr.Register(envCmd{&sizeCmd{}}, "package query")
+	cmdr.Register(envCmd{&auditCmd{}}, "package query")
+	cmdr.Register(envCmd{&exportCmd{}}, "package query")
+	cmdr.Register(envCmd{&listReposCmd{}}, "repository management")
+	cmdr.Register(envCmd{&addRepoCmd{}}, "repository management")
+	cmdr.Register(envCmd{&rmRepoCmd{}}, "repository management")
+	cmdr.Register(envCmd{&trustCmd{}}, "repository management")
+	cmdr.Register(envCmd{&repoStatusCmd{}}, "repository management")
+	cmdr.Register(envCmd{&cleanCmd{}}, "")
+	cmdr.Register(envCmd{&helperCmd{}}, "")
+	cmdr.Register(envCmd{&daemonCmd{}}, "")
 
 	cmdr.ImportantFlag("verbose")
 	cmdr.ImportantFlag("noconfirm")
@@ -516,18 +783,44 @@ func main() {
 		logger.Fatalf("The environment variable %q not defined and no '-root' flag passed.", envVar)
 	}
 	if err := os.MkdirAll(rootDir, 0774); err != nil {
+		if os.IsPermission(err) && !isElevated() {
+			requireElevation(err)
+		}
 		logger.Fatalln("Error setting up root directory:", err)
 	}
 
-	lockFile = filepath.Join(rootDir, "googet.lock")
-	if err := obtainLock(lockFile); err != nil {
-		logger.Fatalf("Cannot obtain GooGet lock, you may need to run with admin rights, error: %v", err)
+	lockFile = filepath.Join(rootDir, api.LockFile)
+	// The commands run by the helper and the operations of the daemon take
+	// the lock themselves.
+	if ggFlags.Args()[0] != "helper" && ggFlags.Args()[0] != "daemon" {
+		if err := obtainLock(lockFile); err != nil {
+			if _, serr := os.Stat(filepath.Join(rootDir, helperSocket)); os.IsPermission(err) && serr == nil {
+				code, err := runThroughHelper(ggFlags.Args())
+				if err != nil {
+					logger.Fatal(err)
+				}
+				os.Exit(code)
+			}
+			if os.IsPermission(err) && !isElevated() {
+				requireElevation(err)
+			}
+			if !detailedExitCodes || !errors.Is(err, errLockTimeout) {
+				logger.Fatalf("Cannot obtain GooGet lock, you may need to run with admin rights, error: %v", err)
+			}
+			// The command exits with exitLockHeld without running.
+			logger.Errorf("Cannot obtain GooGet lock: %v", err)
+			lockHeld = true
+		}
 	}
 	readConf(filepath.Join(rootDir, confFile))
+	events.Dir = filepath.Join(rootDir, eventsDir)
 
 	logPath := filepath.Join(rootDir, logFile)
-	if err := rotateLog(logPath, logSize); err != nil {
-		logger.Error(err)
+	// The run holding the lock may be writing the log.
+	if !lockHeld {
+		if err := rotateLog(logPath, logSize); err != nil {
+			logger.Error(err)
+		}
 	}
 	lf, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0660)
 	if err != nil {
@@ -536,7 +829,16 @@ func main() {
 	}
 	deferredFuncs = append(deferredFuncs, func() { lf.Close() })
 
-	logger.Init("GooGet", verbose, systemLog, lf)
+	if logFormat == "json" {
+		// The system log and the errors printed to stderr stay text.
+		var w io.Writer = lf
+		if verbose {
+			w = io.MultiWriter(lf, os.Stdout)
+		}
+		logger.Init("GooGet", false, systemLog, jsonlog.Enable(w, ggFlags.Arg(0)))
+	} else {
+		logger.Init("GooGet", verbose, systemLog, lf)
+	}
 
 	if err := os.MkdirAll(filepath.Join(rootDir, cacheDir), 0774); err != nil {
 		runDeferredFuncs()
@@ -547,7 +849,29 @@ func main() {
 		logger.Fatalf("Error setting up repo directory: %v", err)
 	}
 
-	es := cmdr.Execute(context.Background())
+	// Interrupting googet kills the package scripts it runs, so that the
+	// lock is released.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	ctx, span := telemetry.Start(ctx, "googet "+ggFlags.Arg(0))
+	start := time.Now()
+	es := cmdr.Execute(ctx)
+	stop()
+	// The helper exports its own metrics, the daemon runs until stopped.
+	if metricsFile != "" && ggFlags.Arg(0) != "helper" && ggFlags.Arg(0) != "daemon" {
+		if err := recordRun(metricsFile, ggFlags.Arg(0), es, time.Since(start)); err != nil {
+			logger.Errorf("Error recording metrics: %v", err)
+		}
+	}
+	var serr error
+	if es != subcommands.ExitSuccess {
+		serr = fmt.Errorf("exit status %d", es)
+	}
+	span.End(serr)
+	fctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := telemetry.Flush(fctx); err != nil {
+		logger.Errorf("Error exporting telemetry: %v", err)
+	}
+	cancel()
 	runDeferredFuncs()
 	os.Exit(int(es))
 }
@@ -22,37 +22,40 @@ import (
 	"strings"
 
 	"github.com/go-yaml/yaml"
-	"github.com/google/googet/v2/oswrap"
+	"github.com/google/googet/v2/api"
 	"github.com/google/googet/v2/priority"
 	"github.com/google/logger"
 	"github.com/google/subcommands"
 )
 
 type addRepoCmd struct {
-	file     string
-	priority string
+	file      string
+	priority  string
+	allowHTTP bool
 }
 
 func (*addRepoCmd) Name() string     { return "addrepo" }
 func (*addRepoCmd) Synopsis() string { return "add repository" }
 func (*addRepoCmd) Usage() string {
-	return fmt.Sprintf(`%s addrepo [-file <repofile>] [-priority <value>] <name> <url>:
+	return fmt.Sprintf(`%s addrepo [-file <repofile>] [-priority <value>] [-allow_http] <name> <url>:
 	Add repository to GooGet's repository list. 
 	If -file is not set 'name.repo' will be used for the file name 
 	overwriting any existing file with than name. 
 	If -file is set the specified repo will be appended to that repo file, 
 	creating it if it does not exist.
 	If -priority is specified, the repo will be configured with this priority level.
+	If -allow_http is set, the repo may be served over plain HTTP.
 `, filepath.Base(os.Args[0]))
 }
 
 func (cmd *addRepoCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&cmd.file, "file", "", "repo file to add this repository to")
 	f.StringVar(&cmd.priority, "priority", "", "priority level assigned to repository")
+	f.BoolVar(&cmd.allowHTTP, "allow_http", false, "allow the repository to be served over plain HTTP")
 }
 
 func (cmd *addRepoCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	var newEntry repoEntry
+	var newEntry api.RepoEntry
 	switch f.NArg() {
 	case 0, 1:
 		fmt.Fprintln(os.Stderr, "Not enough arguments")
@@ -61,12 +64,18 @@ func (cmd *addRepoCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interfac
 	case 2:
 		newEntry.Name = f.Arg(0)
 		newEntry.URL = f.Arg(1)
+		newEntry.AllowHTTP = cmd.allowHTTP
 	default:
 		fmt.Fprintln(os.Stderr, "Excessive arguments")
 		f.Usage()
 		return subcommands.ExitUsageError
 	}
 
+	if err := api.RepoAllowed(newEntry.URL); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return subcommands.ExitFailure
+	}
+
 	if cmd.file == "" {
 		cmd.file = newEntry.Name + ".repo"
 	} else {
@@ -85,38 +94,20 @@ func (cmd *addRepoCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interfac
 		}
 	}
 
-	content, err := yaml.Marshal([]repoEntry{newEntry})
+	content, err := yaml.Marshal([]api.RepoEntry{newEntry})
 	if err != nil {
 		logger.Fatal(err)
 	}
 
 	repoPath := filepath.Join(rootDir, repoDir, cmd.file)
 
-	if _, err := oswrap.Stat(repoPath); err != nil && os.IsNotExist(err) {
-		if err := writeRepoFile(repoFile{repoPath, []repoEntry{newEntry}}); err != nil {
-			logger.Fatal(err)
-		}
-		fmt.Printf("Wrote repo file %s with content:\n%s\n", repoPath, content)
-		return subcommands.ExitSuccess
-	}
-
-	rf, err := unmarshalRepoFile(repoPath)
+	appended, err := api.AddRepo(repoPath, newEntry)
 	if err != nil {
 		logger.Fatal(err)
 	}
-
-	var res []repoEntry
-	for _, re := range rf.repoEntries {
-		if re.Name != newEntry.Name && re.URL != newEntry.URL {
-			res = append(res, re)
-		}
-	}
-
-	res = append(res, newEntry)
-	rf = repoFile{rf.fileName, res}
-
-	if err := writeRepoFile(rf); err != nil {
-		logger.Fatal(err)
+	if !appended {
+		fmt.Printf("Wrote repo file %s with content:\n%s\n", repoPath, content)
+		return subcommands.ExitSuccess
 	}
 
 	fmt.Printf("Appended to repo file %s with the following content:\n%s\n", repoPath, content)
@@ -0,0 +1,440 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The apply subcommand executes a plan written by the -plan flag of install,
+// update or remove.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/googet/v2/api"
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/download"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/install"
+	"github.com/google/googet/v2/priority"
+	"github.com/google/googet/v2/remove"
+	"github.com/google/logger"
+	"github.com/google/subcommands"
+)
+
+const (
+	planInstall = "install"
+	planRemove  = "remove"
+)
+
+// planStep is a single package operation of a plan.
+type planStep struct {
+	Action string
+	// Package is the name.arch.version of the package.
+	Package string
+	// Repo, URL and Checksum are set for installs, RepoSpec is the index
+	// entry the package is installed from.
+	Repo     string           `json:",omitempty"`
+	URL      string           `json:",omitempty"`
+	Checksum string           `json:",omitempty"`
+	RepoSpec *goolib.RepoSpec `json:",omitempty"`
+	// Installed is the version of the package installed when an install was
+	// planned, empty if there was none.
+	Installed string `json:",omitempty"`
+	// Migrates is the name.arch of the installed package an install replaces
+	// because it was renamed, see install.Migrate.
+	Migrates string `json:",omitempty"`
+	// Dependency is set for installs of packages only needed by the
+	// packages of later steps.
+	Dependency bool `json:",omitempty"`
+}
+
+// plan is a resolved list of package operations, in execution order.
+type plan struct {
+	Created time.Time
+	// Command is the command line that wrote the plan, without the -plan
+	// flag, it is run again to re-plan.
+	Command []string
+	Steps   []planStep
+}
+
+func (p *plan) has(action, pkg string) bool {
+	for _, s := range p.Steps {
+		if s.Action == action && s.Package == pkg {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *plan) addInstall(rs goolib.RepoSpec, repo string, dep bool, state client.GooGetState) error {
+	pkg := rs.PackageSpec.String()
+	if p.has(planInstall, pkg) {
+		if !dep {
+			for i := range p.Steps {
+				if p.Steps[i].Action == planInstall && p.Steps[i].Package == pkg {
+					p.Steps[i].Dependency = false
+				}
+			}
+		}
+		return nil
+	}
+	u, err := download.PackageURL(rs, repo)
+	if err != nil {
+		return err
+	}
+	var installed string
+	if ps, err := state.GetPackageState(goolib.PackageInfo{Name: rs.PackageSpec.Name, Arch: rs.PackageSpec.Arch}); err == nil {
+		installed = ps.PackageSpec.Version
+	}
+	p.Steps = append(p.Steps, planStep{Action: planInstall, Package: pkg, Repo: repo, URL: u, Checksum: rs.Checksum, RepoSpec: &rs, Installed: installed, Dependency: dep})
+	return nil
+}
+
+// addInstalls adds pi from repo and the dependencies it needs, dependencies
+// first. pi itself is added even if it is already installed.
+func (p *plan) addInstalls(pi goolib.PackageInfo, repo string, rm client.RepoMap, state client.GooGetState) error {
+	dl, err := install.ListDeps(pi, rm, repo, archs)
+	if err != nil {
+		return err
+	}
+	for i := len(dl) - 1; i >= 0; i-- {
+		di := dl[i]
+		if i > 0 {
+			ni, err := install.NeedsInstallation(di, state)
+			if err != nil {
+				return err
+			}
+			if !ni {
+				continue
+			}
+		}
+		r, err := client.WhatRepo(di, rm)
+		if err != nil {
+			return err
+		}
+		rs, err := client.FindRepoSpec(di, rm[r])
+		if err != nil {
+			return err
+		}
+		if err := p.addInstall(rs, r, i > 0, state); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addMigration adds the install of pi from repo, and the dependencies it
+// needs, in place of the installed package from, which was renamed.
+func (p *plan) addMigration(from, pi goolib.PackageInfo, repo string, rm client.RepoMap, state client.GooGetState) error {
+	if err := p.addInstalls(pi, repo, rm, state); err != nil {
+		return err
+	}
+	p.Steps[len(p.Steps)-1].Migrates = from.Name + "." + from.Arch
+	return nil
+}
+
+// addRemoves adds the removal of the packages in deps in the order they are
+// removed.
+func (p *plan) addRemoves(deps remove.DepMap, state client.GooGetState) error {
+	for _, name := range deps.Order() {
+		ps, err := state.GetPackageState(goolib.PkgNameSplit(name))
+		if err != nil {
+			return err
+		}
+		if pkg := ps.PackageSpec.String(); !p.has(planRemove, pkg) {
+			p.Steps = append(p.Steps, planStep{Action: planRemove, Package: pkg})
+		}
+	}
+	return nil
+}
+
+func (p *plan) String() string {
+	var b bytes.Buffer
+	for _, s := range p.Steps {
+		fmt.Fprintf(&b, "  %s %s", s.Action, s.Package)
+		if s.URL != "" {
+			fmt.Fprintf(&b, " from %s", s.URL)
+		}
+		if s.Migrates != "" {
+			fmt.Fprintf(&b, " replacing renamed %s", s.Migrates)
+		}
+		fmt.Fprintln(&b)
+	}
+	return b.String()
+}
+
+// commandLine returns the command name, the flags set in f except -plan and
+// the arguments.
+func commandLine(f *flag.FlagSet) []string {
+	cl := []string{f.Name()}
+	f.Visit(func(fl *flag.Flag) {
+		if fl.Name != "plan" {
+			cl = append(cl, "-"+fl.Name+"="+fl.Value.String())
+		}
+	})
+	return append(cl, f.Args()...)
+}
+
+func writePlan(path string, p *plan, f *flag.FlagSet) error {
+	p.Created = time.Now().UTC()
+	p.Command = commandLine(f)
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Plan written to %s:\n%s", path, p)
+	return nil
+}
+
+func readPlan(path string) (*plan, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p plan
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("error reading plan %s: %v", path, err)
+	}
+	for _, s := range p.Steps {
+		switch s.Action {
+		case planInstall:
+			if s.RepoSpec == nil || s.RepoSpec.PackageSpec == nil || s.RepoSpec.PackageSpec.String() != s.Package {
+				return nil, fmt.Errorf("error reading plan %s: install of %s has no matching RepoSpec", path, s.Package)
+			}
+		case planRemove:
+		default:
+			return nil, fmt.Errorf("error reading plan %s: unknown action %q", path, s.Action)
+		}
+	}
+	return &p, nil
+}
+
+// repoMap returns a RepoMap holding only the packages installed by p, so
+// that dependencies resolve to the planned versions.
+func (p *plan) repoMap() client.RepoMap {
+	rm := make(client.RepoMap)
+	for _, s := range p.Steps {
+		if s.Action != planInstall {
+			continue
+		}
+		r := rm[s.Repo]
+		r.Packages = append(r.Packages, *s.RepoSpec)
+		rm[s.Repo] = r
+	}
+	return rm
+}
+
+// drift returns the differences between the installed packages and repo
+// indexes assumed by p and the current state and indexes in rm.
+func (p *plan) drift(state client.GooGetState, rm client.RepoMap) []string {
+	var d []string
+	for _, s := range p.Steps {
+		pi := goolib.PkgNameSplit(s.Package)
+		want := pi.Ver
+		if s.Action == planInstall {
+			ps := s.RepoSpec.PackageSpec
+			pi = goolib.PackageInfo{Name: ps.Name, Arch: ps.Arch, Ver: ps.Version}
+			want = s.Installed
+		}
+		var got string
+		if ps, err := state.GetPackageState(goolib.PackageInfo{Name: pi.Name, Arch: pi.Arch}); err == nil {
+			got = ps.PackageSpec.Version
+		}
+		switch {
+		case got == want:
+		case got == "":
+			d = append(d, fmt.Sprintf("%s: %s.%s is no longer installed, the plan assumed version %s", s.Package, pi.Name, pi.Arch, want))
+		case want == "":
+			d = append(d, fmt.Sprintf("%s: %s.%s version %s is now installed, the plan assumed none", s.Package, pi.Name, pi.Arch, got))
+		default:
+			d = append(d, fmt.Sprintf("%s: %s.%s version %s is installed, the plan assumed version %s", s.Package, pi.Name, pi.Arch, got, want))
+		}
+		if s.Action != planInstall {
+			continue
+		}
+		rs, err := client.FindRepoSpec(pi, rm[s.Repo])
+		if err != nil {
+			d = append(d, fmt.Sprintf("%s: no longer available in repo %s", s.Package, s.Repo))
+			continue
+		}
+		if rs.Checksum != s.Checksum {
+			d = append(d, fmt.Sprintf("%s: checksum in repo %s is now %s, the plan has %s", s.Package, s.Repo, rs.Checksum, s.Checksum))
+		}
+	}
+	return d
+}
+
+// replan runs the command that wrote the plan at path again, writing a new
+// plan to path.
+func replan(ctx context.Context, path string, p *plan) subcommands.ExitStatus {
+	if len(p.Command) == 0 {
+		logger.Errorf("Plan %s does not record the command that wrote it", path)
+		return subcommands.ExitFailure
+	}
+	var cmd subcommands.Command
+	switch p.Command[0] {
+	case "install":
+		cmd = &installCmd{}
+	case "update":
+		cmd = &updateCmd{}
+	case "remove":
+		cmd = &removeCmd{}
+	default:
+		logger.Errorf("Cannot re-plan unknown command %q", p.Command[0])
+		return subcommands.ExitFailure
+	}
+	f := flag.NewFlagSet(cmd.Name(), flag.ContinueOnError)
+	cmd.SetFlags(f)
+	if err := f.Parse(p.Command[1:]); err != nil {
+		logger.Errorf("Error parsing command of plan %s: %v", path, err)
+		return subcommands.ExitFailure
+	}
+	if err := f.Set("plan", path); err != nil {
+		logger.Error(err)
+		return subcommands.ExitFailure
+	}
+	fmt.Printf("Re-planning %q.\n", strings.Join(p.Command, " "))
+	return cmd.Execute(ctx, f)
+}
+
+type applyCmd struct {
+	replan bool
+	// stopServices sets install.StopServices.
+	stopServices bool
+}
+
+func (*applyCmd) Name() string     { return "apply" }
+func (*applyCmd) Synopsis() string { return "execute a plan written with the -plan flag" }
+func (*applyCmd) Usage() string {
+	return fmt.Sprintf(`%s apply <plan>:
+	Execute the installs and removals of a plan written by install, update or
+	remove with the -plan flag, in order, stopping at the first failure.
+	Nothing is applied if the installed packages or the repo indexes changed
+	since the plan was written.
+`, filepath.Base(os.Args[0]))
+}
+
+func (cmd *applyCmd) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&cmd.replan, "replan", false, "if the plan is out of date, replace it with a new plan for the same command instead of failing; the new plan is not applied")
+	f.BoolVar(&cmd.stopServices, "stop_services", false, "stop the services using files to replace during the install instead of replacing the files on reboot, Windows only")
+}
+
+func (cmd *applyCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		fmt.Printf("%s\nUsage: %s\n", cmd.Synopsis(), cmd.Usage())
+		return subcommands.ExitUsageError
+	}
+	p, err := readPlan(f.Arg(0))
+	if err != nil {
+		logger.Error(err)
+		return subcommands.ExitFailure
+	}
+	if len(p.Steps) == 0 {
+		fmt.Println("Nothing to do.")
+		return subcommands.ExitSuccess
+	}
+	install.StopServices = cmd.stopServices
+
+	cache := filepath.Join(rootDir, cacheDir)
+	sf := filepath.Join(rootDir, stateFile)
+	state, err := api.ReadState(sf)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	rm := p.repoMap()
+	// Compare against fresh indexes, the cache may predate the plan.
+	srcs := make(map[string]priority.Value)
+	for r := range rm {
+		srcs[r] = priority.Default
+	}
+	if d := p.drift(*state, client.AvailableVersions(ctx, srcs, cache, 0, newDownloader())); len(d) > 0 {
+		fmt.Fprintf(os.Stderr, "The plan %s is out of date:\n  %s\n", f.Arg(0), strings.Join(d, "\n  "))
+		if cmd.replan {
+			if replan(ctx, f.Arg(0), p) == subcommands.ExitSuccess {
+				fmt.Println("Review the new plan and apply it again.")
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, "Not applying it, write a new plan or use -replan.")
+		}
+		return subcommands.ExitFailure
+	}
+	if !noConfirm && !confirmation(fmt.Sprintf("The plan created %s will:\n%sApply it?", p.Created.Format(time.RFC3339), p)) {
+		fmt.Println("Not applying plan.")
+		return subcommands.ExitSuccess
+	}
+	var pis []goolib.PackageInfo
+	for _, s := range p.Steps {
+		if s.Action != planRemove {
+			ps := s.RepoSpec.PackageSpec
+			pis = append(pis, goolib.PackageInfo{Name: ps.Name, Arch: ps.Arch, Ver: ps.Version})
+		}
+	}
+	install.Prefetch(ctx, pis, cache, rm, archs, *state, newDownloader())
+	for _, s := range p.Steps {
+		err := applyStep(ctx, s, cache, rm, state)
+		// A failed step can still have installed files to record.
+		if err := api.WriteState(state, sf); err != nil {
+			logger.Fatalf("Error writing state file: %v", err)
+		}
+		if err != nil {
+			logger.Errorf("Error applying %s of %s, stopping: %v", s.Action, s.Package, err)
+			return subcommands.ExitFailure
+		}
+	}
+	return subcommands.ExitSuccess
+}
+
+func applyStep(ctx context.Context, s planStep, cache string, rm client.RepoMap, state *client.GooGetState) error {
+	if s.Action == planRemove {
+		pi := goolib.PkgNameSplit(s.Package)
+		ps, err := state.GetPackageState(goolib.PackageInfo{Name: pi.Name, Arch: pi.Arch})
+		if err != nil {
+			return err
+		}
+		if ps.PackageSpec.Version != pi.Ver {
+			return fmt.Errorf("version %s is installed, the plan removes %s", ps.PackageSpec.Version, pi.Ver)
+		}
+		fmt.Printf("Removing %s...\n", s.Package)
+		return remove.All(ctx, goolib.PackageInfo{Name: pi.Name, Arch: pi.Arch}, remove.DepMap{}, state, false, newDownloader())
+	}
+
+	ps := s.RepoSpec.PackageSpec
+	pi := goolib.PackageInfo{Name: ps.Name, Arch: ps.Arch, Ver: ps.Version}
+	// Dependencies of an earlier step may already have installed it.
+	if _, err := state.GetPackageState(pi); err == nil {
+		fmt.Printf("%s is already installed\n", s.Package)
+		return nil
+	}
+	if s.Migrates != "" {
+		from := goolib.PkgNameSplit(s.Migrates)
+		return install.Migrate(ctx, goolib.PackageInfo{Name: from.Name, Arch: from.Arch}, pi, s.Repo, cache, rm, archs, state, false, newDownloader())
+	}
+	if err := install.FromRepo(ctx, pi, s.Repo, cache, rm, archs, state, false, newDownloader()); err != nil {
+		return err
+	}
+	if s.Dependency && s.Installed == "" {
+		state.SetInstallReason(pi, client.ReasonDependency)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The audit subcommand lists the installed packages affected by the
+// advisories of the repos.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/googet/v2/api"
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/logger"
+	"github.com/google/subcommands"
+)
+
+type auditCmd struct {
+	sources string
+}
+
+func (*auditCmd) Name() string     { return "audit" }
+func (*auditCmd) Synopsis() string { return "list installed packages with known vulnerabilities" }
+func (*auditCmd) Usage() string {
+	return fmt.Sprintf(`%s audit [-sources repo1,repo2...]:
+	Lists the installed packages affected by the advisories published by the
+	repos, with their severity, the versions that fix them and the latest
+	version available. Exits with an error if any package is affected.
+`, filepath.Base(os.Args[0]))
+}
+
+func (cmd *auditCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.sources, "sources", "", "comma separated list of sources, setting this overrides local .repo files")
+}
+
+// finding is an installed package affected by an advisory.
+type finding struct {
+	pkg       goolib.PackageInfo
+	advisory  client.Advisory
+	available string
+}
+
+func (cmd *auditCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "Excessive arguments")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	state, err := api.ReadState(filepath.Join(rootDir, stateFile))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	repos, err := buildSources(cmd.sources)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if repos == nil {
+		logger.Fatal("No repos defined, create a .repo file or pass using the -sources flag.")
+	}
+
+	downloader := newDownloader()
+	var advisories []client.Advisory
+	for r := range repos {
+		as, err := client.FetchAdvisories(ctx, r, downloader)
+		if err != nil {
+			logger.Errorf("Error reading the advisories of repo %s: %v", r, err)
+			continue
+		}
+		advisories = append(advisories, as...)
+	}
+	rm := client.AvailableVersions(ctx, repos, filepath.Join(rootDir, cacheDir), cacheLife, downloader)
+	findings := audit(*state, advisories, rm)
+	if len(findings) == 0 {
+		fmt.Println("No installed packages are affected by known advisories.")
+		return subcommands.ExitSuccess
+	}
+
+	fmt.Println("Installed packages affected by advisories:")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "  PACKAGE\tADVISORY\tSEVERITY\tFIXED IN\tAVAILABLE")
+	for _, fd := range findings {
+		id := fd.advisory.ID
+		if len(fd.advisory.Aliases) > 0 {
+			id += " (" + strings.Join(fd.advisory.Aliases, ", ") + ")"
+		}
+		fixed := strings.Join(fd.advisory.Fixed(fd.pkg.Name), ", ")
+		if fixed == "" {
+			fixed = "none"
+		}
+		available := fd.available
+		if available == "" {
+			available = "none"
+		}
+		fmt.Fprintf(tw, "  %s.%s %s\t%s\t%s\t%s\t%s\n", fd.pkg.Name, fd.pkg.Arch, fd.pkg.Ver, id, fd.advisory.SeverityName(), fixed, available)
+	}
+	tw.Flush()
+	return subcommands.ExitFailure
+}
+
+// audit returns the packages of state affected by advisories, sorted by
+// package, each with the latest version in rm not affected by the advisory,
+// if any is newer.
+func audit(state client.GooGetState, advisories []client.Advisory, rm client.RepoMap) []finding {
+	var fs []finding
+	seen := make(map[string]bool)
+	for _, ps := range state {
+		pi := goolib.PackageInfo{Name: ps.PackageSpec.Name, Arch: ps.PackageSpec.Arch, Ver: ps.PackageSpec.Version}
+		for _, a := range advisories {
+			// Repos may mirror the same advisories.
+			key := pi.Name + "." + pi.Arch + " " + a.ID
+			if seen[key] || !a.Affects(pi.Name, pi.Ver) {
+				continue
+			}
+			seen[key] = true
+			fs = append(fs, finding{pkg: pi, advisory: a, available: fixedVersion(pi, a, rm)})
+		}
+	}
+	sort.Slice(fs, func(i, j int) bool {
+		if fs[i].pkg.Name != fs[j].pkg.Name {
+			return fs[i].pkg.Name < fs[j].pkg.Name
+		}
+		return fs[i].advisory.ID < fs[j].advisory.ID
+	})
+	return fs
+}
+
+// fixedVersion returns the latest version of pi in rm if it is newer than
+// pi and not affected by a.
+func fixedVersion(pi goolib.PackageInfo, a client.Advisory, rm client.RepoMap) string {
+	v, _, _, err := client.FindRepoLatest(goolib.PackageInfo{Name: pi.Name, Arch: pi.Arch}, rm, []string{pi.Arch})
+	if err != nil || a.Affects(pi.Name, v) {
+		return ""
+	}
+	if c, err := goolib.Compare(v, pi.Ver); err != nil || c <= 0 {
+		return ""
+	}
+	return v
+}
@@ -34,12 +34,13 @@ import (
 type availableCmd struct {
 	info    bool
 	sources string
+	tags    string
 }
 
 func (*availableCmd) Name() string     { return "available" }
 func (*availableCmd) Synopsis() string { return "list available packages" }
 func (*availableCmd) Usage() string {
-	return fmt.Sprintf(`%s available [-sources repo1,repo2...] [-info] [<initial>]:
+	return fmt.Sprintf(`%s available [-sources repo1,repo2...] [-info] [-tag tag1,tag2...] [<initial>]:
 	List available packages beginning with an initial string,
 	if no initial string is provided all available packages will be listed.
 `, filepath.Base(os.Args[0]))
@@ -48,6 +49,7 @@ func (*availableCmd) Usage() string {
 func (cmd *availableCmd) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&cmd.info, "info", false, "display package info")
 	f.StringVar(&cmd.sources, "sources", "", "comma separated list of sources, setting this overrides local .repo files")
+	f.StringVar(&cmd.tags, "tag", "", "comma separated list of tags, KEY or KEY=VALUE, only list packages having one of them")
 }
 
 func (cmd *availableCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -73,10 +75,14 @@ func (cmd *availableCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...inte
 		logger.Fatal("No repos defined, create a .repo file or pass using the -sources flag.")
 	}
 
+	tags := splitTags(cmd.tags)
 	m := make(map[string][]string)
-	rm := client.AvailableVersions(ctx, repos, filepath.Join(rootDir, cacheDir), cacheLife, proxyServer)
+	rm := client.AvailableVersions(ctx, repos, filepath.Join(rootDir, cacheDir), cacheLife, newDownloader())
 	for r, repo := range rm {
 		for _, p := range repo.Packages {
+			if tags != nil && !p.PackageSpec.HasTag(tags...) {
+				continue
+			}
 			m[r] = append(m[r], p.PackageSpec.Name+"."+p.PackageSpec.Arch+"."+p.PackageSpec.Version)
 		}
 	}
@@ -110,6 +116,18 @@ func (cmd *availableCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...inte
 	return exitCode
 }
 
+// splitTags splits the comma separated list of tags s, returning nil if s is
+// empty.
+func splitTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
 func repo(pi goolib.PackageInfo, rm client.RepoMap) {
 	for r, repo := range rm {
 		for _, p := range repo.Packages {
@@ -21,8 +21,11 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/google/googet/v2/api"
+	"github.com/google/googet/v2/download"
 	"github.com/google/googet/v2/goolib"
 	"github.com/google/googet/v2/oswrap"
+	"github.com/google/googet/v2/remove"
 	"github.com/google/logger"
 	"github.com/google/subcommands"
 )
@@ -33,13 +36,13 @@ type cleanCmd struct {
 }
 
 func (*cleanCmd) Name() string     { return "clean" }
-func (*cleanCmd) Synopsis() string { return "clean the cache directory" }
+func (*cleanCmd) Synopsis() string { return "clean the cache and trash directories" }
 func (*cleanCmd) Usage() string {
 	return fmt.Sprintf("%s clean\n", filepath.Base(os.Args[0]))
 }
 
 func (cmd *cleanCmd) SetFlags(f *flag.FlagSet) {
-	f.BoolVar(&cmd.all, "all", false, "clear out the entire cache directory")
+	f.BoolVar(&cmd.all, "all", false, "clear out the entire cache and trash directories")
 	f.StringVar(&cmd.packages, "packages", "", "comma separated list of packages to clear out of the cache")
 }
 
@@ -55,18 +58,36 @@ func (cmd *cleanCmd) Execute(_ context.Context, _ *flag.FlagSet, _ ...interface{
 		fmt.Println("Removing all files and directories in cachedir that don't correspond to a currently installed package.")
 		cleanOld()
 	}
+	if remove.TrashDir != "" {
+		age := trashLife
+		if cmd.all {
+			fmt.Println("Removing all files in the trash directory.")
+			age = 0
+		}
+		if err := remove.PurgeTrash(age); err != nil {
+			logger.Error(err)
+		}
+	}
 	return subcommands.ExitSuccess
 }
 
 func cleanPackages(pl []string) {
-	state, err := readState(filepath.Join(rootDir, stateFile))
+	state, err := api.ReadState(filepath.Join(rootDir, stateFile))
 	if err != nil {
 		logger.Fatal(err)
 	}
 
+	// Packages with identical contents share a cache entry, which is kept
+	// while a package not cleaned out uses it.
+	keep := make(map[string]bool)
+	for _, pkg := range *state {
+		if !goolib.ContainsString(pkg.PackageSpec.Name, pl) {
+			keep[pkg.LocalPath] = true
+		}
+	}
 	for _, pkg := range *state {
-		if goolib.ContainsString(pkg.PackageSpec.Name, pl) {
-			if err := oswrap.RemoveAll(pkg.LocalPath); err != nil {
+		if goolib.ContainsString(pkg.PackageSpec.Name, pl) && !keep[pkg.LocalPath] {
+			if err := download.RemoveCached(pkg.LocalPath); err != nil {
 				logger.Error(err)
 			}
 		}
@@ -88,14 +109,14 @@ func clean(il []string) {
 }
 
 func cleanOld() {
-	state, err := readState(filepath.Join(rootDir, stateFile))
+	state, err := api.ReadState(filepath.Join(rootDir, stateFile))
 	if err != nil {
 		logger.Fatal(err)
 	}
 
 	var il []string
 	for _, pkg := range *state {
-		il = append(il, pkg.LocalPath)
+		il = append(il, pkg.LocalPath, download.NamePath(pkg.LocalPath))
 	}
 	clean(il)
 }
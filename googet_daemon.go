@@ -0,0 +1,273 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The daemon subcommand serves the JSON management API of the daemon
+// package, over gRPC, on a unix domain socket in the root directory. Callers are
+// identified from the credentials of the connection, as by the helper, and
+// authorized by the helper policy of googet.conf. With -http_addr it also
+// serves the REST API of the daemon package on a loopback address, where
+// operations need the token the daemon writes to the root directory.
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/googet/v2/api"
+	"github.com/google/googet/v2/daemon"
+	"github.com/google/googet/v2/install"
+	"github.com/google/logger"
+	"github.com/google/subcommands"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	daemonSocket = "daemon.sock"
+	daemonToken  = "daemon.token"
+)
+
+// daemonCommands maps the methods of the service to the helper commands
+// whose policy applies to them.
+var daemonCommands = map[string]string{
+	"ListInstalled": "installed",
+	"GetStatus":     "installed",
+	"Install":       "install",
+	"Update":        "update",
+	"Remove":        "remove",
+}
+
+// callerAddr is the remote address of a daemon connection, identifying the
+// caller.
+type callerAddr struct {
+	uid  string
+	gids []string
+}
+
+func (callerAddr) Network() string  { return "unix" }
+func (a callerAddr) String() string { return "uid " + a.uid }
+
+// callerConn is a connection with the address of its caller.
+type callerConn struct {
+	net.Conn
+	addr callerAddr
+}
+
+func (c callerConn) RemoteAddr() net.Addr { return c.addr }
+
+// callerListener identifies the caller of each connection, connections of
+// callers that can't be identified are closed.
+type callerListener struct {
+	net.Listener
+}
+
+func (l callerListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		uid, gids, err := peerIDs(c)
+		if err != nil {
+			logger.Errorf("GooGet daemon: error identifying caller: %v", err)
+			c.Close()
+			continue
+		}
+		return callerConn{c, callerAddr{uid, gids}}, nil
+	}
+}
+
+// authorize returns an error unless p allows the caller of ctx to call
+// method, the full gRPC method name.
+func authorize(ctx context.Context, p *helperPolicy, method string) error {
+	pr, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "unknown caller")
+	}
+	a, ok := pr.Addr.(callerAddr)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "unknown caller")
+	}
+	// Root can run any command with googet itself.
+	if privilegedCaller(a.uid, a.gids) {
+		return nil
+	}
+	cmd := daemonCommands[method[strings.LastIndex(method, "/")+1:]]
+	if err := p.allow(a.uid, a.gids, []string{cmd}); err != nil {
+		logger.Errorf("GooGet daemon: %v", err)
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
+
+// writeDaemonToken writes a new random token to path, readable only by its
+// owner, or by SYSTEM and administrators on Windows, and returns it.
+func writeDaemonToken(path string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if err := writePrivateFile(path, []byte(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+type daemonCmd struct {
+	socket   string
+	httpAddr string
+}
+
+func (*daemonCmd) Name() string { return "daemon" }
+func (*daemonCmd) Synopsis() string {
+	return "serve the management APIs, as a privileged service"
+}
+func (*daemonCmd) Usage() string {
+	return fmt.Sprintf(`%s daemon [-socket <path>] [-http_addr <host:port>]:
+	Serve the GooGet JSON management API, over gRPC without protobuf, on
+	%s in the root directory, for Go management agents using the daemon
+	package. Root may call any method, other callers those
+	allowed by the helperusers, helpergroups and helpercommands settings of
+	googet.conf, on Windows by user and group SIDs. On Windows the socket
+	needs Windows 10 1803 or later.
+	With -http_addr, also serve the REST API on that loopback address, its
+	OpenAPI definition at /openapi.json. Install, update and remove need the
+	token written to %s in the root directory as bearer token.
+`, filepath.Base(os.Args[0]), daemonSocket, daemonToken)
+}
+
+func (cmd *daemonCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.socket, "socket", "", "path of the unix domain socket to listen on, "+daemonSocket+" in the root directory if empty")
+	f.StringVar(&cmd.httpAddr, "http_addr", "", "loopback address to serve the REST API on, such as localhost:8642, not served if empty")
+}
+
+func (cmd *daemonCmd) Execute(ctx context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	c := &api.Client{
+		Root:         rootDir,
+		Archs:        archs,
+		CacheLife:    cacheLife,
+		ProxyServer:  proxyServer,
+		AllowedRepos: api.AllowedRepos,
+		Signatures:   install.DefaultSignatures,
+		Cosign:       install.DefaultCosign,
+	}
+	s := daemon.NewServer(c, version)
+
+	// Serve until googet is interrupted, which cancels the running
+	// operation.
+	errc := make(chan error, 2)
+	var n int
+	gs, l, err := cmd.grpcServer(s)
+	if err != nil {
+		logger.Error(err)
+		return subcommands.ExitFailure
+	}
+	go func() {
+		<-ctx.Done()
+		gs.Stop()
+	}()
+	n++
+	go func() { errc <- gs.Serve(l) }()
+	if cmd.httpAddr != "" {
+		hs, l, err := cmd.httpServer(s)
+		if err != nil {
+			logger.Error(err)
+			return subcommands.ExitFailure
+		}
+		go func() {
+			<-ctx.Done()
+			hs.Close()
+		}()
+		n++
+		go func() { errc <- hs.Serve(l) }()
+	}
+	es := subcommands.ExitSuccess
+	for ; n > 0; n-- {
+		if err := <-errc; err != nil && ctx.Err() == nil {
+			logger.Error(err)
+			es = subcommands.ExitFailure
+		}
+	}
+	return es
+}
+
+// httpServer returns the REST server of s and its listener.
+func (cmd *daemonCmd) httpServer(s *daemon.Server) (*http.Server, net.Listener, error) {
+	token, err := writeDaemonToken(filepath.Join(rootDir, daemonToken))
+	if err != nil {
+		return nil, nil, err
+	}
+	l, err := daemon.ListenLocal(cmd.httpAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Infof("GooGet daemon REST API listening on %s", l.Addr())
+	return &http.Server{Handler: s.Handler(token)}, l, nil
+}
+
+// grpcServer returns the gRPC server of s and its listener.
+func (cmd *daemonCmd) grpcServer(s *daemon.Server) (*grpc.Server, net.Listener, error) {
+	p, err := newHelperPolicy(helperUsers, helperGroups, helperCommands)
+	if err != nil {
+		return nil, nil, err
+	}
+	sock := cmd.socket
+	if sock == "" {
+		sock = filepath.Join(rootDir, daemonSocket)
+	}
+	if err := os.Remove(sock); err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Anyone may connect, callers are authorized from their credentials.
+	if err := shareSocket(sock); err != nil {
+		l.Close()
+		return nil, nil, err
+	}
+
+	gs := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+			if err := authorize(ctx, p, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return h(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+			if err := authorize(ss.Context(), p, info.FullMethod); err != nil {
+				return err
+			}
+			return h(srv, ss)
+		}),
+	)
+	s.Register(gs)
+	logger.Infof("GooGet daemon listening on %s", sock)
+	return gs, callerListener{l}, nil
+}
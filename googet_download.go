@@ -58,7 +58,7 @@ func (cmd *downloadCmd) Execute(ctx context.Context, flags *flag.FlagSet, _ ...i
 		logger.Fatal("No repos defined, create a .repo file or pass using the -sources flag.")
 	}
 
-	rm := client.AvailableVersions(ctx, repos, filepath.Join(rootDir, cacheDir), cacheLife, proxyServer)
+	rm := client.AvailableVersions(ctx, repos, filepath.Join(rootDir, cacheDir), cacheLife, newDownloader())
 	exitCode := subcommands.ExitSuccess
 
 	dir := cmd.downloadDir
@@ -72,7 +72,7 @@ func (cmd *downloadCmd) Execute(ctx context.Context, flags *flag.FlagSet, _ ...i
 	for _, arg := range flags.Args() {
 		pi := goolib.PkgNameSplit(arg)
 		if pi.Ver == "" {
-			if _, err := download.Latest(ctx, pi.Name, dir, rm, archs, proxyServer); err != nil {
+			if _, err := download.Latest(ctx, pi.Name, dir, rm, archs, newDownloader()); err != nil {
 				logger.Errorf("error downloading %s, %v", pi.Name, err)
 				exitCode = subcommands.ExitFailure
 			}
@@ -97,7 +97,7 @@ func (cmd *downloadCmd) Execute(ctx context.Context, flags *flag.FlagSet, _ ...i
 			exitCode = subcommands.ExitFailure
 			continue
 		}
-		if _, err := download.FromRepo(ctx, rs, repo, dir, proxyServer); err != nil {
+		if _, err := download.FromRepo(ctx, rs, repo, dir, newDownloader()); err != nil {
 			logger.Errorf("error downloading %s.%s %s, %v", pi.Name, pi.Arch, pi.Ver, err)
 			exitCode = subcommands.ExitFailure
 			continue
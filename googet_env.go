@@ -0,0 +1,123 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/subcommands"
+)
+
+// envPrefix starts the names of the environment variables setting flags and
+// googet.conf settings, for example GOOGET_NOCONFIRM or GOOGET_CACHELIFE.
+const envPrefix = "GOOGET_"
+
+// envAliases maps environment variables to the googet.conf setting they set
+// when their name is not the name of the setting.
+var envAliases = map[string]string{"GOOGET_PROXY": "proxyserver"}
+
+// envSkipFlags are the flags not set from the environment.
+var envSkipFlags = []string{"version"}
+
+// envConf returns the googet.conf settings set in environ, the values of
+// settings other than strings are parsed as YAML.
+func envConf(environ []string) (*conf, error) {
+	fields := make(map[string]reflect.Kind)
+	t := reflect.TypeOf(conf{})
+	for i := 0; i < t.NumField(); i++ {
+		fields[strings.ToLower(t.Field(i).Name)] = t.Field(i).Type.Kind()
+	}
+
+	m := make(map[string]interface{})
+	for _, kv := range environ {
+		k, v, _ := strings.Cut(kv, "=")
+		k = strings.ToUpper(k)
+		name, ok := envAliases[k]
+		if !ok {
+			if !strings.HasPrefix(k, envPrefix) {
+				continue
+			}
+			name = strings.ToLower(strings.TrimPrefix(k, envPrefix))
+		}
+		kind, ok := fields[name]
+		if !ok {
+			continue
+		}
+		if kind == reflect.String {
+			m[name] = v
+			continue
+		}
+		var val interface{}
+		if err := yaml.Unmarshal([]byte(v), &val); err != nil {
+			return nil, fmt.Errorf("%s: %v", k, err)
+		}
+		m[name] = val
+	}
+
+	var c conf
+	if len(m) == 0 {
+		return &c, nil
+	}
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("settings from the environment: %v", err)
+	}
+	return &c, nil
+}
+
+// flagsFromEnv sets the flags of fs not given on the command line from their
+// environment variable, GOOGET_ followed by the flag name in upper case.
+func flagsFromEnv(fs *flag.FlagSet) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := envPrefix + strings.ToUpper(f.Name)
+		v, ok := os.LookupEnv(name)
+		if !ok || set[f.Name] || err != nil || goolib.ContainsString(f.Name, envSkipFlags) {
+			return
+		}
+		if e := fs.Set(f.Name, v); e != nil {
+			err = fmt.Errorf("%s: %v", name, e)
+		}
+	})
+	return err
+}
+
+// envCmd is a command whose flags not given on the command line are set from
+// the environment. It does not run when the lock stayed held.
+type envCmd struct {
+	subcommands.Command
+}
+
+func (c envCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if lockHeld {
+		return exitLockHeld
+	}
+	if err := flagsFromEnv(f); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return subcommands.ExitUsageError
+	}
+	return c.Command.Execute(ctx, f, args...)
+}
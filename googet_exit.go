@@ -0,0 +1,105 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/priority"
+	"github.com/google/subcommands"
+)
+
+// Exit statuses of install, update, remove and verify with
+// -detailed_exit_codes, besides ExitSuccess and exitRebootRequired. Without
+// it commands exit with ExitFailure if any package failed, ExitSuccess
+// otherwise.
+const (
+	// exitNothingToDo means the packages were already in the requested
+	// state.
+	exitNothingToDo subcommands.ExitStatus = 10
+	// exitPartialFailure means some packages failed and others succeeded.
+	exitPartialFailure subcommands.ExitStatus = 11
+	// exitLockHeld means another GooGet run held the lock for too long.
+	exitLockHeld subcommands.ExitStatus = 12
+	// exitRepoUnreachable means a repo could not be read and no package
+	// succeeded.
+	exitRepoUnreachable subcommands.ExitStatus = 13
+	// exitVerificationFailed means a package did not match its checksum,
+	// manifest or signature, or failed googet verify, and no package
+	// succeeded.
+	exitVerificationFailed subcommands.ExitStatus = 14
+)
+
+// detailedExitCodes makes commands exit with the statuses above.
+var detailedExitCodes bool
+
+// errLockTimeout is returned by obtainLock when the lock stays held.
+var errLockTimeout = errors.New("timed out waiting for lock")
+
+// lockHeld is set when the lock stayed held with -detailed_exit_codes, the
+// command then exits with exitLockHeld instead of running.
+var lockHeld bool
+
+// outcome tallies the results of a command acting on several packages.
+type outcome struct {
+	done, failed int
+	verification bool
+	unreachable  bool
+}
+
+func (o *outcome) succeed() { o.done++ }
+
+// fail records a failure caused by err, which may be nil.
+func (o *outcome) fail(err error) {
+	o.failed++
+	var ve *goolib.VerificationError
+	if errors.As(err, &ve) {
+		o.verification = true
+	}
+}
+
+// failVerification records a package failing verification.
+func (o *outcome) failVerification() {
+	o.failed++
+	o.verification = true
+}
+
+// readRepos records whether some of repos could not be read, AvailableVersions
+// leaves them out of rm.
+func (o *outcome) readRepos(repos map[string]priority.Value, rm client.RepoMap) {
+	o.unreachable = len(rm) < len(repos)
+}
+
+// status returns the exit status of the command.
+func (o *outcome) status() subcommands.ExitStatus {
+	switch {
+	case !detailedExitCodes && o.failed > 0:
+		return subcommands.ExitFailure
+	case !detailedExitCodes:
+		return subcommands.ExitSuccess
+	case o.failed > 0 && o.done > 0:
+		return exitPartialFailure
+	case o.verification:
+		return exitVerificationFailed
+	case o.unreachable && o.done == 0:
+		return exitRepoUnreachable
+	case o.failed > 0:
+		return subcommands.ExitFailure
+	case o.done == 0:
+		return exitNothingToDo
+	}
+	return subcommands.ExitSuccess
+}
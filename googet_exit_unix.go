@@ -0,0 +1,24 @@
+//go:build linux || darwin
+// +build linux darwin
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import "github.com/google/subcommands"
+
+// exitRebootRequired is the exit status of installs that succeeded but
+// require a reboot to complete. Exit statuses are truncated to 8 bits here, so
+// it follows the other statuses of googet_exit.go rather than Windows' 3010.
+const exitRebootRequired subcommands.ExitStatus = 15
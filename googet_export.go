@@ -0,0 +1,228 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The export subcommand writes the installed packages in the package list
+// formats of other Windows package managers, as written by winget export
+// and choco export and read by winget import and choco install.
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/googet/v2/api"
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/logger"
+	"github.com/google/subcommands"
+)
+
+const (
+	exportWinget     = "winget"
+	exportChocolatey = "chocolatey"
+)
+
+type exportCmd struct {
+	format string
+	output string
+}
+
+func (*exportCmd) Name() string { return "export" }
+func (*exportCmd) Synopsis() string {
+	return "export installed packages for winget or Chocolatey"
+}
+func (*exportCmd) Usage() string {
+	return fmt.Sprintf(`%s export -format winget|chocolatey [-output <file>]:
+	Write the installed packages as a winget package list, as read by
+	winget import, or as a Chocolatey packages.config, as read by choco
+	install. Packages are identified by their GooGet name and versioned by
+	the version of the packaged software, without the GooGet epoch and
+	release. A package installed for several archs is exported once, with
+	its highest version.
+`, filepath.Base(os.Args[0]))
+}
+
+func (cmd *exportCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.format, "format", "", "format to export to, winget or chocolatey")
+	f.StringVar(&cmd.output, "output", "", "file to write to, standard output if empty")
+}
+
+func (cmd *exportCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "Excessive arguments")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	var export func(io.Writer, client.GooGetState) error
+	switch cmd.format {
+	case exportWinget:
+		export = func(w io.Writer, state client.GooGetState) error {
+			return exportWingetList(w, state, time.Now())
+		}
+	case exportChocolatey:
+		export = exportChocolateyConfig
+	default:
+		fmt.Fprintf(os.Stderr, "-format must be %s or %s\n", exportWinget, exportChocolatey)
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	state, err := api.ReadState(filepath.Join(rootDir, stateFile))
+	if err != nil {
+		logger.Fatal(err)
+	}
+	w := os.Stdout
+	if cmd.output != "" {
+		if w, err = os.Create(cmd.output); err != nil {
+			logger.Fatal(err)
+		}
+	}
+	err = export(w, *state)
+	if cmd.output != "" {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		logger.Errorf("Error exporting installed packages: %v", err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// exportVersion returns the version of the software packaged with the GooGet
+// version v, v itself if it can't be parsed.
+func exportVersion(v string) string {
+	pv, err := goolib.ParseVersion(v)
+	if err != nil {
+		return v
+	}
+	return pv.Semver.String()
+}
+
+// exportedPackage is a package as identified by other package managers.
+type exportedPackage struct {
+	id, version string
+}
+
+// exportPackages returns the packages of state sorted by name. Other package
+// managers have no arch in their ids, so a package installed for several
+// archs is exported once, with the highest of its versions.
+func exportPackages(state client.GooGetState) []exportedPackage {
+	latest := make(map[string]string)
+	for _, ps := range state {
+		n, v := ps.PackageSpec.Name, ps.PackageSpec.Version
+		if lv, ok := latest[n]; ok {
+			if c, err := goolib.Compare(v, lv); err != nil || c <= 0 {
+				continue
+			}
+		}
+		latest[n] = v
+	}
+	var eps []exportedPackage
+	for n, v := range latest {
+		eps = append(eps, exportedPackage{n, exportVersion(v)})
+	}
+	sort.Slice(eps, func(i, j int) bool { return eps[i].id < eps[j].id })
+	return eps
+}
+
+// The winget package list, as defined by
+// https://aka.ms/winget-packages.schema.2.0.json.
+type wingetList struct {
+	Schema       string `json:"$schema"`
+	CreationDate string
+	Sources      []wingetSource
+}
+
+type wingetSource struct {
+	Packages      []wingetPackage
+	SourceDetails wingetSourceDetails
+}
+
+type wingetPackage struct {
+	PackageIdentifier string
+	Version           string `json:",omitempty"`
+}
+
+type wingetSourceDetails struct {
+	Argument   string
+	Identifier string
+	Name       string
+	Type       string
+}
+
+// wingetCommunity is the default source of winget, the one winget import
+// looks the packages up in.
+var wingetCommunity = wingetSourceDetails{
+	Argument:   "https://cdn.winget.microsoft.com/cache",
+	Identifier: "Microsoft.Winget.Source_8wekyb3d8bbwe",
+	Name:       "winget",
+	Type:       "Microsoft.PreIndexed.Package",
+}
+
+// exportWingetList writes the packages of state to w as a winget package
+// list created at now.
+func exportWingetList(w io.Writer, state client.GooGetState, now time.Time) error {
+	src := wingetSource{Packages: []wingetPackage{}, SourceDetails: wingetCommunity}
+	for _, ep := range exportPackages(state) {
+		src.Packages = append(src.Packages, wingetPackage{PackageIdentifier: ep.id, Version: ep.version})
+	}
+	l := wingetList{
+		Schema:       "https://aka.ms/winget-packages.schema.2.0.json",
+		CreationDate: now.Format(time.RFC3339),
+		Sources:      []wingetSource{src},
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(l)
+}
+
+// The Chocolatey packages.config.
+type chocolateyConfig struct {
+	XMLName  xml.Name            `xml:"packages"`
+	Packages []chocolateyPackage `xml:"package"`
+}
+
+type chocolateyPackage struct {
+	ID      string `xml:"id,attr"`
+	Version string `xml:"version,attr,omitempty"`
+}
+
+// exportChocolateyConfig writes the packages of state to w as a Chocolatey
+// packages.config.
+func exportChocolateyConfig(w io.Writer, state client.GooGetState) error {
+	var c chocolateyConfig
+	for _, ep := range exportPackages(state) {
+		c.Packages = append(c.Packages, chocolateyPackage{ID: ep.id, Version: ep.version})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(c); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
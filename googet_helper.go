@@ -0,0 +1,381 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The helper subcommand is a privileged service that runs GooGet commands for
+// unprivileged users. It listens on a unix domain socket in the root
+// directory, identifies each caller from the credentials of the connection
+// and runs the command, if the policy in googet.conf allows it, as a child
+// process with the privileges of the helper, in the root directory. A GooGet run that cannot take
+// the lock because it lacks privileges sends its command to the helper
+// instead, see runThroughHelper.
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/logger"
+	"github.com/google/subcommands"
+)
+
+const helperSocket = "helper.sock"
+
+// helperLabel is the label of the launchd daemon running the helper on macOS.
+const helperLabel = "com.google.googet.helper"
+
+// helperDefaultCommands are the commands the helper runs if helpercommands is
+// not set in googet.conf.
+var helperDefaultCommands = []string{"install", "remove", "update", "verify", "installed", "latest", "available", "listrepos", "size"}
+
+// Helper policy, set from googet.conf.
+var (
+	helperUsers    []string
+	helperGroups   []string
+	helperCommands []string
+)
+
+// helperRequest is sent by the client as the first line on the connection,
+// everything after it is the standard input of the command.
+type helperRequest struct {
+	Args      []string
+	NoConfirm bool
+	Verbose   bool
+}
+
+// helperMessage is a line sent by the helper, the output of the command until
+// Done is set.
+type helperMessage struct {
+	Output []byte `json:",omitempty"`
+	Done   bool   `json:",omitempty"`
+	Exit   int    `json:",omitempty"`
+	Error  string `json:",omitempty"`
+}
+
+// helperPathFlags are the flags of helper commands naming files, which the
+// helper would read or write with its own privileges.
+var helperPathFlags = []string{"sources", "report_file", "plan"}
+
+// Well known SIDs of privileged Windows callers.
+const (
+	sidSystem         = "S-1-5-18"
+	sidAdministrators = "S-1-5-32-544"
+)
+
+// privilegedCaller reports whether the user uid, member of gids, already has
+// the privileges of the helper: root, SYSTEM or an elevated administrator.
+func privilegedCaller(uid string, gids []string) bool {
+	return uid == "0" || uid == sidSystem || goolib.ContainsString(sidAdministrators, gids)
+}
+
+// helperPolicy decides which users may run which commands through the
+// helper. Users and groups are held as numeric IDs, or SIDs on Windows.
+type helperPolicy struct {
+	users, groups map[string]bool
+	commands      []string
+}
+
+// newHelperPolicy resolves the user and group names in the configured policy.
+func newHelperPolicy(users, groups, commands []string) (*helperPolicy, error) {
+	p := &helperPolicy{users: make(map[string]bool), groups: make(map[string]bool), commands: commands}
+	if len(p.commands) == 0 {
+		p.commands = helperDefaultCommands
+	}
+	for _, u := range users {
+		if lu, err := user.Lookup(u); err == nil {
+			u = lu.Uid
+		} else if _, err := user.LookupId(u); err != nil {
+			return nil, fmt.Errorf("unknown helper user %q", u)
+		}
+		p.users[u] = true
+	}
+	for _, g := range groups {
+		if lg, err := user.LookupGroup(g); err == nil {
+			g = lg.Gid
+		} else if _, err := user.LookupGroupId(g); err != nil {
+			return nil, fmt.Errorf("unknown helper group %q", g)
+		}
+		p.groups[g] = true
+	}
+	return p, nil
+}
+
+// allow returns an error if the user uid, member of gids, may not run args.
+func (p *helperPolicy) allow(uid string, gids []string, args []string) error {
+	ok := privilegedCaller(uid, gids) || p.users[uid]
+	for _, g := range gids {
+		ok = ok || p.groups[g]
+	}
+	if !ok {
+		return fmt.Errorf("user %s is not allowed to use the GooGet helper", uid)
+	}
+	if len(args) == 0 || !goolib.ContainsString(args[0], p.commands) {
+		return fmt.Errorf("command %q is not allowed through the GooGet helper, allowed commands are %s", strings.Join(args, " "), strings.Join(p.commands, ", "))
+	}
+	for _, a := range args[1:] {
+		if f := strings.TrimLeft(a, "-"); a != f {
+			name := strings.SplitN(f, "=", 2)[0]
+			if goolib.ContainsString(name, helperPathFlags) {
+				return fmt.Errorf("the -%s flag is not allowed through the GooGet helper", name)
+			}
+			continue
+		}
+		// Package files and other paths would be read with the privileges
+		// of the helper, only packages from the repos can be installed.
+		if strings.HasSuffix(a, ".goo") || strings.ContainsAny(a, `/\`) {
+			return fmt.Errorf("local path %q is not allowed through the GooGet helper", a)
+		}
+	}
+	return nil
+}
+
+// helperRun runs args as a GooGet child process, it is replaced in tests.
+var helperRun = func(ctx context.Context, req helperRequest, stdin io.Reader, out io.Writer) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	args := []string{"-root", rootDir, fmt.Sprintf("-noconfirm=%t", req.NoConfirm), fmt.Sprintf("-verbose=%t", req.Verbose)}
+	c := exec.CommandContext(ctx, exe, append(args, req.Args...)...)
+	// The client's working directory is not trusted, relative paths are
+	// resolved against the root directory.
+	c.Dir, c.Stdout, c.Stderr = rootDir, out, out
+	// With a plain reader as Stdin, Wait would block until the client closes
+	// its input.
+	w, err := c.StdinPipe()
+	if err != nil {
+		return 0, err
+	}
+	if err := c.Start(); err != nil {
+		return 0, err
+	}
+	go func() {
+		io.Copy(w, stdin)
+		w.Close()
+	}()
+	err = c.Wait()
+	if e, ok := err.(*exec.ExitError); ok {
+		return e.ExitCode(), nil
+	}
+	return 0, err
+}
+
+// helperWriter sends everything written to it as output messages.
+type helperWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (w *helperWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.enc.Encode(helperMessage{Output: b}); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// serveHelper runs the command requested on c if p allows it.
+func serveHelper(ctx context.Context, c net.Conn, p *helperPolicy) {
+	defer c.Close()
+	enc := json.NewEncoder(c)
+	fail := func(err error) {
+		logger.Errorf("GooGet helper: %v", err)
+		enc.Encode(helperMessage{Error: err.Error()})
+	}
+	uid, gids, err := peerIDs(c)
+	if err != nil {
+		fail(fmt.Errorf("error identifying caller: %v", err))
+		return
+	}
+	br := bufio.NewReader(c)
+	line, err := br.ReadBytes('\n')
+	var req helperRequest
+	if err == nil {
+		err = json.Unmarshal(line, &req)
+	}
+	if err != nil {
+		fail(fmt.Errorf("error reading request of user %s: %v", uid, err))
+		return
+	}
+	// Only allowed commands are used as metric labels.
+	name := "other"
+	if len(req.Args) > 0 && goolib.ContainsString(req.Args[0], p.commands) {
+		name = req.Args[0]
+	}
+	if err := p.allow(uid, gids, req.Args); err != nil {
+		helperStats.command(name, resultRejected, 0)
+		fail(err)
+		return
+	}
+	logger.Infof("GooGet helper: running %q for user %s", strings.Join(req.Args, " "), uid)
+	start := time.Now()
+	code, err := helperRun(ctx, req, br, &helperWriter{enc: enc})
+	if err != nil {
+		helperStats.command(name, resultFailure, time.Since(start))
+		fail(fmt.Errorf("error running %q for user %s: %v", strings.Join(req.Args, " "), uid, err))
+		return
+	}
+	result := resultSuccess
+	if code != 0 {
+		result = resultFailure
+	}
+	helperStats.command(name, result, time.Since(start))
+	logger.Infof("GooGet helper: %q for user %s exited with %d", strings.Join(req.Args, " "), uid, code)
+	enc.Encode(helperMessage{Done: true, Exit: code})
+}
+
+// runThroughHelper sends args to the helper listening in the root directory,
+// forwarding standard input and printing the output of the command, and
+// returns its exit code.
+func runThroughHelper(args []string) (int, error) {
+	c, err := net.Dial("unix", filepath.Join(rootDir, helperSocket))
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	if err := json.NewEncoder(c).Encode(helperRequest{Args: args, NoConfirm: noConfirm, Verbose: verbose}); err != nil {
+		return 0, err
+	}
+	go io.Copy(c, os.Stdin)
+	dec := json.NewDecoder(c)
+	for {
+		var m helperMessage
+		if err := dec.Decode(&m); err != nil {
+			return 0, fmt.Errorf("error reading from the GooGet helper: %v", err)
+		}
+		if m.Error != "" {
+			return 0, errors.New(m.Error)
+		}
+		os.Stdout.Write(m.Output)
+		if m.Done {
+			return m.Exit, nil
+		}
+	}
+}
+
+type helperCmd struct {
+	metricsAddr   string
+	checkInterval time.Duration
+	launchd       bool
+}
+
+func (*helperCmd) Name() string { return "helper" }
+func (*helperCmd) Synopsis() string {
+	return "run commands for unprivileged users, as a privileged service"
+}
+func (*helperCmd) Usage() string {
+	return fmt.Sprintf(`%s helper:
+	Listen for commands of unprivileged GooGet runs on %s in the root
+	directory and run those allowed by the helperusers, helpergroups and
+	helpercommands settings of googet.conf. Run it as a service with the
+	privileges GooGet needs to install packages, on macOS -launchd installs
+	the launchd daemon doing so, on Windows run it as SYSTEM. Windows needs
+	unix domain socket support, Windows 10 1803 or later.
+`, filepath.Base(os.Args[0]), helperSocket)
+}
+
+func (cmd *helperCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.metricsAddr, "metrics_addr", "", "address to serve Prometheus metrics on /metrics, e.g. localhost:9101, none if empty")
+	f.DurationVar(&cmd.checkInterval, "check_interval", 0, "how often to check the repos for updates of installed packages, reported in the metrics, never if 0")
+	f.BoolVar(&cmd.launchd, "launchd", false, "install and load a launchd daemon running the helper with the other flags instead, macOS only")
+}
+
+func (cmd *helperCmd) Execute(ctx context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if cmd.launchd {
+		var args []string
+		if cmd.metricsAddr != "" {
+			args = append(args, "-metrics_addr", cmd.metricsAddr)
+		}
+		if cmd.checkInterval > 0 {
+			args = append(args, "-check_interval", cmd.checkInterval.String())
+		}
+		if err := installHelperService(args); err != nil {
+			logger.Error(err)
+			return subcommands.ExitFailure
+		}
+		fmt.Printf("Installed and loaded the launchd daemon %s\n", helperLabel)
+		return subcommands.ExitSuccess
+	}
+	p, err := newHelperPolicy(helperUsers, helperGroups, helperCommands)
+	if err != nil {
+		logger.Error(err)
+		return subcommands.ExitFailure
+	}
+	sock := filepath.Join(rootDir, helperSocket)
+	if err := os.Remove(sock); err != nil && !os.IsNotExist(err) {
+		logger.Error(err)
+		return subcommands.ExitFailure
+	}
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		logger.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer l.Close()
+	// Anyone may connect, callers are authorized from their credentials.
+	if err := shareSocket(sock); err != nil {
+		logger.Error(err)
+		return subcommands.ExitFailure
+	}
+	logger.Infof("GooGet helper listening on %s", sock)
+	if cmd.metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", serveHelperMetrics)
+		ml, err := net.Listen("tcp", cmd.metricsAddr)
+		if err != nil {
+			logger.Error(err)
+			return subcommands.ExitFailure
+		}
+		logger.Infof("GooGet helper serving metrics on %s", ml.Addr())
+		go func() {
+			if err := http.Serve(ml, mux); err != nil {
+				logger.Errorf("GooGet helper: error serving metrics: %v", err)
+			}
+		}()
+	}
+	if cmd.checkInterval > 0 {
+		go checkUpdates(ctx, cmd.checkInterval)
+	}
+	// Stop listening when googet is interrupted.
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return subcommands.ExitSuccess
+			}
+			logger.Error(err)
+			return subcommands.ExitFailure
+		}
+		go serveHelper(ctx, c, p)
+	}
+}
@@ -16,13 +16,13 @@ package main
 // The install subcommand handles the downloading and installation of a package.
 
 import (
-	"bytes"
 	"context"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/google/googet/v2/api"
 	"github.com/google/googet/v2/client"
 	"github.com/google/googet/v2/goolib"
 	"github.com/google/googet/v2/install"
@@ -31,10 +31,15 @@ import (
 )
 
 type installCmd struct {
-	reinstall  bool
-	redownload bool
-	dbOnly     bool
-	sources    string
+	reinstall   bool
+	redownload  bool
+	dbOnly      bool
+	allowYanked bool
+	sources     string
+	plan        string
+	reportFile  string
+	// stopServices sets install.StopServices.
+	stopServices bool
 }
 
 func (*installCmd) Name() string     { return "install" }
@@ -47,10 +52,16 @@ func (cmd *installCmd) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&cmd.reinstall, "reinstall", false, "install even if already installed")
 	f.BoolVar(&cmd.redownload, "redownload", false, "redownload package files")
 	f.BoolVar(&cmd.dbOnly, "db_only", false, "only make changes to DB, don't perform install system actions")
+	f.BoolVar(&cmd.allowYanked, "allow_yanked", false, "allow installing a version that was yanked from the repo")
 	f.StringVar(&cmd.sources, "sources", "", "comma separated list of sources, setting this overrides local .repo files")
+	f.StringVar(&cmd.reportFile, "report_file", "", "write a JSON report of the packages changed, their scripts, the bytes downloaded and the errors to this file")
+	f.StringVar(&cmd.plan, "plan", "", "write the packages that would be installed to this plan file instead of installing them, see the apply command")
+	f.BoolVar(&cmd.stopServices, "stop_services", false, "stop the services using files to replace during the install instead of replacing the files on reboot, Windows only")
 }
 
-func (cmd *installCmd) Execute(ctx context.Context, flags *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+func (cmd *installCmd) Execute(ctx context.Context, flags *flag.FlagSet, _ ...interface{}) (es subcommands.ExitStatus) {
+	done := startReport(cmd.reportFile, flags)
+	defer func() { done(es) }()
 	if len(flags.Args()) == 0 {
 		fmt.Printf("%s\nUsage: %s\n", cmd.Synopsis(), cmd.Usage())
 		return subcommands.ExitFailure
@@ -60,152 +71,133 @@ func (cmd *installCmd) Execute(ctx context.Context, flags *flag.FlagSet, _ ...in
 		fmt.Fprintln(os.Stderr, "It's an error to use the -redownload flag without the -reinstall flag")
 		return subcommands.ExitFailure
 	}
+	if cmd.plan != "" && cmd.reinstall {
+		fmt.Fprintln(os.Stderr, "It's an error to use the -plan flag with the -reinstall flag")
+		return subcommands.ExitFailure
+	}
 
+	install.StopServices = cmd.stopServices
 	args := flags.Args()
-	exitCode := subcommands.ExitSuccess
-
-	cache := filepath.Join(rootDir, cacheDir)
-	sf := filepath.Join(rootDir, stateFile)
-	state, err := readState(sf)
+	var o outcome
+	c, err := newClient(cmd.sources)
 	if err != nil {
 		logger.Fatal(err)
 	}
-
-	if len(args) == 0 {
-		return exitCode
-	}
-
-	repos, err := buildSources(cmd.sources)
+	c.DBOnly, c.AllowYanked = cmd.dbOnly, cmd.allowYanked
+	op, err := c.Begin()
 	if err != nil {
 		logger.Fatal(err)
 	}
-
-	var rm client.RepoMap
+	state := op.State
+	reboots := pendingReboots(*state)
+	var p plan
 	for _, arg := range args {
 		if ext := filepath.Ext(arg); ext == ".goo" {
-			if !noConfirm {
-				if base := filepath.Base(arg); !confirmation(fmt.Sprintf("Install %s?", base)) {
-					fmt.Printf("Not installing %s...\n", base)
-					continue
-				}
-			}
-			if err := install.FromDisk(arg, cache, state, cmd.dbOnly, cmd.reinstall); err != nil {
-				logger.Errorf("Error installing %s: %v", arg, err)
-				exitCode = subcommands.ExitFailure
+			if cmd.plan != "" {
+				logger.Errorf("Cannot plan the install of local package %s", arg)
+				o.fail(nil)
 				continue
 			}
-			if err := writeState(state, sf); err != nil {
-				logger.Fatalf("Error writing state file: %v", err)
-			}
-			continue
-		}
-
-		pi := goolib.PkgNameSplit(arg)
-		if cmd.reinstall {
-			if err := reinstall(ctx, pi, *state, cmd.redownload); err != nil {
-				logger.Errorf("Error reinstalling %s: %v", pi.Name, err)
-				exitCode = subcommands.ExitFailure
+			err := op.InstallFile(ctx, arg, cmd.reinstall)
+			if err == api.ErrCanceled {
+				fmt.Printf("Not installing %s...\n", filepath.Base(arg))
 				continue
 			}
-			if err := writeState(state, sf); err != nil {
-				logger.Fatalf("Error writing state file: %v", err)
-			}
-			continue
-		}
-		if len(rm) == 0 {
-			if repos == nil {
-				logger.Fatal("No repos defined, create a .repo file or pass using the -sources flag.")
-			}
-			rm = client.AvailableVersions(ctx, repos, filepath.Join(rootDir, cacheDir), cacheLife, proxyServer)
-		}
-		if pi.Ver == "" {
-			v, _, a, err := client.FindRepoLatest(pi, rm, archs)
-			pi.Ver, pi.Arch = v, a
 			if err != nil {
-				logger.Errorf("Can't resolve version for package %q: %v", pi.Name, err)
-				exitCode = subcommands.ExitFailure
+				logger.Errorf("Error installing %s: %v", arg, err)
+				o.fail(err)
 				continue
 			}
-		}
-		if _, err := goolib.ParseVersion(pi.Ver); err != nil {
-			logger.Errorf("Invalid package version %q: %v", pi.Ver, err)
-			exitCode = subcommands.ExitFailure
-			continue
-		}
-
-		r, err := client.WhatRepo(pi, rm)
-		if err != nil {
-			logger.Errorf("Error finding %s.%s.%s in repo: %v", pi.Name, pi.Arch, pi.Ver, err)
-			exitCode = subcommands.ExitFailure
+			o.succeed()
 			continue
 		}
-		ni, err := install.NeedsInstallation(pi, *state)
+		pi, err := goolib.ParsePkgName(arg)
 		if err != nil {
 			logger.Error(err)
-			exitCode = subcommands.ExitFailure
+			o.fail(err)
 			continue
 		}
-		if !ni {
-			fmt.Printf("%s.%s.%s or a newer version is already installed on the system\n", pi.Name, pi.Arch, pi.Ver)
-			continue
-		}
-		if !noConfirm {
-			b, err := enumerateDeps(pi, rm, r, archs, *state)
-			if err != nil {
-				logger.Error(err)
-				exitCode = subcommands.ExitFailure
+		if cmd.reinstall {
+			err := op.Reinstall(ctx, pi, cmd.redownload)
+			if err == api.ErrCanceled {
+				fmt.Printf("Not reinstalling %s...\n", pi.Name)
 				continue
 			}
-			if !confirmation(b.String()) {
-				fmt.Println("canceling install...")
+			if err != nil {
+				logger.Errorf("Error reinstalling %s: %v", pi.Name, err)
+				o.fail(err)
 				continue
 			}
-		}
-		if err := install.FromRepo(ctx, pi, r, cache, rm, archs, state, cmd.dbOnly, proxyServer); err != nil {
-			logger.Errorf("Error installing %s.%s.%s: %v", pi.Name, pi.Arch, pi.Ver, err)
-			exitCode = subcommands.ExitFailure
+			o.succeed()
 			continue
 		}
-		if err := writeState(state, sf); err != nil {
-			logger.Fatalf("error writing state file: %v", err)
+		rm, err := op.RepoMap(ctx)
+		if err != nil {
+			logger.Fatalf("%v, create a .repo file or pass using the -sources flag.", err)
+		}
+		o.readRepos(op.Sources(), rm)
+		var ok bool
+		if cmd.plan != "" {
+			pi, ok, err = addPlannedInstall(ctx, op, &p, pi, cmd.allowYanked)
+		} else {
+			pi, ok, err = op.Install(ctx, pi)
+		}
+		switch {
+		case err == api.ErrCanceled:
+			fmt.Println("canceling install...")
+		case err != nil:
+			logger.Errorf("Error installing %s: %v", arg, err)
+			o.fail(err)
+		case !ok:
+			fmt.Printf("%s.%s.%s or a newer version is already installed on the system\n", pi.Name, pi.Arch, pi.Ver)
+		default:
+			o.succeed()
 		}
 	}
-	return exitCode
-}
 
-func reinstall(ctx context.Context, pi goolib.PackageInfo, state client.GooGetState, rd bool) error {
-	ps, err := state.GetPackageState(pi)
-	if err != nil {
-		return fmt.Errorf("cannot reinstall something that is not already installed")
+	if cmd.plan != "" && o.failed == 0 {
+		if err := writePlan(cmd.plan, &p, flags); err != nil {
+			logger.Errorf("Error writing plan: %v", err)
+			return subcommands.ExitFailure
+		}
 	}
-	if !noConfirm {
-		if !confirmation(fmt.Sprintf("Reinstall %s?", pi.Name)) {
-			fmt.Printf("Not reinstalling %s...\n", pi.Name)
-			return nil
+	if o.failed == 0 {
+		for p := range pendingReboots(*state) {
+			if !reboots[p] {
+				return exitRebootRequired
+			}
 		}
 	}
-	if err := install.Reinstall(ctx, ps, state, rd, proxyServer); err != nil {
-		return fmt.Errorf("error reinstalling %s, %v", pi.Name, err)
+	return o.status()
+}
+
+// pendingReboots returns the packages of state whose install requires a
+// reboot.
+func pendingReboots(state client.GooGetState) map[string]bool {
+	m := make(map[string]bool)
+	for _, ps := range state {
+		if ps.RebootRequired {
+			m[ps.PackageSpec.String()] = true
+		}
 	}
-	return nil
+	return m
 }
 
-func enumerateDeps(pi goolib.PackageInfo, rm client.RepoMap, r string, archs []string, state client.GooGetState) (*bytes.Buffer, error) {
-	dl, err := install.ListDeps(pi, rm, r, archs)
+// addPlannedInstall adds the install of pi to p, see api.Op.Install.
+func addPlannedInstall(ctx context.Context, op *api.Op, p *plan, pi goolib.PackageInfo, allowYanked bool) (goolib.PackageInfo, bool, error) {
+	pi, r, err := op.Resolve(ctx, pi)
 	if err != nil {
-		return nil, fmt.Errorf("error listing dependencies for %s.%s.%s: %v", pi.Name, pi.Arch, pi.Ver, err)
+		return pi, false, err
 	}
-	var b bytes.Buffer
-	fmt.Fprintln(&b, "The following packages will be installed:")
-	for _, di := range dl {
-		ni, err := install.NeedsInstallation(di, state)
-		if err != nil {
-			return nil, err
-		}
-		if ni {
-			fmt.Fprintf(&b, "  %s.%s.%s\n", di.Name, di.Arch, di.Ver)
-		}
+	if ni, err := install.NeedsInstallation(pi, *op.State); err != nil || !ni {
+		return pi, false, err
+	}
+	rm, err := op.RepoMap(ctx)
+	if err != nil {
+		return pi, false, err
+	}
+	if err := api.CheckStatus(pi, rm[r], allowYanked); err != nil {
+		return pi, false, err
 	}
-	fmt.Fprintf(&b, "Do you wish to install %s.%s.%s and all dependencies?", pi.Name, pi.Arch, pi.Ver)
-	return &b, nil
+	return pi, true, p.addInstalls(pi, r, rm, *op.State)
 }
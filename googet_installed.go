@@ -24,33 +24,47 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/google/googet/v2/api"
 	"github.com/google/googet/v2/client"
 	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/verify"
 	"github.com/google/logger"
 	"github.com/google/subcommands"
 )
 
 type installedCmd struct {
-	info  bool
-	files bool
+	info          bool
+	files         bool
+	verifyScripts bool
+	verifyTimeout time.Duration
+	allRoots      bool
 }
 
 func (*installedCmd) Name() string     { return "installed" }
 func (*installedCmd) Synopsis() string { return "list installed packages" }
 func (*installedCmd) Usage() string {
-	return fmt.Sprintf(`%s installed [-info] [-files] [<initial>]:
+	return fmt.Sprintf(`%s installed [-info] [-files] [-verify_scripts [-verify_timeout <duration>]] [-all_roots] [<initial>]:
 	List installed packages beginning with an initial string,
 	if no initial string is provided all installed packages will be listed.
+	With -verify_scripts only the verify commands of the packages are run,
+	without checking their files, and a summary is printed.
+	With -all_roots the packages of the roots listed in the conf file are
+	listed as well, each labeled with its root.
 `, filepath.Base(os.Args[0]))
 }
 
 func (cmd *installedCmd) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&cmd.info, "info", false, "display package info")
 	f.BoolVar(&cmd.files, "files", false, "display package file list")
+	f.BoolVar(&cmd.verifyScripts, "verify_scripts", false, "run the verify command of each package and summarize the results")
+	f.DurationVar(&cmd.verifyTimeout, "verify_timeout", 5*time.Minute, "time limit of each verify command with -verify_scripts, none if 0")
+	f.BoolVar(&cmd.allRoots, "all_roots", false, "list the packages of all roots in the conf file, labeled with their root")
 }
 
-func (cmd *installedCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+func (cmd *installedCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
 	var filter string
 	switch f.NArg() {
 	case 0:
@@ -62,8 +76,15 @@ func (cmd *installedCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interf
 		f.Usage()
 		return subcommands.ExitUsageError
 	}
+	if cmd.allRoots {
+		if cmd.info || cmd.verifyScripts {
+			fmt.Fprintln(os.Stderr, "-all_roots can't be combined with -info or -verify_scripts")
+			return subcommands.ExitUsageError
+		}
+		return listAllRoots(allRoots(), filter, cmd.files)
+	}
 
-	state, err := readState(filepath.Join(rootDir, stateFile))
+	state, err := api.ReadState(filepath.Join(rootDir, stateFile))
 	if err != nil {
 		logger.Fatal(err)
 	}
@@ -80,6 +101,19 @@ func (cmd *installedCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interf
 	}
 
 	sort.Strings(pl)
+	if cmd.verifyScripts {
+		var match []string
+		for _, p := range pl {
+			if strings.Contains(p, filter) {
+				match = append(match, p)
+			}
+		}
+		if len(match) == 0 {
+			fmt.Fprintf(os.Stderr, "No package matching filter %q installed.\n", filter)
+			return subcommands.ExitFailure
+		}
+		return verifyScripts(ctx, *state, match, cmd.verifyTimeout)
+	}
 	if filter != "" {
 		fmt.Printf("Installed packages matching %q:\n", filter)
 	} else {
@@ -95,7 +129,8 @@ func (cmd *installedCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interf
 				local(pi, *state)
 				continue
 			}
-			fmt.Println(" ", pi.Name+"."+pi.Arch+" "+pi.Ver)
+			ps, _ := state.GetPackageState(pi)
+			fmt.Println(" ", pi.Name+"."+pi.Arch+" "+pi.Ver+dependencySuffix(ps))
 
 			if cmd.files {
 				ps, err := state.GetPackageState(pi)
@@ -118,11 +153,184 @@ func (cmd *installedCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interf
 	return exitCode
 }
 
+// allRoots returns the root followed by the other roots of the conf file,
+// without duplicates.
+func allRoots() []string {
+	seen := make(map[string]bool)
+	var rl []string
+	for _, r := range append([]string{rootDir}, otherRoots...) {
+		r = filepath.Clean(r)
+		if !seen[r] {
+			seen[r] = true
+			rl = append(rl, r)
+		}
+	}
+	return rl
+}
+
+// rootPackage is a package installed in root.
+type rootPackage struct {
+	root string
+	client.PackageState
+}
+
+// installedInRoots returns the packages installed in the roots rl whose name
+// contains filter, sorted by name and root, and logs and returns the roots
+// whose state can't be read.
+func installedInRoots(rl []string, filter string) ([]rootPackage, []string) {
+	var pkgs []rootPackage
+	var failed []string
+	for _, r := range rl {
+		// api.ReadState treats a missing state file as no packages, but a
+		// missing root is more likely a mistake in the conf file.
+		if _, err := os.Stat(r); err != nil {
+			logger.Errorf("Unable to read root %s: %v", r, err)
+			failed = append(failed, r)
+			continue
+		}
+		state, err := api.ReadState(filepath.Join(r, stateFile))
+		if err != nil {
+			logger.Errorf("Unable to read state of root %s: %v", r, err)
+			failed = append(failed, r)
+			continue
+		}
+		for _, ps := range *state {
+			if strings.Contains(ps.PackageSpec.String(), filter) {
+				pkgs = append(pkgs, rootPackage{root: r, PackageState: ps})
+			}
+		}
+	}
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		return pkgs[i].PackageSpec.String() < pkgs[j].PackageSpec.String()
+	})
+	return pkgs, failed
+}
+
+// listAllRoots lists the packages installed in the roots rl whose name
+// contains filter, labeled with their root, and their files if files is set.
+func listAllRoots(rl []string, filter string, files bool) subcommands.ExitStatus {
+	pkgs, failed := installedInRoots(rl, filter)
+	if filter != "" {
+		fmt.Printf("Installed packages matching %q in all roots:\n", filter)
+	} else {
+		fmt.Println("Installed packages in all roots:")
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, p := range pkgs {
+		fmt.Fprintf(tw, "  %s.%s %s%s\t%s\n", p.PackageSpec.Name, p.PackageSpec.Arch, p.PackageSpec.Version, dependencySuffix(p.PackageState), p.root)
+		if !files {
+			continue
+		}
+		if len(p.InstalledFiles) == 0 {
+			fmt.Fprintln(tw, "  - No files directly managed by GooGet.")
+		}
+		for file := range p.InstalledFiles {
+			fmt.Fprintln(tw, "  -", file)
+		}
+	}
+	tw.Flush()
+	if len(failed) > 0 {
+		fmt.Fprintf(os.Stderr, "Unable to read roots %v.\n", failed)
+		return subcommands.ExitFailure
+	}
+	if len(pkgs) == 0 {
+		fmt.Fprintf(os.Stderr, "No package matching filter %q installed.\n", filter)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// dependencySuffix labels packages installed as dependencies in lists of
+// installed packages.
+func dependencySuffix(ps client.PackageState) string {
+	if ps.Dependency() {
+		return " (dependency)"
+	}
+	return ""
+}
+
 func local(pi goolib.PackageInfo, state client.GooGetState) {
 	for _, p := range state {
 		if p.Match(pi) {
 			info(p.PackageSpec, "installed")
+			if p.RebootRequired {
+				fmt.Println("The install of this package requested a reboot.")
+			}
+			if p.Dependency() {
+				fmt.Println("This package was installed as a dependency of another.")
+			}
 			return
 		}
 	}
 }
+
+// Results of a verify command run by verifyScripts.
+const (
+	verifyPassed   = "passed"
+	verifyFailed   = "failed"
+	verifyTimedOut = "timed out"
+	verifyError    = "error"
+	verifyNone     = "no script"
+)
+
+// verifyCommand runs the verify command of a package, it is replaced in tests.
+var verifyCommand = verify.Command
+
+// verifyScripts runs the verify command of each of the installed packages pl,
+// each limited to timeout if positive, prints a summary and returns the exit
+// status, a failure if any command failed.
+func verifyScripts(ctx context.Context, state client.GooGetState, pl []string, timeout time.Duration) subcommands.ExitStatus {
+	counts := make(map[string]int)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "Verify commands of installed packages:")
+	for _, p := range pl {
+		pi := goolib.PkgNameSplit(p)
+		ps, err := state.GetPackageState(pi)
+		if err != nil {
+			logger.Errorf("Unable to get state of package %q: %v", p, err)
+			counts[verifyError]++
+			continue
+		}
+		result, d := runVerifyScript(ctx, ps, timeout)
+		counts[result]++
+		dur := ""
+		if result != verifyNone {
+			dur = d.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(tw, "  %s.%s %s\t%s\t%s\n", pi.Name, pi.Arch, pi.Ver, result, dur)
+	}
+	tw.Flush()
+	fmt.Printf("%d packages: %d passed, %d failed, %d timed out, %d errors, %d without verify command\n",
+		len(pl), counts[verifyPassed], counts[verifyFailed], counts[verifyTimedOut], counts[verifyError], counts[verifyNone])
+	if counts[verifyFailed]+counts[verifyTimedOut]+counts[verifyError] > 0 {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// runVerifyScript runs the verify command of ps, limited to timeout if
+// positive, and returns the result and how long it took.
+func runVerifyScript(ctx context.Context, ps client.PackageState, timeout time.Duration) (string, time.Duration) {
+	if ps.PackageSpec.Verify.Path == "" {
+		return verifyNone, 0
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	ok, err := verifyCommand(ctx, ps, newDownloader())
+	d := time.Since(start)
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		logger.Errorf("Verify command of %s did not finish within %v", ps.PackageSpec, timeout)
+		return verifyTimedOut, d
+	case err != nil:
+		logger.Errorf("Error running verify command of %s: %v", ps.PackageSpec, err)
+		return verifyError, d
+	case !ok:
+		return verifyFailed, d
+	}
+	return verifyPassed, d
+}
@@ -20,6 +20,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/google/googet/v2/api"
 	"github.com/google/logger"
 	"github.com/google/subcommands"
 )
@@ -35,15 +36,15 @@ func (*listReposCmd) Usage() string {
 func (cmd *listReposCmd) SetFlags(f *flag.FlagSet) {}
 
 func (cmd *listReposCmd) Execute(_ context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	rfs, err := repos(filepath.Join(rootDir, repoDir))
+	rfs, err := api.ReadRepoFiles(filepath.Join(rootDir, repoDir))
 	if err != nil {
 		logger.Fatal(err)
 	}
 
 	for _, rf := range rfs {
-		fmt.Println(rf.fileName + ":")
+		fmt.Println(rf.FileName + ":")
 
-		for _, re := range rf.repoEntries {
+		for _, re := range rf.Entries {
 			fmt.Printf("  %s: %s\n", re.Name, re.URL)
 		}
 	}
@@ -0,0 +1,334 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The helper exports metrics on /metrics of -metrics_addr in the Prometheus
+// text exposition format. Commands run directly add theirs to the metricsfile
+// set in googet.conf, for the textfile collectors of the Prometheus node and
+// Windows exporters.
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/googet/v2/api"
+	"github.com/google/googet/v2/client"
+	"github.com/google/logger"
+	"github.com/google/subcommands"
+)
+
+// Results of commands.
+const (
+	resultSuccess  = "success"
+	resultFailure  = "failure"
+	resultRejected = "rejected"
+)
+
+type commandKey struct {
+	command, result string
+}
+
+// metrics collects the values exported, with names starting with prefix.
+type metrics struct {
+	prefix    string
+	mu        sync.Mutex
+	commands  map[commandKey]int
+	durations map[string]*commandDuration
+	lastError time.Time
+
+	// Results of the periodic update checks.
+	checks        int
+	checkErrors   int
+	lastCheck     time.Time
+	checkDuration time.Duration
+	pending       int
+}
+
+type commandDuration struct {
+	sum   float64
+	count int
+}
+
+func newMetrics(prefix string) *metrics {
+	return &metrics{
+		prefix:    prefix,
+		commands:  make(map[commandKey]int),
+		durations: make(map[string]*commandDuration),
+	}
+}
+
+var helperStats = newMetrics("googet_helper")
+
+// command records a command run, or rejected by the helper.
+func (m *metrics) command(name, result string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.commands[commandKey{name, result}]++
+	if result == resultFailure {
+		m.lastError = time.Now()
+	}
+	if result == resultRejected {
+		return
+	}
+	cd := m.duration(name)
+	cd.sum += d.Seconds()
+	cd.count++
+}
+
+// check records an update check that found pending updates, or failed with
+// err.
+func (m *metrics) check(start time.Time, pending int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checks++
+	if err != nil {
+		m.checkErrors++
+		m.lastError = time.Now()
+		return
+	}
+	m.lastCheck = time.Now()
+	m.checkDuration = m.lastCheck.Sub(start)
+	m.pending = pending
+}
+
+// writeTo writes the metrics to w in the Prometheus text format, those of
+// the update checks if checks is set.
+func (m *metrics) writeTo(w io.Writer, checks bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s_commands_total Commands run by command and result.\n", m.prefix)
+	fmt.Fprintf(w, "# TYPE %s_commands_total counter\n", m.prefix)
+	var keys []commandKey
+	for k := range m.commands {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].command != keys[j].command {
+			return keys[i].command < keys[j].command
+		}
+		return keys[i].result < keys[j].result
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s_commands_total{command=%q,result=%q} %d\n", m.prefix, k.command, k.result, m.commands[k])
+	}
+
+	fmt.Fprintf(w, "# HELP %s_command_duration_seconds Run time of the commands.\n", m.prefix)
+	fmt.Fprintf(w, "# TYPE %s_command_duration_seconds summary\n", m.prefix)
+	var names []string
+	for n := range m.durations {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		fmt.Fprintf(w, "%s_command_duration_seconds_sum{command=%q} %g\n", m.prefix, n, m.durations[n].sum)
+		fmt.Fprintf(w, "%s_command_duration_seconds_count{command=%q} %d\n", m.prefix, n, m.durations[n].count)
+	}
+
+	var lastError, lastCheck int64
+	if !m.lastError.IsZero() {
+		lastError = m.lastError.Unix()
+	}
+	if !m.lastCheck.IsZero() {
+		lastCheck = m.lastCheck.Unix()
+	}
+	type value struct {
+		name, help, typ string
+		value           interface{}
+	}
+	values := []value{
+		{"last_error_timestamp_seconds", "Time of the last failed command or update check, 0 if none failed.", "gauge", lastError},
+	}
+	if checks {
+		values = append(values, []value{
+			{"update_checks_total", "Update checks run.", "counter", m.checks},
+			{"update_check_errors_total", "Update checks that failed.", "counter", m.checkErrors},
+			{"last_update_check_timestamp_seconds", "Time the last successful update check completed, 0 if none did.", "gauge", lastCheck},
+			{"last_update_check_duration_seconds", "Duration of the last successful update check.", "gauge", fmt.Sprintf("%g", m.checkDuration.Seconds())},
+			{"packages_pending", "Installed packages with an update available at the last update check.", "gauge", m.pending},
+		}...)
+	}
+	for _, v := range values {
+		name := m.prefix + "_" + v.name
+		fmt.Fprintf(w, "# HELP %s %s\n", name, v.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, v.typ)
+		fmt.Fprintf(w, "%s %v\n", name, v.value)
+	}
+}
+
+// readFrom reads back the command metrics written by writeTo from r, other
+// samples are ignored.
+func (m *metrics) readFrom(r io.Reader) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, labels, v, err := parseSample(line)
+		if err != nil {
+			return err
+		}
+		switch strings.TrimPrefix(name, m.prefix+"_") {
+		case "commands_total":
+			m.commands[commandKey{labels["command"], labels["result"]}] = int(v)
+		case "command_duration_seconds_sum":
+			m.duration(labels["command"]).sum = v
+		case "command_duration_seconds_count":
+			m.duration(labels["command"]).count = int(v)
+		case "last_error_timestamp_seconds":
+			if v > 0 {
+				m.lastError = time.Unix(int64(v), 0)
+			}
+		}
+	}
+	return s.Err()
+}
+
+// duration returns the run times of command name, m.mu must be held.
+func (m *metrics) duration(name string) *commandDuration {
+	cd, ok := m.durations[name]
+	if !ok {
+		cd = &commandDuration{}
+		m.durations[name] = cd
+	}
+	return cd
+}
+
+// parseSample parses a sample line of the Prometheus text format, with
+// labels quoted as by writeTo.
+func parseSample(line string) (string, map[string]string, float64, error) {
+	i := strings.LastIndexByte(line, ' ')
+	if i < 0 {
+		return "", nil, 0, fmt.Errorf("invalid sample %q", line)
+	}
+	v, err := strconv.ParseFloat(line[i+1:], 64)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("invalid sample %q: %v", line, err)
+	}
+	name := line[:i]
+	labels := make(map[string]string)
+	j := strings.IndexByte(name, '{')
+	if j < 0 {
+		return name, labels, v, nil
+	}
+	ls := strings.TrimSuffix(name[j+1:], "}")
+	name = name[:j]
+	for ls != "" {
+		k := strings.IndexByte(ls, '=')
+		if k < 0 {
+			return "", nil, 0, fmt.Errorf("invalid labels in sample %q", line)
+		}
+		q, err := strconv.QuotedPrefix(ls[k+1:])
+		if err != nil {
+			return "", nil, 0, fmt.Errorf("invalid labels in sample %q: %v", line, err)
+		}
+		labels[ls[:k]], _ = strconv.Unquote(q)
+		ls = strings.TrimPrefix(ls[k+1+len(q):], ",")
+	}
+	return name, labels, v, nil
+}
+
+// recordRun adds the run of command, which exited with es after d, to the
+// metrics in the file path. The file is replaced rather than written in
+// place, so collectors never read it half written.
+func recordRun(path, command string, es subcommands.ExitStatus, d time.Duration) error {
+	m := newMetrics("googet")
+	f, err := os.Open(path)
+	if err == nil {
+		err = m.readFrom(f)
+		f.Close()
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading metrics file: %v", err)
+	}
+	result := resultSuccess
+	// Having nothing to do is not a failure.
+	if es != subcommands.ExitSuccess && es != exitNothingToDo {
+		result = resultFailure
+	}
+	m.command(command, result, d)
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	m.writeTo(tmp, false)
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// Collectors run as other users.
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func serveHelperMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	helperStats.writeTo(w, true)
+}
+
+// pendingUpdates returns the number of installed packages with an update
+// available in the configured repos.
+func pendingUpdates(ctx context.Context) (int, error) {
+	state, err := api.ReadState(filepath.Join(rootDir, stateFile))
+	if err != nil {
+		return 0, err
+	}
+	pm := installedPackages(*state)
+	if len(pm) == 0 {
+		return 0, nil
+	}
+	repos, err := buildSources("")
+	if err != nil {
+		return 0, err
+	}
+	rm := client.AvailableVersions(ctx, repos, filepath.Join(rootDir, cacheDir), cacheLife, newDownloader())
+	return len(updates(pm, rm)), nil
+}
+
+// checkUpdates counts the pending updates every interval until ctx is done.
+func checkUpdates(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		start := time.Now()
+		n, err := pendingUpdates(ctx)
+		if err != nil {
+			logger.Errorf("GooGet helper: error checking for updates: %v", err)
+		} else {
+			logger.Infof("GooGet helper: %d packages have updates available", n)
+		}
+		helperStats.check(start, n, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -16,13 +16,12 @@ package main
 // The remove subcommand handles the uninstallation of a package.
 
 import (
-	"bytes"
 	"context"
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
 
+	"github.com/google/googet/v2/api"
 	"github.com/google/googet/v2/goolib"
 	"github.com/google/googet/v2/remove"
 	"github.com/google/logger"
@@ -30,7 +29,9 @@ import (
 )
 
 type removeCmd struct {
-	dbOnly bool
+	dbOnly     bool
+	plan       string
+	reportFile string
 }
 
 func (cmd *removeCmd) Name() string     { return "remove" }
@@ -41,25 +42,33 @@ func (cmd *removeCmd) Usage() string {
 
 func (cmd *removeCmd) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&cmd.dbOnly, "db_only", false, "only make changes to DB, don't perform uninstall system actions")
+	f.StringVar(&cmd.reportFile, "report_file", "", "write a JSON report of the packages changed, their scripts, the bytes downloaded and the errors to this file")
+	f.StringVar(&cmd.plan, "plan", "", "write the packages that would be removed to this plan file instead of removing them, see the apply command")
 }
 
-func (cmd *removeCmd) Execute(ctx context.Context, flags *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	exitCode := subcommands.ExitSuccess
-
-	sf := filepath.Join(rootDir, stateFile)
-	state, err := readState(sf)
+func (cmd *removeCmd) Execute(ctx context.Context, flags *flag.FlagSet, _ ...interface{}) (es subcommands.ExitStatus) {
+	done := startReport(cmd.reportFile, flags)
+	defer func() { done(es) }()
+	var o outcome
+	c, err := newClient("")
 	if err != nil {
-		logger.Error(err)
+		logger.Fatal(err)
 	}
-
+	c.DBOnly = cmd.dbOnly
+	op, err := c.Begin()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	state := op.State
+	var p plan
 	for _, arg := range flags.Args() {
-		pi := goolib.PkgNameSplit(arg)
-		var ins []string
-		for _, ps := range *state {
-			if ps.Match(pi) {
-				ins = append(ins, ps.PackageSpec.Name+"."+ps.PackageSpec.Arch)
-			}
+		pi, err := goolib.ParsePkgName(arg)
+		if err != nil {
+			logger.Error(err)
+			o.fail(err)
+			continue
 		}
+		ins := op.Match(pi)
 		if len(ins) == 0 {
 			logger.Errorf("Package %q not installed, cannot remove.", arg)
 			continue
@@ -69,30 +78,35 @@ func (cmd *removeCmd) Execute(ctx context.Context, flags *flag.FlagSet, _ ...int
 			return subcommands.ExitFailure
 		}
 		pi = goolib.PkgNameSplit(ins[0])
-		deps, dl := remove.EnumerateDeps(pi, *state)
-		if !noConfirm {
-			var b bytes.Buffer
-			fmt.Fprintln(&b, "The following packages will be removed:")
-			for _, d := range dl {
-				fmt.Fprintln(&b, "  "+d)
-			}
-			fmt.Fprintf(&b, "Do you wish to remove %s and all dependencies?", pi.Name)
-			if !confirmation(b.String()) {
-				fmt.Println("canceling removal...")
+		if cmd.plan != "" {
+			deps, _ := remove.EnumerateDeps(pi, *state)
+			if err := p.addRemoves(deps, *state); err != nil {
+				logger.Errorf("Error planning removal of %s: %v", arg, err)
+				o.fail(err)
 				continue
 			}
+			o.succeed()
+			continue
+		}
+		err = op.Remove(ctx, pi)
+		if err == api.ErrCanceled {
+			fmt.Println("canceling removal...")
+			continue
 		}
-		fmt.Printf("Removing %s and all dependencies...\n", pi.Name)
-		if err = remove.All(ctx, pi, deps, state, cmd.dbOnly, proxyServer); err != nil {
+		if err != nil {
 			logger.Errorf("error removing %s, %v", arg, err)
-			exitCode = subcommands.ExitFailure
+			o.fail(err)
 			continue
 		}
 		logger.Infof("Removal of %q and dependant packages completed", pi.Name)
 		fmt.Printf("Removal of %s completed\n", pi.Name)
-		if err := writeState(state, sf); err != nil {
-			logger.Fatalf("error writing state file: %v", err)
+		o.succeed()
+	}
+	if cmd.plan != "" && o.failed == 0 {
+		if err := writePlan(cmd.plan, &p, flags); err != nil {
+			logger.Errorf("Error writing plan: %v", err)
+			return subcommands.ExitFailure
 		}
 	}
-	return exitCode
+	return o.status()
 }
@@ -0,0 +1,62 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/google/googet/v2/api"
+	"github.com/google/googet/v2/events"
+	"github.com/google/googet/v2/report"
+	"github.com/google/logger"
+	"github.com/google/subcommands"
+)
+
+// startReport starts recording the report of the command run with f, and
+// returns a function writing it to path with the exit status of the
+// command. Nothing is recorded if path is empty.
+func startReport(path string, f *flag.FlagSet) func(subcommands.ExitStatus) {
+	if path == "" {
+		return func(subcommands.ExitStatus) {}
+	}
+	var before packageMap
+	if state, err := api.ReadState(filepath.Join(rootDir, stateFile)); err == nil {
+		before = installedPackages(*state)
+	}
+	report.Start(commandLine(f))
+	return func(es subcommands.ExitStatus) {
+		if err := writeReport(path, report.Finish(), es, before); err != nil {
+			logger.Errorf("Error writing report: %v", err)
+		}
+	}
+}
+
+// writeReport writes r to path, before are the packages installed before
+// the command.
+func writeReport(path string, r *report.Report, es subcommands.ExitStatus, before packageMap) error {
+	r.ExitStatus = int(es)
+	for _, p := range r.Packages {
+		if v, ok := before[p.Package]; ok && v != p.Version && p.Action != events.Remove {
+			p.PreviousVersion = v
+		}
+	}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
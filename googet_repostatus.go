@@ -0,0 +1,123 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The repostatus subcommand checks that the repos can be installed from.
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/logger"
+	"github.com/google/subcommands"
+)
+
+type repoStatusCmd struct {
+	sources string
+	json    bool
+}
+
+func (*repoStatusCmd) Name() string     { return "repostatus" }
+func (*repoStatusCmd) Synopsis() string { return "check the health of repositories" }
+func (*repoStatusCmd) Usage() string {
+	return fmt.Sprintf(`%s repostatus [-sources repo1,repo2...] [-json]:
+	Fetches the index of each repo, bypassing the cache, and reports whether
+	the repo is reachable, how long it took to answer, when its index last
+	changed and its digest, how many packages it has and whether its index
+	signature is valid. Exits with an error if any repo is unhealthy.
+`, filepath.Base(os.Args[0]))
+}
+
+func (cmd *repoStatusCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.sources, "sources", "", "comma separated list of sources, setting this overrides local .repo files")
+	f.BoolVar(&cmd.json, "json", false, "output the status of the repos as JSON")
+}
+
+func (cmd *repoStatusCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "Excessive arguments")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	repos, err := buildSources(cmd.sources)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if repos == nil {
+		logger.Fatal("No repos defined, create a .repo file or pass using the -sources flag.")
+	}
+	var urls []string
+	for r := range repos {
+		urls = append(urls, r)
+	}
+	sort.Strings(urls)
+
+	downloader := newDownloader()
+	var statuses []client.RepoStatus
+	for _, u := range urls {
+		statuses = append(statuses, client.CheckRepo(ctx, u, downloader))
+	}
+	if cmd.json {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		if err := e.Encode(statuses); err != nil {
+			logger.Fatal(err)
+		}
+	} else {
+		printRepoStatus(os.Stdout, statuses)
+	}
+	for _, s := range statuses {
+		if !s.Healthy() {
+			return subcommands.ExitFailure
+		}
+	}
+	return subcommands.ExitSuccess
+}
+
+// printRepoStatus writes statuses to w for people to read.
+func printRepoStatus(w io.Writer, statuses []client.RepoStatus) {
+	for _, s := range statuses {
+		health := "healthy"
+		if !s.Healthy() {
+			health = "unhealthy"
+		}
+		fmt.Fprintf(w, "%s: %s\n", s.URL, health)
+		if !s.Reachable {
+			fmt.Fprintf(w, "  Unreachable: %s\n", s.Error)
+			continue
+		}
+		fmt.Fprintf(w, "  Index:      %s (%v)\n", s.Index, s.Latency.Round(time.Millisecond))
+		modified := "unknown"
+		if s.LastModified != nil {
+			modified = fmt.Sprintf("%s (%v ago)", s.LastModified.Local().Format(time.RFC3339), time.Since(*s.LastModified).Round(time.Second))
+		}
+		fmt.Fprintf(w, "  Modified:   %s\n", modified)
+		fmt.Fprintf(w, "  Digest:     %s\n", s.Digest)
+		fmt.Fprintf(w, "  Packages:   %d\n", s.Packages)
+		if s.Signature != "" {
+			fmt.Fprintf(w, "  Signature:  %s\n", s.Signature)
+		}
+		if s.Error != "" {
+			fmt.Fprintf(w, "  Error:      %s\n", s.Error)
+		}
+	}
+}
@@ -19,9 +19,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
-	"github.com/google/googet/v2/oswrap"
+	"github.com/google/googet/v2/api"
 	"github.com/google/logger"
 	"github.com/google/subcommands"
 )
@@ -53,44 +52,19 @@ func (cmd *rmRepoCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface
 		return subcommands.ExitUsageError
 	}
 
-	rfs, err := repos(filepath.Join(rootDir, repoDir))
-	if err != nil {
-		logger.Fatal(err)
-	}
-
-	var foundRepo repoFile
-	for _, rf := range rfs {
-		for _, re := range rf.repoEntries {
-			if strings.EqualFold(re.Name, name) {
-				foundRepo = rf
-				break
-			}
-		}
-	}
-
-	if foundRepo.fileName == "" {
+	file, deleted, err := api.RemoveRepo(filepath.Join(rootDir, repoDir), name)
+	if err == api.ErrRepoNotFound {
 		fmt.Fprintf(os.Stderr, "Repo %q not found, nothing to remove.\n", name)
 		return subcommands.ExitUsageError
 	}
-
-	var res []repoEntry
-	for _, re := range foundRepo.repoEntries {
-		if !strings.EqualFold(re.Name, name) {
-			res = append(res, re)
-		}
+	if err != nil {
+		logger.Fatal(err)
 	}
 
-	if len(res) > 0 {
-		if err := writeRepoFile(repoFile{foundRepo.fileName, res}); err != nil {
-			logger.Fatal(err)
-		}
-		fmt.Printf("Removed repo %q from repo file %s.\n", name, foundRepo.fileName)
+	if !deleted {
+		fmt.Printf("Removed repo %q from repo file %s.\n", name, file)
 		return subcommands.ExitSuccess
 	}
-
-	if err := oswrap.Remove(foundRepo.fileName); err != nil {
-		logger.Fatal(err)
-	}
-	fmt.Printf("Removed repo %q and repo file %s.\n", name, foundRepo.fileName)
+	fmt.Printf("Removed repo %q and repo file %s.\n", name, file)
 	return subcommands.ExitSuccess
 }
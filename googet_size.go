@@ -0,0 +1,178 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The size subcommand reports the disk usage of installed packages and the cache.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/googet/v2/api"
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/logger"
+	"github.com/google/subcommands"
+)
+
+type sizeCmd struct {
+	sort  string
+	bytes bool
+}
+
+func (*sizeCmd) Name() string     { return "size" }
+func (*sizeCmd) Synopsis() string { return "report disk usage of installed packages" }
+func (*sizeCmd) Usage() string {
+	return fmt.Sprintf(`%s size [-sort size|name] [-bytes] [<initial>]:
+	Report the disk usage of installed packages beginning with an initial
+	string, followed by the total size of the cache.
+`, filepath.Base(os.Args[0]))
+}
+
+func (cmd *sizeCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.sort, "sort", "size", "sort packages by size or name")
+	f.BoolVar(&cmd.bytes, "bytes", false, "print sizes in bytes")
+}
+
+// packageUsage is the disk usage of an installed package.
+type packageUsage struct {
+	name string
+	// files is the size of the files installed by the package and cache
+	// the size of the package file in the cache.
+	files, cache int64
+}
+
+// fileSize returns the size of the file at path, 0 if it does not exist.
+func fileSize(path string) int64 {
+	fi, err := oswrap.Stat(path)
+	if err != nil || fi.IsDir() {
+		return 0
+	}
+	return fi.Size()
+}
+
+// usage returns the disk usage of ps, only files that still exist are counted.
+func usage(ps client.PackageState) packageUsage {
+	u := packageUsage{name: ps.PackageSpec.Name + "." + ps.PackageSpec.Arch + " " + ps.PackageSpec.Version}
+	for file, chksum := range ps.InstalledFiles {
+		// Directories have an empty checksum.
+		if chksum != "" {
+			u.files += fileSize(file)
+		}
+	}
+	if ps.LocalPath != "" {
+		u.cache = fileSize(ps.LocalPath)
+	}
+	return u
+}
+
+// dirSize returns the total size of the files under dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !fi.IsDir() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// formatSize returns n in human readable form using binary prefixes.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func (cmd *sizeCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	var filter string
+	switch f.NArg() {
+	case 0:
+	case 1:
+		filter = f.Arg(0)
+	default:
+		fmt.Fprintln(os.Stderr, "Excessive arguments")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	if cmd.sort != "size" && cmd.sort != "name" {
+		fmt.Fprintf(os.Stderr, "Invalid sort order %q, must be size or name\n", cmd.sort)
+		return subcommands.ExitUsageError
+	}
+
+	state, err := api.ReadState(filepath.Join(rootDir, stateFile))
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	var us []packageUsage
+	for _, ps := range *state {
+		if u := usage(ps); strings.HasPrefix(u.name, filter) {
+			us = append(us, u)
+		}
+	}
+	sort.Slice(us, func(i, j int) bool {
+		if cmd.sort == "size" && us[i].files+us[i].cache != us[j].files+us[j].cache {
+			return us[i].files+us[i].cache > us[j].files+us[j].cache
+		}
+		return us[i].name < us[j].name
+	})
+
+	size := formatSize
+	if cmd.bytes {
+		size = func(n int64) string { return fmt.Sprint(n) }
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Files\tCache\t\t")
+	var files, cache int64
+	for _, u := range us {
+		files += u.files
+		cache += u.cache
+		fmt.Fprintf(w, "%s\t%s\t\t%s\n", size(u.files), size(u.cache), u.name)
+	}
+	fmt.Fprintf(w, "%s\t%s\t\ttotal\n", size(files), size(cache))
+	w.Flush()
+
+	total, err := dirSize(filepath.Join(rootDir, cacheDir))
+	if err != nil {
+		logger.Errorf("Error computing cache size: %v", err)
+		return subcommands.ExitFailure
+	}
+	fmt.Printf("Cache directory: %s\n", size(total))
+	if filter != "" && len(us) == 0 {
+		fmt.Fprintf(os.Stderr, "No package matching filter %q installed.\n", filter)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
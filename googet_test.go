@@ -14,81 +14,42 @@ limitations under the License.
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"os"
+	"os/user"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/googet/v2/api"
 	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/events"
+	"github.com/google/googet/v2/googettest"
 	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/hooks"
+	"github.com/google/googet/v2/install"
 	"github.com/google/googet/v2/oswrap"
-	"github.com/google/googet/v2/priority"
+	"github.com/google/googet/v2/remove"
+	"github.com/google/googet/v2/report"
+	"github.com/google/subcommands"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
-func TestRepoList(t *testing.T) {
-	testRepo := "https://foo.com/googet/bar"
-	testHTTPRepo := "http://foo.com/googet/bar"
-
-	tempDir, err := ioutil.TempDir("", "")
-	if err != nil {
-		t.Fatalf("error creating temp directory: %v", err)
-	}
-	defer oswrap.RemoveAll(tempDir)
-
-	testFile := filepath.Join(tempDir, "test.repo")
-
-	repoTests := []struct {
-		content        []byte
-		want           map[string]priority.Value
-		allowUnsafeURL bool
-	}{
-		{[]byte("\n"), nil, false},
-		{[]byte("# This is just a comment"), nil, false},
-		{[]byte("url: " + testRepo), map[string]priority.Value{testRepo: priority.Default}, false},
-		{[]byte("\n # Comment\nurl: " + testRepo), map[string]priority.Value{testRepo: priority.Default}, false},
-		{[]byte("- url: " + testRepo), map[string]priority.Value{testRepo: priority.Default}, false},
-		// The HTTP repo should be dropped.
-		{[]byte("- url: " + testHTTPRepo), nil, false},
-		// The HTTP repo should not be dropped.
-		{[]byte("- url: " + testHTTPRepo), map[string]priority.Value{testHTTPRepo: priority.Default}, true},
-		{[]byte("- URL: " + testRepo), map[string]priority.Value{testRepo: priority.Default}, false},
-		// The HTTP repo should be dropped.
-		{[]byte("- url: " + testRepo + "\n\n- URL: " + testHTTPRepo), map[string]priority.Value{testRepo: priority.Default}, false},
-		// The HTTP repo should not be dropped.
-		{[]byte("- url: " + testRepo + "\n\n- URL: " + testHTTPRepo), map[string]priority.Value{testRepo: priority.Default, testHTTPRepo: 500}, true},
-		{[]byte("- url: " + testRepo + "\n\n- URL: " + testRepo), map[string]priority.Value{testRepo: priority.Default}, false},
-		{[]byte("- url: " + testRepo + "\n\n- url: " + testRepo), map[string]priority.Value{testRepo: priority.Default}, false},
-		// Should contain oauth- prefix
-		{[]byte("- url: " + testRepo + "\n  useoauth: true"), map[string]priority.Value{"oauth-" + testRepo: priority.Default}, false},
-		// Should not contain oauth- prefix
-		{[]byte("- url: " + testRepo + "\n  useoauth: false"), map[string]priority.Value{testRepo: priority.Default}, false},
-		{[]byte("- url: " + testRepo + "\n  priority: 1200"), map[string]priority.Value{testRepo: priority.Value(1200)}, false},
-		{[]byte("- url: " + testRepo + "\n  priority: default"), map[string]priority.Value{testRepo: priority.Default}, false},
-		{[]byte("- url: " + testRepo + "\n  priority: canary"), map[string]priority.Value{testRepo: priority.Canary}, false},
-		{[]byte("- url: " + testRepo + "\n  priority: pin"), map[string]priority.Value{testRepo: priority.Pin}, false},
-		{[]byte("- url: " + testRepo + "\n  priority: rollback"), map[string]priority.Value{testRepo: priority.Rollback}, false},
-	}
-
-	for i, tt := range repoTests {
-		if err := ioutil.WriteFile(testFile, tt.content, 0660); err != nil {
-			t.Fatalf("error writing repo: %v", err)
-		}
-		allowUnsafeURL = tt.allowUnsafeURL
-		got, err := repoList(tempDir)
-		if err != nil {
-			t.Fatal(err)
-		}
-		if diff := cmp.Diff(tt.want, got, cmpopts.EquateEmpty()); diff != "" {
-			t.Errorf("test case %d: repoList unexpected diff (-want +got): %v", i+1, diff)
-		}
-	}
-}
-
 func TestInstalledPackages(t *testing.T) {
 	state := []client.PackageState{
 		{
@@ -128,7 +89,7 @@ func TestReadConf(t *testing.T) {
 		t.Fatalf("error creating conf file: %v", err)
 	}
 
-	content := []byte("archs: [noarch, x86_64, arm64]\ncachelife: 10m\nallowunsafeurl: true")
+	content := []byte("archs: [noarch, x86_64, arm64]\narchpreference: [arm64]\ncachelife: 10m")
 	if _, err := f.Write(content); err != nil {
 		t.Fatalf("error writing conf file: %v", err)
 	}
@@ -138,7 +99,7 @@ func TestReadConf(t *testing.T) {
 
 	readConf(confPath)
 
-	ea := []string{"noarch", "x86_64", "arm64"}
+	ea := []string{"arm64", "noarch", "x86_64"}
 	if !reflect.DeepEqual(archs, ea) {
 		t.Errorf("readConf did not create expected arch list, want: %s, got: %s", ea, archs)
 	}
@@ -147,156 +108,213 @@ func TestReadConf(t *testing.T) {
 	if cacheLife != ecl {
 		t.Errorf("readConf did not create expected cacheLife, want: %s, got: %s", ecl, cacheLife)
 	}
+}
+
+func TestReadConfTimeouts(t *testing.T) {
+	defer func(d client.Timeouts) { client.DefaultTimeouts = d }(client.DefaultTimeouts)
+	confPath := filepath.Join(t.TempDir(), "test.conf")
+	content := "connecttimeout: 5s\ntlshandshaketimeout: bad\nresponseheadertimeout: 0\nrequesttimeout: 1h\n"
+	if err := ioutil.WriteFile(confPath, []byte(content), 0644); err != nil {
+		t.Fatalf("error writing conf file: %v", err)
+	}
+	want := client.DefaultTimeouts
+	want.Connect, want.ResponseHeader, want.Request = 5*time.Second, 0, time.Hour
+
+	readConf(confPath)
 
-	if allowUnsafeURL != true {
-		t.Error("readConf did not set allowunsafeurl to true")
+	if client.DefaultTimeouts != want {
+		t.Errorf("readConf set timeouts %+v, want %+v", client.DefaultTimeouts, want)
 	}
 }
 
-func TestRotateLog(t *testing.T) {
-	tempDir, err := ioutil.TempDir("", "")
-	if err != nil {
-		t.Fatalf("error creating temp directory: %v", err)
+func TestReadConfHookTimeout(t *testing.T) {
+	defer func(d time.Duration) { hooks.Timeout = d }(hooks.Timeout)
+	confPath := filepath.Join(t.TempDir(), "test.conf")
+	if err := ioutil.WriteFile(confPath, []byte("hooktimeout: bad\n"), 0644); err != nil {
+		t.Fatalf("error writing conf file: %v", err)
 	}
-	defer oswrap.RemoveAll(tempDir)
+	want := hooks.Timeout
 
-	table := []struct {
-		name    string
-		size    int64
-		rotated bool
-	}{
-		{"test1.log", 10 * 1024, true},
-		{"test2.log", 9 * 1024, false},
+	readConf(confPath)
+
+	if hooks.Timeout != want {
+		t.Errorf("readConf with an invalid hooktimeout set the timeout to %v, want the default %v", hooks.Timeout, want)
 	}
+}
 
-	for _, tt := range table {
-		logPath := filepath.Join(tempDir, tt.name)
-		f, err := oswrap.Create(logPath)
-		if err != nil {
-			t.Fatalf("error creating log file: %v", err)
-		}
+func TestReadConfResolveStrategy(t *testing.T) {
+	defer func() { install.ResolveStrategy, strategy = install.Strategy{}, "" }()
+	confPath := filepath.Join(t.TempDir(), "test.conf")
+	if err := ioutil.WriteFile(confPath, []byte("resolvestrategy: prefer-installed-arch,fail-fast\n"), 0644); err != nil {
+		t.Fatalf("error writing conf file: %v", err)
+	}
 
-		if err := f.Truncate(tt.size); err != nil {
-			t.Fatalf("error truncating log file: %v", err)
-		}
+	readConf(confPath)
 
-		if err := f.Close(); err != nil {
-			t.Fatalf("error closing log file: %v", err)
-		}
+	if want := (install.Strategy{PreferInstalledArch: true, FailFast: true}); install.ResolveStrategy != want {
+		t.Errorf("readConf set resolve strategy %+v, want %+v", install.ResolveStrategy, want)
+	}
+}
 
-		if err := rotateLog(logPath, 10*1024); err != nil {
-			t.Errorf("error running rotateLog: %v", err)
+func TestReadConfDropIns(t *testing.T) {
+	confPath := filepath.Join(t.TempDir(), "googet.conf")
+	files := map[string]string{
+		confPath: "cachelife: 10m\nproxyserver: http://proxy:3128\n",
+		filepath.Join(confPath+".d", "20-b.conf"):  "cachelife: 30m\n",
+		filepath.Join(confPath+".d", "10-a.conf"):  "cachelife: 20m\nscantimeout: 1m\n",
+		filepath.Join(confPath+".d", "README.txt"): "cachelife: 1m\n",
+	}
+	if err := os.Mkdir(confPath+".d", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for p, c := range files {
+		if err := ioutil.WriteFile(p, []byte(c), 0644); err != nil {
+			t.Fatalf("error writing conf file: %v", err)
 		}
+	}
+	defer func(d time.Duration) { install.ScanTimeout, proxyServer = d, "" }(install.ScanTimeout)
 
-		switch tt.rotated {
-		case true:
-			if _, err := oswrap.Stat(logPath); err == nil {
-				t.Error("rotateLog did not rotate log as expected, old log file still exists")
-			}
-			if _, err := oswrap.Stat(logPath + ".old"); err != nil {
-				t.Error("rotateLog did not rotate log as expected, .old file does not exist")
-			}
-		case false:
-			if _, err := oswrap.Stat(logPath); err != nil {
-				t.Error("rotateLog rotated a log we didn't expect")
-			}
-		}
+	readConf(confPath)
+
+	if cacheLife != 30*time.Minute {
+		t.Errorf("cacheLife = %v, want 30m from the last fragment", cacheLife)
+	}
+	if proxyServer != "http://proxy:3128" || install.ScanTimeout != time.Minute {
+		t.Errorf("proxyServer = %q, install.ScanTimeout = %v, want the proxy of the conf file and 1m from a fragment", proxyServer, install.ScanTimeout)
 	}
 }
 
-func TestWriteReadState(t *testing.T) {
-	want := &client.GooGetState{
-		client.PackageState{PackageSpec: &goolib.PkgSpec{Name: "test"}},
+func TestEnvConf(t *testing.T) {
+	environ := []string{
+		"GOOGET_CACHELIFE=5m",
+		"GOOGET_PROXY=http://proxy:3128",
+		"GOOGET_ALLOWUNSAFEURL=true",
+		"GOOGET_ARCHS=[noarch, x86_64]",
+		"GOOGET_FILEMODE=0644",
+		"GOOGET_UNKNOWN=1",
+		"PATH=/bin",
 	}
-
-	tempDir, err := ioutil.TempDir("", "")
+	got, err := envConf(environ)
 	if err != nil {
-		t.Fatalf("error creating temp directory: %v", err)
+		t.Fatalf("envConf: %v", err)
+	}
+	want := &conf{
+		CacheLife:      "5m",
+		ProxyServer:    "http://proxy:3128",
+		AllowUnsafeURL: true,
+		Archs:          []string{"noarch", "x86_64"},
+		FileMode:       "0644",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("envConf(%v) got unexpected diff (-want +got):\n%v", environ, diff)
 	}
-	defer oswrap.RemoveAll(tempDir)
-
-	sf := filepath.Join(tempDir, "test.state")
 
-	if err := writeState(want, sf); err != nil {
-		t.Errorf("error running writeState: %v", err)
+	if _, err := envConf([]string{"GOOGET_ALLOWUNSAFEURL=maybe"}); err == nil {
+		t.Error("envConf with an invalid bool got no error")
 	}
+}
 
-	got, err := readState(sf)
+func TestEnvConfOverriddenByFile(t *testing.T) {
+	t.Setenv("GOOGET_CACHELIFE", "5m")
+	t.Setenv("GOOGET_TRASHLIFE", "1h")
+	confPath := filepath.Join(t.TempDir(), "test.conf")
+	if err := ioutil.WriteFile(confPath, []byte("cachelife: 10m\n"), 0644); err != nil {
+		t.Fatalf("error writing conf file: %v", err)
+	}
+	gc, err := envConf(os.Environ())
 	if err != nil {
-		t.Errorf("error running readState: %v", err)
+		t.Fatalf("envConf: %v", err)
 	}
-
-	if !reflect.DeepEqual(got, want) {
-		t.Errorf("did not get expected state, got: %+v, want %+v", got, want)
+	if err := unmarshalConfFile(confPath, gc); err != nil {
+		t.Fatalf("unmarshalConfFile: %v", err)
+	}
+	if gc.CacheLife != "10m" || gc.TrashLife != "1h" {
+		t.Errorf("got cachelife %q and trashlife %q, want 10m from the file and 1h from the environment", gc.CacheLife, gc.TrashLife)
 	}
 }
 
-func TestReadStateRecovery(t *testing.T) {
-	original := &client.GooGetState{
-		client.PackageState{PackageSpec: &goolib.PkgSpec{Name: "test.org"}},
+func TestFlagsFromEnv(t *testing.T) {
+	t.Setenv("GOOGET_NOCONFIRM", "true")
+	t.Setenv("GOOGET_SOURCES", "https://env.example.com")
+	t.Setenv("GOOGET_VERSION", "1.2.3")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	noconfirm := fs.Bool("noconfirm", false, "")
+	sources := fs.String("sources", "", "")
+	version := fs.Bool("version", false, "")
+	if err := fs.Parse([]string{"-sources", "https://flag.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := flagsFromEnv(fs); err != nil {
+		t.Fatalf("flagsFromEnv: %v", err)
+	}
+	if !*noconfirm || *sources != "https://flag.example.com" || *version {
+		t.Errorf("got noconfirm %v, sources %q, version %v, want true, the flag value and false", *noconfirm, *sources, *version)
 	}
 
-	overwrite := &client.GooGetState{
-		client.PackageState{PackageSpec: &goolib.PkgSpec{Name: "test.new"}},
+	t.Setenv("GOOGET_NOCONFIRM", "maybe")
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Bool("noconfirm", false, "")
+	if err := flagsFromEnv(fs); err == nil {
+		t.Error("flagsFromEnv with an invalid bool got no error")
 	}
+}
 
+func TestEnvCmdLockHeld(t *testing.T) {
+	defer func() { lockHeld = false }()
+	lockHeld = true
+	c := envCmd{&installCmd{}}
+	if got := c.Execute(context.Background(), flag.NewFlagSet("install", flag.ContinueOnError)); got != exitLockHeld {
+		t.Errorf("Execute with the lock held = %d, want %d", got, exitLockHeld)
+	}
+}
+
+func TestRotateLog(t *testing.T) {
 	tempDir, err := ioutil.TempDir("", "")
 	if err != nil {
 		t.Fatalf("error creating temp directory: %v", err)
 	}
 	defer oswrap.RemoveAll(tempDir)
 
-	const (
-		deleteState int = iota
-		corruptState
-	)
-
 	table := []struct {
-		name       string
-		disruption int
+		name    string
+		size    int64
+		rotated bool
 	}{
-		{"test-deleted.state", deleteState},
-		{"test-corrupted.state", corruptState},
+		{"test1.log", 10 * 1024, true},
+		{"test2.log", 9 * 1024, false},
 	}
 
 	for _, tt := range table {
-		sf := filepath.Join(tempDir, tt.name)
-
-		if err := writeState(original, sf); err != nil {
-			t.Errorf("error running writeState: %v", err)
+		logPath := filepath.Join(tempDir, tt.name)
+		f, err := oswrap.Create(logPath)
+		if err != nil {
+			t.Fatalf("error creating log file: %v", err)
 		}
 
-		if err := writeState(overwrite, sf); err != nil {
-			t.Errorf("error running writeState second time: %v", err)
+		if err := f.Truncate(tt.size); err != nil {
+			t.Fatalf("error truncating log file: %v", err)
 		}
 
-		got, err := readState(sf)
-		if err != nil {
-			t.Errorf("error running readState: %v", err)
+		if err := f.Close(); err != nil {
+			t.Fatalf("error closing log file: %v", err)
 		}
 
-		if !reflect.DeepEqual(got, overwrite) {
-			t.Errorf("did not get expected state after overwrite, got: %+v, want %+v", got, overwrite)
+		if err := rotateLog(logPath, 10*1024); err != nil {
+			t.Errorf("error running rotateLog: %v", err)
 		}
 
-		switch tt.disruption {
-		case deleteState:
-			if err := oswrap.Remove(sf); err != nil {
-				t.Errorf("error deleting state: %v", err)
+		switch tt.rotated {
+		case true:
+			if _, err := oswrap.Stat(logPath); err == nil {
+				t.Error("rotateLog did not rotate log as expected, old log file still exists")
 			}
-		case corruptState:
-			if err := ioutil.WriteFile(sf, []byte{0, 0, 0, 0}, 0664); err != nil {
-				t.Errorf("error corrupting state: %v", err)
+			if _, err := oswrap.Stat(logPath + ".old"); err != nil {
+				t.Error("rotateLog did not rotate log as expected, .old file does not exist")
+			}
+		case false:
+			if _, err := oswrap.Stat(logPath); err != nil {
+				t.Error("rotateLog rotated a log we didn't expect")
 			}
-		}
-
-		got, err = readState(sf)
-		if err != nil {
-			t.Errorf("error running readState after corruption of active state: %v", err)
-		}
-
-		if !reflect.DeepEqual(got, original) {
-			t.Errorf("did not get expected state after corruption, got: %+v, want %+v", got, original)
 		}
 	}
 }
@@ -329,8 +347,8 @@ func TestCleanOld(t *testing.T) {
 		},
 	}
 
-	if err := writeState(state, filepath.Join(rootDir, stateFile)); err != nil {
-		t.Fatalf("error running writeState: %v", err)
+	if err := api.WriteState(state, filepath.Join(rootDir, stateFile)); err != nil {
+		t.Fatalf("error running api.WriteState: %v", err)
 	}
 
 	cleanOld()
@@ -382,13 +400,19 @@ func TestCleanPackages(t *testing.T) {
 				Name: "notWant",
 			},
 		},
+		{
+			LocalPath: wantFile,
+			PackageSpec: &goolib.PkgSpec{
+				Name: "sharesWant",
+			},
+		},
 	}
 
-	if err := writeState(state, filepath.Join(rootDir, stateFile)); err != nil {
-		t.Fatalf("error running writeState: %v", err)
+	if err := api.WriteState(state, filepath.Join(rootDir, stateFile)); err != nil {
+		t.Fatalf("error running api.WriteState: %v", err)
 	}
 
-	cleanPackages([]string{"notWant"})
+	cleanPackages([]string{"notWant", "sharesWant"})
 
 	if _, err := oswrap.Stat(wantFile); err != nil {
 		t.Errorf("cleanPackages removed wantDir, Stat err: %v", err)
@@ -478,74 +502,839 @@ func TestUpdates(t *testing.T) {
 	}
 }
 
-func TestWriteRepoFile(t *testing.T) {
-	for _, tc := range []struct {
-		name    string
-		entries []repoEntry
-		want    string
-	}{
-		{
-			name:    "with-no-priority-specified",
-			entries: []repoEntry{{Name: "bar", URL: "https://foo.com/googet/bar"}},
-			want: `- name: bar
-  url: https://foo.com/googet/bar
-  useoauth: false
-`,
-		},
-		{
-			name:    "with-default-priority",
-			entries: []repoEntry{{Name: "bar", URL: "https://foo.com/googet/bar", Priority: priority.Default}},
-			want: `- name: bar
-  url: https://foo.com/googet/bar
-  useoauth: false
-  priority: default
-`,
-		},
-		{
-			name:    "with-rollback-priority",
-			entries: []repoEntry{{Name: "bar", URL: "https://foo.com/googet/bar", Priority: priority.Rollback}},
-			want: `- name: bar
-  url: https://foo.com/googet/bar
-  useoauth: false
-  priority: rollback
-`,
+func TestOnlyTagged(t *testing.T) {
+	rm := client.RepoMap{
+		"stable": client.Repo{
+			Priority: 500,
+			Packages: []goolib.RepoSpec{
+				{PackageSpec: &goolib.PkgSpec{Name: "foo", Version: "2.0", Arch: "x86_32", Tags: map[string][]byte{"security": nil}}},
+				{PackageSpec: &goolib.PkgSpec{Name: "bar", Version: "2.0", Arch: "x86_32", Tags: map[string][]byte{"class": []byte("driver")}}},
+				{PackageSpec: &goolib.PkgSpec{Name: "baz", Version: "2.0", Arch: "x86_32"}},
+				{PackageSpec: &goolib.PkgSpec{Name: "new", Version: "1.0", Arch: "x86_32", Tags: map[string][]byte{"class": []byte("agent")}}},
+			},
 		},
-		{
-			name:    "with-non-standard-priority",
-			entries: []repoEntry{{Name: "bar", URL: "https://foo.com/googet/bar", Priority: 42}},
-			want: `- name: bar
-  url: https://foo.com/googet/bar
-  useoauth: false
-  priority: 42
-`,
+	}
+	ud := []goolib.PackageInfo{{Name: "foo", Arch: "x86_32", Ver: "2.0"}, {Name: "bar", Arch: "x86_32", Ver: "2.0"}, {Name: "baz", Arch: "x86_32", Ver: "2.0"}}
+	mg := []api.Migration{{From: goolib.PackageInfo{Name: "old", Arch: "x86_32"}, To: goolib.PackageInfo{Name: "new", Arch: "x86_32", Ver: "1.0"}, Repo: "stable"}}
+
+	gotUD, gotMG := onlyTagged(ud, mg, rm, []string{"security", "class=driver"})
+	if diff := cmp.Diff([]goolib.PackageInfo{{Name: "foo", Arch: "x86_32", Ver: "2.0"}, {Name: "bar", Arch: "x86_32", Ver: "2.0"}}, gotUD); diff != "" {
+		t.Errorf("onlyTagged returned unexpected updates (-want +got):\n%v", diff)
+	}
+	if gotMG != nil {
+		t.Errorf("onlyTagged returned migrations %v, want none", gotMG)
+	}
+	if _, gotMG = onlyTagged(ud, mg, rm, []string{"class"}); len(gotMG) != 1 {
+		t.Errorf("onlyTagged returned migrations %v, want the migration to new", gotMG)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	file := filepath.Join(tempDir, "file")
+	pkg := filepath.Join(tempDir, "cache", "foo.goo")
+	if err := os.Mkdir(filepath.Dir(pkg), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(pkg, make([]byte, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ps := client.PackageState{
+		PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"},
+		LocalPath:   pkg,
+		InstalledFiles: map[string]string{
+			tempDir:                          "",
+			file:                             "chksum",
+			filepath.Join(tempDir, "absent"): "chksum",
 		},
+	}
+	want := packageUsage{name: "foo.noarch 1.0.0@1", files: 100, cache: 10}
+	if got := usage(ps); got != want {
+		t.Errorf("usage(%v) = %+v, want %+v", ps, got, want)
+	}
+	if got, err := dirSize(tempDir); err != nil || got != 110 {
+		t.Errorf("dirSize(%q) = %d, %v, want 110, nil", tempDir, got, err)
+	}
+
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 5 << 30: "5.0 GiB"} {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestPlanApply(t *testing.T) {
+	bar, err := googettest.GenGoo(&goolib.PkgSpec{Name: "bar", Arch: "noarch", Version: "1.0.0@1"}, nil)
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	foo, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "2.0.0@1", PkgDependencies: map[string]string{"bar": "1.0.0@1"}}, nil)
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	srv, repo, err := googettest.ServeGoo(foo, bar)
+	if err != nil {
+		t.Fatalf("error running ServeGoo: %v", err)
+	}
+	defer srv.Close()
+	rm := client.RepoMap{repo: client.Repo{Packages: []goolib.RepoSpec{foo.RepoSpec(), bar.RepoSpec()}}}
+
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+
+	var p plan
+	if err := p.addInstalls(goolib.PackageInfo{Name: "foo", Arch: "noarch", Ver: "2.0.0@1"}, repo, rm, client.GooGetState{}); err != nil {
+		t.Fatalf("error planning install: %v", err)
+	}
+	planFile := filepath.Join(tempDir, "plan.json")
+	f := flag.NewFlagSet("install", flag.ContinueOnError)
+	(&installCmd{}).SetFlags(f)
+	if err := f.Parse([]string{"-sources", repo, "-plan", planFile, "foo"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePlan(planFile, &p, f); err != nil {
+		t.Fatalf("error writing plan: %v", err)
+	}
+	got, err := readPlan(planFile)
+	if err != nil {
+		t.Fatalf("error reading plan: %v", err)
+	}
+	if diff := cmp.Diff([]string{"install", "-sources=" + repo, "foo"}, got.Command); diff != "" {
+		t.Errorf("plan command unexpected (-want +got):\n%s", diff)
+	}
+	var steps []string
+	for _, s := range got.Steps {
+		steps = append(steps, s.Action+" "+s.Package+" "+s.URL)
+	}
+	want := []string{
+		"install bar.noarch.1.0.0@1 " + srv.URL + "/packages/bar.noarch.1.0.0@1.goo",
+		"install foo.noarch.2.0.0@1 " + srv.URL + "/packages/foo.noarch.2.0.0@1.goo",
+	}
+	if diff := cmp.Diff(want, steps); diff != "" {
+		t.Fatalf("plan steps unexpected (-want +got):\n%s", diff)
+	}
+
+	state := &client.GooGetState{}
+	rm = got.repoMap()
+	for _, s := range got.Steps {
+		if err := applyStep(context.Background(), s, tempDir, rm, state); err != nil {
+			t.Fatalf("error applying %+v: %v", s, err)
+		}
+	}
+	if len(*state) != 2 {
+		t.Fatalf("state has %d packages after apply, want 2", len(*state))
+	}
+	for _, ps := range *state {
+		if want := ps.PackageSpec.Name == "bar"; ps.Dependency() != want {
+			t.Errorf("%s installed as a dependency = %v, want %v", ps.PackageSpec, ps.Dependency(), want)
+		}
+	}
+	// Applying the plan again finds both packages installed.
+	if d := got.drift(*state, rm); len(d) != 2 {
+		t.Errorf("drift after apply = %q, want 2 entries", d)
+	}
+
+	p = plan{}
+	deps, _ := remove.EnumerateDeps(goolib.PackageInfo{Name: "bar", Arch: "noarch"}, *state)
+	if err := p.addRemoves(deps, *state); err != nil {
+		t.Fatalf("error planning removal: %v", err)
+	}
+	steps = nil
+	for _, s := range p.Steps {
+		steps = append(steps, s.Action+" "+s.Package)
+	}
+	if diff := cmp.Diff([]string{"remove foo.noarch.2.0.0@1", "remove bar.noarch.1.0.0@1"}, steps); diff != "" {
+		t.Errorf("removal plan unexpected (-want +got):\n%s", diff)
+	}
+}
+
+func TestPlanDrift(t *testing.T) {
+	foo := goolib.RepoSpec{Checksum: "abc", Source: "foo.goo", PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "2.0.0@1"}}
+	p := &plan{Steps: []planStep{
+		{Action: planInstall, Package: "foo.noarch.2.0.0@1", Repo: "repo", Checksum: "abc", RepoSpec: &foo, Installed: "1.0.0@1"},
+		{Action: planRemove, Package: "bar.noarch.1.0.0@1"},
+	}}
+	installed := func(name, ver string) client.PackageState {
+		return client.PackageState{PackageSpec: &goolib.PkgSpec{Name: name, Arch: "noarch", Version: ver}}
+	}
+	state := client.GooGetState{installed("foo", "1.0.0@1"), installed("bar", "1.0.0@1")}
+	rm := client.RepoMap{"repo": client.Repo{Packages: []goolib.RepoSpec{foo}}}
+
+	changed := foo
+	changed.Checksum = "def"
+	table := []struct {
+		state client.GooGetState
+		rm    client.RepoMap
+		want  []string
+	}{
+		{state, rm, nil},
+		{client.GooGetState{installed("foo", "1.5.0@1"), installed("bar", "1.0.0@1")}, rm, []string{
+			"foo.noarch.2.0.0@1: foo.noarch version 1.5.0@1 is installed, the plan assumed version 1.0.0@1",
+		}},
+		{client.GooGetState{installed("foo", "1.0.0@1")}, rm, []string{
+			"bar.noarch.1.0.0@1: bar.noarch is no longer installed, the plan assumed version 1.0.0@1",
+		}},
+		{state, client.RepoMap{"repo": client.Repo{Packages: []goolib.RepoSpec{changed}}}, []string{
+			"foo.noarch.2.0.0@1: checksum in repo repo is now def, the plan has abc",
+		}},
+		{state, client.RepoMap{}, []string{
+			"foo.noarch.2.0.0@1: no longer available in repo repo",
+		}},
+	}
+	for i, tt := range table {
+		if diff := cmp.Diff(tt.want, p.drift(tt.state, tt.rm)); diff != "" {
+			t.Errorf("%d: drift unexpected (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestRepoAllowed(t *testing.T) {
+	defer func() { api.AllowedRepos = nil }()
+	if err := api.RepoAllowed("http://anything.example.com/repo"); err != nil {
+		t.Errorf("repo rejected without allowedrepos: %v", err)
+	}
+
+	api.AllowedRepos = []string{"https://packages.example.com/googet/", "https://*.corp.example.com", "gs://corp-googet"}
+	table := []struct {
+		url   string
+		allow bool
+	}{
+		{"https://packages.example.com/googet/stable", true},
+		{"https://PACKAGES.example.com/googet", true},
+		{"oauth-https://packages.example.com/googet/stable", true},
+		{"https://packages.example.com/googetx", false},
+		{"https://packages.example.com/googet/../other", false},
+		{"http://packages.example.com/googet/stable", false},
+		{"https://user@packages.example.com/googet/stable", false},
+		{"https://repo.corp.example.com/any", true},
+		{"https://corp.example.com.evil.com/any", false},
+		{"https://evilcorp.example.com/any", false},
+		{"gs://corp-googet/repo", true},
+		{"gs://other-bucket/repo", false},
+	}
+	for _, tt := range table {
+		if err := api.RepoAllowed(tt.url); (err == nil) != tt.allow {
+			t.Errorf("api.RepoAllowed(%q) = %v, want allowed: %t", tt.url, err, tt.allow)
+		}
+	}
+
+	if _, err := buildSources("https://packages.example.com/googet/stable,https://example.org/repo"); err == nil {
+		t.Error("buildSources accepted a source that is not allowed")
+	}
+}
+
+func TestHelperPolicy(t *testing.T) {
+	p := &helperPolicy{users: map[string]bool{"1000": true}, groups: map[string]bool{"50": true}, commands: helperDefaultCommands}
+	table := []struct {
+		uid   string
+		gids  []string
+		args  []string
+		allow bool
+	}{
+		{"1000", nil, []string{"install", "foo"}, true},
+		{"1001", []string{"100", "50"}, []string{"remove", "foo"}, true},
+		{"0", nil, []string{"update"}, true},
+		{"1001", []string{"100"}, []string{"install", "foo"}, false},
+		{"1000", nil, []string{"addrepo", "evil", "https://evil.example.com"}, false},
+		{"1000", nil, []string{"-root=/tmp", "install", "foo"}, false},
+		{"1000", nil, []string{"install", "-plan", "/etc/passwd", "foo"}, false},
+		{"1000", nil, []string{"install", "--plan=/etc/passwd", "foo"}, false},
+		{"1000", nil, []string{"install", "-sources", "https://evil.example.com", "foo"}, false},
+		{"1000", nil, []string{"remove", "-report_file=/etc/passwd", "foo"}, false},
+		{"1000", nil, []string{"install", "-reinstall", "foo.x86_64.1.0"}, true},
+		{"1000", nil, []string{"install", "evil.x86_64.1.0.goo"}, false},
+		{"1000", nil, []string{"install", "../evil"}, false},
+		{"1000", nil, []string{"install", `C:\evil`}, false},
+		{"S-1-5-18", nil, []string{"install", "foo"}, true},
+		{"S-1-5-21-1", []string{"S-1-5-32-544"}, []string{"install", "foo"}, true},
+		{"S-1-5-21-1", []string{"S-1-5-32-545"}, []string{"install", "foo"}, false},
+		{"1000", nil, nil, false},
+	}
+	for _, tt := range table {
+		if err := p.allow(tt.uid, tt.gids, tt.args); (err == nil) != tt.allow {
+			t.Errorf("allow(%q, %q, %q) = %v, want allowed: %t", tt.uid, tt.gids, tt.args, err, tt.allow)
+		}
+	}
+}
+
+func TestServeHelper(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the helper is only supported on Linux")
+	}
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+	l, err := net.Listen("unix", filepath.Join(tempDir, helperSocket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	helperStats = newMetrics("googet_helper")
+	defer func(f func(context.Context, helperRequest, io.Reader, io.Writer) (int, error)) { helperRun = f }(helperRun)
+	helperRun = func(_ context.Context, req helperRequest, stdin io.Reader, out io.Writer) (int, error) {
+		answer, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil {
+			return 0, err
+		}
+		fmt.Fprintf(out, "%s: %s", strings.Join(req.Args, " "), answer)
+		return 3, nil
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		args []string
+		want helperMessage
+	}{
+		{[]string{"install", "foo"}, helperMessage{Output: []byte("install foo: y\n")}},
+		{[]string{"clean", "-all"}, helperMessage{Error: `command "clean -all" is not allowed through the GooGet helper, allowed commands are install`}},
 	} {
-		t.Run(tc.name, func(t *testing.T) {
-			f, err := os.CreateTemp("", "test.repo")
+		p, err := newHelperPolicy([]string{u.Uid}, nil, []string{"install"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			c, err := l.Accept()
 			if err != nil {
-				t.Fatalf("os.CreateTemp: %v", err)
+				return
 			}
-			defer func() {
-				os.Remove(f.Name())
-			}()
-			if err := f.Close(); err != nil {
-				t.Fatalf("f.Close: %v", err)
+			serveHelper(context.Background(), c, p)
+		}()
+		c, err := net.Dial("unix", filepath.Join(tempDir, helperSocket))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.NewEncoder(c).Encode(helperRequest{Args: tt.args}); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintln(c, "y")
+		dec := json.NewDecoder(c)
+		var got helperMessage
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("error reading from helper: %v", err)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("helper message unexpected (-want +got):\n%s", diff)
+		}
+		if tt.want.Error == "" {
+			if err := dec.Decode(&got); err != nil {
+				t.Fatalf("error reading from helper: %v", err)
 			}
-			rf := repoFile{fileName: f.Name(), repoEntries: tc.entries}
-			if err := writeRepoFile(rf); err != nil {
-				t.Fatalf("writeRepoFile(%v): %v", rf, err)
+			if !got.Done || got.Exit != 3 {
+				t.Errorf("last helper message = %+v, want exit code 3", got)
 			}
-			b, err := os.ReadFile(f.Name())
-			if err != nil {
-				t.Fatalf("os.ReadFile(%v): %v", f.Name(), err)
+		}
+		c.Close()
+	}
+
+	var b bytes.Buffer
+	helperStats.writeTo(&b, true)
+	for _, want := range []string{
+		`googet_helper_commands_total{command="install",result="failure"} 1`,
+		`googet_helper_commands_total{command="other",result="rejected"} 1`,
+		`googet_helper_command_duration_seconds_count{command="install"} 1`,
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Errorf("metrics output missing %q, got:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestHelperMetrics(t *testing.T) {
+	m := newMetrics("googet_helper")
+	var b bytes.Buffer
+	m.writeTo(&b, true)
+	for _, want := range []string{
+		"googet_helper_last_error_timestamp_seconds 0\n",
+		"googet_helper_last_update_check_timestamp_seconds 0\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics output missing %q, got:\n%s", want, b.String())
+		}
+	}
+
+	m.check(time.Now().Add(-2*time.Second), 4, nil)
+	m.check(time.Now(), 0, errors.New("repo unreachable"))
+	m.command("update", resultSuccess, 3*time.Second)
+	b.Reset()
+	m.writeTo(&b, true)
+	for _, want := range []string{
+		"googet_helper_update_checks_total 2\n",
+		"googet_helper_update_check_errors_total 1\n",
+		"googet_helper_packages_pending 4\n",
+		`googet_helper_command_duration_seconds_sum{command="update"} 3` + "\n",
+		`googet_helper_commands_total{command="update",result="success"} 1` + "\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics output missing %q, got:\n%s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), "googet_helper_last_error_timestamp_seconds 0\n") {
+		t.Error("failed update check did not set the last error time")
+	}
+	if !strings.Contains(b.String(), "googet_helper_last_update_check_duration_seconds 2") {
+		t.Errorf("last update check duration not reported as about 2s, got:\n%s", b.String())
+	}
+}
+
+func TestRecordRun(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+	path := filepath.Join(tempDir, "googet.prom")
+
+	for _, r := range []struct {
+		command string
+		es      subcommands.ExitStatus
+	}{
+		{"install", subcommands.ExitSuccess},
+		{"install", subcommands.ExitFailure},
+		{"update", exitNothingToDo},
+		{"install", subcommands.ExitSuccess},
+	} {
+		if err := recordRun(path, r.command, r.es, time.Second); err != nil {
+			t.Fatalf("recordRun(%q, %d): %v", r.command, r.es, err)
+		}
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`googet_commands_total{command="install",result="success"} 2`,
+		`googet_commands_total{command="install",result="failure"} 1`,
+		`googet_commands_total{command="update",result="success"} 1`,
+		`googet_command_duration_seconds_sum{command="install"} 3`,
+		`googet_command_duration_seconds_count{command="install"} 3`,
+	} {
+		if !strings.Contains(string(b), want+"\n") {
+			t.Errorf("metrics file missing %q, got:\n%s", want, b)
+		}
+	}
+	if strings.Contains(string(b), "googet_last_error_timestamp_seconds 0\n") {
+		t.Error("failed run did not set the last error time")
+	}
+	if strings.Contains(string(b), "update_check") {
+		t.Errorf("metrics file has update check metrics:\n%s", b)
+	}
+}
+
+func TestVerifyScripts(t *testing.T) {
+	defer func(f func(context.Context, client.PackageState, client.Downloader) (bool, error)) { verifyCommand = f }(verifyCommand)
+	verifyCommand = func(ctx context.Context, ps client.PackageState, _ client.Downloader) (bool, error) {
+		switch ps.PackageSpec.Name {
+		case "pass":
+			return true, nil
+		case "fail":
+			return false, nil
+		case "slow":
+			<-ctx.Done()
+			return false, nil
+		}
+		return false, errors.New("broken")
+	}
+	pkg := func(name, script string) client.PackageState {
+		return client.PackageState{PackageSpec: &goolib.PkgSpec{Name: name, Arch: "noarch", Version: "1.0.0@1", Verify: goolib.ExecFile{Path: script}}}
+	}
+	state := client.GooGetState{pkg("pass", "v.sh"), pkg("fail", "v.sh"), pkg("slow", "v.sh"), pkg("broken", "v.sh"), pkg("none", "")}
+
+	for _, tt := range []struct {
+		name, want string
+	}{
+		{"pass", verifyPassed},
+		{"fail", verifyFailed},
+		{"slow", verifyTimedOut},
+		{"broken", verifyError},
+		{"none", verifyNone},
+	} {
+		ps, err := state.GetPackageState(goolib.PackageInfo{Name: tt.name, Arch: "noarch"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := runVerifyScript(context.Background(), ps, 10*time.Millisecond); got != tt.want {
+			t.Errorf("runVerifyScript of %s = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if got := verifyScripts(context.Background(), state, []string{"pass.noarch.1.0.0@1", "none.noarch.1.0.0@1"}, 0); got != subcommands.ExitSuccess {
+		t.Errorf("verifyScripts of passing packages = %v, want %v", got, subcommands.ExitSuccess)
+	}
+	if got := verifyScripts(context.Background(), state, []string{"pass.noarch.1.0.0@1", "slow.noarch.1.0.0@1"}, 10*time.Millisecond); got != subcommands.ExitFailure {
+		t.Errorf("verifyScripts with a timeout = %v, want %v", got, subcommands.ExitFailure)
+	}
+}
+
+func TestInstalledInRoots(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+	defer func(r string) { rootDir = r }(rootDir)
+	rootDir = tempDir
+
+	pkg := func(name string) client.PackageState {
+		return client.PackageState{PackageSpec: &goolib.PkgSpec{Name: name, Arch: "noarch", Version: "1.0.0@1"}}
+	}
+	states := map[string]*client.GooGetState{
+		"a": {pkg("foo"), pkg("bar")},
+		"b": {pkg("foo"), pkg("baz")},
+	}
+	var rl []string
+	for _, r := range []string{"a", "b", "empty", "corrupt"} {
+		root := filepath.Join(tempDir, r)
+		rl = append(rl, root)
+		if err := os.Mkdir(root, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if s, ok := states[r]; ok {
+			if err := api.WriteState(s, filepath.Join(root, stateFile)); err != nil {
+				t.Fatalf("error running api.WriteState: %v", err)
 			}
-			t.Logf("wrote repo file contents:\n%v", string(b))
-			// Make the diff easier to read by splitting into lines first.
-			got := strings.Split(string(b), "\n")
-			want := strings.Split(tc.want, "\n")
-			if diff := cmp.Diff(want, got); diff != "" {
-				t.Errorf("writeRepoFile got unexpected diff (-want +got):\n%v", diff)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "corrupt", stateFile), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(tempDir, "missing")
+	rl = append(rl, missing)
+
+	pkgs, failed := installedInRoots(rl, "ba")
+	var got []string
+	for _, p := range pkgs {
+		got = append(got, p.PackageSpec.Name+" "+filepath.Base(p.root))
+	}
+	if want := []string{"bar a", "baz b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("installedInRoots returned %v, want %v", got, want)
+	}
+	if want := []string{filepath.Join(tempDir, "corrupt"), missing}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("installedInRoots returned failed roots %v, want %v", failed, want)
+	}
+
+	pkgs, _ = installedInRoots(rl[:2], "foo")
+	if len(pkgs) != 2 || pkgs[0].root != rl[0] || pkgs[1].root != rl[1] {
+		t.Errorf("installedInRoots returned %+v, want foo of both roots in order", pkgs)
+	}
+}
+
+func TestAllRoots(t *testing.T) {
+	defer func(r string, o []string) { rootDir, otherRoots = r, o }(rootDir, otherRoots)
+	rootDir = filepath.FromSlash("/googet")
+	otherRoots = []string{filepath.FromSlash("/other/"), filepath.FromSlash("/googet/"), filepath.FromSlash("/other")}
+	if got, want := allRoots(), []string{filepath.FromSlash("/googet"), filepath.FromSlash("/other")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("allRoots() = %v, want %v", got, want)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+	dst := filepath.Join(tempDir, "dst")
+
+	gen := func(name string, files map[string][]byte) *googettest.Package {
+		p, err := googettest.GenGoo(&goolib.PkgSpec{Name: name, Arch: "noarch", Version: "1.0.0@1", Files: map[string]string{"bin": dst}}, files)
+		if err != nil {
+			t.Fatalf("error running GenGoo: %v", err)
+		}
+		return p
+	}
+	old := gen("old", map[string][]byte{"bin/shared": []byte("old"), "bin/gone": []byte("old")})
+	renamed := gen("new", map[string][]byte{"bin/shared": []byte("new")})
+	const repo = "https://repo.example.com/repo"
+	d := googettest.NewDownloader()
+	if err := d.AddRepo(repo, old, renamed); err != nil {
+		t.Fatal(err)
+	}
+	oldRS := old.RepoSpec()
+	rm := client.RepoMap{repo: client.Repo{Packages: []goolib.RepoSpec{oldRS, renamed.RepoSpec()}}}
+	state := &client.GooGetState{}
+	oldPI := goolib.PackageInfo{Name: "old", Arch: "noarch", Ver: "1.0.0@1"}
+	if err := install.FromRepo(context.Background(), oldPI, repo, tempDir, rm, []string{"noarch"}, state, false, d); err != nil {
+		t.Fatalf("error installing old: %v", err)
+	}
+
+	// The repo renames old to new.
+	oldRS.RenamedTo = "new"
+	rm = client.RepoMap{repo: client.Repo{Packages: []goolib.RepoSpec{oldRS, renamed.RepoSpec()}}}
+	pm := installedPackages(*state)
+	if ud := updates(pm, rm); len(ud) != 0 {
+		t.Errorf("updates of renamed package = %v, want none", ud)
+	}
+	mg := migrations(pm, rm)
+	want := []api.Migration{{From: goolib.PackageInfo{Name: "old", Arch: "noarch"}, To: goolib.PackageInfo{Name: "new", Arch: "noarch", Ver: "1.0.0@1"}, Repo: repo}}
+	if !reflect.DeepEqual(mg, want) {
+		t.Fatalf("migrations = %+v, want %+v", mg, want)
+	}
+	if err := install.Migrate(context.Background(), mg[0].From, mg[0].To, mg[0].Repo, tempDir, rm, []string{"noarch"}, state, false, d); err != nil {
+		t.Fatalf("error migrating: %v", err)
+	}
+
+	if len(*state) != 1 || (*state)[0].PackageSpec.Name != "new" {
+		t.Errorf("state after migration = %+v, want only new", *state)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dst, "shared")); err != nil || string(b) != "new" {
+		t.Errorf("shared file after migration = %q, %v, want new", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "gone")); !os.IsNotExist(err) {
+		t.Errorf("file of old not installed by new still exists: %v", err)
+	}
+	ps, _ := state.GetPackageState(goolib.PackageInfo{Name: "new"})
+	if !goolib.ContainsString(dst, ps.CreatedDirs) {
+		t.Errorf("new did not take over the directories created by old, got %v", ps.CreatedDirs)
+	}
+	if mg := migrations(installedPackages(*state), rm); len(mg) != 0 {
+		t.Errorf("migrations after migration = %+v, want none", mg)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	var as []client.Advisory
+	if err := json.Unmarshal([]byte(`[
+  {"id": "A-1", "affected": [{"package": {"name": "foo"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "2.0"}]}]}]},
+  {"id": "A-2", "affected": [{"package": {"name": "bar"}, "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "3.0"}]}]}]},
+  {"id": "A-1", "affected": [{"package": {"name": "foo"}, "versions": ["1.0"]}]}
+]`), &as); err != nil {
+		t.Fatal(err)
+	}
+	state := client.GooGetState{
+		{PackageSpec: &goolib.PkgSpec{Name: "foo", Version: "1.0", Arch: "noarch"}},
+		{PackageSpec: &goolib.PkgSpec{Name: "bar", Version: "1.0", Arch: "noarch"}},
+		{PackageSpec: &goolib.PkgSpec{Name: "baz", Version: "1.0", Arch: "noarch"}},
+	}
+	rm := client.RepoMap{
+		"stable": client.Repo{
+			Priority: 500,
+			Packages: []goolib.RepoSpec{
+				{PackageSpec: &goolib.PkgSpec{Name: "foo", Version: "2.1", Arch: "noarch"}},
+				{PackageSpec: &goolib.PkgSpec{Name: "bar", Version: "2.0", Arch: "noarch"}},
+			},
+		},
+	}
+
+	var got []string
+	for _, f := range audit(state, as, rm) {
+		got = append(got, fmt.Sprintf("%s %s %s %q", f.pkg.Name, f.pkg.Ver, f.advisory.ID, f.available))
+	}
+	want := []string{`bar 1.0 A-2 ""`, `foo 1.0 A-1 "2.1"`}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("audit got unexpected diff (-want +got):\n%v", diff)
+	}
+}
+
+func TestOutcomeStatus(t *testing.T) {
+	verifyErr := fmt.Errorf("error reinstalling package: %w", &goolib.VerificationError{Err: errors.New("checksum mismatch")})
+	for _, tc := range []struct {
+		desc     string
+		o        func(*outcome)
+		detailed subcommands.ExitStatus
+		plain    subcommands.ExitStatus
+	}{
+		{"nothing to do", func(o *outcome) {}, exitNothingToDo, subcommands.ExitSuccess},
+		{"success", func(o *outcome) { o.succeed() }, subcommands.ExitSuccess, subcommands.ExitSuccess},
+		{"failure", func(o *outcome) { o.fail(nil) }, subcommands.ExitFailure, subcommands.ExitFailure},
+		{"partial failure", func(o *outcome) { o.succeed(); o.fail(verifyErr) }, exitPartialFailure, subcommands.ExitFailure},
+		{"verification", func(o *outcome) { o.fail(nil); o.fail(verifyErr) }, exitVerificationFailed, subcommands.ExitFailure},
+		{"verify command", func(o *outcome) { o.failVerification() }, exitVerificationFailed, subcommands.ExitFailure},
+		{"unreachable", func(o *outcome) { o.unreachable = true }, exitRepoUnreachable, subcommands.ExitSuccess},
+		{"unreachable failure", func(o *outcome) { o.unreachable = true; o.fail(nil) }, exitRepoUnreachable, subcommands.ExitFailure},
+		{"unreachable success", func(o *outcome) { o.unreachable = true; o.succeed() }, subcommands.ExitSuccess, subcommands.ExitSuccess},
+	} {
+		var o outcome
+		tc.o(&o)
+		for _, detailed := range []bool{true, false} {
+			detailedExitCodes = detailed
+			want := tc.plain
+			if detailed {
+				want = tc.detailed
 			}
-		})
+			if got := o.status(); got != want {
+				t.Errorf("%s: status with detailedExitCodes=%v = %d, want %d", tc.desc, detailed, got, want)
+			}
+		}
+	}
+	detailedExitCodes = false
+}
+
+func TestWriteReport(t *testing.T) {
+	r := &report.Report{Packages: []*report.Package{
+		{Action: events.Install, Package: "foo.noarch", Version: "2.0"},
+		{Action: events.Install, Package: "bar.noarch", Version: "1.0"},
+		{Action: events.Remove, Package: "baz.noarch", Version: "3.0"},
+	}}
+	before := packageMap{"foo.noarch": "1.0", "baz.noarch": "3.0"}
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := writeReport(path, r, exitRebootRequired, before); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got report.Report
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ExitStatus != int(exitRebootRequired) {
+		t.Errorf("ExitStatus = %d, want %d", got.ExitStatus, exitRebootRequired)
+	}
+	var prev []string
+	for _, p := range got.Packages {
+		prev = append(prev, p.PreviousVersion)
+	}
+	if diff := cmp.Diff([]string{"1.0", "", ""}, prev); diff != "" {
+		t.Errorf("PreviousVersion got unexpected diff (-want +got):\n%v", diff)
+	}
+}
+
+func TestDaemonAuthorize(t *testing.T) {
+	p := &helperPolicy{users: map[string]bool{"1000": true}, groups: map[string]bool{"100": true}, commands: []string{"installed", "update"}}
+	caller := func(uid string, gids ...string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: callerAddr{uid, gids}})
+	}
+	table := []struct {
+		ctx    context.Context
+		method string
+		code   codes.Code
+	}{
+		{caller("0"), "/googet.v1.GooGet/Install", codes.OK},
+		{caller("1000"), "/googet.v1.GooGet/ListInstalled", codes.OK},
+		{caller("1001", "100"), "/googet.v1.GooGet/Update", codes.OK},
+		{caller("1000"), "/googet.v1.GooGet/Install", codes.PermissionDenied},
+		{caller("1001"), "/googet.v1.GooGet/GetStatus", codes.PermissionDenied},
+		{caller("S-1-5-18"), "/googet.v1.GooGet/Remove", codes.OK},
+		{caller("S-1-5-21-1", "S-1-5-32-544"), "/googet.v1.GooGet/Install", codes.OK},
+		{caller("S-1-5-21-1", "S-1-5-32-545"), "/googet.v1.GooGet/Install", codes.PermissionDenied},
+		{context.Background(), "/googet.v1.GooGet/GetStatus", codes.Unauthenticated},
+	}
+	for i, tt := range table {
+		if got := status.Code(authorize(tt.ctx, p, tt.method)); got != tt.code {
+			t.Errorf("%d: authorize(%s) returned code %v, want %v", i, tt.method, got, tt.code)
+		}
+	}
+}
+
+func TestWriteDaemonToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), daemonToken)
+	token, err := writeDaemonToken(path)
+	if err != nil {
+		t.Fatalf("writeDaemonToken: %v", err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 64 || string(b) != token {
+		t.Errorf("writeDaemonToken wrote %q and returned %q, want the same 64 hex digits", b, token)
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+		t.Errorf("%s has mode %v, want 0600", path, fi.Mode().Perm())
+	}
+	again, err := writeDaemonToken(path)
+	if err != nil {
+		t.Fatalf("writeDaemonToken: %v", err)
+	}
+	if again == token {
+		t.Error("writeDaemonToken returned the same token twice")
+	}
+}
+
+func TestExport(t *testing.T) {
+	state := client.GooGetState{
+		{PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "x86_64", Version: "1:1.2.0@3"}, SourceRepo: "https://repo.example.com/stable"},
+		{PackageSpec: &goolib.PkgSpec{Name: "bar", Arch: "noarch", Version: "2.0.0-beta@1"}},
+		// Exported once, with the highest version of both archs.
+		{PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "x86_32", Version: "1:1.1.0@1"}},
+	}
+
+	var buf bytes.Buffer
+	if err := exportWingetList(&buf, state, time.Date(2026, 10, 16, 13, 50, 9, 0, time.UTC)); err != nil {
+		t.Fatalf("exportWingetList: %v", err)
+	}
+	want := `{
+  "$schema": "https://aka.ms/winget-packages.schema.2.0.json",
+  "CreationDate": "2026-10-16T13:50:09Z",
+  "Sources": [
+    {
+      "Packages": [
+        {
+          "PackageIdentifier": "bar",
+          "Version": "2.0.0-beta"
+        },
+        {
+          "PackageIdentifier": "foo",
+          "Version": "1.2.0"
+        }
+      ],
+      "SourceDetails": {
+        "Argument": "https://cdn.winget.microsoft.com/cache",
+        "Identifier": "Microsoft.Winget.Source_8wekyb3d8bbwe",
+        "Name": "winget",
+        "Type": "Microsoft.PreIndexed.Package"
+      }
+    }
+  ]
+}
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("exportWingetList unexpected output (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := exportChocolateyConfig(&buf, state); err != nil {
+		t.Fatalf("exportChocolateyConfig: %v", err)
+	}
+	want = `<?xml version="1.0" encoding="UTF-8"?>
+<packages>
+  <package id="bar" version="2.0.0-beta"></package>
+  <package id="foo" version="1.2.0"></package>
+</packages>
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("exportChocolateyConfig unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestPrintRepoStatus(t *testing.T) {
+	statuses := []client.RepoStatus{
+		{URL: "https://repo.example.com/stable", Reachable: true, Index: "https://repo.example.com/stable/index.gz", Digest: "abc", Packages: 2, Signature: client.SignatureValid, Latency: 120 * time.Millisecond},
+		{URL: "https://repo.example.com/down", Error: "connection refused"},
+	}
+	var buf bytes.Buffer
+	printRepoStatus(&buf, statuses)
+	want := `https://repo.example.com/stable: healthy
+  Index:      https://repo.example.com/stable/index.gz (120ms)
+  Modified:   unknown
+  Digest:     abc
+  Packages:   2
+  Signature:  valid
+https://repo.example.com/down: unhealthy
+  Unreachable: connection refused
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("printRepoStatus unexpected output (-want +got):\n%s", diff)
 	}
 }
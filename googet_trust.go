@@ -0,0 +1,103 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The trust subcommand lists and accepts the pinned keys of repos.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/logger"
+	"github.com/google/subcommands"
+)
+
+type trustCmd struct{}
+
+func (*trustCmd) Name() string     { return "trust" }
+func (*trustCmd) Synopsis() string { return "list or accept pinned repo keys" }
+func (*trustCmd) Usage() string {
+	return fmt.Sprintf(`%[1]s trust list
+%[1]s trust accept <name>...:
+	Lists the TLS certificates and index signing keys pinned for repos, or
+	accepts the changed ones seen for the named hosts and repos.
+`, filepath.Base(os.Args[0]))
+}
+
+func (cmd *trustCmd) SetFlags(f *flag.FlagSet) {}
+
+func (cmd *trustCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Not enough arguments")
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	pins := client.Pins
+	if pins == nil {
+		var err error
+		if pins, err = client.LoadPins(filepath.Join(rootDir, pinsFile)); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	switch f.Arg(0) {
+	case "list":
+		if f.NArg() > 1 {
+			fmt.Fprintln(os.Stderr, "Excessive arguments")
+			f.Usage()
+			return subcommands.ExitUsageError
+		}
+		for _, n := range pins.Names() {
+			p := pins.Get(n)
+			fmt.Println(n + ":")
+			printPin(client.PinCertificate, p.Certificate, p.PendingCertificate)
+			printPin(client.PinKey, p.Key, p.PendingKey)
+		}
+	case "accept":
+		if f.NArg() < 2 {
+			fmt.Fprintln(os.Stderr, "Not enough arguments")
+			f.Usage()
+			return subcommands.ExitUsageError
+		}
+		exitCode := subcommands.ExitSuccess
+		for _, n := range f.Args()[1:] {
+			if err := pins.Accept(n); err != nil {
+				logger.Error(err)
+				exitCode = subcommands.ExitFailure
+				continue
+			}
+			fmt.Printf("Accepted the changed keys of %s.\n", n)
+		}
+		return exitCode
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown trust command %q\n", f.Arg(0))
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	return subcommands.ExitSuccess
+}
+
+func printPin(kind, pinned, pending string) {
+	if pinned == "" {
+		return
+	}
+	fmt.Printf("  %s: %s\n", kind, pinned)
+	if pending != "" {
+		fmt.Printf("  %s (changed, not accepted): %s\n", kind, pending)
+	}
+}
@@ -21,125 +21,182 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/google/googet/v2/api"
 	"github.com/google/googet/v2/client"
 	"github.com/google/googet/v2/goolib"
 	"github.com/google/googet/v2/install"
-	"github.com/google/googet/v2/priority"
 	"github.com/google/logger"
 	"github.com/google/subcommands"
 )
 
 type updateCmd struct {
-	dbOnly  bool
-	sources string
+	dbOnly     bool
+	sources    string
+	plan       string
+	onlyTag    string
+	reportFile string
+	// stopServices sets install.StopServices.
+	stopServices bool
 }
 
 func (*updateCmd) Name() string     { return "update" }
 func (*updateCmd) Synopsis() string { return "update all packages to the latest version available" }
 func (*updateCmd) Usage() string {
-	return fmt.Sprintf("%s update [-sources repo1,repo2...]\n", filepath.Base(os.Args[0]))
+	return fmt.Sprintf("%s update [-sources repo1,repo2...] [-only_tag tag1,tag2...]\n", filepath.Base(os.Args[0]))
 }
 
 func (cmd *updateCmd) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&cmd.dbOnly, "db_only", false, "only make changes to DB, don't perform install system actions")
 	f.StringVar(&cmd.sources, "sources", "", "comma separated list of sources, setting this overrides local .repo files")
+	f.StringVar(&cmd.reportFile, "report_file", "", "write a JSON report of the packages changed, their scripts, the bytes downloaded and the errors to this file")
+	f.StringVar(&cmd.plan, "plan", "", "write the updates to this plan file instead of installing them, see the apply command")
+	f.StringVar(&cmd.onlyTag, "only_tag", "", "comma separated list of tags, KEY or KEY=VALUE, only update to versions having one of them")
+	f.BoolVar(&cmd.stopServices, "stop_services", false, "stop the services using files to replace during the install instead of replacing the files on reboot, Windows only")
 }
 
-func (cmd *updateCmd) Execute(ctx context.Context, _ *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
-	cache := filepath.Join(rootDir, cacheDir)
-	sf := filepath.Join(rootDir, stateFile)
-	state, err := readState(sf)
+func (cmd *updateCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) (es subcommands.ExitStatus) {
+	done := startReport(cmd.reportFile, f)
+	defer func() { done(es) }()
+	install.StopServices = cmd.stopServices
+	var o outcome
+	c, err := newClient(cmd.sources)
 	if err != nil {
 		logger.Fatal(err)
 	}
-
+	c.DBOnly = cmd.dbOnly
+	op, err := c.Begin()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	state := op.State
 	pm := installedPackages(*state)
 	if len(pm) == 0 {
 		fmt.Println("No packages installed.")
-		return subcommands.ExitSuccess
+		return o.status()
 	}
-
-	repos, err := buildSources(cmd.sources)
+	rm, err := op.RepoMap(ctx)
 	if err != nil {
-		logger.Fatal(err)
-	}
-	if repos == nil {
-		logger.Fatal("No repos defined, create a .repo file or pass using the -sources flag.")
+		logger.Fatalf("%v, create a .repo file or pass using the -sources flag.", err)
 	}
-
-	rm := client.AvailableVersions(ctx, repos, filepath.Join(rootDir, cacheDir), cacheLife, proxyServer)
+	o.readRepos(op.Sources(), rm)
 	ud := updates(pm, rm)
-	if ud == nil {
+	mg := migrations(pm, rm)
+	if tags := splitTags(cmd.onlyTag); tags != nil {
+		ud, mg = onlyTagged(ud, mg, rm, tags)
+	}
+	if ud == nil && mg == nil {
 		fmt.Println("No updates available for any installed packages.")
+		return o.status()
+	}
+
+	if cmd.plan != "" {
+		var p plan
+		for _, pi := range ud {
+			r, err := client.WhatRepo(pi, rm)
+			if err == nil {
+				err = p.addInstalls(pi, r, rm, *state)
+			}
+			if err != nil {
+				logger.Errorf("Error planning update of %s.%s.%s: %v", pi.Name, pi.Arch, pi.Ver, err)
+				return subcommands.ExitFailure
+			}
+		}
+		for _, m := range mg {
+			if err := p.addMigration(m.From, m.To, m.Repo, rm, *state); err != nil {
+				logger.Errorf("Error planning migration of %s.%s to %s: %v", m.From.Name, m.From.Arch, m.To.Name, err)
+				return subcommands.ExitFailure
+			}
+		}
+		if err := writePlan(cmd.plan, &p, f); err != nil {
+			logger.Errorf("Error writing plan: %v", err)
+			return subcommands.ExitFailure
+		}
 		return subcommands.ExitSuccess
 	}
 
 	if !noConfirm {
 		if !confirmation("Perform update?") {
 			fmt.Println("Not updating.")
-			return subcommands.ExitSuccess
+			return o.status()
 		}
 	}
 
-	exitCode := subcommands.ExitSuccess
+	pis := ud
+	for _, m := range mg {
+		pis = append(pis, m.To)
+	}
+	if err := op.Prefetch(ctx, pis); err != nil {
+		logger.Error(err)
+	}
 	for _, pi := range ud {
-		r, err := client.WhatRepo(pi, rm)
-		if err != nil {
-			logger.Errorf("Error finding repo: %v.", err)
-		}
-		if err := install.FromRepo(ctx, pi, r, cache, rm, archs, state, cmd.dbOnly, proxyServer); err != nil {
+		if err := op.Update(ctx, pi); err != nil {
 			logger.Errorf("Error updating %s %s %s: %v", pi.Arch, pi.Name, pi.Ver, err)
-			exitCode = subcommands.ExitFailure
+			o.fail(err)
 			continue
 		}
+		o.succeed()
 	}
-
-	if err := writeState(state, sf); err != nil {
-		logger.Fatalf("Error writing state file: %v", err)
+	for _, m := range mg {
+		if err := op.Migrate(ctx, m); err != nil {
+			logger.Errorf("Error migrating %s.%s to %s: %v", m.From.Name, m.From.Arch, m.To.Name, err)
+			o.fail(err)
+			continue
+		}
+		o.succeed()
 	}
-
-	return exitCode
+	return o.status()
 }
 
 func updates(pm packageMap, rm client.RepoMap) []goolib.PackageInfo {
 	fmt.Println("Searching for available updates...")
-	var ud []goolib.PackageInfo
-	for p, ver := range pm {
-		pi := goolib.PkgNameSplit(p)
-		v, r, _, err := client.FindRepoLatest(pi, rm, archs)
-		if err != nil {
-			// This error is because this installed package is not available in a repo.
-			logger.Info(err)
-			continue
-		}
-		c, err := goolib.ComparePriorityVersion(rm[r].Priority, v, priority.Default, ver)
+	ud := api.Updates(pm, rm, archs)
+	for _, pi := range ud {
+		p := pi.Name + "." + pi.Arch
+		r, _ := client.WhatRepo(pi, rm)
+		fmt.Printf("  %s, %s --> %s from %s\n", p, pm[p], pi.Ver, r)
+	}
+	return ud
+}
+
+// migrations returns the migrations of the installed packages in pm that were
+// renamed, see api.Migrations.
+func migrations(pm packageMap, rm client.RepoMap) []api.Migration {
+	mg := api.Migrations(pm, rm, archs)
+	for _, m := range mg {
+		p := m.From.Name + "." + m.From.Arch
+		fmt.Printf("  %s, %s --> %s.%s %s from %s (renamed)\n", p, pm[p], m.To.Name, m.To.Arch, m.To.Ver, m.Repo)
+	}
+	return mg
+}
+
+// onlyTagged returns the updates and migrations to versions tagged with one
+// of tags in their repo.
+func onlyTagged(ud []goolib.PackageInfo, mg []api.Migration, rm client.RepoMap, tags []string) ([]goolib.PackageInfo, []api.Migration) {
+	tagged := func(pi goolib.PackageInfo) bool {
+		r, err := client.WhatRepo(pi, rm)
 		if err != nil {
-			logger.Error(err)
-			continue
-		}
-		if c < 1 {
-			logger.Infof("%s - highest priority version already installed", p)
-			continue
+			return false
 		}
-		// The versions might actually be the same even though the priorities are different,
-		// so do another check to skip reinstall of the same version.
-		c, err = goolib.Compare(v, ver)
-		if err != nil {
-			logger.Error(err)
-			continue
+		rs, err := client.FindRepoSpec(pi, rm[r])
+		if err != nil || !rs.PackageSpec.HasTag(tags...) {
+			logger.Infof("Skipping %s.%s.%s, not tagged %s", pi.Name, pi.Arch, pi.Ver, strings.Join(tags, " or "))
+			return false
 		}
-		if c == 0 {
-			logger.Infof("%s - same version installed", p)
-			continue
+		return true
+	}
+	var tud []goolib.PackageInfo
+	for _, pi := range ud {
+		if tagged(pi) {
+			tud = append(tud, pi)
 		}
-		op := "Upgrade"
-		if c == -1 {
-			op = "Downgrade"
+	}
+	var tmg []api.Migration
+	for _, m := range mg {
+		if tagged(m.To) {
+			tmg = append(tmg, m)
 		}
-		fmt.Printf("  %s, %s --> %s from %s\n", p, ver, v, r)
-		logger.Infof("%s for package %s, %s installed and %s available from %s.", op, p, ver, v, r)
-		ud = append(ud, goolib.PackageInfo{Name: pi.Name, Arch: pi.Arch, Ver: v})
 	}
-	return ud
+	return tud, tmg
 }
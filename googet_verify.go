@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/google/googet/v2/api"
 	"github.com/google/googet/v2/goolib"
 	"github.com/google/googet/v2/install"
 	"github.com/google/googet/v2/verify"
@@ -50,10 +51,10 @@ func (cmd *verifyCmd) Execute(ctx context.Context, flags *flag.FlagSet, _ ...int
 		fmt.Printf("%s\nUsage: %s\n", cmd.Synopsis(), cmd.Usage())
 		return subcommands.ExitFailure
 	}
-	exitCode := subcommands.ExitSuccess
+	var o outcome
 
 	sf := filepath.Join(rootDir, stateFile)
-	state, err := readState(sf)
+	state, err := api.ReadState(sf)
 	if err != nil {
 		logger.Error(err)
 	}
@@ -76,14 +77,14 @@ func (cmd *verifyCmd) Execute(ctx context.Context, flags *flag.FlagSet, _ ...int
 		}
 		if len(ins) > 1 {
 			fmt.Fprintf(os.Stderr, "More than one %s installed, chose one of:\n%s\n", arg, ins)
-			exitCode = subcommands.ExitFailure
+			o.fail(nil)
 			continue
 		}
 
-		v, err := verify.Command(ctx, ps, proxyServer)
+		v, err := verify.Command(ctx, ps, newDownloader())
 		if err != nil {
 			logger.Errorf("Error running verify command for %s: %v", pkg, err)
-			exitCode = subcommands.ExitFailure
+			o.fail(err)
 			continue
 		}
 
@@ -91,7 +92,15 @@ func (cmd *verifyCmd) Execute(ctx context.Context, flags *flag.FlagSet, _ ...int
 			v, err = verify.Files(ps)
 			if err != nil {
 				logger.Errorf("Error running file verification for %s: %v", pkg, err)
-				exitCode = subcommands.ExitFailure
+				o.fail(err)
+				continue
+			}
+		}
+		if v {
+			v, err = verify.MSI(ps)
+			if err != nil {
+				logger.Errorf("Error running MSI verification for %s: %v", pkg, err)
+				o.fail(err)
 				continue
 			}
 		}
@@ -99,17 +108,20 @@ func (cmd *verifyCmd) Execute(ctx context.Context, flags *flag.FlagSet, _ ...int
 			msg := fmt.Sprintf("Verification failed for %s, reinstalling...", pkg)
 			logger.Info(msg)
 			fmt.Println(msg)
-			if err := install.Reinstall(ctx, ps, *state, false, proxyServer); err != nil {
+			if err := install.Reinstall(ctx, ps, state, false, newDownloader()); err != nil {
 				logger.Errorf("Error reinstalling %s, %v", pi.Name, err)
+				o.fail(err)
+				continue
 			}
 		} else if !v {
 			logger.Errorf("Verification failed for %s, reinstall or run verify again with the '-reinstall' flag.", pkg)
-			exitCode = subcommands.ExitFailure
+			o.failVerification()
 			continue
 		}
 		msg := fmt.Sprintf("Verification of %s completed", pkg)
 		logger.Info(msg)
 		fmt.Println(msg)
+		o.succeed()
 	}
-	return exitCode
+	return o.status()
 }
@@ -0,0 +1,207 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package googettest provides utilities for testing code that uses the
+// googet library, such as building packages in memory and serving them
+// from a fake repo without network access.
+package googettest
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+const (
+	// RepoName is the name of the repo served by ServeGoo.
+	RepoName = "repo"
+	// PackagePath is the path under the server root where packages are served.
+	PackagePath = "packages"
+)
+
+// Package is a generated .goo package.
+type Package struct {
+	Spec     *goolib.PkgSpec
+	Data     []byte
+	Checksum string
+}
+
+// Name returns the file name of the package.
+func (p *Package) Name() string {
+	return goolib.PackageInfo{Name: p.Spec.Name, Arch: p.Spec.Arch, Ver: p.Spec.Version}.PkgName()
+}
+
+// RepoSpec returns the RepoSpec describing the package as served by a repo,
+// with the package located under PackagePath.
+func (p *Package) RepoSpec() goolib.RepoSpec {
+	return goolib.RepoSpec{
+		Checksum:    p.Checksum,
+		Source:      path.Join(PackagePath, p.Name()),
+		PackageSpec: p.Spec,
+	}
+}
+
+// GenGoo builds a .goo package from spec containing files, which maps paths
+// inside the package to their contents.
+func GenGoo(spec *goolib.PkgSpec, files map[string][]byte) (*Package, error) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	var names []string
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		fh := &tar.Header{
+			Name:    n,
+			Size:    int64(len(files[n])),
+			ModTime: time.Now(),
+			Mode:    0755,
+		}
+		if err := tw.WriteHeader(fh); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(files[n]); err != nil {
+			return nil, err
+		}
+	}
+	if err := goolib.WritePackageSpec(tw, spec); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return &Package{
+		Spec:     spec,
+		Data:     buf.Bytes(),
+		Checksum: goolib.Checksum(bytes.NewReader(buf.Bytes())),
+	}, nil
+}
+
+// Index returns the JSON repo index listing pkgs.
+func Index(pkgs ...*Package) ([]byte, error) {
+	rs := []goolib.RepoSpec{}
+	for _, p := range pkgs {
+		rs = append(rs, p.RepoSpec())
+	}
+	return json.MarshalIndent(rs, "", "  ")
+}
+
+// ServeGoo starts an HTTP server serving pkgs as a repo named RepoName. It
+// returns the server, which the caller must Close, and the URL of the repo
+// suitable for use as a googet source.
+func ServeGoo(pkgs ...*Package) (*httptest.Server, string, error) {
+	index, err := Index(pkgs...)
+	if err != nil {
+		return nil, "", err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(fmt.Sprintf("/%s/index", RepoName), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(index)
+	})
+	for _, p := range pkgs {
+		data := p.Data
+		mux.HandleFunc("/"+path.Join(PackagePath, p.Name()), func(w http.ResponseWriter, r *http.Request) {
+			w.Write(data)
+		})
+	}
+	srv := httptest.NewServer(mux)
+	return srv, srv.URL + "/" + RepoName, nil
+}
+
+// Downloader is an in-memory client.Downloader. Requests for URLs that have
+// not been added return a 404 response.
+type Downloader struct {
+	mu       sync.Mutex
+	files    map[string][]byte
+	requests []string
+}
+
+// NewDownloader returns an empty Downloader.
+func NewDownloader() *Downloader {
+	return &Downloader{files: make(map[string][]byte)}
+}
+
+// Add serves b at url.
+func (d *Downloader) Add(url string, b []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.files[url] = b
+}
+
+// AddRepo serves pkgs as a repo at repoURL, laid out the same way as
+// ServeGoo.
+func (d *Downloader) AddRepo(repoURL string, pkgs ...*Package) error {
+	index, err := Index(pkgs...)
+	if err != nil {
+		return err
+	}
+	repoURL = strings.TrimSuffix(repoURL, "/")
+	d.Add(repoURL+"/index", index)
+	base := repoURL[:strings.LastIndex(repoURL, "/")]
+	for _, p := range pkgs {
+		d.Add(base+"/"+p.RepoSpec().Source, p.Data)
+	}
+	return nil
+}
+
+// Requests returns the URLs requested so far, in order.
+func (d *Downloader) Requests() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.requests...)
+}
+
+// Get returns the contents added for url.
+func (d *Downloader) Get(ctx context.Context, url string) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	url = strings.TrimPrefix(url, "oauth-")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests = append(d.requests, url)
+	b, ok := d.files[url]
+	if !ok {
+		return &http.Response{
+			Status:     "404 Not Found",
+			StatusCode: http.StatusNotFound,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}, nil
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		ContentLength: int64(len(b)),
+		Body:          ioutil.NopCloser(bytes.NewReader(b)),
+	}, nil
+}
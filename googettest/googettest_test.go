@@ -0,0 +1,131 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package googettest
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/download"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/googet/v2/priority"
+	"github.com/google/logger"
+)
+
+func init() {
+	logger.Init("test", true, false, ioutil.Discard)
+}
+
+func testPackage(t *testing.T) *Package {
+	t.Helper()
+	pkg, err := GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}, map[string][]byte{"bar.txt": []byte("bar")})
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	return pkg
+}
+
+func TestGenGoo(t *testing.T) {
+	pkg := testPackage(t)
+	ps, err := goolib.ExtractPkgSpec(bytes.NewReader(pkg.Data))
+	if err != nil {
+		t.Fatalf("error extracting spec: %v", err)
+	}
+	if ps.String() != "foo.noarch.1.0.0@1" {
+		t.Errorf("unexpected spec in package: got %q, want %q", ps, "foo.noarch.1.0.0@1")
+	}
+	if pkg.Checksum != goolib.Checksum(bytes.NewReader(pkg.Data)) {
+		t.Error("package checksum does not match package data")
+	}
+}
+
+func TestServeGoo(t *testing.T) {
+	pkg := testPackage(t)
+	srv, repo, err := ServeGoo(pkg)
+	if err != nil {
+		t.Fatalf("error running ServeGoo: %v", err)
+	}
+	defer srv.Close()
+
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+
+	rm := client.AvailableVersions(context.Background(), map[string]priority.Value{repo: priority.Default}, tempDir, 0, client.HTTPDownloader{})
+	rs, err := client.FindRepoSpec(goolib.PackageInfo{Name: "foo", Arch: "noarch", Ver: "1.0.0@1"}, rm[repo])
+	if err != nil {
+		t.Fatalf("package not found in served repo: %v", err)
+	}
+	if _, err := download.FromRepo(context.Background(), rs, repo, tempDir, client.HTTPDownloader{}); err != nil {
+		t.Errorf("error downloading package from served repo: %v", err)
+	}
+}
+
+func TestDownloader(t *testing.T) {
+	pkg := testPackage(t)
+	d := NewDownloader()
+	if err := d.AddRepo("https://example.com/repo", pkg); err != nil {
+		t.Fatalf("error running AddRepo: %v", err)
+	}
+
+	table := []struct {
+		url  string
+		code int
+	}{
+		{"https://example.com/repo/index", http.StatusOK},
+		{"https://example.com/packages/foo.noarch.1.0.0@1.goo", http.StatusOK},
+		{"oauth-https://example.com/packages/foo.noarch.1.0.0@1.goo", http.StatusOK},
+		{"https://example.com/repo/index.gz", http.StatusNotFound},
+	}
+	for _, tt := range table {
+		resp, err := d.Get(context.Background(), tt.url)
+		if err != nil {
+			t.Fatalf("error running Get(%q): %v", tt.url, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.code {
+			t.Errorf("Get(%q) returned status %d, want %d", tt.url, resp.StatusCode, tt.code)
+		}
+	}
+	if got := len(d.Requests()); got != len(table) {
+		t.Errorf("Requests returned %d entries, want %d", got, len(table))
+	}
+}
+
+func TestDownloaderRepo(t *testing.T) {
+	pkg := testPackage(t)
+	repo := "https://example.com/repo"
+	d := NewDownloader()
+	if err := d.AddRepo(repo, pkg); err != nil {
+		t.Fatalf("error running AddRepo: %v", err)
+	}
+
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+
+	rm := client.AvailableVersions(context.Background(), map[string]priority.Value{repo: priority.Default}, tempDir, 0, d)
+	if _, err := download.Latest(context.Background(), "foo", tempDir, rm, []string{"noarch"}, d); err != nil {
+		t.Errorf("error downloading package from in-memory repo: %v", err)
+	}
+}
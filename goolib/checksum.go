@@ -0,0 +1,120 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goolib
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// Checksum algorithms of packages. Checksums are the hex digest prefixed
+// with the algorithm and a colon, such as "sha512:...", except for SHA256
+// checksums, which may omit the prefix.
+const (
+	SHA256 = "sha256"
+	SHA512 = "sha512"
+)
+
+// checksumAlgos maps the supported algorithms to their hash and strength.
+var checksumAlgos = map[string]struct {
+	new      func() hash.Hash
+	strength int
+}{
+	SHA256: {sha256.New, 1},
+	SHA512: {sha512.New, 2},
+}
+
+// NewHash returns a new hash of the checksum algorithm algo.
+func NewHash(algo string) (hash.Hash, error) {
+	a, ok := checksumAlgos[strings.ToLower(algo)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", algo)
+	}
+	return a.new(), nil
+}
+
+// FormatChecksum returns the checksum of algo with digest sum, without a
+// prefix for SHA256 so that older clients can read it.
+func FormatChecksum(algo string, sum []byte) string {
+	algo = strings.ToLower(algo)
+	if algo == SHA256 {
+		return hex.EncodeToString(sum)
+	}
+	return algo + ":" + hex.EncodeToString(sum)
+}
+
+// VerificationError is the error of a package that does not match its
+// checksum, its manifest or its signature.
+type VerificationError struct {
+	Err error
+}
+
+func (e *VerificationError) Error() string { return e.Err.Error() }
+
+func (e *VerificationError) Unwrap() error { return e.Err }
+
+// ParseChecksum returns the algorithm and the lower case hex digest of the
+// checksum c.
+func ParseChecksum(c string) (algo, digest string, err error) {
+	algo, digest = SHA256, c
+	if i := strings.Index(c, ":"); i >= 0 {
+		algo, digest = strings.ToLower(c[:i]), c[i+1:]
+	}
+	h, err := NewHash(algo)
+	if err != nil {
+		return "", "", err
+	}
+	if b, err := hex.DecodeString(digest); err != nil || len(b) != h.Size() {
+		return "", "", fmt.Errorf("invalid %s checksum %q", algo, c)
+	}
+	return algo, strings.ToLower(digest), nil
+}
+
+// MatchChecksum reports whether the contents of r have the checksum c.
+func MatchChecksum(r io.Reader, c string) bool {
+	algo, digest, err := ParseChecksum(c)
+	if err != nil {
+		return false
+	}
+	h, _ := NewHash(algo)
+	if _, err := io.Copy(h, r); err != nil {
+		return false
+	}
+	return hex.EncodeToString(h.Sum(nil)) == digest
+}
+
+// StrongestChecksum returns the checksum of the strongest supported
+// algorithm among checksums, or the first one if none is supported.
+func StrongestChecksum(checksums ...string) string {
+	var best string
+	strength := 0
+	for _, c := range checksums {
+		algo, _, err := ParseChecksum(c)
+		if err != nil {
+			continue
+		}
+		if s := checksumAlgos[algo].strength; s > strength {
+			best, strength = c, s
+		}
+	}
+	if best == "" && len(checksums) > 0 {
+		return checksums[0]
+	}
+	return best
+}
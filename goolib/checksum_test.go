@@ -0,0 +1,64 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goolib
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"strings"
+	"testing"
+)
+
+func TestChecksums(t *testing.T) {
+	data := "some content"
+	s256 := sha256.Sum256([]byte(data))
+	s512 := sha512.Sum512([]byte(data))
+	bare := FormatChecksum(SHA256, s256[:])
+	strong := FormatChecksum(SHA512, s512[:])
+	if bare != Checksum(strings.NewReader(data)) {
+		t.Errorf("FormatChecksum(sha256) = %q, want the bare checksum %q", bare, Checksum(strings.NewReader(data)))
+	}
+	if !strings.HasPrefix(strong, "sha512:") {
+		t.Errorf("FormatChecksum(sha512) = %q, want a sha512: prefix", strong)
+	}
+
+	for _, c := range []string{bare, "sha256:" + bare, "SHA256:" + strings.ToUpper(bare), strong} {
+		if !MatchChecksum(strings.NewReader(data), c) {
+			t.Errorf("MatchChecksum(%q) = false, want true", c)
+		}
+		if MatchChecksum(strings.NewReader("other content"), c) {
+			t.Errorf("MatchChecksum of other content with %q = true, want false", c)
+		}
+	}
+	for _, c := range []string{"", "notachecksum", "sha512:" + bare, "md5:" + bare, "sha256:" + bare[:10]} {
+		if _, _, err := ParseChecksum(c); err == nil {
+			t.Errorf("ParseChecksum(%q) returned no error", c)
+		}
+	}
+
+	for _, tt := range []struct {
+		in   []string
+		want string
+	}{
+		{[]string{bare}, bare},
+		{[]string{bare, strong}, strong},
+		{[]string{strong, bare}, strong},
+		{[]string{bare, "sha3-256:abcd"}, bare},
+		{[]string{"md5:abcd"}, "md5:abcd"},
+	} {
+		if got := StrongestChecksum(tt.in...); got != tt.want {
+			t.Errorf("StrongestChecksum(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
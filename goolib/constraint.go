@@ -0,0 +1,271 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goolib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+// constraintOps are the operators of range terms, longer operators first.
+var constraintOps = []string{">=", "<=", "!=", ">", "<", "=", "~", "^"}
+
+// constraintStart are the characters an entry of Replaces or Conflicts
+// continues with after the package name if it has a version range.
+const constraintStart = " =!<>~^"
+
+// A Constraint restricts the versions of a package a dependency, conflict
+// or replacement applies to.
+type Constraint struct {
+	expr string
+	// min is set if expr is a plain version, the minimum version.
+	min bool
+	// alts are alternatives of which one has to match, all the terms of
+	// an alternative have to.
+	alts [][]constraintTerm
+}
+
+type constraintTerm struct {
+	op string
+	v  Version
+	// release is false if the version has no "@release", the release of
+	// versions checked is then ignored.
+	release bool
+}
+
+// ParseConstraint parses a version constraint. A plain version, as used
+// by older packages, is the minimum version. Otherwise s is a range of
+// space separated terms, which all have to match, alternatives of which are
+// separated by "||". A term is one of the operators =, !=, >, >=, < and <=
+// followed by a version, ~VERSION for the versions up to the next minor
+// version, or the next major version if only the major version is given,
+// or ^VERSION for the versions up to the next major version, or the next
+// minor version for 0.x versions. Unlike in plain versions, missing minor
+// and patch versions of range terms are zero, ">=1.2" is ">=1.2.0".
+// An empty constraint matches all versions.
+func ParseConstraint(s string) (Constraint, error) {
+	s = strings.TrimSpace(s)
+	c := Constraint{expr: s}
+	if s == "" {
+		return c, nil
+	}
+	if !strings.ContainsAny(s, constraintStart+"|") {
+		v, err := ParseVersion(s)
+		if err != nil {
+			return c, err
+		}
+		c.min = true
+		c.alts = [][]constraintTerm{{{op: ">=", v: v, release: true}}}
+		return c, nil
+	}
+	for _, alt := range strings.Split(s, "||") {
+		var terms []constraintTerm
+		fields := strings.Fields(alt)
+		for i := 0; i < len(fields); i++ {
+			f := fields[i]
+			// Allow a space between the operator and the version.
+			if isConstraintOp(f) && i+1 < len(fields) {
+				i++
+				f += fields[i]
+			}
+			t, err := parseConstraintTerm(f)
+			if err != nil {
+				return c, fmt.Errorf("can't parse version constraint %q: %v", s, err)
+			}
+			terms = append(terms, t...)
+		}
+		if len(terms) == 0 {
+			return c, fmt.Errorf("can't parse version constraint %q: empty alternative", s)
+		}
+		c.alts = append(c.alts, terms)
+	}
+	return c, nil
+}
+
+func isConstraintOp(s string) bool {
+	for _, op := range constraintOps {
+		if s == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parseConstraintTerm parses a range term, returning the terms it is
+// equivalent to.
+func parseConstraintTerm(s string) ([]constraintTerm, error) {
+	var op string
+	for _, o := range constraintOps {
+		if strings.HasPrefix(s, o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		return nil, fmt.Errorf("version %q has no operator", s)
+	}
+	v, n, release, err := parseRangeVersion(strings.TrimPrefix(s, op))
+	if err != nil {
+		return nil, err
+	}
+	t := constraintTerm{op: op, v: v, release: release}
+	if op != "~" && op != "^" {
+		return []constraintTerm{t}, nil
+	}
+	t.op = ">="
+	sv := v.Semver
+	upper := semver.Version{Major: sv.Major + 1}
+	switch {
+	case op == "~" && n > 1:
+		upper = semver.Version{Major: sv.Major, Minor: sv.Minor + 1}
+	case op == "^" && sv.Major == 0 && n > 1 && (sv.Minor > 0 || n == 2):
+		upper = semver.Version{Minor: sv.Minor + 1}
+	case op == "^" && sv.Major == 0 && n > 2:
+		upper = semver.Version{Minor: sv.Minor, Patch: sv.Patch + 1}
+	}
+	return []constraintTerm{t, {op: "<", v: Version{Epoch: v.Epoch, Semver: upper}}}, nil
+}
+
+// parseRangeVersion parses the version of a range term, returning the
+// number of components given and whether it has a release.
+func parseRangeVersion(s string) (Version, int, bool, error) {
+	epoch, s, err := splitEpoch(s)
+	if err != nil {
+		return Version{}, 0, false, err
+	}
+	ver, rel, release := strings.Cut(s, "@")
+	if ver == "" {
+		return Version{}, 0, false, fmt.Errorf("version missing in %q", s)
+	}
+	num := ver
+	if i := strings.IndexAny(num, "+-"); i != -1 {
+		num = num[:i]
+	}
+	n := len(strings.Split(num, "."))
+	if num != ver && n < 3 {
+		return Version{}, 0, false, fmt.Errorf("version %q has a suffix but no patch version", s)
+	}
+	if n < 3 {
+		ver += strings.Repeat(".0", 3-n)
+	}
+	sv, err := semver.Parse(fixVer(ver))
+	if err != nil {
+		return Version{}, 0, false, fmt.Errorf("can't parse version %q: %v", s, err)
+	}
+	v := Version{Epoch: epoch, Semver: sv}
+	if release {
+		if v.GsVer, err = strconv.ParseInt(rel, 10, 64); err != nil {
+			return Version{}, 0, false, fmt.Errorf("can't parse version %q: %v", s, err)
+		}
+	}
+	return v, n, release, nil
+}
+
+// Check reports whether ver satisfies the constraint.
+func (c Constraint) Check(ver string) (bool, error) {
+	if len(c.alts) == 0 {
+		return true, nil
+	}
+	v, err := ParseVersion(ver)
+	if err != nil {
+		return false, err
+	}
+	for _, alt := range c.alts {
+		ok := true
+		for _, t := range alt {
+			if !t.match(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (t constraintTerm) match(v Version) bool {
+	if !t.release {
+		v.GsVer = t.v.GsVer
+	}
+	c := v.Compare(t.v)
+	switch t.op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
+
+// Range reports whether the constraint is a range rather than empty or a
+// minimum version.
+func (c Constraint) Range() bool {
+	return len(c.alts) != 0 && !c.min
+}
+
+// String returns the constraint as given, saying a plain version is the
+// minimum version.
+func (c Constraint) String() string {
+	if c.min {
+		return c.expr + " or greater"
+	}
+	return c.expr
+}
+
+// SplitConstraint splits an entry of Replaces or Conflicts, either
+// name[.arch][.version] or name[.arch] followed by a version range, into
+// a PackageInfo whose Ver is the version constraint.
+func SplitConstraint(s string) (PackageInfo, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexAny(s, constraintStart)
+	if i == -1 {
+		pi := PkgNameSplit(s)
+		_, err := ParseConstraint(pi.Ver)
+		return pi, err
+	}
+	pi := PkgNameSplit(s[:i])
+	if pi.Ver != "" {
+		return pi, fmt.Errorf("%q has both a version and a version range", s)
+	}
+	pi.Ver = strings.TrimSpace(s[i:])
+	_, err := ParseConstraint(pi.Ver)
+	return pi, err
+}
+
+// constraintNames returns the name[.arch] of entries of Replaces or
+// Conflicts, without their version range.
+func constraintNames(l []string) []string {
+	var names []string
+	for _, s := range l {
+		s = strings.TrimSpace(s)
+		if i := strings.IndexAny(s, constraintStart); i != -1 {
+			s = strings.TrimSpace(s[:i])
+		}
+		names = append(names, s)
+	}
+	return names
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goolib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConstraintCheck(t *testing.T) {
+	table := []struct {
+		c, ver string
+		want   bool
+	}{
+		{"", "0.0.1", true},
+		{"1.2.3@1", "1.2.3@1", true},
+		{"1.2.3@1", "1.2.3@0", false},
+		{"1.2.3@1", "2.0.0", true},
+		{"1.2", "0.1.3", true}, // plain versions are filled from the left
+		{">=1.2 <2.0", "1.2.0@1", true},
+		{">=1.2 <2.0", "1.9.9@3", true},
+		{">=1.2 <2.0", "2.0.0", false},
+		{">=1.2 <2.0", "1.1.9", false},
+		{">= 1.2  < 2.0", "1.5.0", true},
+		{"~1.4", "1.4.7", true},
+		{"~1.4", "1.5.0", false},
+		{"~1.4.2", "1.4.1", false},
+		{"~1", "1.9.0", true},
+		{"~1", "2.0.0", false},
+		{"^1.2", "1.9.0", true},
+		{"^1.2", "2.0.0", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.4", false},
+		{"!=1.5.0", "1.5.0@2", false},
+		{"!=1.5.0", "1.5.1", true},
+		{"!=1.5.0@2", "1.5.0@1", true},
+		{"=1.5.0", "1.5.0@3", true},
+		{">1.5.0@2", "1.5.0@3", true},
+		{">1.5.0", "1.5.0@3", false},
+		{"<=1.5.0@2", "1.5.0@3", false},
+		{"<1 || >=2 !=2.1", "0.9.0", true},
+		{"<1 || >=2 !=2.1", "1.5.0", false},
+		{"<1 || >=2 !=2.1", "2.1.0", false},
+		{"<1 || >=2 !=2.1", "2.2.0", true},
+		{">=1.0.0-beta", "1.0.0-rc1", true},
+		{"2.0.0", "1:1.0.0", true},
+		{"<3.0", "1:1.0.0", false},
+		{">=1:1.0 <1:2.0", "1:1.5.0@2", true},
+		{"~1:1.4", "1:1.4.5", true},
+		{"~1:1.4", "1.4.5", false},
+	}
+	for _, tt := range table {
+		c, err := ParseConstraint(tt.c)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q): %v", tt.c, err)
+			continue
+		}
+		got, err := c.Check(tt.ver)
+		if err != nil {
+			t.Errorf("Check(%q) of %q: %v", tt.ver, tt.c, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Check(%q) of %q = %v, want %v", tt.ver, tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestBadConstraint(t *testing.T) {
+	for _, c := range []string{"1.2a", ">=1.2 2.0", ">=", "<1 ||", "~1.x", ">=1-beta", ">=1.2@x"} {
+		if _, err := ParseConstraint(c); err == nil {
+			t.Errorf("ParseConstraint(%q) did not return an error", c)
+		}
+	}
+}
+
+func TestSplitConstraint(t *testing.T) {
+	table := []struct {
+		s    string
+		want PackageInfo
+	}{
+		{"foo", PackageInfo{"foo", "", ""}},
+		{"foo.x86_64.1.2.3", PackageInfo{"foo", "x86_64", "1.2.3"}},
+		{"foo >=1.2 <2.0", PackageInfo{"foo", "", ">=1.2 <2.0"}},
+		{"foo.noarch~1.4", PackageInfo{"foo", "noarch", "~1.4"}},
+		{"foo!=1.5.0", PackageInfo{"foo", "", "!=1.5.0"}},
+	}
+	for _, tt := range table {
+		got, err := SplitConstraint(tt.s)
+		if err != nil {
+			t.Errorf("SplitConstraint(%q): %v", tt.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitConstraint(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+	for _, s := range []string{"foo.noarch.1.0 <2.0", "foo >=x"} {
+		if _, err := SplitConstraint(s); err == nil {
+			t.Errorf("SplitConstraint(%q) did not return an error", s)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goolib
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// CosignBundleSuffix is appended to the name of a package to name the bundle
+// written by cosign sign-blob --bundle for it.
+const CosignBundleSuffix = ".bundle"
+
+// Certificate extensions holding the OIDC issuer of Fulcio certificates,
+// the first one is deprecated.
+var (
+	oidIssuerV1 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// CosignBundle is a signature of a blob by cosign, with the certificate of
+// the signer for keyless signatures and the transparency log entry if the
+// signature was uploaded to Rekor.
+type CosignBundle struct {
+	Base64Signature string       `json:"base64Signature"`
+	Cert            string       `json:"cert,omitempty"`
+	RekorBundle     *RekorBundle `json:"rekorBundle,omitempty"`
+}
+
+// RekorBundle is a Rekor log entry and the signed timestamp of its inclusion.
+type RekorBundle struct {
+	SignedEntryTimestamp string
+	Payload              RekorPayload
+}
+
+// RekorPayload is a Rekor log entry. Its fields are in the order of the
+// canonical JSON that SignedEntryTimestamp signs.
+type RekorPayload struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+}
+
+// CosignTrust holds the roots keyless signatures are verified against.
+type CosignTrust struct {
+	// Roots and Intermediates are the certificates of the Fulcio CA, there
+	// are no roots if Roots is nil.
+	Roots, Intermediates *x509.CertPool
+	// RekorKeys are the public keys of the Rekor logs trusted to have
+	// recorded the signatures.
+	RekorKeys []crypto.PublicKey
+}
+
+// ParseCosignBundle parses a bundle written by cosign sign-blob --bundle.
+func ParseCosignBundle(b []byte) (*CosignBundle, error) {
+	var cb CosignBundle
+	if err := json.Unmarshal(b, &cb); err != nil {
+		return nil, fmt.Errorf("invalid cosign bundle: %v", err)
+	}
+	if cb.Base64Signature == "" {
+		return nil, errors.New("invalid cosign bundle: no signature")
+	}
+	return &cb, nil
+}
+
+// ReadPublicKey reads a PEM encoded PKIX ECDSA or RSA public key, as written
+// by cosign generate-key-pair.
+func ReadPublicKey(path string) (crypto.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	k, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	switch k.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		return k, nil
+	}
+	return nil, fmt.Errorf("%s: unsupported key type %T, only ECDSA and RSA keys are supported", path, k)
+}
+
+// ReadCertificates reads the PEM encoded certificates in path.
+func ReadCertificates(path string) ([]*x509.Certificate, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		certs = append(certs, c)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded CERTIFICATE", path)
+	}
+	return certs, nil
+}
+
+// verifyDigest verifies the signature sig of the SHA256 digest with key.
+func verifyDigest(key crypto.PublicKey, digest, sig []byte) error {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if ecdsa.VerifyASN1(k, digest, sig) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, sig) == nil {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	return errors.New("signature does not verify")
+}
+
+// VerifyKey verifies that the bundle signs the blob with SHA256 digest with
+// key.
+func (cb *CosignBundle) VerifyKey(digest []byte, key crypto.PublicKey) error {
+	sig, err := base64.StdEncoding.DecodeString(cb.Base64Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	return verifyDigest(key, digest, sig)
+}
+
+// Certificate returns the certificate of a keyless bundle, nil if the
+// bundle has none.
+func (cb *CosignBundle) Certificate() (*x509.Certificate, error) {
+	if cb.Cert == "" {
+		return nil, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(cb.Cert)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %v", err)
+	}
+	der, err := decodePEM(b, "bundle", "CERTIFICATE")
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// VerifyKeyless verifies that the bundle signs the blob with SHA256 digest
+// with a certificate issued by the CA of trust to identity, such as an email
+// address or a workflow URI, authenticated by the OIDC issuer, and that the
+// signature was recorded in a Rekor log of trust while the certificate was
+// valid.
+func (cb *CosignBundle) VerifyKeyless(digest []byte, identity, issuer string, trust CosignTrust) error {
+	cert, err := cb.Certificate()
+	if err != nil {
+		return err
+	}
+	if cert == nil {
+		return errors.New("bundle has no certificate")
+	}
+	if cb.RekorBundle == nil {
+		return errors.New("bundle has no Rekor log entry")
+	}
+	// With nil Roots, Verify would trust the roots of the system.
+	if trust.Roots == nil {
+		return errors.New("no Fulcio roots to verify the certificate against")
+	}
+	if err := cb.verifyRekor(digest, trust.RekorKeys); err != nil {
+		return err
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         trust.Roots,
+		Intermediates: trust.Intermediates,
+		CurrentTime:   time.Unix(cb.RekorBundle.Payload.IntegratedTime, 0),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return fmt.Errorf("certificate does not verify: %v", err)
+	}
+	ids, iss := CertificateIdentity(cert)
+	if iss != issuer {
+		return fmt.Errorf("certificate issued by %q, want %q", iss, issuer)
+	}
+	found := false
+	for _, id := range ids {
+		found = found || id == identity
+	}
+	if !found {
+		return fmt.Errorf("certificate identities %q do not include %q", ids, identity)
+	}
+	return cb.VerifyKey(digest, cert.PublicKey)
+}
+
+// CertificateIdentity returns the email addresses and URIs a Fulcio
+// certificate was issued to, and the OIDC issuer that authenticated them.
+func CertificateIdentity(cert *x509.Certificate) (ids []string, issuer string) {
+	ids = append(ids, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		ids = append(ids, u.String())
+	}
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuerV2):
+			var s string
+			if _, err := asn1.UnmarshalWithParams(ext.Value, &s, "utf8"); err == nil {
+				return ids, s
+			}
+		case ext.Id.Equal(oidIssuerV1):
+			issuer = string(ext.Value)
+		}
+	}
+	return ids, issuer
+}
+
+// verifyRekor verifies the signed entry timestamp of the Rekor entry of the
+// bundle with one of keys, and that the entry records the signature of the
+// bundle for digest.
+func (cb *CosignBundle) verifyRekor(digest []byte, keys []crypto.PublicKey) error {
+	if len(keys) == 0 {
+		return errors.New("no Rekor keys are configured")
+	}
+	rb := cb.RekorBundle
+	set, err := base64.StdEncoding.DecodeString(rb.SignedEntryTimestamp)
+	if err != nil {
+		return fmt.Errorf("invalid Rekor signed entry timestamp: %v", err)
+	}
+	payload, err := json.Marshal(rb.Payload)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(payload)
+	verified := false
+	for _, k := range keys {
+		if verifyDigest(k, sum[:], set) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return errors.New("Rekor signed entry timestamp does not verify")
+	}
+
+	body, err := base64.StdEncoding.DecodeString(rb.Payload.Body)
+	if err != nil {
+		return fmt.Errorf("invalid Rekor entry: %v", err)
+	}
+	var entry struct {
+		Kind string
+		Spec struct {
+			Data struct {
+				Hash struct {
+					Algorithm, Value string
+				}
+			}
+			Signature struct {
+				Content string
+			}
+		}
+	}
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&entry); err != nil {
+		return fmt.Errorf("invalid Rekor entry: %v", err)
+	}
+	h := entry.Spec.Data.Hash
+	if entry.Kind != "hashedrekord" || h.Algorithm != SHA256 || h.Value != hex.EncodeToString(digest) || entry.Spec.Signature.Content != cb.Base64Signature {
+		return errors.New("Rekor entry does not record this signature")
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goolib
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newECDSAKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func signDigest(t *testing.T, k *ecdsa.PrivateKey, digest []byte) string {
+	t.Helper()
+	sig, err := ecdsa.SignASN1(rand.Reader, k, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestCosignVerifyKey(t *testing.T) {
+	key, other := newECDSAKey(t), newECDSAKey(t)
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "cosign.pub")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ReadPublicKey(keyFile)
+	if err != nil {
+		t.Fatalf("ReadPublicKey: %v", err)
+	}
+
+	digest := sha256.Sum256([]byte("package"))
+	b, err := json.Marshal(CosignBundle{Base64Signature: signDigest(t, key, digest[:])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb, err := ParseCosignBundle(b)
+	if err != nil {
+		t.Fatalf("ParseCosignBundle: %v", err)
+	}
+	if err := cb.VerifyKey(digest[:], pub); err != nil {
+		t.Errorf("VerifyKey with the signing key: %v", err)
+	}
+	if err := cb.VerifyKey(digest[:], other.Public()); err == nil {
+		t.Error("VerifyKey with another key got no error")
+	}
+	changed := sha256.Sum256([]byte("changed package"))
+	if err := cb.VerifyKey(changed[:], pub); err == nil {
+		t.Error("VerifyKey of another digest got no error")
+	}
+	if _, err := ParseCosignBundle([]byte("{}")); err == nil {
+		t.Error("ParseCosignBundle of a bundle without signature got no error")
+	}
+}
+
+// keylessTest holds a test Fulcio CA and Rekor log.
+type keylessTest struct {
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	rekor  *ecdsa.PrivateKey
+	issued time.Time
+}
+
+func newKeylessTest(t *testing.T) *keylessTest {
+	t.Helper()
+	kt := &keylessTest{caKey: newECDSAKey(t), rekor: newECDSAKey(t), issued: time.Now().Add(-time.Hour)}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test fulcio"},
+		NotBefore:             kt.issued.Add(-time.Hour),
+		NotAfter:              kt.issued.Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, kt.caKey.Public(), kt.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kt.ca, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	return kt
+}
+
+func (kt *keylessTest) trust() CosignTrust {
+	roots := x509.NewCertPool()
+	roots.AddCert(kt.ca)
+	return CosignTrust{Roots: roots, RekorKeys: []crypto.PublicKey{kt.rekor.Public()}}
+}
+
+// bundle returns a bundle of digest signed by identity authenticated by
+// issuer, logged at integratedTime.
+func (kt *keylessTest) bundle(t *testing.T, digest []byte, identity, issuer string, integratedTime time.Time) *CosignBundle {
+	t.Helper()
+	key := newECDSAKey(t)
+	iss, err := asn1.MarshalWithParams(issuer, "utf8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		NotBefore:       kt.issued,
+		NotAfter:        kt.issued.Add(10 * time.Minute),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{{Id: oidIssuerV2, Value: iss}},
+	}
+	if strings.Contains(identity, "@") {
+		tmpl.EmailAddresses = []string{identity}
+	} else {
+		u, err := url.Parse(identity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl.URIs = []*url.URL{u}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, kt.ca, key.Public(), kt.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	sig := signDigest(t, key, digest)
+
+	body := fmt.Sprintf(`{"apiVersion":"0.0.1","kind":"hashedrekord","spec":{"data":{"hash":{"algorithm":"sha256","value":%q}},"signature":{"content":%q,"publicKey":{"content":%q}}}}`, hex.EncodeToString(digest), sig, cert)
+	payload := RekorPayload{
+		Body:           base64.StdEncoding.EncodeToString([]byte(body)),
+		IntegratedTime: integratedTime.Unix(),
+		LogID:          "c0d23d6ad406973f9559f3ba2d1ca01f84147d8ffc5b8445c224f98b9591801d",
+		LogIndex:       42,
+	}
+	p, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(p)
+	return &CosignBundle{
+		Base64Signature: sig,
+		Cert:            cert,
+		RekorBundle:     &RekorBundle{SignedEntryTimestamp: signDigest(t, kt.rekor, sum[:]), Payload: payload},
+	}
+}
+
+func TestCosignVerifyKeyless(t *testing.T) {
+	kt := newKeylessTest(t)
+	digest := sha256.Sum256([]byte("package"))
+	const (
+		identity = "https://github.com/example/repo/.github/workflows/release.yml@refs/heads/main"
+		issuer   = "https://token.actions.githubusercontent.com"
+	)
+	signed := kt.issued.Add(time.Minute)
+	good := kt.bundle(t, digest[:], identity, issuer, signed)
+
+	tampered := *good
+	tampered.RekorBundle = &RekorBundle{SignedEntryTimestamp: good.RekorBundle.SignedEntryTimestamp, Payload: good.RekorBundle.Payload}
+	tampered.RekorBundle.Payload.IntegratedTime++
+	otherRekor := kt.trust()
+	otherRekor.RekorKeys = []crypto.PublicKey{newECDSAKey(t).Public()}
+	otherCA := kt.trust()
+	otherCA.Roots = newKeylessTest(t).trust().Roots
+	changed := sha256.Sum256([]byte("changed package"))
+
+	for _, tt := range []struct {
+		desc     string
+		bundle   *CosignBundle
+		digest   []byte
+		identity string
+		issuer   string
+		trust    CosignTrust
+		// wantErr is a substring of the error, empty if none is wanted.
+		wantErr string
+	}{
+		{"valid", good, digest[:], identity, issuer, kt.trust(), ""},
+		{"email identity", kt.bundle(t, digest[:], "dev@example.com", "https://accounts.google.com", signed), digest[:], "dev@example.com", "https://accounts.google.com", kt.trust(), ""},
+		{"other identity", good, digest[:], "https://github.com/example/other", issuer, kt.trust(), "do not include"},
+		{"other issuer", good, digest[:], identity, "https://accounts.google.com", kt.trust(), "issued by"},
+		{"other digest", good, changed[:], identity, issuer, kt.trust(), "does not record"},
+		{"logged after expiry", kt.bundle(t, digest[:], identity, issuer, kt.issued.Add(time.Hour)), digest[:], identity, issuer, kt.trust(), "certificate does not verify"},
+		{"tampered log entry", &tampered, digest[:], identity, issuer, kt.trust(), "timestamp does not verify"},
+		{"other Rekor key", good, digest[:], identity, issuer, otherRekor, "timestamp does not verify"},
+		{"no Rekor keys", good, digest[:], identity, issuer, CosignTrust{Roots: kt.trust().Roots}, "no Rekor keys"},
+		{"no roots", good, digest[:], identity, issuer, CosignTrust{RekorKeys: kt.trust().RekorKeys}, "no Fulcio roots"},
+		{"other CA", good, digest[:], identity, issuer, otherCA, "certificate does not verify"},
+	} {
+		err := tt.bundle.VerifyKeyless(tt.digest, tt.identity, tt.issuer, tt.trust)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: VerifyKeyless returned error: %v", tt.desc, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: VerifyKeyless returned %v, want an error containing %q", tt.desc, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goolib
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// newProcessGroup is a no-op on Windows, where taskkill finds the child
+// processes.
+func newProcessGroup(c *exec.Cmd) {}
+
+// killProcessTree kills the started command c and its child processes.
+func killProcessTree(c *exec.Cmd) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(c.Process.Pid)).Run()
+}
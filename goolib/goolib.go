@@ -15,9 +15,13 @@ limitations under the License.
 package goolib
 
 import (
+	"bufio"
+	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -36,6 +40,15 @@ var interpreter = map[string]string{
 	".exe": "cmd",
 }
 
+// unixInterpreter maps the extensions of scripts run by an interpreter on
+// Linux and darwin, so they need not be executable, other files are run
+// directly.
+var unixInterpreter = map[string]string{
+	".sh":   "sh",
+	".bash": "bash",
+	".ps1":  "pwsh",
+}
+
 // scriptInterpreter reads a scripts extension and returns the interpreter to use.
 func scriptInterpreter(s string) (string, error) {
 	ext := filepath.Ext(s)
@@ -50,6 +63,12 @@ func scriptInterpreter(s string) (string, error) {
 // The process is successful if the exit code matches any of those provided or '0'.
 // stdout and stderr are sent to the writer.
 func Exec(s string, args []string, ec []int, w io.Writer) error {
+	return ExecContext(context.Background(), s, args, ec, w)
+}
+
+// ExecContext is like Exec but kills the process, and the processes it
+// started, when ctx is done.
+func ExecContext(ctx context.Context, s string, args []string, ec []int, w io.Writer) error {
 	var c *exec.Cmd
 	switch runtime.GOOS {
 	case "windows":
@@ -68,21 +87,50 @@ func Exec(s string, args []string, ec []int, w io.Writer) error {
 		default:
 			return fmt.Errorf("unknown interpreter: %q", ipr)
 		}
-	case "linux":
-		c = exec.Command(s, args...)
+	case "linux", "darwin":
+		if ipr, ok := unixInterpreter[filepath.Ext(s)]; ok {
+			c = exec.Command(ipr, append([]string{s}, args...)...)
+		} else {
+			c = exec.Command(s, args...)
+		}
 	default:
-		return fmt.Errorf("OS %q is not Windows or Linux", runtime.GOOS)
+		return fmt.Errorf("OS %q is not Windows, Linux or darwin", runtime.GOOS)
 	}
-	return Run(c, ec, w)
+	return RunContext(ctx, c, ec, w)
 }
 
 // Run runs a command.
 // The process is successful if the exit code matches any of those provided or '0'.
 // stdout and stderr are sent to the writer and to this process's stdout and stderr.
 func Run(c *exec.Cmd, ec []int, w io.Writer) error {
+	return RunContext(context.Background(), c, ec, w)
+}
+
+// RunContext is like Run but kills the process, and the processes it started,
+// when ctx is done.
+func RunContext(ctx context.Context, c *exec.Cmd, ec []int, w io.Writer) error {
 	c.Stdout = io.MultiWriter(os.Stdout, w)
 	c.Stderr = io.MultiWriter(os.Stderr, w)
-	if err := c.Run(); err != nil {
+	newProcessGroup(c)
+	if err := c.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- c.Wait() }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		if killProcessTree(c) != nil {
+			c.Process.Kill()
+		}
+		<-done
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("command %s timed out", c.Path)
+		}
+		return fmt.Errorf("command %s canceled: %v", c.Path, ctx.Err())
+	}
+	if err != nil {
 		e, ok := err.(*exec.ExitError)
 		if !ok {
 			return err
@@ -92,12 +140,22 @@ func Run(c *exec.Cmd, ec []int, w io.Writer) error {
 			return err
 		}
 		if !ContainsInt(s.ExitStatus(), ec) {
-			return fmt.Errorf("command exited with error code %v", s.ExitStatus())
+			return &ExitCodeError{Code: s.ExitStatus()}
 		}
 	}
 	return nil
 }
 
+// ExitCodeError is returned by Run when a command exits with an exit code
+// that is not a success.
+type ExitCodeError struct {
+	Code int
+}
+
+func (e *ExitCodeError) Error() string {
+	return fmt.Sprintf("command exited with error code %v", e.Code)
+}
+
 // PackageInfo describes the name arch and version of a package.
 type PackageInfo struct {
 	Name, Arch, Ver string
@@ -113,9 +171,10 @@ func (pi PackageInfo) String() string {
 	return pi.Name
 }
 
-// PkgName returns the proper goo package name.
+// PkgName returns the proper goo package name. The ":" after the epoch of
+// the version, which Windows does not allow in file names, becomes "_".
 func (pi PackageInfo) PkgName() string {
-	return fmt.Sprintf("%s.%s.%s.goo", pi.Name, pi.Arch, pi.Ver)
+	return fmt.Sprintf("%s.%s.%s.goo", pi.Name, pi.Arch, strings.Replace(pi.Ver, ":", "_", 1))
 }
 
 // PkgNameSplit returns the PackageInfo from a package name.
@@ -132,6 +191,61 @@ func PkgNameSplit(pn string) PackageInfo {
 	return PackageInfo{pi[0], "", ""}
 }
 
+// Reasons of a PkgNameError.
+var (
+	ErrInvalidName    = errors.New("invalid name")
+	ErrAmbiguousArch  = errors.New("ambiguous arch")
+	ErrInvalidVersion = errors.New("invalid version")
+)
+
+// A PkgNameError reports why ParsePkgName could not parse a package name.
+type PkgNameError struct {
+	Name string
+	// Err is ErrInvalidName, ErrAmbiguousArch or ErrInvalidVersion.
+	Err    error
+	Detail string
+}
+
+func (e *PkgNameError) Error() string {
+	return fmt.Sprintf("package %q: %v: %s", e.Name, e.Err, e.Detail)
+}
+
+func (e *PkgNameError) Unwrap() error {
+	return e.Err
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_+-]*$`)
+
+// ParsePkgName parses a package name of the form name[.arch[.version]] like
+// PkgNameSplit, but returns a *PkgNameError instead of guessing if the name
+// is invalid, the arch is not a known architecture or the version can't be
+// parsed.
+func ParsePkgName(pn string) (PackageInfo, error) {
+	pn = strings.TrimSpace(pn)
+	pi := PkgNameSplit(pn)
+	if !validName.MatchString(pi.Name) {
+		return pi, &PkgNameError{pn, ErrInvalidName, fmt.Sprintf("%q is not a valid package name", pi.Name)}
+	}
+	if pi.Arch == "" {
+		if strings.Contains(pn, ".") {
+			return pi, &PkgNameError{pn, ErrAmbiguousArch, "no arch after the name"}
+		}
+		return pi, nil
+	}
+	if !ContainsString(pi.Arch, validArch) {
+		return pi, &PkgNameError{pn, ErrAmbiguousArch, fmt.Sprintf("%q is not one of the architectures %s, give the arch before the version", pi.Arch, strings.Join(validArch, ", "))}
+	}
+	if pi.Ver == "" && strings.Count(pn, ".") > 1 {
+		return pi, &PkgNameError{pn, ErrInvalidVersion, "no version after the arch"}
+	}
+	if pi.Ver != "" {
+		if _, err := ParseVersion(pi.Ver); err != nil {
+			return pi, &PkgNameError{pn, ErrInvalidVersion, err.Error()}
+		}
+	}
+	return pi, nil
+}
+
 // Checksum retuns the SHA256 checksum of the provided reader.
 func Checksum(r io.Reader) string {
 	hash := sha256.New()
@@ -139,10 +253,34 @@ func Checksum(r io.Reader) string {
 	return hex.EncodeToString(hash.Sum(nil))
 }
 
+// Magic numbers of the compression formats of packages.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// NewPackageReader returns a reader of the tar archive in the package read
+// from r. The compression is detected from the first bytes of the package,
+// only gzip is supported.
+func NewPackageReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, zstdMagic):
+		return nil, errors.New("package is zstd compressed, which this version of GooGet does not support")
+	}
+	return nil, errors.New("package is not gzip compressed")
+}
+
 // ExtractPkgSpec pulls and unmarshals the package spec file from a
 // reader.
 func ExtractPkgSpec(r io.Reader) (*PkgSpec, error) {
-	zr, err := gzip.NewReader(r)
+	zr, err := NewPackageReader(r)
 	if err != nil {
 		return nil, err
 	}
@@ -195,3 +333,35 @@ func SplitGCSUrl(p string) (bool, string, string) {
 
 	return false, "", ""
 }
+
+// PathMatch is a simpler filepath.Match but which supports recursive globbing
+// (**) and doesn't get any more special than * or **.
+func PathMatch(pattern, path string) (bool, error) {
+	regex := []rune("^")
+	runePattern := []rune(pattern)
+	for i := 0; i < len(runePattern); i++ {
+		ch := runePattern[i]
+		switch ch {
+		default:
+			regex = append(regex, ch)
+		case '%', '\\', '(', ')', '[', ']', '.', '^', '$', '?', '+', '{', '}', '=':
+			regex = append(regex, '\\', ch)
+		case '*':
+			if i+1 < len(runePattern) && runePattern[i+1] == '*' {
+				if i+2 < len(runePattern) && runePattern[i+2] == '*' {
+					return false, fmt.Errorf("%s: malformed glob", pattern)
+				}
+				regex = append(regex, []rune(".*")...)
+				i++
+			} else {
+				regex = append(regex, []rune("[^/]*")...)
+			}
+		}
+	}
+	regex = append(regex, '$')
+	re, err := regexp.Compile(string(regex))
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(path), nil
+}
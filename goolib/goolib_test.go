@@ -14,8 +14,17 @@ limitations under the License.
 package goolib
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -50,6 +59,56 @@ func TestBadScriptInterpreter(t *testing.T) {
 	}
 }
 
+func TestRunContextTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test command is a shell script")
+	}
+	// The child left running would write marker after the command is killed.
+	marker := filepath.Join(t.TempDir(), "marker")
+	c := exec.Command("sh", "-c", `(sleep 1; touch "$1") & sleep 10`, "sh", marker)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := RunContext(ctx, c, nil, ioutil.Discard)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("RunContext returned error %v, want a timeout", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("RunContext returned after %v, want the command killed", d)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("child process of the command was not killed")
+	}
+
+	if err := RunContext(context.Background(), exec.Command("sh", "-c", "exit 3"), []int{3}, ioutil.Discard); err != nil {
+		t.Errorf("RunContext with an allowed exit code returned error %v", err)
+	}
+	err = RunContext(context.Background(), exec.Command("sh", "-c", "exit 4"), []int{3}, ioutil.Discard)
+	var ee *ExitCodeError
+	if !errors.As(err, &ee) || ee.Code != 4 {
+		t.Errorf("RunContext with exit code 4 returned error %v, want an ExitCodeError with code 4", err)
+	}
+}
+
+func TestExecUnixScript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test script is run by sh")
+	}
+	// The script is not executable and so has to be run by sh.
+	s := filepath.Join(t.TempDir(), "install.sh")
+	if err := ioutil.WriteFile(s, []byte(`echo "$1"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := Exec(s, []string{"hello"}, nil, &b); err != nil {
+		t.Fatalf("Exec(%q) returned error %v", s, err)
+	}
+	if got := b.String(); got != "hello\n" {
+		t.Errorf("Exec(%q) wrote %q, want %q", s, got, "hello\n")
+	}
+}
+
 func TestContainsInt(t *testing.T) {
 	table := []struct {
 		a     int
@@ -90,6 +149,52 @@ func randString(runes []rune, min, max int) string {
 	return string(s)
 }
 
+func TestPkgName(t *testing.T) {
+	for pi, want := range map[PackageInfo]string{
+		{"foo", "noarch", "1.2.3@4"}:   "foo.noarch.1.2.3@4.goo",
+		{"foo", "noarch", "2:1.2.3@4"}: "foo.noarch.2_1.2.3@4.goo",
+	} {
+		if got := pi.PkgName(); got != want {
+			t.Errorf("PkgName() of %v = %q, want %q", pi, got, want)
+		}
+	}
+}
+
+func TestParsePkgName(t *testing.T) {
+	for _, tt := range []struct {
+		pn   string
+		want PackageInfo
+		err  error
+	}{
+		{"foo", PackageInfo{"foo", "", ""}, nil},
+		{"foo.x86_64", PackageInfo{"foo", "x86_64", ""}, nil},
+		{" foo.noarch.1.2.3@4 ", PackageInfo{"foo", "noarch", "1.2.3@4"}, nil},
+		{"foo.noarch.2:1.0", PackageInfo{"foo", "noarch", "2:1.0"}, nil},
+		{"foo.1.2.3", PackageInfo{}, ErrAmbiguousArch},
+		{"foo.", PackageInfo{}, ErrAmbiguousArch},
+		{"foo.x86_64.", PackageInfo{}, ErrInvalidVersion},
+		{"foo.x86_64.1.2.x", PackageInfo{}, ErrInvalidVersion},
+		{"", PackageInfo{}, ErrInvalidName},
+		{"foo bar", PackageInfo{}, ErrInvalidName},
+	} {
+		got, err := ParsePkgName(tt.pn)
+		if tt.err != nil {
+			var pe *PkgNameError
+			if !errors.As(err, &pe) || !errors.Is(err, tt.err) {
+				t.Errorf("ParsePkgName(%q) returned error %v, want a PkgNameError of %v", tt.pn, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParsePkgName(%q): %v", tt.pn, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParsePkgName(%q) = %+v, want %+v", tt.pn, got, tt.want)
+		}
+	}
+}
+
 func TestSplitGCSUrl(t *testing.T) {
 	rand.Seed(time.Now().UnixNano())
 	const alphanum = "abcdefghijklmnopqrstuvwxyz0123456789"
@@ -163,3 +268,49 @@ func TestSplitGCSUrl(t *testing.T) {
 		}
 	}
 }
+
+func TestNewPackageReader(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("tar"))
+	zw.Close()
+
+	r, err := NewPackageReader(&gz)
+	if err != nil {
+		t.Fatalf("error reading gzip package: %v", err)
+	}
+	if b, err := ioutil.ReadAll(r); err != nil || string(b) != "tar" {
+		t.Errorf("gzip package contents = %q, %v, want %q", b, err, "tar")
+	}
+
+	for _, b := range [][]byte{{0x28, 0xb5, 0x2f, 0xfd, 0}, []byte("tar"), nil} {
+		if _, err := NewPackageReader(bytes.NewReader(b)); err == nil {
+			t.Errorf("NewPackageReader(%q) did not return an error", b)
+		}
+	}
+}
+
+func TestPathMatch(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		result        bool
+	}{
+		{"/path**.file", "/path/to.file", true},
+		{"/path[a-z]", "/pathb", false},
+		{"/path[a-z]", "/path[a-z]", true},
+		{"path/*/file", "path/to/file", true},
+		{"path/*/file", "path/to/the/file", false},
+		{"path/**/file", "path/to/the/file", true},
+		{"^$[a(-z])%{}}\\{{\\", "^$[a(-z])%{}}\\{{\\", true},
+	}
+
+	for _, test := range tests {
+		res, err := PathMatch(test.pattern, test.path)
+		if err != nil {
+			t.Fatalf("match %q %q: %v", test.pattern, test.path, err)
+		}
+		if res != test.result {
+			t.Fatalf("match %q %q: expected %v got %v", test.pattern, test.path, test.result, res)
+		}
+	}
+}
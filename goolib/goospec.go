@@ -22,12 +22,15 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"text/template"
+	"text/template/parse"
 	"time"
 
 	"github.com/blang/semver"
@@ -50,12 +53,146 @@ type GooSpec struct {
 	Build       build
 	Sources     []PkgSources
 	PackageSpec *PkgSpec
+	// Archs, if set, lists the architectures the package is built for, the
+	// Arch of PackageSpec is then ignored.
+	Archs map[string]ArchSpec `json:",omitempty"`
+	// Subpackages, if set, are additional packages built from the goospec,
+	// such as foo-dev or foo-debug, keyed by their name.
+	Subpackages map[string]SubpackageSpec `json:",omitempty"`
+}
+
+// ArchSpec overrides the build command and sources of a GooSpec when
+// building for one architecture, unset fields are inherited.
+type ArchSpec struct {
+	Build   *build
+	Sources []PkgSources
+}
+
+// SubpackageSpec describes a package built along with the PackageSpec of a
+// GooSpec, sharing its version, arch and metadata.
+type SubpackageSpec struct {
+	Description     string `json:",omitempty"`
+	Sources         []PkgSources
+	Files           map[string]string         `json:",omitempty"`
+	FileAttributes  map[string]FileAttributes `json:",omitempty"`
+	PkgDependencies map[string]string         `json:",omitempty"`
+	Install         ExecFile
+	Uninstall       ExecFile
+	Verify          ExecFile
+}
+
+// TargetArchs returns the architectures gs builds packages for.
+func (gs GooSpec) TargetArchs() []string {
+	if len(gs.Archs) == 0 {
+		return []string{gs.PackageSpec.Arch}
+	}
+	var archs []string
+	for a := range gs.Archs {
+		archs = append(archs, a)
+	}
+	sort.Strings(archs)
+	return archs
+}
+
+// ForArch returns a copy of gs for building the package for arch, with the
+// overrides for arch applied. If gs declares Archs, arch must be one of them.
+func (gs GooSpec) ForArch(arch string) (*GooSpec, error) {
+	if len(gs.Archs) > 0 {
+		as, ok := gs.Archs[arch]
+		if !ok {
+			return nil, fmt.Errorf("architecture %q is not declared in Archs", arch)
+		}
+		if as.Build != nil {
+			gs.Build = *as.Build
+		}
+		if as.Sources != nil {
+			gs.Sources = as.Sources
+		}
+		gs.Archs = nil
+	}
+	ps := *gs.PackageSpec
+	ps.Arch = arch
+	gs.PackageSpec = &ps
+	return &gs, nil
+}
+
+// SubpackageNames returns the names of the Subpackages of gs, sorted.
+func (gs GooSpec) SubpackageNames() []string {
+	var names []string
+	for n := range gs.Subpackages {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Subpackage returns a GooSpec for building the subpackage name of gs. Its
+// package depends on the exact version and arch of the package of gs and
+// names it as Parent.
+func (gs GooSpec) Subpackage(name string) (*GooSpec, error) {
+	sp, ok := gs.Subpackages[name]
+	if !ok {
+		return nil, fmt.Errorf("subpackage %q is not declared in Subpackages", name)
+	}
+	if name == gs.PackageSpec.Name || strings.Contains(name, ".") || !validPkgName.MatchString(name) {
+		return nil, fmt.Errorf("invalid subpackage name %q", name)
+	}
+	v, err := ParseVersion(gs.PackageSpec.Version)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse %q: %v", gs.PackageSpec.Version, err)
+	}
+	ps := *gs.PackageSpec
+	ps.Name = name
+	ps.Parent = gs.PackageSpec.Name
+	if sp.Description != "" {
+		ps.Description = sp.Description
+	}
+	ps.Files, ps.FileAttributes = sp.Files, sp.FileAttributes
+	ps.Install, ps.Uninstall, ps.Verify = sp.Install, sp.Uninstall, sp.Verify
+	ps.Replaces, ps.Conflicts = nil, nil
+	ps.PkgDependencies = map[string]string{
+		ps.Parent + "." + ps.Arch: fmt.Sprintf("=%s%s@%d", epochPrefix(v), v.Semver, v.GsVer),
+	}
+	for p, c := range sp.PkgDependencies {
+		ps.PkgDependencies[p] = c
+	}
+	ps.normalize()
+	return &GooSpec{Sources: sp.Sources, PackageSpec: &ps}, nil
 }
 
 // RepoSpec is the repository specification of a package.
 type RepoSpec struct {
 	Checksum, Source string
 	PackageSpec      *PkgSpec
+	// Checksums are further checksums of the package, usually of stronger
+	// algorithms than the SHA256 of Checksum, see BestChecksum.
+	Checksums []string `json:",omitempty"`
+	// Sources of the optional sidecar files for this package, relative in the
+	// same way as Source.
+	SpecSource      string `json:",omitempty"`
+	ManifestSource  string `json:",omitempty"`
+	SignatureSource string `json:",omitempty"`
+	// Status is set by the repo maintainer to StatusYanked or
+	// StatusDeprecated, StatusReason optionally explains why.
+	Status       string `json:",omitempty"`
+	StatusReason string `json:",omitempty"`
+	// RenamedTo is set by the repo maintainer to the new name of a renamed
+	// package, clients install and update to the new name instead.
+	RenamedTo string `json:",omitempty"`
+}
+
+const (
+	// StatusYanked marks a version that clients do not install unless
+	// forced, existing installs are left alone.
+	StatusYanked = "yanked"
+	// StatusDeprecated marks a version that is installed with a warning.
+	StatusDeprecated = "deprecated"
+)
+
+// BestChecksum returns the strongest checksum of the package this client
+// supports.
+func (rs *RepoSpec) BestChecksum() string {
+	return StrongestChecksum(append([]string{rs.Checksum}, rs.Checksums...)...)
 }
 
 // Marshal returns the formatted RepoSpec.
@@ -69,6 +206,10 @@ const (
 	maxTagValueSize = 1024 * 10 // 10k
 )
 
+// SpecSidecarSuffix is appended to the name of a package file to name the
+// sidecar file holding its JSON encoded PkgSpec.
+const SpecSidecarSuffix = pkgSpecSuffix
+
 var validArch = []string{"noarch", "x86_64", "x86_32", "arm", "arm64"}
 
 // PkgSpec is an individual package specification.
@@ -90,6 +231,225 @@ type PkgSpec struct {
 	Uninstall       ExecFile
 	Verify          ExecFile
 	Files           map[string]string `json:",omitempty"`
+	// FileMode and DirMode override the octal permissions of the files and
+	// directories created when installing the package on Linux and darwin.
+	FileMode string `json:",omitempty"`
+	DirMode  string `json:",omitempty"`
+	// FileAttributes are applied to installed files and directories, keyed
+	// by their path in the package.
+	FileAttributes map[string]FileAttributes `json:",omitempty"`
+	// Interpreters are the programs the install, uninstall and verify
+	// scripts run with.
+	Interpreters []Interpreter `json:",omitempty"`
+	// Publisher identifies who published the package, clients can require
+	// it to be proven by an embedded signature made with the publisher's key.
+	Publisher string `json:",omitempty"`
+	// Parent is the name of the package a subpackage was built with, see
+	// GooSpec.Subpackage.
+	Parent string `json:",omitempty"`
+	// Env declares changes to the machine environment made on install and
+	// undone on removal.
+	Env *EnvSpec `json:",omitempty"`
+	// Shortcuts are the Start Menu shortcuts created on Windows.
+	Shortcuts []Shortcut `json:",omitempty"`
+	// UninstallEntry customizes the Add/Remove Programs entry on Windows.
+	UninstallEntry *UninstallEntry `json:",omitempty"`
+	// MSI is installed with msiexec on Windows, in place of Install and
+	// Uninstall.
+	MSI *MSISpec `json:",omitempty"`
+}
+
+// MSISpec is an MSI installed by a package.
+type MSISpec struct {
+	// Path is the path of the MSI in the package.
+	Path string
+	// ProductCode identifies the installed product, it is read from the
+	// MSI if empty. A product already installed at the version of the MSI
+	// is taken over instead of being installed again.
+	ProductCode string `json:",omitempty"`
+	// Properties are passed to msiexec on install as NAME=VALUE.
+	Properties map[string]string `json:",omitempty"`
+	// Timeout is the duration after which msiexec is killed, as for
+	// ExecFile.
+	Timeout string `json:",omitempty"`
+}
+
+// validProductCode matches MSI product codes.
+var validProductCode = regexp.MustCompile(`^\{[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}\}$`)
+
+// validMSIProperty matches the names of public MSI properties.
+var validMSIProperty = regexp.MustCompile(`^[A-Z_][A-Z0-9_.]*$`)
+
+func (m *MSISpec) verify() error {
+	if m == nil {
+		return nil
+	}
+	if !strings.EqualFold(filepath.Ext(m.Path), ".msi") {
+		return fmt.Errorf("MSI path %q is not an .msi file", m.Path)
+	}
+	if filepath.IsAbs(m.Path) {
+		return fmt.Errorf("%q is an absolute path, expected relative", m.Path)
+	}
+	if m.ProductCode != "" && !validProductCode.MatchString(m.ProductCode) {
+		return fmt.Errorf("invalid MSI ProductCode %q, expected {XXXXXXXX-XXXX-XXXX-XXXX-XXXXXXXXXXXX}", m.ProductCode)
+	}
+	for k := range m.Properties {
+		if !validMSIProperty.MatchString(k) {
+			return fmt.Errorf("invalid MSI property %q, public properties are upper case", k)
+		}
+	}
+	if _, _, err := (ExecFile{Timeout: m.Timeout}).ParseTimeout(); err != nil {
+		return fmt.Errorf("invalid Timeout for %q: %v", m.Path, err)
+	}
+	return nil
+}
+
+// Shortcut is a Start Menu shortcut of a package.
+type Shortcut struct {
+	// Name is the name of the shortcut without the .lnk extension,
+	// optionally in a folder, for example "Foo/Foo Help".
+	Name string
+	// Target is the file the shortcut opens and Icon the file holding its
+	// icon, both resolved like the destinations of Files.
+	Target      string
+	Args        string `json:",omitempty"`
+	Icon        string `json:",omitempty"`
+	Description string `json:",omitempty"`
+}
+
+// UninstallEntry holds the optional values of the Add/Remove Programs entry
+// of a package.
+type UninstallEntry struct {
+	// DisplayIcon is the file holding the icon, resolved like the
+	// destinations of Files, optionally followed by ",index".
+	DisplayIcon string `json:",omitempty"`
+	// URLInfoAbout is the URL of the publisher.
+	URLInfoAbout string `json:",omitempty"`
+	// EstimatedSize is the size of the installed package in KB.
+	EstimatedSize uint32 `json:",omitempty"`
+}
+
+// EnvSpec declares the machine environment of a package.
+type EnvSpec struct {
+	// Path lists the directories appended to the machine PATH, resolved like
+	// the destinations of Files.
+	Path []string `json:",omitempty"`
+	// Vars are the machine environment variables set, other than PATH.
+	Vars map[string]string `json:",omitempty"`
+}
+
+// EnvChanges are the changes made to the machine environment by the Env of
+// a package, recorded so they can be undone.
+type EnvChanges struct {
+	// Path lists the entries added to PATH.
+	Path []string `json:",omitempty"`
+	// Vars maps the variables set to their previous value, nil if they
+	// were not set.
+	Vars map[string]*string `json:",omitempty"`
+}
+
+// FileAttributes describes the attributes of a file or directory in a
+// package.
+type FileAttributes struct {
+	// Mode is an octal permission string, applied on Linux and darwin in
+	// place of FileMode or DirMode.
+	Mode string `json:",omitempty"`
+	// Owner is "user" or "user:group", by name or numeric ID, applied on
+	// Linux.
+	Owner string `json:",omitempty"`
+	// ReadOnly and Hidden set the Windows file attributes of the same name.
+	ReadOnly bool `json:",omitempty"`
+	Hidden   bool `json:",omitempty"`
+}
+
+// SplitOwner splits an Owner attribute into its user and optional group.
+func (a FileAttributes) SplitOwner() (string, string) {
+	u, g, _ := strings.Cut(a.Owner, ":")
+	return u, g
+}
+
+// Interpreter is a program the scripts of a package run with.
+type Interpreter struct {
+	// Command is the name of the program, looked up in PATH, or its
+	// absolute path.
+	Command string
+	// Package optionally names the package providing Command, which is
+	// installed as a dependency with at least Version.
+	Package string `json:",omitempty"`
+	Version string `json:",omitempty"`
+}
+
+// Dependencies returns PkgDependencies together with the packages providing
+// Interpreters, with the highest minimum version required of each.
+func (ps *PkgSpec) Dependencies() map[string]string {
+	if len(ps.Interpreters) == 0 {
+		return ps.PkgDependencies
+	}
+	deps := make(map[string]string)
+	for p, v := range ps.PkgDependencies {
+		deps[p] = v
+	}
+	for _, in := range ps.Interpreters {
+		if in.Package == "" {
+			continue
+		}
+		v := in.Version
+		if v == "" {
+			v = "0.0.0"
+		}
+		if cur, ok := deps[in.Package]; ok {
+			if c, err := ParseConstraint(cur); err != nil || !c.min {
+				deps[in.Package] = andMinimum(cur, v)
+				continue
+			}
+			if c, err := Compare(cur, v); err != nil || c >= 0 {
+				continue
+			}
+		}
+		deps[in.Package] = v
+	}
+	return deps
+}
+
+// andMinimum adds the minimum version v to each alternative of the version
+// range r.
+func andMinimum(r, v string) string {
+	pv, err := ParseVersion(v)
+	if err != nil {
+		return r
+	}
+	min := ">=" + pv.String()
+	alts := strings.Split(r, "||")
+	for i, a := range alts {
+		alts[i] = strings.TrimSpace(a) + " " + min
+	}
+	return strings.Join(alts, " || ")
+}
+
+// HasTag reports whether ps has one of tags, each either the key of a tag or
+// KEY=VALUE.
+func (ps *PkgSpec) HasTag(tags ...string) bool {
+	for _, t := range tags {
+		k, v, kv := strings.Cut(t, "=")
+		if val, ok := ps.Tags[k]; ok && (!kv || string(val) == v) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckInterpreters returns an error if the command of one of Interpreters
+// can't be found.
+func (ps *PkgSpec) CheckInterpreters() error {
+	for _, in := range ps.Interpreters {
+		if _, err := exec.LookPath(in.Command); err != nil {
+			if in.Package != "" {
+				return fmt.Errorf("interpreter %q of package %s, provided by package %s, not found: %v", in.Command, ps, in.Package, err)
+			}
+			return fmt.Errorf("interpreter %q of package %s not found: %v", in.Command, ps, err)
+		}
+	}
+	return nil
 }
 
 func (ps PkgSpec) String() string {
@@ -97,27 +457,99 @@ func (ps PkgSpec) String() string {
 }
 
 // ExecFile contains info involved in running a script or binary file.
+// ExitCodes are exit codes, besides 0, meaning success, RebootExitCodes mean
+// success but a reboot is required to complete and RetryExitCodes a failure
+// after which the command is run again. Any other exit code is fatal.
 type ExecFile struct {
-	Path      string   `json:",omitempty"`
-	Args      []string `json:",omitempty"`
-	ExitCodes []int    `json:",omitempty"`
+	Path            string   `json:",omitempty"`
+	Args            []string `json:",omitempty"`
+	ExitCodes       []int    `json:",omitempty"`
+	RebootExitCodes []int    `json:",omitempty"`
+	RetryExitCodes  []int    `json:",omitempty"`
+	// Timeout is the duration after which the command is killed, overriding
+	// the default of the client, "0" for no limit.
+	Timeout string `json:",omitempty"`
+}
+
+// ParseTimeout parses the Timeout of e, ok is false if it is not set.
+func (e ExecFile) ParseTimeout() (d time.Duration, ok bool, err error) {
+	if e.Timeout == "" {
+		return 0, false, nil
+	}
+	if d, err = time.ParseDuration(e.Timeout); err != nil {
+		return 0, false, err
+	}
+	if d < 0 {
+		return 0, false, fmt.Errorf("negative timeout %q", e.Timeout)
+	}
+	return d, true, nil
 }
 
 // Version contains the semver version as well as the GsVer.
 // Semver is semantic versioning version.
 // GsVer is a GooSpec version number (usually version of installer).
+// Epoch, written as "EPOCH:" in front of the version, orders versions before
+// Semver, allowing publishers to change the versioning scheme of a package.
 type Version struct {
+	Epoch  int64
 	Semver semver.Version
 	GsVer  int64
 }
 
+// Compare compares v to o, returning -1, 0 or 1 if v is less than, equal
+// to or greater than o.
+func (v Version) Compare(o Version) int {
+	switch {
+	case v.Epoch < o.Epoch:
+		return -1
+	case v.Epoch > o.Epoch:
+		return 1
+	}
+	if c := v.Semver.Compare(o.Semver); c != 0 {
+		return c
+	}
+	switch {
+	case v.GsVer < o.GsVer:
+		return -1
+	case v.GsVer > o.GsVer:
+		return 1
+	}
+	return 0
+}
+
+func (v Version) String() string {
+	s := epochPrefix(v) + v.Semver.String()
+	if v.GsVer != 0 {
+		s = fmt.Sprintf("%s@%d", s, v.GsVer)
+	}
+	return s
+}
+
 // Ver returns the goospec version.
 func (gs GooSpec) Ver() (Version, error) {
 	return ParseVersion(gs.PackageSpec.Version)
 }
 
 func (gs GooSpec) verify() error {
-	return gs.PackageSpec.verify()
+	for _, a := range gs.TargetArchs() {
+		ags, err := gs.ForArch(a)
+		if err != nil {
+			return err
+		}
+		if err := ags.PackageSpec.verify(); err != nil {
+			return err
+		}
+		for _, n := range ags.SubpackageNames() {
+			sgs, err := ags.Subpackage(n)
+			if err != nil {
+				return err
+			}
+			if err := sgs.PackageSpec.verify(); err != nil {
+				return fmt.Errorf("subpackage %s: %v", n, err)
+			}
+		}
+	}
+	return nil
 }
 
 func (gs GooSpec) normalize() {
@@ -137,17 +569,7 @@ func Compare(v1, v2 string) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	var c int
-	if c = pv1.Semver.Compare(pv2.Semver); c == 0 {
-		if pv1.GsVer > pv2.GsVer {
-			return 1, nil
-		}
-		if pv1.GsVer < pv2.GsVer {
-			return -1, nil
-		}
-		return 0, nil
-	}
-	return c, nil
+	return pv1.Compare(pv2), nil
 }
 
 func fixVer(ver string) string {
@@ -187,6 +609,10 @@ func ComparePriorityVersion(p1 priority.Value, v1 string, p2 priority.Value, v2
 // using existing components for the least significant components first (i.e.
 // "1" will become "0.0.1", not "1.0.0").
 func ParseVersion(ver string) (Version, error) {
+	epoch, ver, err := splitEpoch(ver)
+	if err != nil {
+		return Version{}, err
+	}
 	v := strings.SplitN(ver, "@", 2)
 	v[0] = fixVer(v[0])
 
@@ -194,22 +620,30 @@ func ParseVersion(ver string) (Version, error) {
 	if err != nil {
 		return Version{}, err
 	}
-	version := Version{Semver: sv}
+	version := Version{Epoch: epoch, Semver: sv}
 	if len(v) == 2 {
 		gv, err := strconv.ParseInt(v[1], 10, 64)
 		if err != nil {
 			return version, err
 		}
-		version = Version{
-			Semver: sv,
-			GsVer:  gv,
-		}
-	} else {
-		version = Version{Semver: sv}
+		version.GsVer = gv
 	}
 	return version, nil
 }
 
+// splitEpoch splits the epoch off ver, returning 0 if it has none.
+func splitEpoch(ver string) (int64, string, error) {
+	e, rest, ok := strings.Cut(ver, ":")
+	if !ok {
+		return 0, ver, nil
+	}
+	epoch, err := strconv.ParseInt(e, 10, 64)
+	if err != nil || epoch < 0 {
+		return 0, ver, fmt.Errorf("invalid epoch %q in version %q", e, ver)
+	}
+	return epoch, rest, nil
+}
+
 // Versions contains a list of goospec string versions.
 type Versions []string
 
@@ -275,36 +709,150 @@ func jsonError(data []byte, err error) error {
 	return fmt.Errorf("JSON syntax error in line %d: %s:\n%s", line, err, buf.String())
 }
 
-func unmarshalGooSpec(c []byte, varMap map[string]string) (*GooSpec, error) {
-	goospecTemplate := template.New("goospecTemplate").Option("missingkey=zero")
-	tmpl, err := goospecTemplate.Parse(string(c))
+// templateFuncs returns the functions available to goospec templates, relative
+// paths given to them are resolved against dir.
+func templateFuncs(dir string) template.FuncMap {
+	path := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	return template.FuncMap{
+		// env returns the value of an environment variable.
+		"env": os.Getenv,
+		// file returns the contents of a file.
+		"file": func(p string) (string, error) {
+			b, err := ioutil.ReadFile(path(p))
+			return string(b), err
+		},
+		// sha256 returns the hex encoded SHA-256 checksum of a file.
+		"sha256": func(p string) (string, error) {
+			f, err := os.Open(path(p))
+			if err != nil {
+				return "", err
+			}
+			defer f.Close()
+			return Checksum(f), nil
+		},
+		// now returns the current UTC time in the given layout, RFC 3339 by
+		// default.
+		"now": func(layout ...string) (string, error) {
+			switch len(layout) {
+			case 0:
+				return time.Now().UTC().Format(time.RFC3339), nil
+			case 1:
+				return time.Now().UTC().Format(layout[0]), nil
+			}
+			return "", errors.New("now takes at most one layout")
+		},
+		"bump": bumpVersion,
+	}
+}
+
+// bumpVersion increments part, one of major, minor, patch or release, of the
+// package version ver and resets the parts after it. Pre-release and build
+// metadata are dropped.
+func bumpVersion(part, ver string) (string, error) {
+	v, err := ParseVersion(ver)
 	if err != nil {
+		return "", err
+	}
+	sv := semver.Version{Major: v.Semver.Major, Minor: v.Semver.Minor, Patch: v.Semver.Patch}
+	switch part {
+	case "major":
+		sv.Major++
+		sv.Minor, sv.Patch, v.GsVer = 0, 0, 0
+	case "minor":
+		sv.Minor++
+		sv.Patch, v.GsVer = 0, 0
+	case "patch":
+		sv.Patch++
+		v.GsVer = 0
+	case "release":
+		return fmt.Sprintf("%s%s@%d", epochPrefix(v), v.Semver, v.GsVer+1), nil
+	default:
+		return "", fmt.Errorf("unknown version part %q, want major, minor, patch or release", part)
+	}
+	if !strings.Contains(ver, "@") {
+		return epochPrefix(v) + sv.String(), nil
+	}
+	return fmt.Sprintf("%s%s@%d", epochPrefix(v), sv, v.GsVer), nil
+}
+
+// epochPrefix returns the "EPOCH:" of v, if it has an epoch.
+func epochPrefix(v Version) string {
+	if v.Epoch == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d:", v.Epoch)
+}
+
+func unmarshalGooSpec(c []byte, varMap map[string]string, dir string) (*GooSpec, error) {
+	var gs GooSpec
+	if err := decodeGooSpec(&gs, c, varMap, dir, make(map[string]bool)); err != nil {
 		return nil, err
 	}
+	return &gs, nil
+}
+
+// decodeGooSpec executes the goospec template c and decodes it into gs, after
+// the goospecs listed in its include field. Later goospecs override the fields
+// they set, objects are merged and arrays replaced. Relative includes are
+// resolved against dir, seen holds the includes being decoded.
+func decodeGooSpec(gs *GooSpec, c []byte, varMap map[string]string, dir string, seen map[string]bool) error {
+	goospecTemplate := template.New("goospecTemplate").Option("missingkey=zero").Funcs(templateFuncs(dir))
+	tmpl, err := goospecTemplate.Parse(string(c))
+	if err != nil {
+		return err
+	}
 
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, varMap); err != nil {
-		return nil, err
+		return err
 	}
-
-	var gs GooSpec
 	data := buf.Bytes()
+
+	var inc struct{ Include []string }
+	if err := json.Unmarshal(data, &inc); err != nil {
+		return jsonError(data, err)
+	}
+	for _, f := range inc.Include {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(dir, f)
+		}
+		if seen[f] {
+			return fmt.Errorf("goospec %s includes itself", f)
+		}
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		seen[f] = true
+		if err := decodeGooSpec(gs, b, varMap, filepath.Dir(f), seen); err != nil {
+			return fmt.Errorf("%s: %v", f, err)
+		}
+		delete(seen, f)
+	}
+
 	if err := json.Unmarshal(data, &gs.PackageSpec); err != nil {
-		return nil, jsonError(data, err)
+		return jsonError(data, err)
 	}
-	if err := json.Unmarshal(data, &gs); err != nil {
-		return nil, jsonError(data, err)
+	if err := json.Unmarshal(data, gs); err != nil {
+		return jsonError(data, err)
 	}
-	return &gs, nil
+	return nil
 }
 
 // ReadGooSpec unmarshalls and verifies a goospec file into the GooSpec struct.
+// The goospec is a template executed with varMap, see templateFuncs for the
+// functions it can use.
 func ReadGooSpec(cf string, varMap map[string]string) (*GooSpec, error) {
 	c, err := ioutil.ReadFile(cf)
 	if err != nil {
 		return nil, err
 	}
-	gs, err := unmarshalGooSpec(c, varMap)
+	gs, err := unmarshalGooSpec(c, varMap, filepath.Dir(cf))
 	if err != nil {
 		return nil, err
 	}
@@ -315,6 +863,216 @@ func ReadGooSpec(cf string, varMap map[string]string) (*GooSpec, error) {
 	return gs, nil
 }
 
+// validPkgName matches the names of packages that can be depended on,
+// optionally followed by an architecture.
+var validPkgName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_+-]*(\.[A-Za-z0-9_]+)?$`)
+
+// ValidateSpec checks the goospec c, templated with varMap and with relative
+// paths resolved against dir, without building the package and returns every
+// problem found.
+func ValidateSpec(c []byte, varMap map[string]string, dir string) []error {
+	var errs []error
+	tmpl, err := template.New("goospecTemplate").Funcs(templateFuncs(dir)).Parse(string(c))
+	if err != nil {
+		return []error{err}
+	}
+	vars := make(map[string]bool)
+	templateVars(tmpl.Root, vars)
+	var missing []string
+	for v := range vars {
+		if _, ok := varMap[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	sort.Strings(missing)
+	for _, v := range missing {
+		errs = append(errs, fmt.Errorf("template variable %q is not provided", v))
+	}
+
+	gs, err := unmarshalGooSpec(c, varMap, dir)
+	if err != nil {
+		return append(errs, err)
+	}
+	if gs.PackageSpec == nil {
+		return append(errs, errors.New("no package spec defined"))
+	}
+	gs.normalize()
+	if err := gs.verify(); err != nil {
+		errs = append(errs, err)
+	}
+
+	ps := gs.PackageSpec
+	for _, rel := range []struct {
+		field string
+		names []string
+	}{
+		{"PkgDependencies", sortedKeys(ps.PkgDependencies)},
+		{"Replaces", constraintNames(ps.Replaces)},
+		{"Conflicts", constraintNames(ps.Conflicts)},
+		{"Interpreters", interpreterPackages(ps.Interpreters)},
+	} {
+		for _, n := range rel.names {
+			if !validPkgName.MatchString(n) {
+				errs = append(errs, fmt.Errorf("invalid package name %q in %s", n, rel.field))
+			}
+		}
+	}
+
+	for _, a := range gs.TargetArchs() {
+		ags, err := gs.ForArch(a)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, script := range []struct {
+			field string
+			path  string
+		}{
+			{"Install", ps.Install.Path},
+			{"Uninstall", ps.Uninstall.Path},
+			{"Verify", ps.Verify.Path},
+			{"MSI", msiPath(ps.MSI)},
+		} {
+			if script.path == "" {
+				continue
+			}
+			ok, err := inSources(ags.Sources, script.path)
+			if err != nil {
+				errs = append(errs, err)
+			} else if !ok && len(gs.Archs) > 0 {
+				errs = append(errs, fmt.Errorf("%s script %q is not included in Sources for %s", script.field, script.path, a))
+			} else if !ok {
+				errs = append(errs, fmt.Errorf("%s script %q is not included in Sources", script.field, script.path))
+			}
+		}
+	}
+	for _, n := range gs.SubpackageNames() {
+		sp := gs.Subpackages[n]
+		for _, d := range sortedKeys(sp.PkgDependencies) {
+			if !validPkgName.MatchString(d) {
+				errs = append(errs, fmt.Errorf("invalid package name %q in PkgDependencies of subpackage %s", d, n))
+			}
+		}
+		for _, script := range []struct {
+			field string
+			path  string
+		}{
+			{"Install", sp.Install.Path},
+			{"Uninstall", sp.Uninstall.Path},
+			{"Verify", sp.Verify.Path},
+		} {
+			if script.path == "" {
+				continue
+			}
+			if ok, err := inSources(sp.Sources, script.path); err != nil {
+				errs = append(errs, err)
+			} else if !ok {
+				errs = append(errs, fmt.Errorf("%s script %q of subpackage %s is not included in its Sources", script.field, script.path, n))
+			}
+		}
+	}
+	return errs
+}
+
+// interpreterPackages returns the packages providing interpreters.
+func interpreterPackages(interpreters []Interpreter) []string {
+	var pkgs []string
+	for _, in := range interpreters {
+		if in.Package != "" {
+			pkgs = append(pkgs, in.Package)
+		}
+	}
+	return pkgs
+}
+
+// templateVars adds the names of the variables referenced in n to vars.
+func templateVars(n parse.Node, vars map[string]bool) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			templateVars(c, vars)
+		}
+	case *parse.ActionNode:
+		templateVars(n.Pipe, vars)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			templateVars(c, vars)
+		}
+	case *parse.CommandNode:
+		for _, c := range n.Args {
+			templateVars(c, vars)
+		}
+	case *parse.FieldNode:
+		vars[n.Ident[0]] = true
+	case *parse.ChainNode:
+		templateVars(n.Node, vars)
+	case *parse.IfNode:
+		templateVars(&n.BranchNode, vars)
+	case *parse.RangeNode:
+		// Dot is rebound inside range and with, only the pipeline and the
+		// else branch refer to the variables.
+		templateVars(n.Pipe, vars)
+		templateVars(n.ElseList, vars)
+	case *parse.WithNode:
+		templateVars(n.Pipe, vars)
+		templateVars(n.ElseList, vars)
+	case *parse.BranchNode:
+		templateVars(n.Pipe, vars)
+		templateVars(n.List, vars)
+		templateVars(n.ElseList, vars)
+	case *parse.TemplateNode:
+		templateVars(n.Pipe, vars)
+	}
+}
+
+// inSources reports whether the package path p is matched by an include and
+// no exclude of sources, without looking at the file system.
+func inSources(sources []PkgSources, p string) (bool, error) {
+	p = filepath.Clean(filepath.FromSlash(p))
+	for _, s := range sources {
+		rel, err := filepath.Rel(filepath.Clean(filepath.FromSlash(s.Target)), p)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		root := filepath.Clean(s.Root)
+		file := filepath.Join(root, rel)
+		var in, ex bool
+		for _, i := range s.Include {
+			m, err := PathMatch(filepath.Join(root, i), file)
+			if err != nil {
+				return false, err
+			}
+			in = in || m
+		}
+		for _, e := range s.Exclude {
+			m, err := PathMatch(filepath.Join(root, e), file)
+			if err != nil {
+				return false, err
+			}
+			ex = ex || m
+		}
+		if in && !ex {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // WritePackageSpec takes a PkgSpec and writes it as a JSON file using
 // the provided tar writer.
 func WritePackageSpec(tw *tar.Writer, spec *PkgSpec) error {
@@ -366,6 +1124,65 @@ func ReadPackageSpec(r io.Reader) (*PkgSpec, error) {
 	}
 }
 
+// ParseMode parses an octal permission string such as "0644", an empty
+// string parses as 0.
+func ParseMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0, nil
+	}
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("can't parse mode %q: %v", s, err)
+	}
+	if m&^0777 != 0 {
+		return 0, fmt.Errorf("mode %q has bits outside of 0777", s)
+	}
+	return os.FileMode(m), nil
+}
+
+// validEnvVar matches the variable names an EnvSpec can set, those valid in
+// shells as well as on Windows.
+var validEnvVar = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (e *EnvSpec) verify() error {
+	if e == nil {
+		return nil
+	}
+	for _, p := range e.Path {
+		if p == "" || strings.ContainsAny(p, ";\n") {
+			return fmt.Errorf("invalid Path entry %q", p)
+		}
+	}
+	for k, v := range e.Vars {
+		if !validEnvVar.MatchString(k) {
+			return fmt.Errorf("invalid variable name %q", k)
+		}
+		if strings.Contains(v, "\n") {
+			return fmt.Errorf("value of variable %q has a newline", k)
+		}
+		if strings.EqualFold(k, "PATH") {
+			return errors.New("PATH is set with Path, not Vars")
+		}
+	}
+	return nil
+}
+
+func (s Shortcut) verify() error {
+	if s.Target == "" {
+		return fmt.Errorf("shortcut %q has no Target", s.Name)
+	}
+	n := strings.ReplaceAll(s.Name, `\`, "/")
+	if strings.ContainsAny(n, `:*?"<>|`) {
+		return fmt.Errorf("invalid shortcut name %q", s.Name)
+	}
+	for _, e := range strings.Split(n, "/") {
+		if e == "" || e == "." || e == ".." {
+			return fmt.Errorf("invalid shortcut name %q", s.Name)
+		}
+	}
+	return nil
+}
+
 func (ps *PkgSpec) verify() error {
 	if ps.Name == "" {
 		return errors.New("no name defined in package spec")
@@ -391,24 +1208,94 @@ func (ps *PkgSpec) verify() error {
 		}
 	}
 	for k, v := range ps.PkgDependencies {
-		if _, err := ParseVersion(v); err != nil {
+		if _, err := ParseConstraint(v); err != nil {
 			return fmt.Errorf("can't parse version %q for dependancy %q: %v", v, k, err)
 		}
 	}
+	for _, l := range [][]string{ps.Replaces, ps.Conflicts} {
+		for _, p := range l {
+			if _, err := SplitConstraint(p); err != nil {
+				return fmt.Errorf("can't parse %q: %v", p, err)
+			}
+		}
+	}
+	for _, in := range ps.Interpreters {
+		if in.Command == "" {
+			return errors.New("interpreter without a command")
+		}
+		if in.Version == "" {
+			continue
+		}
+		if in.Package == "" {
+			return fmt.Errorf("interpreter %q has a version but no package", in.Command)
+		}
+		if _, err := ParseVersion(in.Version); err != nil {
+			return fmt.Errorf("can't parse version %q for interpreter %q: %v", in.Version, in.Command, err)
+		}
+	}
 	for src := range ps.Files {
 		if filepath.IsAbs(src) {
 			return fmt.Errorf("%q is an absolute path, expected relative", src)
 		}
 	}
+	if _, err := ParseMode(ps.FileMode); err != nil {
+		return fmt.Errorf("invalid FileMode: %v", err)
+	}
+	if _, err := ParseMode(ps.DirMode); err != nil {
+		return fmt.Errorf("invalid DirMode: %v", err)
+	}
+	for p, a := range ps.FileAttributes {
+		if filepath.IsAbs(p) {
+			return fmt.Errorf("%q is an absolute path, expected relative", p)
+		}
+		if _, err := ParseMode(a.Mode); err != nil {
+			return fmt.Errorf("invalid Mode for %q: %v", p, err)
+		}
+		if u, _ := a.SplitOwner(); a.Owner != "" && (u == "" || strings.HasSuffix(a.Owner, ":")) {
+			return fmt.Errorf("invalid Owner %q for %q, expected user or user:group", a.Owner, p)
+		}
+	}
 	if filepath.IsAbs(ps.Install.Path) {
 		return fmt.Errorf("%q is an absolute path, expected relative", ps.Install.Path)
 	}
 	if filepath.IsAbs(ps.Uninstall.Path) {
 		return fmt.Errorf("%q is an absolute path, expected relative", ps.Uninstall.Path)
 	}
+	if err := ps.Env.verify(); err != nil {
+		return fmt.Errorf("invalid Env: %v", err)
+	}
+	if ps.MSI != nil && (ps.Install.Path != "" || ps.Uninstall.Path != "") {
+		return errors.New("MSI can't be combined with Install or Uninstall")
+	}
+	if err := ps.MSI.verify(); err != nil {
+		return err
+	}
+	for _, s := range ps.Shortcuts {
+		if err := s.verify(); err != nil {
+			return err
+		}
+	}
+	for _, e := range []ExecFile{ps.Install, ps.Uninstall, ps.Verify} {
+		if _, _, err := e.ParseTimeout(); err != nil {
+			return fmt.Errorf("invalid Timeout for %q: %v", e.Path, err)
+		}
+		for _, c := range append(append([]int{}, e.RebootExitCodes...), e.RetryExitCodes...) {
+			if c == 0 || ContainsInt(c, e.ExitCodes) || (ContainsInt(c, e.RebootExitCodes) && ContainsInt(c, e.RetryExitCodes)) {
+				return fmt.Errorf("exit code %d of %q has more than one outcome", c, e.Path)
+			}
+		}
+	}
 	return nil
 }
 
+// msiPath returns the path of m, empty if m is nil.
+func msiPath(m *MSISpec) string {
+	if m == nil {
+		return ""
+	}
+	return m.Path
+}
+
 func (ps *PkgSpec) normalize() {
 	for _, str := range []*string{&ps.Install.Path, &ps.Uninstall.Path} {
 		if filepath.IsAbs(*str) {
@@ -424,6 +1311,12 @@ func (ps *PkgSpec) normalize() {
 				delete(ps.Files, src)
 			}
 		}
+		for p, a := range ps.FileAttributes {
+			if newP := strings.ReplaceAll(p, "\\", "/"); newP != p {
+				ps.FileAttributes[newP] = a
+				delete(ps.FileAttributes, p)
+			}
+		}
 	}
 }
 
@@ -16,11 +16,14 @@ package goolib
 import (
 	"archive/tar"
 	"bytes"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/blang/semver"
 	"github.com/google/googet/v2/priority"
@@ -46,6 +49,8 @@ func TestParseVersion(t *testing.T) {
 		{"1.2.3+1", mkVer("1.2.3+1", 0)},
 		{"1.2.03-1", mkVer("1.2.3-1", 0)},
 		{"1.2.3+4@5", mkVer("1.2.3+4", 5)},
+		{"2:1.2.3@4", Version{Epoch: 2, Semver: semver.MustParse("1.2.3"), GsVer: 4}},
+		{"0:1.2", mkVer("0.1.2", 0)},
 	}
 	for _, tt := range table {
 		v, err := ParseVersion(tt.ver)
@@ -65,6 +70,8 @@ func TestBadParseVersion(t *testing.T) {
 		{"1.2.d3@4"},
 		{"1.2.3@4d"},
 		{"1.2.3.4@4"},
+		{"x:1.2.3"},
+		{"-1:1.2.3"},
 	}
 	for _, tt := range table {
 		if _, err := ParseVersion(tt.ver); err == nil {
@@ -80,6 +87,11 @@ func TestVerify(t *testing.T) {
 			Name:            "name",
 			Version:         "1.2.3@4",
 			PkgDependencies: map[string]string{"name": "1.2.3@4"},
+			MSI: &MSISpec{
+				Path:        "foo.msi",
+				ProductCode: "{0A1B2C3D-4E5F-6A7B-8C9D-0E1F2A3B4C5D}",
+				Properties:  map[string]string{"INSTALLDIR": `C:\Foo`, "ADDLOCAL": "ALL"},
+			},
 		},
 	}
 	if err := gs.verify(); err != nil {
@@ -110,7 +122,7 @@ func TestBadVerify(t *testing.T) {
 				Name:    "name",
 				Version: "1.2.3:4d",
 			},
-		}, `Invalid character(s) found in patch number "3:4d"`},
+		}, `invalid epoch "1.2.3" in version "1.2.3:4d"`},
 		{GooSpec{
 			PackageSpec: &PkgSpec{
 				Arch:    "something",
@@ -166,6 +178,127 @@ func TestBadVerify(t *testing.T) {
 				},
 			},
 		}, `tag "text" too large`},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:     "noarch",
+				Name:     "name",
+				Version:  "1.2.3@4",
+				FileMode: "0648",
+			},
+		}, "invalid FileMode"},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:    "noarch",
+				Name:    "name",
+				Version: "1.2.3@4",
+				DirMode: "4755",
+			},
+		}, "invalid DirMode"},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:    "noarch",
+				Name:    "name",
+				Version: "1.2.3@4",
+				Install: ExecFile{Path: "install.sh", Timeout: "-1m"},
+			},
+		}, `invalid Timeout for "install.sh"`},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:    "noarch",
+				Name:    "name",
+				Version: "1.2.3@4",
+				Env:     &EnvSpec{Vars: map[string]string{"Path": "/bin"}},
+			},
+		}, "invalid Env"},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:      "noarch",
+				Name:      "name",
+				Version:   "1.2.3@4",
+				Shortcuts: []Shortcut{{Name: `..\Startup\Foo`, Target: "<ProgramFiles>/Foo/foo.exe"}},
+			},
+		}, `invalid shortcut name`},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:      "noarch",
+				Name:      "name",
+				Version:   "1.2.3@4",
+				Shortcuts: []Shortcut{{Name: "Foo"}},
+			},
+		}, `shortcut "Foo" has no Target`},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:    "noarch",
+				Name:    "name",
+				Version: "1.2.3@4",
+				Install: ExecFile{Path: "install.sh", ExitCodes: []int{3010}, RebootExitCodes: []int{3010}},
+			},
+		}, `exit code 3010 of "install.sh" has more than one outcome`},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:           "noarch",
+				Name:           "name",
+				Version:        "1.2.3@4",
+				FileAttributes: map[string]FileAttributes{"bin/tool": {Mode: "0800"}},
+			},
+		}, `invalid Mode for "bin/tool"`},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:           "noarch",
+				Name:           "name",
+				Version:        "1.2.3@4",
+				FileAttributes: map[string]FileAttributes{"bin/tool": {Owner: "root:"}},
+			},
+		}, `invalid Owner "root:"`},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:         "noarch",
+				Name:         "name",
+				Version:      "1.2.3@4",
+				Interpreters: []Interpreter{{Package: "powershell-core", Version: "7"}},
+			},
+		}, "interpreter without a command"},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:         "noarch",
+				Name:         "name",
+				Version:      "1.2.3@4",
+				Interpreters: []Interpreter{{Command: "pwsh", Package: "powershell-core", Version: "seven"}},
+			},
+		}, `can't parse version "seven" for interpreter "pwsh"`},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:    "noarch",
+				Name:    "name",
+				Version: "1.2.3@4",
+				Install: ExecFile{Path: "install.ps1"},
+				MSI:     &MSISpec{Path: "foo.msi"},
+			},
+		}, "MSI can't be combined with Install or Uninstall"},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:    "noarch",
+				Name:    "name",
+				Version: "1.2.3@4",
+				MSI:     &MSISpec{Path: "foo.exe"},
+			},
+		}, `MSI path "foo.exe" is not an .msi file`},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:    "noarch",
+				Name:    "name",
+				Version: "1.2.3@4",
+				MSI:     &MSISpec{Path: "foo.msi", ProductCode: "1234"},
+			},
+		}, `invalid MSI ProductCode "1234"`},
+		{GooSpec{
+			PackageSpec: &PkgSpec{
+				Arch:    "noarch",
+				Name:    "name",
+				Version: "1.2.3@4",
+				MSI:     &MSISpec{Path: "foo.msi", Properties: map[string]string{"installDir": "C:/foo"}},
+			},
+		}, `invalid MSI property "installDir"`},
 	}
 	for _, tt := range table {
 		err := tt.gs.verify()
@@ -179,6 +312,66 @@ func TestBadVerify(t *testing.T) {
 	}
 }
 
+func TestDependencies(t *testing.T) {
+	ps := &PkgSpec{
+		PkgDependencies: map[string]string{"foo": "1.0.0", "powershell-core": "7.1.0"},
+		Interpreters: []Interpreter{
+			{Command: "pwsh", Package: "powershell-core", Version: "7.0.0"},
+			{Command: "python3", Package: "python", Version: "3.9.0"},
+			{Command: "cmd"},
+		},
+	}
+	want := map[string]string{"foo": "1.0.0", "powershell-core": "7.1.0", "python": "3.9.0"}
+	if got := ps.Dependencies(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Dependencies() = %v, want %v", got, want)
+	}
+	ps.Interpreters[0].Version = "7.2.0"
+	if got := ps.Dependencies()["powershell-core"]; got != "7.2.0" {
+		t.Errorf("Dependencies() requires powershell-core %s, want the higher interpreter version 7.2.0", got)
+	}
+	if ps.PkgDependencies["powershell-core"] != "7.1.0" {
+		t.Error("Dependencies() modified PkgDependencies")
+	}
+	ps.PkgDependencies["powershell-core"] = "<8 || =9.1"
+	if got, want := ps.Dependencies()["powershell-core"], "<8 >=7.2.0 || =9.1 >=7.2.0"; got != want {
+		t.Errorf("Dependencies() requires powershell-core %s, want %s", got, want)
+	}
+}
+
+func TestHasTag(t *testing.T) {
+	ps := &PkgSpec{Tags: map[string][]byte{"security": nil, "class": []byte("driver")}}
+	for _, tt := range []struct {
+		tags []string
+		want bool
+	}{
+		{nil, false},
+		{[]string{"security"}, true},
+		{[]string{"agent", "class"}, true},
+		{[]string{"class=driver"}, true},
+		{[]string{"class=agent"}, false},
+		{[]string{"security=yes"}, false},
+	} {
+		if got := ps.HasTag(tt.tags...); got != tt.want {
+			t.Errorf("HasTag(%q) = %v, want %v", tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestCheckInterpreters(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := &PkgSpec{Name: "foo", Interpreters: []Interpreter{{Command: exe}}}
+	if err := ps.CheckInterpreters(); err != nil {
+		t.Errorf("CheckInterpreters with an existing command: %v", err)
+	}
+	ps.Interpreters = append(ps.Interpreters, Interpreter{Command: "googet-no-such-interpreter", Package: "bar"})
+	if err := ps.CheckInterpreters(); err == nil || !strings.Contains(err.Error(), "provided by package bar") {
+		t.Errorf("CheckInterpreters with a missing command returned %v, want an error naming package bar", err)
+	}
+}
+
 func TestCompare(t *testing.T) {
 	table := []struct {
 		v1     string
@@ -188,6 +381,9 @@ func TestCompare(t *testing.T) {
 		{"1.2.3@1", "1.2.3@2", -1},
 		{"1.2.4@1", "1.2.3@2", 1},
 		{"1.2.3", "1.2.3", 0},
+		{"1:1.0.0@1", "9.9.9@9", 1},
+		{"1:1.0.0", "2:0.0.1", -1},
+		{"0:1.2.3", "1.2.3", 0},
 	}
 	for _, tt := range table {
 		c, err := Compare(tt.v1, tt.v2)
@@ -266,8 +462,8 @@ func TestWritePackageSpec(t *testing.T) {
 }
 
 func TestSortVersions(t *testing.T) {
-	got := SortVersions([]string{"1.2.3@4", "1.5.0", "1.0.0", "1.0", "1.2.A", "1.2.3@1"})
-	want := []string{"1.0", "1.0.0", "1.2.3@1", "1.2.3@4", "1.5.0"}
+	got := SortVersions([]string{"1.2.3@4", "1:0.1.0", "1.5.0", "1.0.0", "1.0", "1.2.A", "1.2.3@1"})
+	want := []string{"1.0", "1.0.0", "1.2.3@1", "1.2.3@4", "1.5.0", "1:0.1.0"}
 	if !reflect.DeepEqual(got, want) {
 		t.Errorf("did not get expected list: got %v, want %v", got, want)
 	}
@@ -312,7 +508,7 @@ func TestUnmarshalGooSpec(t *testing.T) {
 		},
 	}
 
-	got, err := unmarshalGooSpec(c1, nil)
+	got, err := unmarshalGooSpec(c1, nil, "")
 	if err != nil {
 		t.Fatalf("error running unmarshalGooSpec: %v", err)
 	}
@@ -321,6 +517,291 @@ func TestUnmarshalGooSpec(t *testing.T) {
 	}
 }
 
+func TestTemplateFuncs(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "VERSION"), []byte("1.2.3"), 0600); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	os.Setenv("GOOSPEC_TEST_AUTHOR", "someone")
+	defer os.Unsetenv("GOOSPEC_TEST_AUTHOR")
+
+	c := []byte(`{
+		"name": "pkg",
+		"version": "{{bump "release" (printf "%s@%s" (file "VERSION") .release)}}",
+		"arch": "noarch",
+		"authors": "{{env "GOOSPEC_TEST_AUTHOR"}}",
+		"description": "{{sha256 "VERSION"}}",
+		"releaseNotes": ["{{now "2006"}}"]
+	}`)
+	gs, err := unmarshalGooSpec(c, map[string]string{"release": "1"}, tempDir)
+	if err != nil {
+		t.Fatalf("error running unmarshalGooSpec: %v", err)
+	}
+	ps := gs.PackageSpec
+	if ps.Version != "1.2.3@2" {
+		t.Errorf("version = %q, want 1.2.3@2", ps.Version)
+	}
+	if ps.Authors != "someone" {
+		t.Errorf("authors = %q, want someone", ps.Authors)
+	}
+	if want := Checksum(strings.NewReader("1.2.3")); ps.Description != want {
+		t.Errorf("description = %q, want %q", ps.Description, want)
+	}
+	if want := []string{time.Now().UTC().Format("2006")}; !reflect.DeepEqual(ps.ReleaseNotes, want) {
+		t.Errorf("release notes = %q, want %q", ps.ReleaseNotes, want)
+	}
+
+	if _, err := unmarshalGooSpec([]byte(`{"name": "{{file "missing"}}"}`), nil, tempDir); err == nil {
+		t.Error("unmarshalGooSpec with a missing file did not fail")
+	}
+}
+
+func TestReadGooSpecInclude(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	if err := os.Mkdir(filepath.Join(tempDir, "common"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, c := range map[string]string{
+		"common/owners.goospec": `{"owners": "{{.team}}", "tags": {"team": "aW5mcmE="}}`,
+		"common/base.goospec": `{
+			"include": ["owners.goospec"],
+			"authors": "someone",
+			"description": "{{file "DESCRIPTION"}}",
+			"tags": {"tier": "YmFzZQ=="},
+			"releaseNotes": ["base"],
+			"build": {"linux": "build.sh", "linuxArgs": ["-v"]}
+		}`,
+		"common/DESCRIPTION": "shared",
+		"pkg.goospec": `{
+			"include": ["common/base.goospec"],
+			"name": "pkg",
+			"version": "1.0.0@1",
+			"arch": "noarch",
+			"tags": {"tier": "cGtn"},
+			"releaseNotes": ["pkg"],
+			"build": {"linuxArgs": ["-q"]}
+		}`,
+		"loop.goospec":  `{"include": ["loop2.goospec"], "name": "loop", "version": "1.0.0@1", "arch": "noarch"}`,
+		"loop2.goospec": `{"include": ["loop.goospec"]}`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(tempDir, name), []byte(c), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gs, err := ReadGooSpec(filepath.Join(tempDir, "pkg.goospec"), map[string]string{"team": "infra"})
+	if err != nil {
+		t.Fatalf("error running ReadGooSpec: %v", err)
+	}
+	ps := gs.PackageSpec
+	if ps.Name != "pkg" || ps.Authors != "someone" || ps.Owners != "infra" || ps.Description != "shared" {
+		t.Errorf("ReadGooSpec returned %+v, want name pkg, authors someone, owners infra and description shared", ps)
+	}
+	if want := map[string][]byte{"team": []byte("infra"), "tier": []byte("pkg")}; !reflect.DeepEqual(ps.Tags, want) {
+		t.Errorf("tags = %q, want %q", ps.Tags, want)
+	}
+	if want := []string{"pkg"}; !reflect.DeepEqual(ps.ReleaseNotes, want) {
+		t.Errorf("release notes = %q, want %q", ps.ReleaseNotes, want)
+	}
+	if gs.Build.Linux != "build.sh" || !reflect.DeepEqual(gs.Build.LinuxArgs, []string{"-q"}) {
+		t.Errorf("build = %+v, want linux build.sh with args [-q]", gs.Build)
+	}
+
+	if _, err := ReadGooSpec(filepath.Join(tempDir, "loop.goospec"), nil); err == nil || !strings.Contains(err.Error(), "includes itself") {
+		t.Errorf("ReadGooSpec of an include loop returned %v, want an error", err)
+	}
+}
+
+func TestBumpVersion(t *testing.T) {
+	table := []struct {
+		part, ver, want string
+	}{
+		{"major", "1.2.3@4", "2.0.0@0"},
+		{"minor", "1.2.3@4", "1.3.0@0"},
+		{"patch", "1.2.3-beta", "1.2.4"},
+		{"release", "1.2.3@4", "1.2.3@5"},
+		{"release", "1.2.3", "1.2.3@1"},
+		{"minor", "2:1.2.3@4", "2:1.3.0@0"},
+	}
+	for _, tt := range table {
+		got, err := bumpVersion(tt.part, tt.ver)
+		if err != nil {
+			t.Errorf("bumpVersion(%q, %q): %v", tt.part, tt.ver, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("bumpVersion(%q, %q) = %q, want %q", tt.part, tt.ver, got, tt.want)
+		}
+	}
+	if _, err := bumpVersion("build", "1.2.3"); err == nil {
+		t.Error("bumpVersion of an unknown part did not fail")
+	}
+}
+
+func TestValidateSpec(t *testing.T) {
+	good := `{
+  "name": "pkg",
+  "version": "{{.version}}",
+  "arch": "noarch",
+  "pkgDependencies": {"dep.x86_64": "1.0.0", "lib": ">=1.2 <2.0"},
+  "replaces": ["old.x86_64 <2.0"],
+  "install": {"path": "scripts/install.ps1"},
+  "uninstall": {"path": "uninstall.ps1"},
+  "sources": [
+    {"include": ["**.ps1"], "exclude": ["**/test_*"], "root": "src", "target": "scripts"},
+    {"include": ["uninstall.ps1"], "root": "other"}
+  ]
+}`
+	if errs := ValidateSpec([]byte(good), map[string]string{"version": "1.2.3@1"}, ""); len(errs) != 0 {
+		t.Errorf("ValidateSpec of a valid goospec returned %v", errs)
+	}
+
+	bad := `{
+  "name": "pkg",
+  "version": "{{.version}}{{if .suffix}}-{{.suffix}}{{end}}",
+  "arch": "noarch",
+  "pkgDependencies": {"bad name": "1.0.0"},
+  "conflicts": ["-pkg"],
+  "install": {"path": "install.ps1"},
+  "verify": {"path": "../verify.ps1"},
+  "sources": [{"include": ["*.ps1"], "exclude": ["install.ps1"]}]
+}`
+	var got []string
+	for _, err := range ValidateSpec([]byte(bad), map[string]string{"version": "1.2.3"}, "") {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		`template variable "suffix" is not provided`,
+		`invalid package name "bad name" in PkgDependencies`,
+		`invalid package name "-pkg" in Conflicts`,
+		`Install script "install.ps1" is not included in Sources`,
+		`Verify script "../verify.ps1" is not included in Sources`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateSpec returned:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if errs := ValidateSpec([]byte(`{"name": "pkg", "version": "x", "arch": "sparc"}`), nil, ""); len(errs) != 1 {
+		t.Errorf("ValidateSpec of a goospec with a bad arch and version returned %v, want 1 error", errs)
+	}
+}
+
+func TestForArch(t *testing.T) {
+	c := []byte(`{
+  "name": "pkg",
+  "version": "1.2.3@4",
+  "build": {"linux": "build.sh"},
+  "sources": [{"include": ["x64/**"]}],
+  "archs": {
+    "x86_64": {},
+    "arm64": {"build": {"linux": "build.sh", "linuxArgs": ["arm64"]}, "sources": [{"include": ["arm/**"]}]}
+  }
+}`)
+	gs, err := unmarshalGooSpec(c, nil, "")
+	if err != nil {
+		t.Fatalf("error running unmarshalGooSpec: %v", err)
+	}
+	if err := gs.verify(); err != nil {
+		t.Errorf("verify of a goospec with Archs returned %v", err)
+	}
+	if got, want := gs.TargetArchs(), []string{"arm64", "x86_64"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TargetArchs() = %v, want %v", got, want)
+	}
+
+	x64, err := gs.ForArch("x86_64")
+	if err != nil {
+		t.Fatalf("ForArch(x86_64) returned %v", err)
+	}
+	if x64.PackageSpec.Arch != "x86_64" || x64.Sources[0].Include[0] != "x64/**" || x64.Build.LinuxArgs != nil {
+		t.Errorf("ForArch(x86_64) = %+v, want the inherited build and sources", x64)
+	}
+	arm, err := gs.ForArch("arm64")
+	if err != nil {
+		t.Fatalf("ForArch(arm64) returned %v", err)
+	}
+	if arm.PackageSpec.Arch != "arm64" || arm.Sources[0].Include[0] != "arm/**" || !reflect.DeepEqual(arm.Build.LinuxArgs, []string{"arm64"}) {
+		t.Errorf("ForArch(arm64) = %+v, want the arm64 build and sources", arm)
+	}
+	if gs.PackageSpec.Arch != "" {
+		t.Errorf("ForArch modified the original spec, Arch = %q", gs.PackageSpec.Arch)
+	}
+	if _, err := gs.ForArch("x86_32"); err == nil {
+		t.Error("ForArch of an undeclared arch did not fail")
+	}
+
+	gs.Archs["sparc"] = ArchSpec{}
+	if err := gs.verify(); err == nil {
+		t.Error("verify of a goospec with an invalid arch in Archs did not fail")
+	}
+}
+
+func TestSubpackage(t *testing.T) {
+	c := []byte(`{
+  "name": "foo",
+  "version": "1.2.3@4",
+  "arch": "x86_64",
+  "description": "Foo",
+  "replaces": ["oldfoo"],
+  "install": {"path": "install.ps1"},
+  "sources": [{"include": ["bin/**", "install.ps1"]}],
+  "subpackages": {
+    "foo-debug": {"sources": [{"include": ["debug/**"]}], "files": {"debug": "<ProgramFiles>/foo/debug"}},
+    "foo-dev": {"description": "Foo headers", "sources": [{"include": ["include/**"]}], "pkgDependencies": {"bar": ">=2"}}
+  }
+}`)
+	gs, err := unmarshalGooSpec(c, nil, "")
+	if err != nil {
+		t.Fatalf("error running unmarshalGooSpec: %v", err)
+	}
+	if err := gs.verify(); err != nil {
+		t.Errorf("verify of a goospec with Subpackages returned %v", err)
+	}
+	if got, want := gs.SubpackageNames(), []string{"foo-debug", "foo-dev"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SubpackageNames() = %v, want %v", got, want)
+	}
+
+	dev, err := gs.Subpackage("foo-dev")
+	if err != nil {
+		t.Fatalf("Subpackage(foo-dev) returned %v", err)
+	}
+	ps := dev.PackageSpec
+	if ps.Name != "foo-dev" || ps.Parent != "foo" || ps.Version != "1.2.3@4" || ps.Arch != "x86_64" || ps.Description != "Foo headers" {
+		t.Errorf("Subpackage(foo-dev) = %+v, want foo-dev 1.2.3@4 of foo with its own description", ps)
+	}
+	if ps.Install.Path != "" || ps.Replaces != nil || dev.Sources[0].Include[0] != "include/**" {
+		t.Errorf("Subpackage(foo-dev) inherited the scripts, replaces or sources of foo: %+v", dev)
+	}
+	if want := map[string]string{"foo.x86_64": "=1.2.3@4", "bar": ">=2"}; !reflect.DeepEqual(ps.PkgDependencies, want) {
+		t.Errorf("Subpackage(foo-dev) depends on %v, want %v", ps.PkgDependencies, want)
+	}
+	dbg, err := gs.Subpackage("foo-debug")
+	if err != nil {
+		t.Fatalf("Subpackage(foo-debug) returned %v", err)
+	}
+	if dbg.PackageSpec.Description != "Foo" {
+		t.Errorf("Subpackage(foo-debug) has description %q, want the inherited Foo", dbg.PackageSpec.Description)
+	}
+	if gs.PackageSpec.Name != "foo" || gs.PackageSpec.PkgDependencies != nil {
+		t.Errorf("Subpackage modified the original spec: %+v", gs.PackageSpec)
+	}
+	if _, err := gs.Subpackage("foo-doc"); err == nil {
+		t.Error("Subpackage of an undeclared subpackage did not fail")
+	}
+
+	gs.Subpackages["foo.x86_64"] = SubpackageSpec{}
+	if err := gs.verify(); err == nil {
+		t.Error("verify of a goospec with an invalid subpackage name did not fail")
+	}
+}
+
 func TestNormalize(t *testing.T) {
 	var input *PkgSpec
 	if runtime.GOOS == "windows" {
@@ -0,0 +1,264 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goolib
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/googet/v2/oswrap"
+)
+
+// ManifestSuffix is appended to the name of a package file to name the
+// sidecar file holding its JSON encoded file manifest.
+const ManifestSuffix = ".manifest"
+
+// ManifestEntry describes a single file contained in a package.
+type ManifestEntry struct {
+	Path     string
+	Size     int64
+	Checksum string
+}
+
+// pkgManifestSuffix names the package entry holding the manifest of the other
+// files in the package, written by WritePackageManifest.
+const pkgManifestSuffix = ".pkgmanifest"
+
+// WritePackageManifest writes the manifest of files, the files already
+// written to tw, to the package and returns its own manifest entry.
+func WritePackageManifest(tw *tar.Writer, spec *PkgSpec, files []ManifestEntry) (ManifestEntry, error) {
+	b, err := json.MarshalIndent(files, "", "  ")
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	fh := &tar.Header{
+		Name:    spec.Name + pkgManifestSuffix,
+		Size:    int64(len(b)),
+		ModTime: time.Now(),
+		Mode:    0644,
+	}
+	if err := tw.WriteHeader(fh); err != nil {
+		return ManifestEntry{}, err
+	}
+	if _, err := tw.Write(b); err != nil {
+		return ManifestEntry{}, err
+	}
+	return manifestEntry(fh.Name, b), nil
+}
+
+// ReadPackageManifest returns the manifest embedded in the package of spec
+// extracted to dir and its SHA-256 checksum, nil if the package has none.
+func ReadPackageManifest(dir string, spec *PkgSpec) ([]ManifestEntry, string, error) {
+	f, err := oswrap.Open(filepath.Join(dir, spec.Name+pkgManifestSuffix))
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, "", err
+	}
+	var files []ManifestEntry
+	if err := json.Unmarshal(b, &files); err != nil {
+		return nil, "", fmt.Errorf("error parsing package manifest: %v", err)
+	}
+	sum := sha256.Sum256(b)
+	return files, hex.EncodeToString(sum[:]), nil
+}
+
+// ReadManifest reads a gzipped package and returns the manifest of the files
+// it contains along with its PkgSpec.
+func ReadManifest(r io.Reader) ([]ManifestEntry, *PkgSpec, error) {
+	pc, err := readPackage(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pc.manifest, pc.spec, nil
+}
+
+// ReadFileSizes reads a gzipped package and returns the paths and sizes of
+// the regular files it contains from their tar headers, without checksums.
+func ReadFileSizes(r io.Reader) ([]ManifestEntry, error) {
+	zr, err := NewPackageReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(zr)
+	var files []ManifestEntry
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ext := filepath.Ext(header.Name); header.Typeflag != tar.TypeReg || ext == pkgSpecSuffix || ext == pkgSigSuffix {
+			continue
+		}
+		files = append(files, ManifestEntry{Path: header.Name, Size: header.Size})
+	}
+}
+
+// PackageDetails describes the contents of a package.
+type PackageDetails struct {
+	Spec *PkgSpec
+	// Files lists the regular files in the package, without its spec and
+	// signature.
+	Files  []ManifestEntry
+	Signed bool
+}
+
+// InspectPackage reads a package and returns its details.
+func InspectPackage(r io.Reader) (*PackageDetails, error) {
+	pc, err := readPackage(r)
+	if err != nil {
+		return nil, err
+	}
+	return &PackageDetails{Spec: pc.spec, Files: pc.manifest, Signed: pc.sig != nil}, nil
+}
+
+// ExtractPackage extracts every directory and regular file of the package
+// read from r into dir, creating it if needed. Entries that would be written
+// outside of dir are rejected.
+func ExtractPackage(r io.Reader, dir string) error {
+	zr, err := NewPackageReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(zr)
+	if err := oswrap.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error opening file: %v", err)
+		}
+
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if name == ".." || strings.HasPrefix(name, ".."+string(os.PathSeparator)) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+			return fmt.Errorf("error unpacking package, file contains path traversal: %q", header.Name)
+		}
+
+		path := filepath.Join(dir, name)
+		fi := header.FileInfo()
+		if fi.IsDir() {
+			if err := oswrap.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		if err := oswrap.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		f, err := oswrap.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+}
+
+// packageContents is what readPackage finds in a package.
+type packageContents struct {
+	// manifest lists the regular files, without the spec and signature.
+	manifest  []ManifestEntry
+	spec      *PkgSpec
+	specEntry ManifestEntry
+	// sig is the embedded package signature, nil if the package is unsigned.
+	sig []byte
+}
+
+func readPackage(r io.Reader) (*packageContents, error) {
+	zr, err := NewPackageReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(zr)
+
+	var pc packageContents
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch filepath.Ext(header.Name) {
+		case pkgSpecSuffix:
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			if pc.spec, err = UnmarshalPackageSpec(data); err != nil {
+				return nil, err
+			}
+			pc.specEntry = manifestEntry(header.Name, data)
+			continue
+		case pkgSigSuffix:
+			if pc.sig, err = ioutil.ReadAll(tr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		hash := sha256.New()
+		n, err := io.Copy(hash, tr)
+		if err != nil {
+			return nil, err
+		}
+		pc.manifest = append(pc.manifest, ManifestEntry{
+			Path:     header.Name,
+			Size:     n,
+			Checksum: hex.EncodeToString(hash.Sum(nil)),
+		})
+	}
+	if pc.spec == nil {
+		return nil, fmt.Errorf("no file with suffix %q found in package", pkgSpecSuffix)
+	}
+	return &pc, nil
+}
+
+func manifestEntry(name string, data []byte) ManifestEntry {
+	sum := sha256.Sum256(data)
+	return ManifestEntry{Path: name, Size: int64(len(data)), Checksum: hex.EncodeToString(sum[:])}
+}
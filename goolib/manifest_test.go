@@ -0,0 +1,174 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goolib
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestReadManifest(t *testing.T) {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "dir/foo.txt", Typeflag: tar.TypeReg, Size: 3, Mode: 0644}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if err := WritePackageSpec(tw, &PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	gw.Close()
+
+	manifest, spec, err := ReadManifest(buf)
+	if err != nil {
+		t.Fatalf("error running ReadManifest: %v", err)
+	}
+	if spec.Name != "foo" {
+		t.Errorf("ReadManifest returned spec name %q, want %q", spec.Name, "foo")
+	}
+	want := []ManifestEntry{{
+		Path:     "dir/foo.txt",
+		Size:     3,
+		Checksum: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+	}}
+	if !reflect.DeepEqual(manifest, want) {
+		t.Errorf("ReadManifest returned %+v, want %+v", manifest, want)
+	}
+}
+
+func TestReadFileSizes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "dir/foo.txt", Typeflag: tar.TypeReg, Size: 3, Mode: 0644}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("foo")); err != nil {
+		t.Fatal(err)
+	}
+	if err := WritePackageSpec(tw, &PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	gw.Close()
+
+	files, err := ReadFileSizes(buf)
+	if err != nil {
+		t.Fatalf("error running ReadFileSizes: %v", err)
+	}
+	if want := []ManifestEntry{{Path: "dir/foo.txt", Size: 3}}; !reflect.DeepEqual(files, want) {
+		t.Errorf("ReadFileSizes = %v, want %v", files, want)
+	}
+}
+
+func TestInspectPackage(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	files := []ManifestEntry{manifestEntry("foo.txt", []byte("foo"))}
+	for _, tt := range []struct {
+		key    ed25519.PrivateKey
+		signed bool
+	}{{nil, false}, {priv, true}} {
+		d, err := InspectPackage(bytes.NewReader(testPackage(t, "foo", tt.key, files)))
+		if err != nil {
+			t.Fatalf("error running InspectPackage: %v", err)
+		}
+		if d.Spec.Name != "foo" || d.Signed != tt.signed || !reflect.DeepEqual(d.Files, files) {
+			t.Errorf("InspectPackage returned %+v, want spec foo, signed %t and files %+v", d, tt.signed, files)
+		}
+	}
+}
+
+// tarPackage returns a package of the given files and directories, keyed by
+// name, directories ending with a slash.
+func tarPackage(t *testing.T, entries map[string]string) []byte {
+	var names []string
+	for n := range entries {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for _, n := range names {
+		h := &tar.Header{Name: n, Typeflag: tar.TypeReg, Size: int64(len(entries[n])), Mode: 0640}
+		if strings.HasSuffix(n, "/") {
+			h = &tar.Header{Name: n, Typeflag: tar.TypeDir, Mode: 0755}
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entries[n])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gw.Close()
+	return buf.Bytes()
+}
+
+func TestExtractPackage(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	dir := filepath.Join(tempDir, "out")
+	// Paths over 255 characters need a PAX header.
+	long := strings.Repeat(strings.Repeat("d", 50)+"/", 6) + "long.txt"
+	pkg := tarPackage(t, map[string]string{"empty/": "", "dir/foo.txt": "foo", "dir/../bar.txt": "bar", long: "long"})
+	if err := ExtractPackage(bytes.NewReader(pkg), dir); err != nil {
+		t.Fatalf("error running ExtractPackage: %v", err)
+	}
+	for name, want := range map[string]string{"dir/foo.txt": "foo", "bar.txt": "bar", long: "long"} {
+		got, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != want {
+			t.Errorf("extracted %s = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "empty")); err != nil || !fi.IsDir() {
+		t.Errorf("directory empty was not extracted: %v", err)
+	}
+
+	for _, name := range []string{"../escape.txt", "dir/../../escape.txt", ".."} {
+		if err := ExtractPackage(bytes.NewReader(tarPackage(t, map[string]string{name: "x"})), dir); err == nil {
+			t.Errorf("ExtractPackage of %q did not fail", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "escape.txt")); err == nil {
+		t.Error("ExtractPackage wrote outside of the destination")
+	}
+}
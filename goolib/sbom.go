@@ -0,0 +1,132 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goolib
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SBOMSuffix is appended to the name of a package file to name its CycloneDX
+// software bill of materials, it also names the copy embedded in a package.
+const SBOMSuffix = ".cdx.json"
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxLicense struct {
+	License struct {
+		Name string `json:"name"`
+	} `json:"license"`
+}
+
+type cdxComponent struct {
+	Type        string       `json:"type"`
+	BOMRef      string       `json:"bom-ref"`
+	Name        string       `json:"name"`
+	Version     string       `json:"version,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Author      string       `json:"author,omitempty"`
+	Licenses    []cdxLicense `json:"licenses,omitempty"`
+	Hashes      []cdxHash    `json:"hashes,omitempty"`
+}
+
+type cdxTool struct {
+	Name string `json:"name"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+type cdxBOM struct {
+	BOMFormat    string `json:"bomFormat"`
+	SpecVersion  string `json:"specVersion"`
+	SerialNumber string `json:"serialNumber"`
+	Version      int    `json:"version"`
+	Metadata     struct {
+		Timestamp string       `json:"timestamp"`
+		Tools     []cdxTool    `json:"tools"`
+		Component cdxComponent `json:"component"`
+	} `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+// SBOM returns a CycloneDX software bill of materials, in JSON, of the package
+// described by spec with the files in manifest. Dependencies, including the
+// packages providing interpreters, are listed with their minimum version.
+func SBOM(spec *PkgSpec, manifest []ManifestEntry, tool string) ([]byte, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	// A version 4 UUID.
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:]),
+		Version:      1,
+	}
+	bom.Metadata.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	bom.Metadata.Tools = []cdxTool{{tool}}
+	pkg := cdxComponent{
+		Type:        "application",
+		BOMRef:      spec.String(),
+		Name:        spec.Name,
+		Version:     spec.Version,
+		Description: spec.Description,
+		Author:      spec.Authors,
+	}
+	if spec.License != "" {
+		var l cdxLicense
+		l.License.Name = spec.License
+		pkg.Licenses = []cdxLicense{l}
+	}
+	bom.Metadata.Component = pkg
+
+	files := append([]ManifestEntry{}, manifest...)
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	for _, f := range files {
+		bom.Components = append(bom.Components, cdxComponent{
+			Type:   "file",
+			BOMRef: "file:" + f.Path,
+			Name:   f.Path,
+			Hashes: []cdxHash{{"SHA-256", f.Checksum}},
+		})
+	}
+	dep := cdxDependency{Ref: pkg.BOMRef, DependsOn: []string{}}
+	deps := spec.Dependencies()
+	for _, d := range sortedKeys(deps) {
+		ref := "package:" + d
+		bom.Components = append(bom.Components, cdxComponent{
+			Type:    "application",
+			BOMRef:  ref,
+			Name:    d,
+			Version: deps[d],
+		})
+		dep.DependsOn = append(dep.DependsOn, ref)
+	}
+	bom.Dependencies = []cdxDependency{dep}
+	return json.MarshalIndent(bom, "", "  ")
+}
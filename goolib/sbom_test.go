@@ -0,0 +1,72 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goolib
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSBOM(t *testing.T) {
+	spec := &PkgSpec{
+		Name:            "foo",
+		Version:         "1.2.3@4",
+		Arch:            "x86_64",
+		License:         "Apache-2.0",
+		PkgDependencies: map[string]string{"qux": "2.0.0", "bar": "1.0.0"},
+	}
+	manifest := []ManifestEntry{{Path: "foo/b.exe", Checksum: "bb"}, {Path: "foo/a.txt", Checksum: "aa"}}
+	b, err := SBOM(spec, manifest, "goopack")
+	if err != nil {
+		t.Fatalf("error generating SBOM: %v", err)
+	}
+	var bom cdxBOM
+	if err := json.Unmarshal(b, &bom); err != nil {
+		t.Fatalf("error unmarshalling SBOM: %v", err)
+	}
+	if bom.BOMFormat != "CycloneDX" || bom.SpecVersion != "1.5" {
+		t.Errorf("SBOM format = %s %s, want CycloneDX 1.5", bom.BOMFormat, bom.SpecVersion)
+	}
+	if !regexp.MustCompile(`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(bom.SerialNumber) {
+		t.Errorf("serial number %q is not a version 4 UUID URN", bom.SerialNumber)
+	}
+	if c := bom.Metadata.Component; c.BOMRef != "foo.x86_64.1.2.3@4" || len(c.Licenses) != 1 || c.Licenses[0].License.Name != "Apache-2.0" {
+		t.Errorf("unexpected package component: %+v", c)
+	}
+
+	var got []string
+	for _, c := range bom.Components {
+		s := c.Type + " " + c.Name + " " + c.Version
+		for _, h := range c.Hashes {
+			s += " " + h.Alg + ":" + h.Content
+		}
+		got = append(got, s)
+	}
+	want := []string{
+		"file foo/a.txt  SHA-256:aa",
+		"file foo/b.exe  SHA-256:bb",
+		"application bar 1.0.0",
+		"application qux 2.0.0",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("SBOM components unexpected diff (-want +got):\n%v", diff)
+	}
+	wantDeps := []cdxDependency{{Ref: "foo.x86_64.1.2.3@4", DependsOn: []string{"package:bar", "package:qux"}}}
+	if diff := cmp.Diff(wantDeps, bom.Dependencies); diff != "" {
+		t.Errorf("SBOM dependencies unexpected diff (-want +got):\n%v", diff)
+	}
+}
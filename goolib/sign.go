@@ -0,0 +1,268 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goolib
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// SignatureSuffix is appended to the name of a signed file to name its
+// detached signature, e.g. index.sig.
+const SignatureSuffix = ".sig"
+
+// PublicKeySuffix is appended to the name of a signed file to name the
+// public key its signature verifies with, e.g. index.pub.
+const PublicKeySuffix = ".pub"
+
+// pkgSigSuffix names the package entry holding the signature embedded by the
+// package author, e.g. googet.pkgsig.
+const pkgSigSuffix = ".pkgsig"
+
+// ErrUnsigned is returned by VerifyPackage for packages without an embedded
+// signature.
+var ErrUnsigned = errors.New("package has no embedded signature")
+
+func readPEM(path, typ string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodePEM(b, path, typ)
+}
+
+// decodePEM returns the contents of the PEM block of type typ in b, name
+// identifies b in errors.
+func decodePEM(b []byte, name, typ string) ([]byte, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != typ {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded %s", name, typ)
+	}
+	return block.Bytes, nil
+}
+
+// ReadSigningKey reads a PEM encoded PKCS #8 ed25519 private key.
+func ReadSigningKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	k, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := k.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported key type %T, only ed25519 keys are supported", path, k)
+	}
+	return key, nil
+}
+
+// ReadVerifyKey reads a PEM encoded PKIX ed25519 public key.
+func ReadVerifyKey(path string) (ed25519.PublicKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseVerifyKey(b, path)
+}
+
+// ParseVerifyKey parses a PEM encoded PKIX ed25519 public key.
+func ParseVerifyKey(b []byte) (ed25519.PublicKey, error) {
+	return parseVerifyKey(b, "key")
+}
+
+func parseVerifyKey(b []byte, name string) (ed25519.PublicKey, error) {
+	der, err := decodePEM(b, name, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	k, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := k.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported key type %T, only ed25519 keys are supported", name, k)
+	}
+	return key, nil
+}
+
+// MarshalVerifyKey returns key PEM encoded as ReadVerifyKey reads it.
+func MarshalVerifyKey(key ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// Sign returns the base64 encoded ed25519 signature of data.
+func Sign(key ed25519.PrivateKey, data []byte) []byte {
+	sig := ed25519.Sign(key, data)
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(sig)))
+	base64.StdEncoding.Encode(out, sig)
+	return out
+}
+
+// VerifySignature reports whether sig, as produced by Sign, is a valid
+// signature of data by key.
+func VerifySignature(key ed25519.PublicKey, data, sig []byte) bool {
+	raw, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(key, data, raw)
+}
+
+// signedContent returns the data covered by a package signature, the
+// manifest of all files in the package, including the spec, sorted by path.
+func signedContent(files []ManifestEntry) ([]byte, error) {
+	sorted := append([]ManifestEntry{}, files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+	return json.Marshal(sorted)
+}
+
+// WritePackageSignature signs the package being written to tw with key and
+// writes the signature as the last entry. files is the manifest of the files
+// written to tw, the spec written by WritePackageSpec is added to it.
+func WritePackageSignature(tw *tar.Writer, key ed25519.PrivateKey, spec *PkgSpec, files []ManifestEntry) error {
+	c, err := MarshalPackageSpec(spec)
+	if err != nil {
+		return err
+	}
+	data, err := signedContent(append(files, manifestEntry(spec.Name+pkgSpecSuffix, c)))
+	if err != nil {
+		return err
+	}
+	sig := Sign(key, data)
+	fh := &tar.Header{
+		Name:    spec.Name + pkgSigSuffix,
+		Size:    int64(len(sig)),
+		ModTime: time.Now(),
+		Mode:    0644,
+	}
+	if err := tw.WriteHeader(fh); err != nil {
+		return err
+	}
+	_, err = tw.Write(sig)
+	return err
+}
+
+// SignPackage copies the package read from src to dst, signed with key. An
+// existing embedded signature is replaced.
+func SignPackage(dst io.Writer, src io.Reader, key ed25519.PrivateKey) error {
+	zr, err := NewPackageReader(src)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(zr)
+	gw := gzip.NewWriter(dst)
+	tw := tar.NewWriter(gw)
+	var files []ManifestEntry
+	var spec *PkgSpec
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(header.Name)
+		if ext == pkgSigSuffix {
+			continue
+		}
+		if ext != pkgSpecSuffix {
+			// Other files are streamed, they can be larger than memory.
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			hash := sha256.New()
+			n, err := io.Copy(io.MultiWriter(tw, hash), tr)
+			if err != nil {
+				return err
+			}
+			if header.Typeflag == tar.TypeReg {
+				files = append(files, ManifestEntry{Path: header.Name, Size: n, Checksum: hex.EncodeToString(hash.Sum(nil))})
+			}
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if spec, err = UnmarshalPackageSpec(data); err != nil {
+			return err
+		}
+		// WritePackageSignature covers the spec as MarshalPackageSpec
+		// writes it.
+		if data, err = MarshalPackageSpec(spec); err != nil {
+			return err
+		}
+		header.Size = int64(len(data))
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if spec == nil {
+		return fmt.Errorf("no file with suffix %q found in package", pkgSpecSuffix)
+	}
+	if err := WritePackageSignature(tw, key, spec, files); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// VerifyPackage checks the signature embedded in the package read from r
+// against key and returns the package spec. It returns ErrUnsigned if the
+// package has no signature.
+func VerifyPackage(r io.Reader, key ed25519.PublicKey) (*PkgSpec, error) {
+	pc, err := readPackage(r)
+	if err != nil {
+		return nil, err
+	}
+	if pc.sig == nil {
+		return nil, ErrUnsigned
+	}
+	data, err := signedContent(append(pc.manifest, pc.specEntry))
+	if err != nil {
+		return nil, err
+	}
+	if !VerifySignature(key, data, pc.sig) {
+		return nil, fmt.Errorf("embedded signature of package %s does not match its contents or was made with another key", pc.spec)
+	}
+	return pc.spec, nil
+}
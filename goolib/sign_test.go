@@ -0,0 +1,150 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goolib
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privPath := filepath.Join(tempDir, "key.pem")
+	pubPath := filepath.Join(tempDir, "key.pub")
+	if err := ioutil.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sk, err := ReadSigningKey(privPath)
+	if err != nil {
+		t.Fatalf("error reading signing key: %v", err)
+	}
+	vk, err := ReadVerifyKey(pubPath)
+	if err != nil {
+		t.Fatalf("error reading verify key: %v", err)
+	}
+	if _, err := ReadVerifyKey(privPath); err == nil {
+		t.Error("ReadVerifyKey accepted a private key, want error")
+	}
+
+	data := []byte(`[{"Checksum": "abc"}]`)
+	sig := Sign(sk, data)
+	if !VerifySignature(vk, data, append(sig, '\n')) {
+		t.Error("VerifySignature did not accept valid signature")
+	}
+	if VerifySignature(vk, []byte(`[]`), sig) {
+		t.Error("VerifySignature accepted signature for different data")
+	}
+	if VerifySignature(vk, data, []byte("not base64!")) {
+		t.Error("VerifySignature accepted malformed signature")
+	}
+}
+
+// testPackage returns a package holding foo.txt with content, signed with key
+// over files if key is not nil.
+func testPackage(t *testing.T, content string, key ed25519.PrivateKey, files []ManifestEntry) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Name: "foo.txt", Typeflag: tar.TypeReg, Size: int64(len(content)), Mode: 0644}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	spec := &PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}
+	if err := WritePackageSpec(tw, spec); err != nil {
+		t.Fatal(err)
+	}
+	if key != nil {
+		if err := WritePackageSignature(tw, key, spec, files); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gw.Close()
+	return buf.Bytes()
+}
+
+func TestPackageSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	other, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	files := []ManifestEntry{manifestEntry("foo.txt", []byte("foo"))}
+
+	signed := testPackage(t, "foo", priv, files)
+	if spec, err := VerifyPackage(bytes.NewReader(signed), pub); err != nil || spec.Name != "foo" {
+		t.Errorf("VerifyPackage of a signed package = %v, %v, want spec foo", spec, err)
+	}
+	if _, err := VerifyPackage(bytes.NewReader(signed), other); err == nil {
+		t.Error("VerifyPackage accepted a signature made with another key")
+	}
+	// The signature does not cover what is in the package.
+	if _, err := VerifyPackage(bytes.NewReader(testPackage(t, "bar", priv, files)), pub); err == nil {
+		t.Error("VerifyPackage accepted a package with modified contents")
+	}
+
+	unsigned := testPackage(t, "foo", nil, nil)
+	if _, err := VerifyPackage(bytes.NewReader(unsigned), pub); err != ErrUnsigned {
+		t.Errorf("VerifyPackage of an unsigned package returned %v, want %v", err, ErrUnsigned)
+	}
+	var resigned bytes.Buffer
+	if err := SignPackage(&resigned, bytes.NewReader(unsigned), priv); err != nil {
+		t.Fatalf("error running SignPackage: %v", err)
+	}
+	if _, err := VerifyPackage(&resigned, pub); err != nil {
+		t.Errorf("VerifyPackage of a package signed by SignPackage returned %v", err)
+	}
+	manifest, _, err := ReadManifest(bytes.NewReader(signed))
+	if err != nil {
+		t.Fatalf("error running ReadManifest: %v", err)
+	}
+	if len(manifest) != 1 {
+		t.Errorf("ReadManifest of a signed package returned %+v, want only foo.txt", manifest)
+	}
+}
@@ -0,0 +1,464 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The convert mode turns a Chocolatey package into a goospec, the package
+// contents and a GooGet package built from them.
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+)
+
+// convertContents is the directory of the package contents next to the
+// converted goospec.
+const convertContents = "pkg"
+
+// nuspec is the metadata of a NuGet package, as used by Chocolatey.
+type nuspec struct {
+	Metadata struct {
+		ID               string         `xml:"id"`
+		Version          string         `xml:"version"`
+		Title            string         `xml:"title"`
+		Authors          string         `xml:"authors"`
+		Owners           string         `xml:"owners"`
+		Summary          string         `xml:"summary"`
+		Description      string         `xml:"description"`
+		ProjectURL       string         `xml:"projectUrl"`
+		ProjectSourceURL string         `xml:"projectSourceUrl"`
+		LicenseURL       string         `xml:"licenseUrl"`
+		License          string         `xml:"license"`
+		ReleaseNotes     string         `xml:"releaseNotes"`
+		Dependencies     []nuDependency `xml:"dependencies>dependency"`
+		// Dependencies of NuGet 2.12 and later can be grouped by target
+		// framework, which Chocolatey doesn't use.
+		GroupDependencies []nuDependency `xml:"dependencies>group>dependency"`
+	} `xml:"metadata"`
+}
+
+type nuDependency struct {
+	ID      string `xml:"id,attr"`
+	Version string `xml:"version,attr"`
+}
+
+// convertedSpec is the goospec written for a converted package.
+type convertedSpec struct {
+	Name            string            `json:"name"`
+	Version         string            `json:"version"`
+	Arch            string            `json:"arch"`
+	Authors         string            `json:"authors,omitempty"`
+	Owners          string            `json:"owners,omitempty"`
+	License         string            `json:"license,omitempty"`
+	Description     string            `json:"description,omitempty"`
+	Source          string            `json:"source,omitempty"`
+	ReleaseNotes    []string          `json:"releaseNotes,omitempty"`
+	PkgDependencies map[string]string `json:"pkgDependencies,omitempty"`
+	Files           map[string]string `json:"files,omitempty"`
+	Install         *convertedExec    `json:"install,omitempty"`
+	Uninstall       *convertedExec    `json:"uninstall,omitempty"`
+	Sources         []convertedSource `json:"sources"`
+}
+
+type convertedExec struct {
+	Path string `json:"path"`
+}
+
+type convertedSource struct {
+	Include []string `json:"include"`
+	Root    string   `json:"root"`
+}
+
+// nupkgMetadata reports whether name is NuGet packaging metadata rather than
+// package contents.
+func nupkgMetadata(name string) bool {
+	return strings.HasPrefix(name, "_rels/") || strings.HasPrefix(name, "package/") || name == "[Content_Types].xml"
+}
+
+// readNupkg extracts the contents of the .nupkg file src to dir and returns
+// its nuspec and the paths of the files extracted, relative to dir.
+func readNupkg(src, dir string) (*nuspec, []string, error) {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zr.Close()
+	var ns *nuspec
+	var files []string
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/") || nupkgMetadata(f.Name) {
+			continue
+		}
+		// NuGet escapes the names of the files in the archive.
+		name, err := url.PathUnescape(f.Name)
+		if err != nil {
+			name = f.Name
+		}
+		name = strings.ReplaceAll(name, `\`, "/")
+		if path.IsAbs(name) || name != path.Clean(name) || strings.HasPrefix(name, "../") {
+			return nil, nil, fmt.Errorf("invalid file name %q in package", f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, nil, err
+		}
+		if !strings.Contains(name, "/") && strings.HasSuffix(strings.ToLower(name), ".nuspec") {
+			ns = new(nuspec)
+			err = xml.NewDecoder(rc).Decode(ns)
+			rc.Close()
+			if err != nil {
+				return nil, nil, fmt.Errorf("error parsing %s: %v", name, err)
+			}
+			continue
+		}
+		err = extractFile(rc, filepath.Join(dir, filepath.FromSlash(name)))
+		rc.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, name)
+	}
+	if ns == nil {
+		return nil, nil, fmt.Errorf("%s has no .nuspec", src)
+	}
+	sort.Strings(files)
+	return ns, files, nil
+}
+
+func extractFile(r io.Reader, dst string) error {
+	if err := oswrap.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	f, err := oswrap.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// invalidNameChars match the characters of Chocolatey package IDs GooGet
+// package names can't have.
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9_+-]+`)
+
+// convertName returns the GooGet name of the Chocolatey package id.
+func convertName(id string) string {
+	return invalidNameChars.ReplaceAllString(strings.ToLower(id), "-")
+}
+
+// convertVersion returns the GooGet version of the NuGet version v. The
+// fourth part of a version, used by Chocolatey for fixes of the package
+// rather than of the software, becomes the release.
+func convertVersion(v string) (string, error) {
+	suffix := ""
+	if i := strings.IndexAny(v, "-+"); i != -1 {
+		v, suffix = v[:i], v[i:]
+	}
+	parts := strings.Split(v, ".")
+	gv := v + suffix
+	if len(parts) == 4 {
+		gv = strings.Join(parts[:3], ".") + suffix + "@" + strings.TrimLeft(parts[3], "0")
+		if strings.HasSuffix(gv, "@") {
+			gv += "0"
+		}
+	}
+	if _, err := goolib.ParseVersion(gv); err != nil {
+		return "", fmt.Errorf("can't convert version %q: %v", v+suffix, err)
+	}
+	return gv, nil
+}
+
+// convertRange returns the GooGet version range of the NuGet version range
+// r, such as "1.0", "[1.0]" or "[1.0,2.0)".
+func convertRange(r string) (string, error) {
+	r = strings.TrimSpace(r)
+	if r == "" {
+		return "", nil
+	}
+	if !strings.ContainsAny(r[:1], "[(") {
+		return convertVersion(r)
+	}
+	if len(r) < 3 || !strings.ContainsAny(r[len(r)-1:], "])") {
+		return "", fmt.Errorf("invalid version range %q", r)
+	}
+	bounds := strings.Split(r[1:len(r)-1], ",")
+	if len(bounds) == 1 {
+		v, err := convertVersion(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return "", err
+		}
+		return "=" + v, nil
+	}
+	if len(bounds) != 2 {
+		return "", fmt.Errorf("invalid version range %q", r)
+	}
+	var terms []string
+	if lo := strings.TrimSpace(bounds[0]); lo != "" {
+		v, err := convertVersion(lo)
+		if err != nil {
+			return "", err
+		}
+		op := ">="
+		if r[0] == '(' {
+			op = ">"
+		}
+		terms = append(terms, op+v)
+	}
+	if hi := strings.TrimSpace(bounds[1]); hi != "" {
+		v, err := convertVersion(hi)
+		if err != nil {
+			return "", err
+		}
+		op := "<="
+		if r[len(r)-1] == ')' {
+			op = "<"
+		}
+		terms = append(terms, op+v)
+	}
+	return strings.Join(terms, " "), nil
+}
+
+// chocolateyHelpers are the Chocolatey commands and variables converted
+// scripts may use, with how to do without them.
+var chocolateyHelpers = []struct {
+	re     *regexp.Regexp
+	advice string
+}{
+	{regexp.MustCompile(`(?i)\b(Install-ChocolateyPackage|Install-ChocolateyInstallPackage|Uninstall-ChocolateyPackage)\b`), "run the installer directly, or make it the install command of the package"},
+	{regexp.MustCompile(`(?i)\b(Get-ChocolateyWebFile|Install-ChocolateyZipPackage|Install-ChocolateyPowershellCommand|Install-ChocolateyVsixPackage)\b`), "package the files it downloads instead, GooGet verifies the checksums of packages"},
+	{regexp.MustCompile(`(?i)\b(Get-ChocolateyUnzip|Uninstall-ChocolateyZipPackage)\b`), "package the extracted files, or use Expand-Archive"},
+	{regexp.MustCompile(`(?i)\b(Install-BinFile|Uninstall-BinFile)\b`), "Chocolatey shims are not created, add the directory to the env path of the goospec"},
+	{regexp.MustCompile(`(?i)\bInstall-ChocolateyPath\b`), "add the directory to the env path of the goospec"},
+	{regexp.MustCompile(`(?i)\b(Install-ChocolateyEnvironmentVariable|Uninstall-ChocolateyEnvironmentVariable)\b`), "set the variable in the env vars of the goospec"},
+	{regexp.MustCompile(`(?i)\b(Install-ChocolateyShortcut|Install-ChocolateyDesktopLink|Install-ChocolateyPinnedTaskBarItem)\b`), "declare the shortcut in the shortcuts of the goospec"},
+	{regexp.MustCompile(`(?i)(\bGet-PackageParameters\b|\$env:chocolateyPackageParameters)`), "GooGet packages take no parameters, use fixed values or tags"},
+	{regexp.MustCompile(`(?i)(\$env:Chocolatey\w*|\bGet-ToolsLocation\b)`), "Chocolatey environment variables are not set by GooGet"},
+	{regexp.MustCompile(`(?i)\b(\w+-Chocolatey\w*|Update-SessionEnvironment|Get-UninstallRegistryKey|Get-OSArchitectureWidth|Get-ProcessorBits)\b`), "Chocolatey helpers are not available to GooGet scripts"},
+}
+
+// scanScript returns the notes on the Chocolatey constructs in the script
+// file, named name in the notes.
+func scanScript(file, name string) ([]string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var notes []string
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, h := range chocolateyHelpers {
+			// Each line is reported once, for the most specific helper.
+			if m := h.re.FindString(line); m != "" {
+				notes = append(notes, fmt.Sprintf("%s:%d: %s: %s", name, n, m, h.advice))
+				break
+			}
+		}
+	}
+	return notes, s.Err()
+}
+
+// convertNuspec returns the goospec of the Chocolatey package ns with the
+// files files, relative to the contents in dir, and notes on what needs
+// manual attention.
+func convertNuspec(ns *nuspec, files []string, dir string) (*convertedSpec, []string, error) {
+	md := ns.Metadata
+	var notes []string
+	name := convertName(md.ID)
+	if name != md.ID {
+		notes = append(notes, fmt.Sprintf("package %s is named %s", md.ID, name))
+	}
+	ver, err := convertVersion(md.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if strings.Contains(ver, "@") {
+		notes = append(notes, fmt.Sprintf("the fourth part of version %s is the release of %s, check that the versions of its updates are ordered the same", md.Version, ver))
+	}
+	cs := &convertedSpec{
+		Name:        name,
+		Version:     ver,
+		Arch:        "noarch",
+		Authors:     md.Authors,
+		Owners:      md.Owners,
+		License:     md.License,
+		Description: strings.TrimSpace(md.Description),
+		Source:      md.ProjectSourceURL,
+		Sources:     []convertedSource{{Include: []string{"**"}, Root: convertContents}},
+	}
+	if cs.License == "" {
+		cs.License = md.LicenseURL
+	}
+	if cs.Description == "" {
+		cs.Description = strings.TrimSpace(md.Summary)
+	}
+	if cs.Source == "" {
+		cs.Source = md.ProjectURL
+	}
+	for _, l := range strings.Split(strings.TrimSpace(md.ReleaseNotes), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			cs.ReleaseNotes = append(cs.ReleaseNotes, l)
+		}
+	}
+
+	for _, d := range append(md.Dependencies, md.GroupDependencies...) {
+		dn := convertName(d.ID)
+		if strings.HasSuffix(dn, "-extension") {
+			notes = append(notes, fmt.Sprintf("dependency on Chocolatey extension %s dropped", d.ID))
+			continue
+		}
+		r, err := convertRange(d.Version)
+		if err != nil {
+			return nil, nil, fmt.Errorf("dependency %s: %v", d.ID, err)
+		}
+		if cs.PkgDependencies == nil {
+			cs.PkgDependencies = make(map[string]string)
+		}
+		cs.PkgDependencies[dn] = r
+		notes = append(notes, fmt.Sprintf("dependency %s is %s, it has to be converted too", d.ID, dn))
+	}
+
+	// The contents are installed where Chocolatey would keep them, the
+	// scripts run from the unpacked package.
+	lib := "<ProgramData>/GooGet/lib/" + name
+	cs.Files = make(map[string]string)
+	var scripts []string
+	for _, f := range files {
+		top := strings.SplitN(f, "/", 2)[0]
+		cs.Files[top] = lib + "/" + top
+		switch strings.ToLower(f) {
+		case "tools/chocolateyinstall.ps1":
+			cs.Install = &convertedExec{Path: f}
+		case "tools/chocolateyuninstall.ps1":
+			cs.Uninstall = &convertedExec{Path: f}
+		case "tools/chocolateybeforemodify.ps1":
+			notes = append(notes, f+": GooGet has no before modify script, run it from the uninstall script or the install script of the next version")
+		}
+		switch strings.ToLower(path.Ext(f)) {
+		case ".ps1", ".psm1":
+			scripts = append(scripts, f)
+		case ".ignore", ".gui":
+			notes = append(notes, f+": Chocolatey shim control files have no effect")
+		}
+	}
+	if cs.Install == nil {
+		notes = append(notes, "no chocolateyInstall.ps1, the package only installs its files")
+	}
+	if cs.Uninstall == nil && cs.Install != nil {
+		notes = append(notes, "no chocolateyUninstall.ps1, GooGet has no automatic uninstaller, add an uninstall script if the install script installs software")
+	}
+	for _, f := range scripts {
+		n, err := scanScript(filepath.Join(dir, filepath.FromSlash(f)), f)
+		if err != nil {
+			return nil, nil, err
+		}
+		notes = append(notes, n...)
+	}
+	return cs, notes, nil
+}
+
+// writeConvertedSpec writes cs to file, escaping the template actions the
+// metadata may contain.
+func writeConvertedSpec(cs *convertedSpec, file string) error {
+	b, err := json.MarshalIndent(cs, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = bytes.ReplaceAll(b, []byte("{{"), []byte(`{{"{{"}}`))
+	return ioutil.WriteFile(file, append(b, '\n'), 0644)
+}
+
+// convertPackage converts the Chocolatey package src to a goospec and its
+// contents in dir, builds the GooGet package in outDir and returns the notes
+// on what needs manual attention.
+func convertPackage(src, dir, outDir string) ([]string, error) {
+	contents := filepath.Join(dir, convertContents)
+	ns, files, err := readNupkg(src, contents)
+	if err != nil {
+		return nil, err
+	}
+	cs, notes, err := convertNuspec(ns, files, contents)
+	if err != nil {
+		return nil, err
+	}
+	spec := filepath.Join(dir, cs.Name+".goospec")
+	if err := writeConvertedSpec(cs, spec); err != nil {
+		return nil, err
+	}
+	gs, err := goolib.ReadGooSpec(spec, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error reading converted goospec: %v", err)
+	}
+	// Sources are relative to the working directory of goopack.
+	gs.Sources[0].Root = contents
+	if err := createPackage(gs, dir, outDir, packageOptions{}); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// convert converts a Chocolatey package and returns the exit code.
+func convert(args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	dest := fs.String("dest", "", "directory to write the goospec and package contents to, named after the package in the current directory if empty")
+	out := fs.String("output_dir", "", "where to put the built package, the current directory if empty")
+	pkgs := parseInterspersed(fs, args)
+	if len(pkgs) != 1 {
+		fmt.Println("convert takes one package.")
+		usage()
+		return 1
+	}
+	dir := *dest
+	if dir == "" {
+		dir = strings.TrimSuffix(filepath.Base(pkgs[0]), filepath.Ext(pkgs[0]))
+	}
+	outDir := *out
+	if outDir == "" {
+		outDir = "."
+	}
+	notes, err := convertPackage(pkgs[0], filepath.Clean(dir), outDir)
+	if err != nil {
+		log.Fatalf("Error converting package %s: %v", pkgs[0], err)
+	}
+	fmt.Printf("Converted %s to %s\n", pkgs[0], dir)
+	if len(notes) > 0 {
+		fmt.Println("Needs manual attention:")
+		for _, n := range notes {
+			fmt.Println("  " + n)
+		}
+	}
+	return 0
+}
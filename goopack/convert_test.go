@@ -0,0 +1,228 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestConvertVersion(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"1.2.3", "1.2.3"},
+		{"1.2", "1.2"},
+		{"1.2.3.20260101", "1.2.3@20260101"},
+		{"1.2.3.0", "1.2.3@0"},
+		{"1.2.3-beta", "1.2.3-beta"},
+		{"1.2.3.4-beta", "1.2.3-beta@4"},
+	} {
+		got, err := convertVersion(tt.in)
+		if err != nil {
+			t.Errorf("convertVersion(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("convertVersion(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if _, err := convertVersion("1.2.3.4.5"); err == nil {
+		t.Error("convertVersion(1.2.3.4.5) did not return an error")
+	}
+}
+
+func TestConvertRange(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"1.0", "1.0"},
+		{"[1.0]", "=1.0"},
+		{"[1.0,2.0)", ">=1.0 <2.0"},
+		{"(1.0,]", ">1.0"},
+		{"(,2.0.0.1]", "<=2.0.0@1"},
+	} {
+		got, err := convertRange(tt.in)
+		if err != nil {
+			t.Errorf("convertRange(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("convertRange(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if _, err := goolib.ParseConstraint(got); err != nil {
+			t.Errorf("convertRange(%q) = %q, which does not parse: %v", tt.in, got, err)
+		}
+	}
+	for _, in := range []string{"[1.0", "[1.0,2.0,3.0]", "[x]"} {
+		if got, err := convertRange(in); err == nil {
+			t.Errorf("convertRange(%q) = %q, want an error", in, got)
+		}
+	}
+}
+
+const testNuspec = `<?xml version="1.0" encoding="utf-8"?>
+<package xmlns="http://schemas.microsoft.com/packaging/2015/06/nuspec.xsd">
+  <metadata>
+    <id>Foo.Install</id>
+    <version>1.4.0.20260101</version>
+    <authors>Foo Authors</authors>
+    <owners>packager</owners>
+    <licenseUrl>https://example.com/license</licenseUrl>
+    <projectUrl>https://example.com/foo</projectUrl>
+    <description>Foo does {{things}}.</description>
+    <releaseNotes>1.4.0 - Faster.
+1.3.0 - Smaller.</releaseNotes>
+    <dependencies>
+      <dependency id="bar" version="[2.0,3.0)" />
+      <dependency id="chocolatey-core.extension" version="1.1.0" />
+    </dependencies>
+  </metadata>
+</package>
+`
+
+const testInstallScript = `$ErrorActionPreference = 'Stop'
+# Install-ChocolateyPackage in a comment is not reported.
+$toolsDir = "$(Split-Path -parent $MyInvocation.MyCommand.Definition)"
+Install-ChocolateyPackage -PackageName 'foo' -Url 'https://example.com/foo.msi'
+Install-BinFile -Name foo -Path "$toolsDir\foo.exe"
+`
+
+func TestConvertPackage(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "foo.install.1.4.0.20260101.nupkg")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		"Foo.Install.nuspec":            testNuspec,
+		"[Content_Types].xml":           "<Types/>",
+		"_rels/.rels":                   "<Relationships/>",
+		"package/services/metadata.x":   "x",
+		"tools/chocolateyInstall.ps1":   testInstallScript,
+		"tools/foo%20helper.txt":        "help",
+		"tools/chocolateyUninstall.ps1": "Write-Host bye\n",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	dest := filepath.Join(dir, "foo")
+	notes, err := convertPackage(src, dest, dir)
+	if err != nil {
+		t.Fatalf("convertPackage: %v", err)
+	}
+	wantNotes := []string{
+		"package Foo.Install is named foo-install",
+		"the fourth part of version 1.4.0.20260101 is the release of 1.4.0@20260101",
+		"dependency bar is bar",
+		"dependency on Chocolatey extension chocolatey-core.extension dropped",
+		"tools/chocolateyInstall.ps1:4: Install-ChocolateyPackage: ",
+		"tools/chocolateyInstall.ps1:5: Install-BinFile: ",
+	}
+	if len(notes) != len(wantNotes) {
+		t.Errorf("convertPackage notes:\n%s\nwant %d notes", strings.Join(notes, "\n"), len(wantNotes))
+	}
+	for _, w := range wantNotes {
+		found := false
+		for _, n := range notes {
+			found = found || strings.HasPrefix(n, w)
+		}
+		if !found {
+			t.Errorf("convertPackage notes:\n%s\nwant one starting with %q", strings.Join(notes, "\n"), w)
+		}
+	}
+
+	gs, err := goolib.ReadGooSpec(filepath.Join(dest, "foo-install.goospec"), nil)
+	if err != nil {
+		t.Fatalf("error reading converted goospec: %v", err)
+	}
+	ps := gs.PackageSpec
+	want := &goolib.PkgSpec{
+		Name:            "foo-install",
+		Version:         "1.4.0@20260101",
+		Arch:            "noarch",
+		Authors:         "Foo Authors",
+		Owners:          "packager",
+		License:         "https://example.com/license",
+		Description:     "Foo does {{things}}.",
+		Source:          "https://example.com/foo",
+		ReleaseNotes:    []string{"1.4.0 - Faster.", "1.3.0 - Smaller."},
+		PkgDependencies: map[string]string{"bar": ">=2.0 <3.0"},
+		Files:           map[string]string{"tools": "<ProgramData>/GooGet/lib/foo-install/tools"},
+		Install:         goolib.ExecFile{Path: "tools/chocolateyInstall.ps1"},
+		Uninstall:       goolib.ExecFile{Path: "tools/chocolateyUninstall.ps1"},
+	}
+	ps.Replaces, ps.Conflicts = nil, nil
+	if !reflect.DeepEqual(ps, want) {
+		t.Errorf("converted spec = %+v, want %+v", ps, want)
+	}
+
+	pf, err := os.Open(filepath.Join(dir, "foo-install.noarch.1.4.0@20260101.goo"))
+	if err != nil {
+		t.Fatalf("converted package not built: %v", err)
+	}
+	defer pf.Close()
+	d, err := goolib.InspectPackage(pf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range d.Files {
+		got = append(got, e.Path)
+	}
+	sort.Strings(got)
+	if want := []string{"foo-install.pkgmanifest", "tools/chocolateyInstall.ps1", "tools/chocolateyUninstall.ps1", "tools/foo helper.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("converted package files = %v, want %v", got, want)
+	}
+}
+
+func TestReadNupkgTraversal(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "evil.nupkg")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("tools/..%2F..%2Fevil.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("evil"))
+	zw.Close()
+	f.Close()
+	if _, _, err := readNupkg(src, filepath.Join(dir, "out")); err == nil {
+		t.Error("readNupkg of a package escaping its directory did not return an error")
+	}
+}
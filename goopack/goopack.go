@@ -16,7 +16,12 @@ package main
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -24,16 +29,25 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
 	"runtime"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/go-yaml/yaml"
 	"github.com/google/googet/v2/goolib"
 	"github.com/google/googet/v2/oswrap"
 )
 
 var (
 	outputDir = flag.String("output_dir", "", "where to put the built package")
+	signKey   = flag.String("sign_key", "", "path to a PEM encoded ed25519 private key used to sign the package contents, the signature is embedded in the package")
+	archs     = flag.String("archs", "", "comma separated architectures to build the package for, instead of those declared in the goospec; the architecture is available to the goospec as {{.arch}}")
+	sbom      = flag.Bool("sbom", false, "write a CycloneDX SBOM of the package next to it, named after the package with a .cdx.json suffix")
+	embedSBOM = flag.Bool("embed_sbom", false, "embed a CycloneDX SBOM of the package in it as NAME.cdx.json")
+	lint      = flag.Bool("lint", false, "check the goospec for problems without building the package")
+	varFile   = flag.String("var_file", "", "path to a YAML or JSON file of template variables for the goospec, -var: flags take precedence")
+	cacheDir  = flag.String("cache_dir", "", "directory to cache the compressed package files in, files unchanged since a previous build with the same cache are not read or compressed again")
 )
 
 type fileMap map[string][]string
@@ -68,41 +82,9 @@ func walkDir(dir string) ([]string, error) {
 	return wl, nil
 }
 
-// pathMatch is a simpler filepath.Match but which supports recursive globbing
-// (**) and doesn't get any more special than * or **.
-func pathMatch(pattern, path string) (bool, error) {
-	regex := []rune("^")
-	runePattern := []rune(pattern)
-	for i := 0; i < len(runePattern); i++ {
-		ch := runePattern[i]
-		switch ch {
-		default:
-			regex = append(regex, ch)
-		case '%', '\\', '(', ')', '[', ']', '.', '^', '$', '?', '+', '{', '}', '=':
-			regex = append(regex, '\\', ch)
-		case '*':
-			if i+1 < len(runePattern) && runePattern[i+1] == '*' {
-				if i+2 < len(runePattern) && runePattern[i+2] == '*' {
-					return false, fmt.Errorf("%s: malformed glob", pattern)
-				}
-				regex = append(regex, []rune(".*")...)
-				i++
-			} else {
-				regex = append(regex, []rune("[^/]*")...)
-			}
-		}
-	}
-	regex = append(regex, '$')
-	re, err := regexp.Compile(string(regex))
-	if err != nil {
-		return false, err
-	}
-	return re.MatchString(path), nil
-}
-
 func anyMatch(patterns []string, name string) (bool, error) {
 	for _, ex := range patterns {
-		m, err := pathMatch(ex, name)
+		m, err := goolib.PathMatch(ex, name)
 		if err != nil {
 			return false, err
 		}
@@ -218,37 +200,294 @@ func globFiles(s goolib.PkgSources) ([]string, error) {
 	return glob(cr, s.Include, s.Exclude)
 }
 
-func writeFiles(tw *tar.Writer, fm fileMap) error {
+// fileHeader returns the tar header of file packaged in folder, with the mode
+// and owner in attrs if set.
+func fileHeader(fi os.FileInfo, folder, file string, attrs map[string]goolib.FileAttributes) (*tar.Header, error) {
+	fih, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return nil, err
+	}
+	fih.Name = filepath.ToSlash(filepath.Join(folder, filepath.Base(file)))
+	// PAX headers hold files over 8GiB and paths of any length. They would
+	// also record access and change times and the exact modification time,
+	// which differ between otherwise identical builds.
+	fih.Format = tar.FormatPAX
+	fih.AccessTime, fih.ChangeTime = time.Time{}, time.Time{}
+	fih.ModTime = fih.ModTime.Truncate(time.Second)
+	if a, ok := attrs[fih.Name]; ok {
+		m, err := goolib.ParseMode(a.Mode)
+		if err != nil {
+			return nil, err
+		}
+		if m != 0 {
+			fih.Mode = int64(m)
+		}
+		if a.Owner != "" {
+			fih.Uname, fih.Gname = a.SplitOwner()
+		}
+	}
+	return fih, nil
+}
+
+// writeFiles writes the files in fm to tw, with the mode and owner in attrs
+// if set, and returns their manifest.
+func writeFiles(tw *tar.Writer, fm fileMap, attrs map[string]goolib.FileAttributes) ([]goolib.ManifestEntry, error) {
+	var manifest []goolib.ManifestEntry
 	for folder, fl := range fm {
 		for _, file := range fl {
 			fi, err := oswrap.Stat(file)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			fpath := filepath.Join(folder, filepath.Base(file))
-			fih, err := tar.FileInfoHeader(fi, "")
+			fih, err := fileHeader(fi, folder, file, attrs)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			fih.Name = filepath.ToSlash(fpath)
 			if err := tw.WriteHeader(fih); err != nil {
-				return err
+				return nil, err
 			}
 			f, err := oswrap.Open(file)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			if _, err := io.Copy(tw, f); err != nil {
-				f.Close()
+			hash := sha256.New()
+			n, err := io.Copy(io.MultiWriter(tw, hash), f)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			manifest = append(manifest, goolib.ManifestEntry{Path: fih.Name, Size: n, Checksum: hex.EncodeToString(hash.Sum(nil))})
+		}
+	}
+	return manifest, nil
+}
+
+// packageOptions control what is written in addition to the package files.
+type packageOptions struct {
+	// key, if not nil, signs the package.
+	key ed25519.PrivateKey
+	// sbom writes an SBOM next to the package, embedSBOM embeds it.
+	sbom, embedSBOM bool
+	// cache, if not nil, reuses the compressed files of previous builds.
+	cache *buildCache
+}
+
+// buildCache keeps the gzip compressed tar entry of every packaged file in
+// dir, named after the checksum of its tar header and contents, so files that
+// did not change since a previous build are not compressed again.
+type buildCache struct {
+	dir string
+	// index maps source files to the checksum of their contents, which is
+	// reused as long as their size and modification time are unchanged so
+	// that unchanged files are not read again either.
+	index map[string]cachedFile
+}
+
+type cachedFile struct {
+	Size     int64
+	ModTime  time.Time
+	Checksum string
+}
+
+const cacheIndex = "index.json"
+
+// openBuildCache opens the build cache in dir, creating it if necessary.
+func openBuildCache(dir string) (*buildCache, error) {
+	if err := oswrap.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &buildCache{dir: dir, index: make(map[string]cachedFile)}
+	b, err := ioutil.ReadFile(filepath.Join(dir, cacheIndex))
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c.index); err != nil {
+		// A corrupt index only means files are read again.
+		log.Printf("Ignoring build cache index: %v", err)
+		c.index = make(map[string]cachedFile)
+	}
+	return c, nil
+}
+
+// save writes the index of the cache.
+func (c *buildCache) save() error {
+	b, err := json.Marshal(c.index)
+	if err != nil {
+		return err
+	}
+	return c.writeFile(cacheIndex, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+// writeFile replaces name in the cache with what write writes, so that
+// concurrent builds sharing the cache never see a partial file.
+func (c *buildCache) writeFile(name string, write func(io.Writer) error) error {
+	tmp, err := ioutil.TempFile(c.dir, "tmp")
+	if err != nil {
+		return err
+	}
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		oswrap.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		oswrap.Remove(tmp.Name())
+		return err
+	}
+	return oswrap.Rename(tmp.Name(), filepath.Join(c.dir, name))
+}
+
+// checksum returns the checksum of the contents of file.
+func (c *buildCache) checksum(file string, fi os.FileInfo) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	if cf, ok := c.index[abs]; ok && cf.Size == fi.Size() && cf.ModTime.Equal(fi.ModTime()) {
+		return cf.Checksum, nil
+	}
+	f, err := oswrap.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sum := goolib.Checksum(f)
+	c.index[abs] = cachedFile{Size: fi.Size(), ModTime: fi.ModTime(), Checksum: sum}
+	return sum, nil
+}
+
+// segment returns the path of the cached tar entry of file with header fih
+// and contents checksum sum, compressing it if it is not cached yet.
+func (c *buildCache) segment(file string, fih *tar.Header, sum string) (string, error) {
+	var hdr bytes.Buffer
+	if err := tar.NewWriter(&hdr).WriteHeader(fih); err != nil {
+		return "", err
+	}
+	key := sha256.New()
+	key.Write(hdr.Bytes())
+	io.WriteString(key, sum)
+	name := hex.EncodeToString(key.Sum(nil)) + ".gz"
+	path := filepath.Join(c.dir, name)
+	if _, err := oswrap.Stat(path); err == nil {
+		return path, nil
+	}
+
+	f, err := oswrap.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	err = c.writeFile(name, func(w io.Writer) error {
+		gw := gzip.NewWriter(w)
+		if _, err := gw.Write(hdr.Bytes()); err != nil {
+			return err
+		}
+		hash := sha256.New()
+		n, err := io.Copy(io.MultiWriter(gw, hash), f)
+		if err != nil {
+			return err
+		}
+		if n != fih.Size || hex.EncodeToString(hash.Sum(nil)) != sum {
+			return fmt.Errorf("%s changed while it was packaged", file)
+		}
+		// Pad the contents to the tar block size like tar.Writer does.
+		if pad := (blockSize - n%blockSize) % blockSize; pad > 0 {
+			if _, err := gw.Write(make([]byte, pad)); err != nil {
 				return err
 			}
-			f.Close()
+		}
+		return gw.Close()
+	})
+	if err != nil {
+		abs, _ := filepath.Abs(file)
+		delete(c.index, abs)
+		return "", err
+	}
+	return path, nil
+}
+
+// blockSize is the size of tar blocks.
+const blockSize = 512
+
+// writeFiles writes the files in fm to w like the function of the same name,
+// each as a gzip member of its own taken from the cache where possible.
+func (c *buildCache) writeFiles(w io.Writer, fm fileMap, attrs map[string]goolib.FileAttributes) ([]goolib.ManifestEntry, error) {
+	var manifest []goolib.ManifestEntry
+	for folder, fl := range fm {
+		for _, file := range fl {
+			fi, err := oswrap.Stat(file)
+			if err != nil {
+				return nil, err
+			}
+			fih, err := fileHeader(fi, folder, file, attrs)
+			if err != nil {
+				return nil, err
+			}
+			sum, err := c.checksum(file, fi)
+			if err != nil {
+				return nil, err
+			}
+			seg, err := c.segment(file, fih, sum)
+			if err != nil {
+				return nil, err
+			}
+			s, err := oswrap.Open(seg)
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.Copy(w, s)
+			s.Close()
+			if err != nil {
+				return nil, err
+			}
+			manifest = append(manifest, goolib.ManifestEntry{Path: fih.Name, Size: fih.Size, Checksum: sum})
 		}
 	}
-	return nil
+	return manifest, nil
+}
+
+// writeSBOM embeds an SBOM of the package in tw, if requested, adding it to
+// manifest, and writes it next to the package pn in dir.
+func writeSBOM(tw *tar.Writer, spec *goolib.PkgSpec, manifest []goolib.ManifestEntry, dir, pn string, opts packageOptions) ([]goolib.ManifestEntry, error) {
+	if !opts.sbom && !opts.embedSBOM {
+		return manifest, nil
+	}
+	b, err := goolib.SBOM(spec, manifest, "goopack")
+	if err != nil {
+		return nil, err
+	}
+	if opts.sbom {
+		if err := ioutil.WriteFile(filepath.Join(dir, pn+goolib.SBOMSuffix), b, 0644); err != nil {
+			return nil, err
+		}
+	}
+	if !opts.embedSBOM {
+		return manifest, nil
+	}
+	fh := &tar.Header{
+		Name:    spec.Name + goolib.SBOMSuffix,
+		Size:    int64(len(b)),
+		ModTime: time.Now(),
+		Mode:    0644,
+	}
+	if err := tw.WriteHeader(fh); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(b); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	return append(manifest, goolib.ManifestEntry{Path: fh.Name, Size: fh.Size, Checksum: hex.EncodeToString(sum[:])}), nil
 }
 
-func packageFiles(fm fileMap, gs *goolib.GooSpec, dir string) (err error) {
+// packageFiles writes the package to dir with the extras requested in opts.
+func packageFiles(fm fileMap, gs *goolib.GooSpec, dir string, opts packageOptions) (err error) {
 	pn := goolib.PackageInfo{Name: gs.PackageSpec.Name, Arch: gs.PackageSpec.Arch, Ver: gs.PackageSpec.Version}.PkgName()
 	f, err := oswrap.Create(filepath.Join(dir, pn))
 	if err != nil {
@@ -260,6 +499,14 @@ func packageFiles(fm fileMap, gs *goolib.GooSpec, dir string) (err error) {
 			err = cErr
 		}
 	}()
+	var manifest []goolib.ManifestEntry
+	if opts.cache != nil {
+		// The cached files are gzip members of their own, the rest of the
+		// package follows in another one.
+		if manifest, err = opts.cache.writeFiles(f, fm, gs.PackageSpec.FileAttributes); err != nil {
+			return err
+		}
+	}
 	gw := gzip.NewWriter(f)
 	defer func() {
 		cErr := gw.Close()
@@ -275,11 +522,26 @@ func packageFiles(fm fileMap, gs *goolib.GooSpec, dir string) (err error) {
 		}
 	}()
 
-	if err := writeFiles(tw, fm); err != nil {
+	if opts.cache == nil {
+		if manifest, err = writeFiles(tw, fm, gs.PackageSpec.FileAttributes); err != nil {
+			return err
+		}
+	}
+	if manifest, err = writeSBOM(tw, gs.PackageSpec, manifest, dir, pn, opts); err != nil {
 		return err
 	}
-
-	return goolib.WritePackageSpec(tw, gs.PackageSpec)
+	me, err := goolib.WritePackageManifest(tw, gs.PackageSpec, manifest)
+	if err != nil {
+		return err
+	}
+	manifest = append(manifest, me)
+	if err := goolib.WritePackageSpec(tw, gs.PackageSpec); err != nil {
+		return err
+	}
+	if opts.key == nil {
+		return nil
+	}
+	return goolib.WritePackageSignature(tw, opts.key, gs.PackageSpec, manifest)
 }
 
 func mapFiles(sources []goolib.PkgSources) (fileMap, error) {
@@ -342,7 +604,7 @@ func verifyFiles(gs *goolib.GooSpec, fm fileMap) error {
 	return nil
 }
 
-func createPackage(gs *goolib.GooSpec, baseDir, outDir string) error {
+func createPackage(gs *goolib.GooSpec, baseDir, outDir string, opts packageOptions) error {
 	switch {
 	case gs.Build.Linux != "" && runtime.GOOS == "linux":
 		cmd := gs.Build.Linux
@@ -368,7 +630,7 @@ func createPackage(gs *goolib.GooSpec, baseDir, outDir string) error {
 	if err := verifyFiles(gs, fm); err != nil {
 		return err
 	}
-	return packageFiles(fm, gs, outDir)
+	return packageFiles(fm, gs, outDir, opts)
 }
 
 const (
@@ -401,8 +663,27 @@ func addFlags(args []string) {
 	}
 }
 
+// readVarFile reads a YAML or JSON map of template variables from path.
+func readVarFile(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	varMap := map[string]string{}
+	if err := yaml.Unmarshal(b, &varMap); err != nil {
+		return nil, fmt.Errorf("error parsing variable file %s: %v", path, err)
+	}
+	return varMap, nil
+}
+
 func populateVars() map[string]string {
 	varMap := map[string]string{}
+	if *varFile != "" {
+		var err error
+		if varMap, err = readVarFile(*varFile); err != nil {
+			log.Fatal(err)
+		}
+	}
 	flag.Visit(func(flg *flag.Flag) {
 		if strings.HasPrefix(flg.Name, varFlagPrefix) {
 			varMap[strings.TrimPrefix(flg.Name, varFlagPrefix)] = flg.Value.String()
@@ -412,11 +693,182 @@ func populateVars() map[string]string {
 	return varMap
 }
 
+// archVars returns varMap with the arch variable set to arch.
+func archVars(varMap map[string]string, arch string) map[string]string {
+	vm := map[string]string{"arch": arch}
+	for k, v := range varMap {
+		if k != "arch" {
+			vm[k] = v
+		}
+	}
+	return vm
+}
+
+// lintSpec prints the problems ValidateSpec finds in the goospec file, for
+// each of archs if set, and returns the exit code.
+func lintSpec(file string, varMap map[string]string, archs []string) int {
+	c, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var errs []error
+	if len(archs) == 0 {
+		errs = goolib.ValidateSpec(c, varMap, filepath.Dir(file))
+	}
+	for _, a := range archs {
+		for _, err := range goolib.ValidateSpec(c, archVars(varMap, a), filepath.Dir(file)) {
+			errs = append(errs, fmt.Errorf("%s: %v", a, err))
+		}
+	}
+	for _, err := range errs {
+		fmt.Printf("%s: %v\n", file, err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	fmt.Printf("%s: OK\n", file)
+	return 0
+}
+
+// buildArchs builds the package described by file, and its subpackages, for
+// each of archs, or for the architectures the goospec declares if archs is
+// empty.
+func buildArchs(file string, varMap map[string]string, archs []string, baseDir, outDir string, opts packageOptions) error {
+	if len(archs) == 0 {
+		gs, err := goolib.ReadGooSpec(file, varMap)
+		if err != nil {
+			return err
+		}
+		archs = gs.TargetArchs()
+	}
+	for _, a := range archs {
+		gs, err := goolib.ReadGooSpec(file, archVars(varMap, a))
+		if err != nil {
+			return fmt.Errorf("%s: %v", a, err)
+		}
+		ags, err := gs.ForArch(a)
+		if err != nil {
+			return err
+		}
+		if err := createPackage(ags, baseDir, outDir, opts); err != nil {
+			return fmt.Errorf("%s: %v", a, err)
+		}
+		for _, n := range ags.SubpackageNames() {
+			sgs, err := ags.Subpackage(n)
+			if err != nil {
+				return err
+			}
+			if err := createPackage(sgs, baseDir, outDir, opts); err != nil {
+				return fmt.Errorf("%s: subpackage %s: %v", a, n, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseInterspersed parses the flags in args, which may follow the positional
+// arguments, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var pos []string
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return pos
+		}
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// inspect prints the spec, signature status and files of a package and
+// returns the exit code.
+func inspect(args []string) int {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the package details as JSON")
+	pkgs := parseInterspersed(fs, args)
+	if len(pkgs) != 1 {
+		fmt.Println("inspect takes one package.")
+		usage()
+		return 1
+	}
+	f, err := os.Open(pkgs[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	d, err := goolib.InspectPackage(f)
+	if err != nil {
+		log.Fatalf("Error reading package %s: %v", pkgs[0], err)
+	}
+	if *asJSON {
+		b, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(b))
+		return 0
+	}
+	spec, err := goolib.MarshalPackageSpec(d.Spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s\n\nSigned: %t\nFiles:\n", spec, d.Signed)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, e := range d.Files {
+		fmt.Fprintf(tw, "  %d\t%s\t%s\n", e.Size, e.Checksum, e.Path)
+	}
+	tw.Flush()
+	return 0
+}
+
+// extract extracts a package and returns the exit code.
+func extract(args []string) int {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	dest := fs.String("dest", "", "directory to extract the package to, named after the package in the current directory if empty")
+	pkgs := parseInterspersed(fs, args)
+	if len(pkgs) != 1 {
+		fmt.Println("extract takes one package.")
+		usage()
+		return 1
+	}
+	dir := *dest
+	if dir == "" {
+		dir = strings.TrimSuffix(filepath.Base(pkgs[0]), ".goo")
+	}
+	f, err := os.Open(pkgs[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if err := goolib.ExtractPackage(f, dir); err != nil {
+		log.Fatalf("Error extracting package %s: %v", pkgs[0], err)
+	}
+	fmt.Printf("Extracted %s to %s\n", pkgs[0], dir)
+	return 0
+}
+
 func usage() {
-	fmt.Printf("Usage: %s <path/to/goospec>\n", filepath.Base(os.Args[0]))
+	name := filepath.Base(os.Args[0])
+	fmt.Printf("Usage: %s [flags] <path/to/goospec>\n", name)
+	fmt.Printf("       %s inspect [-json] <path/to/package.goo>\n", name)
+	fmt.Printf("       %s extract [-dest dir] <path/to/package.goo>\n", name)
+	fmt.Printf("       %s convert [-dest dir] [-output_dir dir] <path/to/package.nupkg>\n", name)
+	fmt.Printf("       %s repackage [-format zip|tar] [-output_dir dir] <path/to/package.goo|.zip|.tar>\n", name)
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "inspect":
+			os.Exit(inspect(os.Args[2:]))
+		case "extract":
+			os.Exit(extract(os.Args[2:]))
+		case "convert":
+			os.Exit(convert(os.Args[2:]))
+		case "repackage":
+			os.Exit(repackage(os.Args[2:]))
+		}
+	}
 	addFlags(os.Args[1:])
 	flag.Parse()
 
@@ -436,6 +888,15 @@ func main() {
 		os.Exit(0)
 	}
 
+	var archList []string
+	if *archs != "" {
+		archList = strings.Split(*archs, ",")
+	}
+	varMap := populateVars()
+	if *lint {
+		os.Exit(lintSpec(flag.Arg(0), varMap, archList))
+	}
+
 	outDir := *outputDir
 	if outDir == "" {
 		var err error
@@ -445,12 +906,7 @@ func main() {
 		}
 	}
 
-	varMap := populateVars()
-	gs, err := goolib.ReadGooSpec(flag.Arg(0), varMap)
-	if err != nil {
-		log.Fatal(err)
-	}
-
+	var err error
 	baseDir := filepath.Dir(filepath.Clean(flag.Arg(0)))
 	if baseDir == "." {
 		baseDir, err = os.Getwd()
@@ -458,7 +914,23 @@ func main() {
 			log.Fatal(err)
 		}
 	}
-	if err := createPackage(gs, baseDir, outDir); err != nil {
+	opts := packageOptions{sbom: *sbom, embedSBOM: *embedSBOM}
+	if *signKey != "" {
+		if opts.key, err = goolib.ReadSigningKey(*signKey); err != nil {
+			log.Fatalf("Error reading signing key: %v", err)
+		}
+	}
+	if *cacheDir != "" {
+		if opts.cache, err = openBuildCache(*cacheDir); err != nil {
+			log.Fatalf("Error opening build cache: %v", err)
+		}
+	}
+	if err := buildArchs(flag.Arg(0), varMap, archList, baseDir, outDir, opts); err != nil {
 		log.Fatal(err)
 	}
+	if opts.cache != nil {
+		if err := opts.cache.save(); err != nil {
+			log.Fatalf("Error saving build cache: %v", err)
+		}
+	}
 }
@@ -16,44 +16,22 @@ package main
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/ed25519"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/googet/v2/goolib"
 	"github.com/google/googet/v2/oswrap"
 )
 
-func TestPathMatch(t *testing.T) {
-	tests := []struct {
-		pattern, path string
-		result        bool
-	}{
-		{"/path**.file", "/path/to.file", true},
-		{"/path[a-z]", "/pathb", false},
-		{"/path[a-z]", "/path[a-z]", true},
-		{"path/*/file", "path/to/file", true},
-		{"path/*/file", "path/to/the/file", false},
-		{"path/**/file", "path/to/the/file", true},
-		{"^$[a(-z])%{}}\\{{\\", "^$[a(-z])%{}}\\{{\\", true},
-	}
-
-	for _, test := range tests {
-		res, err := pathMatch(test.pattern, test.path)
-		if err != nil {
-			t.Fatalf("match %q %q: %v", test.pattern, test.path, err)
-		}
-		if res != test.result {
-			t.Fatalf("match %q %q: expected %v got %v", test.pattern, test.path, test.result, res)
-		}
-	}
-}
-
 func TestMergeWalks(t *testing.T) {
 	before := []pathWalk{
 		{[][]string{{"path", "to", "file"}}, -1},
@@ -151,7 +129,7 @@ func TestWriteFiles(t *testing.T) {
 
 	buf := new(bytes.Buffer)
 	tw := tar.NewWriter(buf)
-	if err := writeFiles(tw, fm); err != nil {
+	if _, err := writeFiles(tw, fm, map[string]goolib.FileAttributes{ef: {Mode: "0600", Owner: "root:adm"}}); err != nil {
 		t.Errorf("error writing files to zip: %v", err)
 	}
 	if err := tw.Close(); err != nil {
@@ -165,6 +143,144 @@ func TestWriteFiles(t *testing.T) {
 	if hdr.Name != ef {
 		t.Errorf("zip contains unexpected file: expect %q got %q", ef, f.Name())
 	}
+	if hdr.Mode != 0600 || hdr.Uname != "root" || hdr.Gname != "adm" {
+		t.Errorf("file header has mode %o, owner %s:%s, want 600, root:adm", hdr.Mode, hdr.Uname, hdr.Gname)
+	}
+}
+
+// sizedFile is the FileInfo of a regular file of any size.
+type sizedFile struct {
+	os.FileInfo
+	size int64
+}
+
+func (f sizedFile) Size() int64 { return f.size }
+
+func TestFileHeaderPAX(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+	file := filepath.Join(tempDir, "large.bin")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := oswrap.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither fits in a USTAR header.
+	const size = 9 << 30
+	folder := strings.Repeat(strings.Repeat("d", 50)+"/", 6)
+	fih, err := fileHeader(sizedFile{fi, size}, folder, file, nil)
+	if err != nil {
+		t.Fatalf("fileHeader: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	if err := tar.NewWriter(buf).WriteHeader(fih); err != nil {
+		t.Fatalf("error writing header: %v", err)
+	}
+	hdr, err := tar.NewReader(buf).Next()
+	if err != nil {
+		t.Fatalf("error reading header: %v", err)
+	}
+	if want := path.Join(folder, "large.bin"); hdr.Name != want || hdr.Size != size || hdr.Format != tar.FormatPAX {
+		t.Errorf("read header %q of size %d in format %v, want %q of size %d in PAX format", hdr.Name, hdr.Size, hdr.Format, want, int64(size))
+	}
+}
+
+func TestBuildCache(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+	src := filepath.Join(tempDir, "src")
+	if err := oswrap.Mkdir(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	a, b := filepath.Join(src, "a.txt"), filepath.Join(src, "b.bin")
+	if err := ioutil.WriteFile(a, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, bytes.Repeat([]byte{1}, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fm := fileMap{"foo": []string{a, b}}
+	gs := &goolib.GooSpec{PackageSpec: &goolib.PkgSpec{Name: "test", Version: "1.0.0@1", Arch: "noarch"}}
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := openBuildCache(filepath.Join(tempDir, "cache"))
+	if err != nil {
+		t.Fatalf("openBuildCache: %v", err)
+	}
+
+	// build packages fm into a new directory and returns the package files.
+	build := func(opts packageOptions) []goolib.ManifestEntry {
+		out, err := ioutil.TempDir(tempDir, "out")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := packageFiles(fm, gs, out, opts); err != nil {
+			t.Fatalf("packageFiles: %v", err)
+		}
+		f, err := oswrap.Open(filepath.Join(out, "test.noarch.1.0.0@1.goo"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		pd, err := goolib.InspectPackage(f)
+		if err != nil {
+			t.Fatalf("InspectPackage: %v", err)
+		}
+		f.Seek(0, 0)
+		if _, err := goolib.VerifyPackage(f, pub); err != nil {
+			t.Errorf("VerifyPackage: %v", err)
+		}
+		return pd.Files
+	}
+	segments := func() int {
+		m, err := filepath.Glob(filepath.Join(cache.dir, "*.gz"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(m)
+	}
+
+	want := build(packageOptions{key: key})
+	for i := 0; i < 2; i++ {
+		if got := build(packageOptions{key: key, cache: cache}); !reflect.DeepEqual(got, want) {
+			t.Errorf("build %d with cache has files %+v, want %+v", i, got, want)
+		}
+		if n := segments(); n != 2 {
+			t.Errorf("build %d left %d files in the cache, want 2", i, n)
+		}
+	}
+	if err := cache.save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if cache, err = openBuildCache(cache.dir); err != nil || len(cache.index) != 2 {
+		t.Fatalf("openBuildCache of saved cache returned %d index entries, %v; want 2, nil", len(cache.index), err)
+	}
+
+	if err := ioutil.WriteFile(a, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mt := time.Now().Add(time.Hour)
+	if err := os.Chtimes(a, mt, mt); err != nil {
+		t.Fatal(err)
+	}
+	got := build(packageOptions{key: key, cache: cache})
+	if sum := goolib.Checksum(strings.NewReader("changed")); got[0].Checksum != sum {
+		t.Errorf("build after change has %+v, want checksum %s", got[0], sum)
+	}
+	if n := segments(); n != 3 {
+		t.Errorf("build after change left %d files in the cache, want 3", n)
+	}
 }
 
 func TestPopulateVars(t *testing.T) {
@@ -179,6 +295,38 @@ func TestPopulateVars(t *testing.T) {
 	}
 }
 
+func TestReadVarFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, c := range []string{"version: 1.2.3\nbuild: 4\nsigned: true\n", `{"version": "1.2.3", "build": 4, "signed": true}`} {
+		f := filepath.Join(tempDir, "vars")
+		if err := ioutil.WriteFile(f, []byte(c), 0600); err != nil {
+			t.Fatalf("error writing variable file: %v", err)
+		}
+		got, err := readVarFile(f)
+		if err != nil {
+			t.Fatalf("readVarFile(%q): %v", c, err)
+		}
+		want := map[string]string{"version": "1.2.3", "build": "4", "signed": "true"}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("readVarFile(%q) = %q, want %q", c, got, want)
+		}
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("extract", flag.ContinueOnError)
+	dest := fs.String("dest", "", "")
+	got := parseInterspersed(fs, []string{"foo.goo", "-dest", "out", "bar.goo"})
+	if want := []string{"foo.goo", "bar.goo"}; !reflect.DeepEqual(got, want) || *dest != "out" {
+		t.Errorf("parseInterspersed returned %q and -dest %q, want %q and out", got, *dest, want)
+	}
+}
+
 func TestAddFlags(t *testing.T) {
 	firstFlag := "var:first_var"
 	secondFlag := "var:second_var"
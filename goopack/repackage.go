@@ -0,0 +1,260 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The repackage mode converts a package to a plain zip or uncompressed tar
+// and back. Every entry, the package spec, manifest and signature among
+// them, is copied unchanged, so a package converted back verifies as the
+// original did.
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+)
+
+// Formats a package can be repackaged to.
+const (
+	formatGoo = "goo"
+	formatZip = "zip"
+	formatTar = "tar"
+)
+
+// repackFormat returns the format of the file name, empty if it is not one
+// of the repackage formats.
+func repackFormat(name string) string {
+	for _, f := range []string{formatTar, formatZip, formatGoo} {
+		if strings.HasSuffix(name, "."+f) {
+			return f
+		}
+	}
+	return ""
+}
+
+// gooToTar copies the tar archive of the package read from r to w.
+func gooToTar(r io.Reader, w io.Writer) error {
+	tr, err := goolib.NewPackageReader(r)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, tr)
+	return err
+}
+
+// gooToZip writes the entries of the package read from r to w as a zip.
+func gooToZip(r io.Reader, w io.Writer) error {
+	pr, err := goolib.NewPackageReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(pr)
+	zw := zip.NewWriter(w)
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		zh, err := zip.FileInfoHeader(th.FileInfo())
+		if err != nil {
+			return err
+		}
+		zh.Name = th.Name
+		zh.Modified = th.ModTime
+		if th.Typeflag == tar.TypeDir {
+			zh.Name = strings.TrimSuffix(zh.Name, "/") + "/"
+		} else {
+			zh.Method = zip.Deflate
+		}
+		f, err := zw.CreateHeader(zh)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// zipSpec returns the package spec in zr.
+func zipSpec(zr *zip.Reader) (*goolib.PkgSpec, error) {
+	for _, f := range zr.File {
+		if filepath.Ext(f.Name) != ".pkgspec" || strings.Contains(f.Name, "/") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		return goolib.UnmarshalPackageSpec(b)
+	}
+	return nil, errors.New("no package spec found in zip")
+}
+
+// zipToGoo writes the entries of the zip zr to w as a package, with the
+// owners of the FileAttributes of its spec, which zip does not record.
+func zipToGoo(zr *zip.Reader, w io.Writer) error {
+	spec, err := zipSpec(zr)
+	if err != nil {
+		return err
+	}
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, f := range zr.File {
+		th, err := tar.FileInfoHeader(f.FileInfo(), "")
+		if err != nil {
+			return err
+		}
+		th.Name = f.Name
+		th.Format = tar.FormatPAX
+		th.ModTime = f.Modified.Truncate(time.Second)
+		if a, ok := spec.FileAttributes[th.Name]; ok && a.Owner != "" {
+			th.Uname, th.Gname = a.SplitOwner()
+		}
+		if err := tw.WriteHeader(th); err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// tarToGoo writes the tar archive read from r to w as a package.
+func tarToGoo(r io.Reader, w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	if _, err := io.Copy(gw, r); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// repackageFile converts the package or repackaged package src to format,
+// writing it to outDir, and returns the path written. Repackaged packages
+// are always converted back to packages.
+func repackageFile(src, format, outDir string) (dst string, err error) {
+	from := repackFormat(src)
+	if from == "" {
+		return "", fmt.Errorf("%s is not a .goo, .zip or .tar file", src)
+	}
+	if from != formatGoo {
+		format = formatGoo
+	} else if format != formatZip && format != formatTar {
+		return "", fmt.Errorf("can't repackage to %q, use zip or tar", format)
+	}
+	dst = filepath.Join(outDir, strings.TrimSuffix(filepath.Base(src), from)+format)
+
+	in, err := oswrap.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	out, err := oswrap.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if cErr := out.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+		if err != nil {
+			oswrap.Remove(dst)
+		}
+	}()
+
+	switch {
+	case format == formatZip:
+		return dst, gooToZip(in, out)
+	case format == formatTar:
+		return dst, gooToTar(in, out)
+	case from == formatZip:
+		fi, err := in.Stat()
+		if err != nil {
+			return "", err
+		}
+		zr, err := zip.NewReader(in, fi.Size())
+		if err != nil {
+			return "", err
+		}
+		return dst, zipToGoo(zr, out)
+	}
+	if err := tarToGoo(in, out); err != nil {
+		return "", err
+	}
+	// The tar archive was copied as is, check that it is a package.
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if _, err := goolib.ExtractPkgSpec(out); err != nil {
+		return "", fmt.Errorf("%s is not a repackaged package: %v", src, err)
+	}
+	return dst, nil
+}
+
+// repackage converts a package to zip or tar, or back, and returns the
+// exit code.
+func repackage(args []string) int {
+	fs := flag.NewFlagSet("repackage", flag.ExitOnError)
+	format := fs.String("format", formatZip, "format to repackage a .goo to, zip or tar")
+	out := fs.String("output_dir", "", "where to put the converted file, the current directory if empty")
+	pkgs := parseInterspersed(fs, args)
+	if len(pkgs) != 1 {
+		fmt.Println("repackage takes one package.")
+		usage()
+		return 1
+	}
+	outDir := *out
+	if outDir == "" {
+		outDir = "."
+	}
+	dst, err := repackageFile(pkgs[0], *format, outDir)
+	if err != nil {
+		log.Fatalf("Error repackaging %s: %v", pkgs[0], err)
+	}
+	fmt.Printf("Repackaged %s to %s\n", pkgs[0], dst)
+	return 0
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/google/googet/v2/googettest"
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestRepackageFile(t *testing.T) {
+	spec := &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}
+	p, err := googettest.GenGoo(spec, map[string][]byte{"bin/foo": []byte("foo"), "README": []byte("read me")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{formatZip, formatTar} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, p.Name())
+			if err := os.WriteFile(src, p.Data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			out := filepath.Join(dir, "out")
+			if err := os.Mkdir(out, 0755); err != nil {
+				t.Fatal(err)
+			}
+			rp, err := repackageFile(src, format, out)
+			if err != nil {
+				t.Fatalf("repackageFile(%s, %s): %v", src, format, err)
+			}
+			if want := filepath.Join(out, "foo.noarch.1.0.0@1."+format); rp != want {
+				t.Errorf("repackageFile(%s, %s) = %s, want %s", src, format, rp, want)
+			}
+			back, err := repackageFile(rp, format, out)
+			if err != nil {
+				t.Fatalf("repackageFile(%s): %v", rp, err)
+			}
+			if want := filepath.Join(out, p.Name()); back != want {
+				t.Errorf("repackageFile(%s) = %s, want %s", rp, back, want)
+			}
+
+			want := inspectFile(t, src)
+			got := inspectFile(t, back)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("repackaged package = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func inspectFile(t *testing.T, path string) *goolib.PackageDetails {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	d, err := goolib.InspectPackage(f)
+	if err != nil {
+		t.Fatalf("InspectPackage(%s): %v", path, err)
+	}
+	return d
+}
+
+func TestRepackageFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		src, format string
+	}{
+		{"foo.tar.gz", formatZip},
+		{"foo.noarch.1.0.0@1.goo", "rar"},
+	} {
+		if _, err := repackageFile(filepath.Join(dir, tc.src), tc.format, dir); err == nil {
+			t.Errorf("repackageFile(%s, %s) did not return an error", tc.src, tc.format)
+		}
+	}
+}
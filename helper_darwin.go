@@ -0,0 +1,115 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"text/template"
+
+	"golang.org/x/sys/unix"
+)
+
+// shareSocket lets any user connect to the unix domain socket path.
+func shareSocket(path string) error {
+	return os.Chmod(path, 0666)
+}
+
+func peerIDs(c net.Conn) (string, []string, error) {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return "", nil, errors.New("not a unix domain socket")
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return "", nil, err
+	}
+	var cred *unix.Xucred
+	var cerr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, cerr = unix.GetsockoptXucred(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+	}); err != nil {
+		return "", nil, err
+	}
+	if cerr != nil {
+		return "", nil, cerr
+	}
+	uid := strconv.Itoa(int(cred.Uid))
+	var gids []string
+	for _, g := range cred.Groups[:cred.Ngroups] {
+		gids = append(gids, strconv.Itoa(int(g)))
+	}
+	if u, err := user.LookupId(uid); err == nil {
+		if g, err := u.GroupIds(); err == nil {
+			gids = append(gids, g...)
+		}
+	}
+	return uid, gids, nil
+}
+
+var helperPlist = template.Must(template.New("plist").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{.Label | html}}</string>
+	<key>ProgramArguments</key>
+	<array>
+{{- range .Args}}
+		<string>{{. | html}}</string>
+{{- end}}
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+</dict>
+</plist>
+`))
+
+// installHelperService writes a launchd daemon running the helper with args
+// to /Library/LaunchDaemons and loads it, replacing a loaded one.
+func installHelperService(args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	p := filepath.Join("/Library/LaunchDaemons", helperLabel+".plist")
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	err = helperPlist.Execute(f, struct {
+		Label string
+		Args  []string
+	}{helperLabel, append([]string{exe, "-root", rootDir, "helper"}, args...)})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing %s: %v", p, err)
+	}
+	// Unloading fails if the daemon is not loaded yet.
+	exec.Command("launchctl", "bootout", "system/"+helperLabel).Run()
+	if out, err := exec.Command("launchctl", "bootstrap", "system", p).CombinedOutput(); err != nil {
+		return fmt.Errorf("error loading %s: %v: %s", p, err, out)
+	}
+	return nil
+}
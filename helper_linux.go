@@ -0,0 +1,64 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// peerIDs returns the user ID and group IDs of the process on the other end
+// of the unix domain socket c.
+func peerIDs(c net.Conn) (string, []string, error) {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return "", nil, errors.New("not a unix domain socket")
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return "", nil, err
+	}
+	var cred *syscall.Ucred
+	var cerr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, cerr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return "", nil, err
+	}
+	if cerr != nil {
+		return "", nil, cerr
+	}
+	uid := strconv.Itoa(int(cred.Uid))
+	gids := []string{strconv.Itoa(int(cred.Gid))}
+	if u, err := user.LookupId(uid); err == nil {
+		if g, err := u.GroupIds(); err == nil {
+			gids = append(gids, g...)
+		}
+	}
+	return uid, gids, nil
+}
+
+// shareSocket lets any user connect to the unix domain socket path.
+func shareSocket(path string) error {
+	return os.Chmod(path, 0666)
+}
+
+// installHelperService is only supported on macOS.
+func installHelperService(args []string) error {
+	return errors.New("installing the helper service is only supported on macOS")
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// sioAFUnixGetPeerPID is the SIO_AF_UNIX_GETPEERPID ioctl, returning the
+// process ID of the other end of an AF_UNIX socket.
+const sioAFUnixGetPeerPID = 0x58000100
+
+// socketSDDL allows SYSTEM and administrators full access to the helper
+// sockets, and authenticated users to connect.
+const socketSDDL = "D:P(A;;FA;;;SY)(A;;FA;;;BA)(A;;FRFW;;;AU)"
+
+// peerIDs returns the user SID and the SIDs of the enabled groups of the
+// process on the other end of the unix domain socket c.
+func peerIDs(c net.Conn) (string, []string, error) {
+	uc, ok := c.(*net.UnixConn)
+	if !ok {
+		return "", nil, errors.New("not a unix domain socket")
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return "", nil, err
+	}
+	var pid uint32
+	var cerr error
+	if err := raw.Control(func(fd uintptr) {
+		var n uint32
+		cerr = windows.WSAIoctl(windows.Handle(fd), sioAFUnixGetPeerPID, nil, 0, (*byte)(unsafe.Pointer(&pid)), uint32(unsafe.Sizeof(pid)), &n, nil, 0)
+	}); err != nil {
+		return "", nil, err
+	}
+	if cerr != nil {
+		return "", nil, fmt.Errorf("error getting the caller process: %v", cerr)
+	}
+	p, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return "", nil, fmt.Errorf("error opening caller process %d: %v", pid, err)
+	}
+	defer windows.CloseHandle(p)
+	var t windows.Token
+	if err := windows.OpenProcessToken(p, windows.TOKEN_QUERY, &t); err != nil {
+		return "", nil, fmt.Errorf("error opening the token of caller process %d: %v", pid, err)
+	}
+	defer t.Close()
+	tu, err := t.GetTokenUser()
+	if err != nil {
+		return "", nil, err
+	}
+	tg, err := t.GetTokenGroups()
+	if err != nil {
+		return "", nil, err
+	}
+	// Groups of a filtered administrator token are deny only, not enabled.
+	var gids []string
+	for _, g := range tg.AllGroups() {
+		if g.Attributes&windows.SE_GROUP_ENABLED != 0 {
+			gids = append(gids, g.Sid.String())
+		}
+	}
+	return tu.User.Sid.String(), gids, nil
+}
+
+// shareSocket lets authenticated users connect to the unix domain socket
+// path, and only SYSTEM and administrators replace it.
+func shareSocket(path string) error {
+	sd, err := windows.SecurityDescriptorFromString(socketSDDL)
+	if err != nil {
+		return err
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return err
+	}
+	return windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION, nil, nil, dacl, nil)
+}
+
+// installHelperService is only supported on macOS.
+func installHelperService(args []string) error {
+	return errors.New("installing the helper service is only supported on macOS")
+}
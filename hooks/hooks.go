@@ -0,0 +1,163 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package hooks runs organization provided hooks at points of a GooGet
+// transaction, which can enforce policies by denying the operation.
+//
+// A hook is a command, which gets a Request as JSON on its stdin and can
+// write a Response as JSON on its stdout, or a Go function registered by a
+// program embedding GooGet.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/logger"
+)
+
+// Hook points.
+const (
+	// PreResolve runs before the dependencies of a package to install from a
+	// repo are resolved.
+	PreResolve = "preresolve"
+	// PreDownload runs before a package is downloaded from a repo.
+	PreDownload = "predownload"
+	// PreInstall runs before the files of a package are installed.
+	PreInstall = "preinstall"
+	// PostInstall runs after a package is installed, it can't deny it.
+	PostInstall = "postinstall"
+	// PreRemove runs before a package is removed.
+	PreRemove = "preremove"
+)
+
+// Points are the hook points.
+var Points = []string{PreResolve, PreDownload, PreInstall, PostInstall, PreRemove}
+
+// Request describes the operation a hook runs for.
+type Request struct {
+	Hook    string
+	Package string
+	Repo    string `json:",omitempty"`
+	// URL is the URL the package is downloaded from.
+	URL string `json:",omitempty"`
+	// Path is the path of the downloaded package.
+	Path string          `json:",omitempty"`
+	Spec *goolib.PkgSpec `json:",omitempty"`
+}
+
+// Response is the answer of a hook, denying the operation unless Allow is
+// set. A hook command exiting with 0 without writing anything allows it.
+type Response struct {
+	Allow   bool
+	Message string `json:",omitempty"`
+}
+
+// Func is a hook registered by a Go program.
+type Func func(context.Context, Request) Response
+
+var (
+	mu    sync.Mutex
+	funcs = make(map[string][]Func)
+)
+
+// Register runs f at the hook point hook, before the hook commands.
+func Register(hook string, f Func) {
+	mu.Lock()
+	defer mu.Unlock()
+	funcs[hook] = append(funcs[hook], f)
+}
+
+// Commands maps hook points to the commands run there, in order.
+var Commands map[string][]string
+
+// Timeout limits the run time of each hook command, 0 means no limit.
+var Timeout time.Duration
+
+// Run runs the hooks of r.Hook and returns an error if one of them denies
+// the operation, the remaining hooks are not run. A hook command that fails
+// or writes an invalid response denies it.
+func Run(ctx context.Context, r Request) error {
+	mu.Lock()
+	fs := append([]Func(nil), funcs[r.Hook]...)
+	mu.Unlock()
+	for _, f := range fs {
+		if res := f(ctx, r); !res.Allow {
+			return denied(r, "", res.Message)
+		}
+	}
+	for _, c := range Commands[r.Hook] {
+		res, err := runCommand(ctx, c, r)
+		if err != nil {
+			return fmt.Errorf("%s hook %s for %s: %v", r.Hook, c, r.Package, err)
+		}
+		if !res.Allow {
+			return denied(r, c, res.Message)
+		}
+	}
+	return nil
+}
+
+func denied(r Request, c, msg string) error {
+	by := "a " + r.Hook + " hook"
+	if c != "" {
+		by = fmt.Sprintf("%s hook %s", r.Hook, c)
+	}
+	if msg == "" {
+		return fmt.Errorf("%s denied by %s", r.Package, by)
+	}
+	return fmt.Errorf("%s denied by %s: %s", r.Package, by, msg)
+}
+
+func runCommand(ctx context.Context, path string, r Request) (Response, error) {
+	if Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, Timeout)
+		defer cancel()
+	}
+	in, err := json.Marshal(r)
+	if err != nil {
+		return Response{}, err
+	}
+	var stdout, stderr bytes.Buffer
+	c := exec.CommandContext(ctx, path)
+	c.Stdin = bytes.NewReader(in)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	logger.Infof("Running %s hook %q for %s", r.Hook, path, r.Package)
+	err = c.Run()
+	if ctx.Err() != nil {
+		return Response{}, fmt.Errorf("did not finish: %v", ctx.Err())
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return Response{}, fmt.Errorf("%v: %s", err, msg)
+		}
+		return Response{}, err
+	}
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return Response{Allow: true}, nil
+	}
+	var res Response
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return Response{}, fmt.Errorf("invalid response: %v", err)
+	}
+	return res, nil
+}
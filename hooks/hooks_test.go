@@ -0,0 +1,94 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hooks
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunFuncs(t *testing.T) {
+	defer func() { funcs = make(map[string][]Func) }()
+	var got []Request
+	Register(PreInstall, func(_ context.Context, r Request) Response {
+		got = append(got, r)
+		return Response{Allow: r.Package != "bad.noarch.1"}
+	})
+
+	if err := Run(context.Background(), Request{Hook: PreRemove, Package: "bad.noarch.1"}); err != nil {
+		t.Errorf("Run of a point without hooks: %v", err)
+	}
+	if err := Run(context.Background(), Request{Hook: PreInstall, Package: "good.noarch.1"}); err != nil {
+		t.Errorf("Run of an allowed package: %v", err)
+	}
+	if err := Run(context.Background(), Request{Hook: PreInstall, Package: "bad.noarch.1"}); err == nil {
+		t.Error("Run of a denied package succeeded")
+	}
+	if len(got) != 2 || got[0].Package != "good.noarch.1" {
+		t.Errorf("hook got requests %+v, want good.noarch.1 and bad.noarch.1", got)
+	}
+}
+
+func TestRunCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test hooks are shell scripts")
+	}
+	defer func() { Commands, Timeout = nil, 0 }()
+	dir := t.TempDir()
+	hook := func(name, script string) string {
+		p := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(p, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	table := []struct {
+		script  string
+		timeout time.Duration
+		wantErr string
+	}{
+		{"cat >/dev/null", 0, ""},
+		{`grep -q '"Package":"foo.noarch.1"' && echo '{"Allow": true}'`, 0, ""},
+		{`echo '{"Allow": false, "Message": "no change ticket"}'`, 0, "no change ticket"},
+		{"echo 'not json'", 0, "invalid response"},
+		{"echo oops >&2; exit 1", 0, "oops"},
+		{"exec sleep 5", 10 * time.Millisecond, "did not finish"},
+	}
+	for i, tt := range table {
+		Commands = map[string][]string{PreInstall: {hook(strings.Repeat("h", i+1), tt.script)}}
+		Timeout = tt.timeout
+		err := Run(context.Background(), Request{Hook: PreInstall, Package: "foo.noarch.1"})
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%d: Run returned error %v", i, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%d: Run returned error %v, want error containing %q", i, err, tt.wantErr)
+		}
+	}
+
+	// A denying hook stops the remaining ones.
+	Commands = map[string][]string{PreRemove: {hook("deny", "echo '{}'"), hook("marker", "touch "+filepath.Join(dir, "ran"))}}
+	if err := Run(context.Background(), Request{Hook: PreRemove, Package: "foo.noarch.1"}); err == nil {
+		t.Error("Run with a denying hook succeeded")
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(dir, "ran")); err == nil {
+		t.Error("hook after a denying hook ran")
+	}
+}
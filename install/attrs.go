@@ -0,0 +1,98 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/logger"
+)
+
+// attributePaths maps the FileAttributes of ps, keyed by path in the package,
+// to the paths the files of the package extracted to dir are installed at.
+func attributePaths(ps *goolib.PkgSpec, dir string) map[string]goolib.FileAttributes {
+	out := make(map[string]goolib.FileAttributes)
+	for p, a := range ps.FileAttributes {
+		p = filepath.Join(dir, p)
+		for src, dst := range ps.Files {
+			src = filepath.Join(dir, src)
+			if p == src || strings.HasPrefix(p, src+string(filepath.Separator)) {
+				out[filepath.Join(resolveDst(dst), strings.TrimPrefix(p, src))] = a
+			}
+		}
+	}
+	return out
+}
+
+// applyAttributes sets the attributes a on the installed file or directory
+// path.
+func applyAttributes(path string, a goolib.FileAttributes, perms Permissions) error {
+	if runtime.GOOS == "windows" {
+		return setWindowsAttributes(path, a.ReadOnly, a.Hidden)
+	}
+	if a.Mode != "" {
+		m, err := goolib.ParseMode(a.Mode)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Setting mode of %q to %v", path, m&^perms.Umask)
+		if err := os.Chmod(path, m&^perms.Umask); err != nil {
+			return err
+		}
+	}
+	if a.Owner != "" && runtime.GOOS == "linux" {
+		uid, gid, err := lookupOwner(a)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Setting owner of %q to %s", path, a.Owner)
+		if err := os.Lchown(path, uid, gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupOwner returns the user and group IDs of the Owner of a, the group ID
+// is -1 if no group is given.
+func lookupOwner(a goolib.FileAttributes) (int, int, error) {
+	u, g := a.SplitOwner()
+	uid := u
+	if lu, err := user.Lookup(u); err == nil {
+		uid = lu.Uid
+	}
+	uidN, err := strconv.Atoi(uid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unknown user %q", u)
+	}
+	if g == "" {
+		return uidN, -1, nil
+	}
+	gid := g
+	if lg, err := user.LookupGroup(g); err == nil {
+		gid = lg.Gid
+	}
+	gidN, err := strconv.Atoi(gid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unknown group %q", g)
+	}
+	return uidN, gidN, nil
+}
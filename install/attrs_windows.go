@@ -0,0 +1,52 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"github.com/google/logger"
+	"golang.org/x/sys/windows"
+)
+
+// setWindowsAttributes sets the read-only and hidden attributes of path if
+// requested.
+func setWindowsAttributes(path string, readOnly, hidden bool) error {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	attrs, err := windows.GetFileAttributes(p)
+	if err != nil {
+		return err
+	}
+	old := attrs
+	for _, f := range []struct {
+		set  bool
+		attr uint32
+	}{
+		{readOnly, windows.FILE_ATTRIBUTE_READONLY},
+		{hidden, windows.FILE_ATTRIBUTE_HIDDEN},
+	} {
+		if f.set {
+			attrs |= f.attr
+		}
+	}
+	if attrs == old {
+		return nil
+	}
+	logger.Infof("Setting attributes of %q to %#x", path, attrs)
+	return windows.SetFileAttributes(p, attrs)
+}
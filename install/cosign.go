@@ -0,0 +1,146 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/download"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/logger"
+)
+
+// CosignKey is a public key cosign bundles may be signed with, Name
+// identifies it in errors.
+type CosignKey struct {
+	Name string
+	Key  crypto.PublicKey
+}
+
+// Cosign is how the cosign bundles published next to packages are verified
+// and what to do if they are missing or do not verify. Bundles with a
+// certificate are keyless and must be issued to Identity by Issuer, the
+// others must be signed with one of Keys.
+type Cosign struct {
+	Policy           SignaturePolicy
+	Keys             []CosignKey
+	Identity, Issuer string
+}
+
+// DefaultCosign applies to local packages and to the repos not in
+// RepoCosign.
+var DefaultCosign Cosign
+
+// RepoCosign maps repo URLs to the cosign settings of their packages, which
+// replace DefaultCosign.
+var RepoCosign map[string]Cosign
+
+// CosignTrust holds the Fulcio and Rekor keys keyless bundles are verified
+// with.
+var CosignTrust goolib.CosignTrust
+
+// checkCosign verifies the cosign bundle of the package pkg with spec ps,
+// downloaded from pkgURL in repo, and returns an error if the policy of the
+// repo blocks it. repo and pkgURL are empty for local files, whose bundle is
+// next to them.
+func checkCosign(ctx context.Context, pkg string, ps *goolib.PkgSpec, pkgURL, repo string, downloader client.Downloader) error {
+	v := verification(ctx)
+	c, ok := v.RepoCosign[repo]
+	if !ok || repo == "" {
+		c = v.Cosign
+	}
+	if c.Policy == SignatureOff {
+		return nil
+	}
+	if pkgURL == "" {
+		pkgURL = pkg
+	}
+	if repo != "" {
+		ctx = client.WithRepo(ctx, repo)
+	}
+	how, err := verifyCosign(ctx, pkg, ps, pkgURL, c, downloader)
+	if err == nil {
+		logger.Infof("Cosign bundle of %s verified with %s", ps, how)
+		return nil
+	}
+	if c.Policy == SignatureWarn {
+		logger.Warningf("%v, installing it anyway", err)
+		return nil
+	}
+	return &goolib.VerificationError{Err: fmt.Errorf("%v, not installing it", err)}
+}
+
+// verifyCosign returns the key or identity the cosign bundle of pkg verifies
+// with.
+func verifyCosign(ctx context.Context, pkg string, ps *goolib.PkgSpec, pkgURL string, c Cosign, downloader client.Downloader) (string, error) {
+	b, err := download.Sidecar(ctx, pkgURL, goolib.CosignBundleSuffix, downloader)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%s has no cosign bundle", ps)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching the cosign bundle of %s: %v", ps, err)
+	}
+	cb, err := goolib.ParseCosignBundle(b)
+	if err != nil {
+		return "", fmt.Errorf("cosign bundle of %s: %v", ps, err)
+	}
+	digest, err := fileDigest(pkg)
+	if err != nil {
+		return "", err
+	}
+
+	if cb.Cert != "" {
+		if c.Identity == "" || c.Issuer == "" {
+			return "", fmt.Errorf("keyless cosign bundle of %s can't be verified, no identity and issuer are configured", ps)
+		}
+		if err := cb.VerifyKeyless(digest, c.Identity, c.Issuer, CosignTrust); err != nil {
+			return "", fmt.Errorf("keyless cosign bundle of %s does not verify: %v", ps, err)
+		}
+		return fmt.Sprintf("identity %s from %s", c.Identity, c.Issuer), nil
+	}
+	if len(c.Keys) == 0 {
+		return "", fmt.Errorf("cosign bundle of %s can't be verified, no cosign keys are configured", ps)
+	}
+	var errs []string
+	for _, k := range c.Keys {
+		err := cb.VerifyKey(digest, k.Key)
+		if err == nil {
+			return "key " + k.Name, nil
+		}
+		errs = append(errs, fmt.Sprintf("key %s: %v", k.Name, err))
+	}
+	return "", fmt.Errorf("cosign bundle of %s does not verify, %s", ps, strings.Join(errs, "; "))
+}
+
+// fileDigest returns the SHA256 digest of the file at path.
+func fileDigest(path string) ([]byte, error) {
+	f, err := oswrap.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
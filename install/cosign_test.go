@@ -0,0 +1,112 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestCheckCosign(t *testing.T) {
+	defer func() { DefaultCosign, RepoCosign = Cosign{}, nil }()
+	tempDir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := &goolib.PkgSpec{Name: "foo", Version: "1.0.0@1", Arch: "noarch"}
+	signed := filepath.Join(tempDir, "signed.goo")
+	writeTestPackage(t, signed, spec, nil)
+	digest, err := fileDigest(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := json.Marshal(goolib.CosignBundle{Base64Signature: base64.StdEncoding.EncodeToString(sig)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(signed+goolib.CosignBundleSuffix, bundle, 0644); err != nil {
+		t.Fatal(err)
+	}
+	unsigned := filepath.Join(tempDir, "unsigned.goo")
+	writeTestPackage(t, unsigned, spec, nil)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/packages/foo.goo"+goolib.CosignBundleSuffix {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(bundle)
+	}))
+	defer ts.Close()
+
+	repo := ts.URL + "/stable"
+	DefaultCosign = Cosign{Policy: SignatureEnforce, Keys: []CosignKey{{"cosign.pub", key.Public()}}}
+	RepoCosign = map[string]Cosign{
+		repo:                                  {Policy: SignatureEnforce, Keys: []CosignKey{{"other.pub", other.Public()}}},
+		"https://repo.example.com/googet/lab": {Policy: SignatureWarn},
+	}
+
+	for _, tt := range []struct {
+		desc, pkg, pkgURL, repo string
+		// wantErr is a substring of the error, empty if none is wanted.
+		wantErr string
+	}{
+		{"local bundle", signed, "", "", ""},
+		{"no local bundle", unsigned, "", "", "has no cosign bundle"},
+		{"bundle from the repo", signed, ts.URL + "/packages/foo.goo", "", ""},
+		{"no bundle in the repo", signed, ts.URL + "/packages/bar.goo", "", "has no cosign bundle"},
+		{"repo with another key", signed, ts.URL + "/packages/foo.goo", repo, "key other.pub"},
+		{"warn policy", unsigned, "", "https://repo.example.com/googet/lab", ""},
+	} {
+		err := checkCosign(context.Background(), tt.pkg, spec, tt.pkgURL, tt.repo, client.HTTPDownloader{})
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: checkCosign returned %v, want nil", tt.desc, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: checkCosign returned %v, want an error containing %q", tt.desc, err, tt.wantErr)
+		}
+	}
+
+	DefaultCosign = Cosign{Policy: SignatureEnforce, Identity: "dev@example.com"}
+	if err := checkCosign(context.Background(), signed, spec, "", "", nil); err == nil || !strings.Contains(err.Error(), "no cosign keys") {
+		t.Errorf("checkCosign without keys returned %v, want an error about missing keys", err)
+	}
+	DefaultCosign = Cosign{}
+	if err := checkCosign(context.Background(), unsigned, spec, "", "", nil); err != nil {
+		t.Errorf("checkCosign with the off policy returned %v", err)
+	}
+}
@@ -24,151 +24,356 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/googet/v2/client"
 	"github.com/google/googet/v2/download"
+	"github.com/google/googet/v2/events"
 	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/hooks"
 	"github.com/google/googet/v2/oswrap"
 	"github.com/google/googet/v2/remove"
 	"github.com/google/googet/v2/system"
+	"github.com/google/googet/v2/telemetry"
 	"github.com/google/logger"
 )
 
 var toRemove []string
 
-// minInstalled reports whether the package is installed at the given version or greater.
+// MSI operations, replaced in tests.
+var (
+	installMSI   = system.InstallMSI
+	uninstallMSI = system.UninstallMSI
+)
+
+// StopServices makes installs stop the services using files they replace and
+// start them again afterwards, instead of replacing the files on reboot.
+var StopServices bool
+
+// MaxDownloads is the number of packages Prefetch downloads in parallel.
+var MaxDownloads = 1
+
+// minInstalled reports whether the package is installed at a version
+// satisfying the version constraint pi.Ver, a plain version being the
+// minimum version.
 func minInstalled(pi goolib.PackageInfo, state client.GooGetState) (bool, error) {
+	c, err := goolib.ParseConstraint(pi.Ver)
+	if err != nil {
+		return false, err
+	}
 	for _, p := range state {
 		if p.PackageSpec.Name == pi.Name && (pi.Arch == "" || p.PackageSpec.Arch == pi.Arch) {
-			c, err := goolib.Compare(pi.Ver, p.PackageSpec.Version)
-			if err != nil {
-				return false, err
-			}
-			return c < 1, nil
+			return c.Check(p.PackageSpec.Version)
 		}
 	}
 	return false, nil
 }
 
+// installedMatching returns the installed packages matched by rel, an entry
+// of the Conflicts or Replaces of ps, leaving out ps itself.
+func installedMatching(ps *goolib.PkgSpec, rel string, state client.GooGetState) ([]goolib.PackageInfo, error) {
+	var pl []goolib.PackageInfo
+	for _, p := range state {
+		spec := p.PackageSpec
+		if spec.Name == ps.Name && spec.Arch == ps.Arch {
+			continue
+		}
+		ok, err := relMatches(rel, spec)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			pl = append(pl, goolib.PackageInfo{Name: spec.Name, Arch: spec.Arch, Ver: spec.Version})
+		}
+	}
+	return pl, nil
+}
+
 func resolveConflicts(ps *goolib.PkgSpec, state *client.GooGetState) error {
 	// Check for any conflicting packages.
 	// TODO(ajackura): Make sure no conflicting packages are listed as
 	// dependencies or subdependancies.
 	for _, pkg := range ps.Conflicts {
-		pi := goolib.PkgNameSplit(pkg)
-		ins, err := minInstalled(goolib.PackageInfo{Name: pi.Name, Arch: pi.Arch, Ver: pi.Ver}, *state)
+		pl, err := installedMatching(ps, pkg, *state)
 		if err != nil {
 			return err
 		}
-		if ins {
-			return fmt.Errorf("cannot install, conflict with installed package: %s", pi)
+		if len(pl) != 0 {
+			return fmt.Errorf("cannot install, conflict with installed package: %s", pl[0])
 		}
 	}
 	return nil
 }
 
-func resolveReplacements(ctx context.Context, ps *goolib.PkgSpec, state *client.GooGetState, dbOnly bool, proxyServer string) error {
+func resolveReplacements(ctx context.Context, ps *goolib.PkgSpec, state *client.GooGetState, dbOnly bool, downloader client.Downloader) error {
 	// Check for and remove any package this replaces.
 	// TODO(ajackura): Make sure no replacements are listed as
 	// dependencies or subdependancies.
 	for _, pkg := range ps.Replaces {
-		pi := goolib.PkgNameSplit(pkg)
-		ins, err := minInstalled(goolib.PackageInfo{Name: pi.Name, Arch: pi.Arch, Ver: pi.Ver}, *state)
+		pl, err := installedMatching(ps, pkg, *state)
 		if err != nil {
 			return err
 		}
-		if !ins {
-			continue
-		}
-		deps, _ := remove.EnumerateDeps(pi, *state)
-		logger.Infof("%s replaces %s, removing", ps, pi)
-		if err := remove.All(ctx, pi, deps, state, dbOnly, proxyServer); err != nil {
-			return err
+		for _, pi := range pl {
+			// Dependencies on the name of a replaced package of another
+			// arch are met by ps, so only that package is removed.
+			deps := remove.DepMap{pi.Name + "." + pi.Arch: nil}
+			if pi.Name != ps.Name {
+				deps, _ = remove.EnumerateDeps(pi, *state)
+			}
+			logger.Infof("%s replaces %s, removing", ps, pi)
+			if err := remove.All(ctx, pi, deps, state, dbOnly, downloader); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
-func installDeps(ctx context.Context, ps *goolib.PkgSpec, cache string, rm client.RepoMap, archs []string, state *client.GooGetState, dbOnly bool, proxyServer string) error {
+func installDeps(ctx context.Context, ps *goolib.PkgSpec, res resolution, cache string, rm client.RepoMap, archs []string, state *client.GooGetState, dbOnly bool, downloader client.Downloader) error {
 	logger.Infof("Resolving conflicts and dependencies for %s %s version %s", ps.Arch, ps.Name, ps.Version)
 	if err := resolveConflicts(ps, state); err != nil {
 		return err
 	}
 	// Check for and install any dependencies.
-	for p, ver := range ps.PkgDependencies {
-		pi := goolib.PkgNameSplit(p)
-		mi, err := minInstalled(goolib.PackageInfo{Name: pi.Name, Arch: pi.Arch, Ver: ver}, *state)
-		if err != nil {
-			return err
+	reqs, err := requirements(ps)
+	if err != nil {
+		return err
+	}
+	for _, req := range reqs {
+		c, ok := res.find(req)
+		if !ok {
+			// Only dependencies renamed to an installed package are met
+			// without a package in res.
+			if _, renamed := renamedInstalled(req.pi, rm, *state); !renamed || req.c.Range() {
+				return fmt.Errorf("package dependency %s of %s is not met", req, ps)
+			}
 		}
-		if mi {
-			logger.Infof("Dependency met: %s.%s with version greater than %s installed", pi.Name, pi.Arch, ver)
+		if !ok || c.repo == "" || installedVersion(c.spec, *state) {
+			logger.Infof("Dependency met: %s installed", req)
 			continue
 		}
-		var ins bool
-		v, repo, arch, err := client.FindRepoLatest(goolib.PackageInfo{Name: pi.Name, Arch: pi.Arch, Ver: ""}, rm, archs)
-		if err != nil {
-			return err
-		}
-		c, err := goolib.Compare(v, ver)
-		if err != nil {
+		logger.Infof("Dependency found: %s is available", c.spec)
+		if err := fromRepo(ctx, goolib.PackageInfo{Name: c.spec.Name, Arch: c.spec.Arch, Ver: c.spec.Version}, c.repo, client.ReasonDependency, res, cache, rm, archs, state, dbOnly, downloader); err != nil {
 			return err
 		}
-		if c > -1 {
-			logger.Infof("Dependency found: %s.%s %s is available", pi.Name, arch, v)
-			if err := FromRepo(ctx, goolib.PackageInfo{Name: pi.Name, Arch: arch, Ver: v}, repo, cache, rm, archs, state, dbOnly, proxyServer); err != nil {
-				return err
-			}
-			ins = true
+	}
+	return resolveReplacements(ctx, ps, state, dbOnly, downloader)
+}
+
+// installedVersion reports whether spec is installed at its version.
+func installedVersion(spec *goolib.PkgSpec, state client.GooGetState) bool {
+	for _, p := range state {
+		if p.PackageSpec.Name == spec.Name && p.PackageSpec.Arch == spec.Arch && p.PackageSpec.Version == spec.Version {
+			return true
 		}
-		if !ins {
-			return fmt.Errorf("cannot resolve dependency, %s.%s version %s or greater not installed and not available in any repo", pi.Name, arch, ver)
+	}
+	return false
+}
+
+// FromRepo installs a package and all dependencies from a repository. A
+// package not installed before is recorded as explicitly installed and its
+// dependencies as installed as dependencies, upgrades keep the reason the
+// version they replace was installed for.
+func FromRepo(ctx context.Context, pi goolib.PackageInfo, repo, cache string, rm client.RepoMap, archs []string, state *client.GooGetState, dbOnly bool, downloader client.Downloader) error {
+	return fromRepo(ctx, pi, repo, client.ReasonExplicit, nil, cache, rm, archs, state, dbOnly, downloader)
+}
+
+// installReason returns the reason spec is installed for, that of the
+// installed version of the package if any, else reason.
+func installReason(spec *goolib.PkgSpec, reason string, state client.GooGetState) string {
+	for _, p := range state {
+		if p.PackageSpec.Name == spec.Name && p.PackageSpec.Arch == spec.Arch {
+			return p.InstallReason
 		}
 	}
-	return resolveReplacements(ctx, ps, state, dbOnly, proxyServer)
+	return reason
 }
 
-// FromRepo installs a package and all dependencies from a repository.
-func FromRepo(ctx context.Context, pi goolib.PackageInfo, repo, cache string, rm client.RepoMap, archs []string, state *client.GooGetState, dbOnly bool, proxyServer string) error {
+// fromRepo installs pi from repo for reason after the dependencies chosen
+// for it in res, which is resolved after the preresolve hook if nil.
+func fromRepo(ctx context.Context, pi goolib.PackageInfo, repo, reason string, res resolution, cache string, rm client.RepoMap, archs []string, state *client.GooGetState, dbOnly bool, downloader client.Downloader) (err error) {
 	logger.Infof("Starting install of %s.%s.%s", pi.Name, pi.Arch, pi.Ver)
 	fmt.Printf("Installing %s.%s.%s and dependencies...\n", pi.Name, pi.Arch, pi.Ver)
+	done := events.Begin(events.Install, pi, repo)
+	defer func() { done(err) }()
 	rs, err := client.FindRepoSpec(pi, rm[repo])
 	if err != nil {
 		return err
 	}
-	if err := installDeps(ctx, rs.PackageSpec, cache, rm, archs, state, dbOnly, proxyServer); err != nil {
+	if err := runHook(ctx, hooks.PreResolve, rs.PackageSpec, repo, "", ""); err != nil {
+		return err
+	}
+	if res == nil {
+		_, span := telemetry.Start(ctx, "resolve", "googet.package", pi.Name)
+		res, err = resolve(rs, repo, rm, archs, *state)
+		span.End(err)
+		if err != nil {
+			return err
+		}
+		if err := preflight(ctx, res, cache, rm, *state, dbOnly, downloader); err != nil {
+			return err
+		}
+	}
+	if err := installDeps(ctx, rs.PackageSpec, res, cache, rm, archs, state, dbOnly, downloader); err != nil {
 		return err
 	}
 
-	dst, err := download.FromRepo(ctx, rs, repo, cache, proxyServer)
+	pkgURL, err := download.PackageURL(rs, repo)
 	if err != nil {
 		return err
 	}
-
-	insFiles, err := installPkg(dst, rs.PackageSpec, dbOnly)
+	if err := runHook(ctx, hooks.PreDownload, rs.PackageSpec, repo, pkgURL, ""); err != nil {
+		return err
+	}
+	dst, err := download.FromRepo(ctx, rs, repo, cache, downloader)
 	if err != nil {
 		return err
 	}
-
-	logger.Infof("Installation of %s.%s.%s completed", pi.Name, pi.Arch, pi.Ver)
-	fmt.Printf("Installation of %s.%s.%s and all dependencies completed\n", pi.Name, pi.Arch, pi.Ver)
+	if err := checkSignature(ctx, dst, rs.PackageSpec, repo); err != nil {
+		return err
+	}
+	if err := checkCosign(ctx, dst, rs.PackageSpec, pkgURL, repo, downloader); err != nil {
+		return err
+	}
+	if err := checkPublisher(dst, rs.PackageSpec, repo); err != nil {
+		return err
+	}
+	sr, err := scan(ctx, dst, pi, repo)
+	if err != nil {
+		return err
+	}
+	if err := runHook(ctx, hooks.PreInstall, rs.PackageSpec, repo, pkgURL, dst); err != nil {
+		return err
+	}
+	st, err := installPkg(ctx, dst, rs.PackageSpec, *state, dbOnly, false)
+	if err != nil {
+		return err
+	}
+	// The files are in place, so the package is recorded even if
+	// integrating it fails, for remove to undo.
+	ierr := integrate(&st, rs.PackageSpec, *state, dbOnly)
+	if ierr == nil {
+		logger.Infof("Installation of %s.%s.%s completed", pi.Name, pi.Arch, pi.Ver)
+		fmt.Printf("Installation of %s.%s.%s and all dependencies completed\n", pi.Name, pi.Arch, pi.Ver)
+		printReboot(st, rs.PackageSpec)
+	}
+	st.InstallReason = installReason(rs.PackageSpec, reason, *state)
 	// Clean up old version, if applicable.
 	pi = goolib.PackageInfo{Name: pi.Name, Arch: pi.Arch, Ver: ""}
-	cleanOld(state, pi, insFiles, dbOnly)
-
-	state.Add(client.PackageState{
-		SourceRepo:     repo,
-		DownloadURL:    strings.TrimSuffix(repo, filepath.Base(repo)) + rs.Source,
-		Checksum:       rs.Checksum,
-		LocalPath:      dst,
-		PackageSpec:    rs.PackageSpec,
-		InstalledFiles: insFiles,
-	})
+	st.CreatedDirs = mergeDirs(st.CreatedDirs, cleanOld(ctx, state, pi, st, dst, dbOnly))
+
+	st.SourceRepo = repo
+	st.DownloadURL = strings.TrimSuffix(repo, filepath.Base(repo)) + rs.Source
+	st.Checksum = rs.BestChecksum()
+	st.LocalPath = dst
+	st.PackageSpec = rs.PackageSpec
+	st.Scan = sr
+	state.Add(st)
+	if ierr != nil {
+		return ierr
+	}
+	postInstall(ctx, rs.PackageSpec, repo, pkgURL, dst)
+	return nil
+}
+
+// Prefetch downloads the packages of pis and their dependencies that need
+// installation to cache, MaxDownloads at a time, so that installing them
+// one by one afterwards finds them there. It does nothing unless
+// MaxDownloads is more than 1. Errors are only logged, the install of the
+// package reports them again.
+func Prefetch(ctx context.Context, pis []goolib.PackageInfo, cache string, rm client.RepoMap, archs []string, state client.GooGetState, downloader client.Downloader) {
+	if MaxDownloads < 2 {
+		return
+	}
+	type fetch struct {
+		rs   goolib.RepoSpec
+		repo string
+	}
+	var fetches []fetch
+	seen := make(map[string]bool)
+	for _, pi := range pis {
+		repo, err := client.WhatRepo(pi, rm)
+		if err != nil {
+			continue
+		}
+		dl, err := ListDeps(pi, rm, repo, archs)
+		if err != nil {
+			continue
+		}
+		for _, di := range dl {
+			if ni, err := NeedsInstallation(di, state); err != nil || !ni {
+				continue
+			}
+			r, err := client.WhatRepo(di, rm)
+			if err != nil {
+				continue
+			}
+			rs, err := client.FindRepoSpec(di, rm[r])
+			if err != nil || seen[rs.Checksum] {
+				continue
+			}
+			seen[rs.Checksum] = true
+			fetches = append(fetches, fetch{rs, r})
+		}
+	}
+
+	sem := make(chan struct{}, MaxDownloads)
+	var wg sync.WaitGroup
+	for _, f := range fetches {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(f fetch) {
+			defer func() { <-sem; wg.Done() }()
+			if _, err := download.FromRepo(ctx, f.rs, f.repo, cache, downloader); err != nil {
+				logger.Errorf("Error downloading %s: %v", f.rs.PackageSpec, err)
+			}
+		}(f)
+	}
+	wg.Wait()
+}
+
+// renamedInstalled returns the name pi was renamed to in rm and whether a
+// package of that name is installed.
+func renamedInstalled(pi goolib.PackageInfo, rm client.RepoMap, state client.GooGetState) (string, bool) {
+	n, ok := client.Renamed(pi.Name, rm)
+	if !ok {
+		return "", false
+	}
+	_, err := state.GetPackageState(goolib.PackageInfo{Name: n, Arch: pi.Arch})
+	return n, err == nil
+}
+
+// Migrate installs pi from repo in place of the installed package from, which
+// was renamed to pi.Name. The files of from that pi does not install again are
+// removed as if from were an older version of pi, its uninstall command is not
+// run.
+func Migrate(ctx context.Context, from, pi goolib.PackageInfo, repo, cache string, rm client.RepoMap, archs []string, state *client.GooGetState, dbOnly bool, downloader client.Downloader) error {
+	old, err := state.GetPackageState(from)
+	if err != nil {
+		return err
+	}
+	if err := FromRepo(ctx, pi, repo, cache, rm, archs, state, dbOnly, downloader); err != nil {
+		return err
+	}
+	ps, err := state.GetPackageState(pi)
+	if err != nil {
+		return err
+	}
+	from.Ver = old.PackageSpec.Version
+	ps.CreatedDirs = mergeDirs(ps.CreatedDirs, cleanOld(ctx, state, from, ps, ps.LocalPath, dbOnly))
+	ps.InstallReason = old.InstallReason
+	if err := state.Remove(pi); err != nil {
+		return err
+	}
+	state.Add(ps)
+	logger.Infof("Migrated %s to %s.%s.%s", old.PackageSpec, pi.Name, pi.Arch, pi.Ver)
 	return nil
 }
 
 // FromDisk installs a local .goo file.
-func FromDisk(arg, cache string, state *client.GooGetState, dbOnly, ri bool) error {
+func FromDisk(ctx context.Context, arg, cache string, state *client.GooGetState, dbOnly, ri bool) (err error) {
 	if _, err := oswrap.Stat(arg); err != nil {
 		return err
 	}
@@ -191,69 +396,121 @@ func FromDisk(arg, cache string, state *client.GooGetState, dbOnly, ri bool) err
 
 	logger.Infof("Starting install of %q, version %q from %q", zs.Name, zs.Version, arg)
 	fmt.Printf("Installing %s %s...\n", zs.Name, zs.Version)
+	done := events.Begin(events.Install, goolib.PackageInfo{Name: zs.Name, Arch: zs.Arch, Ver: zs.Version}, "")
+	defer func() { done(err) }()
 
+	if err := checkSignature(ctx, arg, zs, ""); err != nil {
+		return err
+	}
+	if err := checkCosign(ctx, arg, zs, "", "", nil); err != nil {
+		return err
+	}
+	if err := checkPublisher(arg, zs, ""); err != nil {
+		return err
+	}
 	if err := resolveConflicts(zs, state); err != nil {
 		return err
 	}
-	for p, ver := range zs.PkgDependencies {
+	for p, ver := range zs.Dependencies() {
 		pi := goolib.PkgNameSplit(p)
+		c, err := goolib.ParseConstraint(ver)
+		if err != nil {
+			return err
+		}
 		mi, err := minInstalled(goolib.PackageInfo{Name: pi.Name, Arch: pi.Arch, Ver: ver}, *state)
 		if err != nil {
 			return err
 		}
 		if mi {
-			logger.Infof("Dependency met: %s.%s with version greater than %s installed", pi.Name, pi.Arch, ver)
+			logger.Infof("Dependency met: %s.%s with version %s installed", pi.Name, pi.Arch, c)
 			continue
 		}
-		return fmt.Errorf("package dependency %s %s (min version %s) not installed", pi.Name, pi.Arch, ver)
+		return fmt.Errorf("package dependency %s %s (version %s) not installed", pi.Name, pi.Arch, c)
 	}
 	for _, pkg := range zs.Replaces {
-		pi := goolib.PkgNameSplit(pkg)
-		ins, err := minInstalled(goolib.PackageInfo{Name: pi.Name, Arch: pi.Arch, Ver: pi.Ver}, *state)
+		pl, err := installedMatching(zs, pkg, *state)
 		if err != nil {
 			return err
 		}
-		if ins {
-			return fmt.Errorf("cannot install, replaces installed package, remove first then try installation again: %s", pi)
+		if len(pl) != 0 {
+			return fmt.Errorf("cannot install, replaces installed package, remove first then try installation again: %s", pl[0])
 		}
 	}
 
-	dst := filepath.Join(cache, goolib.PackageInfo{Name: zs.Name, Arch: zs.Arch, Ver: zs.Version}.PkgName())
-	if err := copyPkg(arg, dst); err != nil {
+	f, err := oswrap.Open(arg)
+	if err != nil {
 		return err
 	}
-
-	insFiles, err := installPkg(dst, zs, dbOnly)
+	chksum := goolib.Checksum(f)
+	f.Close()
+	dst, err := download.CachePath(cache, chksum)
 	if err != nil {
 		return err
 	}
+	// An identical package, perhaps from a repo or under another name, may
+	// already be cached.
+	if _, ok := download.Cached(client.PackageState{LocalPath: dst, Checksum: chksum}); !ok {
+		if err := copyPkg(arg, dst); err != nil {
+			return err
+		}
+	}
+	download.WriteCacheName(dst, zs)
+	sr, err := scan(ctx, dst, goolib.PackageInfo{Name: zs.Name, Arch: zs.Arch, Ver: zs.Version}, "")
+	if err != nil {
+		return err
+	}
+	if err := runHook(ctx, hooks.PreInstall, zs, "", "", dst); err != nil {
+		return err
+	}
+	st, err := installPkg(ctx, dst, zs, *state, dbOnly, false)
+	if err != nil {
+		return err
+	}
+	// The files are in place, so the package is recorded even if
+	// integrating it fails, for remove to undo.
+	ierr := integrate(&st, zs, *state, dbOnly)
 
 	if ri {
+		setReinstalled(state, zs, st)
+		if ierr != nil {
+			return ierr
+		}
 		logger.Infof("Reinstallation of %q, version %q completed", zs.Name, zs.Version)
 		fmt.Printf("Reinstallation of %s completed\n", zs.Name)
+		printReboot(st, zs)
+		postInstall(ctx, zs, "", "", dst)
 		return nil
 	}
 
-	logger.Infof("Installation of %q, version %q completed", zs.Name, zs.Version)
-	fmt.Printf("Installation of %s completed\n", zs.Name)
+	if ierr == nil {
+		logger.Infof("Installation of %q, version %q completed", zs.Name, zs.Version)
+		fmt.Printf("Installation of %s completed\n", zs.Name)
+		printReboot(st, zs)
+	}
+	st.InstallReason = client.ReasonExplicit
 
 	// Clean up old version, if applicable.
 	pi := goolib.PackageInfo{Name: zs.Name, Arch: zs.Arch, Ver: ""}
-	cleanOld(state, pi, insFiles, dbOnly)
+	st.CreatedDirs = mergeDirs(st.CreatedDirs, cleanOld(ctx, state, pi, st, dst, dbOnly))
 
-	state.Add(client.PackageState{
-		LocalPath:      dst,
-		PackageSpec:    zs,
-		InstalledFiles: insFiles,
-	})
+	st.LocalPath = dst
+	st.PackageSpec = zs
+	st.Scan = sr
+	state.Add(st)
+	if ierr != nil {
+		return ierr
+	}
+	postInstall(ctx, zs, "", "", dst)
 	return nil
 }
 
 // Reinstall reinstalls and optionally redownloads, a package.
-func Reinstall(ctx context.Context, ps client.PackageState, state client.GooGetState, rd bool, proxyServer string) error {
+func Reinstall(ctx context.Context, ps client.PackageState, state *client.GooGetState, rd bool, downloader client.Downloader) (err error) {
 	pi := goolib.PackageInfo{Name: ps.PackageSpec.Name, Arch: ps.PackageSpec.Arch, Ver: ps.PackageSpec.Version}
 	logger.Infof("Starting reinstall of %s.%s, version %s", pi.Name, pi.Arch, pi.Ver)
 	fmt.Printf("Reinstalling %s.%s %s and dependencies...\n", pi.Name, pi.Arch, pi.Ver)
+	done := events.Begin(events.Reinstall, pi, ps.SourceRepo)
+	defer func() { done(err) }()
 
 	// Fix for package install by older versions of GooGet.
 	if ps.LocalPath == "" && ps.UnpackDir != "" {
@@ -264,40 +521,159 @@ func Reinstall(ctx context.Context, ps client.PackageState, state client.GooGetS
 		return fmt.Errorf("local path not referenced in state file for %s.%s.%s. Cannot redownload", pi.Name, pi.Arch, pi.Ver)
 	}
 
-	f, err := os.Open(ps.LocalPath)
-	if err != nil && !os.IsNotExist(err) {
-		return err
-	}
-	if os.IsNotExist(err) {
-		logger.Infof("Local package does not exist for %s.%s.%s, redownloading...", pi.Name, pi.Arch, pi.Ver)
+	if p, ok := download.Cached(ps); ok {
+		ps.LocalPath = p
+	} else {
+		logger.Infof("No cached package matches %s.%s.%s, redownloading...", pi.Name, pi.Arch, pi.Ver)
 		rd = true
 	}
-	// Force redownload if checksum does not match.
-	// If checksum is empty this was a local install so ignore.
-	if !rd && ps.Checksum != "" && goolib.Checksum(f) != ps.Checksum {
-		logger.Info("Local package checksum does not match, redownloading...")
-		rd = true
-	}
-	f.Close()
 
 	if rd {
 		if ps.DownloadURL == "" {
 			return fmt.Errorf("can not redownload %s.%s.%s, DownloadURL not saved", pi.Name, pi.Arch, pi.Ver)
 		}
-		if err := download.Package(ctx, ps.DownloadURL, ps.LocalPath, ps.Checksum, proxyServer); err != nil {
-			return fmt.Errorf("error redownloading package: %v", err)
+		if err := download.Package(client.WithRepo(ctx, ps.SourceRepo), ps.DownloadURL, ps.LocalPath, ps.Checksum, downloader); err != nil {
+			return fmt.Errorf("error redownloading package: %w", err)
 		}
 	}
 
-	if _, err := installPkg(ps.LocalPath, ps.PackageSpec, false); err != nil {
-		return fmt.Errorf("error reinstalling package: %v", err)
+	if err := checkSignature(ctx, ps.LocalPath, ps.PackageSpec, ps.SourceRepo); err != nil {
+		return err
+	}
+	if err := checkCosign(ctx, ps.LocalPath, ps.PackageSpec, ps.DownloadURL, ps.SourceRepo, downloader); err != nil {
+		return err
+	}
+	if err := checkPublisher(ps.LocalPath, ps.PackageSpec, ps.SourceRepo); err != nil {
+		return err
+	}
+	if _, err := scan(ctx, ps.LocalPath, pi, ps.SourceRepo); err != nil {
+		return err
+	}
+	if err := runHook(ctx, hooks.PreInstall, ps.PackageSpec, ps.SourceRepo, ps.DownloadURL, ps.LocalPath); err != nil {
+		return err
+	}
+	// A reinstall repairs the MSI product rather than taking it over.
+	st, err := installPkg(ctx, ps.LocalPath, ps.PackageSpec, *state, false, true)
+	if err != nil {
+		return fmt.Errorf("error reinstalling package: %w", err)
+	}
+	ierr := integrate(&st, ps.PackageSpec, *state, false)
+	setReinstalled(state, ps.PackageSpec, st)
+	if ierr != nil {
+		return fmt.Errorf("error reinstalling package: %v", ierr)
 	}
 
 	logger.Infof("Reinstallation of %s.%s, version %s completed", pi.Name, pi.Arch, pi.Ver)
 	fmt.Printf("Reinstallation of %s.%s %s completed\n", pi.Name, pi.Arch, pi.Ver)
+	printReboot(st, ps.PackageSpec)
+	postInstall(ctx, ps.PackageSpec, ps.SourceRepo, ps.DownloadURL, ps.LocalPath)
 	return nil
 }
 
+// runHook runs the hooks of hook for the package ps from repo, downloaded
+// from url to path.
+func runHook(ctx context.Context, hook string, ps *goolib.PkgSpec, repo, url, path string) error {
+	return hooks.Run(ctx, hooks.Request{Hook: hook, Package: fmt.Sprintf("%s.%s.%s", ps.Name, ps.Arch, ps.Version), Repo: repo, URL: url, Path: path, Spec: ps})
+}
+
+// postInstall runs the PostInstall hooks of ps, which can't fail the
+// install.
+func postInstall(ctx context.Context, ps *goolib.PkgSpec, repo, url, path string) {
+	if err := runHook(ctx, hooks.PostInstall, ps, repo, url, path); err != nil {
+		logger.Error(err)
+	}
+}
+
+// printReboot tells the user if the install of ps in st requires a reboot.
+func printReboot(st client.PackageState, ps *goolib.PkgSpec) {
+	if st.RebootRequired {
+		logger.Infof("Installation of %s requires a reboot", ps)
+		fmt.Printf("A reboot is required to complete the installation of %s\n", ps)
+	}
+}
+
+// setReinstalled records in state whether the reinstall st of ps requires a
+// reboot and the changes it made to the system.
+func setReinstalled(state *client.GooGetState, ps *goolib.PkgSpec, st client.PackageState) {
+	pi := goolib.PackageInfo{Name: ps.Name, Arch: ps.Arch, Ver: ps.Version}
+	for i := range *state {
+		if (*state)[i].Match(pi) {
+			(*state)[i].RebootRequired = st.RebootRequired
+			(*state)[i].RebootRequested = st.RebootRequested
+			(*state)[i].EnvChanges = st.EnvChanges
+			(*state)[i].Shortcuts = st.Shortcuts
+			(*state)[i].MSIProductCode = st.MSIProductCode
+		}
+	}
+}
+
+// integrate applies the Env and Shortcuts of ps, recording them in st and
+// taking over the changes made by the installed version of the package. If it
+// fails st records the changes still in effect.
+func integrate(st *client.PackageState, ps *goolib.PkgSpec, state client.GooGetState, dbOnly bool) error {
+	var prev client.PackageState
+	for _, p := range state {
+		if p.PackageSpec.Name == ps.Name && p.PackageSpec.Arch == ps.Arch {
+			prev = p
+		}
+	}
+	// The changes of the installed version stay in effect until replaced.
+	st.EnvChanges, st.Shortcuts = prev.EnvChanges, prev.Shortcuts
+	if dbOnly {
+		return nil
+	}
+
+	env := ps.Env
+	if env != nil {
+		env = &goolib.EnvSpec{Vars: env.Vars}
+		for _, p := range ps.Env.Path {
+			env.Path = append(env.Path, filepath.Clean(resolveDst(p)))
+		}
+	}
+	c, err := system.SetEnv(env, prev.EnvChanges)
+	if err != nil {
+		return fmt.Errorf("error setting the environment: %v", err)
+	}
+	st.EnvChanges = c
+
+	var shortcuts []goolib.Shortcut
+	for _, sc := range ps.Shortcuts {
+		sc.Target = filepath.Clean(resolveDst(sc.Target))
+		if sc.Icon != "" {
+			sc.Icon = resolveDst(sc.Icon)
+		}
+		shortcuts = append(shortcuts, sc)
+	}
+	paths, err := system.AddShortcuts(shortcuts)
+	if err != nil {
+		return err
+	}
+	var old []string
+	for _, p := range prev.Shortcuts {
+		if !goolib.ContainsString(p, paths) {
+			old = append(old, p)
+		}
+	}
+	if err := system.RemoveShortcuts(old); err != nil {
+		logger.Error(err)
+	}
+	st.Shortcuts = paths
+	return nil
+}
+
+// resolveEntry returns ps with the DisplayIcon of its UninstallEntry resolved
+// like the destinations of its files.
+func resolveEntry(ps *goolib.PkgSpec) *goolib.PkgSpec {
+	if ps.UninstallEntry == nil || ps.UninstallEntry.DisplayIcon == "" {
+		return ps
+	}
+	e := *ps.UninstallEntry
+	e.DisplayIcon = resolveDst(e.DisplayIcon)
+	rs := *ps
+	rs.UninstallEntry = &e
+	return &rs
+}
+
 func copyPkg(src, dst string) (retErr error) {
 	r, err := oswrap.Open(src)
 	if err != nil {
@@ -352,7 +728,10 @@ func extractSpec(pkgPath string) (*goolib.PkgSpec, error) {
 	return goolib.ExtractPkgSpec(f)
 }
 
-func makeInstallFunction(src, dst string, insFiles map[string]string, dbOnly bool) func(string, os.FileInfo, error) error {
+// makeInstallFunction returns a walk function copying files under src to dst.
+// Files with an entry in manifest, keyed by their extracted path, must match
+// it.
+func makeInstallFunction(src, dst string, insFiles map[string]string, createdDirs map[string]bool, dbOnly bool, perms Permissions, manifest map[string]goolib.ManifestEntry) func(string, os.FileInfo, error) error {
 	return func(path string, fi os.FileInfo, err error) (outerr error) {
 		if err != nil {
 			return err
@@ -374,7 +753,11 @@ func makeInstallFunction(src, dst string, insFiles map[string]string, dbOnly boo
 			logger.Infof("Creating folder %q", outPath)
 			// We designate directories by an empty hash.
 			insFiles[outPath] = ""
-			return oswrap.MkdirAll(outPath, fi.Mode())
+			created, err := perms.mkdirAll(outPath, fi.Mode())
+			for _, d := range created {
+				createdDirs[d] = true
+			}
+			return err
 		}
 		fn, err := client.RemoveOrRename(outPath)
 		if err != nil {
@@ -389,7 +772,11 @@ func makeInstallFunction(src, dst string, insFiles map[string]string, dbOnly boo
 			if !os.IsNotExist(err) {
 				return err
 			}
-			if err := oswrap.MkdirAll(filepath.Dir(outPath), fi.Mode()); err != nil {
+			created, err := perms.mkdirAll(filepath.Dir(outPath), fi.Mode())
+			for _, d := range created {
+				createdDirs[d] = true
+			}
+			if err != nil {
 				return err
 			}
 			if oFile, err = oswrap.Create(outPath); err != nil {
@@ -401,6 +788,11 @@ func makeInstallFunction(src, dst string, insFiles map[string]string, dbOnly boo
 				outerr = err
 			}
 		}()
+		if perms.set() {
+			if err := oFile.Chmod(perms.file()); err != nil {
+				return err
+			}
+		}
 		iFile, err := oswrap.Open(path)
 		if err != nil {
 			return err
@@ -413,6 +805,9 @@ func makeInstallFunction(src, dst string, insFiles map[string]string, dbOnly boo
 			return err
 		}
 		insFiles[outPath] = hex.EncodeToString(hash.Sum(nil))
+		if e, ok := manifest[path]; ok && e.Checksum != insFiles[outPath] {
+			return &goolib.VerificationError{Err: fmt.Errorf("checksum of %q does not match the package manifest", e.Path)}
+		}
 		return nil
 	}
 }
@@ -429,19 +824,28 @@ func resolveDst(dst string) string {
 	return dst
 }
 
-func cleanOld(state *client.GooGetState, pi goolib.PackageInfo, insFiles map[string]string, dbOnly bool) {
+// cleanOld removes the files of the old version of a package that are not
+// part of the new version, installed as ns, and uninstalls its MSI product if
+// the new version has another. It returns the directories the old version
+// created that still exist, so they are removed when the new version is.
+func cleanOld(ctx context.Context, state *client.GooGetState, pi goolib.PackageInfo, ns client.PackageState, keep string, dbOnly bool) []string {
 	st, err := state.GetPackageState(pi)
 	if err != nil {
 		// TODO: Use error wrapping here https://blog.golang.org/go1.13-errors
 		if !strings.Contains(err.Error(), "no match found for package") {
 			logger.Error(err)
 		}
-		return
+		return nil
 	}
 	if !dbOnly {
-		cleanOldFiles(st, insFiles)
+		cleanOldFiles(st, ns.InstalledFiles)
+		if st.MSIProductCode != "" && st.MSIProductCode != ns.MSIProductCode {
+			cleanOldMSI(ctx, st)
+		}
 	}
-	if st.LocalPath != "" && oswrap.RemoveAll(st.LocalPath) != nil {
+	// The cached package is kept if the new version or another package
+	// has the same contents.
+	if st.LocalPath != "" && st.LocalPath != keep && !state.SharesCache(st) && download.RemoveCached(st.LocalPath) != nil {
 		logger.Error(err)
 	}
 	if st.UnpackDir != "" && oswrap.RemoveAll(st.UnpackDir) != nil {
@@ -450,7 +854,37 @@ func cleanOld(state *client.GooGetState, pi goolib.PackageInfo, insFiles map[str
 	if err := state.Remove(pi); err != nil {
 		logger.Error(err)
 	}
-	return
+	old := st.CreatedDirs
+	if old == nil {
+		// Installed by an older version of GooGet, fall back to the
+		// directories listed in InstalledFiles.
+		for file, chksum := range st.InstalledFiles {
+			if chksum == "" {
+				old = append(old, file)
+			}
+		}
+	}
+	var dirs []string
+	for _, d := range old {
+		if _, err := oswrap.Stat(d); err == nil {
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
+
+// mergeDirs returns the sorted union of a and b.
+func mergeDirs(a, b []string) []string {
+	seen := make(map[string]bool)
+	out := make([]string, 0, len(a)+len(b))
+	for _, d := range append(append([]string{}, a...), b...) {
+		if !seen[d] {
+			seen[d] = true
+			out = append(out, d)
+		}
+	}
+	sort.Strings(out)
+	return out
 }
 
 func cleanOldFiles(oldState client.PackageState, insFiles map[string]string) {
@@ -478,10 +912,46 @@ func cleanOldFiles(oldState client.PackageState, insFiles map[string]string) {
 	}
 }
 
-func installPkg(pkg string, ps *goolib.PkgSpec, dbOnly bool) (map[string]string, error) {
+// cleanOldMSI uninstalls the MSI product of the old version st of a package,
+// which the new version no longer installs.
+func cleanOldMSI(ctx context.Context, st client.PackageState) {
+	logger.Infof("Uninstalling MSI product %s of %s", st.MSIProductCode, st.PackageSpec)
+	dir, err := os.MkdirTemp("", "googet-msi")
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	defer oswrap.RemoveAll(dir)
+	if err := uninstallMSI(ctx, dir, st.MSIProductCode, st.PackageSpec.MSI); err != nil {
+		logger.Errorf("Error uninstalling MSI product %s of %s: %v", st.MSIProductCode, st.PackageSpec, err)
+	}
+}
+
+// installPkg installs the files of a package and runs its install script, or
+// installs its MSI, repairing the MSI product if it is already installed and
+// repair is set. It returns a state with the installed files, their entries
+// in the manifest embedded in the package, if any, the directories that were
+// created and whether the install script asked for a reboot. The versions of
+// the package in state are those it replaces.
+func installPkg(ctx context.Context, pkg string, ps *goolib.PkgSpec, state client.GooGetState, dbOnly, repair bool) (client.PackageState, error) {
+	_, span := telemetry.Start(ctx, "extract", "googet.package", ps.Name)
 	dir, err := download.ExtractPkg(pkg)
+	span.End(err)
 	if err != nil {
-		return nil, err
+		return client.PackageState{}, err
+	}
+	files, digest, err := goolib.ReadPackageManifest(dir, ps)
+	if err != nil {
+		return client.PackageState{}, err
+	}
+	manifest := make(map[string]goolib.ManifestEntry)
+	for _, e := range files {
+		manifest[filepath.Join(dir, filepath.FromSlash(e.Path))] = e
+	}
+	if !dbOnly {
+		if err := checkSpace(dir, ps, state); err != nil {
+			return client.PackageState{}, err
+		}
 	}
 
 	logger.Infof("Executing install of package %q", filepath.Base(dir))
@@ -494,18 +964,49 @@ func installPkg(pkg string, ps *goolib.PkgSpec, dbOnly bool) (map[string]string,
 		}
 	}()
 
+	perms, err := permissions(ps)
+	if err != nil {
+		return client.PackageState{}, err
+	}
+	if !dbOnly {
+		restart, err := system.ReleaseFiles(replacedFiles(ps, dir), StopServices)
+		if err != nil {
+			logger.Errorf("Error looking for files in use: %v", err)
+		}
+		defer restart()
+	}
 	insFiles := make(map[string]string)
+	createdDirs := make(map[string]bool)
 	for src, dst := range ps.Files {
 		dst = resolveDst(dst)
 		src = filepath.Join(dir, src)
-		if err := oswrap.Walk(src, makeInstallFunction(src, dst, insFiles, dbOnly)); err != nil {
-			return nil, err
+		if err := oswrap.Walk(src, makeInstallFunction(src, dst, insFiles, createdDirs, dbOnly, perms, manifest)); err != nil {
+			return client.PackageState{}, err
+		}
+	}
+	if !dbOnly {
+		for path, a := range attributePaths(ps, dir) {
+			if _, ok := insFiles[path]; !ok {
+				continue
+			}
+			if err := applyAttributes(path, a, perms); err != nil {
+				return client.PackageState{}, fmt.Errorf("error setting attributes of %q: %v", path, err)
+			}
 		}
 	}
 
+	var reboot bool
+	var productCode string
+	if ps.MSI != nil {
+		productCode = ps.MSI.ProductCode
+	}
 	if !dbOnly {
-		if err := system.Install(dir, ps); err != nil {
-			return nil, err
+		if ps.MSI != nil {
+			if productCode, reboot, err = installMSI(ctx, dir, ps.MSI, repair); err != nil {
+				return client.PackageState{}, err
+			}
+		} else if reboot, err = system.Install(ctx, dir, resolveEntry(ps)); err != nil {
+			return client.PackageState{}, err
 		}
 	}
 
@@ -513,40 +1014,80 @@ func installPkg(pkg string, ps *goolib.PkgSpec, dbOnly bool) (map[string]string,
 		logger.Error(err)
 	}
 
-	return insFiles, nil
+	dirs := make([]string, 0, len(createdDirs))
+	for d := range createdDirs {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+	st := client.PackageState{
+		InstalledFiles: insFiles,
+		CreatedDirs:    dirs,
+		ManifestDigest: digest,
+		RebootRequired: reboot,
+		MSIProductCode: productCode,
+	}
+	if reboot {
+		now := time.Now()
+		st.RebootRequested = &now
+	}
+	return st, nil
+}
+
+// replacedFiles returns the existing files the install of ps, extracted to
+// dir, replaces.
+func replacedFiles(ps *goolib.PkgSpec, dir string) []string {
+	var files []string
+	for src, dst := range ps.Files {
+		dst = resolveDst(dst)
+		src = filepath.Join(dir, src)
+		oswrap.Walk(src, func(path string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() {
+				return nil
+			}
+			outPath := filepath.Join(dst, strings.TrimPrefix(path, src))
+			if fi, err := oswrap.Stat(outPath); err == nil && !fi.IsDir() {
+				files = append(files, outPath)
+			}
+			return nil
+		})
+	}
+	sort.Strings(files)
+	return files
 }
 
-func listDeps(pi goolib.PackageInfo, rm client.RepoMap, repo string, dl []goolib.PackageInfo, archs []string) ([]goolib.PackageInfo, error) {
+// ListDeps returns a package and the dependencies and subdependencies
+// resolved for it, regardless of what is installed, dependencies after the
+// packages needing them.
+func ListDeps(pi goolib.PackageInfo, rm client.RepoMap, repo string, archs []string) ([]goolib.PackageInfo, error) {
+	logger.Infof("Building dependency list for %s.%s.%s", pi.Name, pi.Arch, pi.Ver)
 	rs, err := client.FindRepoSpec(pi, rm[repo])
 	if err != nil {
 		return nil, err
 	}
-	dl = append(dl, pi)
-	for d, v := range rs.PackageSpec.PkgDependencies {
-		di := goolib.PkgNameSplit(d)
-		ver, repo, arch, err := client.FindRepoLatest(di, rm, archs)
-		di.Arch = arch
-		if err != nil {
-			return nil, fmt.Errorf("cannot resolve dependency %s.%s.%s: %v", di.Name, di.Arch, di.Ver, err)
-		}
-		c, err := goolib.Compare(ver, v)
-		if err != nil {
-			return nil, err
-		}
-		if c == -1 {
-			return nil, fmt.Errorf("cannot resolve dependency, %s.%s version %s or greater not installed and not available in any repo", pi.Name, pi.Arch, pi.Ver)
+	res, err := resolve(rs, repo, rm, archs, nil)
+	if err != nil {
+		return nil, err
+	}
+	var dl []goolib.PackageInfo
+	seen := make(map[string]bool)
+	var walk func(spec *goolib.PkgSpec)
+	walk = func(spec *goolib.PkgSpec) {
+		if seen[spec.Name+"."+spec.Arch] {
+			return
 		}
-		di.Ver = ver
-		dl, err = listDeps(di, rm, repo, dl, archs)
-		if err != nil {
-			return nil, err
+		seen[spec.Name+"."+spec.Arch] = true
+		reqs, _ := requirements(spec)
+		for _, req := range reqs {
+			if c, ok := res.find(req); ok {
+				walk(c.spec)
+			}
 		}
+		dl = append(dl, goolib.PackageInfo{Name: spec.Name, Arch: spec.Arch, Ver: spec.Version})
+	}
+	walk(rs.PackageSpec)
+	// Reversed, packages come before their dependencies.
+	for i, j := 0, len(dl)-1; i < j; i, j = i+1, j-1 {
+		dl[i], dl[j] = dl[j], dl[i]
 	}
 	return dl, nil
 }
-
-// ListDeps returns a list of dependencies and subdependancies for a package.
-func ListDeps(pi goolib.PackageInfo, rm client.RepoMap, repo string, archs []string) ([]goolib.PackageInfo, error) {
-	logger.Infof("Building dependency list for %s.%s.%s", pi.Name, pi.Arch, pi.Ver)
-	return listDeps(pi, rm, repo, nil, archs)
-}
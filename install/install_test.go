@@ -16,16 +16,23 @@ package install
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/googettest"
 	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/hooks"
 	"github.com/google/googet/v2/oswrap"
 	"github.com/google/logger"
 )
@@ -72,6 +79,64 @@ func TestMinInstalled(t *testing.T) {
 			t.Errorf("minInstalled returned %v for %q when it should return %v", ma, tt.pkg, tt.ins)
 		}
 	}
+
+	for _, tt := range []struct {
+		ver string
+		ins bool
+	}{
+		{">=1.2 <2.0", true},
+		{"~1.2", true},
+		{"~1.3", false},
+		{"!=1.2.3", false},
+		{"!=1.2.3@3", true},
+		{"<1 || >=1.2.3@4", true},
+	} {
+		ma, err := minInstalled(goolib.PackageInfo{Name: "foo_pkg", Ver: tt.ver}, state)
+		if err != nil {
+			t.Fatalf("error checking minAvailable: %v", err)
+		}
+		if ma != tt.ins {
+			t.Errorf("minInstalled returned %v for foo_pkg %q when it should return %v", ma, tt.ver, tt.ins)
+		}
+	}
+}
+
+func TestResolveReplacements(t *testing.T) {
+	pkg := func(name, arch, ver string, deps map[string]string) client.PackageState {
+		return client.PackageState{PackageSpec: &goolib.PkgSpec{Name: name, Arch: arch, Version: ver, PkgDependencies: deps}}
+	}
+	state := client.GooGetState{
+		pkg("foo", "x86_32", "1.0.0@1", nil),
+		pkg("foo", "x86_64", "1.0.0@1", nil),
+		pkg("old", "noarch", "2.5.0@1", nil),
+		pkg("older", "noarch", "3.0.0@1", nil),
+		pkg("bar", "noarch", "1.0.0@1", map[string]string{"foo": "1.0.0"}),
+		pkg("baz", "noarch", "1.0.0@1", map[string]string{"old": "1.0.0"}),
+	}
+	ps := &goolib.PkgSpec{Name: "foo", Arch: "x86_64", Version: "2.0.0@1", Replaces: []string{"foo", "old >=2 <3", "older <3"}}
+	if err := resolveReplacements(context.Background(), ps, &state, true, nil); err != nil {
+		t.Fatalf("resolveReplacements: %v", err)
+	}
+	var got []string
+	for _, p := range state {
+		got = append(got, p.PackageSpec.String())
+	}
+	sort.Strings(got)
+	// foo.x86_64 is ps itself, bar still has its foo dependency met while
+	// baz depended on the replaced old.
+	want := []string{"bar.noarch.1.0.0@1", "foo.x86_64.1.0.0@1", "older.noarch.3.0.0@1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveReplacements left %v installed, want %v", got, want)
+	}
+
+	ps = &goolib.PkgSpec{Name: "new", Arch: "noarch", Conflicts: []string{"foo.x86_64 <2.0", "bar.x86_64"}}
+	if err := resolveConflicts(ps, &state); err == nil || !strings.Contains(err.Error(), "foo.x86_64.1.0.0@1") {
+		t.Errorf("resolveConflicts returned %v, want a conflict with foo.x86_64.1.0.0@1", err)
+	}
+	ps.Conflicts = []string{"foo.x86_64 >=2.0", "bar.x86_64"}
+	if err := resolveConflicts(ps, &state); err != nil {
+		t.Errorf("resolveConflicts without conflicting packages installed: %v", err)
+	}
 }
 
 func TestNeedsInstallation(t *testing.T) {
@@ -185,8 +250,9 @@ func TestInstallPkg(t *testing.T) {
 		log.Fatal(err)
 	}
 
-	ps := goolib.PkgSpec{Files: map[string]string{"./": dst}}
-	got, err := installPkg(f.Name(), &ps, false)
+	ps := goolib.PkgSpec{Files: map[string]string{"./": dst}, FileAttributes: map[string]goolib.FileAttributes{"test2": {Mode: "0600"}}}
+	st, err := installPkg(context.Background(), f.Name(), &ps, nil, false, false)
+	got, dirs := st.InstalledFiles, st.CreatedDirs
 	if err != nil {
 		t.Fatalf("Error running installPkg: %v", err)
 	}
@@ -194,6 +260,9 @@ func TestInstallPkg(t *testing.T) {
 	if !reflect.DeepEqual(got, want) {
 		t.Fatalf("installPkg did not return expected file list, got: %+v, want: %+v", got, want)
 	}
+	if len(dirs) == 0 || dirs[len(dirs)-1] != dst {
+		t.Errorf("installPkg did not report %q as created, got: %v", dst, dirs)
+	}
 
 	for _, n := range files {
 		want := filepath.Join(dst, n)
@@ -201,6 +270,66 @@ func TestInstallPkg(t *testing.T) {
 			t.Errorf("Expected test file %s does not exist", want)
 		}
 	}
+	if runtime.GOOS != "windows" {
+		fi, err := oswrap.Stat(filepath.Join(dst, "test2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0600 {
+			t.Errorf("test2 has mode %v, want the declared -rw-------", fi.Mode().Perm())
+		}
+	}
+}
+
+func TestInstallPkgManifest(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+
+	// writePkg writes a package of foo/bar.txt with an embedded manifest
+	// claiming checksum for it.
+	writePkg := func(name, checksum string) string {
+		path := filepath.Join(tempDir, name+".goo")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		gw := gzip.NewWriter(f)
+		tw := tar.NewWriter(gw)
+		if err := tw.WriteHeader(&tar.Header{Name: "foo/bar.txt", Typeflag: tar.TypeReg, Size: 3, Mode: 0644}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte("bar"))
+		if _, err := goolib.WritePackageManifest(tw, &goolib.PkgSpec{Name: "test"}, []goolib.ManifestEntry{{Path: "foo/bar.txt", Size: 3, Checksum: checksum}}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Close()
+		gw.Close()
+		return path
+	}
+
+	barSum := goolib.Checksum(strings.NewReader("bar"))
+	dst := filepath.Join(tempDir, "dst")
+	ps := goolib.PkgSpec{Name: "test", Files: map[string]string{"foo": dst}}
+	st, err := installPkg(context.Background(), writePkg("good", barSum), &ps, nil, false, false)
+	if err != nil {
+		t.Fatalf("Error running installPkg: %v", err)
+	}
+	files := st.InstalledFiles
+	installed := filepath.Join(dst, "bar.txt")
+	if len(st.ManifestDigest) != 64 {
+		t.Errorf("installPkg recorded manifest digest %q, want a SHA-256 checksum", st.ManifestDigest)
+	}
+	if files[installed] != barSum {
+		t.Errorf("installPkg recorded checksum %q for %s, want %q", files[installed], installed, barSum)
+	}
+
+	if _, err := installPkg(context.Background(), writePkg("bad", "0000"), &ps, nil, false, false); err == nil {
+		t.Error("installPkg of a file not matching the package manifest did not fail")
+	}
 }
 
 func TestCleanOldFiles(t *testing.T) {
@@ -255,6 +384,36 @@ func TestCleanOldFiles(t *testing.T) {
 	}
 }
 
+func TestReplacedFiles(t *testing.T) {
+	dir := t.TempDir()
+	dst := t.TempDir()
+	for _, n := range []string{"bin/foo.exe", "bin/new.dll", "bin/sub/bar.dll"} {
+		p := filepath.Join(dir, "pkg", filepath.FromSlash(n))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte{}, 0666); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	for _, n := range []string{"foo.exe", "sub/bar.dll", "other.dll"} {
+		p := filepath.Join(dst, filepath.FromSlash(n))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte{}, 0666); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	ps := &goolib.PkgSpec{Files: map[string]string{"pkg/bin": dst}}
+	got := replacedFiles(ps, dir)
+	want := []string{filepath.Join(dst, "foo.exe"), filepath.Join(dst, "sub", "bar.dll")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replacedFiles = %v, want %v", got, want)
+	}
+}
+
 func TestResolveDst(t *testing.T) {
 	if err := os.Setenv("foo", "bar"); err != nil {
 		t.Errorf("error setting environment variable: %v", err)
@@ -274,3 +433,230 @@ func TestResolveDst(t *testing.T) {
 		}
 	}
 }
+
+func TestResolveEntry(t *testing.T) {
+	if err := os.Setenv("foo", "bar"); err != nil {
+		t.Errorf("error setting environment variable: %v", err)
+	}
+
+	ps := &goolib.PkgSpec{Name: "foo", UninstallEntry: &goolib.UninstallEntry{DisplayIcon: "<foo>/foo.exe,0", EstimatedSize: 1024}}
+	got := resolveEntry(ps)
+	if want := "bar/foo.exe,0"; got.UninstallEntry.DisplayIcon != want {
+		t.Errorf("resolveEntry DisplayIcon = %q, want %q", got.UninstallEntry.DisplayIcon, want)
+	}
+	if ps.UninstallEntry.DisplayIcon != "<foo>/foo.exe,0" {
+		t.Errorf("resolveEntry changed the spec: %+v", ps.UninstallEntry)
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(tempDir)
+
+	var pkgs []*googettest.Package
+	for _, ps := range []*goolib.PkgSpec{
+		{Name: "foo", Arch: "noarch", Version: "1.0.0@1", PkgDependencies: map[string]string{"bar": "1.0.0@1", "baz": "1.0.0@1"}},
+		{Name: "bar", Arch: "noarch", Version: "1.0.0@1"},
+		{Name: "baz", Arch: "noarch", Version: "1.0.0@1"},
+	} {
+		p, err := googettest.GenGoo(ps, nil)
+		if err != nil {
+			t.Fatalf("error running GenGoo: %v", err)
+		}
+		pkgs = append(pkgs, p)
+	}
+	const repo = "https://repo.example.com/repo"
+	d := googettest.NewDownloader()
+	if err := d.AddRepo(repo, pkgs...); err != nil {
+		t.Fatal(err)
+	}
+	rm := client.RepoMap{repo: client.Repo{Packages: []goolib.RepoSpec{pkgs[0].RepoSpec(), pkgs[1].RepoSpec(), pkgs[2].RepoSpec()}}}
+	state := client.GooGetState{{PackageSpec: pkgs[2].Spec}}
+	pis := []goolib.PackageInfo{{Name: "foo", Arch: "noarch", Ver: "1.0.0@1"}}
+
+	Prefetch(context.Background(), pis, tempDir, rm, []string{"noarch"}, state, d)
+	if r := d.Requests(); len(r) != 0 {
+		t.Errorf("Prefetch with MaxDownloads 1 downloaded %v", r)
+	}
+
+	defer func(n int) { MaxDownloads = n }(MaxDownloads)
+	MaxDownloads = 2
+	Prefetch(context.Background(), pis, tempDir, rm, []string{"noarch"}, state, d)
+	for i, want := range []bool{true, true, false} {
+		rs := pkgs[i].RepoSpec()
+		if _, err := os.Stat(filepath.Join(tempDir, rs.Checksum+".goo")); (err == nil) != want {
+			t.Errorf("%s cached: %v, want %v", rs.PackageSpec, err == nil, want)
+		}
+	}
+}
+
+func TestFromRepoHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test hook is a shell script")
+	}
+	defer func() { hooks.Commands = nil }()
+	tempDir := t.TempDir()
+	hook := filepath.Join(tempDir, "hook")
+	if err := ioutil.WriteFile(hook, []byte("#!/bin/sh\ncat > \"$0.in\"\necho '{\"Message\": \"no change ticket\"}'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	hooks.Commands = map[string][]string{hooks.PreDownload: {hook}}
+
+	p, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}, nil)
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	const repo = "https://repo.example.com/repo"
+	d := googettest.NewDownloader()
+	if err := d.AddRepo(repo, p); err != nil {
+		t.Fatal(err)
+	}
+	rm := client.RepoMap{repo: client.Repo{Packages: []goolib.RepoSpec{p.RepoSpec()}}}
+	pi := goolib.PackageInfo{Name: "foo", Arch: "noarch", Ver: "1.0.0@1"}
+	err = FromRepo(context.Background(), pi, repo, tempDir, rm, []string{"noarch"}, &client.GooGetState{}, false, d)
+	if err == nil || !strings.Contains(err.Error(), "no change ticket") {
+		t.Errorf("FromRepo denied by a hook returned %v, want the hook's message", err)
+	}
+	if r := d.Requests(); len(r) != 0 {
+		t.Errorf("FromRepo denied by a hook downloaded %v", r)
+	}
+	b, err := ioutil.ReadFile(hook + ".in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req hooks.Request
+	if err := json.Unmarshal(b, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Hook != hooks.PreDownload || req.Package != "foo.noarch.1.0.0@1" || req.Repo != repo || !strings.HasSuffix(req.URL, p.RepoSpec().Source) {
+		t.Errorf("hook got request %+v", req)
+	}
+}
+
+func TestFromRepoInstallReason(t *testing.T) {
+	bar1, err := googettest.GenGoo(&goolib.PkgSpec{Name: "bar", Arch: "noarch", Version: "1.0.0@1"}, nil)
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	bar2, err := googettest.GenGoo(&goolib.PkgSpec{Name: "bar", Arch: "noarch", Version: "2.0.0@1"}, nil)
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	foo, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1", PkgDependencies: map[string]string{"bar": "1.0.0@1"}}, nil)
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	const repo = "https://repo.example.com/repo"
+	d := googettest.NewDownloader()
+	if err := d.AddRepo(repo, foo, bar1, bar2); err != nil {
+		t.Fatal(err)
+	}
+	tempDir := t.TempDir()
+	state := &client.GooGetState{}
+	// Only one version of bar is available at a time.
+	install := func(rs goolib.RepoSpec, bar *googettest.Package) {
+		rm := client.RepoMap{repo: client.Repo{Packages: []goolib.RepoSpec{foo.RepoSpec(), bar.RepoSpec()}}}
+		pi := goolib.PackageInfo{Name: rs.PackageSpec.Name, Arch: rs.PackageSpec.Arch, Ver: rs.PackageSpec.Version}
+		if err := FromRepo(context.Background(), pi, repo, tempDir, rm, []string{"noarch"}, state, true, d); err != nil {
+			t.Fatalf("FromRepo(%s): %v", rs.PackageSpec, err)
+		}
+	}
+	reasons := func() map[string]string {
+		m := make(map[string]string)
+		for _, ps := range *state {
+			m[ps.PackageSpec.String()] = ps.InstallReason
+		}
+		return m
+	}
+
+	install(foo.RepoSpec(), bar1)
+	want := map[string]string{"foo.noarch.1.0.0@1": client.ReasonExplicit, "bar.noarch.1.0.0@1": client.ReasonDependency}
+	if got := reasons(); !reflect.DeepEqual(got, want) {
+		t.Errorf("install reasons = %v, want %v", got, want)
+	}
+	// Upgrading a dependency keeps it a dependency.
+	install(bar2.RepoSpec(), bar2)
+	want = map[string]string{"foo.noarch.1.0.0@1": client.ReasonExplicit, "bar.noarch.2.0.0@1": client.ReasonDependency}
+	if got := reasons(); !reflect.DeepEqual(got, want) {
+		t.Errorf("install reasons after upgrade = %v, want %v", got, want)
+	}
+}
+
+// fakeMSI records the MSI operations of installs in place of the system.
+type fakeMSI struct {
+	calls []string
+}
+
+func (f *fakeMSI) install(_ context.Context, _ string, m *goolib.MSISpec, repair bool) (string, bool, error) {
+	if repair {
+		f.calls = append(f.calls, "repair "+m.ProductCode)
+	} else {
+		f.calls = append(f.calls, "install "+m.ProductCode)
+	}
+	return m.ProductCode, false, nil
+}
+
+func (f *fakeMSI) uninstall(_ context.Context, _, productCode string, _ *goolib.MSISpec) error {
+	f.calls = append(f.calls, "uninstall "+productCode)
+	return nil
+}
+
+func TestFromRepoMSI(t *testing.T) {
+	const (
+		codeA = "{00000000-0000-0000-0000-00000000000A}"
+		codeB = "{00000000-0000-0000-0000-00000000000B}"
+	)
+	var pkgs []*googettest.Package
+	for _, v := range []struct{ ver, code string }{{"1.0.0@1", codeA}, {"2.0.0@1", codeB}, {"3.0.0@1", codeB}} {
+		p, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: v.ver, MSI: &goolib.MSISpec{Path: "foo.msi", ProductCode: v.code}}, nil)
+		if err != nil {
+			t.Fatalf("error running GenGoo: %v", err)
+		}
+		pkgs = append(pkgs, p)
+	}
+	const repo = "https://repo.example.com/repo"
+	d := googettest.NewDownloader()
+	if err := d.AddRepo(repo, pkgs...); err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeMSI{}
+	defer func(i func(context.Context, string, *goolib.MSISpec, bool) (string, bool, error), u func(context.Context, string, string, *goolib.MSISpec) error) {
+		installMSI, uninstallMSI = i, u
+	}(installMSI, uninstallMSI)
+	installMSI, uninstallMSI = f.install, f.uninstall
+
+	tempDir := t.TempDir()
+	state := &client.GooGetState{}
+	for i, want := range [][]string{
+		{"install " + codeA},
+		// An upgrade to another product uninstalls the old one.
+		{"install " + codeB, "uninstall " + codeA},
+		{"install " + codeB},
+	} {
+		f.calls = nil
+		rs := pkgs[i].RepoSpec()
+		rm := client.RepoMap{repo: client.Repo{Packages: []goolib.RepoSpec{rs}}}
+		pi := goolib.PackageInfo{Name: "foo", Arch: "noarch", Ver: rs.PackageSpec.Version}
+		if err := FromRepo(context.Background(), pi, repo, tempDir, rm, []string{"noarch"}, state, false, d); err != nil {
+			t.Fatalf("FromRepo(%s): %v", pi.Ver, err)
+		}
+		if !reflect.DeepEqual(f.calls, want) {
+			t.Errorf("FromRepo(%s) ran MSI operations %q, want %q", pi.Ver, f.calls, want)
+		}
+		if len(*state) != 1 || (*state)[0].MSIProductCode != rs.PackageSpec.MSI.ProductCode {
+			t.Errorf("after FromRepo(%s) state is %+v, want only foo with product %s", pi.Ver, *state, rs.PackageSpec.MSI.ProductCode)
+		}
+	}
+
+	// A reinstall repairs the product rather than taking it over.
+	f.calls = nil
+	if err := Reinstall(context.Background(), (*state)[0], state, false, d); err != nil {
+		t.Fatalf("Reinstall: %v", err)
+	}
+	if want := []string{"repair " + codeB}; !reflect.DeepEqual(f.calls, want) {
+		t.Errorf("Reinstall ran MSI operations %q, want %q", f.calls, want)
+	}
+}
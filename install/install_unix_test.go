@@ -0,0 +1,65 @@
+//go:build linux || darwin
+// +build linux darwin
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/googettest"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/system"
+)
+
+func TestFromRepoIntegrateFails(t *testing.T) {
+	tempDir := t.TempDir()
+	// The environment file can't be written under a regular file.
+	blocker := filepath.Join(tempDir, "blocker")
+	if err := ioutil.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(f string) { system.EnvFile = f }(system.EnvFile)
+	system.EnvFile = filepath.Join(blocker, "googet.sh")
+
+	dst := filepath.Join(tempDir, "dst")
+	p, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1", Files: map[string]string{"bin": dst}, Env: &goolib.EnvSpec{Vars: map[string]string{"FOO": "bar"}}}, map[string][]byte{"bin/foo": []byte("foo")})
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	const repo = "https://repo.example.com/repo"
+	d := googettest.NewDownloader()
+	if err := d.AddRepo(repo, p); err != nil {
+		t.Fatal(err)
+	}
+	rm := client.RepoMap{repo: client.Repo{Packages: []goolib.RepoSpec{p.RepoSpec()}}}
+	pi := goolib.PackageInfo{Name: "foo", Arch: "noarch", Ver: "1.0.0@1"}
+	state := &client.GooGetState{}
+	if err := FromRepo(context.Background(), pi, repo, tempDir, rm, []string{"noarch"}, state, false, d); err == nil {
+		t.Fatal("FromRepo with the environment failing succeeded")
+	}
+	// The installed files are recorded for remove to undo.
+	ps, err := state.GetPackageState(pi)
+	if err != nil {
+		t.Fatalf("package not recorded after integrating it failed: %v", err)
+	}
+	if _, ok := ps.InstalledFiles[filepath.Join(dst, "foo")]; !ok {
+		t.Errorf("InstalledFiles = %v, want %s", ps.InstalledFiles, filepath.Join(dst, "foo"))
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+)
+
+// Permissions controls the modes of files and directories created when
+// installing packages on Linux and darwin. On Windows modes are not applied
+// and created files inherit the ACL of their parent directory.
+type Permissions struct {
+	// FileMode and DirMode are applied to created files and directories, 0
+	// keeps the default of 0666 and 0777 less the process umask.
+	FileMode, DirMode os.FileMode
+	// Umask is cleared from the applied modes.
+	Umask os.FileMode
+}
+
+// DefaultPermissions is used for packages whose spec does not set FileMode or
+// DirMode.
+var DefaultPermissions Permissions
+
+// permissions returns the permissions for installing ps, modes set in the
+// spec take precedence over DefaultPermissions.
+func permissions(ps *goolib.PkgSpec) (Permissions, error) {
+	p := DefaultPermissions
+	fm, err := goolib.ParseMode(ps.FileMode)
+	if err != nil {
+		return p, err
+	}
+	if fm != 0 {
+		p.FileMode = fm
+	}
+	dm, err := goolib.ParseMode(ps.DirMode)
+	if err != nil {
+		return p, err
+	}
+	if dm != 0 {
+		p.DirMode = dm
+	}
+	return p, nil
+}
+
+func (p Permissions) set() bool {
+	return runtime.GOOS != "windows" && (p.FileMode != 0 || p.DirMode != 0 || p.Umask != 0)
+}
+
+func (p Permissions) file() os.FileMode {
+	if p.FileMode == 0 {
+		return 0666 &^ p.Umask
+	}
+	return p.FileMode &^ p.Umask
+}
+
+func (p Permissions) dir() os.FileMode {
+	if p.DirMode == 0 {
+		return 0777 &^ p.Umask
+	}
+	return p.DirMode &^ p.Umask
+}
+
+// mkdirAll creates dir and any missing parents and returns the directories
+// it created. Created directories get the configured mode while existing
+// directories are left untouched.
+func (p Permissions) mkdirAll(dir string, mode os.FileMode) ([]string, error) {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := oswrap.Stat(d); err == nil || filepath.Dir(d) == d {
+			break
+		}
+		missing = append(missing, d)
+	}
+	if !p.set() {
+		return missing, oswrap.MkdirAll(dir, mode)
+	}
+	if err := oswrap.MkdirAll(dir, p.dir()); err != nil {
+		return nil, err
+	}
+	for _, d := range missing {
+		if err := os.Chmod(d, p.dir()); err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
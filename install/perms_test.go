@@ -0,0 +1,84 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+)
+
+func TestPermissions(t *testing.T) {
+	DefaultPermissions = Permissions{FileMode: 0640, DirMode: 0750, Umask: 0002}
+	defer func() { DefaultPermissions = Permissions{} }()
+
+	p, err := permissions(&goolib.PkgSpec{FileMode: "0604"})
+	if err != nil {
+		t.Fatalf("error running permissions: %v", err)
+	}
+	if p.file() != 0604 {
+		t.Errorf("file mode = %o, want %o", p.file(), 0604)
+	}
+	if p.dir() != 0750 {
+		t.Errorf("dir mode = %o, want %o", p.dir(), 0750)
+	}
+	if _, err := permissions(&goolib.PkgSpec{DirMode: "999"}); err == nil {
+		t.Error("permissions accepted invalid DirMode")
+	}
+}
+
+func TestPermissionsMkdirAll(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("modes are not applied on Windows")
+	}
+	root, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(root)
+	if err := os.Chmod(root, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	p := Permissions{DirMode: 0751}
+	dir := filepath.Join(root, "a", "b")
+	created, err := p.mkdirAll(dir, 0755)
+	if err != nil {
+		t.Fatalf("error running mkdirAll: %v", err)
+	}
+	if len(created) != 2 {
+		t.Errorf("mkdirAll returned %v, want the 2 created directories", created)
+	}
+	for _, d := range []string{filepath.Join(root, "a"), dir} {
+		fi, err := os.Stat(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0751 {
+			t.Errorf("%s has mode %o, want %o", d, fi.Mode().Perm(), 0751)
+		}
+	}
+	fi, err := os.Stat(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0700 {
+		t.Errorf("existing directory mode changed to %o", fi.Mode().Perm())
+	}
+}
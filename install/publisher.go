@@ -0,0 +1,117 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/logger"
+)
+
+// PublisherRule requires packages whose name starts with Prefix to be
+// installed from one of Repos, or a URL below one, or to be signed by one of
+// Publishers.
+type PublisherRule struct {
+	Prefix     string
+	Publishers []string
+	Repos      []string
+}
+
+// PublisherRules are checked before a package is installed, every rule whose
+// prefix matches the package name must be satisfied.
+var PublisherRules []PublisherRule
+
+// PublisherKeys are the keys the embedded signatures of the packages of each
+// publisher are checked against.
+var PublisherKeys map[string]ed25519.PublicKey
+
+// checkPublisher returns an error if the package pkg with spec ps, installed
+// from repo, does not satisfy PublisherRules. repo is empty for local files.
+func checkPublisher(pkg string, ps *goolib.PkgSpec, repo string) error {
+	var publisher string
+	var verifyErr error
+	verified := false
+	for _, r := range PublisherRules {
+		if !strings.HasPrefix(ps.Name, r.Prefix) || repoIn(repo, r.Repos) {
+			continue
+		}
+		if !verified {
+			publisher, verifyErr = verifyPublisher(pkg, ps)
+			verified = true
+		}
+		if verifyErr == nil && goolib.ContainsString(publisher, r.Publishers) {
+			continue
+		}
+		var allowed []string
+		if len(r.Repos) > 0 {
+			allowed = append(allowed, "repos "+strings.Join(r.Repos, ", "))
+		}
+		if len(r.Publishers) > 0 {
+			allowed = append(allowed, "publishers "+strings.Join(r.Publishers, ", "))
+		}
+		msg := fmt.Sprintf("packages starting with %q may only come from %s", r.Prefix, strings.Join(allowed, " or "))
+		if verifyErr != nil {
+			return fmt.Errorf("%s is blocked, %s: %v", ps, msg, verifyErr)
+		}
+		return fmt.Errorf("%s of publisher %q is blocked, %s", ps, publisher, msg)
+	}
+	if verified {
+		logger.Infof("Publisher of %s verified as %q", ps, publisher)
+	}
+	return nil
+}
+
+// verifyPublisher returns the publisher ps declares if the embedded signature
+// of pkg verifies with its key and covers a matching spec.
+func verifyPublisher(pkg string, ps *goolib.PkgSpec) (string, error) {
+	if ps.Publisher == "" {
+		return "", errors.New("package declares no publisher")
+	}
+	key, ok := PublisherKeys[ps.Publisher]
+	if !ok {
+		return "", fmt.Errorf("no key configured for publisher %q", ps.Publisher)
+	}
+	f, err := oswrap.Open(pkg)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	signed, err := goolib.VerifyPackage(f, key)
+	if err != nil {
+		return "", err
+	}
+	if signed.String() != ps.String() || signed.Publisher != ps.Publisher {
+		return "", fmt.Errorf("signed spec %s of publisher %q does not match", signed, signed.Publisher)
+	}
+	return ps.Publisher, nil
+}
+
+// repoIn reports whether repo is one of repos or below one of them.
+func repoIn(repo string, repos []string) bool {
+	if repo == "" {
+		return false
+	}
+	for _, r := range repos {
+		r = strings.TrimSuffix(r, "/")
+		if repo == r || strings.HasPrefix(repo, r+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+// writeTestPackage writes a package of spec to path, signed with key if set.
+func writeTestPackage(t *testing.T, path string, spec *goolib.PkgSpec, key ed25519.PrivateKey) {
+	b, err := goolib.MarshalPackageSpec(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Name: spec.Name + ".pkgspec", Mode: 0644, Size: int64(len(b))}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(b)
+	tw.Close()
+	gw.Close()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if key == nil {
+		f.Write(buf.Bytes())
+		return
+	}
+	if err := goolib.SignPackage(f, &buf, key); err != nil {
+		t.Fatalf("error signing package: %v", err)
+	}
+}
+
+func TestCheckPublisher(t *testing.T) {
+	defer func() { PublisherRules, PublisherKeys = nil, nil }()
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	_, other, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+
+	spec := &goolib.PkgSpec{Name: "google-compute-agent", Version: "1.0.0@1", Arch: "noarch", Publisher: "google"}
+	signed := filepath.Join(tempDir, "signed.goo")
+	writeTestPackage(t, signed, spec, priv)
+	forged := filepath.Join(tempDir, "forged.goo")
+	writeTestPackage(t, forged, spec, other)
+	unsigned := filepath.Join(tempDir, "unsigned.goo")
+	writeTestPackage(t, unsigned, spec, nil)
+
+	if err := checkPublisher(unsigned, spec, ""); err != nil {
+		t.Errorf("checkPublisher without rules: %v", err)
+	}
+
+	PublisherKeys = map[string]ed25519.PublicKey{"google": pub}
+	PublisherRules = []PublisherRule{
+		{Prefix: "google-compute-", Publishers: []string{"google"}, Repos: []string{"https://packages.example.com/repos/stable/"}},
+		{Prefix: "other-", Repos: []string{"https://other.example.com"}},
+	}
+	table := []struct {
+		desc    string
+		pkg     string
+		spec    *goolib.PkgSpec
+		repo    string
+		wantErr bool
+	}{
+		{"signed by the publisher", signed, spec, "https://mirror.example.com/repo", false},
+		{"from an allowed repo", unsigned, spec, "https://packages.example.com/repos/stable", false},
+		{"from below an allowed repo", unsigned, spec, "https://packages.example.com/repos/stable/x86_64", false},
+		{"from another repo", unsigned, spec, "https://packages.example.com/repos/stable-old", true},
+		{"local and unsigned", unsigned, spec, "", true},
+		{"signed with another key", forged, spec, "", true},
+		{"spec differs from the signed one", signed, &goolib.PkgSpec{Name: "google-compute-agent", Version: "2.0.0@1", Arch: "noarch", Publisher: "google"}, "", true},
+		{"publisher without a key", signed, &goolib.PkgSpec{Name: "google-compute-agent", Version: "1.0.0@1", Arch: "noarch", Publisher: "evil"}, "", true},
+		{"no matching rule", unsigned, &goolib.PkgSpec{Name: "foo", Version: "1.0.0@1", Arch: "noarch"}, "", false},
+		{"no allowed publisher", signed, &goolib.PkgSpec{Name: "other-agent", Version: "1.0.0@1", Arch: "noarch", Publisher: "google"}, "", true},
+	}
+	for _, tt := range table {
+		if err := checkPublisher(tt.pkg, tt.spec, tt.repo); (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkPublisher returned %v, want error: %t", tt.desc, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,643 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+// Dependencies are resolved by a backtracking search over every version of
+// every package that can meet them, in order of preference: installed
+// versions, then by arch, repo priority and version. A choice that leads to
+// a dependency that can't be met, a conflict, or an installed package
+// depending on an older version is undone and the next candidate tried, so
+// the packages installed are consistent with each other and with those
+// already installed. ResolveStrategy changes the order candidates are tried
+// in, and whether the next one is. When nothing works, the dependencies no
+// candidate could be chosen for are reported, the deepest in the search
+// first. Packages don't declare names they provide besides their own.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/priority"
+	"github.com/google/logger"
+)
+
+// maxResolveSteps bounds the candidates tried to resolve the dependencies of
+// a package, as the search can take exponential time on contrived repos.
+const maxResolveSteps = 10000
+
+var errResolveSteps = fmt.Errorf("gave up resolving dependencies after trying %d candidates", maxResolveSteps)
+
+// maxDeadEnds bounds the other dead ends named by resolve errors.
+const maxDeadEnds = 10
+
+// Names of the rules of a Strategy.
+const (
+	PreferPriority      = "prefer-highest-priority-repo"
+	PreferInstalledArch = "prefer-installed-arch"
+	FailFast            = "fail-fast"
+	Interactive         = "interactive"
+)
+
+// Strategy is how dependencies met by several packages are resolved.
+type Strategy struct {
+	// PreferPriority tries the packages of higher priority repos first,
+	// whatever their arch, instead of trying the preferred arch first.
+	PreferPriority bool
+	// PreferInstalledArch tries the arch of an installed package of the
+	// same name first.
+	PreferInstalledArch bool
+	// FailFast fails on the first candidate that can't be installed
+	// instead of backtracking to the next.
+	FailFast bool
+	// Interactive lets Choose pick between the packages or repos that can
+	// meet a dependency.
+	Interactive bool
+}
+
+// ResolveStrategy is the Strategy dependencies are resolved with.
+var ResolveStrategy Strategy
+
+// Choose is called by Interactive strategies with a dependency and the
+// candidates for it, in order of preference, and returns the index of the
+// one to install.
+var Choose func(dep string, choices []string) int
+
+// ParseStrategy parses a comma separated list of rule names.
+func ParseStrategy(s string) (Strategy, error) {
+	var st Strategy
+	for _, n := range strings.Split(s, ",") {
+		switch strings.TrimSpace(strings.ToLower(n)) {
+		case "":
+		case PreferPriority:
+			st.PreferPriority = true
+		case PreferInstalledArch:
+			st.PreferInstalledArch = true
+		case FailFast:
+			st.FailFast = true
+		case Interactive:
+			st.Interactive = true
+		default:
+			return Strategy{}, fmt.Errorf("unknown resolve strategy %q, want %s, %s, %s or %s", n, PreferPriority, PreferInstalledArch, FailFast, Interactive)
+		}
+	}
+	return st, nil
+}
+
+var (
+	// choices remembers what Choose returned for a set of choices, as
+	// installs resolve the same dependencies more than once.
+	choicesMu sync.Mutex
+	choices   = make(map[string]string)
+)
+
+// candidate is a version of a package from repo, or an installed package if
+// repo is empty, meeting a dependency of the package by.
+type candidate struct {
+	spec *goolib.PkgSpec
+	repo string
+	by   string
+	// arch is the rank of the arch of spec in the archs of the resolver.
+	arch int
+	// chosenBy is the rule that chose c, if not the order of candidates.
+	chosenBy string
+}
+
+func (c candidate) key() string {
+	return c.spec.Name + "." + c.spec.Arch
+}
+
+func (c candidate) String() string {
+	if c.repo == "" {
+		return "installed " + c.spec.String()
+	}
+	return c.spec.String()
+}
+
+// requirement is a dependency of by on pi, with the version constraint c.
+type requirement struct {
+	pi goolib.PackageInfo
+	c  goolib.Constraint
+	by string
+}
+
+func (r requirement) String() string {
+	name := r.pi.Name
+	if r.pi.Arch != "" {
+		name += "." + r.pi.Arch
+	}
+	if s := r.c.String(); s != "" {
+		return name + " " + s
+	}
+	return name
+}
+
+// meets reports whether spec meets r.
+func (r requirement) meets(spec *goolib.PkgSpec) bool {
+	if spec.Name != r.pi.Name || (r.pi.Arch != "" && spec.Arch != r.pi.Arch) {
+		return false
+	}
+	ok, err := r.c.Check(spec.Version)
+	return err == nil && ok
+}
+
+// requirements returns the dependencies of spec, sorted by name.
+func requirements(spec *goolib.PkgSpec) ([]requirement, error) {
+	var reqs []requirement
+	for p, ver := range spec.Dependencies() {
+		c, err := goolib.ParseConstraint(ver)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q of dependency %s of %s: %v", ver, p, spec, err)
+		}
+		reqs = append(reqs, requirement{pi: goolib.PkgNameSplit(p), c: c, by: spec.String()})
+	}
+	sort.Slice(reqs, func(i, j int) bool { return reqs[i].String() < reqs[j].String() })
+	return reqs, nil
+}
+
+// relMatches reports whether rel, an entry of the Conflicts or Replaces of
+// a package, matches spec.
+func relMatches(rel string, spec *goolib.PkgSpec) (bool, error) {
+	pi, err := goolib.SplitConstraint(rel)
+	if err != nil {
+		return false, err
+	}
+	if spec.Name != pi.Name || (pi.Arch != "" && spec.Arch != pi.Arch) {
+		return false, nil
+	}
+	c, err := goolib.ParseConstraint(pi.Ver)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(spec.Version)
+}
+
+// anyMatches returns the entry of rels matching spec, if any.
+func anyMatches(rels []string, spec *goolib.PkgSpec) (string, bool) {
+	for _, rel := range rels {
+		if ok, err := relMatches(rel, spec); err == nil && ok {
+			return rel, true
+		}
+	}
+	return "", false
+}
+
+// resolution maps name.arch to the package chosen to install, or the
+// installed package kept, to meet a dependency.
+type resolution map[string]candidate
+
+// find returns the package of res meeting req.
+func (res resolution) find(req requirement) (candidate, bool) {
+	for _, k := range res.keys() {
+		if req.meets(res[k].spec) {
+			return res[k], true
+		}
+	}
+	return candidate{}, false
+}
+
+func (res resolution) keys() []string {
+	var ks []string
+	for k := range res {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+// resolveError explains why no candidate meets req.
+type resolveError struct {
+	req     requirement
+	reasons []string
+}
+
+func (e *resolveError) Error() string {
+	if len(e.reasons) == 0 {
+		return fmt.Sprintf("cannot resolve dependency, %s needed by %s is not installed and not available in any repo", e.req, e.req.by)
+	}
+	return fmt.Sprintf("cannot resolve dependency, %s needed by %s: %s", e.req, e.req.by, strings.Join(e.reasons, "; "))
+}
+
+// deadEnd is a dependency no candidate could be chosen for, with the number
+// of packages chosen when it was found.
+type deadEnd struct {
+	err   *resolveError
+	depth int
+}
+
+type resolver struct {
+	rm       client.RepoMap
+	archs    []string
+	state    client.GooGetState
+	strategy Strategy
+	chosen   resolution
+	steps    int
+	// deadEnds are the distinct dead ends of the search.
+	deadEnds []deadEnd
+}
+
+// resolve chooses the packages to install or keep to meet the dependencies
+// of rs from repo, rs included.
+func resolve(rs goolib.RepoSpec, repo string, rm client.RepoMap, archs []string, state client.GooGetState) (resolution, error) {
+	r := &resolver{rm: rm, archs: archs, state: state, strategy: ResolveStrategy, chosen: make(resolution)}
+	root := candidate{spec: rs.PackageSpec, repo: repo}
+	if why := r.reject(root); why != "" {
+		return nil, fmt.Errorf("cannot install %s, it %s", root.spec, why)
+	}
+	reqs, err := requirements(root.spec)
+	if err != nil {
+		return nil, err
+	}
+	r.chosen[root.key()] = root
+	if err := r.satisfy(reqs, func() error { return nil }); err != nil {
+		if err == errResolveSteps || len(r.deadEnds) == 0 {
+			return nil, err
+		}
+		return nil, r.explain()
+	}
+	return r.chosen, nil
+}
+
+// explain returns the error of the deepest dead end, naming the others.
+func (r *resolver) explain() error {
+	sort.SliceStable(r.deadEnds, func(i, j int) bool { return r.deadEnds[i].depth > r.deadEnds[j].depth })
+	if len(r.deadEnds) == 1 {
+		return r.deadEnds[0].err
+	}
+	var others []string
+	for i, d := range r.deadEnds[1:] {
+		if i == maxDeadEnds {
+			others = append(others, fmt.Sprintf("%d more", len(r.deadEnds)-1-i))
+			break
+		}
+		others = append(others, fmt.Sprintf("%s needed by %s", d.err.req, d.err.req.by))
+	}
+	return fmt.Errorf("%v; other choices failed on %s", r.deadEnds[0].err, strings.Join(others, ", "))
+}
+
+// deadEnd records e, found with the packages chosen so far.
+func (r *resolver) deadEnd(e *resolveError) {
+	msg := e.Error()
+	for _, d := range r.deadEnds {
+		if d.err.Error() == msg {
+			return
+		}
+	}
+	r.deadEnds = append(r.deadEnds, deadEnd{err: e, depth: len(r.chosen)})
+}
+
+// satisfy chooses packages meeting reqs and their dependencies and then
+// calls next, backtracking to the next candidate while next fails.
+func (r *resolver) satisfy(reqs []requirement, next func() error) error {
+	if len(reqs) == 0 {
+		return next()
+	}
+	req := reqs[0]
+	rest := func() error { return r.satisfy(reqs[1:], next) }
+	if _, ok := r.chosen.find(req); ok {
+		return rest()
+	}
+
+	cands := r.candidates(req)
+	// A range can't be checked against the unrelated versions of the new
+	// name.
+	if len(cands) == 0 && !req.c.Range() {
+		if n, ok := renamedInstalled(req.pi, r.rm, r.state); ok {
+			// The versions of the old and the new name are unrelated.
+			logger.Warningf("Dependency %s of %s was renamed to %s, which is installed", req.pi.Name, req.by, n)
+			return rest()
+		}
+	}
+	var reasons []string
+	for _, k := range r.chosen.keys() {
+		if o := r.chosen[k]; o.spec.Name == req.pi.Name && (req.pi.Arch == "" || o.spec.Arch == req.pi.Arch) {
+			reasons = append(reasons, r.neededBy(o))
+		}
+	}
+	if r.strategy.Interactive && Choose != nil {
+		cands = r.choose(req, cands)
+	}
+	// The failures of the dependencies of the candidates tried are dead
+	// ends of their own.
+	tried := false
+	for i, c := range cands {
+		if r.steps++; r.steps > maxResolveSteps {
+			return errResolveSteps
+		}
+		if i > 0 && r.strategy.FailFast {
+			break
+		}
+		if why := r.reject(c); why != "" {
+			reasons = append(reasons, c.String()+" "+why)
+			continue
+		}
+		if why := r.downgrades(c); why != "" {
+			reasons = append(reasons, c.String()+" "+why)
+			continue
+		}
+		var deps []requirement
+		if c.repo != "" {
+			var err error
+			if deps, err = requirements(c.spec); err != nil {
+				reasons = append(reasons, err.Error())
+				continue
+			}
+		}
+		c.by = req.by
+		logger.Infof("Chose %s for %s needed by %s: %s", c, req, req.by, r.rule(c, cands, i))
+		tried = true
+		r.chosen[c.key()] = c
+		err := r.satisfy(deps, rest)
+		if err == nil {
+			return nil
+		}
+		delete(r.chosen, c.key())
+		if err == errResolveSteps {
+			return err
+		}
+	}
+	e := &resolveError{req: req, reasons: reasons}
+	if !tried {
+		r.deadEnd(e)
+	}
+	return e
+}
+
+// candidates returns the packages that can meet req: the installed ones,
+// then those in the repos by arch, priority and version, or as the strategy
+// orders them.
+func (r *resolver) candidates(req requirement) []candidate {
+	var cands []candidate
+	for _, ps := range r.state {
+		if req.meets(ps.PackageSpec) {
+			cands = append(cands, candidate{spec: ps.PackageSpec})
+		}
+	}
+	archs := r.archs
+	if req.pi.Arch != "" {
+		archs = []string{req.pi.Arch}
+	}
+	var rc []candidate
+	for i, a := range archs {
+		for repo, rp := range r.rm {
+			for _, p := range rp.Packages {
+				if p.Status == goolib.StatusYanked || p.PackageSpec.Arch != a || !req.meets(p.PackageSpec) {
+					continue
+				}
+				rc = append(rc, candidate{spec: p.PackageSpec, repo: repo, arch: i})
+			}
+		}
+	}
+	installedArch := r.installedArch(req.pi.Name)
+	sort.SliceStable(rc, func(i, j int) bool {
+		a, b := rc[i], rc[j]
+		if r.strategy.PreferInstalledArch && installedArch != "" && (a.spec.Arch == installedArch) != (b.spec.Arch == installedArch) {
+			return a.spec.Arch == installedArch
+		}
+		if r.strategy.PreferPriority && r.priority(a) != r.priority(b) {
+			return r.priority(a) > r.priority(b)
+		}
+		if a.arch != b.arch {
+			return a.arch < b.arch
+		}
+		c, err := goolib.ComparePriorityVersion(r.priority(a), a.spec.Version, r.priority(b), b.spec.Version)
+		if err != nil || c == 0 {
+			return a.repo < b.repo
+		}
+		return c > 0
+	})
+	seen := make(map[string]bool)
+	for _, c := range rc {
+		// Repos mirroring a version add nothing to try.
+		if k := c.key() + "." + c.spec.Version; !seen[k] {
+			seen[k] = true
+			cands = append(cands, c)
+		}
+	}
+	return cands
+}
+
+func (r *resolver) priority(c candidate) priority.Value {
+	return r.rm[c.repo].Priority
+}
+
+// installedArch returns the arch of an installed package named name.
+func (r *resolver) installedArch(name string) string {
+	for _, ps := range r.state {
+		if ps.PackageSpec.Name == name {
+			return ps.PackageSpec.Arch
+		}
+	}
+	return ""
+}
+
+// rule returns the rule that decided c, the candidate i of cands, over the
+// others.
+func (r *resolver) rule(c candidate, cands []candidate, i int) string {
+	switch {
+	case c.chosenBy != "":
+		return c.chosenBy
+	case i > 0:
+		return fmt.Sprintf("fallback after %d preferred candidates", i)
+	case c.repo == "":
+		return "installed version kept"
+	}
+	for _, o := range cands[1:] {
+		if o.repo == "" || o.key() == c.key() && o.spec.Version == c.spec.Version {
+			continue
+		}
+		switch {
+		case o.spec.Arch != c.spec.Arch && r.strategy.PreferInstalledArch && c.spec.Arch == r.installedArch(c.spec.Name):
+			return PreferInstalledArch
+		case o.spec.Arch != c.spec.Arch && r.strategy.PreferPriority && r.priority(c) != r.priority(o):
+			return PreferPriority
+		case o.spec.Arch != c.spec.Arch:
+			return "preferred arch"
+		case r.priority(c) != r.priority(o):
+			return "highest priority repo"
+		}
+		return "latest version"
+	}
+	return "only candidate"
+}
+
+// choose returns cands with the one picked by Choose, among the first of
+// each package and repo that can be installed, first. Only that one is tried.
+func (r *resolver) choose(req requirement, cands []candidate) []candidate {
+	var ok []candidate
+	var labels []string
+	seen := make(map[string]bool)
+	for _, c := range cands {
+		if c.repo == "" {
+			// Keeping an installed package needs no choice.
+			if r.reject(c) == "" {
+				return cands
+			}
+			continue
+		}
+		k := c.key() + " " + c.repo
+		if seen[k] || r.reject(c) != "" {
+			continue
+		}
+		seen[k] = true
+		ok = append(ok, c)
+		labels = append(labels, fmt.Sprintf("%s from %s", c.spec, c.repo))
+	}
+	if len(ok) < 2 {
+		return cands
+	}
+	dep := fmt.Sprintf("%s needed by %s", req, req.by)
+	key := dep + "\n" + strings.Join(labels, "\n")
+	choicesMu.Lock()
+	defer choicesMu.Unlock()
+	label, found := choices[key]
+	if !found {
+		if i := Choose(dep, labels); i >= 0 && i < len(labels) {
+			label = labels[i]
+		} else {
+			label = labels[0]
+		}
+		choices[key] = label
+	}
+	for i, l := range labels {
+		if l == label {
+			c := ok[i]
+			c.chosenBy = Interactive
+			return []candidate{c}
+		}
+	}
+	return cands
+}
+
+// kept returns the installed packages left in place by the packages chosen,
+// which neither upgrade nor replace them.
+func (r *resolver) kept() []*goolib.PkgSpec {
+	var specs []*goolib.PkgSpec
+	for _, ps := range r.state {
+		spec := ps.PackageSpec
+		if _, ok := r.chosen[spec.Name+"."+spec.Arch]; ok {
+			continue
+		}
+		replaced := false
+		for _, k := range r.chosen.keys() {
+			if _, ok := anyMatches(r.chosen[k].spec.Replaces, spec); ok {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			specs = append(specs, spec)
+		}
+	}
+	return specs
+}
+
+// neededBy describes the chosen package o and why it was chosen.
+func (r *resolver) neededBy(o candidate) string {
+	if o.by == "" {
+		return fmt.Sprintf("%s being installed", o)
+	}
+	return fmt.Sprintf("%s needed by %s", o, o.by)
+}
+
+// reject returns why c can't be installed with the packages chosen and
+// those kept, empty if it can.
+func (r *resolver) reject(c candidate) string {
+	if o, ok := r.chosen[c.key()]; ok {
+		return "conflicts with " + r.neededBy(o)
+	}
+	for _, k := range r.chosen.keys() {
+		o := r.chosen[k]
+		if _, ok := anyMatches(c.spec.Conflicts, o.spec); ok {
+			return fmt.Sprintf("conflicts with %s", o)
+		}
+		if _, ok := anyMatches(o.spec.Conflicts, c.spec); ok {
+			return fmt.Sprintf("conflicts with %s", o)
+		}
+		if _, ok := anyMatches(c.spec.Replaces, o.spec); ok && o.by != "" {
+			return fmt.Sprintf("replaces %s needed by %s", o, o.by)
+		}
+		if _, ok := anyMatches(o.spec.Replaces, c.spec); ok {
+			return fmt.Sprintf("is replaced by %s", o)
+		}
+	}
+	if c.repo == "" {
+		return ""
+	}
+	for _, spec := range r.kept() {
+		if spec.Name == c.spec.Name && spec.Arch == c.spec.Arch {
+			continue
+		}
+		if _, ok := anyMatches(c.spec.Conflicts, spec); ok {
+			return fmt.Sprintf("conflicts with installed %s", spec)
+		}
+		if _, ok := anyMatches(spec.Conflicts, c.spec); ok {
+			return fmt.Sprintf("conflicts with installed %s", spec)
+		}
+		if why := r.breaks(c, spec); why != "" {
+			return why
+		}
+	}
+	return ""
+}
+
+// downgrades returns why c, chosen for a dependency, would downgrade the
+// installed package of c, empty if it would not. Dependencies never
+// downgrade, the installed version not meeting a range is an error.
+func (r *resolver) downgrades(c candidate) string {
+	if c.repo == "" {
+		return ""
+	}
+	for _, ps := range r.state {
+		spec := ps.PackageSpec
+		if spec.Name != c.spec.Name || spec.Arch != c.spec.Arch {
+			continue
+		}
+		if cmp, err := goolib.Compare(c.spec.Version, spec.Version); err == nil && cmp < 0 {
+			return fmt.Sprintf("would downgrade installed %s", spec)
+		}
+	}
+	return ""
+}
+
+// breaks returns why upgrading or downgrading the installed package of c to
+// c breaks the dependencies of the installed package spec, empty if it does
+// not.
+func (r *resolver) breaks(c candidate, spec *goolib.PkgSpec) string {
+	installed := false
+	for _, ps := range r.state {
+		if ps.PackageSpec.Name == c.spec.Name && ps.PackageSpec.Arch == c.spec.Arch {
+			installed = true
+		}
+	}
+	if !installed {
+		return ""
+	}
+	reqs, err := requirements(spec)
+	if err != nil {
+		return ""
+	}
+	for _, req := range reqs {
+		if req.pi.Name != c.spec.Name || (req.pi.Arch != "" && req.pi.Arch != c.spec.Arch) {
+			continue
+		}
+		if !req.meets(c.spec) {
+			return fmt.Sprintf("breaks installed %s, which needs %s", spec, req)
+		}
+	}
+	return ""
+}
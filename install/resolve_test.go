@@ -0,0 +1,255 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/priority"
+)
+
+func repoSpecs(specs ...*goolib.PkgSpec) []goolib.RepoSpec {
+	var rs []goolib.RepoSpec
+	for _, s := range specs {
+		rs = append(rs, goolib.RepoSpec{PackageSpec: s})
+	}
+	return rs
+}
+
+// chosen returns the packages of res to install, sorted.
+func chosen(res resolution) []string {
+	var l []string
+	for _, c := range res {
+		if c.repo != "" {
+			l = append(l, c.spec.String()+" "+c.repo)
+		}
+	}
+	sort.Strings(l)
+	return l
+}
+
+func TestResolve(t *testing.T) {
+	foo := &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1", PkgDependencies: map[string]string{"bar": "1.0.0", "baz": "1.0.0"}}
+	bar1 := &goolib.PkgSpec{Name: "bar", Arch: "noarch", Version: "1.0.0@1"}
+	bar2 := &goolib.PkgSpec{Name: "bar", Arch: "noarch", Version: "2.0.0@1"}
+	baz := &goolib.PkgSpec{Name: "baz", Arch: "noarch", Version: "1.0.0@1", PkgDependencies: map[string]string{"bar": "<2.0.0"}}
+	qux := &goolib.PkgSpec{Name: "qux", Arch: "noarch", Version: "1.0.0@1", Conflicts: []string{"bar >= 2.0.0"}}
+	baz2 := &goolib.PkgSpec{Name: "baz", Arch: "noarch", Version: "2.0.0@1", PkgDependencies: map[string]string{"qux": "1.0.0"}}
+
+	for _, tt := range []struct {
+		desc  string
+		rm    client.RepoMap
+		state client.GooGetState
+		want  []string
+		err   string
+	}{
+		{
+			desc: "latest versions",
+			rm:   client.RepoMap{"a": {Packages: repoSpecs(foo, bar1, bar2, &goolib.PkgSpec{Name: "baz", Arch: "noarch", Version: "1.0.0@1"})}},
+			want: []string{"bar.noarch.2.0.0@1 a", "baz.noarch.1.0.0@1 a", "foo.noarch.1.0.0@1 a"},
+		},
+		{
+			desc: "backtracks to an older version meeting a later dependency",
+			rm:   client.RepoMap{"a": {Packages: repoSpecs(foo, bar1, bar2, baz)}},
+			want: []string{"bar.noarch.1.0.0@1 a", "baz.noarch.1.0.0@1 a", "foo.noarch.1.0.0@1 a"},
+		},
+		{
+			desc: "backtracks on conflicts",
+			rm:   client.RepoMap{"a": {Packages: repoSpecs(foo, bar1, bar2, baz2, qux)}},
+			want: []string{"bar.noarch.1.0.0@1 a", "baz.noarch.2.0.0@1 a", "foo.noarch.1.0.0@1 a", "qux.noarch.1.0.0@1 a"},
+		},
+		{
+			desc: "repo priority before version",
+			rm: client.RepoMap{
+				"a": {Priority: priority.Default, Packages: repoSpecs(foo, bar2, baz)},
+				"b": {Priority: priority.Canary, Packages: repoSpecs(bar1)},
+			},
+			want: []string{"bar.noarch.1.0.0@1 b", "baz.noarch.1.0.0@1 a", "foo.noarch.1.0.0@1 a"},
+		},
+		{
+			desc:  "installed versions are kept",
+			rm:    client.RepoMap{"a": {Packages: repoSpecs(foo, bar1, bar2, baz)}},
+			state: client.GooGetState{{PackageSpec: bar1}},
+			want:  []string{"baz.noarch.1.0.0@1 a", "foo.noarch.1.0.0@1 a"},
+		},
+		{
+			desc: "no solution",
+			rm:   client.RepoMap{"a": {Packages: repoSpecs(foo, bar2, baz)}},
+			err:  "bar <2.0.0 needed by baz.noarch.1.0.0@1: bar.noarch.2.0.0@1 needed by foo.noarch.1.0.0@1",
+		},
+		{
+			desc:  "installed packages needing an older version",
+			rm:    client.RepoMap{"a": {Packages: repoSpecs(foo, bar2, &goolib.PkgSpec{Name: "baz", Arch: "noarch", Version: "1.0.0@1"})}},
+			state: client.GooGetState{{PackageSpec: bar1}, {PackageSpec: &goolib.PkgSpec{Name: "old", Arch: "noarch", Version: "1.0.0@1", PkgDependencies: map[string]string{"bar": "<2.0.0"}}}},
+			want:  []string{"baz.noarch.1.0.0@1 a", "foo.noarch.1.0.0@1 a"},
+		},
+		{
+			desc:  "installed conflict",
+			rm:    client.RepoMap{"a": {Packages: repoSpecs(foo, bar1, &goolib.PkgSpec{Name: "baz", Arch: "noarch", Version: "1.0.0@1"})}},
+			state: client.GooGetState{{PackageSpec: &goolib.PkgSpec{Name: "old", Arch: "noarch", Version: "1.0.0@1", Conflicts: []string{"baz"}}}},
+			err:   "baz.noarch.1.0.0@1 conflicts with installed old.noarch.1.0.0@1",
+		},
+		{
+			desc: "deepest dead end first",
+			rm: client.RepoMap{"a": {Packages: repoSpecs(foo, bar1,
+				&goolib.PkgSpec{Name: "bar", Arch: "noarch", Version: "2.0.0@1", PkgDependencies: map[string]string{"missing": ""}},
+				&goolib.PkgSpec{Name: "baz", Arch: "noarch", Version: "1.0.0@1", PkgDependencies: map[string]string{"gone": ""}})}},
+			err: "gone needed by baz.noarch.1.0.0@1 is not installed and not available in any repo; other choices failed on missing needed by bar.noarch.2.0.0@1",
+		},
+		{
+			desc:  "range dependencies don't downgrade",
+			rm:    client.RepoMap{"a": {Packages: repoSpecs(foo, bar1, bar2, baz)}},
+			state: client.GooGetState{{PackageSpec: bar2}},
+			err:   "bar <2.0.0 needed by baz.noarch.1.0.0@1: installed bar.noarch.2.0.0@1 needed by foo.noarch.1.0.0@1",
+		},
+		{
+			desc: "missing dependency",
+			rm:   client.RepoMap{"a": {Packages: repoSpecs(foo, bar1)}},
+			err:  "baz 1.0.0 or greater needed by foo.noarch.1.0.0@1 is not installed and not available in any repo",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			res, err := resolve(goolib.RepoSpec{PackageSpec: foo}, "a", tt.rm, []string{"noarch"}, tt.state)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("resolve returned %v, want an error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, chosen(res)); diff != "" {
+				t.Errorf("resolve unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestListDeps(t *testing.T) {
+	rm := client.RepoMap{"a": {Packages: repoSpecs(
+		&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1", PkgDependencies: map[string]string{"bar": "1.0.0", "baz": "1.0.0"}},
+		&goolib.PkgSpec{Name: "bar", Arch: "noarch", Version: "1.0.0@1"},
+		&goolib.PkgSpec{Name: "baz", Arch: "noarch", Version: "1.0.0@1", PkgDependencies: map[string]string{"bar": "1.0.0"}},
+	)}}
+	dl, err := ListDeps(goolib.PackageInfo{Name: "foo", Arch: "noarch", Ver: "1.0.0@1"}, rm, "a", []string{"noarch"})
+	if err != nil {
+		t.Fatalf("ListDeps: %v", err)
+	}
+	var got []string
+	for _, pi := range dl {
+		got = append(got, pi.Name)
+	}
+	// Each package comes before its dependencies.
+	if want := []string{"foo", "baz", "bar"}; !cmp.Equal(want, got) {
+		t.Errorf("ListDeps = %v, want %v", got, want)
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	got, err := ParseStrategy("prefer-highest-priority-repo, Fail-Fast")
+	if err != nil {
+		t.Fatalf("ParseStrategy: %v", err)
+	}
+	if want := (Strategy{PreferPriority: true, FailFast: true}); got != want {
+		t.Errorf("ParseStrategy = %+v, want %+v", got, want)
+	}
+	if _, err := ParseStrategy("prefer-latest"); err == nil {
+		t.Error("ParseStrategy of an unknown rule returned no error")
+	}
+}
+
+func TestResolveStrategy(t *testing.T) {
+	foo := &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1", PkgDependencies: map[string]string{"bar": "1.0.0", "baz": "1.0.0"}}
+	bar64 := &goolib.PkgSpec{Name: "bar", Arch: "x86_64", Version: "1.0.0@1"}
+	bar32 := &goolib.PkgSpec{Name: "bar", Arch: "x86_32", Version: "1.0.0@1"}
+	bar2 := &goolib.PkgSpec{Name: "bar", Arch: "x86_64", Version: "2.0.0@1"}
+	baz := &goolib.PkgSpec{Name: "baz", Arch: "noarch", Version: "1.0.0@1"}
+	bazOld := &goolib.PkgSpec{Name: "baz", Arch: "noarch", Version: "1.0.0@1", PkgDependencies: map[string]string{"bar": "<2.0.0"}}
+	rm := client.RepoMap{
+		"a": {Priority: priority.Default, Packages: repoSpecs(foo, bar64, baz)},
+		"b": {Priority: priority.Canary, Packages: repoSpecs(bar32)},
+	}
+	defer func() { ResolveStrategy, Choose = Strategy{}, nil }()
+
+	for _, tt := range []struct {
+		desc     string
+		strategy Strategy
+		rm       client.RepoMap
+		state    client.GooGetState
+		choose   int
+		want     []string
+		err      string
+	}{
+		{
+			desc: "arch before repo priority",
+			rm:   rm,
+			want: []string{"bar.x86_64.1.0.0@1 a", "baz.noarch.1.0.0@1 a", "foo.noarch.1.0.0@1 a"},
+		},
+		{
+			desc:     "prefer-highest-priority-repo",
+			strategy: Strategy{PreferPriority: true},
+			rm:       rm,
+			want:     []string{"bar.x86_32.1.0.0@1 b", "baz.noarch.1.0.0@1 a", "foo.noarch.1.0.0@1 a"},
+		},
+		{
+			desc:     "prefer-installed-arch",
+			strategy: Strategy{PreferInstalledArch: true},
+			rm:       rm,
+			state:    client.GooGetState{{PackageSpec: &goolib.PkgSpec{Name: "bar", Arch: "x86_32", Version: "0.1.0@1"}}},
+			want:     []string{"bar.x86_32.1.0.0@1 b", "baz.noarch.1.0.0@1 a", "foo.noarch.1.0.0@1 a"},
+		},
+		{
+			desc:     "fail-fast",
+			strategy: Strategy{FailFast: true},
+			rm:       client.RepoMap{"a": {Packages: repoSpecs(foo, bar64, bar2, bazOld)}},
+			err:      "bar <2.0.0 needed by baz.noarch.1.0.0@1",
+		},
+		{
+			desc:     "interactive",
+			strategy: Strategy{Interactive: true},
+			rm:       rm,
+			choose:   1,
+			want:     []string{"bar.x86_32.1.0.0@1 b", "baz.noarch.1.0.0@1 a", "foo.noarch.1.0.0@1 a"},
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			ResolveStrategy = tt.strategy
+			Choose = func(dep string, choices []string) int {
+				if want := []string{"bar.x86_64.1.0.0@1 from a", "bar.x86_32.1.0.0@1 from b"}; !cmp.Equal(want, choices) {
+					t.Errorf("Choose(%q) choices = %v, want %v", dep, choices, want)
+				}
+				return tt.choose
+			}
+			res, err := resolve(goolib.RepoSpec{PackageSpec: foo}, "a", tt.rm, []string{"x86_64", "x86_32", "noarch"}, tt.state)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("resolve returned %v, want an error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, chosen(res)); diff != "" {
+				t.Errorf("resolve unexpected diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
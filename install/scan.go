@@ -0,0 +1,87 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/events"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/logger"
+)
+
+// ScanCommand, if set, is run with the path of every package appended before
+// the package is extracted, a non-zero exit code blocks the install.
+var ScanCommand []string
+
+// ScanTimeout limits the run time of ScanCommand, 0 means no limit.
+var ScanTimeout time.Duration
+
+// maxScanOutput limits the scanner output kept in the state file.
+const maxScanOutput = 1024
+
+// scan runs ScanCommand on pkg, the package pi from repo, empty for local
+// packages. It returns nil if no scanner is configured and an error if the
+// scanner could not be run or rejected the package. Every scan is recorded
+// as a Scan event, see events.Begin.
+func scan(ctx context.Context, pkg string, pi goolib.PackageInfo, repo string) (*client.ScanResult, error) {
+	if len(ScanCommand) == 0 {
+		return nil, nil
+	}
+	done := events.Begin(events.Scan, pi, repo)
+	res, err := runScanner(ctx, pkg)
+	done(err)
+	return res, err
+}
+
+func runScanner(ctx context.Context, pkg string) (*client.ScanResult, error) {
+	if ScanTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ScanTimeout)
+		defer cancel()
+	}
+	args := append(append([]string{}, ScanCommand[1:]...), pkg)
+	c := exec.CommandContext(ctx, ScanCommand[0], args...)
+	logger.Infof("Scanning %q: %s", pkg, c)
+	start := time.Now()
+	out, err := c.CombinedOutput()
+	res := &client.ScanResult{
+		Time:     start.UTC(),
+		Command:  c.String(),
+		Duration: time.Since(start),
+		Output:   strings.TrimSpace(string(out)),
+	}
+	if len(res.Output) > maxScanOutput {
+		res.Output = res.Output[:maxScanOutput]
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("scan of %s did not finish: %v", pkg, ctx.Err())
+	}
+	if e, ok := err.(*exec.ExitError); ok {
+		res.ExitCode = e.ExitCode()
+	} else if err != nil {
+		return nil, fmt.Errorf("error running scanner on %s: %v", pkg, err)
+	}
+	if res.ExitCode != 0 {
+		logger.Errorf("Scan of %q failed with exit code %d: %s", pkg, res.ExitCode, res.Output)
+		return res, fmt.Errorf("scan of %s failed with exit code %d, not installing", pkg, res.ExitCode)
+	}
+	logger.Infof("Scan of %q passed in %v", pkg, res.Duration)
+	return res, nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/googet/v2/events"
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestScan(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test scanner is a shell script")
+	}
+	defer func() { ScanCommand, ScanTimeout = nil, 0 }()
+	var got []events.Event
+	defer events.Watch(func(e events.Event) { got = append(got, e) })()
+	pi := goolib.PackageInfo{Name: "foo", Arch: "noarch", Ver: "1.0.0@1"}
+
+	if res, err := scan(context.Background(), "foo.goo", pi, "repo"); res != nil || err != nil {
+		t.Errorf("scan without a scanner = %+v, %v, want nil, nil", res, err)
+	}
+	if len(got) != 0 {
+		t.Errorf("scan without a scanner emitted %+v", got)
+	}
+
+	table := []struct {
+		script   string
+		timeout  time.Duration
+		exitCode int
+		output   string
+		wantErr  bool
+	}{
+		{`echo "clean $1"`, 0, 0, "clean foo.goo", false},
+		{`echo "infected $1"; exit 2`, 0, 2, "infected foo.goo", true},
+		{`exec sleep 5`, 10 * time.Millisecond, 0, "", true},
+	}
+	for i, tt := range table {
+		ScanCommand = []string{"sh", "-c", tt.script, "sh"}
+		ScanTimeout = tt.timeout
+		got = nil
+		res, err := scan(context.Background(), "foo.goo", pi, "repo")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%d: scan returned error %v, want error: %t", i, err, tt.wantErr)
+		}
+		wantState := events.Completed
+		if tt.wantErr {
+			wantState = events.Failed
+		}
+		if len(got) != 2 || got[1].Action != events.Scan || got[1].State != wantState || got[1].Package != "foo.noarch.1.0.0@1" || got[1].Repo != "repo" {
+			t.Errorf("%d: scan emitted %+v, want a %s %s event", i, got, events.Scan, wantState)
+		}
+		if res == nil {
+			if tt.output != "" {
+				t.Errorf("%d: scan returned no result", i)
+			}
+			continue
+		}
+		if res.ExitCode != tt.exitCode || res.Output != tt.output {
+			t.Errorf("%d: scan result = %+v, want exit code %d and output %q", i, res, tt.exitCode, tt.output)
+		}
+	}
+
+	ScanCommand, ScanTimeout = []string{"/nonexistent/scanner"}, 0
+	if _, err := scan(context.Background(), "foo.goo", pi, ""); err == nil {
+		t.Error("scan with a missing scanner did not fail")
+	}
+}
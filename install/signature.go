@@ -0,0 +1,160 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/logger"
+)
+
+// SignaturePolicy is what installs do with packages whose embedded signature
+// is missing or does not verify.
+type SignaturePolicy int
+
+const (
+	// SignatureOff does not check signatures.
+	SignatureOff SignaturePolicy = iota
+	// SignatureWarn logs a warning and installs the package.
+	SignatureWarn
+	// SignatureEnforce blocks the install.
+	SignatureEnforce
+)
+
+// ParseSignaturePolicy parses "off", "warn" or "enforce", empty meaning off.
+func ParseSignaturePolicy(s string) (SignaturePolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "off":
+		return SignatureOff, nil
+	case "warn":
+		return SignatureWarn, nil
+	case "enforce":
+		return SignatureEnforce, nil
+	}
+	return SignatureOff, fmt.Errorf("invalid signature policy %q, want off, warn or enforce", s)
+}
+
+// SignatureKey is a public key packages may be signed with, Name identifies
+// it in errors, for example by the file it was read from.
+type SignatureKey struct {
+	Name string
+	Key  ed25519.PublicKey
+}
+
+// Signatures are the keys the embedded signatures of packages are verified
+// with and what to do if none of them verifies.
+type Signatures struct {
+	Policy SignaturePolicy
+	Keys   []SignatureKey
+}
+
+// DefaultSignatures applies to local packages and to the repos not in
+// RepoSignatures.
+var DefaultSignatures Signatures
+
+// RepoSignatures maps repo URLs to the signature settings of their packages,
+// which replace DefaultSignatures.
+var RepoSignatures map[string]Signatures
+
+// Verification holds the signature and cosign settings packages are verified
+// with.
+type Verification struct {
+	Signatures     Signatures
+	RepoSignatures map[string]Signatures
+	Cosign         Cosign
+	RepoCosign     map[string]Cosign
+}
+
+type verificationKey struct{}
+
+// WithVerification returns a copy of ctx with which packages are verified
+// with v instead of DefaultSignatures, RepoSignatures, DefaultCosign and
+// RepoCosign.
+func WithVerification(ctx context.Context, v Verification) context.Context {
+	return context.WithValue(ctx, verificationKey{}, v)
+}
+
+// verification returns the settings packages are verified with under ctx.
+func verification(ctx context.Context) Verification {
+	if v, ok := ctx.Value(verificationKey{}).(Verification); ok {
+		return v
+	}
+	return Verification{Signatures: DefaultSignatures, RepoSignatures: RepoSignatures, Cosign: DefaultCosign, RepoCosign: RepoCosign}
+}
+
+// checkSignature verifies the embedded signature of the package pkg with spec
+// ps, installed from repo, and returns an error if the policy of the repo
+// blocks it. repo is empty for local files.
+func checkSignature(ctx context.Context, pkg string, ps *goolib.PkgSpec, repo string) error {
+	v := verification(ctx)
+	s, ok := v.RepoSignatures[repo]
+	if !ok || repo == "" {
+		s = v.Signatures
+	}
+	if s.Policy == SignatureOff {
+		return nil
+	}
+	name, err := verifySignature(pkg, ps, s.Keys)
+	if err == nil {
+		logger.Infof("Signature of %s verified with key %s", ps, name)
+		return nil
+	}
+	if s.Policy == SignatureWarn {
+		logger.Warningf("%v, installing it anyway", err)
+		return nil
+	}
+	return &goolib.VerificationError{Err: fmt.Errorf("%v, not installing it", err)}
+}
+
+// verifySignature returns the name of the first of keys the embedded
+// signature of pkg verifies with and covers a spec matching ps.
+func verifySignature(pkg string, ps *goolib.PkgSpec, keys []SignatureKey) (string, error) {
+	if len(keys) == 0 {
+		return "", fmt.Errorf("signature of %s can't be verified, no signature keys are configured", ps)
+	}
+	var errs []string
+	for _, k := range keys {
+		err := verifyWith(pkg, ps, k.Key)
+		if err == nil {
+			return k.Name, nil
+		}
+		if errors.Is(err, goolib.ErrUnsigned) {
+			return "", fmt.Errorf("%s is not signed", ps)
+		}
+		errs = append(errs, fmt.Sprintf("key %s: %v", k.Name, err))
+	}
+	return "", fmt.Errorf("signature of %s does not verify, %s", ps, strings.Join(errs, "; "))
+}
+
+func verifyWith(pkg string, ps *goolib.PkgSpec, key ed25519.PublicKey) error {
+	f, err := oswrap.Open(pkg)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	signed, err := goolib.VerifyPackage(f, key)
+	if err != nil {
+		return err
+	}
+	if signed.String() != ps.String() {
+		return fmt.Errorf("signed spec %s does not match", signed)
+	}
+	return nil
+}
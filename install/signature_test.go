@@ -0,0 +1,88 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"context"
+	"crypto/ed25519"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestCheckSignature(t *testing.T) {
+	defer func() { DefaultSignatures, RepoSignatures = Signatures{}, nil }()
+	tempDir := t.TempDir()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	otherPub, other, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+
+	spec := &goolib.PkgSpec{Name: "foo", Version: "1.0.0@1", Arch: "noarch"}
+	signed := filepath.Join(tempDir, "signed.goo")
+	writeTestPackage(t, signed, spec, priv)
+	forged := filepath.Join(tempDir, "forged.goo")
+	writeTestPackage(t, forged, spec, other)
+	unsigned := filepath.Join(tempDir, "unsigned.goo")
+	writeTestPackage(t, unsigned, spec, nil)
+
+	const repo = "https://repo.example.com/googet/stable"
+	key := SignatureKey{"corp.pub", pub}
+	DefaultSignatures = Signatures{Policy: SignatureEnforce, Keys: []SignatureKey{key}}
+	RepoSignatures = map[string]Signatures{
+		repo:                                  {Policy: SignatureEnforce, Keys: []SignatureKey{{"other.pub", otherPub}}},
+		"https://repo.example.com/googet/lab": {Policy: SignatureWarn, Keys: []SignatureKey{key}},
+	}
+
+	for _, tt := range []struct {
+		desc, pkg, repo string
+		// wantErr is a substring of the error, empty if none is wanted.
+		wantErr string
+	}{
+		{"signed", signed, "", ""},
+		{"signed by another key", forged, "", "key corp.pub"},
+		{"unsigned", unsigned, "", "is not signed"},
+		{"signed with the key of its repo", forged, repo, ""},
+		{"signed with the global key from a repo with its own key", signed, repo, "key other.pub"},
+		{"repo without its own settings", forged, "https://other.example.com/repo", "key corp.pub"},
+		{"warn policy", unsigned, "https://repo.example.com/googet/lab", ""},
+	} {
+		err := checkSignature(context.Background(), tt.pkg, spec, tt.repo)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: checkSignature returned %v, want nil", tt.desc, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: checkSignature returned %v, want an error containing %q", tt.desc, err, tt.wantErr)
+		}
+	}
+
+	DefaultSignatures = Signatures{Policy: SignatureEnforce}
+	if err := checkSignature(context.Background(), signed, spec, ""); err == nil || !strings.Contains(err.Error(), "no signature keys") {
+		t.Errorf("checkSignature without keys returned %v, want an error about missing keys", err)
+	}
+	DefaultSignatures = Signatures{}
+	if err := checkSignature(context.Background(), unsigned, spec, ""); err != nil {
+		t.Errorf("checkSignature with the off policy returned %v", err)
+	}
+	ctx := WithVerification(context.Background(), Verification{Signatures: Signatures{Policy: SignatureEnforce}})
+	if err := checkSignature(ctx, unsigned, spec, ""); err == nil {
+		t.Error("checkSignature with the enforce policy of its context returned nil")
+	}
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+// Installs check that the volumes packages are downloaded, extracted and
+// installed to have the space needed before writing anything, rather than
+// fail with a package half installed. The files of packages are known from
+// the cached package or the manifest sidecar in the repo before the download,
+// and from the files extracted from the package after it. The files of the
+// installed version a package replaces count as freed.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/download"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/hooks"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/googet/v2/system"
+	"github.com/google/logger"
+)
+
+// diskSpace is replaced in tests.
+var diskSpace = system.DiskSpace
+
+// spaceNeeds maps directories to the bytes to be written to them, less
+// those freed.
+type spaceNeeds map[string]int64
+
+func (n spaceNeeds) add(dir string, size int64) {
+	if size != 0 {
+		n[dir] += size
+	}
+}
+
+// addFiles adds the files of the package ps to the directories they are
+// installed to.
+func (n spaceNeeds) addFiles(ps *goolib.PkgSpec, files []goolib.ManifestEntry) {
+	for _, e := range files {
+		path := filepath.Clean(filepath.FromSlash(e.Path))
+		for src, dst := range ps.Files {
+			src = filepath.Clean(filepath.FromSlash(src))
+			var rel string
+			switch {
+			case src == ".":
+				rel = path
+			case path == src:
+				// A single file is installed as dst.
+			case strings.HasPrefix(path, src+string(filepath.Separator)):
+				rel = strings.TrimPrefix(path, src+string(filepath.Separator))
+			default:
+				continue
+			}
+			n.add(filepath.Dir(filepath.Join(resolveDst(dst), rel)), e.Size)
+		}
+	}
+}
+
+// freeInstalled subtracts the files of the installed versions of ps, which
+// its install replaces.
+func (n spaceNeeds) freeInstalled(ps *goolib.PkgSpec, state client.GooGetState) {
+	for _, s := range state {
+		if s.PackageSpec.Name != ps.Name || s.PackageSpec.Arch != ps.Arch {
+			continue
+		}
+		for path := range s.InstalledFiles {
+			if fi, err := oswrap.Stat(path); err == nil && fi.Mode().IsRegular() {
+				n.add(filepath.Dir(path), -fi.Size())
+			}
+		}
+	}
+}
+
+// check returns an error if a volume lacks the space needed by the
+// directories on it. Volumes whose free space can't be read are skipped.
+func (n spaceNeeds) check() error {
+	type volume struct {
+		dir  string
+		need int64
+		free uint64
+	}
+	vols := make(map[string]*volume)
+	var dirs []string
+	for d := range n {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+	var ids []string
+	for _, d := range dirs {
+		id, free, err := diskSpace(existingDir(d))
+		if err != nil {
+			logger.Errorf("Error checking the free space of %s: %v", d, err)
+			continue
+		}
+		v, ok := vols[id]
+		if !ok {
+			v = &volume{dir: d, free: free}
+			vols[id] = v
+			ids = append(ids, id)
+		}
+		v.need += n[d]
+	}
+	for _, id := range ids {
+		if v := vols[id]; v.need > 0 && uint64(v.need) > v.free {
+			return fmt.Errorf("not enough disk space on the volume of %s, %s needed but only %s free", v.dir, humanize.IBytes(uint64(v.need)), humanize.IBytes(v.free))
+		}
+	}
+	return nil
+}
+
+// existingDir returns dir or its closest parent that exists.
+func existingDir(dir string) string {
+	for {
+		if _, err := oswrap.Stat(dir); err == nil {
+			return dir
+		}
+		p := filepath.Dir(dir)
+		if p == dir {
+			return dir
+		}
+		dir = p
+	}
+}
+
+func totalSize(files []goolib.ManifestEntry) int64 {
+	var n int64
+	for _, e := range files {
+		n += e.Size
+	}
+	return n
+}
+
+// packageFiles returns the files of the package rs from repo, from its copy
+// in cache or else its manifest sidecar, and the size of its download, 0 if
+// it is cached. The download is estimated at the size of the files. Nothing
+// is fetched from repos when a predownload hook could veto it.
+func packageFiles(ctx context.Context, rs goolib.RepoSpec, repo, cache string, downloader client.Downloader) ([]goolib.ManifestEntry, int64, error) {
+	if dst, err := download.CachePath(cache, rs.BestChecksum()); err == nil {
+		if _, ok := download.Cached(client.PackageState{LocalPath: dst, Checksum: rs.BestChecksum()}); ok {
+			files, err := readFileSizes(dst)
+			return files, 0, err
+		}
+	}
+	if len(hooks.Commands[hooks.PreDownload]) > 0 {
+		return nil, 0, os.ErrNotExist
+	}
+	b, err := download.RepoSidecar(ctx, rs, repo, goolib.ManifestSuffix, downloader)
+	if err != nil {
+		return nil, 0, err
+	}
+	var files []goolib.ManifestEntry
+	if err := json.Unmarshal(b, &files); err != nil {
+		return nil, 0, fmt.Errorf("error parsing manifest sidecar: %v", err)
+	}
+	return files, totalSize(files), nil
+}
+
+func readFileSizes(pkg string) ([]goolib.ManifestEntry, error) {
+	f, err := oswrap.Open(pkg)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return goolib.ReadFileSizes(f)
+}
+
+// preflight returns an error if the volumes of cache and of the files of the
+// packages of res to install lack the space needed to download, extract and
+// install them. Packages whose files are only known once downloaded are left
+// to checkSpace.
+func preflight(ctx context.Context, res resolution, cache string, rm client.RepoMap, state client.GooGetState, dbOnly bool, downloader client.Downloader) error {
+	n := make(spaceNeeds)
+	// Packages are extracted one at a time.
+	var extract int64
+	for _, k := range res.keys() {
+		c := res[k]
+		if c.repo == "" || installedVersion(c.spec, state) {
+			continue
+		}
+		rs, err := client.FindRepoSpec(goolib.PackageInfo{Name: c.spec.Name, Arch: c.spec.Arch, Ver: c.spec.Version}, rm[c.repo])
+		if err != nil {
+			return err
+		}
+		files, size, err := packageFiles(ctx, rs, c.repo, cache, downloader)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				logger.Errorf("Error reading the files of %s: %v", c.spec, err)
+			}
+			logger.Infof("Size of %s unknown until it is downloaded", c.spec)
+			continue
+		}
+		n.add(cache, size)
+		if t := totalSize(files); t > extract {
+			extract = t
+		}
+		if !dbOnly {
+			n.addFiles(c.spec, files)
+			n.freeInstalled(c.spec, state)
+		}
+	}
+	n.add(cache, extract)
+	return n.check()
+}
+
+// checkSpace returns an error if the volumes the package ps extracted to dir
+// is installed to lack the space needed, replacing its versions in state.
+func checkSpace(dir string, ps *goolib.PkgSpec, state client.GooGetState) error {
+	files, err := extractedFiles(dir)
+	if err != nil {
+		return err
+	}
+	n := make(spaceNeeds)
+	n.addFiles(ps, files)
+	n.freeInstalled(ps, state)
+	return n.check()
+}
+
+// extractedFiles returns the paths, relative to dir, and sizes of the regular
+// files extracted to dir.
+func extractedFiles(dir string) ([]goolib.ManifestEntry, error) {
+	var files []goolib.ManifestEntry
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, goolib.ManifestEntry{Path: filepath.ToSlash(rel), Size: fi.Size()})
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/download"
+	"github.com/google/googet/v2/googettest"
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestSpaceNeedsAddFiles(t *testing.T) {
+	ps := &goolib.PkgSpec{Files: map[string]string{"bin": "/opt/foo/bin", "foo.conf": "/etc/foo.conf"}}
+	n := make(spaceNeeds)
+	n.addFiles(ps, []goolib.ManifestEntry{
+		{Path: "bin/foo", Size: 10},
+		{Path: "bin/lib/libfoo", Size: 5},
+		{Path: "bin/bar", Size: 1},
+		{Path: "foo.conf", Size: 3},
+		{Path: "unused", Size: 7},
+	})
+	want := spaceNeeds{"/opt/foo/bin": 11, "/opt/foo/bin/lib": 5, "/etc": 3}
+	if !reflect.DeepEqual(n, want) {
+		t.Errorf("addFiles = %v, want %v", n, want)
+	}
+}
+
+func TestSpaceNeedsCheck(t *testing.T) {
+	defer func(f func(string) (string, uint64, error)) { diskSpace = f }(diskSpace)
+	dir := t.TempDir()
+	diskSpace = func(path string) (string, uint64, error) {
+		if path != dir {
+			t.Errorf("diskSpace(%q) called, want the existing dir %q", path, dir)
+		}
+		return "vol", 100, nil
+	}
+
+	n := spaceNeeds{filepath.Join(dir, "a"): 60, filepath.Join(dir, "b", "c"): 30}
+	if err := n.check(); err != nil {
+		t.Errorf("check of 90 bytes on a volume with 100 free: %v", err)
+	}
+	n[filepath.Join(dir, "b")] = 20
+	if err := n.check(); err == nil || !strings.Contains(err.Error(), "110 B needed but only 100 B free") {
+		t.Errorf("check of 110 bytes on a volume with 100 free returned %v", err)
+	}
+}
+
+func TestCheckSpace(t *testing.T) {
+	defer func(f func(string) (string, uint64, error)) { diskSpace = f }(diskSpace)
+	diskSpace = func(string) (string, uint64, error) { return "vol", 100, nil }
+
+	dir, dst := t.TempDir(), t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "foo"), make([]byte, 150), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ps := &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "2.0.0@1", Files: map[string]string{"foo": filepath.Join(dst, "foo")}}
+	if err := checkSpace(dir, ps, nil); err == nil || !strings.Contains(err.Error(), "150 B needed but only 100 B free") {
+		t.Errorf("checkSpace of 150 bytes on a volume with 100 free returned %v", err)
+	}
+
+	// The installed version frees its files.
+	old := filepath.Join(dst, "old")
+	if err := ioutil.WriteFile(old, make([]byte, 60), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state := client.GooGetState{{PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}, InstalledFiles: map[string]string{old: ""}}}
+	if err := checkSpace(dir, ps, state); err != nil {
+		t.Errorf("checkSpace replacing 60 bytes: %v", err)
+	}
+}
+
+func TestFromRepoDiskSpace(t *testing.T) {
+	defer func(f func(string) (string, uint64, error)) { diskSpace = f }(diskSpace)
+	diskSpace = func(string) (string, uint64, error) { return "vol", 1000, nil }
+
+	tempDir := t.TempDir()
+	p, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1", Files: map[string]string{"bin": filepath.Join(tempDir, "bin")}}, map[string][]byte{"bin/foo": make([]byte, 2000)})
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	const repo = "https://repo.example.com/repo"
+	d := googettest.NewDownloader()
+	if err := d.AddRepo(repo, p); err != nil {
+		t.Fatal(err)
+	}
+	pkgURL, err := download.PackageURL(p.RepoSpec(), repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal([]goolib.ManifestEntry{{Path: "bin/foo", Size: 2000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Add(pkgURL+goolib.ManifestSuffix, b)
+
+	rm := client.RepoMap{repo: client.Repo{Packages: []goolib.RepoSpec{p.RepoSpec()}}}
+	pi := goolib.PackageInfo{Name: "foo", Arch: "noarch", Ver: "1.0.0@1"}
+	err = FromRepo(context.Background(), pi, repo, filepath.Join(tempDir, "cache"), rm, []string{"noarch"}, &client.GooGetState{}, false, d)
+	if err == nil || !strings.Contains(err.Error(), "not enough disk space") {
+		t.Errorf("FromRepo without the space needed returned %v, want a disk space error", err)
+	}
+	for _, r := range d.Requests() {
+		if r == pkgURL {
+			t.Errorf("FromRepo without the space needed downloaded %s", r)
+		}
+	}
+}
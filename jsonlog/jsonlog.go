@@ -0,0 +1,174 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jsonlog writes the logs of GooGet as JSON records, one per line,
+// for log collectors such as Cloud Logging or Splunk.
+//
+// Each record holds the message of a log line with its time, severity and
+// source, the command being run and the package, version and repo of the
+// operation in progress. Operations started with Begin add a record when
+// they end with their duration and error.
+package jsonlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is a JSON log record.
+type Record struct {
+	Time     time.Time `json:"time"`
+	Severity string    `json:"severity"`
+	Message  string    `json:"message"`
+	Source   string    `json:"source,omitempty"`
+	Command  string    `json:"command,omitempty"`
+	Action   string    `json:"action,omitempty"`
+	Package  string    `json:"package,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	Repo     string    `json:"repo,omitempty"`
+	// Duration is in seconds.
+	Duration float64 `json:"duration,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// op is an operation in progress.
+type op struct {
+	action, pkg, ver, repo string
+	start                  time.Time
+}
+
+var (
+	mu      sync.Mutex
+	out     io.Writer
+	command string
+	// ops are the operations in progress, the last one innermost, as when
+	// installing the dependencies of a package.
+	ops []op
+)
+
+// Severity tags written by github.com/google/logger and the severities
+// recorded for them, as understood by Cloud Logging.
+var severities = map[string]string{
+	"INFO : ": "INFO",
+	"WARN : ": "WARNING",
+	"ERROR: ": "ERROR",
+	"FATAL: ": "CRITICAL",
+}
+
+// timeLayout is the date and time written by the logger.
+const timeLayout = "2006/01/02 15:04:05.000000"
+
+// Writer converts the lines written by github.com/google/logger to records.
+type Writer struct{}
+
+// Enable makes records be written to w and returns the Writer to pass to
+// logger.Init. command is the GooGet command being run.
+func Enable(w io.Writer, cmd string) Writer {
+	mu.Lock()
+	defer mu.Unlock()
+	out, command = w, cmd
+	return Writer{}
+}
+
+// Write writes the log line p as a record.
+func (Writer) Write(p []byte) (int, error) {
+	if err := write(parse(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// parse parses a line written with the default flags of the logger:
+// the severity tag, date, time and file, then the message.
+func parse(line string) Record {
+	r := Record{Severity: "INFO", Message: strings.TrimSuffix(line, "\n")}
+	for tag, s := range severities {
+		if strings.HasPrefix(r.Message, tag) {
+			r.Severity, r.Message = s, strings.TrimPrefix(r.Message, tag)
+			break
+		}
+	}
+	f := strings.SplitN(r.Message, " ", 4)
+	if len(f) < 4 || !strings.HasSuffix(f[2], ":") {
+		return r
+	}
+	t, err := time.ParseInLocation(timeLayout, f[0]+" "+f[1], time.Local)
+	if err != nil {
+		return r
+	}
+	r.Time, r.Source, r.Message = t, strings.TrimSuffix(f[2], ":"), f[3]
+	return r
+}
+
+// write completes r with the command and operation in progress and writes
+// it, it does nothing unless enabled.
+func write(r Record) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if out == nil {
+		return nil
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	r.Time = r.Time.UTC()
+	r.Command = command
+	if len(ops) > 0 && r.Package == "" {
+		o := ops[len(ops)-1]
+		r.Action, r.Package, r.Version, r.Repo = o.action, o.pkg, o.ver, o.repo
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(append(b, '\n'))
+	return err
+}
+
+// Begin records that action on version ver of the package pkg from repo
+// started, the records written until the returned function is called carry
+// them. The function writes a record of the end of the action with its
+// duration and err.
+func Begin(action, pkg, ver, repo string) func(err error) {
+	mu.Lock()
+	o := op{action, pkg, ver, repo, time.Now()}
+	ops = append(ops, o)
+	mu.Unlock()
+	return func(err error) {
+		mu.Lock()
+		for i := len(ops) - 1; i >= 0; i-- {
+			if ops[i] == o {
+				ops = append(ops[:i], ops[i+1:]...)
+				break
+			}
+		}
+		mu.Unlock()
+		r := Record{
+			Severity: "INFO",
+			Message:  fmt.Sprintf("%s of %s %s completed", action, pkg, ver),
+			Action:   action,
+			Package:  pkg,
+			Version:  ver,
+			Repo:     repo,
+			Duration: time.Since(o.start).Seconds(),
+		}
+		if err != nil {
+			r.Severity, r.Message, r.Error = "ERROR", fmt.Sprintf("%s of %s %s failed", action, pkg, ver), err.Error()
+		}
+		write(r)
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jsonlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := Enable(&buf, "install")
+	defer Enable(nil, "")
+	// The flags and prefixes used by github.com/google/logger.
+	flags := log.Ldate | log.Lmicroseconds | log.Lshortfile
+	info := log.New(w, "INFO : ", flags)
+	errLog := log.New(w, "ERROR: ", flags)
+
+	start := time.Now().Add(-time.Second)
+	info.Print("Starting")
+	end := Begin("install", "foo.noarch", "1.0.0@1", "https://repo.example.com/stable")
+	info.Print("Installing foo")
+	endDep := Begin("install", "bar.noarch", "2.0.0@1", "https://repo.example.com/stable")
+	errLog.Print("Error running script")
+	endDep(errors.New("script failed"))
+	end(nil)
+	log.New(w, "", 0).Print("no header")
+
+	var got []Record
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		var r Record
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			t.Fatalf("invalid record %q: %v", s.Text(), err)
+		}
+		if r.Time.Before(start) || r.Time.After(time.Now()) {
+			t.Errorf("record %q has time %v, want the current time", s.Text(), r.Time)
+		}
+		if r.Duration < 0 || r.Duration > 10 {
+			t.Errorf("record %q has duration %v", s.Text(), r.Duration)
+		}
+		if r.Source != "" && !strings.HasPrefix(r.Source, "jsonlog_test.go:") {
+			t.Errorf("record %q has source %q, want this file", s.Text(), r.Source)
+		}
+		got = append(got, r)
+	}
+	const repo = "https://repo.example.com/stable"
+	want := []Record{
+		{Severity: "INFO", Message: "Starting", Command: "install"},
+		{Severity: "INFO", Message: "Installing foo", Command: "install", Action: "install", Package: "foo.noarch", Version: "1.0.0@1", Repo: repo},
+		{Severity: "ERROR", Message: "Error running script", Command: "install", Action: "install", Package: "bar.noarch", Version: "2.0.0@1", Repo: repo},
+		{Severity: "ERROR", Message: "install of bar.noarch 2.0.0@1 failed", Command: "install", Action: "install", Package: "bar.noarch", Version: "2.0.0@1", Repo: repo, Error: "script failed"},
+		{Severity: "INFO", Message: "install of foo.noarch 1.0.0@1 completed", Command: "install", Action: "install", Package: "foo.noarch", Version: "1.0.0@1", Repo: repo},
+		{Severity: "INFO", Message: "no header", Command: "install"},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(Record{}, "Time", "Source", "Duration")); diff != "" {
+		t.Errorf("records got unexpected diff (-want +got):\n%v", diff)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	end := Begin("remove", "foo.noarch", "1.0.0@1", "")
+	if n, err := (Writer{}).Write([]byte("INFO : message\n")); n != 15 || err != nil {
+		t.Errorf("Write while disabled = %d, %v, want 15, nil", n, err)
+	}
+	end(nil)
+	if len(ops) != 0 {
+		t.Errorf("operations in progress after the end of all: %v", ops)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remove
+
+import (
+	"fmt"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/logger"
+)
+
+// ModifiedPolicy controls what happens to files that were modified since
+// they were installed when their package is removed.
+type ModifiedPolicy string
+
+const (
+	// RemoveModified removes modified files like any other file.
+	RemoveModified ModifiedPolicy = "remove"
+	// KeepModified leaves modified files in place.
+	KeepModified ModifiedPolicy = "keep"
+	// BackupModified renames modified files by appending BackupSuffix.
+	BackupModified ModifiedPolicy = "backup"
+)
+
+// BackupSuffix is appended to the name of modified files backed up on removal.
+const BackupSuffix = ".googetsave"
+
+// ModifiedFiles is the policy applied to modified files on removal.
+var ModifiedFiles = RemoveModified
+
+// ParseModifiedPolicy parses the name of a ModifiedPolicy, an empty string
+// parses as RemoveModified.
+func ParseModifiedPolicy(s string) (ModifiedPolicy, error) {
+	switch p := ModifiedPolicy(s); p {
+	case "":
+		return RemoveModified, nil
+	case RemoveModified, KeepModified, BackupModified:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown modified files policy %q, must be one of %q, %q or %q", s, RemoveModified, KeepModified, BackupModified)
+}
+
+// modified reports whether file no longer matches chksum. Missing files are
+// not considered modified.
+func modified(file, chksum string) bool {
+	f, err := oswrap.Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	return goolib.Checksum(f) != chksum
+}
+
+// guardModified applies ModifiedFiles to file and reports whether it has been
+// handled and must not be removed.
+func guardModified(file, chksum string) bool {
+	if ModifiedFiles == RemoveModified || !modified(file, chksum) {
+		return false
+	}
+	switch ModifiedFiles {
+	case KeepModified:
+		logger.Warningf("Keeping %q, it was modified since it was installed", file)
+	case BackupModified:
+		logger.Warningf("Backing up %q to %q, it was modified since it was installed", file, file+BackupSuffix)
+		if err := oswrap.Rename(file, file+BackupSuffix); err != nil {
+			logger.Errorf("Error backing up %q, keeping it: %v", file, err)
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remove
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+)
+
+func TestGuardModified(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(dir)
+	defer func() { ModifiedFiles = RemoveModified }()
+
+	chksum := goolib.Checksum(bytes.NewReader([]byte("original")))
+	table := []struct {
+		policy        ModifiedPolicy
+		content       string
+		handled       bool
+		exists, saved bool
+	}{
+		{RemoveModified, "modified", false, true, false},
+		{KeepModified, "original", false, true, false},
+		{KeepModified, "modified", true, true, false},
+		{BackupModified, "modified", true, false, true},
+	}
+	for i, tt := range table {
+		file := filepath.Join(dir, string(rune('a'+i)))
+		if err := ioutil.WriteFile(file, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		ModifiedFiles = tt.policy
+		if got := guardModified(file, chksum); got != tt.handled {
+			t.Errorf("%s, %s: guardModified returned %t, want %t", tt.policy, tt.content, got, tt.handled)
+		}
+		if _, err := oswrap.Stat(file); (err == nil) != tt.exists {
+			t.Errorf("%s, %s: file exists = %t, want %t", tt.policy, tt.content, err == nil, tt.exists)
+		}
+		if _, err := oswrap.Stat(file + BackupSuffix); (err == nil) != tt.saved {
+			t.Errorf("%s, %s: backup exists = %t, want %t", tt.policy, tt.content, err == nil, tt.saved)
+		}
+	}
+
+	if _, err := ParseModifiedPolicy("delete"); err == nil {
+		t.Error("ParseModifiedPolicy accepted unknown policy")
+	}
+}
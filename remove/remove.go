@@ -22,18 +22,29 @@ import (
 
 	"github.com/google/googet/v2/client"
 	"github.com/google/googet/v2/download"
+	"github.com/google/googet/v2/events"
 	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/hooks"
 	"github.com/google/googet/v2/oswrap"
 	"github.com/google/googet/v2/system"
 	"github.com/google/logger"
 )
 
-func uninstallPkg(ctx context.Context, pi goolib.PackageInfo, state *client.GooGetState, dbOnly bool, proxyServer string) error {
+// uninstallMSI is replaced in tests.
+var uninstallMSI = system.UninstallMSI
+
+func uninstallPkg(ctx context.Context, pi goolib.PackageInfo, state *client.GooGetState, dbOnly bool, downloader client.Downloader, trash string) (err error) {
 	logger.Infof("Executing removal of package %q", pi.Name)
 	ps, err := state.GetPackageState(pi)
 	if err != nil {
 		return fmt.Errorf("package not found in state file: %v", err)
 	}
+	rpi := goolib.PackageInfo{Name: ps.PackageSpec.Name, Arch: ps.PackageSpec.Arch, Ver: ps.PackageSpec.Version}
+	done := events.Begin(events.Remove, rpi, ps.SourceRepo)
+	defer func() { done(err) }()
+	if err := hooks.Run(ctx, hooks.Request{Hook: hooks.PreRemove, Package: rpi.String(), Repo: ps.SourceRepo, Path: ps.LocalPath, Spec: ps.PackageSpec}); err != nil {
+		return err
+	}
 
 	if !dbOnly {
 		// Fix for package install by older versions of GooGet.
@@ -44,28 +55,19 @@ func uninstallPkg(ctx context.Context, pi goolib.PackageInfo, state *client.GooG
 			return fmt.Errorf("no local path available for package %q", pi.Name)
 		}
 
-		f, err := os.Open(ps.LocalPath)
-		if err != nil && !os.IsNotExist(err) {
-			return err
-		}
 		var rd bool
-		if os.IsNotExist(err) {
-			logger.Infof("Local package does not exist for %s.%s.%s, redownloading...", pi.Name, pi.Arch, pi.Ver)
+		if p, ok := download.Cached(ps); ok {
+			ps.LocalPath = p
+		} else {
+			logger.Infof("No cached package matches %s.%s.%s, redownloading...", pi.Name, pi.Arch, pi.Ver)
 			rd = true
 		}
-		// Force redownload if checksum does not match.
-		// If checksum is empty this was a local install so ignore.
-		if !rd && ps.Checksum != "" && goolib.Checksum(f) != ps.Checksum {
-			logger.Info("Local package checksum does not match, redownloading...")
-			rd = true
-		}
-		f.Close()
 
 		if rd {
 			if ps.DownloadURL == "" {
 				return fmt.Errorf("can not redownload %s.%s.%s, DownloadURL not saved", pi.Name, pi.Arch, pi.Ver)
 			}
-			if err := download.Package(ctx, ps.DownloadURL, ps.LocalPath, ps.Checksum, proxyServer); err != nil {
+			if err := download.Package(client.WithRepo(ctx, ps.SourceRepo), ps.DownloadURL, ps.LocalPath, ps.Checksum, downloader); err != nil {
 				return fmt.Errorf("error redownloading %s.%s.%s, package may no longer exist in the repo, you can use the '-db_only' flag to remove it form the database: %v", pi.Name, pi.Arch, pi.Ver, err)
 			}
 		}
@@ -75,35 +77,55 @@ func uninstallPkg(ctx context.Context, pi goolib.PackageInfo, state *client.GooG
 			return err
 		}
 
-		if err := system.Uninstall(eDir, ps.PackageSpec); err != nil {
+		if ps.MSIProductCode != "" {
+			if err := uninstallMSI(ctx, eDir, ps.MSIProductCode, ps.PackageSpec.MSI); err != nil {
+				return err
+			}
+		} else if err := system.Uninstall(ctx, eDir, ps.PackageSpec); err != nil {
 			return err
 		}
+		if err := system.UndoEnv(ps.PackageSpec.Env, ps.EnvChanges); err != nil {
+			logger.Errorf("Error undoing the environment changes of %s: %v", pi.Name, err)
+		}
+		if err := system.RemoveShortcuts(ps.Shortcuts); err != nil {
+			logger.Error(err)
+		}
 
 		if err := oswrap.RemoveAll(eDir); err != nil {
 			logger.Error(err)
 		}
 
-		if len(ps.InstalledFiles) > 0 {
-			var dirs []string
-			for file, chksum := range ps.InstalledFiles {
-				if chksum == "" {
-					dirs = append(dirs, file)
+		var dirs []string
+		for file, chksum := range ps.InstalledFiles {
+			if chksum == "" {
+				dirs = append(dirs, file)
+				continue
+			}
+			if guardModified(file, chksum) {
+				continue
+			}
+			if trash != "" {
+				logger.Infof("Moving %q to %q", file, trash)
+				err := trashFile(trash, file)
+				if err == nil || os.IsNotExist(err) {
 					continue
 				}
-				logger.Infof("Removing %q", file)
-				if _, err := client.RemoveOrRename(file); err != nil {
-					logger.Error(err)
-				}
+				logger.Errorf("Error moving %q to trash, removing it: %v", file, err)
 			}
-			sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
-			for _, dir := range dirs {
-				logger.Infof("Removing %q", dir)
-				if _, err := client.RemoveOrRename(dir); err != nil {
-					logger.Info(err)
-				}
+			logger.Infof("Removing %q", file)
+			if _, err := client.RemoveOrRename(file); err != nil {
+				logger.Error(err)
 			}
 		}
-		if err := oswrap.RemoveAll(ps.LocalPath); err != nil {
+		// Only remove the directories the install created, packages
+		// installed by older versions of GooGet do not record them.
+		if ps.CreatedDirs != nil {
+			dirs = ps.CreatedDirs
+		}
+		removeEmptyDirs(dirs)
+		if state.SharesCache(ps) {
+			logger.Infof("Keeping cached %q, another installed package has the same contents", ps.LocalPath)
+		} else if err := download.RemoveCached(ps.LocalPath); err != nil {
 			logger.Errorf("error removing package data from cache directory: %v", err)
 		}
 	}
@@ -134,7 +156,7 @@ func (deps DepMap) build(name, arch string, state client.GooGetState) {
 		if p.PackageSpec.Name == name && p.PackageSpec.Arch == arch {
 			continue
 		}
-		for d := range p.PackageSpec.PkgDependencies {
+		for d := range p.PackageSpec.Dependencies() {
 			di := goolib.PkgNameSplit(d)
 			if di.Name == name && (di.Arch == arch || di.Arch == "") {
 				n, a := p.PackageSpec.Name, p.PackageSpec.Arch
@@ -145,6 +167,36 @@ func (deps DepMap) build(name, arch string, state client.GooGetState) {
 	}
 }
 
+// Order returns the packages in deps in the order All removes them, packages
+// with no dependant packages first.
+func (deps DepMap) Order() []string {
+	d := make(DepMap)
+	for k, v := range deps {
+		d[k] = append([]string(nil), v...)
+	}
+	var out []string
+	for len(d) > 0 {
+		var next []string
+		for k, v := range d {
+			if len(v) == 0 {
+				next = append(next, k)
+			}
+		}
+		if len(next) == 0 {
+			// Circular dependencies, remove the rest in any order.
+			for k := range d {
+				next = append(next, k)
+			}
+		}
+		sort.Strings(next)
+		for _, k := range next {
+			out = append(out, k)
+			d.remove(k)
+		}
+	}
+	return out
+}
+
 // EnumerateDeps returns a DepMap and list of dependencies for a package.
 func EnumerateDeps(pi goolib.PackageInfo, state client.GooGetState) (DepMap, []string) {
 	dm := make(DepMap)
@@ -163,17 +215,35 @@ func EnumerateDeps(pi goolib.PackageInfo, state client.GooGetState) (DepMap, []s
 
 // All removes a package and all dependant packages. Packages with no dependant packages
 // will be removed first.
-func All(ctx context.Context, pi goolib.PackageInfo, deps DepMap, state *client.GooGetState, dbOnly bool, proxyServer string) error {
+func All(ctx context.Context, pi goolib.PackageInfo, deps DepMap, state *client.GooGetState, dbOnly bool, downloader client.Downloader) error {
+	trash := newTrash(pi)
 	for len(deps) > 1 {
 		for dep := range deps {
 			if len(deps[dep]) == 0 {
 				di := goolib.PkgNameSplit(dep)
-				if err := uninstallPkg(ctx, di, state, dbOnly, proxyServer); err != nil {
+				if err := uninstallPkg(ctx, di, state, dbOnly, downloader, trash); err != nil {
 					return err
 				}
 				deps.remove(dep)
+				// pi is removed last, even once nothing depends on it.
+				if len(deps) == 1 {
+					break
+				}
 			}
 		}
 	}
-	return uninstallPkg(ctx, pi, state, dbOnly, proxyServer)
+	return uninstallPkg(ctx, pi, state, dbOnly, downloader, trash)
+}
+
+// removeEmptyDirs removes the empty directories in dirs, deepest first so
+// that parents emptied by removing their children are removed as well.
+func removeEmptyDirs(dirs []string) {
+	dirs = append([]string(nil), dirs...)
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		logger.Infof("Removing %q", dir)
+		if err := oswrap.Remove(dir); err != nil && !os.IsNotExist(err) {
+			logger.Info(err)
+		}
+	}
 }
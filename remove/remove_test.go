@@ -25,6 +25,7 @@ import (
 	"testing"
 
 	"github.com/google/googet/v2/client"
+	"github.com/google/googet/v2/googettest"
 	"github.com/google/googet/v2/goolib"
 	"github.com/google/googet/v2/oswrap"
 	"github.com/google/logger"
@@ -108,7 +109,7 @@ func TestUninstallPkg(t *testing.T) {
 		},
 	}
 
-	if err := uninstallPkg(context.Background(), goolib.PackageInfo{Name: "foo"}, st, false, ""); err != nil {
+	if err := uninstallPkg(context.Background(), goolib.PackageInfo{Name: "foo"}, st, false, client.HTTPDownloader{}, ""); err != nil {
 		t.Fatalf("Error running uninstallPkg: %v", err)
 	}
 
@@ -119,6 +120,64 @@ func TestUninstallPkg(t *testing.T) {
 	}
 }
 
+func TestUninstallPkgMSI(t *testing.T) {
+	const code = "{00000000-0000-0000-0000-00000000000A}"
+	spec := &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1", MSI: &goolib.MSISpec{Path: "foo.msi", ProductCode: code}}
+	p, err := googettest.GenGoo(spec, nil)
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	pkg := filepath.Join(t.TempDir(), p.Name())
+	if err := ioutil.WriteFile(pkg, p.Data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var uninstalled []string
+	defer func(f func(context.Context, string, string, *goolib.MSISpec) error) { uninstallMSI = f }(uninstallMSI)
+	uninstallMSI = func(_ context.Context, _, productCode string, _ *goolib.MSISpec) error {
+		uninstalled = append(uninstalled, productCode)
+		return nil
+	}
+
+	st := &client.GooGetState{{PackageSpec: spec, LocalPath: pkg, Checksum: p.Checksum, MSIProductCode: code}}
+	if err := uninstallPkg(context.Background(), goolib.PackageInfo{Name: "foo"}, st, false, client.HTTPDownloader{}, ""); err != nil {
+		t.Fatalf("Error running uninstallPkg: %v", err)
+	}
+	if want := []string{code}; !reflect.DeepEqual(uninstalled, want) {
+		t.Errorf("uninstallPkg uninstalled MSI products %q, want %q", uninstalled, want)
+	}
+	if len(*st) != 0 {
+		t.Errorf("uninstallPkg left state %+v, want it empty", *st)
+	}
+}
+
+func TestRemoveEmptyDirs(t *testing.T) {
+	dst, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(dst)
+
+	empty := filepath.Join(dst, "a", "b", "c")
+	full := filepath.Join(dst, "d")
+	for _, d := range []string{empty, full} {
+		if err := oswrap.MkdirAll(d, 0755); err != nil {
+			t.Fatalf("Failed to create test folder: %v", err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(full, "user"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	removeEmptyDirs([]string{filepath.Join(dst, "a"), full, empty, filepath.Join(dst, "a", "b"), filepath.Join(dst, "missing")})
+
+	if _, err := oswrap.Stat(filepath.Join(dst, "a")); err == nil {
+		t.Error("empty directory tree was not removed")
+	}
+	if _, err := oswrap.Stat(full); err != nil {
+		t.Errorf("non empty directory was removed: %v", err)
+	}
+}
+
 func TestBuild(t *testing.T) {
 	pkg1 := "foo_pkg"
 	pkg2 := "bar_pkg"
@@ -184,3 +243,14 @@ func TestRemoveDep(t *testing.T) {
 		t.Errorf("returned dependancy map does not match expected one: got %v, want %v", deps, want)
 	}
 }
+
+func TestOrder(t *testing.T) {
+	deps := DepMap{"foo_pkg": []string{"bar_pkg"}, "bar_pkg": nil, "baz_pkg": []string{"foo_pkg", "bar_pkg"}}
+	want := []string{"bar_pkg", "foo_pkg", "baz_pkg"}
+	if got := deps.Order(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Order() = %v, want %v", got, want)
+	}
+	if len(deps["baz_pkg"]) != 2 {
+		t.Errorf("Order modified the dependency map: %v", deps)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remove
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/logger"
+)
+
+// trashTimeFormat prefixes the name of each trash transaction directory.
+const trashTimeFormat = "20060102T150405Z"
+
+// TrashDir, if set, is the directory files owned by removed packages are moved
+// to instead of being deleted. Each call to All uses its own subdirectory so
+// a removal can be recovered as a unit.
+var TrashDir string
+
+// newTrash returns the trash directory for a removal of pi, or "" if
+// TrashDir is not set.
+func newTrash(pi goolib.PackageInfo) string {
+	if TrashDir == "" {
+		return ""
+	}
+	return filepath.Join(TrashDir, time.Now().UTC().Format(trashTimeFormat)+"-"+pi.Name)
+}
+
+// trashFile moves file into trash, keeping its full path below it.
+func trashFile(trash, file string) error {
+	rel := strings.TrimPrefix(file, filepath.VolumeName(file))
+	if vol := filepath.VolumeName(file); vol != "" {
+		rel = filepath.Join(strings.Trim(vol, `:\/`), rel)
+	}
+	dst := filepath.Join(trash, rel)
+	if err := oswrap.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	return oswrap.Rename(file, dst)
+}
+
+// PurgeTrash deletes the trash transaction directories in TrashDir that are
+// older than age, an age of 0 deletes all of them.
+func PurgeTrash(age time.Duration) error {
+	if TrashDir == "" {
+		return nil
+	}
+	dirs, err := filepath.Glob(filepath.Join(TrashDir, "*"))
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		name := filepath.Base(dir)
+		if len(name) < len(trashTimeFormat) {
+			continue
+		}
+		t, err := time.Parse(trashTimeFormat, name[:len(trashTimeFormat)])
+		if err != nil {
+			continue
+		}
+		if age > 0 && time.Since(t) < age {
+			continue
+		}
+		logger.Infof("Purging %q from trash", dir)
+		if err := oswrap.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remove
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+)
+
+func TestTrash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer oswrap.RemoveAll(dir)
+	TrashDir = filepath.Join(dir, "trash")
+	defer func() { TrashDir = "" }()
+
+	file := filepath.Join(dir, "files", "foo")
+	if err := oswrap.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	trash := newTrash(goolib.PackageInfo{Name: "foo"})
+	if !strings.HasPrefix(trash, TrashDir) {
+		t.Fatalf("newTrash returned %q, want a directory in %q", trash, TrashDir)
+	}
+	if err := trashFile(trash, file); err != nil {
+		t.Fatalf("error running trashFile: %v", err)
+	}
+	if _, err := oswrap.Stat(file); err == nil {
+		t.Errorf("%s was not moved", file)
+	}
+	moved := filepath.Join(trash, strings.TrimPrefix(file, filepath.VolumeName(file)))
+	if vol := filepath.VolumeName(file); vol != "" {
+		moved = filepath.Join(trash, strings.Trim(vol, `:\/`), strings.TrimPrefix(file, vol))
+	}
+	if b, err := ioutil.ReadFile(moved); err != nil || string(b) != "foo" {
+		t.Errorf("moved file %s has content %q, %v", moved, b, err)
+	}
+
+	old := filepath.Join(TrashDir, time.Now().UTC().Add(-48*time.Hour).Format(trashTimeFormat)+"-bar")
+	if err := oswrap.MkdirAll(old, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := PurgeTrash(24 * time.Hour); err != nil {
+		t.Fatalf("error running PurgeTrash: %v", err)
+	}
+	if _, err := oswrap.Stat(old); err == nil {
+		t.Errorf("expired trash %s was not purged", old)
+	}
+	if _, err := oswrap.Stat(trash); err != nil {
+		t.Errorf("recent trash %s was purged", trash)
+	}
+	if err := PurgeTrash(0); err != nil {
+		t.Fatalf("error running PurgeTrash: %v", err)
+	}
+	if _, err := oswrap.Stat(trash); err == nil {
+		t.Errorf("trash %s was not purged", trash)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package report records a summary of a GooGet transaction, the packages it
+// changed, the scripts it ran and the bytes it downloaded, for patch
+// management tools.
+package report
+
+import (
+	"sync"
+	"time"
+)
+
+// Report is the summary of a command.
+type Report struct {
+	// Command is the command name, its flags and arguments.
+	Command []string
+	Start   time.Time
+	// Duration is in seconds.
+	Duration        float64
+	ExitStatus      int
+	BytesDownloaded int64
+	Packages        []*Package
+	// Errors are the errors of the failed packages.
+	Errors []string `json:",omitempty"`
+}
+
+// Package is the install, reinstall, removal or scan of a package.
+type Package struct {
+	Action string
+	// Package is the name and arch of the package.
+	Package string
+	Version string
+	// PreviousVersion is the version installed before the command, if
+	// another.
+	PreviousVersion string `json:",omitempty"`
+	Repo            string `json:",omitempty"`
+	// Duration is in seconds.
+	Duration float64
+	Scripts  []Script `json:",omitempty"`
+	Error    string   `json:",omitempty"`
+}
+
+// Script is a run of a package script.
+type Script struct {
+	Path string
+	// ExitCode is -1 if the script could not run or was killed.
+	ExitCode int
+	Error    string `json:",omitempty"`
+}
+
+var (
+	mu  sync.Mutex
+	cur *Report
+	// open are the packages whose transaction is in progress, the last one
+	// runs the scripts.
+	open []*Package
+)
+
+// Start starts recording the report of command, until Finish.
+func Start(command []string) {
+	mu.Lock()
+	defer mu.Unlock()
+	cur = &Report{Command: command, Start: time.Now()}
+	open = nil
+}
+
+// Finish stops recording and returns the report, nil if Start was not
+// called.
+func Finish() *Report {
+	mu.Lock()
+	defer mu.Unlock()
+	r := cur
+	cur, open = nil, nil
+	if r != nil {
+		r.Duration = time.Since(r.Start).Seconds()
+	}
+	return r
+}
+
+// Begin records the start of action on version ver of pkg, its name and
+// arch, from repo and returns a function recording its end with the error
+// passed to it.
+func Begin(action, pkg, ver, repo string) func(error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if cur == nil {
+		return func(error) {}
+	}
+	r := cur
+	p := &Package{Action: action, Package: pkg, Version: ver, Repo: repo}
+	r.Packages = append(r.Packages, p)
+	open = append(open, p)
+	start := time.Now()
+	return func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		p.Duration = time.Since(start).Seconds()
+		if err != nil {
+			p.Error = err.Error()
+			r.Errors = append(r.Errors, err.Error())
+		}
+		for i := len(open) - 1; i >= 0; i-- {
+			if open[i] == p {
+				open = append(open[:i], open[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// ScriptExited records a run of the script at path of the package in
+// progress, which exited with code or failed to run with err.
+func ScriptExited(path string, code int, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if cur == nil || len(open) == 0 {
+		return
+	}
+	s := Script{Path: path, ExitCode: code}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	p := open[len(open)-1]
+	p.Scripts = append(p.Scripts, s)
+}
+
+// Downloaded records n bytes downloaded.
+func Downloaded(n int64) {
+	mu.Lock()
+	defer mu.Unlock()
+	if cur != nil {
+		cur.BytesDownloaded += n
+	}
+}
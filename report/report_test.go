@@ -0,0 +1,62 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package report
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestReport(t *testing.T) {
+	// Nothing is recorded before Start.
+	Begin("install", "foo.x86_64", "1.0", "")(nil)
+	ScriptExited("install.ps1", 0, nil)
+	Downloaded(10)
+	if r := Finish(); r != nil {
+		t.Fatalf("Finish without Start = %+v, want nil", r)
+	}
+
+	Start([]string{"install", "foo"})
+	endFoo := Begin("install", "foo.x86_64", "2.0", "https://repo")
+	endBar := Begin("install", "bar.noarch", "1.0", "https://repo")
+	Downloaded(100)
+	ScriptExited("bar/install.ps1", 1, nil)
+	ScriptExited("bar/install.ps1", 0, nil)
+	endBar(nil)
+	Downloaded(50)
+	ScriptExited("foo/install.ps1", -1, errors.New("timed out"))
+	endFoo(errors.New("install script failed"))
+	r := Finish()
+
+	want := &Report{
+		Command:         []string{"install", "foo"},
+		BytesDownloaded: 150,
+		Packages: []*Package{
+			{Action: "install", Package: "foo.x86_64", Version: "2.0", Repo: "https://repo", Error: "install script failed",
+				Scripts: []Script{{Path: "foo/install.ps1", ExitCode: -1, Error: "timed out"}}},
+			{Action: "install", Package: "bar.noarch", Version: "1.0", Repo: "https://repo",
+				Scripts: []Script{{Path: "bar/install.ps1", ExitCode: 1}, {Path: "bar/install.ps1"}}},
+		},
+		Errors: []string{"install script failed"},
+	}
+	if diff := cmp.Diff(want, r, cmpopts.IgnoreFields(Report{}, "Start", "Duration"), cmpopts.IgnoreFields(Package{}, "Duration")); diff != "" {
+		t.Errorf("Finish got unexpected diff (-want +got):\n%v", diff)
+	}
+	if r.Start.IsZero() || r.Duration <= 0 {
+		t.Errorf("Finish did not record the start and duration, got %v and %v", r.Start, r.Duration)
+	}
+}
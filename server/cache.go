@@ -0,0 +1,93 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+// fileVersion identifies the content of a package file without reading it.
+type fileVersion struct {
+	size int64
+	// version is the modification time in nanoseconds of a local file or the
+	// generation of a GCS object.
+	version int64
+}
+
+type cacheEntry struct {
+	fileVersion
+	rs goolib.RepoSpec
+	// manifest is only kept when deduplication statistics are enabled.
+	manifest []goolib.ManifestEntry
+}
+
+// pkgCache holds the RepoSpec of each package read by previous sync runs so
+// unchanged packages are not read and checksummed again.
+type pkgCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func (c *pkgCache) get(pkgPath string, v fileVersion) (goolib.RepoSpec, []goolib.ManifestEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[pkgPath]
+	if !ok || e.fileVersion != v {
+		return goolib.RepoSpec{}, nil, false
+	}
+	return e.rs, e.manifest, true
+}
+
+func (c *pkgCache) put(pkgPath string, v fileVersion, rs goolib.RepoSpec, manifest []goolib.ManifestEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+	c.entries[pkgPath] = cacheEntry{v, rs, manifest}
+}
+
+// prune removes the entries of packages that are no longer present.
+func (c *pkgCache) prune(present map[string]fileVersion) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for p := range c.entries {
+		if _, ok := present[p]; !ok {
+			delete(c.entries, p)
+		}
+	}
+}
+
+// syncTrigger requests a sync run outside of the regular interval, pending
+// requests are coalesced.
+var syncTrigger = make(chan struct{}, 1)
+
+// serveSync triggers a sync run on POST requests, it can be used as the
+// target of a Pub/Sub push subscription for GCS bucket notifications or
+// called by a file watcher.
+func serveSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	select {
+	case syncTrigger <- struct{}{}:
+	default:
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
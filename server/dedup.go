@@ -0,0 +1,119 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+// maxDuplicates limits the number of duplicated files listed in a report.
+const maxDuplicates = 100
+
+// fileCopy is a file contained in a package.
+type fileCopy struct {
+	Package, Path string
+}
+
+// duplicate is a file content found more than once in a repo.
+type duplicate struct {
+	Checksum string
+	Size     int64
+	Copies   []fileCopy
+}
+
+// wasted is the space used by the copies beyond the first.
+func (d duplicate) wasted() int64 {
+	return d.Size * int64(len(d.Copies)-1)
+}
+
+// dedupReport describes how much of the content of a repo is duplicated.
+type dedupReport struct {
+	Packages, Files int
+	// TotalBytes is the uncompressed size of all files and UniqueBytes the
+	// size of the distinct file contents.
+	TotalBytes, UniqueBytes int64
+	// VersionSavingsBytes could be saved by delta updates between versions
+	// of the same package, PackageSavingsBytes by sharing content between
+	// different packages.
+	VersionSavingsBytes, PackageSavingsBytes int64
+	// Duplicates lists the duplicated files wasting the most space.
+	Duplicates []duplicate
+}
+
+// analyzeDedup analyzes the manifests of the packages in rs, keyed by source.
+func analyzeDedup(rs []goolib.RepoSpec, manifests map[string][]goolib.ManifestEntry) *dedupReport {
+	r := &dedupReport{Packages: len(rs)}
+	byChecksum := make(map[string]*duplicate)
+	names := make(map[fileCopy]string)
+	for _, s := range rs {
+		for _, e := range manifests[s.Source] {
+			r.Files++
+			r.TotalBytes += e.Size
+			d, ok := byChecksum[e.Checksum]
+			if !ok {
+				d = &duplicate{Checksum: e.Checksum, Size: e.Size}
+				byChecksum[e.Checksum] = d
+				r.UniqueBytes += e.Size
+			}
+			c := fileCopy{Package: s.Source, Path: e.Path}
+			d.Copies = append(d.Copies, c)
+			names[c] = s.PackageSpec.Name
+		}
+	}
+
+	for _, d := range byChecksum {
+		if len(d.Copies) < 2 {
+			continue
+		}
+		perName := make(map[string]int)
+		for _, c := range d.Copies {
+			perName[names[c]]++
+		}
+		// Keeping one copy per package name is what delta updates would
+		// achieve, the rest needs content shared across packages.
+		r.VersionSavingsBytes += d.Size * int64(len(d.Copies)-len(perName))
+		r.PackageSavingsBytes += d.Size * int64(len(perName)-1)
+		r.Duplicates = append(r.Duplicates, *d)
+	}
+	sort.Slice(r.Duplicates, func(i, j int) bool {
+		if r.Duplicates[i].wasted() != r.Duplicates[j].wasted() {
+			return r.Duplicates[i].wasted() > r.Duplicates[j].wasted()
+		}
+		return r.Duplicates[i].Checksum < r.Duplicates[j].Checksum
+	})
+	if len(r.Duplicates) > maxDuplicates {
+		r.Duplicates = r.Duplicates[:maxDuplicates]
+	}
+	return r
+}
+
+// serveDedup writes the deduplication report of the last sync run.
+func (rep *repo) serveDedup(w http.ResponseWriter, r *http.Request) {
+	d := rep.dedup.Load()
+	if d == nil {
+		unavailable(w)
+		return
+	}
+	out, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out)
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestAnalyzeDedup(t *testing.T) {
+	rs := []goolib.RepoSpec{
+		{Source: "foo.1.goo", PackageSpec: &goolib.PkgSpec{Name: "foo"}},
+		{Source: "foo.2.goo", PackageSpec: &goolib.PkgSpec{Name: "foo"}},
+		{Source: "bar.1.goo", PackageSpec: &goolib.PkgSpec{Name: "bar"}},
+	}
+	manifests := map[string][]goolib.ManifestEntry{
+		"foo.1.goo": {{Path: "lib.dll", Size: 100, Checksum: "lib"}, {Path: "foo.exe", Size: 10, Checksum: "foo1"}},
+		"foo.2.goo": {{Path: "lib.dll", Size: 100, Checksum: "lib"}, {Path: "foo.exe", Size: 10, Checksum: "foo2"}},
+		"bar.1.goo": {{Path: "bin/lib.dll", Size: 100, Checksum: "lib"}, {Path: "README", Size: 5, Checksum: "readme"}},
+		// Not in rs, e.g. removed by retention.
+		"old.goo": {{Path: "lib.dll", Size: 100, Checksum: "lib"}},
+	}
+
+	r := analyzeDedup(rs, manifests)
+	if r.Packages != 3 || r.Files != 6 {
+		t.Errorf("got %d packages and %d files, want 3 and 6", r.Packages, r.Files)
+	}
+	if r.TotalBytes != 325 || r.UniqueBytes != 125 {
+		t.Errorf("got %d total and %d unique bytes, want 325 and 125", r.TotalBytes, r.UniqueBytes)
+	}
+	if r.VersionSavingsBytes != 100 || r.PackageSavingsBytes != 100 {
+		t.Errorf("got %d version and %d package savings, want 100 and 100", r.VersionSavingsBytes, r.PackageSavingsBytes)
+	}
+	if len(r.Duplicates) != 1 || r.Duplicates[0].Checksum != "lib" || len(r.Duplicates[0].Copies) != 3 {
+		t.Errorf("unexpected duplicates: %+v", r.Duplicates)
+	}
+}
@@ -15,19 +15,31 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/go-yaml/yaml"
 	"github.com/google/googet/v2/goolib"
 	"github.com/google/googet/v2/oswrap"
 	"github.com/google/logger"
@@ -35,20 +47,103 @@ import (
 )
 
 var (
-	root        = flag.String("root", "", "root location")
-	interval    = flag.Duration("interval", 5*time.Minute, "duration between refresh runs")
-	verbose     = flag.Bool("verbose", false, "print info level logs to stdout")
-	systemLog   = flag.Bool("system_log", false, "log to Linux Syslog or Windows Event Log")
-	address     = flag.String("address", "", "address to listen on")
-	port        = flag.Int("port", 8000, "listen port")
-	repoName    = flag.String("repo_name", "repo", "name of the repo to setup")
-	packagePath = flag.String("package_path", "packages", "path under both the filesystem (-root flag) and webserver root where packages are located")
-	dumpIndex   = flag.Bool("dump_index", false, "dump the package index to stdout and quit")
-	saveIndex   = flag.Bool("save_index", false, "save the package index file and quit")
-
-	repoContents *repoPackages
+	root             = flag.String("root", "", "root location")
+	interval         = flag.Duration("interval", 5*time.Minute, "duration between refresh runs")
+	verbose          = flag.Bool("verbose", false, "print info level logs to stdout")
+	systemLog        = flag.Bool("system_log", false, "log to Linux Syslog or Windows Event Log")
+	address          = flag.String("address", "", "address to listen on")
+	port             = flag.Int("port", 8000, "listen port")
+	repoName         = flag.String("repo_name", "repo", "name of the repo to setup")
+	config           = flag.String("config", "", "path to a YAML file defining several repos to serve, overrides -repo_name, -package_path and the -upstream flags")
+	packagePath      = flag.String("package_path", "packages", "path under both the filesystem (-root flag) and webserver root where packages are located")
+	dumpIndex        = flag.Bool("dump_index", false, "dump the package index to stdout and quit")
+	saveIndex        = flag.Bool("save_index", false, "save the package index file and quit")
+	sidecars         = flag.Bool("sidecars", false, "write spec, manifest and, with -sign_key, signature sidecar files next to each package and list them in the index")
+	keepVersions     = flag.Int("keep_versions", 0, "if set, only keep this many of the newest versions of each package name and arch, older packages are removed during sync runs")
+	keepNewerThan    = flag.Duration("keep_newer_than", 0, "if set, packages modified within this duration are not removed by retention, combine with -keep_versions or use alone to only keep recent packages")
+	retentionArchive = flag.String("retention_archive", "", "directory or GCS URL that packages removed by retention are moved to instead of being deleted")
+	syncEndpoint     = flag.Bool("sync_endpoint", false, "serve /sync, a POST to it starts a sync run immediately, use with GCS Pub/Sub push notifications or a file watcher")
+	retentionDryRun  = flag.Bool("retention_dry_run", false, "only log the packages that retention would remove")
+	shutdownTimeout  = flag.Duration("shutdown_timeout", 30*time.Second, "how long to wait for in-flight requests to finish on SIGTERM")
+	dedupStats       = flag.Bool("dedup_stats", false, "serve /<repo>/dedup, a report of the files duplicated across packages, the file manifests of all packages are kept in memory")
+	dumpDedup        = flag.Bool("dump_dedup", false, "dump the deduplication report of each repo to stdout and quit")
+	upstream         = flag.String("upstream", "", "URL of a GooGet repo to mirror, packages missing locally are downloaded before each sync run")
+	upstreamInclude  = flag.String("upstream_include", "", "comma separated patterns of package names to mirror, all packages are mirrored if empty")
+	upstreamExclude  = flag.String("upstream_exclude", "", "comma separated patterns of package names not to mirror")
+	upstreamTimeout  = flag.Duration("upstream_timeout", 10*time.Minute, "how long each request to an upstream repo, the download of a package included, may take before the sync run fails")
+	webUI            = flag.Bool("web_ui", false, "serve /<repo>/browse, an HTML page listing the packages of the repo with download links")
+	statusEndpoint   = flag.Bool("status_endpoint", false, "serve /<repo>/status, a POST to it marks a package as yanked or deprecated")
+	rateLimit        = flag.Float64("rate_limit", 0, "if set, the requests per second allowed per client IP, further requests get 429 responses; /healthz, /readyz and /metrics are not limited")
+	rateBurst        = flag.Int("rate_burst", 0, "the burst of requests allowed per client IP above -rate_limit, defaults to -rate_limit rounded up")
+	maxRequestBytes  = flag.Int64("max_request_bytes", 1<<20, "maximum size of a request body, larger requests get 413 responses, 0 for no limit")
+	renames          = flag.String("renames", "", "comma separated OLD=NEW package renames published in the index, clients install and update to the new names instead")
+	signKey          = flag.String("sign_key", "", "path to a PEM encoded ed25519 private key used to sign the index, the signature is served and saved as index.sig and the public key as index.pub")
+	checksums        = flag.String("checksums", "", "comma separated checksum algorithms, such as sha512, whose checksums of each package are published in the index next to the SHA256 one")
+
+	key ed25519.PrivateKey
+	// pubKey is the PEM encoded public key of key, served as index.pub.
+	pubKey []byte
+	// checksumAlgos are the algorithms of -checksums.
+	checksumAlgos []string
 )
 
+// repo is a named repository served by gooserve.
+type repo struct {
+	Name string
+	// PackagePath is the path under both the root and the webserver root
+	// where the packages of this repo are located.
+	PackagePath string
+	// Archs limits the repo to packages of these architectures, all
+	// architectures are included if empty.
+	Archs []string
+	// Upstream is the URL of a repo this repo mirrors. Include and Exclude
+	// are patterns, as in path.Match, of the package names to mirror.
+	Upstream         string
+	Include, Exclude []string
+	// Renames maps old package names to the names the packages were renamed
+	// to, which clients install and update to instead.
+	Renames map[string]string
+
+	index repoIndex
+	cache pkgCache
+	dedup atomic.Pointer[dedupReport]
+	// fetched holds the upstream sources already present locally.
+	fetched map[string]bool
+}
+
+type serveConfig struct {
+	Repos []*repo
+}
+
+// readConfig reads the repos to serve from the YAML file at path.
+func readConfig(path string) ([]*repo, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c serveConfig
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Repos) == 0 {
+		return nil, fmt.Errorf("%s: no repos defined", path)
+	}
+	seen := make(map[string]bool)
+	for _, r := range c.Repos {
+		if r.Name == "" {
+			return nil, fmt.Errorf("%s: repo with no name", path)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("%s: repo %q defined more than once", path, r.Name)
+		}
+		seen[r.Name] = true
+		if r.PackagePath == "" {
+			r.PackagePath = *packagePath
+		}
+	}
+	return c.Repos, nil
+}
+
 // repoPackages describes a repository of packages.
 type repoPackages struct {
 	rs []goolib.RepoSpec
@@ -56,14 +151,76 @@ type repoPackages struct {
 }
 
 // add provides a thread safe way to add a package to repoPackages.
-func (r *repoPackages) add(src, chksum string, spec *goolib.PkgSpec) {
+func (r *repoPackages) add(rs goolib.RepoSpec) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.rs = append(r.rs, goolib.RepoSpec{
-		Source:      src,
-		Checksum:    chksum,
-		PackageSpec: spec,
-	})
+	r.rs = append(r.rs, rs)
+}
+
+// indexData is a serialized index in the forms it is served in.
+type indexData struct {
+	// data is the JSON index and gz its gzip compressed form.
+	data, gz []byte
+	// etag is the strong entity tag of data, the gzip compressed form uses
+	// gzETag.
+	etag string
+	// sig is the signature of data, nil if signing is not configured.
+	sig []byte
+	// html is the package listing served with -web_ui.
+	html []byte
+}
+
+// repoIndex holds the index from the last completed sync run.
+type repoIndex struct {
+	mu sync.RWMutex
+	d  *indexData
+}
+
+func (i *repoIndex) set(d *indexData) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.d = d
+}
+
+// get returns the current index or nil if no sync run completed yet, the
+// returned value must not be modified.
+func (i *repoIndex) get() *indexData {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.d
+}
+
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalIndex serializes and compresses rs and, if a signing key is
+// configured, signs the result.
+func marshalIndex(rs []goolib.RepoSpec) (*indexData, error) {
+	out, err := json.MarshalIndent(rs, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzipBytes(out)
+	if err != nil {
+		return nil, err
+	}
+	d := &indexData{data: out, gz: gz, etag: fmt.Sprintf(`"%x"`, sha256.Sum256(out))}
+	if key != nil {
+		d.sig = goolib.Sign(key, out)
+	}
+	return d, nil
 }
 
 func getReader(ctx context.Context, client *storage.Client, rootLoc, packageLoc, pkgPath string) (io.ReadCloser, error) {
@@ -79,12 +236,72 @@ func getReader(ctx context.Context, client *storage.Client, rootLoc, packageLoc,
 	}
 }
 
-func runSync(ctx context.Context, rootLoc, packageLoc string) error {
-	logger.Info("Beginning sync run")
+// readPackage reads the spec, checksum and file manifest of a package and, if
+// enabled, writes its sidecar files.
+func readPackage(ctx context.Context, client *storage.Client, rootLoc, packageLoc, pkgPath string) (goolib.RepoSpec, []goolib.ManifestEntry, error) {
+	r, err := getReader(ctx, client, rootLoc, packageLoc, pkgPath)
+	if err != nil {
+		return goolib.RepoSpec{}, nil, err
+	}
+	manifest, spec, err := goolib.ReadManifest(r)
+	r.Close()
+	if err != nil {
+		return goolib.RepoSpec{}, nil, err
+	}
+
+	// Re-get the reader so we can get the checksum, GCS does not
+	// provide a seeker.
+	r, err = getReader(ctx, client, rootLoc, packageLoc, pkgPath)
+	if err != nil {
+		return goolib.RepoSpec{}, nil, err
+	}
+	hashes := []hash.Hash{sha256.New()}
+	ws := []io.Writer{hashes[0]}
+	for _, a := range checksumAlgos {
+		h, _ := goolib.NewHash(a)
+		hashes = append(hashes, h)
+		ws = append(ws, h)
+	}
+	_, err = io.Copy(io.MultiWriter(ws...), r)
+	r.Close()
+	if err != nil {
+		return goolib.RepoSpec{}, nil, err
+	}
+
+	rs := goolib.RepoSpec{
+		Source:      pkgPath,
+		Checksum:    goolib.FormatChecksum(goolib.SHA256, hashes[0].Sum(nil)),
+		PackageSpec: spec,
+	}
+	for i, a := range checksumAlgos {
+		rs.Checksums = append(rs.Checksums, goolib.FormatChecksum(a, hashes[i+1].Sum(nil)))
+	}
+	if *sidecars {
+		if err := writeSidecars(ctx, client, rootLoc, packageLoc, &rs, manifest); err != nil {
+			logger.Errorf("Error writing sidecar files for %q: %v", pkgPath, err)
+			metrics.syncError()
+		}
+	}
+	return rs, manifest, nil
+}
+
+func runSync(ctx context.Context, rootLoc string, rep *repo) error {
+	logger.Infof("Beginning sync run for repo %q", rep.Name)
+	start := time.Now()
+	packageLoc := rep.PackagePath
+	if rep.Upstream != "" {
+		if err := rep.mirror(ctx, rootLoc); err != nil {
+			logger.Errorf("Error mirroring %q: %v", rep.Upstream, err)
+			metrics.syncError()
+		}
+	}
 
 	var pkgs []string
 	var err error
 	var client *storage.Client
+	modTimes := make(map[string]time.Time)
+	versions := make(map[string]fileVersion)
+	status := make(map[string]string)
 
 	isGCSURL, bucket, folder := goolib.SplitGCSUrl(rootLoc)
 	if isGCSURL {
@@ -103,12 +320,19 @@ func runSync(ctx context.Context, rootLoc, packageLoc string) error {
 			if err != nil {
 				return err
 			}
+			// Status files may be empty.
+			if p, s, ok := statusFile(objAttr.Name); ok {
+				addStatus(status, p, s)
+				continue
+			}
 			if objAttr.Size == 0 {
 				continue
 			}
 
 			if strings.HasSuffix(objAttr.Name, ".goo") {
 				pkgs = append(pkgs, objAttr.Name)
+				modTimes[objAttr.Name] = objAttr.Updated
+				versions[objAttr.Name] = fileVersion{objAttr.Size, objAttr.Generation}
 			}
 		}
 	} else {
@@ -121,52 +345,291 @@ func runSync(ctx context.Context, rootLoc, packageLoc string) error {
 		if err != nil {
 			return err
 		}
+		for _, pkg := range pkgs {
+			fi, err := oswrap.Stat(pkg)
+			if err != nil {
+				return err
+			}
+			modTimes[pkg] = fi.ModTime()
+			versions[pkg] = fileVersion{fi.Size(), fi.ModTime().UnixNano()}
+		}
+		for _, s := range statuses {
+			files, err := filepath.Glob(filepath.Join(packageDir, "*.goo."+s))
+			if err != nil {
+				return err
+			}
+			for _, f := range files {
+				p, s, _ := statusFile(f)
+				addStatus(status, p, s)
+			}
+		}
 	}
 
-	repoContents = &repoPackages{}
+	rp := &repoPackages{}
+	keepManifests := *dedupStats || *dumpDedup
+	var mu sync.Mutex
+	manifests := make(map[string][]goolib.ManifestEntry)
 	var wg sync.WaitGroup
 	for _, pkgPath := range pkgs {
 		wg.Add(1)
 		go func(pkgPath string) {
 			defer wg.Done()
 
-			var r io.ReadCloser
-
-			r, err := getReader(ctx, client, rootLoc, packageLoc, pkgPath)
-			if err != nil {
-				logger.Error(err)
-				return
+			rs, manifest, ok := rep.cache.get(pkgPath, versions[pkgPath])
+			if !ok {
+				var err error
+				rs, manifest, err = readPackage(ctx, client, rootLoc, packageLoc, pkgPath)
+				if err != nil {
+					logger.Error(err)
+					metrics.syncError()
+					return
+				}
+				if !keepManifests {
+					manifest = nil
+				}
+				// Packages with missing sidecar files are read again on the
+				// next run so writing them is retried.
+				if !*sidecars || rs.SpecSource != "" {
+					rep.cache.put(pkgPath, versions[pkgPath], rs, manifest)
+				}
 			}
-			spec, err := goolib.ExtractPkgSpec(r)
-			if err != nil {
-				logger.Error(err)
+			if len(rep.Archs) > 0 && !goolib.ContainsString(rs.PackageSpec.Arch, rep.Archs) {
 				return
 			}
-
-			// Re-get the reader so we can get the checksum, GCS does not
-			// provide a seeker.
-			r, err = getReader(ctx, client, rootLoc, packageLoc, pkgPath)
-			if err != nil {
-				logger.Error(err)
-				return
+			if s, ok := status[pkgPath]; ok {
+				if err := readStatus(ctx, client, rootLoc, packageLoc, pkgPath, s, &rs); err != nil {
+					logger.Errorf("Error reading %s status of %q: %v", s, pkgPath, err)
+					metrics.syncError()
+				}
+			}
+			if n, ok := rep.Renames[rs.PackageSpec.Name]; ok {
+				rs.RenamedTo = n
+			}
+			rp.add(rs)
+			if keepManifests {
+				mu.Lock()
+				manifests[pkgPath] = manifest
+				mu.Unlock()
 			}
-			chksum := goolib.Checksum(r)
-
-			repoContents.add(pkgPath, chksum, spec)
 		}(pkgPath)
 	}
 	wg.Wait()
-	logger.Info("Sync run completed successfully")
+	rep.cache.prune(versions)
+	if p := (retentionPolicy{keepVersions: *keepVersions, keepNewerThan: *keepNewerThan}); p.enabled() {
+		rp.rs = applyRetention(ctx, client, rootLoc, packageLoc, p, rp.rs, modTimes)
+	}
+	if keepManifests {
+		rep.dedup.Store(analyzeDedup(rp.rs, manifests))
+	}
+
+	d, err := marshalIndex(rp.rs)
+	if err != nil {
+		return err
+	}
+	if *webUI {
+		if d.html, err = renderBrowse(rep.Name, rp.rs); err != nil {
+			return err
+		}
+	}
+	rep.index.set(d)
+	metrics.syncDone(rep.Name, start, len(rp.rs), len(d.data))
+	logger.Infof("Sync run for repo %q completed successfully", rep.Name)
 	return nil
 }
 
-func serve(w http.ResponseWriter, r *http.Request) {
-	out, err := json.MarshalIndent(repoContents.rs, "", "  ")
-	if err != nil {
-		logger.Fatal(err)
+// syncAll runs a sync for each repo, errors are logged.
+func syncAll(ctx context.Context, rootLoc string, repos []*repo) {
+	for _, r := range repos {
+		if err := runSync(ctx, rootLoc, r); err != nil {
+			logger.Errorf("Error syncing repo %q: %v", r.Name, err)
+			metrics.syncError()
+		}
+	}
+}
+
+// gzETag is the entity tag of the gzip compressed index, it differs from
+// etag as the representations differ.
+func (d *indexData) gzETag() string {
+	return strings.TrimSuffix(d.etag, `"`) + `-gz"`
+}
+
+// notModified sets the ETag header and reports whether the If-None-Match
+// header of r matches etag, in which case it responds with 304.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	for _, t := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// serve writes the JSON index, compressed if the client accepts gzip.
+func (rep *repo) serve(w http.ResponseWriter, r *http.Request) {
+	d := rep.index.get()
+	if d == nil {
+		unavailable(w)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(out)
+	w.Header().Set("Vary", "Accept-Encoding")
+	if acceptsGzip(r) {
+		if notModified(w, r, d.gzETag()) {
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(d.gz)
+		return
+	}
+	if notModified(w, r, d.etag) {
+		return
+	}
+	w.Write(d.data)
+}
+
+// serveGzip writes the gzip compressed index file.
+func (rep *repo) serveGzip(w http.ResponseWriter, r *http.Request) {
+	d := rep.index.get()
+	if d == nil {
+		unavailable(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-gzip")
+	if notModified(w, r, d.gzETag()) {
+		return
+	}
+	w.Write(d.gz)
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of r allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, e := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(e, ";")
+		if strings.TrimSpace(parts[0]) != "gzip" {
+			continue
+		}
+		for _, p := range parts[1:] {
+			if q := strings.TrimSpace(p); strings.HasPrefix(q, "q=") {
+				if v, err := strconv.ParseFloat(q[2:], 64); err == nil && v == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (rep *repo) serveSig(w http.ResponseWriter, r *http.Request) {
+	d := rep.index.get()
+	if d == nil {
+		unavailable(w)
+		return
+	}
+	sig := d.sig
+	if sig == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write(sig)
+}
+
+// servePubKey serves the public key of the index signatures.
+func servePubKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Write(pubKey)
+}
+
+// advisoriesFeed returns the location of the advisories feed of the repo
+// name, maintained by hand next to its saved index under rootLoc: the bucket
+// and object if rootLoc is a GCS URL, else the file.
+func advisoriesFeed(rootLoc, name string) (bool, string, string) {
+	if isGCSURL, bucket, folder := goolib.SplitGCSUrl(rootLoc); isGCSURL {
+		return true, bucket, path.Join(folder, name, "advisories.json")
+	}
+	return false, "", filepath.Join(rootLoc, name, "advisories.json")
+}
+
+// serveAdvisories serves the advisories feed of the repo name.
+func serveAdvisories(rootLoc, name string) http.HandlerFunc {
+	isGCSURL, bucket, feed := advisoriesFeed(rootLoc, name)
+	if !isGCSURL {
+		return func(w http.ResponseWriter, r *http.Request) {
+			http.ServeFile(w, r, feed)
+		}
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, err := storage.NewClient(r.Context())
+		if err != nil {
+			logger.Errorf("Error reading advisories of %s: %v", name, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		defer client.Close()
+		rd, err := client.Bucket(bucket).Object(feed).NewReader(r.Context())
+		if err == storage.ErrObjectNotExist {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			logger.Errorf("Error reading advisories of %s: %v", name, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		defer rd.Close()
+		w.Header().Set("Content-Type", "application/json")
+		io.Copy(w, rd)
+	}
+}
+
+// writeFile writes data to path, which may be a local path or a GCS URL.
+func writeFile(ctx context.Context, path string, data []byte) error {
+	logger.Infof("Writing %q", path)
+	if isGCSURL, bucket, object := goolib.SplitGCSUrl(path); isGCSURL {
+		client, err := storage.NewClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		w := client.Bucket(bucket).Object(object).NewWriter(ctx)
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		return w.Close()
+	}
+	if err := oswrap.MkdirAll(filepath.Dir(path), 0774); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// splitList splits a comma separated flag value.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// parseRenames parses the OLD=NEW pairs of the -renames flag.
+func parseRenames(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	m := make(map[string]string)
+	for _, r := range strings.Split(s, ",") {
+		old, n, ok := strings.Cut(r, "=")
+		if !ok || old == "" || n == "" {
+			return nil, fmt.Errorf("invalid rename %q, want OLD=NEW", r)
+		}
+		m[old] = n
+	}
+	return m, nil
 }
 
 func main() {
@@ -174,61 +637,168 @@ func main() {
 	ctx := context.Background()
 	logger.Init("GooServe", *verbose, *systemLog, ioutil.Discard)
 
-	if err := runSync(ctx, *root, *packagePath); err != nil {
-		logger.Error(err)
+	if *signKey != "" {
+		var err error
+		key, err = goolib.ReadSigningKey(*signKey)
+		if err != nil {
+			logger.Fatalf("Error reading signing key: %v", err)
+		}
+		pubKey, err = goolib.MarshalVerifyKey(key.Public().(ed25519.PublicKey))
+		if err != nil {
+			logger.Fatalf("Error encoding public key: %v", err)
+		}
 	}
 
-	if *dumpIndex || *saveIndex {
-		out, err := json.MarshalIndent(repoContents.rs, "", "  ")
-		if err != nil {
+	rn, err := parseRenames(*renames)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	for _, a := range splitList(*checksums) {
+		if _, err := goolib.NewHash(a); err != nil {
 			logger.Fatal(err)
 		}
-		if *dumpIndex {
+		if a = strings.ToLower(a); a != goolib.SHA256 {
+			checksumAlgos = append(checksumAlgos, a)
+		}
+	}
+	repos := []*repo{{Name: *repoName, PackagePath: *packagePath, Upstream: *upstream, Include: splitList(*upstreamInclude), Exclude: splitList(*upstreamExclude), Renames: rn}}
+	if *config != "" {
+		repos, err = readConfig(*config)
+		if err != nil {
+			logger.Fatalf("Error reading config: %v", err)
+		}
+	}
+
+	if *dumpDedup {
+		syncAll(ctx, *root, repos)
+		for _, r := range repos {
+			out, err := json.MarshalIndent(map[string]*dedupReport{r.Name: r.dedup.Load()}, "", "  ")
+			if err != nil {
+				logger.Fatal(err)
+			}
 			fmt.Println(string(out))
 		}
-		if *saveIndex {
-			index := fmt.Sprintf("%s/%s/index", *root, *repoName)
-			logger.Infof("Writing index to %q", index)
-			if isGCSURL, bucket, object := goolib.SplitGCSUrl(index); isGCSURL {
-				client, err := storage.NewClient(ctx)
-				if err != nil {
-					logger.Fatal(err)
-				}
-				defer client.Close()
+		return
+	}
 
-				w := client.Bucket(bucket).Object(object).NewWriter(ctx)
-				if _, err := w.Write(out); err != nil {
+	if *dumpIndex || *saveIndex {
+		syncAll(ctx, *root, repos)
+		for _, r := range repos {
+			d := r.index.get()
+			if d == nil {
+				logger.Errorf("No index for repo %q", r.Name)
+				continue
+			}
+			if *dumpIndex {
+				fmt.Println(string(d.data))
+			}
+			if *saveIndex {
+				index := fmt.Sprintf("%s/%s/index", *root, r.Name)
+				if err := writeFile(ctx, index, d.data); err != nil {
 					logger.Fatal(err)
 				}
-				if err := w.Close(); err != nil {
+				if err := writeFile(ctx, index+".gz", d.gz); err != nil {
 					logger.Fatal(err)
 				}
-			} else {
-				if err := oswrap.MkdirAll(filepath.Join(*root, *repoName), 0774); err != nil {
-					logger.Fatal(err)
+				if d.sig != nil {
+					if err := writeFile(ctx, index+goolib.SignatureSuffix, d.sig); err != nil {
+						logger.Fatal(err)
+					}
 				}
-				err := ioutil.WriteFile(index, out, 0644)
-				if err != nil {
-					logger.Fatal(err)
+				if pubKey != nil {
+					if err := writeFile(ctx, index+goolib.PublicKeySuffix, pubKey); err != nil {
+						logger.Fatal(err)
+					}
 				}
 			}
 		}
 		return
 	}
 
-	http.HandleFunc(fmt.Sprintf("/%s/index", *repoName), serve)
-	prefix := "/" + *packagePath + "/"
-	http.Handle(prefix, http.StripPrefix(prefix, http.FileServer(http.Dir(filepath.Join(*root, *packagePath)))))
+	served := make(map[string]bool)
+	for _, r := range repos {
+		index := fmt.Sprintf("/%s/index", r.Name)
+		http.Handle(index, instrument(index, false, http.HandlerFunc(r.serve)))
+		http.Handle(index+".gz", instrument(index+".gz", false, http.HandlerFunc(r.serveGzip)))
+		http.Handle(index+goolib.SignatureSuffix, instrument(index+goolib.SignatureSuffix, false, http.HandlerFunc(r.serveSig)))
+		if pubKey != nil {
+			http.Handle(index+goolib.PublicKeySuffix, instrument(index+goolib.PublicKeySuffix, false, http.HandlerFunc(servePubKey)))
+		}
+		if *webUI {
+			p := fmt.Sprintf("/%s/browse", r.Name)
+			http.Handle(p, instrument(p, false, http.HandlerFunc(r.serveBrowse)))
+		}
+		if *dedupStats {
+			p := fmt.Sprintf("/%s/dedup", r.Name)
+			http.Handle(p, instrument(p, false, http.HandlerFunc(r.serveDedup)))
+		}
+		if *statusEndpoint {
+			p := fmt.Sprintf("/%s/status", r.Name)
+			http.Handle(p, instrument(p, false, http.HandlerFunc(r.serveStatus)))
+		}
+		advisories := fmt.Sprintf("/%s/advisories.json", r.Name)
+		http.Handle(advisories, instrument(advisories, false, serveAdvisories(*root, r.Name)))
+		prefix := "/" + r.PackagePath + "/"
+		if served[prefix] {
+			continue
+		}
+		served[prefix] = true
+		http.Handle(prefix, instrument(prefix, true, http.StripPrefix(prefix, http.FileServer(http.Dir(filepath.Join(*root, r.PackagePath))))))
+	}
+	http.HandleFunc("/metrics", serveMetrics)
+	if *syncEndpoint {
+		http.Handle("/sync", instrument("/sync", false, http.HandlerFunc(serveSync)))
+	}
+	http.HandleFunc("/healthz", serveHealthz)
+	http.HandleFunc("/readyz", serveReadyz)
+	var limiter *rateLimiter
+	if *rateLimit > 0 {
+		limiter = newRateLimiter(*rateLimit, *rateBurst)
+		metrics.setLimits(limiter.rate, int(limiter.burst), *maxRequestBytes)
+	} else {
+		metrics.setLimits(0, 0, *maxRequestBytes)
+	}
+	// The signals are handled before anything is served, a SIGTERM during
+	// the initial sync cancels it and shuts the server down.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	sigCtx, cancelSync := context.WithCancel(ctx)
+	defer cancelSync()
+	var sig os.Signal
+	go func() {
+		sig = <-stop
+		cancelSync()
+	}()
+
+	srv := &http.Server{Addr: fmt.Sprintf("%s:%d", *address, *port), Handler: limit(http.DefaultServeMux, limiter, *maxRequestBytes)}
 	go func() {
-		err := http.ListenAndServe(fmt.Sprintf("%s:%d", *address, *port), nil)
-		if err != nil {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Fatal(err)
 		}
 	}()
 
-	for range time.Tick(*interval) {
-		if err := runSync(ctx, *root, *packagePath); err != nil {
-			logger.Error(err)
+	syncAll(sigCtx, *root, repos)
+	if sigCtx.Err() == nil {
+		ready.Store(true)
+		logger.Info("Initial sync run completed, ready to serve")
+	}
+
+	tick := time.NewTicker(*interval)
+	for {
+		select {
+		case <-tick.C:
+		case <-syncTrigger:
+			logger.Info("Sync run triggered")
+		case <-sigCtx.Done():
+			logger.Infof("Received %v, draining in-flight requests", sig)
+			ready.Store(false)
+			sctx, cancel := context.WithTimeout(ctx, *shutdownTimeout)
+			defer cancel()
+			if err := srv.Shutdown(sctx); err != nil {
+				logger.Errorf("Error shutting down: %v", err)
+			}
+			return
 		}
+		syncAll(sigCtx, *root, repos)
 	}
 }
@@ -0,0 +1,366 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/googet/v2/googettest"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/logger"
+)
+
+func init() {
+	logger.Init("test", true, false, ioutil.Discard)
+}
+
+// indexed returns the packages in the index of r.
+func indexed(t *testing.T, r *repo) []goolib.RepoSpec {
+	t.Helper()
+	d := r.index.get()
+	if d == nil {
+		t.Fatalf("repo %q has no index", r.Name)
+	}
+	var rs []goolib.RepoSpec
+	if err := json.Unmarshal(d.data, &rs); err != nil {
+		t.Fatalf("error decoding index of repo %q: %v", r.Name, err)
+	}
+	return rs
+}
+
+func TestReadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	table := []struct {
+		config string
+		repos  int
+		werr   string
+	}{
+		{"repos:\n- name: stable\n  packagepath: stable\n  archs: [x86_64]\n- name: all\n", 2, ""},
+		{"repos: []\n", 0, "no repos defined"},
+		{"repos:\n- packagepath: stable\n", 0, "repo with no name"},
+		{"repos:\n- name: a\n- name: a\n", 0, "defined more than once"},
+	}
+	for _, tt := range table {
+		p := filepath.Join(dir, "gooserve.yaml")
+		if err := ioutil.WriteFile(p, []byte(tt.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		repos, err := readConfig(p)
+		if tt.werr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.werr) {
+				t.Errorf("readConfig(%q) returned error %v, want %q", tt.config, err, tt.werr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("readConfig(%q) returned error: %v", tt.config, err)
+			continue
+		}
+		if len(repos) != tt.repos {
+			t.Errorf("readConfig(%q) returned %d repos, want %d", tt.config, len(repos), tt.repos)
+		}
+	}
+}
+
+func TestRunSyncArchFilter(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.Mkdir(filepath.Join(root, "packages"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, arch := range []string{"noarch", "x86_64"} {
+		pkg, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: arch, Version: "1.0.0@1"}, nil)
+		if err != nil {
+			t.Fatalf("error running GenGoo: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, "packages", pkg.Name()), pkg.Data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all := &repo{Name: "all", PackagePath: "packages"}
+	x64 := &repo{Name: "x64", PackagePath: "packages", Archs: []string{"x86_64"}}
+	for _, r := range []*repo{all, x64} {
+		if err := runSync(context.Background(), root, r); err != nil {
+			t.Fatalf("error running runSync for %q: %v", r.Name, err)
+		}
+	}
+	if got := len(indexed(t, all)); got != 2 {
+		t.Errorf("repo %q has %d packages, want 2", all.Name, got)
+	}
+	if rs := indexed(t, x64); len(rs) != 1 || rs[0].PackageSpec.Arch != "x86_64" {
+		t.Errorf("repo %q has unexpected packages: %+v", x64.Name, rs)
+	}
+}
+
+func TestRunSyncRenames(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.Mkdir(filepath.Join(root, "packages"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"foo", "bar"} {
+		pkg, err := googettest.GenGoo(&goolib.PkgSpec{Name: name, Arch: "noarch", Version: "1.0.0@1"}, nil)
+		if err != nil {
+			t.Fatalf("error running GenGoo: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, "packages", pkg.Name()), pkg.Data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rn, err := parseRenames("foo=foo-ng,gone=other")
+	if err != nil {
+		t.Fatalf("parseRenames: %v", err)
+	}
+	r := &repo{Name: "repo", PackagePath: "packages", Renames: rn}
+	if err := runSync(context.Background(), root, r); err != nil {
+		t.Fatalf("error running runSync: %v", err)
+	}
+	for _, rs := range indexed(t, r) {
+		if want := map[string]string{"foo": "foo-ng"}[rs.PackageSpec.Name]; rs.RenamedTo != want {
+			t.Errorf("%s is renamed to %q, want %q", rs.PackageSpec.Name, rs.RenamedTo, want)
+		}
+	}
+
+	for _, bad := range []string{"foo", "foo=", "=bar", "a=b,c"} {
+		if _, err := parseRenames(bad); err == nil {
+			t.Errorf("parseRenames(%q) did not fail", bad)
+		}
+	}
+}
+
+func TestRunSyncCache(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.Mkdir(filepath.Join(root, "packages"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}, nil)
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	pkgPath := filepath.Join(root, "packages", pkg.Name())
+	if err := ioutil.WriteFile(pkgPath, pkg.Data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &repo{Name: "repo", PackagePath: "packages"}
+	sync := func() goolib.RepoSpec {
+		t.Helper()
+		if err := runSync(context.Background(), root, r); err != nil {
+			t.Fatalf("error running runSync: %v", err)
+		}
+		rs := indexed(t, r)
+		if len(rs) != 1 {
+			t.Fatalf("repo has %d packages, want 1", len(rs))
+		}
+		return rs[0]
+	}
+
+	sync()
+	// Unchanged packages are served from the cache.
+	e := r.cache.entries[pkgPath]
+	e.rs.Checksum = "cached"
+	r.cache.entries[pkgPath] = e
+	if got := sync().Checksum; got != "cached" {
+		t.Errorf("unchanged package was read again, got checksum %q", got)
+	}
+
+	// A new modification time invalidates the entry.
+	mt := time.Now().Add(time.Hour)
+	if err := os.Chtimes(pkgPath, mt, mt); err != nil {
+		t.Fatal(err)
+	}
+	if got := sync().Checksum; got != pkg.Checksum {
+		t.Errorf("modified package was not read again, got checksum %q, want %q", got, pkg.Checksum)
+	}
+
+	if err := os.Remove(pkgPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := runSync(context.Background(), root, r); err != nil {
+		t.Fatalf("error running runSync: %v", err)
+	}
+	if len(r.cache.entries) != 0 {
+		t.Errorf("cache has %d entries after package removal, want 0", len(r.cache.entries))
+	}
+}
+
+func TestServeSync(t *testing.T) {
+	w := httptest.NewRecorder()
+	serveSync(w, httptest.NewRequest(http.MethodGet, "/sync", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /sync returned %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+	for i := 0; i < 2; i++ {
+		w = httptest.NewRecorder()
+		serveSync(w, httptest.NewRequest(http.MethodPost, "/sync", nil))
+		if w.Code != http.StatusAccepted {
+			t.Errorf("POST /sync returned %d, want %d", w.Code, http.StatusAccepted)
+		}
+	}
+	<-syncTrigger
+	select {
+	case <-syncTrigger:
+		t.Error("pending sync requests were not coalesced")
+	default:
+	}
+}
+
+func TestServeIndex(t *testing.T) {
+	d, err := marshalIndex([]goolib.RepoSpec{{Source: "packages/foo.goo", Checksum: "abc"}})
+	if err != nil {
+		t.Fatalf("error running marshalIndex: %v", err)
+	}
+	r := &repo{Name: "repo"}
+	r.index.set(d)
+
+	table := []struct {
+		handler         http.HandlerFunc
+		acceptEncoding  string
+		contentType     string
+		contentEncoding string
+		gzipped         bool
+	}{
+		{r.serve, "", "application/json", "", false},
+		{r.serve, "gzip;q=0", "application/json", "", false},
+		{r.serve, "br, gzip;q=0.5", "application/json", "gzip", true},
+		{r.serveGzip, "", "application/x-gzip", "", true},
+	}
+	for i, tt := range table {
+		req := httptest.NewRequest(http.MethodGet, "/repo/index", nil)
+		if tt.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		tt.handler(w, req)
+		if got := w.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%d: Content-Type = %q, want %q", i, got, tt.contentType)
+		}
+		if got := w.Header().Get("Content-Encoding"); got != tt.contentEncoding {
+			t.Errorf("%d: Content-Encoding = %q, want %q", i, got, tt.contentEncoding)
+		}
+		body := w.Body.Bytes()
+		if tt.gzipped {
+			gr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("%d: error reading gzip body: %v", i, err)
+			}
+			if body, err = ioutil.ReadAll(gr); err != nil {
+				t.Fatalf("%d: error reading gzip body: %v", i, err)
+			}
+		}
+		if !bytes.Equal(body, d.data) {
+			t.Errorf("%d: served index %q, want %q", i, body, d.data)
+		}
+	}
+}
+
+func TestServeIndexETag(t *testing.T) {
+	d, err := marshalIndex([]goolib.RepoSpec{{Source: "packages/foo.goo", Checksum: "abc"}})
+	if err != nil {
+		t.Fatalf("error running marshalIndex: %v", err)
+	}
+	r := &repo{Name: "repo"}
+	r.index.set(d)
+
+	table := []struct {
+		handler        http.HandlerFunc
+		acceptEncoding string
+		ifNoneMatch    string
+		etag           string
+		status         int
+	}{
+		{r.serve, "", "", d.etag, http.StatusOK},
+		{r.serve, "", d.etag, d.etag, http.StatusNotModified},
+		{r.serve, "", `"other", W/` + d.etag, d.etag, http.StatusNotModified},
+		{r.serve, "", "*", d.etag, http.StatusNotModified},
+		{r.serve, "", d.gzETag(), d.etag, http.StatusOK},
+		{r.serve, "gzip", d.gzETag(), d.gzETag(), http.StatusNotModified},
+		{r.serve, "gzip", d.etag, d.gzETag(), http.StatusOK},
+		{r.serveGzip, "", d.gzETag(), d.gzETag(), http.StatusNotModified},
+		{r.serveGzip, "", `"other"`, d.gzETag(), http.StatusOK},
+	}
+	for i, tt := range table {
+		req := httptest.NewRequest(http.MethodGet, "/repo/index", nil)
+		if tt.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		if tt.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		tt.handler(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%d: status = %d, want %d", i, w.Code, tt.status)
+		}
+		if got := w.Header().Get("ETag"); got != tt.etag {
+			t.Errorf("%d: ETag = %q, want %q", i, got, tt.etag)
+		}
+		if tt.status == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("%d: 304 response has a body", i)
+		}
+	}
+
+	// The tag changes with the index.
+	d2, err := marshalIndex([]goolib.RepoSpec{{Source: "packages/bar.goo", Checksum: "abc"}})
+	if err != nil {
+		t.Fatalf("error running marshalIndex: %v", err)
+	}
+	if d2.etag == d.etag {
+		t.Errorf("indexes with different content have the same ETag %q", d.etag)
+	}
+}
+
+func TestAdvisoriesFeed(t *testing.T) {
+	for _, tt := range []struct {
+		root, bucket, feed string
+		gcs                bool
+	}{
+		{"gs://bucket", "bucket", "repo/advisories.json", true},
+		{"gs://bucket/googet/", "bucket", "googet/repo/advisories.json", true},
+		{"/srv/googet", "", filepath.Join("/srv/googet", "repo", "advisories.json"), false},
+	} {
+		gcs, bucket, feed := advisoriesFeed(tt.root, "repo")
+		if gcs != tt.gcs || bucket != tt.bucket || feed != tt.feed {
+			t.Errorf("advisoriesFeed(%q) = %t, %q, %q, want %t, %q, %q", tt.root, gcs, bucket, feed, tt.gcs, tt.bucket, tt.feed)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// ready is set once the initial sync run completed and cleared on shutdown.
+var ready atomic.Bool
+
+// serveHealthz reports that the process is up.
+func serveHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok\n"))
+}
+
+// serveReadyz reports whether the server is ready to serve indexes.
+func serveReadyz(w http.ResponseWriter, r *http.Request) {
+	if !ready.Load() {
+		unavailable(w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok\n"))
+}
+
+// unavailable responds with 503, used until an index is available.
+func unavailable(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "5")
+	http.Error(w, "initial sync in progress", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	defer ready.Store(false)
+	r := &repo{Name: "repo"}
+
+	get := func(h http.HandlerFunc) int {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w.Code
+	}
+
+	if got := get(serveHealthz); got != http.StatusOK {
+		t.Errorf("healthz returned %d, want %d", got, http.StatusOK)
+	}
+	for _, h := range []http.HandlerFunc{serveReadyz, r.serve, r.serveGzip, r.serveSig} {
+		if got := get(h); got != http.StatusServiceUnavailable {
+			t.Errorf("handler returned %d before initial sync, want %d", got, http.StatusServiceUnavailable)
+		}
+	}
+
+	d, err := marshalIndex(nil)
+	if err != nil {
+		t.Fatalf("error running marshalIndex: %v", err)
+	}
+	r.index.set(d)
+	ready.Store(true)
+	for _, h := range []http.HandlerFunc{serveReadyz, r.serve, r.serveGzip} {
+		if got := get(h); got != http.StatusOK {
+			t.Errorf("handler returned %d after initial sync, want %d", got, http.StatusOK)
+		}
+	}
+}
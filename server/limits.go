@@ -0,0 +1,123 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Requests are rate limited per client IP with a token bucket, and request
+// bodies are limited in size, before they reach the handlers.
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// unlimited are the paths that are never rate limited, so probes and
+// scrapers keep working while clients are throttled.
+var unlimited = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// bucket is the token bucket of a single client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter allows each client rate requests per second on average and
+// bursts of up to burst requests.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	clients   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &rateLimiter{rate: rate, burst: float64(burst), clients: make(map[string]*bucket)}
+}
+
+// allow takes a token from the bucket of client. If there is none it returns
+// false and the time until the next token is available.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+	b, ok := l.clients[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.clients[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep forgets clients whose bucket has refilled, they are indistinguishable
+// from new clients. It runs at most once a minute.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for c, b := range l.clients {
+		if now.Sub(b.last) >= full {
+			delete(l.clients, c)
+		}
+	}
+}
+
+// clientIP returns the IP address of the client that sent r.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limit wraps h so requests are rejected with 429 when l, if not nil, has no
+// token for the client and with 413 when their body exceeds maxBytes, if
+// positive.
+func limit(h http.Handler, l *rateLimiter, maxBytes int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l != nil && !unlimited[r.URL.Path] {
+			if ok, wait := l.allow(clientIP(r), time.Now()); !ok {
+				metrics.rateLimited()
+				w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+		}
+		if maxBytes > 0 {
+			if r.ContentLength > maxBytes {
+				metrics.oversized()
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(2, 3)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow("a", now); !ok {
+			t.Fatalf("request %d within the burst was limited", i)
+		}
+	}
+	ok, wait := l.allow("a", now)
+	if ok {
+		t.Fatal("request over the burst was allowed")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("wait = %v, want 500ms", wait)
+	}
+	if ok, _ := l.allow("b", now); !ok {
+		t.Error("request of another client was limited")
+	}
+	if ok, _ := l.allow("a", now.Add(wait)); !ok {
+		t.Error("request after the wait was limited")
+	}
+
+	// Idle clients are forgotten.
+	l.allow("a", now.Add(time.Hour))
+	if len(l.clients) != 1 {
+		t.Errorf("limiter tracks %d clients after sweep, want 1", len(l.clients))
+	}
+}
+
+func TestLimit(t *testing.T) {
+	metrics = newServerMetrics()
+	h := limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+	}), newRateLimiter(1, 2), 10)
+
+	table := []struct {
+		path   string
+		body   string
+		remote string
+		code   int
+	}{
+		{"/repo/index", "", "192.0.2.1:1234", http.StatusOK},
+		{"/repo/status", strings.Repeat("x", 11), "192.0.2.1:1235", http.StatusRequestEntityTooLarge},
+		{"/repo/index", "", "192.0.2.1:1236", http.StatusTooManyRequests},
+		{"/healthz", "", "192.0.2.1:1237", http.StatusOK},
+		{"/repo/index", "", "192.0.2.2:1234", http.StatusOK},
+	}
+	for i, tt := range table {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		req.RemoteAddr = tt.remote
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%d: status = %d, want %d", i, w.Code, tt.code)
+		}
+		if tt.code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "1" {
+			t.Errorf("%d: Retry-After = %q, want 1", i, w.Header().Get("Retry-After"))
+		}
+	}
+
+	// Bodies without a length are cut off while reading.
+	req := httptest.NewRequest(http.MethodPost, "/sync", ioutil.NopCloser(strings.NewReader(strings.Repeat("x", 11))))
+	req.ContentLength = -1
+	req.RemoteAddr = "192.0.2.3:1234"
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status of chunked request = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	metrics.setLimits(1, 2, 10)
+	w = httptest.NewRecorder()
+	serveMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	got := w.Body.String()
+	for _, want := range []string{
+		"gooserve_rate_limit_requests_per_second 1\n",
+		"gooserve_rate_limit_burst 2\n",
+		"gooserve_max_request_bytes 10\n",
+		"gooserve_rate_limited_requests_total 1\n",
+		"gooserve_oversized_requests_total 1\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics output missing %q, got:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// Metrics are exported on /metrics in the Prometheus text exposition format.
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+type requestKey struct {
+	endpoint string
+	code     int
+}
+
+type latency struct {
+	sum   float64
+	count int
+}
+
+// repoStatus is the result of the last successful sync run of a repo.
+type repoStatus struct {
+	packages         int
+	indexBytes       int
+	lastSync         time.Time
+	lastSyncDuration time.Duration
+}
+
+// serverMetrics collects the values exported on /metrics.
+type serverMetrics struct {
+	mu         sync.Mutex
+	repos      map[string]*repoStatus
+	syncErrors int
+	requests   map[requestKey]int
+	latencies  map[string]*latency
+	downloads  map[string]int
+
+	// limits are the configured request limits, see limit.
+	rateLimit       float64
+	rateBurst       int
+	maxRequestBytes int64
+	limitedRequests int
+	oversizedBodies int
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		repos:     make(map[string]*repoStatus),
+		requests:  make(map[requestKey]int),
+		latencies: make(map[string]*latency),
+		downloads: make(map[string]int),
+	}
+}
+
+var metrics = newServerMetrics()
+
+// syncDone records a completed sync run of repo.
+func (m *serverMetrics) syncDone(repo string, start time.Time, packages, indexBytes int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.repos[repo] = &repoStatus{
+		packages:         packages,
+		indexBytes:       indexBytes,
+		lastSync:         now,
+		lastSyncDuration: now.Sub(start),
+	}
+}
+
+// syncError records an error encountered during a sync run.
+func (m *serverMetrics) syncError() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.syncErrors++
+}
+
+func (m *serverMetrics) request(endpoint string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{endpoint, code}]++
+	l, ok := m.latencies[endpoint]
+	if !ok {
+		l = &latency{}
+		m.latencies[endpoint] = l
+	}
+	l.sum += d.Seconds()
+	l.count++
+}
+
+func (m *serverMetrics) download(pkg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.downloads[pkg]++
+}
+
+// setLimits records the configured request limits, zero means unlimited.
+func (m *serverMetrics) setLimits(rate float64, burst int, maxBytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rateLimit, m.rateBurst, m.maxRequestBytes = rate, burst, maxBytes
+}
+
+func (m *serverMetrics) rateLimited() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limitedRequests++
+}
+
+func (m *serverMetrics) oversized() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.oversizedBodies++
+}
+
+func sortedKeys(m map[string]int) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeTo writes all metrics to w in the Prometheus text format.
+func (m *serverMetrics) writeTo(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var repos []string
+	for k := range m.repos {
+		repos = append(repos, k)
+	}
+	sort.Strings(repos)
+	gauges := []struct {
+		name, help string
+		value      func(*repoStatus) string
+	}{
+		{"gooserve_packages", "Number of packages in the index.", func(s *repoStatus) string { return fmt.Sprint(s.packages) }},
+		{"gooserve_index_size_bytes", "Size of the serialized index.", func(s *repoStatus) string { return fmt.Sprint(s.indexBytes) }},
+		{"gooserve_last_sync_timestamp_seconds", "Time the last successful sync run completed.", func(s *repoStatus) string { return fmt.Sprint(s.lastSync.Unix()) }},
+		{"gooserve_last_sync_duration_seconds", "Duration of the last successful sync run.", func(s *repoStatus) string { return fmt.Sprintf("%g", s.lastSyncDuration.Seconds()) }},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(w, "# HELP %s %s\n", g.name, g.help)
+		fmt.Fprintf(w, "# TYPE %s gauge\n", g.name)
+		for _, r := range repos {
+			fmt.Fprintf(w, "%s{repo=%q} %s\n", g.name, r, g.value(m.repos[r]))
+		}
+	}
+	fmt.Fprintln(w, "# HELP gooserve_sync_errors_total Errors encountered during sync runs.")
+	fmt.Fprintln(w, "# TYPE gooserve_sync_errors_total counter")
+	fmt.Fprintf(w, "gooserve_sync_errors_total %d\n", m.syncErrors)
+
+	limits := []struct {
+		name, help, typ string
+		value           interface{}
+	}{
+		{"gooserve_rate_limit_requests_per_second", "Requests per second allowed per client IP, 0 if unlimited.", "gauge", m.rateLimit},
+		{"gooserve_rate_limit_burst", "Burst of requests allowed per client IP.", "gauge", m.rateBurst},
+		{"gooserve_max_request_bytes", "Maximum size of a request body, 0 if unlimited.", "gauge", m.maxRequestBytes},
+		{"gooserve_rate_limited_requests_total", "Requests rejected by the rate limit.", "counter", m.limitedRequests},
+		{"gooserve_oversized_requests_total", "Requests rejected because of the size of their body.", "counter", m.oversizedBodies},
+	}
+	for _, l := range limits {
+		fmt.Fprintf(w, "# HELP %s %s\n", l.name, l.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", l.name, l.typ)
+		fmt.Fprintf(w, "%s %v\n", l.name, l.value)
+	}
+
+	fmt.Fprintln(w, "# HELP gooserve_http_requests_total HTTP requests by endpoint and status code.")
+	fmt.Fprintln(w, "# TYPE gooserve_http_requests_total counter")
+	var rks []requestKey
+	for k := range m.requests {
+		rks = append(rks, k)
+	}
+	sort.Slice(rks, func(i, j int) bool {
+		if rks[i].endpoint != rks[j].endpoint {
+			return rks[i].endpoint < rks[j].endpoint
+		}
+		return rks[i].code < rks[j].code
+	})
+	for _, k := range rks {
+		fmt.Fprintf(w, "gooserve_http_requests_total{endpoint=%q,code=\"%d\"} %d\n", k.endpoint, k.code, m.requests[k])
+	}
+
+	fmt.Fprintln(w, "# HELP gooserve_http_request_duration_seconds HTTP request latency by endpoint.")
+	fmt.Fprintln(w, "# TYPE gooserve_http_request_duration_seconds summary")
+	var eps []string
+	for k := range m.latencies {
+		eps = append(eps, k)
+	}
+	sort.Strings(eps)
+	for _, e := range eps {
+		l := m.latencies[e]
+		fmt.Fprintf(w, "gooserve_http_request_duration_seconds_sum{endpoint=%q} %g\n", e, l.sum)
+		fmt.Fprintf(w, "gooserve_http_request_duration_seconds_count{endpoint=%q} %d\n", e, l.count)
+	}
+
+	fmt.Fprintln(w, "# HELP gooserve_package_downloads_total Successful package downloads by package file.")
+	fmt.Fprintln(w, "# TYPE gooserve_package_downloads_total counter")
+	for _, p := range sortedKeys(m.downloads) {
+		fmt.Fprintf(w, "gooserve_package_downloads_total{package=%q} %d\n", p, m.downloads[p])
+	}
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// instrument wraps h so requests to it are recorded under endpoint. Package
+// downloads are additionally counted per package when countDownloads is set.
+func instrument(endpoint string, countDownloads bool, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		metrics.request(endpoint, rec.code, time.Since(start))
+		if countDownloads && rec.code == http.StatusOK && r.Method == http.MethodGet {
+			metrics.download(path.Base(r.URL.Path))
+		}
+	})
+}
+
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metrics.writeTo(w)
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics = newServerMetrics()
+	metrics.syncDone("repo", time.Now(), 2, 100)
+	metrics.syncError()
+
+	h := instrument("packages", true, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "missing.goo") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("data"))
+	}))
+	for _, p := range []string{"/packages/foo.goo", "/packages/foo.goo", "/packages/missing.goo"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+	}
+
+	w := httptest.NewRecorder()
+	serveMetrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	got := w.Body.String()
+	for _, want := range []string{
+		`gooserve_packages{repo="repo"} 2` + "\n",
+		`gooserve_index_size_bytes{repo="repo"} 100` + "\n",
+		"gooserve_sync_errors_total 1\n",
+		`gooserve_http_requests_total{endpoint="packages",code="200"} 2` + "\n",
+		`gooserve_http_requests_total{endpoint="packages",code="404"} 1` + "\n",
+		`gooserve_http_request_duration_seconds_count{endpoint="packages"} 3` + "\n",
+		`gooserve_package_downloads_total{package="foo.goo"} 2` + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics output missing %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "missing.goo") {
+		t.Error("metrics output counts failed download")
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// A repo with an upstream is a mirror, each sync run first copies the
+// packages of the upstream repo that are missing locally into the package
+// path, the regular scan then adds them to the index.
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/logger"
+)
+
+// mirrored reports whether the package name matches the include and exclude
+// patterns of the repo.
+func (rep *repo) mirrored(name string) bool {
+	for _, p := range rep.Exclude {
+		if ok, _ := path.Match(p, name); ok {
+			return false
+		}
+	}
+	if len(rep.Include) == 0 {
+		return true
+	}
+	for _, p := range rep.Include {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// upstreamTransport limits connecting to upstream repos and waiting for their
+// answers.
+var upstreamTransport = &http.Transport{
+	Proxy:                 http.ProxyFromEnvironment,
+	DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: time.Minute,
+}
+
+// upstreamClient returns the client upstream repos are fetched with, so that
+// an unresponsive upstream fails the sync run instead of stalling it.
+func upstreamClient() *http.Client {
+	return &http.Client{Timeout: *upstreamTimeout, Transport: upstreamTransport}
+}
+
+// fetchIndex downloads the index of the upstream repo at url.
+func fetchIndex(ctx context.Context, url string) ([]goolib.RepoSpec, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/index", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := upstreamClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s/index: %s", url, resp.Status)
+	}
+	var rs []goolib.RepoSpec
+	if err := json.NewDecoder(resp.Body).Decode(&rs); err != nil {
+		return nil, fmt.Errorf("error decoding index of %s: %v", url, err)
+	}
+	return rs, nil
+}
+
+// mirror copies the packages of the upstream repo that match the filters and
+// are missing locally.
+func (rep *repo) mirror(ctx context.Context, rootLoc string) error {
+	logger.Infof("Mirroring %q into repo %q", rep.Upstream, rep.Name)
+	upstream := strings.TrimSuffix(rep.Upstream, "/")
+	rs, err := fetchIndex(ctx, upstream)
+	if err != nil {
+		return err
+	}
+
+	var client *storage.Client
+	isGCSURL, bucket, folder := goolib.SplitGCSUrl(rootLoc)
+	if isGCSURL {
+		client, err = storage.NewClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+	}
+	if rep.fetched == nil {
+		rep.fetched = make(map[string]bool)
+	}
+	// Sources are relative to the parent of the repo URL, see
+	// download.PackageURL.
+	base := upstream[:strings.LastIndex(upstream, "/")+1]
+	for _, r := range rs {
+		if r.PackageSpec == nil || !rep.mirrored(r.PackageSpec.Name) || rep.fetched[r.Source] {
+			continue
+		}
+		pkgPath := path.Base(r.Source)
+		if isGCSURL {
+			pkgPath = path.Join(folder, rep.PackagePath, pkgPath)
+		}
+		dst := packageFile(rootLoc, rep.PackagePath, pkgPath, "")
+		if isGCSURL {
+			_, err = client.Bucket(bucket).Object(pkgPath).Attrs(ctx)
+		} else {
+			_, err = oswrap.Stat(dst)
+		}
+		if err != nil && !os.IsNotExist(err) && err != storage.ErrObjectNotExist {
+			return err
+		}
+		if err != nil {
+			logger.Infof("Mirroring %q to %q", base+r.Source, dst)
+			if err := fetchPackage(ctx, client, base+r.Source, dst, r.BestChecksum()); err != nil {
+				logger.Errorf("Error mirroring %q: %v", r.Source, err)
+				metrics.syncError()
+				continue
+			}
+		}
+		rep.fetched[r.Source] = true
+	}
+	return nil
+}
+
+// fetchPackage downloads url to dst, which may be a local path or a GCS URL,
+// and verifies its checksum. Nothing is left at dst if the download fails.
+func fetchPackage(ctx context.Context, client *storage.Client, url, dst, checksum string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := upstreamClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	algo, want, err := goolib.ParseChecksum(checksum)
+	if err != nil {
+		return err
+	}
+	hash, err := goolib.NewHash(algo)
+	if err != nil {
+		return err
+	}
+	body := io.TeeReader(resp.Body, hash)
+	verify := func() error {
+		if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+			return fmt.Errorf("%s checksum mismatch, got %s, want %s", algo, got, want)
+		}
+		return nil
+	}
+
+	if isGCSURL, bucket, object := goolib.SplitGCSUrl(dst); isGCSURL {
+		// Canceling the context aborts the upload.
+		wctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		w := client.Bucket(bucket).Object(object).NewWriter(wctx)
+		if _, err := io.Copy(w, body); err != nil {
+			cancel()
+			w.Close()
+			return err
+		}
+		if err := verify(); err != nil {
+			cancel()
+			w.Close()
+			return err
+		}
+		return w.Close()
+	}
+
+	if err := oswrap.MkdirAll(filepath.Dir(dst), 0774); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(dst), filepath.Base(dst)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := verify(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return oswrap.Rename(f.Name(), dst)
+}
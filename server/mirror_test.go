@@ -0,0 +1,90 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/googet/v2/googettest"
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestRunSyncMirror(t *testing.T) {
+	var pkgs []*googettest.Package
+	for _, name := range []string{"foo", "foo-debug", "bar"} {
+		p, err := googettest.GenGoo(&goolib.PkgSpec{Name: name, Arch: "noarch", Version: "1.0.0@1"}, nil)
+		if err != nil {
+			t.Fatalf("error running GenGoo: %v", err)
+		}
+		pkgs = append(pkgs, p)
+	}
+	// A package whose content does not match the index is not mirrored.
+	bad, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo-bad", Arch: "noarch", Version: "1.0.0@1"}, nil)
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	bad.Checksum = "abc"
+	srv, url, err := googettest.ServeGoo(append(pkgs, bad)...)
+	if err != nil {
+		t.Fatalf("error running ServeGoo: %v", err)
+	}
+	defer srv.Close()
+
+	root, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	r := &repo{Name: "mirror", PackagePath: "packages", Upstream: url, Include: []string{"foo*"}, Exclude: []string{"*-debug"}}
+	if err := runSync(context.Background(), root, r); err != nil {
+		t.Fatalf("error running runSync: %v", err)
+	}
+	rs := indexed(t, r)
+	if len(rs) != 1 || rs[0].PackageSpec.Name != "foo" {
+		t.Fatalf("mirror has unexpected packages: %+v", rs)
+	}
+	if got := rs[0].Checksum; got != pkgs[0].Checksum {
+		t.Errorf("mirrored package has checksum %q, want %q", got, pkgs[0].Checksum)
+	}
+	files, err := filepath.Glob(filepath.Join(root, "packages", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("package directory contains %v, want only the mirrored package", files)
+	}
+}
+
+func TestFetchIndexTimeout(t *testing.T) {
+	defer func(d time.Duration) { *upstreamTimeout = d }(*upstreamTimeout)
+	*upstreamTimeout = 50 * time.Millisecond
+	unblock := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer srv.Close()
+	defer close(unblock)
+
+	if _, err := fetchIndex(context.Background(), srv.URL); err == nil {
+		t.Error("fetchIndex of an unresponsive upstream succeeded")
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/logger"
+)
+
+// retentionPolicy decides which packages are removed during sync runs.
+type retentionPolicy struct {
+	// keepVersions is the number of newest versions kept per package name
+	// and arch, 0 disables the limit.
+	keepVersions int
+	// keepNewerThan keeps all packages modified within this duration, 0
+	// disables the limit.
+	keepNewerThan time.Duration
+}
+
+func (p retentionPolicy) enabled() bool {
+	return p.keepVersions > 0 || p.keepNewerThan > 0
+}
+
+// expired returns the packages in rs that fall outside the policy. A package
+// is kept if either limit keeps it, the newest version of a package is always
+// kept.
+func (p retentionPolicy) expired(rs []goolib.RepoSpec, modTimes map[string]time.Time, now time.Time) []goolib.RepoSpec {
+	groups := make(map[string][]goolib.RepoSpec)
+	for _, r := range rs {
+		k := r.PackageSpec.Name + "." + r.PackageSpec.Arch
+		groups[k] = append(groups[k], r)
+	}
+
+	var out []goolib.RepoSpec
+	for _, g := range groups {
+		sort.Slice(g, func(i, j int) bool {
+			c, err := goolib.Compare(g[i].PackageSpec.Version, g[j].PackageSpec.Version)
+			if err != nil {
+				return g[i].PackageSpec.Version > g[j].PackageSpec.Version
+			}
+			return c == 1
+		})
+		for i, r := range g {
+			keep := i == 0
+			if p.keepVersions > 0 && i < p.keepVersions {
+				keep = true
+			}
+			if p.keepNewerThan > 0 && now.Sub(modTimes[r.Source]) < p.keepNewerThan {
+				keep = true
+			}
+			if !keep {
+				out = append(out, r)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Source < out[j].Source })
+	return out
+}
+
+// applyRetention removes or archives the packages in rs that are expired
+// under p and returns the remaining packages. In dry run
+// mode the expired packages are only reported.
+func applyRetention(ctx context.Context, client *storage.Client, rootLoc, packageLoc string, p retentionPolicy, rs []goolib.RepoSpec, modTimes map[string]time.Time) []goolib.RepoSpec {
+	exp := p.expired(rs, modTimes, time.Now())
+	if len(exp) == 0 {
+		return rs
+	}
+
+	action := "Deleting"
+	if *retentionArchive != "" {
+		action = "Archiving"
+	}
+	if *retentionDryRun {
+		logger.Infof("Retention dry run: %d of %d packages would be removed", len(exp), len(rs))
+		for _, r := range exp {
+			logger.Infof("Retention dry run: would remove %q", r.Source)
+		}
+		return rs
+	}
+
+	removed := make(map[string]bool)
+	for _, r := range exp {
+		logger.Infof("%s %q, outside of retention policy", action, r.Source)
+		if err := removePackage(ctx, client, rootLoc, packageLoc, r.Source); err != nil {
+			logger.Errorf("Error removing %q: %v", r.Source, err)
+			metrics.syncError()
+			continue
+		}
+		removed[r.Source] = true
+	}
+	var out []goolib.RepoSpec
+	for _, r := range rs {
+		if !removed[r.Source] {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// removePackage deletes, or moves to the archive location, a package and its
+// sidecar files.
+func removePackage(ctx context.Context, client *storage.Client, rootLoc, packageLoc, pkgPath string) error {
+	suffixes := []string{"", goolib.SpecSidecarSuffix, goolib.ManifestSuffix, goolib.SignatureSuffix, goolib.CosignBundleSuffix}
+	for _, s := range statuses {
+		suffixes = append(suffixes, "."+s)
+	}
+	for _, suffix := range suffixes {
+		p := packageFile(rootLoc, packageLoc, pkgPath, suffix)
+		var err error
+		if *retentionArchive != "" {
+			err = moveFile(ctx, client, p, *retentionArchive)
+		} else {
+			err = removeFile(ctx, client, p)
+		}
+		// Sidecar files are optional.
+		if err != nil && (suffix == "" || !os.IsNotExist(err)) {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeFile removes path, which may be a local path or a GCS URL. A missing
+// file is reported as os.ErrNotExist.
+func removeFile(ctx context.Context, client *storage.Client, p string) error {
+	if isGCSURL, bucket, object := goolib.SplitGCSUrl(p); isGCSURL {
+		err := client.Bucket(bucket).Object(object).Delete(ctx)
+		if err == storage.ErrObjectNotExist {
+			return os.ErrNotExist
+		}
+		return err
+	}
+	return oswrap.Remove(p)
+}
+
+// moveFile moves the file at p into the directory or GCS prefix dir.
+func moveFile(ctx context.Context, client *storage.Client, p, dir string) error {
+	srcGCS, srcBucket, srcObject := goolib.SplitGCSUrl(p)
+	dstGCS, dstBucket, dstPrefix := goolib.SplitGCSUrl(dir)
+	if srcGCS != dstGCS {
+		return fmt.Errorf("cannot archive %q to %q, both must be local paths or GCS URLs", p, dir)
+	}
+	if !srcGCS {
+		if err := oswrap.MkdirAll(dir, 0774); err != nil {
+			return err
+		}
+		return oswrap.Rename(p, filepath.Join(dir, filepath.Base(p)))
+	}
+
+	src := client.Bucket(srcBucket).Object(srcObject)
+	dst := client.Bucket(dstBucket).Object(path.Join(dstPrefix, path.Base(srcObject)))
+	if _, err := dst.CopierFrom(src).Run(ctx); err != nil {
+		if err == storage.ErrObjectNotExist {
+			return os.ErrNotExist
+		}
+		return err
+	}
+	return src.Delete(ctx)
+}
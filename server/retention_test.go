@@ -0,0 +1,60 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestRetentionExpired(t *testing.T) {
+	now := time.Now()
+	rs := []goolib.RepoSpec{
+		{Source: "foo.1", PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0"}},
+		{Source: "foo.2", PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "2.0.0"}},
+		{Source: "foo.10", PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "10.0.0"}},
+		{Source: "foo.x64", PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "x86_64", Version: "1.0.0"}},
+		{Source: "bar.1", PackageSpec: &goolib.PkgSpec{Name: "bar", Arch: "noarch", Version: "1.0.0"}},
+	}
+	modTimes := map[string]time.Time{
+		"foo.1":   now.Add(-time.Hour),
+		"foo.2":   now.Add(-48 * time.Hour),
+		"foo.10":  now.Add(-48 * time.Hour),
+		"foo.x64": now.Add(-48 * time.Hour),
+		"bar.1":   now.Add(-48 * time.Hour),
+	}
+
+	table := []struct {
+		name   string
+		policy retentionPolicy
+		want   []string
+	}{
+		{"keep one", retentionPolicy{keepVersions: 1}, []string{"foo.1", "foo.2"}},
+		{"keep two", retentionPolicy{keepVersions: 2}, []string{"foo.1"}},
+		{"newer than a day", retentionPolicy{keepNewerThan: 24 * time.Hour}, []string{"foo.2"}},
+		{"either policy", retentionPolicy{keepVersions: 1, keepNewerThan: 24 * time.Hour}, []string{"foo.2"}},
+	}
+	for _, tt := range table {
+		var got []string
+		for _, r := range tt.policy.expired(rs, modTimes, now) {
+			got = append(got, r.Source)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expired returned %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+)
+
+// packageFile returns the location of the package at pkgPath with suffix
+// appended, an empty suffix gives the location of the package itself.
+func packageFile(rootLoc, packageLoc, pkgPath, suffix string) string {
+	if isGCSURL, bucket, _ := goolib.SplitGCSUrl(rootLoc); isGCSURL {
+		return fmt.Sprintf("gs://%s/%s%s", bucket, pkgPath, suffix)
+	}
+	return filepath.Join(rootLoc, packageLoc, filepath.Base(pkgPath)) + suffix
+}
+
+// readFile reads path, which may be a local path or a GCS URL. A missing file
+// is reported as os.ErrNotExist.
+func readFile(ctx context.Context, client *storage.Client, path string) ([]byte, error) {
+	if isGCSURL, bucket, object := goolib.SplitGCSUrl(path); isGCSURL {
+		r, err := client.Bucket(bucket).Object(object).NewReader(ctx)
+		if err == storage.ErrObjectNotExist {
+			return nil, os.ErrNotExist
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	f, err := oswrap.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+type sidecar struct {
+	suffix string
+	data   []byte
+	src    *string
+}
+
+// writeSidecars writes the spec, manifest and, if a signing key is
+// configured, signature sidecar files next to the package described by rs
+// and records their sources in rs. Files whose content is unchanged are not
+// rewritten. The signature covers the package checksum.
+func writeSidecars(ctx context.Context, client *storage.Client, rootLoc, packageLoc string, rs *goolib.RepoSpec, manifest []goolib.ManifestEntry) error {
+	spec, err := goolib.MarshalPackageSpec(rs.PackageSpec)
+	if err != nil {
+		return err
+	}
+	man, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	files := []sidecar{
+		{goolib.SpecSidecarSuffix, spec, &rs.SpecSource},
+		{goolib.ManifestSuffix, man, &rs.ManifestSource},
+	}
+	if key != nil {
+		files = append(files, sidecar{goolib.SignatureSuffix, goolib.Sign(key, []byte(rs.Checksum)), &rs.SignatureSource})
+	}
+
+	for _, f := range files {
+		p := packageFile(rootLoc, packageLoc, rs.Source, f.suffix)
+		old, err := readFile(ctx, client, p)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err != nil || !bytes.Equal(old, f.data) {
+			if err := writeFile(ctx, p, f.data); err != nil {
+				return err
+			}
+		}
+		*f.src = rs.Source + f.suffix
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/googet/v2/googettest"
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestWriteSidecars(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	key = priv
+	defer func() { key = nil }()
+
+	root, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	pkg, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}, map[string][]byte{"bar.txt": []byte("bar")})
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	manifest, spec, err := goolib.ReadManifest(bytes.NewReader(pkg.Data))
+	if err != nil {
+		t.Fatalf("error running ReadManifest: %v", err)
+	}
+	pkgPath := filepath.Join(root, "packages", "foo.noarch.1.0.0@1.goo")
+	rs := goolib.RepoSpec{Source: pkgPath, Checksum: pkg.Checksum, PackageSpec: spec}
+	if err := writeSidecars(context.Background(), nil, root, "packages", &rs, manifest); err != nil {
+		t.Fatalf("error running writeSidecars: %v", err)
+	}
+
+	for _, src := range []string{rs.SpecSource, rs.ManifestSource, rs.SignatureSource} {
+		if _, err := os.Stat(src); err != nil {
+			t.Errorf("sidecar %q not written: %v", src, err)
+		}
+	}
+	b, err := ioutil.ReadFile(rs.SpecSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := goolib.UnmarshalPackageSpec(b); err != nil || got.Name != "foo" {
+		t.Errorf("spec sidecar contains %+v, %v", got, err)
+	}
+	sig, err := ioutil.ReadFile(rs.SignatureSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !goolib.VerifySignature(pub, []byte(pkg.Checksum), sig) {
+		t.Error("signature sidecar does not verify against the package checksum")
+	}
+}
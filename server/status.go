@@ -0,0 +1,133 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// A package is marked as yanked or deprecated by a sidecar file named after
+// the package with the status appended, e.g. foo.x86_64.1.0.0@1.goo.yanked.
+// The content of the file is the reason given to clients.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+)
+
+// statuses are the package statuses in order of precedence.
+var statuses = []string{goolib.StatusYanked, goolib.StatusDeprecated}
+
+// statusFile returns the package a status sidecar file belongs to and the
+// status it sets, ok is false if name is not a status file.
+func statusFile(name string) (pkgPath, status string, ok bool) {
+	for _, s := range statuses {
+		if p := strings.TrimSuffix(name, "."+s); p != name && strings.HasSuffix(p, ".goo") {
+			return p, s, true
+		}
+	}
+	return "", "", false
+}
+
+// addStatus records status for pkgPath in m, keeping the status with the
+// highest precedence if a package has several.
+func addStatus(m map[string]string, pkgPath, status string) {
+	if m[pkgPath] != goolib.StatusYanked {
+		m[pkgPath] = status
+	}
+}
+
+// readStatus sets the status of rs and reads the reason from the sidecar file.
+func readStatus(ctx context.Context, client *storage.Client, rootLoc, packageLoc, pkgPath, status string, rs *goolib.RepoSpec) error {
+	reason, err := readFile(ctx, client, packageFile(rootLoc, packageLoc, pkgPath, "."+status))
+	if err != nil {
+		return err
+	}
+	rs.Status = status
+	rs.StatusReason = strings.TrimSpace(string(reason))
+	return nil
+}
+
+// setStatus writes the status sidecar file of the package named pkg and
+// removes any other, an empty status clears the status of the package.
+func setStatus(ctx context.Context, rootLoc, packageLoc, pkg, status, reason string) error {
+	pkgPath := pkg
+	var client *storage.Client
+	if isGCSURL, bucket, folder := goolib.SplitGCSUrl(rootLoc); isGCSURL {
+		pkgPath = path.Join(folder, packageLoc, pkg)
+		var err error
+		client, err = storage.NewClient(ctx)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		if _, err := client.Bucket(bucket).Object(pkgPath).Attrs(ctx); err != nil {
+			return err
+		}
+	} else if _, err := oswrap.Stat(packageFile(rootLoc, packageLoc, pkgPath, "")); err != nil {
+		return err
+	}
+
+	for _, s := range statuses {
+		p := packageFile(rootLoc, packageLoc, pkgPath, "."+s)
+		if s == status {
+			if err := writeFile(ctx, p, []byte(reason)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := removeFile(ctx, client, p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// serveStatus sets the status of a package on POST requests, the package
+// file name, the status and the reason are given as form values. The sync run
+// it triggers publishes the new status in the index.
+func (rep *repo) serveStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	pkg := r.FormValue("package")
+	if !strings.HasSuffix(pkg, ".goo") || path.Base(pkg) != pkg || strings.ContainsRune(pkg, '\\') {
+		http.Error(w, "package must be the file name of a package", http.StatusBadRequest)
+		return
+	}
+	status := r.FormValue("status")
+	if status != "" && !goolib.ContainsString(status, statuses) {
+		http.Error(w, fmt.Sprintf("status must be empty or one of %s", strings.Join(statuses, ", ")), http.StatusBadRequest)
+		return
+	}
+	if err := setStatus(r.Context(), *root, rep.PackagePath, pkg, status, r.FormValue("reason")); err != nil {
+		if os.IsNotExist(err) || err == storage.ErrObjectNotExist {
+			http.Error(w, "package not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	select {
+	case syncTrigger <- struct{}{}:
+	default:
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
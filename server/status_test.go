@@ -0,0 +1,107 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/googet/v2/googettest"
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestServeStatus(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	if err := os.Mkdir(filepath.Join(tempDir, "packages"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := googettest.GenGoo(&goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}, nil)
+	if err != nil {
+		t.Fatalf("error running GenGoo: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "packages", pkg.Name()), pkg.Data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(r string) { *root = r }(*root)
+	*root = tempDir
+
+	r := &repo{Name: "repo", PackagePath: "packages"}
+	post := func(form url.Values) int {
+		req := httptest.NewRequest(http.MethodPost, "/repo/status", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		r.serveStatus(w, req)
+		select {
+		case <-syncTrigger:
+		default:
+		}
+		return w.Code
+	}
+	sync := func() goolib.RepoSpec {
+		t.Helper()
+		if err := runSync(context.Background(), tempDir, r); err != nil {
+			t.Fatalf("error running runSync: %v", err)
+		}
+		rs := indexed(t, r)
+		if len(rs) != 1 {
+			t.Fatalf("repo has %d packages, want 1", len(rs))
+		}
+		return rs[0]
+	}
+
+	for _, tt := range []struct {
+		form url.Values
+		want int
+	}{
+		{url.Values{"package": {"../" + pkg.Name()}, "status": {"yanked"}}, http.StatusBadRequest},
+		{url.Values{"package": {pkg.Name()}, "status": {"gone"}}, http.StatusBadRequest},
+		{url.Values{"package": {"bar.noarch.1.0.0@1.goo"}, "status": {"yanked"}}, http.StatusNotFound},
+	} {
+		if got := post(tt.form); got != tt.want {
+			t.Errorf("POST %v returned %d, want %d", tt.form, got, tt.want)
+		}
+	}
+
+	if got := post(url.Values{"package": {pkg.Name()}, "status": {"yanked"}, "reason": {"broken"}}); got != http.StatusAccepted {
+		t.Fatalf("POST returned %d, want %d", got, http.StatusAccepted)
+	}
+	if rs := sync(); rs.Status != goolib.StatusYanked || rs.StatusReason != "broken" {
+		t.Errorf("package has status %q, reason %q, want %q, %q", rs.Status, rs.StatusReason, goolib.StatusYanked, "broken")
+	}
+
+	if got := post(url.Values{"package": {pkg.Name()}, "status": {"deprecated"}}); got != http.StatusAccepted {
+		t.Fatalf("POST returned %d, want %d", got, http.StatusAccepted)
+	}
+	if rs := sync(); rs.Status != goolib.StatusDeprecated || rs.StatusReason != "" {
+		t.Errorf("package has status %q, reason %q, want %q with no reason", rs.Status, rs.StatusReason, goolib.StatusDeprecated)
+	}
+
+	if got := post(url.Values{"package": {pkg.Name()}}); got != http.StatusAccepted {
+		t.Fatalf("POST returned %d, want %d", got, http.StatusAccepted)
+	}
+	if rs := sync(); rs.Status != "" {
+		t.Errorf("package has status %q after clearing it", rs.Status)
+	}
+}
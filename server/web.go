@@ -0,0 +1,91 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"sort"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+var browseTemplate = template.Must(template.New("browse").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} - GooGet repository</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border-bottom: 1px solid #ddd; padding: 0.3em 0.8em; text-align: left; vertical-align: top; }
+.yanked { color: #999; text-decoration: line-through; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>{{len .Packages}} packages, <a href="index">index</a></p>
+<table>
+<tr><th>Name</th><th>Version</th><th>Arch</th><th>Description</th><th>Release notes</th></tr>
+{{range .Packages}}<tr{{if eq .Status "yanked"}} class="yanked"{{end}}>
+<td>{{.PackageSpec.Name}}</td>
+<td><a href="/{{.Source}}">{{.PackageSpec.Version}}</a>{{if .Status}} ({{.Status}}{{if .StatusReason}}: {{.StatusReason}}{{end}}){{end}}</td>
+<td>{{.PackageSpec.Arch}}</td>
+<td>{{.PackageSpec.Description}}</td>
+<td>{{if .PackageSpec.ReleaseNotes}}<details><summary>{{index .PackageSpec.ReleaseNotes 0}}</summary>{{range .PackageSpec.ReleaseNotes}}{{.}}<br>{{end}}</details>{{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// renderBrowse renders the HTML listing of the packages of a repo, sorted by
+// name and arch with the newest version first.
+func renderBrowse(name string, rs []goolib.RepoSpec) ([]byte, error) {
+	pkgs := append([]goolib.RepoSpec(nil), rs...)
+	sort.Slice(pkgs, func(i, j int) bool {
+		a, b := pkgs[i].PackageSpec, pkgs[j].PackageSpec
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Arch != b.Arch {
+			return a.Arch < b.Arch
+		}
+		c, err := goolib.Compare(a.Version, b.Version)
+		if err != nil {
+			return a.Version > b.Version
+		}
+		return c == 1
+	})
+	var buf bytes.Buffer
+	if err := browseTemplate.Execute(&buf, struct {
+		Name     string
+		Packages []goolib.RepoSpec
+	}{name, pkgs}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// serveBrowse writes the HTML listing of the repo.
+func (rep *repo) serveBrowse(w http.ResponseWriter, r *http.Request) {
+	d := rep.index.get()
+	if d == nil {
+		unavailable(w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(d.html)
+}
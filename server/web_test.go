@@ -0,0 +1,43 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestRenderBrowse(t *testing.T) {
+	rs := []goolib.RepoSpec{
+		{Source: "packages/foo.noarch.1.0.0@1.goo", PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "1.0.0@1"}},
+		{Source: "packages/foo.noarch.10.0.0@1.goo", PackageSpec: &goolib.PkgSpec{Name: "foo", Arch: "noarch", Version: "10.0.0@1", Description: "<b>new</b>", ReleaseNotes: []string{"10.0.0 - rewrite"}}, Status: goolib.StatusYanked},
+		{Source: "packages/bar.noarch.2.0.0@1.goo", PackageSpec: &goolib.PkgSpec{Name: "bar", Arch: "noarch", Version: "2.0.0@1"}},
+	}
+	b, err := renderBrowse("repo", rs)
+	if err != nil {
+		t.Fatalf("error running renderBrowse: %v", err)
+	}
+	out := string(b)
+	for _, want := range []string{`<a href="/packages/foo.noarch.10.0.0@1.goo">10.0.0@1</a>`, "&lt;b&gt;new&lt;/b&gt;", "10.0.0 - rewrite", `class="yanked"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered page does not contain %q:\n%s", want, out)
+		}
+	}
+	bar, foo10, foo1 := strings.Index(out, ">2.0.0@1<"), strings.Index(out, ">10.0.0@1<"), strings.Index(out, ">1.0.0@1<")
+	if !(bar < foo10 && foo10 < foo1) {
+		t.Errorf("packages not sorted by name and newest version first:\n%s", out)
+	}
+}
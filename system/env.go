@@ -0,0 +1,215 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+// environment is the machine environment changed by the Env of packages.
+type environment interface {
+	// Get returns the value of a variable and whether it is set.
+	Get(name string) (string, bool, error)
+	// Set sets a variable, or unsets it if value is nil.
+	Set(name string, value *string) error
+	// Commit makes the changes visible to new processes.
+	Commit() error
+	Close() error
+}
+
+// SetEnv applies env to the machine environment and returns the changes
+// made. prev are the changes made by the installed version of the package,
+// which are taken over or undone if env no longer declares them.
+func SetEnv(env *goolib.EnvSpec, prev *goolib.EnvChanges) (*goolib.EnvChanges, error) {
+	if env == nil && prev == nil {
+		return nil, nil
+	}
+	e, err := openEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	defer e.Close()
+	return setEnv(e, env, prev)
+}
+
+// UndoEnv undoes the changes c made by env, leaving variables changed since.
+func UndoEnv(env *goolib.EnvSpec, c *goolib.EnvChanges) error {
+	if c == nil {
+		return nil
+	}
+	e, err := openEnvironment()
+	if err != nil {
+		return err
+	}
+	defer e.Close()
+	return undoEnv(e, env, c)
+}
+
+func setEnv(e environment, env *goolib.EnvSpec, prev *goolib.EnvChanges) (*goolib.EnvChanges, error) {
+	if env == nil {
+		env = &goolib.EnvSpec{}
+	}
+	if prev == nil {
+		prev = &goolib.EnvChanges{}
+	}
+	c := &goolib.EnvChanges{}
+	want := make(map[string]*string)
+
+	path, _, err := e.Get(pathVar)
+	if err != nil {
+		return nil, err
+	}
+	entries := splitPath(path)
+	for _, p := range prev.Path {
+		if !containsPath(env.Path, p) {
+			entries = removePath(entries, p)
+		}
+	}
+	for _, p := range env.Path {
+		if !containsPath(entries, p) {
+			entries = append(entries, p)
+		} else if !containsPath(prev.Path, p) {
+			// The entry was there before the package.
+			continue
+		}
+		c.Path = append(c.Path, p)
+	}
+	if np := strings.Join(entries, pathSeparator); np != path {
+		want[pathVar] = &np
+	}
+
+	for k, old := range prev.Vars {
+		if _, ok := env.Vars[k]; !ok {
+			want[k] = old
+		}
+	}
+	for k, v := range env.Vars {
+		if c.Vars == nil {
+			c.Vars = make(map[string]*string)
+		}
+		if old, ok := prev.Vars[k]; ok {
+			c.Vars[k] = old
+		} else {
+			cur, set, err := e.Get(k)
+			if err != nil {
+				return nil, err
+			}
+			if set {
+				c.Vars[k] = &cur
+			} else {
+				c.Vars[k] = nil
+			}
+		}
+		v := v
+		want[k] = &v
+	}
+
+	if err := apply(e, want); err != nil {
+		return nil, err
+	}
+	if len(c.Path) == 0 && len(c.Vars) == 0 {
+		return nil, nil
+	}
+	return c, nil
+}
+
+func undoEnv(e environment, env *goolib.EnvSpec, c *goolib.EnvChanges) error {
+	want := make(map[string]*string)
+	path, _, err := e.Get(pathVar)
+	if err != nil {
+		return err
+	}
+	entries := splitPath(path)
+	for _, p := range c.Path {
+		entries = removePath(entries, p)
+	}
+	if np := strings.Join(entries, pathSeparator); np != path {
+		want[pathVar] = &np
+	}
+	for k, old := range c.Vars {
+		cur, set, err := e.Get(k)
+		if err != nil {
+			return err
+		}
+		// Leave variables that were changed since the install.
+		if env != nil && set && cur == env.Vars[k] {
+			want[k] = old
+		}
+	}
+	return apply(e, want)
+}
+
+// apply sets the variables of want, nil values unsetting them, restoring
+// those already set if one fails, and commits them.
+func apply(e environment, want map[string]*string) error {
+	if len(want) == 0 {
+		return nil
+	}
+	var names []string
+	for k := range want {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	olds := make(map[string]*string)
+	for _, k := range names {
+		old, set, err := e.Get(k)
+		if err == nil {
+			if set {
+				olds[k] = &old
+			} else {
+				olds[k] = nil
+			}
+			err = e.Set(k, want[k])
+		}
+		if err != nil {
+			for r, old := range olds {
+				e.Set(r, old)
+			}
+			return err
+		}
+	}
+	return e.Commit()
+}
+
+func splitPath(path string) []string {
+	var entries []string
+	for _, p := range strings.Split(path, pathSeparator) {
+		if p != "" {
+			entries = append(entries, p)
+		}
+	}
+	return entries
+}
+
+func containsPath(entries []string, p string) bool {
+	for _, e := range entries {
+		if samePath(e, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func removePath(entries []string, p string) []string {
+	var res []string
+	for _, e := range entries {
+		if !samePath(e, p) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
@@ -0,0 +1,130 @@
+//go:build linux || darwin
+// +build linux darwin
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	pathVar       = "PATH"
+	pathSeparator = ":"
+	envHeader     = "# Environment of the packages installed by GooGet, do not edit."
+	pathPrefix    = `"${PATH:+$PATH:}"`
+)
+
+// EnvFile is the shell profile script setting the environment of packages,
+// PATH holding only the entries added by them.
+var EnvFile = "/etc/profile.d/googet.sh"
+
+func samePath(a, b string) bool {
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// profileEnv is the environment of EnvFile.
+type profileEnv struct {
+	vars map[string]string
+}
+
+func openEnvironment() (environment, error) {
+	e := &profileEnv{vars: make(map[string]string)}
+	b, err := ioutil.ReadFile(EnvFile)
+	if os.IsNotExist(err) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(strings.TrimPrefix(l, "export "), "=")
+		if k == pathVar {
+			v = strings.TrimPrefix(v, pathPrefix)
+		}
+		if !ok || len(v) < 2 || v[0] != '\'' || v[len(v)-1] != '\'' {
+			return nil, fmt.Errorf("%s: can't parse %q", EnvFile, l)
+		}
+		e.vars[k] = strings.ReplaceAll(v[1:len(v)-1], `'\''`, `'`)
+	}
+	return e, s.Err()
+}
+
+func (e *profileEnv) Get(name string) (string, bool, error) {
+	v, ok := e.vars[name]
+	return v, ok, nil
+}
+
+func (e *profileEnv) Set(name string, value *string) error {
+	if value == nil || (name == pathVar && *value == "") {
+		delete(e.vars, name)
+		return nil
+	}
+	e.vars[name] = *value
+	return nil
+}
+
+// Commit replaces EnvFile, removing it if no variables are set.
+func (e *profileEnv) Commit() error {
+	if len(e.vars) == 0 {
+		if err := os.Remove(EnvFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	var names []string
+	for k := range e.vars {
+		if k != pathVar {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	var b bytes.Buffer
+	fmt.Fprintln(&b, envHeader)
+	for _, k := range names {
+		fmt.Fprintf(&b, "export %s=%s\n", k, shellQuote(e.vars[k]))
+	}
+	if p, ok := e.vars[pathVar]; ok {
+		fmt.Fprintf(&b, "export %s=%s%s\n", pathVar, pathPrefix, shellQuote(p))
+	}
+	if err := os.MkdirAll(filepath.Dir(EnvFile), 0755); err != nil {
+		return err
+	}
+	tmp := EnvFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, b.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, EnvFile)
+}
+
+func (e *profileEnv) Close() error {
+	return nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,55 @@
+//go:build linux || darwin
+// +build linux darwin
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestProfileEnv(t *testing.T) {
+	EnvFile = filepath.Join(t.TempDir(), "profile.d", "googet.sh")
+	env := &goolib.EnvSpec{Path: []string{"/opt/foo bar/bin"}, Vars: map[string]string{"FOO": "it's $HOME"}}
+	c, err := SetEnv(env, nil)
+	if err != nil {
+		t.Fatalf("SetEnv: %v", err)
+	}
+
+	out, err := exec.Command("sh", "-c", `PATH=/bin:/usr/bin; . "$0"; echo "$PATH"; echo "$FOO"`, EnvFile).Output()
+	if err != nil {
+		t.Fatalf("sourcing %s: %v", EnvFile, err)
+	}
+	if got, want := strings.TrimSpace(string(out)), "/bin:/usr/bin:/opt/foo bar/bin\nit's $HOME"; got != want {
+		t.Errorf("sourced environment = %q, want %q", got, want)
+	}
+
+	// Reading the file back must give the same environment.
+	if _, err := SetEnv(env, c); err != nil {
+		t.Fatalf("SetEnv: %v", err)
+	}
+	if err := UndoEnv(env, c); err != nil {
+		t.Fatalf("UndoEnv: %v", err)
+	}
+	if _, err := os.Stat(EnvFile); !os.IsNotExist(err) {
+		t.Errorf("%s not removed, Stat: %v", EnvFile, err)
+	}
+}
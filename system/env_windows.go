@@ -0,0 +1,98 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"strings"
+	"unsafe"
+
+	"github.com/google/logger"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	pathVar       = "Path"
+	pathSeparator = ";"
+	envKey        = `SYSTEM\CurrentControlSet\Control\Session Manager\Environment`
+)
+
+var procSendMessageTimeoutW = windows.NewLazySystemDLL("user32.dll").NewProc("SendMessageTimeoutW")
+
+// Arguments of SendMessageTimeoutW broadcasting a change of the environment.
+const (
+	hwndBroadcast   = 0xffff
+	wmSettingChange = 0x001a
+	smtoAbortIfHung = 0x0002
+)
+
+func samePath(a, b string) bool {
+	return strings.EqualFold(strings.TrimRight(a, `\/`), strings.TrimRight(b, `\/`))
+}
+
+// registryEnv is the machine environment in the registry.
+type registryEnv struct {
+	k registry.Key
+}
+
+func openEnvironment() (environment, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, envKey, registry.QUERY_VALUE|registry.SET_VALUE)
+	if err != nil {
+		return nil, err
+	}
+	return &registryEnv{k: k}, nil
+}
+
+func (e *registryEnv) Get(name string) (string, bool, error) {
+	v, _, err := e.k.GetStringValue(name)
+	if err == registry.ErrNotExist {
+		return "", false, nil
+	}
+	return v, err == nil, err
+}
+
+func (e *registryEnv) Set(name string, value *string) error {
+	if value == nil {
+		if err := e.k.DeleteValue(name); err != nil && err != registry.ErrNotExist {
+			return err
+		}
+		return nil
+	}
+	// Path and values referring to other variables have to be expanded.
+	if name == pathVar || strings.Contains(*value, "%") {
+		return e.k.SetExpandStringValue(name, *value)
+	}
+	return e.k.SetStringValue(name, *value)
+}
+
+// Commit broadcasts WM_SETTINGCHANGE so running programs such as Explorer
+// pick up the new environment.
+func (e *registryEnv) Commit() error {
+	env, err := windows.UTF16PtrFromString("Environment")
+	if err != nil {
+		return err
+	}
+	var res uintptr
+	if r, _, err := procSendMessageTimeoutW.Call(hwndBroadcast, wmSettingChange, 0, uintptr(unsafe.Pointer(env)), smtoAbortIfHung, 5000, uintptr(unsafe.Pointer(&res))); r == 0 {
+		logger.Errorf("Error broadcasting the environment change: %v", err)
+	}
+	return nil
+}
+
+func (e *registryEnv) Close() error {
+	return e.k.Close()
+}
@@ -0,0 +1,233 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/logger"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	msiDLL                   = windows.NewLazySystemDLL("msi.dll")
+	procMsiOpenDatabase      = msiDLL.NewProc("MsiOpenDatabaseW")
+	procMsiDatabaseOpenView  = msiDLL.NewProc("MsiDatabaseOpenViewW")
+	procMsiViewExecute       = msiDLL.NewProc("MsiViewExecute")
+	procMsiViewFetch         = msiDLL.NewProc("MsiViewFetch")
+	procMsiRecordGetString   = msiDLL.NewProc("MsiRecordGetStringW")
+	procMsiCloseHandle       = msiDLL.NewProc("MsiCloseHandle")
+	procMsiQueryProductState = msiDLL.NewProc("MsiQueryProductStateW")
+	procMsiGetProductInfo    = msiDLL.NewProc("MsiGetProductInfoW")
+)
+
+// installStateDefault is the INSTALLSTATE of an installed product.
+const installStateDefault = 5
+
+func msiError(fn string, r uintptr) error {
+	return fmt.Errorf("%s: %v", fn, syscall.Errno(r))
+}
+
+func msiClose(h uintptr) {
+	procMsiCloseHandle.Call(h)
+}
+
+// msiProperty returns the value of the property name of the MSI at path.
+func msiProperty(path, name string) (string, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return "", err
+	}
+	var db uintptr
+	// A nil persist mode opens the database read only.
+	if r, _, _ := procMsiOpenDatabase.Call(uintptr(unsafe.Pointer(p)), 0, uintptr(unsafe.Pointer(&db))); r != 0 {
+		return "", msiError("MsiOpenDatabase", r)
+	}
+	defer msiClose(db)
+	q, err := syscall.UTF16PtrFromString("SELECT `Value` FROM `Property` WHERE `Property`='" + name + "'")
+	if err != nil {
+		return "", err
+	}
+	var view uintptr
+	if r, _, _ := procMsiDatabaseOpenView.Call(db, uintptr(unsafe.Pointer(q)), uintptr(unsafe.Pointer(&view))); r != 0 {
+		return "", msiError("MsiDatabaseOpenView", r)
+	}
+	defer msiClose(view)
+	if r, _, _ := procMsiViewExecute.Call(view, 0); r != 0 {
+		return "", msiError("MsiViewExecute", r)
+	}
+	var rec uintptr
+	if r, _, _ := procMsiViewFetch.Call(view, uintptr(unsafe.Pointer(&rec))); r == uintptr(windows.ERROR_NO_MORE_ITEMS) {
+		return "", fmt.Errorf("%s has no property %s", path, name)
+	} else if r != 0 {
+		return "", msiError("MsiViewFetch", r)
+	}
+	defer msiClose(rec)
+	return msiString(func(buf *uint16, n *uint32) uintptr {
+		r, _, _ := procMsiRecordGetString.Call(rec, 1, uintptr(unsafe.Pointer(buf)), uintptr(unsafe.Pointer(n)))
+		return r
+	}, "MsiRecordGetString")
+}
+
+// msiString returns the string get writes to a buffer, growing the buffer
+// while get returns ERROR_MORE_DATA.
+func msiString(get func(*uint16, *uint32) uintptr, fn string) (string, error) {
+	buf := make([]uint16, 64)
+	for {
+		n := uint32(len(buf))
+		r := get(&buf[0], &n)
+		switch r {
+		case 0:
+			return windows.UTF16ToString(buf[:n]), nil
+		case uintptr(windows.ERROR_MORE_DATA):
+			buf = make([]uint16, n+1)
+		default:
+			return "", msiError(fn, r)
+		}
+	}
+}
+
+// MSIProduct reports whether the product with productCode is installed and
+// at which version.
+func MSIProduct(productCode string) (bool, string, error) {
+	p, err := syscall.UTF16PtrFromString(productCode)
+	if err != nil {
+		return false, "", err
+	}
+	if s, _, _ := procMsiQueryProductState.Call(uintptr(unsafe.Pointer(p))); int32(s) != installStateDefault {
+		return false, "", nil
+	}
+	attr, err := syscall.UTF16PtrFromString("VersionString")
+	if err != nil {
+		return false, "", err
+	}
+	v, err := msiString(func(buf *uint16, n *uint32) uintptr {
+		r, _, _ := procMsiGetProductInfo.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(attr)), uintptr(unsafe.Pointer(buf)), uintptr(unsafe.Pointer(n)))
+		return r
+	}, "MsiGetProductInfo")
+	if err != nil {
+		return true, "", err
+	}
+	return true, v, nil
+}
+
+// runMsiexec runs msiexec with args, already quoted, as the command e and
+// writes its output to the file log. It reports whether a reboot is required.
+func runMsiexec(ctx context.Context, e goolib.ExecFile, args []string, log string) (bool, error) {
+	out, err := oswrap.Create(log)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := out.Close(); err != nil {
+			logger.Error(err)
+		}
+	}()
+	return runScript(ctx, e, msiRebootCodes, func(ctx context.Context) error {
+		c := exec.Command("msiexec")
+		// msiexec parses its command line itself, properties can't be
+		// quoted as a whole as exec.Command does.
+		c.SysProcAttr = &syscall.SysProcAttr{CmdLine: "msiexec " + strings.Join(args, " ")}
+		return goolib.RunContext(ctx, c, nil, out)
+	})
+}
+
+// InstallMSI installs the MSI m of a package extracted to dir and returns
+// its product code. A product installed at the version of the MSI is taken
+// over, unless repair is set, one installed at another version with the same
+// product code is reinstalled. It reports whether a reboot is required.
+func InstallMSI(ctx context.Context, dir string, m *goolib.MSISpec, repair bool) (string, bool, error) {
+	path := filepath.Join(dir, m.Path)
+	code := m.ProductCode
+	if code == "" {
+		var err error
+		if code, err = msiProperty(path, "ProductCode"); err != nil {
+			return "", false, fmt.Errorf("error reading the product code of %s: %v", m.Path, err)
+		}
+	}
+	installed, ver, err := MSIProduct(code)
+	if err != nil {
+		return "", false, err
+	}
+	reinstall := false
+	if installed {
+		want, err := msiProperty(path, "ProductVersion")
+		if err != nil {
+			return "", false, fmt.Errorf("error reading the product version of %s: %v", m.Path, err)
+		}
+		switch {
+		case ver == want && repair:
+			logger.Infof("MSI product %s %s is already installed, repairing it", code, ver)
+		case ver == want:
+			logger.Infof("MSI product %s %s is already installed, taking it over", code, ver)
+			return code, false, nil
+		default:
+			logger.Infof("MSI product %s is installed at version %s, reinstalling it at %s", code, ver, want)
+		}
+		reinstall = true
+	}
+
+	logger.Infof("Running msiexec to install %q", m.Path)
+	args := []string{"/i", syscall.EscapeArg(path), "/qn", "/norestart", "/log", syscall.EscapeArg(filepath.Join(dir, "msi_install.log"))}
+	if reinstall {
+		args = append(args, "REINSTALL=ALL", "REINSTALLMODE=vomus")
+	}
+	args = append(args, msiProperties(m.Properties)...)
+	reboot, err := runMsiexec(ctx, goolib.ExecFile{Path: m.Path, Timeout: m.Timeout}, args, filepath.Join(dir, m.Path+".log"))
+	if err != nil {
+		return "", false, err
+	}
+	if installed, _, err := MSIProduct(code); err != nil || !installed {
+		logger.Errorf("msiexec installed %s but product %s is not installed, check the ProductCode of the package", m.Path, code)
+	}
+	return code, reboot, nil
+}
+
+// UninstallMSI uninstalls the MSI product with productCode of a package
+// extracted to dir, unless it was already uninstalled.
+func UninstallMSI(ctx context.Context, dir, productCode string, m *goolib.MSISpec) error {
+	installed, _, err := MSIProduct(productCode)
+	if err != nil {
+		return err
+	}
+	if !installed {
+		logger.Infof("MSI product %s is not installed, nothing to uninstall", productCode)
+		return nil
+	}
+	logger.Infof("Running msiexec to uninstall %s", productCode)
+	e := goolib.ExecFile{Path: productCode}
+	if m != nil {
+		e.Timeout = m.Timeout
+	}
+	args := []string{"/x", productCode, "/qn", "/norestart", "/log", syscall.EscapeArg(filepath.Join(dir, "msi_uninstall.log"))}
+	reboot, err := runMsiexec(ctx, e, args, filepath.Join(dir, "msi_uninstall.out.log"))
+	if err != nil {
+		return err
+	}
+	if reboot {
+		fmt.Printf("A reboot is required to complete the uninstall of %s\n", productCode)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+	"unsafe"
+
+	"github.com/google/logger"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+var (
+	rstrtmgr                = windows.NewLazySystemDLL("rstrtmgr.dll")
+	procRmStartSession      = rstrtmgr.NewProc("RmStartSession")
+	procRmEndSession        = rstrtmgr.NewProc("RmEndSession")
+	procRmRegisterResources = rstrtmgr.NewProc("RmRegisterResources")
+	procRmGetList           = rstrtmgr.NewProc("RmGetList")
+)
+
+const (
+	cchRmSessionKey = 32
+	rmService       = 3
+)
+
+// serviceStopTimeout is how long a service is waited for to stop.
+var serviceStopTimeout = 30 * time.Second
+
+// rmProcessInfo is the RM_PROCESS_INFO structure.
+type rmProcessInfo struct {
+	ProcessID        uint32
+	StartTime        windows.Filetime
+	AppName          [256]uint16
+	ServiceShortName [64]uint16
+	ApplicationType  uint32
+	AppStatus        uint32
+	TSSessionID      uint32
+	Restartable      int32
+}
+
+// fileUsers returns the processes holding any of paths open, as reported by
+// the Restart Manager.
+func fileUsers(paths []string) ([]rmProcessInfo, error) {
+	var session uint32
+	key := make([]uint16, cchRmSessionKey+1)
+	if r, _, _ := procRmStartSession.Call(uintptr(unsafe.Pointer(&session)), 0, uintptr(unsafe.Pointer(&key[0]))); r != 0 {
+		return nil, fmt.Errorf("RmStartSession: %v", windows.Errno(r))
+	}
+	defer procRmEndSession.Call(uintptr(session))
+
+	files := make([]*uint16, len(paths))
+	for i, p := range paths {
+		f, err := windows.UTF16PtrFromString(p)
+		if err != nil {
+			return nil, err
+		}
+		files[i] = f
+	}
+	if r, _, _ := procRmRegisterResources.Call(uintptr(session), uintptr(len(files)), uintptr(unsafe.Pointer(&files[0])), 0, 0, 0, 0); r != 0 {
+		return nil, fmt.Errorf("RmRegisterResources: %v", windows.Errno(r))
+	}
+
+	var procs []rmProcessInfo
+	for {
+		var needed, reasons uint32
+		n := uint32(len(procs))
+		var p uintptr
+		if n > 0 {
+			p = uintptr(unsafe.Pointer(&procs[0]))
+		}
+		r, _, _ := procRmGetList.Call(uintptr(session), uintptr(unsafe.Pointer(&needed)), uintptr(unsafe.Pointer(&n)), p, uintptr(unsafe.Pointer(&reasons)))
+		if r == 0 {
+			return procs[:n], nil
+		}
+		if windows.Errno(r) != windows.ERROR_MORE_DATA {
+			return nil, fmt.Errorf("RmGetList: %v", windows.Errno(r))
+		}
+		procs = make([]rmProcessInfo, needed)
+	}
+}
+
+// ReleaseFiles asks the Restart Manager which processes hold any of paths,
+// the files about to be replaced. With stopServices the services among them
+// are stopped, the returned function starting them again, otherwise in-use
+// files are renamed and removed on reboot.
+func ReleaseFiles(paths []string, stopServices bool) (func(), error) {
+	if len(paths) == 0 {
+		return func() {}, nil
+	}
+	procs, err := fileUsers(paths)
+	if err != nil {
+		return func() {}, err
+	}
+
+	var stopped []string
+	restart := func() {
+		for _, name := range stopped {
+			logger.Infof("Starting service %q.", name)
+			if err := startService(name); err != nil {
+				logger.Errorf("Error starting service %q: %v", name, err)
+			}
+		}
+	}
+	for _, p := range procs {
+		if p.ProcessID == uint32(os.Getpid()) {
+			continue
+		}
+		name := windows.UTF16ToString(p.AppName[:])
+		if svcName := windows.UTF16ToString(p.ServiceShortName[:]); p.ApplicationType == rmService && svcName != "" {
+			if !stopServices {
+				logger.Warningf("Files to replace are in use by service %q, use -stop_services to stop it during the install.", svcName)
+				continue
+			}
+			logger.Infof("Stopping service %q, which uses files to replace.", svcName)
+			if err := stopService(svcName); err != nil {
+				logger.Errorf("Error stopping service %q: %v", svcName, err)
+				continue
+			}
+			stopped = append(stopped, svcName)
+			continue
+		}
+		logger.Warningf("Files to replace are in use by %s (PID %d), they are replaced on reboot.", name, p.ProcessID)
+	}
+	return restart, nil
+}
+
+func stopService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	st, err := s.Control(svc.Stop)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(serviceStopTimeout)
+	for st.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for the service to stop")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if st, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	// The install command may already have started it.
+	if err := s.Start(); err != nil && !errors.Is(err, windows.ERROR_SERVICE_ALREADY_RUNNING) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/logger"
+	"golang.org/x/sys/windows"
+)
+
+// sFalse is returned by CoInitializeEx if COM is already initialized on the
+// thread.
+const sFalse = 1
+
+// startMenu returns the Start Menu programs folder of all users.
+func startMenu() (string, error) {
+	return windows.KnownFolderPath(windows.FOLDERID_CommonPrograms, 0)
+}
+
+// AddShortcuts creates the Start Menu shortcuts s for all users, replacing
+// existing ones, and returns their paths. Targets must already be resolved.
+func AddShortcuts(s []goolib.Shortcut) ([]string, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	dir, err := startMenu()
+	if err != nil {
+		return nil, err
+	}
+
+	// COM objects can only be used from the thread that created them.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := ole.CoInitializeEx(0, ole.COINIT_APARTMENTTHREADED); err != nil {
+		if oe, ok := err.(*ole.OleError); !ok || oe.Code() != sFalse {
+			return nil, err
+		}
+	}
+	defer ole.CoUninitialize()
+	unk, err := oleutil.CreateObject("WScript.Shell")
+	if err != nil {
+		return nil, err
+	}
+	defer unk.Release()
+	shell, err := unk.QueryInterface(ole.IID_IDispatch)
+	if err != nil {
+		return nil, err
+	}
+	defer shell.Release()
+
+	var paths []string
+	for _, sc := range s {
+		p := filepath.Join(dir, filepath.FromSlash(strings.ReplaceAll(sc.Name, `\`, "/"))+".lnk")
+		logger.Infof("Creating shortcut %q.", p)
+		if err := oswrap.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			RemoveShortcuts(paths)
+			return nil, err
+		}
+		if err := saveShortcut(shell, p, sc); err != nil {
+			RemoveShortcuts(paths)
+			return nil, fmt.Errorf("error creating shortcut %q: %v", p, err)
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+func saveShortcut(shell *ole.IDispatch, p string, sc goolib.Shortcut) error {
+	v, err := oleutil.CallMethod(shell, "CreateShortcut", p)
+	if err != nil {
+		return err
+	}
+	lnk := v.ToIDispatch()
+	defer lnk.Release()
+	table := []struct {
+		name, value string
+	}{
+		{"TargetPath", sc.Target},
+		{"WorkingDirectory", filepath.Dir(sc.Target)},
+		{"Arguments", sc.Args},
+		{"IconLocation", sc.Icon},
+		{"Description", sc.Description},
+	}
+	for _, prop := range table {
+		if prop.value == "" {
+			continue
+		}
+		if _, err := oleutil.PutProperty(lnk, prop.name, prop.value); err != nil {
+			return fmt.Errorf("error setting %s: %v", prop.name, err)
+		}
+	}
+	_, err = oleutil.CallMethod(lnk, "Save")
+	return err
+}
+
+// RemoveShortcuts removes the shortcuts created by AddShortcuts and the
+// Start Menu folders they leave empty.
+func RemoveShortcuts(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	dir, err := startMenu()
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, p := range paths {
+		logger.Infof("Removing shortcut %q.", p)
+		if err := oswrap.Remove(p); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err.Error())
+			continue
+		}
+		for d := filepath.Dir(p); len(d) > len(dir) && strings.HasPrefix(strings.ToLower(d), strings.ToLower(dir)); d = filepath.Dir(d) {
+			if os.Remove(d) != nil {
+				break
+			}
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("error removing shortcuts: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -15,10 +15,17 @@ limitations under the License.
 package system
 
 import (
+	"context"
+	"errors"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/google/googet/v2/goolib"
 	"github.com/google/googet/v2/oswrap"
+	"github.com/google/googet/v2/report"
+	"github.com/google/googet/v2/telemetry"
 	"github.com/google/logger"
 )
 
@@ -66,13 +73,94 @@ var (
 	}
 )
 
-// Verify runs a verify command given a package extraction directory and a PkgSpec struct.
-func Verify(dir string, ps *goolib.PkgSpec) error {
+// ScriptTimeout limits the run time of install, uninstall and verify commands
+// that don't set a Timeout, 0 means no limit.
+var ScriptTimeout = time.Hour
+
+// scriptContext returns a context that is done after the Timeout of e, or
+// ScriptTimeout if it is not set.
+func scriptContext(ctx context.Context, e goolib.ExecFile) (context.Context, context.CancelFunc) {
+	d, ok, err := e.ParseTimeout()
+	if err != nil {
+		logger.Errorf("Invalid timeout of %q, using the default: %v", e.Path, err)
+	}
+	if !ok {
+		d = ScriptTimeout
+	}
+	if d == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// msiProperties returns the msiexec arguments setting props, sorted, as
+// NAME="VALUE" with the quotes in values doubled as msiexec expects.
+func msiProperties(props map[string]string) []string {
+	var args []string
+	for k, v := range props {
+		args = append(args, k+`="`+strings.ReplaceAll(v, `"`, `""`)+`"`)
+	}
+	sort.Strings(args)
+	return args
+}
+
+// ScriptRetries is the number of times a command exiting with one of its
+// RetryExitCodes is run again.
+var ScriptRetries = 2
+
+// retryDelay is the time waited before a command is run again.
+var retryDelay = 10 * time.Second
+
+// runScript runs the command e with run, which returns the error of
+// goolib.Run, within the timeout of e and retrying it on its RetryExitCodes.
+// It reports whether the command exited with one of rebootCodes or its
+// RebootExitCodes, a success requiring a reboot.
+func runScript(ctx context.Context, e goolib.ExecFile, rebootCodes []int, run func(context.Context) error) (reboot bool, err error) {
+	ctx, span := telemetry.Start(ctx, "script", "googet.script", e.Path)
+	defer func() { span.End(err) }()
+	ctx, cancel := scriptContext(ctx, e)
+	defer cancel()
+	rebootCodes = append(append([]int{}, rebootCodes...), e.RebootExitCodes...)
+	for i := 0; ; i++ {
+		err := run(ctx)
+		var ee *goolib.ExitCodeError
+		if !errors.As(err, &ee) {
+			code := 0
+			if err != nil {
+				code = -1
+			}
+			report.ScriptExited(e.Path, code, err)
+			return false, err
+		}
+		report.ScriptExited(e.Path, ee.Code, nil)
+		switch {
+		case goolib.ContainsInt(ee.Code, rebootCodes):
+			logger.Infof("Command %q exited with code %d, a reboot is required", e.Path, ee.Code)
+			return true, nil
+		case goolib.ContainsInt(ee.Code, e.RetryExitCodes) && i < ScriptRetries:
+			logger.Infof("Command %q exited with code %d, retrying", e.Path, ee.Code)
+			select {
+			case <-time.After(retryDelay):
+			case <-ctx.Done():
+				return false, err
+			}
+		default:
+			return false, err
+		}
+	}
+}
+
+// Verify runs a verify command given a package extraction directory and a PkgSpec struct,
+// the command is killed when ctx is done or it times out.
+func Verify(ctx context.Context, dir string, ps *goolib.PkgSpec) error {
 	v := ps.Verify
 	if v.Path == "" {
 		return nil
 	}
 
+	if err := ps.CheckInterpreters(); err != nil {
+		return err
+	}
 	logger.Infof("Running verify command: %q", v.Path)
 	out, err := oswrap.Create(filepath.Join(dir, "googet_verify.log"))
 	if err != nil {
@@ -83,5 +171,8 @@ func Verify(dir string, ps *goolib.PkgSpec) error {
 			logger.Error(err)
 		}
 	}()
-	return goolib.Exec(filepath.Join(dir, v.Path), v.Args, v.ExitCodes, out)
+	_, err = runScript(ctx, v, nil, func(ctx context.Context) error {
+		return goolib.ExecContext(ctx, filepath.Join(dir, v.Path), v.Args, v.ExitCodes, out)
+	})
+	return err
 }
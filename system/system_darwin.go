@@ -0,0 +1,62 @@
+//go:build darwin
+// +build darwin
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// DefaultRoot is the GooGet root directory used when none is given.
+const DefaultRoot = "/Library/Application Support/GooGet"
+
+// InstallableArchs returns a slice of archs supported by this machine.
+func InstallableArchs() ([]string, error) {
+	m, err := syscall.Sysctl("hw.machine")
+	if err != nil {
+		return nil, fmt.Errorf("error getting machine hardware name: %v", err)
+	}
+	// An x86_64 build of GooGet running under Rosetta sees an x86_64 machine.
+	translated, err := syscall.SysctlUint32("sysctl.proc_translated")
+	return darwinArchs(m, err == nil && translated == 1)
+}
+
+// darwinArchs returns the archs supported by a Mac with the given hardware
+// name, translated being set if GooGet runs under Rosetta. Apple silicon Macs
+// prefer arm64 packages and run x86_64 ones under Rosetta.
+func darwinArchs(m string, translated bool) ([]string, error) {
+	switch {
+	case m == "arm64" || translated:
+		return []string{"noarch", "arm64", "x86_64"}, nil
+	case m == "x86_64":
+		return []string{"noarch", "x86_64"}, nil
+	default:
+		return nil, fmt.Errorf("machine %q not supported", m)
+	}
+}
+
+// BootTime returns when the machine last booted, the kern.boottime sysctl.
+func BootTime() (time.Time, error) {
+	tv, err := unix.SysctlTimeval("kern.boottime")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting boot time: %v", err)
+	}
+	return time.Unix(tv.Unix()), nil
+}
@@ -0,0 +1,44 @@
+//go:build darwin
+// +build darwin
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDarwinArchs(t *testing.T) {
+	table := []struct {
+		machine    string
+		translated bool
+		want       []string
+	}{
+		{"x86_64", false, []string{"noarch", "x86_64"}},
+		{"x86_64", true, []string{"noarch", "arm64", "x86_64"}},
+		{"arm64", false, []string{"noarch", "arm64", "x86_64"}},
+		{"ppc", false, nil},
+	}
+	for _, tt := range table {
+		got, err := darwinArchs(tt.machine, tt.translated)
+		if (err != nil) != (tt.want == nil) {
+			t.Errorf("darwinArchs(%q, %t) returned error %v", tt.machine, tt.translated, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("darwinArchs(%q, %t) = %v, want %v", tt.machine, tt.translated, got, tt.want)
+		}
+	}
+}
@@ -17,60 +17,78 @@ limitations under the License.
 package system
 
 import (
+	"bufio"
 	"fmt"
-	"path/filepath"
-
-	"github.com/google/googet/v2/goolib"
-	"github.com/google/googet/v2/oswrap"
-	"github.com/google/logger"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
-// Install performs a system specfic install given a package extraction directory and a PkgSpec struct.
-func Install(dir string, ps *goolib.PkgSpec) error {
-	in := ps.Install
-	if in.Path == "" {
-		return nil
-	}
+// DefaultRoot is the GooGet root directory used when none is given.
+const DefaultRoot = "/var/lib/googet"
 
-	logger.Infof("Running install command: %q", in.Path)
-	out, err := oswrap.Create(filepath.Join(dir, "googet_install.log"))
-	if err != nil {
-		return err
+// InstallableArchs returns a slice of archs supported by this machine.
+func InstallableArchs() ([]string, error) {
+	var u syscall.Utsname
+	if err := syscall.Uname(&u); err != nil {
+		return nil, fmt.Errorf("error getting machine hardware name: %v", err)
 	}
-	defer func() {
-		if err := out.Close(); err != nil {
-			logger.Error(err)
+	var m []byte
+	for _, c := range u.Machine {
+		if c == 0 {
+			break
 		}
-	}()
-	if err := goolib.Exec(filepath.Join(dir, in.Path), in.Args, in.ExitCodes, out); err != nil {
-		return fmt.Errorf("error running install: %v", err)
+		m = append(m, byte(c))
 	}
-	return nil
+	return machineArchs(string(m))
 }
 
-// Uninstall performs a system specfic uninstall given a package extraction directory and a PkgSpec struct.
-func Uninstall(dir string, ps *goolib.PkgSpec) error {
-	un := ps.Uninstall
-	if un.Path == "" {
-		return nil
+// machineArchs returns the archs supported by a machine with the given
+// hardware name, as reported by uname -m.
+func machineArchs(m string) ([]string, error) {
+	switch {
+	case m == "x86_64":
+		return []string{"noarch", "x86_64"}, nil
+	case len(m) == 4 && m[0] == 'i' && strings.HasSuffix(m, "86"):
+		return []string{"noarch", "x86_32"}, nil
+	case m == "aarch64" || m == "arm64":
+		return []string{"noarch", "arm64"}, nil
+	case strings.HasPrefix(m, "arm"):
+		return []string{"noarch", "arm"}, nil
+	default:
+		return nil, fmt.Errorf("machine %q not supported", m)
 	}
+}
 
-	logger.Infof("Running uninstall command: %q", un.Path)
-	// logging is only useful for failed uninstalls
-	out, err := oswrap.Create(filepath.Join(dir, "googet_remove.log"))
+// BootTime returns when the machine last booted, the btime of /proc/stat.
+func BootTime() (time.Time, error) {
+	f, err := os.Open("/proc/stat")
 	if err != nil {
-		return err
+		return time.Time{}, err
 	}
-	defer func() {
-		if err := out.Close(); err != nil {
-			logger.Error(err)
-		}
-	}()
-	return goolib.Exec(filepath.Join(dir, un.Path), un.Args, un.ExitCodes, out)
+	defer f.Close()
+	return parseBootTime(f)
 }
 
-// InstallableArchs returns a slice of archs supported by this machine.
-func InstallableArchs() ([]string, error) {
-	// Just return all archs as Linux builds are currently just used for testing.
-	return []string{"noarch", "x86_64", "x86_32", "arm", "arm64"}, nil
+// parseBootTime returns the btime of the /proc/stat read from r.
+func parseBootTime(r io.Reader) (time.Time, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) != 2 || f[0] != "btime" {
+			continue
+		}
+		sec, err := strconv.ParseInt(f[1], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid btime %q: %v", f[1], err)
+		}
+		return time.Unix(sec, 0), nil
+	}
+	if err := s.Err(); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, fmt.Errorf("no btime in /proc/stat")
 }
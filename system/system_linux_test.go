@@ -0,0 +1,61 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMachineArchs(t *testing.T) {
+	table := []struct {
+		machine string
+		want    []string
+	}{
+		{"x86_64", []string{"noarch", "x86_64"}},
+		{"i686", []string{"noarch", "x86_32"}},
+		{"i386", []string{"noarch", "x86_32"}},
+		{"aarch64", []string{"noarch", "arm64"}},
+		{"armv7l", []string{"noarch", "arm"}},
+		{"s390x", nil},
+	}
+	for _, tt := range table {
+		got, err := machineArchs(tt.machine)
+		if (err != nil) != (tt.want == nil) {
+			t.Errorf("machineArchs(%q) returned error %v", tt.machine, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("machineArchs(%q) = %v, want %v", tt.machine, got, tt.want)
+		}
+	}
+
+	if _, err := InstallableArchs(); err != nil {
+		t.Errorf("InstallableArchs returned error %v", err)
+	}
+}
+
+func TestParseBootTime(t *testing.T) {
+	got, err := parseBootTime(strings.NewReader("cpu  1 2 3 4\nintr 5\nbtime 1700000000\nprocesses 6\n"))
+	if err != nil || !got.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("parseBootTime = %v, %v, want %v", got, err, time.Unix(1700000000, 0))
+	}
+	if _, err := parseBootTime(strings.NewReader("cpu  1 2 3 4\n")); err == nil {
+		t.Error("parseBootTime without btime returned no error")
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/googet/v2/goolib"
+)
+
+func TestRunScript(t *testing.T) {
+	retryDelay = 0
+	e := goolib.ExecFile{Path: "install.cmd", RebootExitCodes: []int{3010}, RetryExitCodes: []int{5}}
+	table := []struct {
+		desc       string
+		codes      []int
+		rebootCode []int
+		wantReboot bool
+		wantErr    bool
+		wantRuns   int
+	}{
+		{"success", []int{0}, nil, false, false, 1},
+		{"reboot", []int{3010}, nil, true, false, 1},
+		{"installer reboot", []int{1641}, []int{1641}, true, false, 1},
+		{"fatal", []int{1}, nil, false, true, 1},
+		{"retried", []int{5, 0}, nil, false, false, 2},
+		{"retried reboot", []int{5, 3010}, nil, true, false, 2},
+		{"retries exhausted", []int{5, 5, 5, 0}, nil, false, true, 3},
+	}
+	for _, tt := range table {
+		var runs int
+		reboot, err := runScript(context.Background(), e, tt.rebootCode, func(context.Context) error {
+			c := tt.codes[runs]
+			runs++
+			if c == 0 {
+				return nil
+			}
+			return &goolib.ExitCodeError{Code: c}
+		})
+		if reboot != tt.wantReboot || (err != nil) != tt.wantErr || runs != tt.wantRuns {
+			t.Errorf("%s: runScript = %t, %v after %d runs, want %t, error: %t after %d runs", tt.desc, reboot, err, runs, tt.wantReboot, tt.wantErr, tt.wantRuns)
+		}
+	}
+
+	want := errors.New("not started")
+	if _, err := runScript(context.Background(), e, nil, func(context.Context) error { return want }); err != want {
+		t.Errorf("runScript returned error %v, want %v", err, want)
+	}
+}
+
+type mapEnv map[string]string
+
+func (e mapEnv) Get(name string) (string, bool, error) {
+	v, ok := e[name]
+	return v, ok, nil
+}
+
+func (e mapEnv) Set(name string, value *string) error {
+	if value == nil {
+		delete(e, name)
+		return nil
+	}
+	e[name] = *value
+	return nil
+}
+
+func (e mapEnv) Commit() error { return nil }
+func (e mapEnv) Close() error  { return nil }
+
+func TestSetEnv(t *testing.T) {
+	e := mapEnv{pathVar: "/bin" + pathSeparator + "/usr/bin", "EDITOR": "vi"}
+	v1 := &goolib.EnvSpec{Path: []string{"/opt/foo/bin", "/bin"}, Vars: map[string]string{"FOO_HOME": "/opt/foo", "EDITOR": "foo"}}
+	c, err := setEnv(e, v1, nil)
+	if err != nil {
+		t.Fatalf("setEnv: %v", err)
+	}
+	if want := "/bin" + pathSeparator + "/usr/bin" + pathSeparator + "/opt/foo/bin"; e[pathVar] != want {
+		t.Errorf("%s = %q, want %q", pathVar, e[pathVar], want)
+	}
+	if e["FOO_HOME"] != "/opt/foo" || e["EDITOR"] != "foo" {
+		t.Errorf("vars not set: %v", e)
+	}
+
+	// An upgrade dropping FOO_HOME restores it, keeps the rest.
+	v2 := &goolib.EnvSpec{Path: []string{"/opt/foo/bin"}, Vars: map[string]string{"EDITOR": "foo"}}
+	c, err = setEnv(e, v2, c)
+	if err != nil {
+		t.Fatalf("setEnv: %v", err)
+	}
+	if _, ok := e["FOO_HOME"]; ok {
+		t.Errorf("FOO_HOME still set after upgrade: %v", e)
+	}
+
+	// The user changes EDITOR, remove leaves it.
+	e["EDITOR"] = "emacs"
+	if err := undoEnv(e, v2, c); err != nil {
+		t.Fatalf("undoEnv: %v", err)
+	}
+	want := mapEnv{pathVar: "/bin" + pathSeparator + "/usr/bin", "EDITOR": "emacs"}
+	if len(e) != len(want) || e[pathVar] != want[pathVar] || e["EDITOR"] != want["EDITOR"] {
+		t.Errorf("after undoEnv got %v, want %v", e, want)
+	}
+}
+
+func TestMSIProperties(t *testing.T) {
+	got := msiProperties(map[string]string{"INSTALLDIR": `C:\Foo Bar`, "ADDLOCAL": "ALL", "NAME": `say "hi"`})
+	want := []string{`ADDLOCAL="ALL"`, `INSTALLDIR="C:\Foo Bar"`, `NAME="say ""hi"""`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("msiProperties = %v, want %v", got, want)
+	}
+}
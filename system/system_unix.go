@@ -0,0 +1,136 @@
+//go:build linux || darwin
+// +build linux darwin
+
+/*
+Copyright 2016 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"syscall"
+
+	"github.com/google/googet/v2/goolib"
+	"github.com/google/googet/v2/oswrap"
+	"github.com/google/logger"
+)
+
+var errMSI = errors.New("MSI packages are only supported on Windows")
+
+// Install performs a system specfic install given a package extraction directory and a PkgSpec struct,
+// the command is killed when ctx is done or it times out. It reports whether a reboot is required.
+func Install(ctx context.Context, dir string, ps *goolib.PkgSpec) (bool, error) {
+	in := ps.Install
+	if in.Path == "" {
+		return false, nil
+	}
+
+	if err := ps.CheckInterpreters(); err != nil {
+		return false, err
+	}
+	logger.Infof("Running install command: %q", in.Path)
+	out, err := oswrap.Create(filepath.Join(dir, "googet_install.log"))
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := out.Close(); err != nil {
+			logger.Error(err)
+		}
+	}()
+	reboot, err := runScript(ctx, in, nil, func(ctx context.Context) error {
+		return goolib.ExecContext(ctx, filepath.Join(dir, in.Path), in.Args, in.ExitCodes, out)
+	})
+	if err != nil {
+		return false, fmt.Errorf("error running install: %v", err)
+	}
+	return reboot, nil
+}
+
+// Uninstall performs a system specfic uninstall given a package extraction directory and a PkgSpec struct,
+// the command is killed when ctx is done or it times out.
+func Uninstall(ctx context.Context, dir string, ps *goolib.PkgSpec) error {
+	un := ps.Uninstall
+	if un.Path == "" {
+		return nil
+	}
+
+	if err := ps.CheckInterpreters(); err != nil {
+		return err
+	}
+	logger.Infof("Running uninstall command: %q", un.Path)
+	// logging is only useful for failed uninstalls
+	out, err := oswrap.Create(filepath.Join(dir, "googet_remove.log"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := out.Close(); err != nil {
+			logger.Error(err)
+		}
+	}()
+	reboot, err := runScript(ctx, un, nil, func(ctx context.Context) error {
+		return goolib.ExecContext(ctx, filepath.Join(dir, un.Path), un.Args, un.ExitCodes, out)
+	})
+	if reboot {
+		fmt.Printf("A reboot is required to complete the removal of %s\n", ps)
+	}
+	return err
+}
+
+// AddShortcuts does nothing, shortcuts are only created on Windows.
+func AddShortcuts(s []goolib.Shortcut) ([]string, error) {
+	return nil, nil
+}
+
+// RemoveShortcuts does nothing, shortcuts are only created on Windows.
+func RemoveShortcuts(paths []string) error {
+	return nil
+}
+
+// ReleaseFiles does nothing, files in use can be replaced on Linux and darwin.
+func ReleaseFiles(paths []string, stopServices bool) (func(), error) {
+	return func() {}, nil
+}
+
+// InstallMSI returns an error, MSI packages are only supported on Windows.
+func InstallMSI(ctx context.Context, dir string, m *goolib.MSISpec, repair bool) (string, bool, error) {
+	return "", false, errMSI
+}
+
+// UninstallMSI returns an error, MSI packages are only supported on Windows.
+func UninstallMSI(ctx context.Context, dir, productCode string, m *goolib.MSISpec) error {
+	return errMSI
+}
+
+// MSIProduct returns an error, MSI packages are only supported on Windows.
+func MSIProduct(productCode string) (bool, string, error) {
+	return false, "", errMSI
+}
+
+// DiskSpace returns an identifier of the volume holding path, which must
+// exist, and the space available on it to the user.
+func DiskSpace(path string) (string, uint64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return "", 0, err
+	}
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(path, &fs); err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprint(st.Dev), fs.Bavail * uint64(fs.Bsize), nil
+}
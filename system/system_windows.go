@@ -17,8 +17,10 @@ limitations under the License.
 package system
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -26,17 +28,25 @@ import (
 	"runtime"
 	"strings"
 	"time"
+	"unsafe"
 
 	"github.com/google/googet/v2/goolib"
 	"github.com/google/googet/v2/oswrap"
 	"github.com/google/logger"
 	"github.com/yusufpapurcu/wmi"
+	"golang.org/x/sys/windows"
 	"golang.org/x/sys/windows/registry"
 )
 
 const uninstallBase = `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall\`
 
-var msiSuccessCodes = []int{1641, 3010}
+// DefaultRoot is the GooGet root directory used when none is given, there is
+// none on Windows where the installer sets the GooGetRoot environment variable.
+const DefaultRoot = ""
+
+// msiRebootCodes are the exit codes of msiexec and wusa meaning success but
+// a reboot is required.
+var msiRebootCodes = []int{1641, 3010}
 
 func addUninstallEntry(dir string, ps *goolib.PkgSpec) error {
 	reg := uninstallBase + "GooGet - " + ps.Name
@@ -58,11 +68,25 @@ func addUninstallEntry(dir string, ps *goolib.PkgSpec) error {
 		{"DisplayName", "GooGet - " + ps.Name},
 		{"InstallDate", time.Now().Format("20060102")},
 	}
+	if e := ps.UninstallEntry; e != nil {
+		table = append(table, []struct {
+			name, value string
+		}{
+			{"DisplayIcon", e.DisplayIcon},
+			{"URLInfoAbout", e.URLInfoAbout},
+		}...)
+	}
 	for _, re := range table {
+		if re.value == "" {
+			continue
+		}
 		if err := k.SetStringValue(re.name, re.value); err != nil {
 			return err
 		}
 	}
+	if ps.UninstallEntry != nil && ps.UninstallEntry.EstimatedSize != 0 {
+		return k.SetDWordValue("EstimatedSize", ps.UninstallEntry.EstimatedSize)
+	}
 	return nil
 }
 
@@ -136,17 +160,21 @@ func uninstallString(publisher, installSource, programName, extension string) st
 
 }
 
-// Install performs a system specfic install given a package extraction directory and a PkgSpec struct.
-func Install(dir string, ps *goolib.PkgSpec) error {
+// Install performs a system specfic install given a package extraction directory and a PkgSpec struct,
+// the command is killed when ctx is done or it times out. It reports whether a reboot is required.
+func Install(ctx context.Context, dir string, ps *goolib.PkgSpec) (bool, error) {
 	in := ps.Install
 	if in.Path == "" {
-		return nil
+		return false, nil
 	}
 
+	if err := ps.CheckInterpreters(); err != nil {
+		return false, err
+	}
 	logger.Infof("Running install command: %q", in.Path)
 	out, err := oswrap.Create(filepath.Join(dir, in.Path+".log"))
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer func() {
 		if err := out.Close(); err != nil {
@@ -155,40 +183,51 @@ func Install(dir string, ps *goolib.PkgSpec) error {
 	}()
 	s := filepath.Join(dir, in.Path)
 	msiLog := filepath.Join(dir, "msi_install.log")
-	ec := append(msiSuccessCodes, in.ExitCodes...)
+	var name string
+	var args []string
 	switch filepath.Ext(s) {
 	case ".msi":
-		args := append([]string{"/i", s, "/qn", "/norestart", "/log", msiLog}, in.Args...)
-		err = goolib.Run(exec.Command("msiexec", args...), ec, out)
+		name, args = "msiexec", append([]string{"/i", s, "/qn", "/norestart", "/log", msiLog}, in.Args...)
 	case ".msp":
-		args := append([]string{"/update", s, "/qn", "/norestart", "/log", msiLog}, in.Args...)
-		err = goolib.Run(exec.Command("msiexec", args...), ec, out)
+		name, args = "msiexec", append([]string{"/update", s, "/qn", "/norestart", "/log", msiLog}, in.Args...)
 	case ".msu":
-		args := append([]string{s, "/quiet", "/norestart"}, in.Args...)
-		err = goolib.Run(exec.Command("wusa", args...), ec, out)
+		name, args = "wusa", append([]string{s, "/quiet", "/norestart"}, in.Args...)
 	case ".exe":
-		err = goolib.Run(exec.Command(s, in.Args...), ec, out)
+		name, args = s, in.Args
 	case ".msix", ".msixbundle":
 		// Add-AppxProvisionedPackage will install for all users.
 		installCmd := fmt.Sprintf("Add-AppxProvisionedPackage -online -PackagePath %v -SkipLicense", s)
-		args := append([]string{installCmd}, in.Args...)
-		err = goolib.Run(exec.Command("powershell", args...), ec, out)
-	default:
-		err = goolib.Exec(s, in.Args, in.ExitCodes, out)
+		name, args = "powershell", append([]string{installCmd}, in.Args...)
 	}
+	reboot, err := runCommand(ctx, in, name, args, s, out)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if err := addUninstallEntry(dir, ps); err != nil {
 		logger.Error(err)
 	}
 
-	return nil
+	return reboot, nil
 }
 
-// Uninstall performs a system specfic uninstall given a packages PackageState.
-func Uninstall(dir string, ps *goolib.PkgSpec) error {
+// runCommand runs the installer command name with args, or the script s if
+// name is empty, with runScript. The installer exit codes of msiRebootCodes
+// require a reboot.
+func runCommand(ctx context.Context, e goolib.ExecFile, name string, args []string, s string, out io.Writer) (bool, error) {
+	if name == "" {
+		return runScript(ctx, e, nil, func(ctx context.Context) error {
+			return goolib.ExecContext(ctx, s, e.Args, e.ExitCodes, out)
+		})
+	}
+	return runScript(ctx, e, msiRebootCodes, func(ctx context.Context) error {
+		return goolib.RunContext(ctx, exec.Command(name, args...), e.ExitCodes, out)
+	})
+}
+
+// Uninstall performs a system specfic uninstall given a packages PackageState,
+// the command is killed when ctx is done or it times out.
+func Uninstall(ctx context.Context, dir string, ps *goolib.PkgSpec) error {
 	var filePath string
 	un := ps.Uninstall
 	r := regexp.MustCompile(`[^\s"]+|"([^"]*)"`)
@@ -221,6 +260,9 @@ func Uninstall(dir string, ps *goolib.PkgSpec) error {
 		}
 	}
 
+	if err := ps.CheckInterpreters(); err != nil {
+		return err
+	}
 	logger.Infof("Running uninstall command: %q", un.Path)
 	// logging is only useful for failed uninstall
 	// Only append the directory if the folder structure doesn't exist
@@ -240,28 +282,28 @@ func Uninstall(dir string, ps *goolib.PkgSpec) error {
 	if filePath == "" {
 		filePath = filepath.Join(dir, un.Path)
 	}
-	ec := append(msiSuccessCodes, un.ExitCodes...)
+	var name string
+	var args []string
 	switch filepath.Ext(filePath) {
 	case ".msi":
 		msiLog := filepath.Join(dir, "msi_uninstall.log")
-		args := append([]string{"/x", filePath, "/qn", "/norestart", "/log", msiLog}, un.Args...)
-		err = goolib.Run(exec.Command("msiexec", args...), ec, out)
+		name, args = "msiexec", append([]string{"/x", filePath, "/qn", "/norestart", "/log", msiLog}, un.Args...)
 	case ".msu":
-		args := append([]string{filePath, "/uninstall", "/quiet", "/norestart"}, un.Args...)
-		err = goolib.Run(exec.Command("wusa", args...), ec, out)
+		name, args = "wusa", append([]string{filePath, "/uninstall", "/quiet", "/norestart"}, un.Args...)
 	case ".exe":
-		err = goolib.Run(exec.Command(filePath, un.Args...), ec, out)
+		name, args = filePath, un.Args
 	case ".msix", ".msixbundle":
 		s := strings.Split(filepath.Base(filePath), "_")[0]
 		removeCmd := fmt.Sprintf(`Get-AppxProvisionedPackage -online | Where {$_.DisplayName -match "%v*"} | Remove-AppProvisionedPackage -online -AllUsers`, s)
-		args := append([]string{removeCmd}, un.Args...)
-		err = goolib.Run(exec.Command("powershell", args...), ec, out)
-	default:
-		err = goolib.Exec(filepath.Join(dir, un.Path), un.Args, un.ExitCodes, out)
+		name, args = "powershell", append([]string{removeCmd}, un.Args...)
 	}
+	reboot, err := runCommand(ctx, un, name, args, filepath.Join(dir, un.Path), out)
 	if err != nil {
 		return err
 	}
+	if reboot {
+		fmt.Printf("A reboot is required to complete the removal of %s\n", ps)
+	}
 
 	if err := removeUninstallEntry(ps.Name); err != nil {
 		logger.Error(err)
@@ -282,9 +324,43 @@ func width() (int, error) {
 	return int(p[0].AddressWidth), nil
 }
 
+// Machine types of IsWow64Process2 and IsWow64GuestMachineSupported.
+const (
+	imageFileMachineAMD64 = 0x8664
+	imageFileMachineARM64 = 0xaa64
+)
+
+var procIsWow64GuestMachineSupported = windows.NewLazySystemDLL("kernel32.dll").NewProc("IsWow64GuestMachineSupported")
+
+// x64Emulation reports whether this machine runs x86_64 binaries under
+// emulation, which ARM64 machines do from Windows 11.
+func x64Emulation() bool {
+	if procIsWow64GuestMachineSupported.Find() != nil {
+		return false
+	}
+	var supported int32
+	hr, _, _ := procIsWow64GuestMachineSupported.Call(imageFileMachineAMD64, uintptr(unsafe.Pointer(&supported)))
+	return hr == 0 && supported != 0
+}
+
+// arm64Archs returns the archs of an ARM64 machine in order of preference,
+// native arm64 packages first, x86_64 ones if they can be emulated and
+// noarch last.
+func arm64Archs(x64 bool) []string {
+	if x64 {
+		return []string{"arm64", "x86_64", "x86_32", "noarch"}
+	}
+	return []string{"arm64", "x86_32", "noarch"}
+}
+
 // InstallableArchs returns a slice of archs supported by this machine.
 // WMI errors are logged but not returned.
 func InstallableArchs() ([]string, error) {
+	// An x86 build of GooGet on an ARM64 machine runs emulated.
+	var pm, nm uint16
+	if err := windows.IsWow64Process2(windows.CurrentProcess(), &pm, &nm); err == nil && nm == imageFileMachineARM64 {
+		return arm64Archs(x64Emulation()), nil
+	}
 	switch {
 	case runtime.GOARCH == "386":
 		// Check if this is indeed a 32bit system.
@@ -302,8 +378,43 @@ func InstallableArchs() ([]string, error) {
 	case runtime.GOARCH == "arm":
 		return []string{"noarch", "arm"}, nil
 	case runtime.GOARCH == "arm64":
-		return []string{"noarch", "x86_32", "x86_64", "arm64"}, nil
+		return arm64Archs(x64Emulation()), nil
 	default:
 		return nil, fmt.Errorf("runtime %s not supported", runtime.GOARCH)
 	}
 }
+
+// DiskSpace returns the volume holding path, which must exist, and the space
+// available on it to the user.
+func DiskSpace(path string) (string, uint64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return "", 0, err
+	}
+	vol := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumePathName(p, &vol[0], uint32(len(vol))); err != nil {
+		return "", 0, fmt.Errorf("error getting the volume of %q: %v", path, err)
+	}
+	var free uint64
+	if err := windows.GetDiskFreeSpaceEx(&vol[0], &free, nil, nil); err != nil {
+		return "", 0, fmt.Errorf("error getting the free space of %q: %v", path, err)
+	}
+	return windows.UTF16ToString(vol), free, nil
+}
+
+var procGetTickCount64 = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetTickCount64")
+
+// BootTime returns when the machine last booted, from the milliseconds
+// elapsed since.
+func BootTime() (time.Time, error) {
+	if err := procGetTickCount64.Find(); err != nil {
+		return time.Time{}, err
+	}
+	lo, hi, _ := procGetTickCount64.Call()
+	ms := uint64(lo)
+	if unsafe.Sizeof(lo) == 4 {
+		// 32 bit processes get the high half in EDX.
+		ms |= uint64(hi) << 32
+	}
+	return time.Now().Add(-time.Duration(ms) * time.Millisecond), nil
+}
@@ -0,0 +1,295 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package telemetry records spans of the work of GooGet, such as fetching
+// repos, downloading packages or running their scripts, and exports them
+// with a histogram of their durations to an OpenTelemetry collector, using
+// OTLP over HTTP with the JSON encoding.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoint is the base URL of the OTLP/HTTP receiver, such as
+// http://localhost:4318. Nothing is recorded if it is empty.
+var Endpoint string
+
+// Headers are added to the export requests, for example to authenticate.
+var Headers map[string]string
+
+// Resource holds the attributes describing this GooGet run.
+var Resource = map[string]string{"service.name": "googet"}
+
+// durationBounds are the bucket bounds, in seconds, of the duration
+// histogram.
+var durationBounds = []float64{0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 600, 1800}
+
+// Span is an operation being timed. Its methods do nothing on a nil Span,
+// which Start returns when no Endpoint is set.
+type Span struct {
+	name     string
+	traceID  string
+	spanID   string
+	parentID string
+	start    time.Time
+	end      time.Time
+	attrs    map[string]string
+	err      error
+}
+
+type spanKey struct{}
+
+var (
+	mu    sync.Mutex
+	ended []*Span
+)
+
+func newID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Start starts the span name, a child of the span of ctx if any, with the
+// attributes given as key and value pairs. The returned context carries
+// the span.
+func Start(ctx context.Context, name string, attrs ...string) (context.Context, *Span) {
+	if Endpoint == "" {
+		return ctx, nil
+	}
+	s := &Span{name: name, spanID: newID(8), start: time.Now(), attrs: make(map[string]string)}
+	if p, ok := ctx.Value(spanKey{}).(*Span); ok {
+		s.traceID, s.parentID = p.traceID, p.spanID
+	} else {
+		s.traceID = newID(16)
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		s.attrs[attrs[i]] = attrs[i+1]
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// End ends s, which failed with err if it is not nil.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	s.end, s.err = time.Now(), err
+	ended = append(ended, s)
+}
+
+// Flush exports the spans ended so far and the histogram of their
+// durations.
+func Flush(ctx context.Context) error {
+	mu.Lock()
+	spans := ended
+	ended = nil
+	mu.Unlock()
+	if Endpoint == "" || len(spans) == 0 {
+		return nil
+	}
+	if err := export(ctx, "/v1/traces", traces(spans)); err != nil {
+		return err
+	}
+	return export(ctx, "/v1/metrics", metrics(spans))
+}
+
+func export(ctx context.Context, path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(Endpoint, "/")+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("OTLP export to %s returned status: %q", req.URL, res.Status)
+	}
+	return nil
+}
+
+// The OTLP JSON encoding, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding. 64 bit
+// integers are encoded as strings.
+type (
+	keyValue struct {
+		Key   string   `json:"key"`
+		Value anyValue `json:"value"`
+	}
+	anyValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	scope struct {
+		Name string `json:"name"`
+	}
+	resource struct {
+		Attributes []keyValue `json:"attributes"`
+	}
+	status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	span struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              int        `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		Status            status     `json:"status"`
+	}
+	histogramPoint struct {
+		Attributes        []keyValue `json:"attributes"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		TimeUnixNano      string     `json:"timeUnixNano"`
+		Count             string     `json:"count"`
+		Sum               float64    `json:"sum"`
+		BucketCounts      []string   `json:"bucketCounts"`
+		ExplicitBounds    []float64  `json:"explicitBounds"`
+	}
+)
+
+// Span kinds and status codes of OTLP.
+const (
+	kindInternal = 1
+	statusOK     = 1
+	statusError  = 2
+	// temporalityDelta marks the histogram as covering only this run.
+	temporalityDelta = 1
+)
+
+func attributes(m map[string]string) []keyValue {
+	var kvs []keyValue
+	for k, v := range m {
+		kvs = append(kvs, keyValue{k, anyValue{v}})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs
+}
+
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func resourceOf() resource {
+	return resource{attributes(Resource)}
+}
+
+func traces(spans []*Span) interface{} {
+	var out []span
+	for _, s := range spans {
+		o := span{
+			TraceID:           s.traceID,
+			SpanID:            s.spanID,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              kindInternal,
+			StartTimeUnixNano: nanos(s.start),
+			EndTimeUnixNano:   nanos(s.end),
+			Attributes:        attributes(s.attrs),
+			Status:            status{Code: statusOK},
+		}
+		if s.err != nil {
+			o.Status = status{Code: statusError, Message: s.err.Error()}
+		}
+		out = append(out, o)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource":   resourceOf(),
+			"scopeSpans": []interface{}{map[string]interface{}{"scope": scope{"googet"}, "spans": out}},
+		}},
+	}
+}
+
+// metrics returns the histogram of the durations of spans by name.
+func metrics(spans []*Span) interface{} {
+	points := make(map[string]*histogramPoint)
+	var names []string
+	start, now := spans[0].start, time.Now()
+	for _, s := range spans {
+		if s.start.Before(start) {
+			start = s.start
+		}
+	}
+	for _, s := range spans {
+		p, ok := points[s.name]
+		if !ok {
+			p = &histogramPoint{
+				Attributes:        []keyValue{{"span.name", anyValue{s.name}}},
+				StartTimeUnixNano: nanos(start),
+				TimeUnixNano:      nanos(now),
+				BucketCounts:      make([]string, len(durationBounds)+1),
+				ExplicitBounds:    durationBounds,
+			}
+			points[s.name] = p
+			names = append(names, s.name)
+		}
+		d := s.end.Sub(s.start).Seconds()
+		p.Sum += d
+		p.Count = incr(p.Count)
+		i := sort.SearchFloat64s(durationBounds, d)
+		p.BucketCounts[i] = incr(p.BucketCounts[i])
+	}
+	sort.Strings(names)
+	var dps []histogramPoint
+	for _, n := range names {
+		dps = append(dps, *points[n])
+	}
+	return map[string]interface{}{
+		"resourceMetrics": []interface{}{map[string]interface{}{
+			"resource": resourceOf(),
+			"scopeMetrics": []interface{}{map[string]interface{}{
+				"scope": scope{"googet"},
+				"metrics": []interface{}{map[string]interface{}{
+					"name":        "googet.span.duration",
+					"description": "Duration of the operations of GooGet by span name.",
+					"unit":        "s",
+					"histogram":   map[string]interface{}{"aggregationTemporality": temporalityDelta, "dataPoints": dps},
+				}},
+			}},
+		}},
+	}
+}
+
+// incr increments the count c encoded as a string.
+func incr(c string) string {
+	n, _ := strconv.ParseUint(c, 10, 64)
+	return strconv.FormatUint(n+1, 10)
+}
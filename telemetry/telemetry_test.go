@@ -0,0 +1,122 @@
+/*
+Copyright 2026 Google Inc. All Rights Reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDisabled(t *testing.T) {
+	Endpoint = ""
+	ctx, s := Start(context.Background(), "op")
+	if s != nil {
+		t.Errorf("Start returned a span with no endpoint set")
+	}
+	s.End(nil)
+	if err := Flush(ctx); err != nil {
+		t.Errorf("Flush: %v", err)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	posted := make(map[string]map[string]interface{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("%s: missing header, got %v", r.URL.Path, r.Header)
+		}
+		var v map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			t.Errorf("%s: %v", r.URL.Path, err)
+		}
+		posted[r.URL.Path] = v
+	}))
+	defer ts.Close()
+	Endpoint, Headers = ts.URL+"/", map[string]string{"Authorization": "Bearer token"}
+	defer func() { Endpoint, Headers = "", nil }()
+
+	ctx, root := Start(context.Background(), "googet install")
+	_, dl := Start(ctx, "download", "googet.url", "https://repo/foo.goo")
+	dl.End(errors.New("bad checksum"))
+	_, dl = Start(ctx, "download", "googet.url", "https://repo/bar.goo")
+	dl.End(nil)
+	root.End(nil)
+	if err := Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	var traces struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []span
+			}
+		}
+	}
+	b, _ := json.Marshal(posted["/v1/traces"])
+	if err := json.Unmarshal(b, &traces); err != nil {
+		t.Fatal(err)
+	}
+	spans := traces.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	if spans[0].TraceID != spans[2].TraceID || spans[0].ParentSpanID != spans[2].SpanID || spans[2].ParentSpanID != "" {
+		t.Errorf("download span %+v is not a child of root span %+v", spans[0], spans[2])
+	}
+	if spans[0].Status.Code != statusError || spans[0].Status.Message != "bad checksum" {
+		t.Errorf("failed span status = %+v", spans[0].Status)
+	}
+	if len(spans[1].Attributes) != 1 || spans[1].Attributes[0].Value.StringValue != "https://repo/bar.goo" {
+		t.Errorf("span attributes = %+v", spans[1].Attributes)
+	}
+
+	var metrics struct {
+		ResourceMetrics []struct {
+			ScopeMetrics []struct {
+				Metrics []struct {
+					Name      string
+					Histogram struct {
+						DataPoints []histogramPoint
+					}
+				}
+			}
+		}
+	}
+	b, _ = json.Marshal(posted["/v1/metrics"])
+	if err := json.Unmarshal(b, &metrics); err != nil {
+		t.Fatal(err)
+	}
+	m := metrics.ResourceMetrics[0].ScopeMetrics[0].Metrics[0]
+	if m.Name != "googet.span.duration" {
+		t.Errorf("metric name = %q", m.Name)
+	}
+	got := make(map[string]string)
+	for _, p := range m.Histogram.DataPoints {
+		got[p.Attributes[0].Value.StringValue] = p.Count
+		if len(p.BucketCounts) != len(p.ExplicitBounds)+1 {
+			t.Errorf("%d buckets for %d bounds", len(p.BucketCounts), len(p.ExplicitBounds))
+		}
+	}
+	if got["download"] != "2" || got["googet install"] != "1" {
+		t.Errorf("histogram counts = %v", got)
+	}
+
+	if err := Flush(context.Background()); err != nil || len(ended) != 0 {
+		t.Errorf("second Flush: %v, %d spans left", err, len(ended))
+	}
+}
@@ -16,12 +16,9 @@ package verify
 
 import (
 	"archive/tar"
-	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
-	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -39,7 +36,7 @@ const (
 )
 
 func extractVerify(r io.Reader, verify, dir string) error {
-	zr, err := gzip.NewReader(r)
+	zr, err := goolib.NewPackageReader(r)
 	if err != nil {
 		return err
 	}
@@ -107,49 +104,59 @@ func Files(ps client.PackageState) (bool, error) {
 	return true, nil
 }
 
+// MSI checks that the MSI product installed by the package is still
+// installed, returning true if it is or the package has none.
+func MSI(ps client.PackageState) (bool, error) {
+	if ps.MSIProductCode == "" {
+		return true, nil
+	}
+	pkg := fmt.Sprintf("%s.%s.%s", ps.PackageSpec.Name, ps.PackageSpec.Arch, ps.PackageSpec.Version)
+	logger.Infof("Running MSI verification for %s", pkg)
+	fmt.Printf("Running MSI verification for %s...\n", pkg)
+	installed, _, err := system.MSIProduct(ps.MSIProductCode)
+	if err != nil {
+		return false, err
+	}
+	if !installed {
+		logger.Errorf("%q: verify MSI product %s failed, product is not installed", pkg, ps.MSIProductCode)
+	}
+	return installed, nil
+}
+
 // Command runs a packages verify command.
 // Will only return true if the verify command exits with 0 or an approved
 // return code.
-func Command(ctx context.Context, ps client.PackageState, proxyServer string) (bool, error) {
+func Command(ctx context.Context, ps client.PackageState, downloader client.Downloader) (bool, error) {
 	if ps.PackageSpec.Verify.Path == "" {
 		return true, nil
 	}
 	pkg := fmt.Sprintf("%s.%s.%s", ps.PackageSpec.Name, ps.PackageSpec.Arch, ps.PackageSpec.Version)
+	ctx = client.WithRepo(ctx, ps.SourceRepo)
 	logger.Infof("Running verification command for %s", pkg)
 	fmt.Printf("Running verification command for %s...\n", pkg)
-	f, err := os.Open(ps.LocalPath)
-	if err != nil && !os.IsNotExist(err) {
-		return false, err
-	}
 	var rd bool
-	if os.IsNotExist(err) {
-		logger.Infof("Local package does not exist for %s, pulling from repo...", pkg)
-		rd = true
-	}
-	// Force redownload if checksum does not match.
-	// If checksum is empty this was a local install so ignore.
-	if !rd && ps.Checksum != "" && goolib.Checksum(f) != ps.Checksum {
-		logger.Info("Local package checksum does not match, pulling from repo...")
+	if p, ok := download.Cached(ps); ok {
+		ps.LocalPath = p
+	} else {
+		logger.Infof("No cached package matches %s, pulling from repo...", pkg)
 		rd = true
 	}
 
 	dir := strings.TrimSuffix(ps.LocalPath, filepath.Ext(ps.LocalPath))
 	var r io.Reader
-	r = f
-	if rd {
+	if !rd {
+		f, err := os.Open(ps.LocalPath)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		r = f
+	} else {
 		if ps.DownloadURL == "" {
 			return false, fmt.Errorf("can not pull package %s from repo, DownloadURL not saved", pkg)
 		}
 
-		httpClient := &http.Client{}
-		if proxyServer != "" {
-			proxyURL, err := url.Parse(proxyServer)
-			if err != nil {
-				return false, err
-			}
-			httpClient.Transport = &http.Transport{Proxy: http.ProxyURL(proxyURL)}
-		}
-		resp, err := httpClient.Get(ps.DownloadURL)
+		resp, err := downloader.Get(ctx, ps.DownloadURL)
 		if err != nil {
 			return false, err
 		}
@@ -162,26 +169,28 @@ func Command(ctx context.Context, ps client.PackageState, proxyServer string) (b
 	if err := extractVerify(r, ps.PackageSpec.Verify.Path, dir); err != nil {
 		return false, err
 	}
-	f.Close()
 
 	// Try just running the extracted command, rextract the full package on any error.
-	if err := system.Verify(dir, ps.PackageSpec); err == nil {
+	if err := system.Verify(ctx, dir, ps.PackageSpec); err == nil {
 		return true, nil
 	}
+	if ctx.Err() != nil {
+		return false, fmt.Errorf("verify command of %s did not finish: %v", pkg, ctx.Err())
+	}
 
 	if rd {
-		if err := download.Package(ctx, ps.DownloadURL, ps.LocalPath, ps.Checksum, proxyServer); err != nil {
+		if err := download.Package(ctx, ps.DownloadURL, ps.LocalPath, ps.Checksum, downloader); err != nil {
 			return false, fmt.Errorf("error redownloading package: %v", err)
 		}
 	}
 
-	dir, err = download.ExtractPkg(ps.LocalPath)
+	dir, err := download.ExtractPkg(ps.LocalPath)
 	if err != nil {
 		return false, err
 	}
 
 	// Any error is deemed a verification failure.
-	if err := system.Verify(dir, ps.PackageSpec); err != nil {
+	if err := system.Verify(ctx, dir, ps.PackageSpec); err != nil {
 		logger.Errorf("%q: verify command failed: %v", pkg, err)
 		return false, nil
 	}